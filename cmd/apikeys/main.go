@@ -0,0 +1,129 @@
+// Command apikeys issues and revokes API keys for machine clients. The
+// plaintext key is printed once on creation; only its hash is stored.
+//
+// Usage:
+//
+//	apikeys create -name billing -scopes companies.read,companies.write -rate 600
+//	apikeys revoke -id 6f1c0f7e-8d1b-4c8e-9a57-3c1b2f0e9d4a
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/gartstein/xm/internal/company/db"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/pkg/secrets"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+const requestTimeout = 30 * time.Second
+
+// dbConfig holds the database settings read from the service config file.
+type dbConfig struct {
+	DBDriver   string `yaml:"DB_DRIVER"`
+	DBPath     string `yaml:"DB_PATH"`
+	DBHost     string `yaml:"DB_HOST"`
+	DBPort     int    `yaml:"DB_PORT"`
+	DBUser     string `yaml:"DB_USER"`
+	DBPassword string `yaml:"DB_PASSWORD"`
+	DBName     string `yaml:"DB_NAME"`
+	DBSSLMode  string `yaml:"DB_SSLMODE"`
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	configPath := fs.String("config", filepath.Join("internal", "company", "config", "config.yaml"), "service config file")
+	name := fs.String("name", "", "name of the integration owning the key (create only)")
+	scopes := fs.String("scopes", auth.ScopeRead, "comma-separated scopes (create only)")
+	rate := fs.Int("rate", 0, "requests per minute, 0 for unlimited (create only)")
+	id := fs.String("id", "", "key ID (revoke only)")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+
+	repo, err := openRepository(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer repo.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	switch os.Args[1] {
+	case "create":
+		if *name == "" {
+			log.Fatal("-name is required")
+		}
+		plaintext, hash, err := auth.GenerateAPIKey()
+		if err != nil {
+			log.Fatal(err)
+		}
+		key := &models.APIKey{
+			ID:        uuid.New(),
+			Name:      *name,
+			KeyHash:   hash,
+			Scopes:    strings.Split(*scopes, ","),
+			RateLimit: *rate,
+		}
+		if err := repo.CreateAPIKey(ctx, key); err != nil {
+			log.Fatalf("failed to create API key: %v", err)
+		}
+		fmt.Printf("id:  %s\nkey: %s\n", key.ID, plaintext)
+	case "revoke":
+		keyID, err := uuid.Parse(*id)
+		if err != nil {
+			log.Fatalf("invalid -id: %v", err)
+		}
+		if err := repo.RevokeAPIKey(ctx, keyID); err != nil {
+			log.Fatalf("failed to revoke API key: %v", err)
+		}
+		log.Printf("API key %s revoked", keyID)
+	default:
+		usage()
+	}
+}
+
+// openRepository connects to the database configured in the service config.
+func openRepository(path string) (*db.Repository, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg dbConfig
+	if err := yaml.Unmarshal(file, &cfg); err != nil {
+		return nil, err
+	}
+	password, err := secrets.FromEnv().Resolve(context.Background(), cfg.DBPassword)
+	if err != nil {
+		return nil, err
+	}
+	return db.NewRepository(&db.Config{
+		Driver:   cfg.DBDriver,
+		Path:     cfg.DBPath,
+		Host:     cfg.DBHost,
+		Port:     cfg.DBPort,
+		User:     cfg.DBUser,
+		Password: password,
+		DBName:   cfg.DBName,
+		SSLMode:  cfg.DBSSLMode,
+	})
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: apikeys <create|revoke> [-config <path>] [-name <name> -scopes <list> -rate <n>] [-id <uuid>]")
+	os.Exit(2)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuthAuditEvent records a single login attempt.
+type AuthAuditEvent struct {
+	ID uint `gorm:"primaryKey"`
+	// Action is the endpoint attempted: "token", "operation_token",
+	// "service_token", "totp_enroll" or "totp_verify".
+	Action    string
+	Username  string
+	IP        string
+	UserAgent string
+	Success   bool
+	// Reason explains a failure, e.g. "invalid credentials" or "locked out".
+	Reason    string
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// auditLog persists login attempts.
+type auditLog interface {
+	Record(ctx context.Context, event *AuthAuditEvent) error
+}
+
+// dbAuditLog stores audit events in the database.
+type dbAuditLog struct {
+	db *gorm.DB
+}
+
+func newDBAuditLog(db *gorm.DB) (*dbAuditLog, error) {
+	if err := db.AutoMigrate(&AuthAuditEvent{}); err != nil {
+		return nil, err
+	}
+	return &dbAuditLog{db: db}, nil
+}
+
+func (l *dbAuditLog) Record(ctx context.Context, event *AuthAuditEvent) error {
+	return l.db.WithContext(ctx).Create(event).Error
+}
+
+// stdoutAuditLog writes audit events to the standard logger, for running
+// without a database.
+type stdoutAuditLog struct{}
+
+func (stdoutAuditLog) Record(_ context.Context, event *AuthAuditEvent) error {
+	event.CreatedAt = time.Now()
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	log.Printf("auth audit: %s", b)
+	return nil
+}
@@ -1,20 +1,37 @@
 // This is a **mock authentication service**, designed to provide JWT tokens
-// for the company service, simulating user authentication.
+// for the company service, simulating user authentication. Failed logins
+// are throttled per IP and username, and every attempt is audited.
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/gartstein/xm/internal/pkg/secrets"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
 )
 
 const (
-	defaultPort   = "8081"       // Default port for the authentication service
-	defaultSecret = "jwt_secret" // Secret for signing JWT
+	defaultPort     = "8081"       // Default port for the authentication service
+	defaultSecret   = "jwt_secret" // Secret for signing JWT
+	defaultPassword = "password"   // Password accepted for any user by the mock credential check
+
+	maxLoginFailures = 5                // Failures before an IP or username is locked out
+	failureWindow    = 15 * time.Minute // Window in which failures are counted
+	lockoutDuration  = 15 * time.Minute // How long a lockout lasts
+
+	keysRefreshInterval = time.Minute     // How often a JWT_KEYS key set is reloaded
+	operationTokenTTL   = 5 * time.Minute // How long single-use operation tokens are valid
 )
 
 // TokenResponse represents the response structure
@@ -22,47 +39,366 @@ type TokenResponse struct {
 	Token string `json:"token"`
 }
 
-// tokenHandler generates a JWT and returns it in JSON response
-func tokenHandler(w http.ResponseWriter, _ *http.Request) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = defaultSecret
+// LoginRequest carries the credentials presented to /token and the TOTP
+// endpoints.
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// OTP is the current code of the user's authenticator, required once
+	// TOTP is enrolled.
+	OTP string `json:"otp,omitempty"`
+	// Operation is the full gRPC method an /operation-token token is for.
+	Operation string `json:"operation,omitempty"`
+}
+
+// EnrollResponse carries a new TOTP secret.
+type EnrollResponse struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth:// URI authenticator apps enroll from.
+	URI string `json:"uri"`
+}
+
+// Authentication methods reported in the "amr" claim (RFC 8176).
+const (
+	amrPassword = "pwd"
+	amrOTP      = "otp"
+)
+
+// credentialChecker verifies a username and password, returning the user ID.
+// It is the seam for the real user store.
+type credentialChecker func(username, password string) (userID string, ok bool)
+
+// mockCredentials accepts any username with the configured password.
+func mockCredentials(password string) credentialChecker {
+	return func(username, pw string) (string, bool) {
+		return username, username != "" && pw == password
 	}
+}
+
+// authServer issues tokens for valid credentials.
+type authServer struct {
+	secret   string
+	keys     *auth.Keyring // when set, tokens are signed with its primary key instead of secret
+	check    credentialChecker
+	throttle *loginThrottle
+	audit    auditLog
+	totp     totpStore
+	now      func() time.Time
+	// serviceAccounts may mint scoped tokens through /service-token.
+	serviceAccounts serviceAccounts
+}
+
+// attempt is a request whose credentials were accepted.
+type attempt struct {
+	req     LoginRequest
+	userID  string
+	event   *AuthAuditEvent
+	userKey string
+	ipKey   string
+}
 
-	// Simulate a user ID for the token
-	userID := "12345"
+// authenticate wraps next, serving POST requests whose credentials are
+// valid. Lockouts and failed attempts are handled as for /token, and every
+// attempt is audited under action.
+func (s *authServer) authenticate(action string, next func(w http.ResponseWriter, r *http.Request, a *attempt)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
 
-	token, err := generateToken(userID, secret)
+		var req LoginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		ip := clientIP(r)
+		a := &attempt{
+			req:     req,
+			event:   &AuthAuditEvent{Action: action, Username: req.Username, IP: ip, UserAgent: r.UserAgent()},
+			ipKey:   "ip:" + ip,
+			userKey: "user:" + req.Username,
+		}
+		defer func() {
+			if err := s.audit.Record(r.Context(), a.event); err != nil {
+				log.Printf("failed to record auth audit event: %v", err)
+			}
+		}()
+
+		if wait := s.throttle.lockedFor(a.ipKey, a.userKey); wait > 0 {
+			a.event.Reason = "locked out"
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			http.Error(w, "too many failed login attempts", http.StatusTooManyRequests)
+			return
+		}
+
+		var ok bool
+		if a.userID, ok = s.check(req.Username, req.Password); !ok {
+			s.refuse(w, a, "invalid credentials")
+			return
+		}
+		next(w, r, a)
+	}
+}
+
+// refuse answers a failed attempt, counting it towards lockouts.
+func (s *authServer) refuse(w http.ResponseWriter, a *attempt, reason string) {
+	s.throttle.fail(a.ipKey, a.userKey)
+	a.event.Reason = reason
+	http.Error(w, reason, http.StatusUnauthorized)
+}
+
+// tokenHandler validates credentials and returns a JWT in a JSON response.
+// Users with a confirmed TOTP enrollment must also present a code.
+func (s *authServer) tokenHandler(w http.ResponseWriter, r *http.Request, a *attempt) {
+	amr, ok := s.checkSecondFactor(w, r, a)
+	if !ok {
+		return
+	}
+	s.respondWithToken(w, a, tokenClaims(a.userID, amr))
+}
+
+// operationTokenHandler returns a single-use token for the one gRPC method
+// named in the request, valid for a few minutes, so that a client can have
+// the user confirm a sensitive operation such as a deletion by logging in
+// again.
+func (s *authServer) operationTokenHandler(w http.ResponseWriter, r *http.Request, a *attempt) {
+	if err := auth.CheckMethods(a.req.Operation); err != nil {
+		a.event.Reason = "unknown operation"
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	amr, ok := s.checkSecondFactor(w, r, a)
+	if !ok {
+		return
+	}
+	s.respondWithToken(w, a, operationTokenClaims(a.userID, amr, a.req.Operation))
+}
+
+// checkSecondFactor verifies the one-time code of users with a confirmed
+// TOTP enrollment and returns the authentication methods of the attempt. On
+// failure it writes the response and returns false.
+func (s *authServer) checkSecondFactor(w http.ResponseWriter, r *http.Request, a *attempt) ([]string, bool) {
+	enrollment, err := s.totp.Get(r.Context(), a.userID)
 	if err != nil {
+		a.event.Reason = "totp lookup failed"
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return nil, false
+	}
+	amr := []string{amrPassword}
+	if enrollment != nil && enrollment.Confirmed {
+		if a.req.OTP == "" {
+			a.event.Reason = "one-time code required"
+			http.Error(w, "one-time code required", http.StatusUnauthorized)
+			return nil, false
+		}
+		if !enrollment.verify(a.req.OTP, s.now()) {
+			s.refuse(w, a, "invalid one-time code")
+			return nil, false
+		}
+		if err := s.totp.Save(r.Context(), enrollment); err != nil {
+			a.event.Reason = "totp update failed"
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return nil, false
+		}
+		amr = append(amr, amrOTP)
+	}
+	s.throttle.reset(a.userKey)
+	return amr, true
+}
+
+// respondWithToken signs claims and writes the token as a TokenResponse.
+func (s *authServer) respondWithToken(w http.ResponseWriter, a *attempt, claims jwt.MapClaims) {
+	token, err := s.sign(claims)
+	if err != nil {
+		a.event.Reason = "token generation failed"
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
+	a.event.Success = true
 
 	resp := TokenResponse{Token: token}
 	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(resp)
-	if err != nil {
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		http.Error(w, "Failed to encode token", http.StatusInternalServerError)
 	}
 }
 
+// enrollTOTPHandler starts a TOTP enrollment, returning a new secret. The
+// enrollment takes effect once confirmed through /totp/verify; until then,
+// enrolling again replaces the secret. A confirmed enrollment is kept.
+func (s *authServer) enrollTOTPHandler(w http.ResponseWriter, r *http.Request, a *attempt) {
+	existing, err := s.totp.Get(r.Context(), a.userID)
+	if err != nil {
+		a.event.Reason = "totp lookup failed"
+		http.Error(w, "Failed to enroll", http.StatusInternalServerError)
+		return
+	}
+	if existing != nil && existing.Confirmed {
+		a.event.Reason = "already enrolled"
+		http.Error(w, "TOTP is already enrolled", http.StatusConflict)
+		return
+	}
+	enrollment, err := newTOTPEnrollment(a.userID)
+	if err == nil {
+		err = s.totp.Save(r.Context(), enrollment)
+	}
+	if err != nil {
+		a.event.Reason = "totp enrollment failed"
+		http.Error(w, "Failed to enroll", http.StatusInternalServerError)
+		return
+	}
+	a.event.Success = true
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(EnrollResponse{Secret: enrollment.Secret, URI: enrollment.URI()}); err != nil {
+		http.Error(w, "Failed to encode enrollment", http.StatusInternalServerError)
+	}
+}
+
+// verifyTOTPHandler confirms a pending TOTP enrollment with a code from the
+// user's authenticator. From then on, /token requires a code.
+func (s *authServer) verifyTOTPHandler(w http.ResponseWriter, r *http.Request, a *attempt) {
+	enrollment, err := s.totp.Get(r.Context(), a.userID)
+	if err != nil {
+		a.event.Reason = "totp lookup failed"
+		http.Error(w, "Failed to verify", http.StatusInternalServerError)
+		return
+	}
+	if enrollment == nil {
+		a.event.Reason = "not enrolled"
+		http.Error(w, "TOTP is not enrolled", http.StatusNotFound)
+		return
+	}
+	if !enrollment.verify(a.req.OTP, s.now()) {
+		s.refuse(w, a, "invalid one-time code")
+		return
+	}
+	enrollment.Confirmed = true
+	if err := s.totp.Save(r.Context(), enrollment); err != nil {
+		a.event.Reason = "totp update failed"
+		http.Error(w, "Failed to verify", http.StatusInternalServerError)
+		return
+	}
+	s.throttle.reset(a.userKey)
+	a.event.Success = true
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func main() {
 	// TODO: move to env or config
 	port := defaultPort
-	http.HandleFunc("/token", tokenHandler)
+
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		secret = defaultSecret
+	}
+	password := os.Getenv("MOCK_PASSWORD")
+	if password == "" {
+		password = defaultPassword
+	}
+
+	var audit auditLog = stdoutAuditLog{}
+	var totp totpStore = newMemoryTOTPStore()
+	if dsn := os.Getenv("DATABASE_DSN"); dsn != "" {
+		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		if err != nil {
+			log.Fatalf("failed to connect to database: %v", err)
+		}
+		if audit, err = newDBAuditLog(db); err != nil {
+			log.Fatalf("failed to migrate audit log: %v", err)
+		}
+		if totp, err = newDBTOTPStore(db); err != nil {
+			log.Fatalf("failed to migrate TOTP enrollments: %v", err)
+		}
+	}
+
+	s := &authServer{
+		secret:   secret,
+		check:    mockCredentials(password),
+		throttle: newLoginThrottle(maxLoginFailures, failureWindow, lockoutDuration),
+		audit:    audit,
+		totp:     totp,
+		now:      time.Now,
+	}
+	// JWT_KEYS holds a key set, or a secret reference to one, shared with the
+	// company service; tokens are then signed with its primary key and name
+	// it in their kid header, so keys can be rotated without an outage.
+	if ref := os.Getenv("JWT_KEYS"); ref != "" {
+		resolver := secrets.FromEnv()
+		keys, err := auth.NewKeyring(context.Background(), func(ctx context.Context) (auth.KeySet, error) {
+			data, err := resolver.Resolve(ctx, ref)
+			if err != nil {
+				return auth.KeySet{}, err
+			}
+			return auth.ParseKeySet(data)
+		})
+		if err != nil {
+			log.Fatalf("failed to load JWT keys: %v", err)
+		}
+		go keys.Run(context.Background(), keysRefreshInterval, func(err error) {
+			log.Printf("failed to refresh JWT keys: %v", err)
+		})
+		s.keys = keys
+	}
+	// SERVICE_ACCOUNTS holds the service accounts, or a secret reference to
+	// them; without it, /service-token refuses every client.
+	if ref := os.Getenv("SERVICE_ACCOUNTS"); ref != "" {
+		data, err := secrets.FromEnv().Resolve(context.Background(), ref)
+		if err == nil {
+			s.serviceAccounts, err = parseServiceAccounts(data)
+		}
+		if err != nil {
+			log.Fatalf("failed to load service accounts: %v", err)
+		}
+	}
+	http.HandleFunc("/token", s.authenticate("token", s.tokenHandler))
+	http.HandleFunc("/totp/enroll", s.authenticate("totp_enroll", s.enrollTOTPHandler))
+	http.HandleFunc("/totp/verify", s.authenticate("totp_verify", s.verifyTOTPHandler))
+	http.HandleFunc("/operation-token", s.authenticate("operation_token", s.operationTokenHandler))
+	http.HandleFunc("/service-token", s.serviceTokenHandler)
 
 	log.Printf("Authentication service running on port %s", port)
 	log.Fatal(http.ListenAndServe(":"+port, nil))
 }
 
-func generateToken(userID string, secret string) (string, error) {
-	claims := jwt.MapClaims{
+// clientIP returns the remote address of the request without its port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// sign returns a token carrying claims, signed with the primary key of the
+// key set when one is configured and with the shared secret otherwise.
+func (s *authServer) sign(claims jwt.MapClaims) (string, error) {
+	if s.keys != nil {
+		return s.keys.Sign(claims)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(s.secret))
+}
+
+// tokenClaims returns the claims of a token issued to userID.
+func tokenClaims(userID string, amr []string) jwt.MapClaims {
+	return jwt.MapClaims{
 		"sub": userID,                                // Subject (User ID)
 		"exp": time.Now().Add(time.Hour * 24).Unix(), // Expiration time
 		"iat": time.Now().Unix(),                     // Issued at time
 		"iss": "auth-service",                        // Issuer
+		"amr": amr,                                   // Authentication methods
+		"jti": uuid.NewString(),                      // Token ID
 	}
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+// operationTokenClaims returns the claims of a single-use token allowing
+// userID to call the gRPC method op once.
+func operationTokenClaims(userID string, amr []string, op string) jwt.MapClaims {
+	claims := tokenClaims(userID, amr)
+	claims["exp"] = time.Now().Add(operationTokenTTL).Unix()
+	claims["op"] = op
+	return claims
 }
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestServer(t *testing.T) (*authServer, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	audit, err := newDBAuditLog(db)
+	require.NoError(t, err)
+	return &authServer{
+		secret:   "secret",
+		check:    mockCredentials("password"),
+		throttle: newLoginThrottle(3, time.Minute, time.Minute),
+		audit:    audit,
+		totp:     newMemoryTOTPStore(),
+		now:      time.Now,
+		serviceAccounts: serviceAccounts{
+			// The SHA-256 hash of "s3cret".
+			"billing": {SecretSHA256: "1ec1c26b50d5d3c58d9583181af8076655fe00756bf7285940ba3670f99fcba0", Scopes: []string{"companies.read", "companies.write"}},
+		},
+	}, db
+}
+
+func login(s *authServer, ip, username, password string) *httptest.ResponseRecorder {
+	return post(s, "/token", ip, `{"username":"`+username+`","password":"`+password+`"}`)
+}
+
+// post sends body to the endpoint at path.
+func post(s *authServer, path, ip, body string) *httptest.ResponseRecorder {
+	handlers := map[string]http.HandlerFunc{
+		"/token":           s.authenticate("token", s.tokenHandler),
+		"/totp/enroll":     s.authenticate("totp_enroll", s.enrollTOTPHandler),
+		"/totp/verify":     s.authenticate("totp_verify", s.verifyTOTPHandler),
+		"/operation-token": s.authenticate("operation_token", s.operationTokenHandler),
+		"/service-token":   s.serviceTokenHandler,
+	}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.RemoteAddr = ip + ":1234"
+	req.Header.Set("User-Agent", "test-agent")
+	rec := httptest.NewRecorder()
+	handlers[path](rec, req)
+	return rec
+}
+
+func TestTokenHandler(t *testing.T) {
+	s, db := newTestServer(t)
+
+	rec := login(s, "10.0.0.1", "alice", "password")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"token"`)
+
+	rec = login(s, "10.0.0.1", "alice", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	var events []AuthAuditEvent
+	require.NoError(t, db.Order("id").Find(&events).Error)
+	require.Len(t, events, 2)
+	assert.True(t, events[0].Success)
+	assert.Equal(t, "10.0.0.1", events[0].IP)
+	assert.Equal(t, "test-agent", events[0].UserAgent)
+	assert.False(t, events[1].Success)
+	assert.Equal(t, "invalid credentials", events[1].Reason)
+}
+
+func TestTokenHandler_Lockout(t *testing.T) {
+	s, db := newTestServer(t)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login(s, "10.0.0.1", "bob", "wrong").Code)
+	}
+
+	rec := login(s, "10.0.0.1", "bob", "password")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code, "correct password should be refused while locked out")
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusTooManyRequests, login(s, "10.0.0.2", "bob", "password").Code, "username lockout applies from any IP")
+	assert.Equal(t, http.StatusTooManyRequests, login(s, "10.0.0.1", "carol", "password").Code, "IP lockout applies to any username")
+	assert.Equal(t, http.StatusOK, login(s, "10.0.0.3", "carol", "password").Code)
+
+	var locked int64
+	require.NoError(t, db.Model(&AuthAuditEvent{}).Where("reason = ?", "locked out").Count(&locked).Error)
+	assert.Equal(t, int64(3), locked)
+}
+
+func TestLoginThrottle(t *testing.T) {
+	now := time.Now()
+	th := newLoginThrottle(2, time.Minute, 5*time.Minute)
+	th.now = func() time.Time { return now }
+
+	th.fail("k")
+	assert.Zero(t, th.lockedFor("k"))
+
+	now = now.Add(2 * time.Minute)
+	th.fail("k")
+	assert.Zero(t, th.lockedFor("k"), "failures outside the window should not count")
+
+	th.fail("k")
+	assert.Equal(t, 5*time.Minute, th.lockedFor("k"))
+
+	now = now.Add(5 * time.Minute)
+	assert.Zero(t, th.lockedFor("k"), "lockout should expire")
+
+	th.fail("other")
+	th.reset("other")
+	th.fail("other")
+	assert.Zero(t, th.lockedFor("other"), "reset should clear failures")
+}
+
+func TestStdoutAuditLog(t *testing.T) {
+	assert.NoError(t, stdoutAuditLog{}.Record(context.Background(), &AuthAuditEvent{Username: "alice"}))
+}
+
+func TestTokenHandler_KeySet(t *testing.T) {
+	s, _ := newTestServer(t)
+	keys, err := auth.NewKeyring(context.Background(), func(context.Context) (auth.KeySet, error) {
+		return auth.KeySet{Primary: "k2", Keys: map[string]string{"k1": "one", "k2": "two"}}, nil
+	})
+	require.NoError(t, err)
+	s.keys = keys
+
+	rec := login(s, "10.0.0.1", "alice", "password")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp TokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	token, err := jwt.Parse(resp.Token, func(token *jwt.Token) (interface{}, error) {
+		assert.Equal(t, "k2", token.Header["kid"], "tokens are signed with the primary key")
+		return []byte("two"), nil
+	})
+	require.NoError(t, err)
+	sub, _ := token.Claims.GetSubject()
+	assert.Equal(t, "alice", sub)
+}
+
+func TestTOTP(t *testing.T) {
+	s, db := newTestServer(t)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	const creds = `"username":"alice","password":"password"`
+	codeAt := func(secret string, at time.Time) string {
+		key, err := totpEncoding.DecodeString(secret)
+		require.NoError(t, err)
+		return totpCode(key, at.Unix()/30)
+	}
+
+	assert.Equal(t, http.StatusNotFound, post(s, "/totp/verify", "10.0.0.1", `{`+creds+`,"otp":"123456"}`).Code)
+
+	rec := post(s, "/totp/enroll", "10.0.0.1", `{`+creds+`}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var enrollment EnrollResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &enrollment))
+	assert.Contains(t, enrollment.URI, "otpauth://totp/xm:alice?")
+	assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+	assert.Equal(t, http.StatusOK, login(s, "10.0.0.1", "alice", "password").Code, "unconfirmed enrollments do not require a code")
+
+	assert.Equal(t, http.StatusUnauthorized, post(s, "/totp/verify", "10.0.0.1", `{`+creds+`,"otp":"000000"}`).Code)
+	code := codeAt(enrollment.Secret, now)
+	assert.Equal(t, http.StatusNoContent, post(s, "/totp/verify", "10.0.0.1", `{`+creds+`,"otp":"`+code+`"}`).Code)
+	assert.Equal(t, http.StatusConflict, post(s, "/totp/enroll", "10.0.0.1", `{`+creds+`}`).Code, "a confirmed enrollment is kept")
+
+	rec = login(s, "10.0.0.1", "alice", "password")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "one-time code required")
+	assert.Equal(t, http.StatusUnauthorized, post(s, "/token", "10.0.0.1", `{`+creds+`,"otp":"`+code+`"}`).Code, "a code is accepted once")
+
+	now = now.Add(30 * time.Second)
+	rec = post(s, "/token", "10.0.0.1", `{`+creds+`,"otp":"`+codeAt(enrollment.Secret, now)+`"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp TokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	token, err := jwt.Parse(resp.Token, func(*jwt.Token) (interface{}, error) { return []byte("secret"), nil })
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"pwd", "otp"}, token.Claims.(jwt.MapClaims)["amr"])
+
+	var actions []string
+	require.NoError(t, db.Model(&AuthAuditEvent{}).Order("id").Pluck("action", &actions).Error)
+	assert.Equal(t, []string{"totp_verify", "totp_enroll", "token", "totp_verify", "totp_verify", "totp_enroll", "token", "token", "token"}, actions)
+}
+
+// TestTOTPCode checks codes against the SHA-1 test vectors of RFC 6238,
+// truncated to 6 digits.
+func TestTOTPCode(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	for unix, want := range map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"} {
+		assert.Equal(t, want, totpCode(secret, unix/30), "time %d", unix)
+	}
+}
+
+func TestServiceTokenHandler(t *testing.T) {
+	s, _ := newTestServer(t)
+	claims := func(rec *httptest.ResponseRecorder) jwt.MapClaims {
+		t.Helper()
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var resp TokenResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		token, err := jwt.Parse(resp.Token, func(*jwt.Token) (interface{}, error) { return []byte("secret"), nil })
+		require.NoError(t, err)
+		return token.Claims.(jwt.MapClaims)
+	}
+
+	got := claims(post(s, "/service-token", "10.0.0.1", `{"client_id":"billing","client_secret":"s3cret"}`))
+	assert.Equal(t, "svc:billing", got["sub"])
+	assert.Equal(t, "companies.read companies.write", got["scope"])
+
+	got = claims(post(s, "/service-token", "10.0.0.1", `{"client_id":"billing","client_secret":"s3cret","scopes":["companies.read"]}`))
+	assert.Equal(t, "companies.read", got["scope"], "tokens can be narrowed")
+
+	assert.Equal(t, http.StatusForbidden, post(s, "/service-token", "10.0.0.1", `{"client_id":"billing","client_secret":"s3cret","scopes":["companies.admin"]}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, post(s, "/service-token", "10.0.0.1", `{"client_id":"billing","client_secret":"wrong"}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, post(s, "/service-token", "10.0.0.1", `{"client_id":"alice","client_secret":"password"}`).Code, "users are not service accounts")
+}
+
+func TestParseServiceAccounts(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+	accounts, err := parseServiceAccounts(`{"billing": {"secret_sha256": "` + hash + `", "scopes": ["companies.read"]}}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"companies.read"}, accounts["billing"].Scopes)
+
+	_, err = parseServiceAccounts(`{"billing": {"secret_sha256": "s3cret"}}`)
+	assert.Error(t, err, "secrets are configured as hashes")
+	_, err = parseServiceAccounts(`{"billing": {"secret_sha256": "` + hash + `", "scopes": ["companies.delete"]}}`)
+	assert.Error(t, err, "unknown scopes are rejected")
+}
+
+func TestOperationTokenHandler(t *testing.T) {
+	s, _ := newTestServer(t)
+	const op = "/definition.v1.CompanyService/DeleteCompany"
+
+	rec := post(s, "/operation-token", "10.0.0.1", `{"username":"alice","password":"password","operation":"`+op+`"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp TokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	token, err := jwt.Parse(resp.Token, func(*jwt.Token) (interface{}, error) { return []byte("secret"), nil })
+	require.NoError(t, err)
+	claims := token.Claims.(jwt.MapClaims)
+	assert.Equal(t, op, claims["op"])
+	assert.NotEmpty(t, claims["jti"])
+	exp, err := claims.GetExpirationTime()
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(operationTokenTTL), exp.Time, time.Minute)
+
+	assert.Equal(t, http.StatusBadRequest, post(s, "/operation-token", "10.0.0.1", `{"username":"alice","password":"password","operation":"DeleteCompany"}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, post(s, "/operation-token", "10.0.0.1", `{"username":"alice","password":"wrong","operation":"`+op+`"}`).Code)
+}
+
+func TestTokenClaims_UniqueID(t *testing.T) {
+	first, second := tokenClaims("alice", nil), tokenClaims("alice", nil)
+	assert.NotEmpty(t, first["jti"])
+	assert.NotEqual(t, first["jti"], second["jti"], "every token has its own ID")
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// serviceTokenTTL is how long service account tokens are valid; jobs mint a
+// new one when it expires.
+const serviceTokenTTL = time.Hour
+
+// ServiceAccount is a non-human client allowed to mint scoped tokens.
+type ServiceAccount struct {
+	// SecretSHA256 is the hex encoded SHA-256 hash of the client secret.
+	SecretSHA256 string `json:"secret_sha256"`
+	// Scopes lists the scopes the account may request, e.g.
+	// "companies.read".
+	Scopes []string `json:"scopes"`
+}
+
+// serviceAccounts maps client IDs to their accounts.
+type serviceAccounts map[string]ServiceAccount
+
+// parseServiceAccounts parses the JSON accounts of SERVICE_ACCOUNTS, e.g.
+// {"billing": {"secret_sha256": "...", "scopes": ["companies.read"]}}.
+func parseServiceAccounts(data string) (serviceAccounts, error) {
+	var accounts serviceAccounts
+	if err := json.Unmarshal([]byte(data), &accounts); err != nil {
+		return nil, fmt.Errorf("invalid service accounts: %w", err)
+	}
+	for id, account := range accounts {
+		if id == "" || len(account.SecretSHA256) != sha256.Size*2 {
+			return nil, fmt.Errorf("invalid service account %q: a client ID and a SHA-256 secret hash are required", id)
+		}
+		for _, scope := range account.Scopes {
+			if !slices.Contains(auth.Scopes, scope) {
+				return nil, fmt.Errorf("invalid service account %q: unknown scope %q", id, scope)
+			}
+		}
+	}
+	return accounts, nil
+}
+
+// check returns the account of clientID if secret is its secret.
+func (a serviceAccounts) check(clientID, secret string) (ServiceAccount, bool) {
+	account, ok := a[clientID]
+	sum := sha256.Sum256([]byte(secret))
+	if !ok || subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(account.SecretSHA256))) != 1 {
+		return ServiceAccount{}, false
+	}
+	return account, true
+}
+
+// ServiceTokenRequest carries the client credentials presented to
+// /service-token.
+type ServiceTokenRequest struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	// Scopes narrows the token to some of the account's scopes; empty grants
+	// all of them.
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// serviceTokenHandler returns a token for a service account, limited to the
+// scopes requested. Failed attempts are throttled and audited as logins.
+func (s *authServer) serviceTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ServiceTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ip := clientIP(r)
+	ipKey, clientKey := "ip:"+ip, "client:"+req.ClientID
+	event := &AuthAuditEvent{Action: "service_token", Username: req.ClientID, IP: ip, UserAgent: r.UserAgent()}
+	defer func() {
+		if err := s.audit.Record(r.Context(), event); err != nil {
+			log.Printf("failed to record auth audit event: %v", err)
+		}
+	}()
+
+	if wait := s.throttle.lockedFor(ipKey, clientKey); wait > 0 {
+		event.Reason = "locked out"
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		http.Error(w, "too many failed login attempts", http.StatusTooManyRequests)
+		return
+	}
+
+	account, ok := s.serviceAccounts.check(req.ClientID, req.ClientSecret)
+	if !ok {
+		s.throttle.fail(ipKey, clientKey)
+		event.Reason = "invalid credentials"
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	s.throttle.reset(clientKey)
+
+	scopes := account.Scopes
+	if len(req.Scopes) > 0 {
+		for _, scope := range req.Scopes {
+			if !slices.Contains(account.Scopes, scope) {
+				event.Reason = "scope not granted"
+				http.Error(w, fmt.Sprintf("scope %q is not granted to %s", scope, req.ClientID), http.StatusForbidden)
+				return
+			}
+		}
+		scopes = req.Scopes
+	}
+
+	token, err := s.sign(serviceTokenClaims(req.ClientID, scopes))
+	if err != nil {
+		event.Reason = "token generation failed"
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	event.Success = true
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(TokenResponse{Token: token}); err != nil {
+		http.Error(w, "Failed to encode token", http.StatusInternalServerError)
+	}
+}
+
+// serviceTokenClaims returns the claims of a token issued to a service
+// account. The scope claim is space separated, as in OAuth 2.0.
+func serviceTokenClaims(clientID string, scopes []string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   auth.ServiceAccountPrefix + clientID,   // Subject (service account)
+		"exp":   time.Now().Add(serviceTokenTTL).Unix(), // Expiration time
+		"iat":   time.Now().Unix(),                      // Issued at time
+		"iss":   "auth-service",                         // Issuer
+		"scope": strings.Join(scopes, " "),              // Granted scopes
+		"jti":   uuid.NewString(),                       // Token ID
+	}
+}
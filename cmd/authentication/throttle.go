@@ -0,0 +1,105 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// loginThrottle locks out IPs and usernames after repeated failed logins.
+// Failures are counted per key within a sliding window; reaching maxFailures
+// locks the key for the lockout duration.
+type loginThrottle struct {
+	maxFailures int
+	window      time.Duration
+	lockout     time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*throttleEntry
+}
+
+// maxThrottleEntries bounds memory use; beyond it, expired entries are swept.
+const maxThrottleEntries = 10000
+
+type throttleEntry struct {
+	failures    []time.Time
+	lockedUntil time.Time
+}
+
+func newLoginThrottle(maxFailures int, window, lockout time.Duration) *loginThrottle {
+	return &loginThrottle{
+		maxFailures: maxFailures,
+		window:      window,
+		lockout:     lockout,
+		now:         time.Now,
+		entries:     make(map[string]*throttleEntry),
+	}
+}
+
+// lockedFor returns how long the longest-locked of keys remains locked, or
+// zero if none is.
+func (t *loginThrottle) lockedFor(keys ...string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	var longest time.Duration
+	for _, k := range keys {
+		if e, ok := t.entries[k]; ok {
+			if d := e.lockedUntil.Sub(now); d > longest {
+				longest = d
+			}
+		}
+	}
+	return longest
+}
+
+// fail records a failed attempt against each key, locking keys that reach
+// the failure limit.
+func (t *loginThrottle) fail(keys ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if len(t.entries) >= maxThrottleEntries {
+		t.sweep(now)
+	}
+	for _, k := range keys {
+		e, ok := t.entries[k]
+		if !ok {
+			e = &throttleEntry{}
+			t.entries[k] = e
+		}
+		e.failures = append(recent(e.failures, now.Add(-t.window)), now)
+		if len(e.failures) >= t.maxFailures {
+			e.lockedUntil = now.Add(t.lockout)
+			e.failures = nil
+		}
+	}
+}
+
+// sweep removes entries that are neither locked nor have recent failures.
+// t.mu must be held.
+func (t *loginThrottle) sweep(now time.Time) {
+	for k, e := range t.entries {
+		if now.After(e.lockedUntil) && len(recent(e.failures, now.Add(-t.window))) == 0 {
+			delete(t.entries, k)
+		}
+	}
+}
+
+// reset forgets the failures recorded against key.
+func (t *loginThrottle) reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, key)
+}
+
+// recent drops timestamps before cutoff.
+func recent(ts []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(ts) && ts[i].Before(cutoff) {
+		i++
+	}
+	return ts[i:]
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TOTP parameters, the defaults of authenticator apps (RFC 6238).
+const (
+	totpDigits = 6
+	totpPeriod = 30 * time.Second
+	totpSkew   = 1    // Steps accepted either side of the current one, for clock drift
+	totpIssuer = "xm" // Issuer shown by authenticator apps
+)
+
+// totpEncoding encodes secrets as authenticator apps expect them.
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPEnrollment holds the TOTP secret of a user. Once confirmed with a code
+// from the user's authenticator, logins require a code.
+type TOTPEnrollment struct {
+	UserID    string `gorm:"primaryKey"`
+	Secret    string // Base32 encoded
+	Confirmed bool
+	// LastStep is the time step of the last accepted code, so that a code
+	// cannot be used twice.
+	LastStep  int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// newTOTPEnrollment returns an unconfirmed enrollment with a random secret.
+func newTOTPEnrollment(userID string) (*TOTPEnrollment, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{UserID: userID, Secret: totpEncoding.EncodeToString(secret)}, nil
+}
+
+// URI returns the otpauth:// URI authenticator apps enroll from, usually
+// shown as a QR code.
+func (e *TOTPEnrollment) URI() string {
+	q := url.Values{}
+	q.Set("secret", e.Secret)
+	q.Set("issuer", totpIssuer)
+	return "otpauth://totp/" + url.PathEscape(totpIssuer+":"+e.UserID) + "?" + q.Encode()
+}
+
+// verify reports whether code is valid at now and not used before, and
+// records it as used.
+func (e *TOTPEnrollment) verify(code string, now time.Time) bool {
+	secret, err := totpEncoding.DecodeString(strings.ToUpper(e.Secret))
+	if err != nil || len(code) != totpDigits {
+		return false
+	}
+	current := now.Unix() / int64(totpPeriod/time.Second)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= e.LastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(secret, step)), []byte(code)) == 1 {
+			e.LastStep = step
+			return true
+		}
+	}
+	return false
+}
+
+// totpCode returns the code of a time step (RFC 4226 HOTP with HMAC-SHA1).
+func totpCode(secret []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// totpStore persists TOTP enrollments.
+type totpStore interface {
+	// Get returns the enrollment of userID, or nil if there is none.
+	Get(ctx context.Context, userID string) (*TOTPEnrollment, error)
+	Save(ctx context.Context, e *TOTPEnrollment) error
+}
+
+// dbTOTPStore stores enrollments in the database.
+type dbTOTPStore struct {
+	db *gorm.DB
+}
+
+func newDBTOTPStore(db *gorm.DB) (*dbTOTPStore, error) {
+	if err := db.AutoMigrate(&TOTPEnrollment{}); err != nil {
+		return nil, err
+	}
+	return &dbTOTPStore{db: db}, nil
+}
+
+func (s *dbTOTPStore) Get(ctx context.Context, userID string) (*TOTPEnrollment, error) {
+	var e TOTPEnrollment
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Take(&e).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (s *dbTOTPStore) Save(ctx context.Context, e *TOTPEnrollment) error {
+	return s.db.WithContext(ctx).Save(e).Error
+}
+
+// memoryTOTPStore keeps enrollments in memory, for running without a
+// database; they are lost on restart.
+type memoryTOTPStore struct {
+	mu          sync.Mutex
+	enrollments map[string]TOTPEnrollment
+}
+
+func newMemoryTOTPStore() *memoryTOTPStore {
+	return &memoryTOTPStore{enrollments: make(map[string]TOTPEnrollment)}
+}
+
+func (s *memoryTOTPStore) Get(_ context.Context, userID string) (*TOTPEnrollment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.enrollments[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &e, nil
+}
+
+func (s *memoryTOTPStore) Save(_ context.Context, e *TOTPEnrollment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enrollments[e.UserID] = *e
+	return nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	gorm "github.com/gartstein/xm/internal/company/db"
+	"github.com/gartstein/xm/internal/company/enrichment"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/faults"
+	"github.com/gartstein/xm/internal/company/handlers"
+	"github.com/gartstein/xm/internal/company/validation"
+	"github.com/gartstein/xm/internal/pkg/secrets"
+	"github.com/gartstein/xm/internal/pkg/version"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// checkTimeout bounds the whole self-check, so an unreachable dependency
+// fails it instead of hanging the init container.
+const checkTimeout = time.Minute
+
+// checkResult is the outcome of one check of the self-check.
+type checkResult struct {
+	Name string `json:"name"`
+	OK   bool   `json:"ok"`
+	// Error tells why the check failed.
+	Error string `json:"error,omitempty"`
+	// Detail qualifies a passed check.
+	Detail     string `json:"detail,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// checkReport is the machine-readable report of the self-check.
+type checkReport struct {
+	OK      bool          `json:"ok"`
+	Version string        `json:"version"`
+	Checks  []checkResult `json:"checks"`
+}
+
+// run runs check under name and adds its result to the report.
+func (r *checkReport) run(ctx context.Context, name string, check func(ctx context.Context) (string, error)) bool {
+	start := time.Now()
+	detail, err := check(ctx)
+	result := checkResult{Name: name, OK: err == nil, Detail: detail, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Error = err.Error()
+		r.OK = false
+	}
+	r.Checks = append(r.Checks, result)
+	return result.OK
+}
+
+// selfCheck validates the configuration returned by load, loads the JWT
+// secret and keys, connects to the database and Kafka and verifies the
+// schema is migrated, without changing anything. It writes the report as
+// JSON to out and returns the exit code: 0 when every check passed.
+func selfCheck(ctx context.Context, load func() (*Config, error), out io.Writer, logger *zap.Logger) int {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	report := checkReport{OK: true, Version: version.Get().Version}
+	defer func() {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			logger.Error("failed to write check report", zap.Error(err))
+		}
+	}()
+
+	var cfg *Config
+	if !report.run(ctx, "config", func(context.Context) (string, error) {
+		var err error
+		if cfg, err = load(); err != nil {
+			return "", err
+		}
+		return "", validateConfig(cfg, logger)
+	}) && cfg == nil {
+		return 1
+	}
+
+	resolver := secrets.FromEnv()
+	report.run(ctx, "jwt", func(ctx context.Context) (string, error) {
+		return checkJWT(ctx, cfg, resolver)
+	})
+
+	var repo *gorm.Repository
+	if report.run(ctx, "database", func(ctx context.Context) (string, error) {
+		dbConf := initDatabase(cfg)
+		var err error
+		if dbConf.Password, err = resolver.Resolve(ctx, cfg.DBPassword); err != nil {
+			return "", fmt.Errorf("failed to resolve database password: %w", err)
+		}
+		if repo, err = gorm.NewRepository(dbConf, gorm.WithoutMigration()); err != nil {
+			return "", err
+		}
+		return "", repo.Ping(ctx)
+	}) {
+		defer repo.Close()
+		report.run(ctx, "migrations", func(ctx context.Context) (string, error) {
+			status, err := repo.MigrationStatus(ctx)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("schema version %d, expected %d", status.Version, status.Expected), status.Err()
+		})
+	}
+
+	if cfg.EventBus == "" || cfg.EventBus == "kafka" {
+		report.run(ctx, "kafka", func(ctx context.Context) (string, error) {
+			return checkKafka(cfg, logger)
+		})
+	}
+
+	if !report.OK {
+		return 1
+	}
+	return 0
+}
+
+// validateConfig returns the errors of the settings main would reject,
+// apart from those needing a connection.
+func validateConfig(cfg *Config, logger *zap.Logger) error {
+	var errs []error
+	switch cfg.MigrationMode {
+	case "", migrationAuto, migrationVerify:
+	default:
+		errs = append(errs, fmt.Errorf("invalid migration mode %q", cfg.MigrationMode))
+	}
+	switch cfg.EventBus {
+	case "", "kafka":
+		if _, err := producerOptions(cfg); err != nil {
+			errs = append(errs, err)
+		}
+	case "in_process":
+	default:
+		errs = append(errs, fmt.Errorf("invalid event bus %q", cfg.EventBus))
+	}
+	if _, err := newTimeouts(cfg); err != nil {
+		errs = append(errs, fmt.Errorf("invalid method timeouts: %w", err))
+	}
+	if err := auth.CheckMethods(slices.Concat(cfg.ProtectedMethods, cfg.AdminMethods, cfg.SingleUseMethods)...); err != nil {
+		errs = append(errs, fmt.Errorf("invalid auth method configuration: %w", err))
+	}
+	if len(cfg.SingleUseMethods) > 0 && !cfg.ReplayProtection {
+		errs = append(errs, errors.New("SINGLE_USE_METHODS requires REPLAY_PROTECTION"))
+	}
+	for _, methods := range cfg.MTLSAllowlist {
+		if err := auth.CheckMethods(methods...); err != nil {
+			errs = append(errs, fmt.Errorf("invalid mTLS allowlist: %w", err))
+		}
+	}
+	if _, _, err := transportCredentials(cfg); err != nil {
+		errs = append(errs, fmt.Errorf("invalid TLS credentials: %w", err))
+	}
+	if _, err := handlers.NewFieldVisibility(cfg.FieldVisibility); err != nil {
+		errs = append(errs, fmt.Errorf("invalid field visibility: %w", err))
+	}
+	if cfg.AccessLog != "" {
+		if _, err := handlers.ParseAccessLogFormat(cfg.AccessLogFormat); err != nil {
+			errs = append(errs, err)
+		}
+		if cfg.AccessLogSampleRate < 0 || cfg.AccessLogSampleRate > 1 {
+			errs = append(errs, fmt.Errorf("access log sample rate %v not between 0 and 1", cfg.AccessLogSampleRate))
+		}
+	}
+	if cfg.FaultInjection {
+		if _, err := faults.NewInjector(cfg.Faults, logger); err != nil {
+			errs = append(errs, fmt.Errorf("invalid fault injection configuration: %w", err))
+		}
+	}
+	for _, providerCfg := range cfg.EnrichmentProviders {
+		if _, err := enrichment.NewHTTPProvider(providerCfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, hookCfg := range cfg.ValidationHooks {
+		if _, err := validation.NewHTTPHook(hookCfg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if cfg.DuplicateDetectionSchedule != "" {
+		if _, err := cron.ParseStandard(cfg.DuplicateDetectionSchedule); err != nil {
+			errs = append(errs, fmt.Errorf("invalid duplicate detection schedule %q: %w", cfg.DuplicateDetectionSchedule, err))
+		}
+	}
+	if cfg.DuplicateSimilarityThreshold < 0 || cfg.DuplicateSimilarityThreshold > 1 {
+		errs = append(errs, fmt.Errorf("duplicate similarity threshold %v not between 0 and 1", cfg.DuplicateSimilarityThreshold))
+	}
+	for _, schedule := range cfg.ReportSchedules {
+		if err := schedule.Validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if schedule.Store && cfg.ExportBucket == "" {
+			errs = append(errs, fmt.Errorf("report schedule %s stores reports but EXPORT_BUCKET is unset", schedule.Name))
+		}
+		if len(schedule.EmailTo) > 0 && cfg.ReportMailer == "" {
+			errs = append(errs, fmt.Errorf("report schedule %s emails reports but REPORT_MAILER is unset", schedule.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkJWT resolves the JWT secret and, when configured, loads the JWT keys.
+func checkJWT(ctx context.Context, cfg *Config, resolver *secrets.Resolver) (string, error) {
+	secret, err := resolver.Resolve(ctx, cfg.JWTSecret)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve JWT secret: %w", err)
+	}
+	if secret == "" {
+		return "", errors.New("JWT secret empty")
+	}
+	if cfg.JWTKeys == "" {
+		return "", nil
+	}
+	data, err := resolver.Resolve(ctx, cfg.JWTKeys)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve JWT keys: %w", err)
+	}
+	set, err := auth.ParseKeySet(data)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d JWT keys, primary %s", len(set.Keys), set.Primary), nil
+}
+
+// checkKafka connects to the brokers and checks the topics exist. Missing
+// topics pass when the service creates them on startup; the check never
+// creates them itself.
+func checkKafka(cfg *Config, logger *zap.Logger) (string, error) {
+	opts, err := producerOptions(cfg)
+	if err != nil {
+		return "", err
+	}
+	settings := topicSettings(cfg)
+	settings.AutoCreate = false
+	producer, err := events.NewProducer(cfg.KafkaBrokers, logger, cfg.Topic, append(opts, events.WithTopicSettings(settings))...)
+	if errors.Is(err, events.ErrTopicMissing) && !cfg.DisableTopicCreation {
+		return "topics missing, created on startup", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	producer.Close()
+	return "", nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	gorm "github.com/gartstein/xm/internal/company/db"
+	"github.com/gartstein/xm/internal/company/report"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// runSelfCheck runs the self-check on the configuration load returns and
+// decodes its report.
+func runSelfCheck(t *testing.T, load func() (*Config, error)) (int, checkReport) {
+	t.Helper()
+	var out bytes.Buffer
+	code := selfCheck(context.Background(), load, &out, zap.NewNop())
+	var report checkReport
+	require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+	return code, report
+}
+
+// checkNames returns the names of the checks of report that passed or
+// failed, as ok says.
+func checkNames(report checkReport, ok bool) []string {
+	var names []string
+	for _, check := range report.Checks {
+		if check.OK == ok {
+			names = append(names, check.Name)
+		}
+	}
+	return names
+}
+
+// TestSelfCheck verifies every check is reported, an unmigrated schema and
+// invalid settings fail the self-check, and a migrated one passes.
+func TestSelfCheck(t *testing.T) {
+	cfg := &Config{
+		DBDriver:  "sqlite",
+		DBPath:    filepath.Join(t.TempDir(), "company.db"),
+		JWTSecret: "secret",
+		EventBus:  "in_process",
+	}
+	load := func() (*Config, error) { return cfg, nil }
+
+	code, report := runSelfCheck(t, load)
+	assert.Equal(t, 1, code)
+	assert.False(t, report.OK)
+	assert.Equal(t, []string{"config", "jwt", "database"}, checkNames(report, true))
+	assert.Equal(t, []string{"migrations"}, checkNames(report, false))
+	assert.Equal(t, "schema version 0, expected 5", report.Checks[3].Error)
+
+	repo, err := gorm.NewRepository(initDatabase(cfg))
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+	code, report = runSelfCheck(t, load)
+	assert.Equal(t, 0, code)
+	assert.True(t, report.OK)
+	assert.Len(t, checkNames(report, true), 4)
+
+	cfg.JWTSecret = ""
+	cfg.JWTKeys = `{"primary": "k2", "keys": {"k1": "one"}}`
+	cfg.SingleUseMethods = []string{"/definition.v1.CompanyService/DeleteCompany"}
+	cfg.FieldVisibility = map[string][]string{"name": {"admin"}}
+	code, report = runSelfCheck(t, load)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, []string{"config", "jwt"}, checkNames(report, false))
+	assert.Contains(t, report.Checks[0].Error, "requires REPLAY_PROTECTION")
+	assert.Contains(t, report.Checks[0].Error, "invalid field visibility")
+
+	code, report = runSelfCheck(t, func() (*Config, error) { return nil, errors.New("no config") })
+	assert.Equal(t, 1, code)
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, "no config", report.Checks[0].Error)
+}
+
+// TestValidateConfigReportSchedules verifies report schedules are validated
+// along with the delivery channels they use.
+func TestValidateConfigReportSchedules(t *testing.T) {
+	cfg := &Config{ReportSchedules: []report.Schedule{
+		{Name: "weekly", Report: "recently_modified", Schedule: "@weekly", EmailTo: []string{"ops@example.com"}},
+		{Name: "monthly", Report: "growth", Schedule: "@monthly", Store: true},
+		{Name: "hourly", Report: "growth", Schedule: "every hour", Store: true},
+	}}
+	err := validateConfig(cfg, zap.NewNop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "report schedule weekly emails reports but REPORT_MAILER is unset")
+	assert.Contains(t, err.Error(), "report schedule monthly stores reports but EXPORT_BUCKET is unset")
+	assert.Contains(t, err.Error(), `report schedule hourly: invalid schedule "every hour"`)
+
+	cfg.ReportSchedules = cfg.ReportSchedules[:2]
+	cfg.ReportMailer, cfg.ExportBucket = "smtp", "exports"
+	assert.NoError(t, validateConfig(cfg, zap.NewNop()))
+}
+
+func TestValidateConfigDuplicateDetection(t *testing.T) {
+	cfg := &Config{DuplicateDetectionSchedule: "nightly", DuplicateSimilarityThreshold: 1.5}
+	err := validateConfig(cfg, zap.NewNop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid duplicate detection schedule "nightly"`)
+	assert.Contains(t, err.Error(), "duplicate similarity threshold 1.5 not between 0 and 1")
+
+	cfg.DuplicateDetectionSchedule, cfg.DuplicateSimilarityThreshold = "0 3 * * *", 0.6
+	assert.NoError(t, validateConfig(cfg, zap.NewNop()))
+}
@@ -2,96 +2,896 @@ package main
 
 import (
 	"context"
-	"log"
+	"crypto/tls"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"maps"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"syscall"
+	"time"
 
 	"github.com/gartstein/xm/internal/company/auth"
 	"github.com/gartstein/xm/internal/company/controller"
 	gorm "github.com/gartstein/xm/internal/company/db"
+	"github.com/gartstein/xm/internal/company/enrichment"
+	e "github.com/gartstein/xm/internal/company/errors"
 	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/export"
+	"github.com/gartstein/xm/internal/company/faults"
 	"github.com/gartstein/xm/internal/company/handlers"
+	"github.com/gartstein/xm/internal/company/integrations"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/company/report"
+	"github.com/gartstein/xm/internal/company/scheduler"
+	"github.com/gartstein/xm/internal/company/startup"
+	"github.com/gartstein/xm/internal/company/validation"
+	"github.com/gartstein/xm/internal/notifier"
+	"github.com/gartstein/xm/internal/pkg/leader"
+	"github.com/gartstein/xm/internal/pkg/logfile"
+	"github.com/gartstein/xm/internal/pkg/secrets"
+	"github.com/gartstein/xm/internal/pkg/version"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"gopkg.in/yaml.v3"
 )
 
+const (
+	// producerFlushTimeout bounds how long shutdown waits for queued events.
+	producerFlushTimeout = 10 * time.Second
+	// jwtSecretGrace is how long tokens signed with a rotated-out JWT secret
+	// are still accepted.
+	jwtSecretGrace = time.Hour
+	// defaultSecretsRefreshInterval is how often secret references are
+	// re-resolved to pick up rotations.
+	defaultSecretsRefreshInterval = 5 * time.Minute
+	// defaultArchiveSchedule runs the archival job daily at 03:00.
+	defaultArchiveSchedule = "0 3 * * *"
+	// defaultCompliancePurgeSchedule purges expired compliance records daily
+	// at 04:00.
+	defaultCompliancePurgeSchedule = "0 4 * * *"
+	// usedTokenPurgeSchedule drops the records of expired single-use tokens
+	// hourly.
+	usedTokenPurgeSchedule = "@hourly"
+	// defaultAlertWebhookRefreshInterval is how often alert webhooks changed
+	// through other replicas are picked up.
+	defaultAlertWebhookRefreshInterval = 30 * time.Second
+	// Enrichment lookups are tried 3 times, 1s then 2s apart, and a
+	// provider failing 5 times in a row is left alone for a minute.
+	defaultEnrichmentMaxAttempts     = 3
+	defaultEnrichmentBackoff         = time.Second
+	defaultEnrichmentBreakerFailures = 5
+	defaultEnrichmentBreakerCooldown = time.Minute
+)
+
+// Migration modes of MIGRATION_MODE.
+const (
+	migrationAuto   = "auto"
+	migrationVerify = "verify"
+)
+
+// reencryptLockKey elects the instance re-encrypting companies on startup.
+var reencryptLockKey = leader.Key("xm.reencrypt")
+
 // Config struct for YAML configuration
 type Config struct {
-	GRPCPort     int      `yaml:"GRPC_PORT"`
-	HTTPPort     int      `yaml:"HTTP_PORT"`
+	GRPCPort  int `yaml:"GRPC_PORT"`
+	HTTPPort  int `yaml:"HTTP_PORT"`
+	AdminPort int `yaml:"ADMIN_PORT"` // internal port for health, metrics and pprof; 0 disables
+	// DBDriver is "postgres" (default) or "sqlite", which keeps the data in
+	// the file at DBPath and ignores the other database settings.
+	DBDriver     string   `yaml:"DB_DRIVER"`
+	DBPath       string   `yaml:"DB_PATH"`
 	DBHost       string   `yaml:"DB_HOST"`
 	DBPort       int      `yaml:"DB_PORT"`
 	DBUser       string   `yaml:"DB_USER"`
 	DBPassword   string   `yaml:"DB_PASSWORD"`
 	DBName       string   `yaml:"DB_NAME"`
 	DBSSLMode    string   `yaml:"DB_SSLMODE"`
+	DBSchema     string   `yaml:"DB_SCHEMA"` // created if missing; empty uses the default search_path
 	KafkaBrokers []string `yaml:"KAFKA_BROKERS"`
-	JWTSecret    string   `yaml:"JWT_SECRET"`
-	Topic        string   `yaml:"TOPIC"`
+	// KafkaDialTimeout bounds connecting to a broker and KafkaMetadataTTL
+	// how long partition leaders are cached; after a broker fails, writes
+	// are retried until new leaders are known. KafkaTLSVersions, e.g.
+	// ["1.2", "1.3"], enables TLS to the brokers with those versions,
+	// verified against KafkaTLSCAFile or the system roots.
+	KafkaDialTimeout time.Duration `yaml:"KAFKA_DIAL_TIMEOUT"`
+	KafkaMetadataTTL time.Duration `yaml:"KAFKA_METADATA_TTL"`
+	KafkaTLSVersions []string      `yaml:"KAFKA_TLS_VERSIONS"`
+	KafkaTLSCAFile   string        `yaml:"KAFKA_TLS_CA_FILE"`
+	JWTSecret        string        `yaml:"JWT_SECRET"` // literal or secret reference, e.g. vault://secret/xm#jwt_secret
+	Topic            string        `yaml:"TOPIC"`
+	TopicStrategy    string        `yaml:"TOPIC_STRATEGY"` // "single" (default) or "per_event"
+	EventEncoding    string        `yaml:"EVENT_ENCODING"` // "json" (default) or "protobuf"
+	// EventBus is "kafka" (default), or "in_process" to dispatch events to
+	// subscribers in the service instead, for small deployments without a
+	// broker; the Kafka settings are then ignored.
+	EventBus string `yaml:"EVENT_BUS"`
+	// EventOutbox queues the events of mutations in the database, in the
+	// transaction of the mutation, for a relay publishing them every
+	// OutboxRelayInterval (0 keeps 1s) in the order the mutations of each
+	// company committed, instead of producing them once it committed.
+	// Delivery and that order are only guaranteed with it: by default an
+	// event whose write fails is logged and dropped, and the events still
+	// queued when the process dies are lost.
+	EventOutbox         bool          `yaml:"EVENT_OUTBOX"`
+	OutboxRelayInterval time.Duration `yaml:"OUTBOX_RELAY_INTERVAL"`
+	// Missing topics are created on startup with TopicPartitions partitions,
+	// TopicReplicationFactor replicas and TopicRetention (0 keeps the broker
+	// default), unless DisableTopicCreation is set or the cluster refuses;
+	// startup then fails if a topic does not exist.
+	DisableTopicCreation   bool          `yaml:"DISABLE_TOPIC_CREATION"`
+	TopicPartitions        int           `yaml:"TOPIC_PARTITIONS"`
+	TopicReplicationFactor int           `yaml:"TOPIC_REPLICATION_FACTOR"`
+	TopicRetention         time.Duration `yaml:"TOPIC_RETENTION"`
+	// AuditTopic receives an audit entry for every change; empty disables
+	// it. It is created with AuditTopicRetention instead of TopicRetention.
+	AuditTopic          string        `yaml:"AUDIT_TOPIC"`
+	AuditTopicRetention time.Duration `yaml:"AUDIT_TOPIC_RETENTION"`
+	// The database and then Kafka are connected at startup, each attempted
+	// up to StartupRetryAttempts times, waiting StartupRetryBackoff after
+	// the first failure and twice as long after each further one, up to
+	// StartupRetryMaxBackoff. With ReadOnlyWithoutKafka, the service starts
+	// read-only when Kafka stays unreachable and keeps connecting in the
+	// background instead of exiting.
+	StartupRetryAttempts   int           `yaml:"STARTUP_RETRY_ATTEMPTS"`
+	StartupRetryBackoff    time.Duration `yaml:"STARTUP_RETRY_BACKOFF"`
+	StartupRetryMaxBackoff time.Duration `yaml:"STARTUP_RETRY_MAX_BACKOFF"`
+	ReadOnlyWithoutKafka   bool          `yaml:"READ_ONLY_WITHOUT_KAFKA"`
+	// MigrationMode is "auto" (default), migrating the schema on startup, or
+	// "verify", refusing to start unless it is already migrated.
+	MigrationMode string `yaml:"MIGRATION_MODE"`
+	// PurgeAfterDays enables the janitor permanently removing companies
+	// soft-deleted longer ago than this; 0 disables it.
+	PurgeAfterDays int           `yaml:"PURGE_AFTER_DAYS"`
+	PurgeInterval  time.Duration `yaml:"PURGE_INTERVAL"`
+	// ArchiveAfterDays enables the scheduled job archiving companies not
+	// updated for this many days; 0 disables it. ArchiveSchedule is a cron
+	// expression in the server's time zone.
+	ArchiveAfterDays int    `yaml:"ARCHIVE_AFTER_DAYS"`
+	ArchiveSchedule  string `yaml:"ARCHIVE_SCHEDULE"`
+	// SlowQueryThreshold logs database statements taking at least this
+	// long, with bound parameters redacted; 0 disables the log. Latency
+	// histograms per operation are served under /metrics either way.
+	SlowQueryThreshold time.Duration `yaml:"SLOW_QUERY_THRESHOLD"`
+	// WatchPollInterval is how often WatchCompanies streams look for new
+	// events, and WatchSettleDelay how long an event is held back for the
+	// transactions of earlier ones to commit; 0 keeps 1s and 2s.
+	WatchPollInterval time.Duration `yaml:"WATCH_POLL_INTERVAL"`
+	WatchSettleDelay  time.Duration `yaml:"WATCH_SETTLE_DELAY"`
+	// OIDCIssuerURL enables validating tokens against an OpenID Connect
+	// provider instead of JWTSecret.
+	OIDCIssuerURL string `yaml:"OIDC_ISSUER_URL"`
+	OIDCAudience  string `yaml:"OIDC_AUDIENCE"`
+	// ProtectedMethods and AdminMethods override the full gRPC method names
+	// requiring authentication and the admin role; HTTP routes follow them.
+	ProtectedMethods []string `yaml:"PROTECTED_METHODS"`
+	AdminMethods     []string `yaml:"ADMIN_METHODS"`
+	// PolicyFile enables per-method authorization rules; empty disables it.
+	PolicyFile string `yaml:"POLICY_FILE"`
+	// DisabledMiddleware names middleware to leave out of the chain wrapping
+	// every call, e.g. "logging"; "auth" and "compliance" cannot be disabled.
+	DisabledMiddleware []string `yaml:"DISABLED_MIDDLEWARE"`
+	// ReplayProtection accepts single-use operation tokens, recording their
+	// jti in the database so that a replay is refused on every instance.
+	// SingleUseMethods lists the methods requiring such a token, e.g. to
+	// confirm deletions; it needs ReplayProtection.
+	ReplayProtection bool     `yaml:"REPLAY_PROTECTION"`
+	SingleUseMethods []string `yaml:"SINGLE_USE_METHODS"`
+	// TLSCertFile and TLSKeyFile enable TLS on the gRPC port. Client
+	// certificates signed by TLSClientCAFile identify internal callers, who
+	// bypass JWT for the methods listed for them in MTLSAllowlist.
+	TLSCertFile     string              `yaml:"TLS_CERT_FILE"`
+	TLSKeyFile      string              `yaml:"TLS_KEY_FILE"`
+	TLSClientCAFile string              `yaml:"TLS_CLIENT_CA_FILE"`
+	MTLSAllowlist   map[string][]string `yaml:"MTLS_ALLOWLIST"`
+	// TLSCAFile and TLSServerName let the HTTP gateway verify the gRPC
+	// server certificate; system roots are used when TLSCAFile is empty.
+	TLSCAFile     string `yaml:"TLS_CA_FILE"`
+	TLSServerName string `yaml:"TLS_SERVER_NAME"`
+	// LogPayloadSampleRate is the fraction of calls (0-1) whose request and
+	// response payloads are logged, with LogRedactFields masked.
+	LogPayloadSampleRate float64  `yaml:"LOG_PAYLOAD_SAMPLE_RATE"`
+	LogRedactFields      []string `yaml:"LOG_REDACT_FIELDS"`
+	// FieldVisibility maps company fields such as "employees" to the roles
+	// allowed to see them; other callers get them empty and listed in
+	// redacted_fields, and their updates leave them unchanged.
+	FieldVisibility map[string][]string `yaml:"FIELD_VISIBILITY"`
+	// AccessLog enables the HTTP access log: "stdout", or the path of a file
+	// rotated once it reaches AccessLogMaxSizeMB, keeping
+	// AccessLogMaxBackups old files. AccessLogFormat is "combined" or
+	// "json"; AccessLogSampleRate is the fraction of requests (0-1) logged,
+	// server errors always being.
+	AccessLog           string  `yaml:"ACCESS_LOG"`
+	AccessLogFormat     string  `yaml:"ACCESS_LOG_FORMAT"`
+	AccessLogSampleRate float64 `yaml:"ACCESS_LOG_SAMPLE_RATE"`
+	AccessLogMaxSizeMB  int     `yaml:"ACCESS_LOG_MAX_SIZE_MB"`
+	AccessLogMaxBackups int     `yaml:"ACCESS_LOG_MAX_BACKUPS"`
+	// SecretsRefreshInterval is how often a referenced JWT secret is
+	// re-resolved to pick up rotations.
+	SecretsRefreshInterval time.Duration `yaml:"SECRETS_REFRESH_INTERVAL"`
+	// JWTKeys is a JSON key set, {"primary": "<id>", "keys": {"<id>":
+	// "<secret>"}}, or a secret reference to one. Tokens naming a key in
+	// their kid header are validated against it; tokens without a kid still
+	// use JWT_SECRET. A referenced set is reloaded every
+	// SECRETS_REFRESH_INTERVAL and on RotateJWTKeys.
+	JWTKeys string `yaml:"JWT_KEYS"`
+	// EncryptionKeys maps key IDs to base64 encoded AES-256 keys, usually
+	// secret references, protecting sensitive columns such as contact
+	// emails. EncryptionKeyID names the key new values are encrypted with;
+	// the others only decrypt, until rows are re-encrypted on startup.
+	EncryptionKeyID string            `yaml:"ENCRYPTION_KEY_ID"`
+	EncryptionKeys  map[string]string `yaml:"ENCRYPTION_KEYS"`
+	// UUIDv7IDs gives new companies time-ordered UUIDv7 IDs for better
+	// index locality; existing IDs are unaffected.
+	UUIDv7IDs bool `yaml:"UUIDV7_IDS"`
+	// NameSimilarityThreshold rejects new companies whose name has at least
+	// this trigram similarity (0-1) with an existing one unless forced; 0
+	// disables the check.
+	NameSimilarityThreshold float64 `yaml:"NAME_SIMILARITY_THRESHOLD"`
+	// DuplicateDetectionSchedule suggests likely duplicate companies for
+	// admins to merge or dismiss on a cron schedule, pairing companies of
+	// a tenant whose names have at least DuplicateSimilarityThreshold
+	// trigram similarity (0-1; 0 selects 0.6); empty disables detection.
+	DuplicateDetectionSchedule   string  `yaml:"DUPLICATE_DETECTION_SCHEDULE"`
+	DuplicateSimilarityThreshold float64 `yaml:"DUPLICATE_SIMILARITY_THRESHOLD"`
+	// OwnershipChecks lets callers without the admin role update or delete
+	// only the companies they created.
+	OwnershipChecks bool `yaml:"OWNERSHIP_CHECKS"`
+	// IdempotentDeletes makes deleting a company that does not exist
+	// succeed, reporting nothing was deleted, instead of failing with
+	// NOT_FOUND.
+	IdempotentDeletes bool `yaml:"IDEMPOTENT_DELETES"`
+	// DefaultTenantMaxCompanies and DefaultTenantMaxMutationsPerMinute
+	// limit the tenants of callers whose quota was not set through the
+	// admin RPCs; 0 means unlimited.
+	DefaultTenantMaxCompanies          int `yaml:"DEFAULT_TENANT_MAX_COMPANIES"`
+	DefaultTenantMaxMutationsPerMinute int `yaml:"DEFAULT_TENANT_MAX_MUTATIONS_PER_MINUTE"`
+	// RequestTransactions runs every mutating RPC in a database transaction
+	// of its own, committed only when the call succeeds, and publishes its
+	// events after the commit.
+	RequestTransactions bool `yaml:"REQUEST_TRANSACTIONS"`
+	// ComplianceMode records the request and response payloads of every
+	// mutating RPC, with ComplianceRedactFields masked, in an append-only
+	// table. A successful call whose record cannot be stored fails and, with
+	// RequestTransactions, is rolled back. Records older than
+	// ComplianceRetentionDays are purged on CompliancePurgeSchedule; 0 keeps
+	// them forever.
+	ComplianceMode          bool     `yaml:"COMPLIANCE_MODE"`
+	ComplianceRedactFields  []string `yaml:"COMPLIANCE_REDACT_FIELDS"`
+	ComplianceRetentionDays int      `yaml:"COMPLIANCE_RETENTION_DAYS"`
+	CompliancePurgeSchedule string   `yaml:"COMPLIANCE_PURGE_SCHEDULE"`
+	// ExportBucket enables exporting the companies as gzipped CSV files to
+	// this S3 bucket, or to a bucket of another S3-compatible store such as
+	// Google Cloud Storage at ExportEndpoint. The keys, and the session
+	// token of temporary credentials, may be secret references.
+	// Incremental exports run on ExportSchedule and full ones on
+	// ExportFullSchedule; with neither set, companies are only exported on
+	// request through ExportCompanies.
+	ExportBucket          string `yaml:"EXPORT_BUCKET"`
+	ExportEndpoint        string `yaml:"EXPORT_ENDPOINT"`
+	ExportRegion          string `yaml:"EXPORT_REGION"`
+	ExportPrefix          string `yaml:"EXPORT_PREFIX"`
+	ExportAccessKeyID     string `yaml:"EXPORT_ACCESS_KEY_ID"`
+	ExportSecretAccessKey string `yaml:"EXPORT_SECRET_ACCESS_KEY"`
+	ExportSessionToken    string `yaml:"EXPORT_SESSION_TOKEN"`
+	ExportRowsPerFile     int    `yaml:"EXPORT_ROWS_PER_FILE"`
+	ExportSchedule        string `yaml:"EXPORT_SCHEDULE"`
+	ExportFullSchedule    string `yaml:"EXPORT_FULL_SCHEDULE"`
+	// ReportSchedules deliver predefined reports on cron schedules, stored
+	// under ReportPrefix in the export bucket or emailed with ReportMailer,
+	// "smtp" or "sendgrid", from ReportEmailFrom. The SMTP password and
+	// SendGrid API key may be secret references.
+	ReportSchedules      []report.Schedule `yaml:"REPORT_SCHEDULES"`
+	ReportPrefix         string            `yaml:"REPORT_PREFIX"`
+	ReportMailer         string            `yaml:"REPORT_MAILER"`
+	ReportEmailFrom      string            `yaml:"REPORT_EMAIL_FROM"`
+	ReportSMTPAddr       string            `yaml:"REPORT_SMTP_ADDR"`
+	ReportSMTPUsername   string            `yaml:"REPORT_SMTP_USERNAME"`
+	ReportSMTPPassword   string            `yaml:"REPORT_SMTP_PASSWORD"`
+	ReportSendGridAPIKey string            `yaml:"REPORT_SENDGRID_API_KEY"`
+	// AlertWebhookRefreshInterval is how often the alert webhooks managed
+	// through the admin RPCs are reloaded from the database.
+	AlertWebhookRefreshInterval time.Duration `yaml:"ALERT_WEBHOOK_REFRESH_INTERVAL"`
+	// EnrichmentProviders enables filling in the attributes new companies
+	// lack from these APIs, asked in order; their API keys may be secret
+	// references. Failed lookups are retried EnrichmentMaxAttempts times
+	// with exponential backoff, and a provider failing
+	// EnrichmentBreakerFailures times in a row is skipped for
+	// EnrichmentBreakerCooldown.
+	EnrichmentProviders       []enrichment.HTTPConfig `yaml:"ENRICHMENT_PROVIDERS"`
+	EnrichmentMaxAttempts     int                     `yaml:"ENRICHMENT_MAX_ATTEMPTS"`
+	EnrichmentBackoff         time.Duration           `yaml:"ENRICHMENT_BACKOFF"`
+	EnrichmentBreakerFailures int                     `yaml:"ENRICHMENT_BREAKER_FAILURES"`
+	EnrichmentBreakerCooldown time.Duration           `yaml:"ENRICHMENT_BREAKER_COOLDOWN"`
+	// ValidationHooks are asked, in order, to approve every create and
+	// update before it is committed, e.g. by sanctions screening; their API
+	// keys may be secret references.
+	ValidationHooks []validation.HTTPConfig `yaml:"VALIDATION_HOOKS"`
+	// MaxRecvMsgSize and MaxSendMsgSize are the largest gRPC messages, in
+	// bytes, the server accepts and sends; 0 keeps gRPC's 4MB default.
+	MaxRecvMsgSize int `yaml:"MAX_RECV_MSG_SIZE"`
+	MaxSendMsgSize int `yaml:"MAX_SEND_MSG_SIZE"`
+	// MaxHTTPBodySize is the largest HTTP request body, in bytes, the
+	// gateway accepts; 0 disables the limit.
+	MaxHTTPBodySize int64 `yaml:"MAX_HTTP_BODY_SIZE"`
+	// LoadSheddingMaxInFlight rejects calls with UNAVAILABLE while more are
+	// in flight; 0 disables load shedding. The limit is lowered while the
+	// p99 latency exceeds LoadSheddingTargetP99, and shed callers are told
+	// to retry after LoadSheddingRetryAfter.
+	LoadSheddingMaxInFlight int           `yaml:"LOAD_SHEDDING_MAX_IN_FLIGHT"`
+	LoadSheddingTargetP99   time.Duration `yaml:"LOAD_SHEDDING_TARGET_P99"`
+	LoadSheddingRetryAfter  time.Duration `yaml:"LOAD_SHEDDING_RETRY_AFTER"`
+	// MethodTimeouts is the latency budget of unary calls, by full method
+	// name, and DefaultMethodTimeout that of the other methods; 0 leaves
+	// calls unbounded. Calls out of their budget fail with
+	// DEADLINE_EXCEEDED and code LATENCY_BUDGET_EXCEEDED.
+	MethodTimeouts       map[string]time.Duration `yaml:"METHOD_TIMEOUTS"`
+	DefaultMethodTimeout time.Duration            `yaml:"DEFAULT_METHOD_TIMEOUT"`
+	// V1DeprecatedSince, when set, adds Deprecation headers pointing to v2
+	// to every v1 response, plus a Sunset header when V1Sunset is set.
+	V1DeprecatedSince time.Time `yaml:"V1_DEPRECATED_SINCE"`
+	V1Sunset          time.Time `yaml:"V1_SUNSET"`
+	// FaultInjection enables injecting FAULTS into the repository and event
+	// producer, adjustable at runtime under /admin/faults on the admin port.
+	// For development only.
+	FaultInjection bool          `yaml:"FAULT_INJECTION"`
+	Faults         faults.Config `yaml:"FAULTS"`
 }
 
 func main() {
-	logger := initLogger()
+	check := flag.Bool("check", false, "check the configuration, JWT keys, database, migrations and Kafka, print a JSON report and exit, non-zero on failure")
+	flag.Parse()
+
+	logger, logLevel := initLogger()
+	if *check {
+		code := selfCheck(context.Background(), loadConfig, os.Stdout, logger)
+		_ = logger.Sync()
+		os.Exit(code)
+	}
 	defer func(logger *zap.Logger) {
 		err := logger.Sync()
 		if err != nil {
 			logger.Error("failed to sync logger", zap.Error(err))
 		}
 	}(logger)
+	build := version.Get()
+	logger.Info("Starting company service",
+		zap.String("version", build.Version),
+		zap.String("commit", build.Commit),
+		zap.String("build_time", build.BuildTime),
+		zap.String("go_version", build.GoVersion),
+	)
 
 	cfg, err := loadConfig()
 	if err != nil {
 		logger.Fatal("failed to load config", zap.Error(err))
 	}
 
-	dbConf := initDatabase(cfg)
-	repo, err := gorm.NewRepository(dbConf)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	secretResolver := secrets.FromEnv()
+	jwtSecret, err := secretResolver.Resolve(ctx, cfg.JWTSecret)
 	if err != nil {
-		log.Fatal("failed to initialize database", err)
+		logger.Fatal("failed to resolve JWT secret", zap.Error(err))
+	}
+	if cfg.DBPassword, err = secretResolver.Resolve(ctx, cfg.DBPassword); err != nil {
+		logger.Fatal("failed to resolve database password", zap.Error(err))
+	}
+	rotatingSecret := auth.NewRotatingSecret(jwtSecret, jwtSecretGrace)
+	if secretResolver.IsRef(cfg.JWTSecret) {
+		go secretResolver.Watch(ctx, cfg.JWTSecret, jwtSecret, cfg.SecretsRefreshInterval,
+			func(secret string) {
+				logger.Info("JWT secret rotated")
+				rotatingSecret.Rotate(secret)
+			},
+			func(err error) {
+				logger.Warn("failed to refresh JWT secret", zap.Error(err))
+			})
 	}
 
-	producer, err := events.NewProducer(cfg.KafkaBrokers, logger, cfg.Topic)
+	backoff := startup.Backoff{
+		Initial:  cfg.StartupRetryBackoff,
+		Max:      cfg.StartupRetryMaxBackoff,
+		Attempts: cfg.StartupRetryAttempts,
+	}
+	dbConf := initDatabase(cfg)
+	queryMetrics := gorm.NewQueryMetrics(logger, cfg.SlowQueryThreshold)
+	expvar.Publish("db_queries", queryMetrics)
+	repoOpts := []gorm.Option{gorm.WithQueryMetrics(queryMetrics)}
+	if len(cfg.EncryptionKeys) > 0 {
+		keyring, err := loadKeyring(ctx, secretResolver, cfg.EncryptionKeyID, cfg.EncryptionKeys)
+		if err != nil {
+			logger.Fatal("failed to load encryption keys", zap.Error(err))
+		}
+		repoOpts = append(repoOpts, gorm.WithEncryption(keyring))
+	}
+	switch cfg.MigrationMode {
+	case "", migrationAuto:
+	case migrationVerify:
+		repoOpts = append(repoOpts, gorm.WithoutMigration())
+	default:
+		logger.Fatal("invalid migration mode", zap.String("migration_mode", cfg.MigrationMode))
+	}
+	repo, err := startup.Connect(ctx, logger, "database", backoff, func(context.Context) (*gorm.Repository, error) {
+		return gorm.NewRepository(dbConf, repoOpts...)
+	})
+	if err != nil {
+		logger.Fatal("failed to initialize database", zap.Error(err))
+	}
+	migrations, err := repo.MigrationStatus(ctx)
 	if err != nil {
-		log.Fatal("failed to initialize Kafka producer", err)
+		logger.Fatal("failed to check database schema", zap.Error(err))
+	}
+	if err := migrations.Err(); err != nil {
+		logger.Fatal("database schema incompatible, migrate it or set MIGRATION_MODE: auto", zap.Error(err))
+	}
+	if migrations.Version > migrations.Expected {
+		logger.Warn("database schema migrated by a newer version",
+			zap.Int("schema_version", migrations.Version),
+			zap.Int("expected_schema_version", migrations.Expected),
+		)
+	}
+	if len(cfg.EncryptionKeys) > 0 {
+		go func() {
+			// One instance re-encrypts; the others leave it to it.
+			ran, err := leader.Do(ctx, repo, reencryptLockKey, func(ctx context.Context) error {
+				n, err := repo.ReencryptCompanies(ctx)
+				if n > 0 {
+					logger.Info("Re-encrypted companies", zap.Int64("count", n))
+				}
+				return err
+			})
+			switch {
+			case err != nil:
+				logger.Error("failed to re-encrypt companies", zap.Error(err))
+			case !ran:
+				logger.Info("Re-encryption running on another instance")
+			}
+		}()
+	}
+
+	var (
+		producer eventProducer
+		// kafkaProducer is nil unless events go to Kafka.
+		kafkaProducer *events.StandbyProducer
+	)
+	switch cfg.EventBus {
+	case "", "kafka":
+		kafkaProducer = connectProducer(ctx, cfg, backoff, logger)
+		producer = kafkaProducer
+		expvar.Publish("kafka_producer", kafkaProducer)
+	case "in_process":
+		bus := events.NewBus(logger)
+		bus.Subscribe("event_log", logEvent(logger))
+		producer = bus
+		expvar.Publish("event_bus", bus)
+		logger.Warn("Dispatching events in process; they are not published to Kafka")
+	default:
+		logger.Fatal("invalid event bus", zap.String("event_bus", cfg.EventBus))
 	}
 	defer producer.Close()
 
-	companySvc := controller.NewCompanyService(repo, producer, logger)
+	var serviceOpts []controller.ServiceOption
+	if cfg.UUIDv7IDs {
+		serviceOpts = append(serviceOpts, controller.WithUUIDv7())
+	}
+	if cfg.NameSimilarityThreshold > 0 || cfg.DuplicateDetectionSchedule != "" {
+		if err := repo.EnableNameSimilarity(ctx); err != nil {
+			logger.Fatal("failed to enable name similarity", zap.Error(err))
+		}
+	}
+	if cfg.NameSimilarityThreshold > 0 {
+		serviceOpts = append(serviceOpts, controller.WithNameSimilarity(cfg.NameSimilarityThreshold))
+	}
+	serviceOpts = append(serviceOpts, controller.WithDuplicates(repo, cfg.DuplicateSimilarityThreshold))
+	serviceOpts = append(serviceOpts, controller.WithCompanyTypes(repo))
+	if cfg.OwnershipChecks {
+		serviceOpts = append(serviceOpts, controller.WithOwnershipChecks())
+	}
+	if cfg.IdempotentDeletes {
+		serviceOpts = append(serviceOpts, controller.WithIdempotentDeletes())
+	}
+	if cfg.ReadOnlyWithoutKafka && kafkaProducer != nil {
+		serviceOpts = append(serviceOpts, controller.WithWritesEnabled(kafkaProducer.Connected))
+	}
+	serviceOpts = append(serviceOpts, controller.WithWatchPolling(cfg.WatchPollInterval, cfg.WatchSettleDelay))
+	serviceOpts = append(serviceOpts, controller.WithReports(repo))
+	serviceOpts = append(serviceOpts, controller.WithQuotas(repo, models.TenantQuota{
+		MaxCompanies:          cfg.DefaultTenantMaxCompanies,
+		MaxMutationsPerMinute: cfg.DefaultTenantMaxMutationsPerMinute,
+	}))
+	if cfg.EventOutbox {
+		serviceOpts = append(serviceOpts, controller.WithOutbox(repo))
+	}
+	var (
+		svcRepo     controller.Repository    = repo
+		svcProducer controller.EventProducer = producer
+		injector    *faults.Injector
+	)
+	if cfg.FaultInjection {
+		if injector, err = faults.NewInjector(cfg.Faults, logger); err != nil {
+			logger.Fatal("invalid fault injection configuration", zap.Error(err))
+		}
+		logger.Warn("Fault injection enabled; do not use in production")
+		svcRepo, svcProducer = injector.Repository(repo), injector.Producer(producer)
+	}
+	alerter := integrations.NewAlerter(svcProducer, repo, logger)
+	if err := alerter.Start(ctx, cfg.AlertWebhookRefreshInterval); err != nil {
+		logger.Fatal("failed to start alerting", zap.Error(err))
+	}
+	svcProducer = alerter
+	if cfg.EventOutbox {
+		relay := controller.NewOutboxRelay(repo, alerter.Publishing(producer), repo, cfg.OutboxRelayInterval, logger)
+		go relay.Run(ctx)
+	}
+	var pipeline *enrichment.Pipeline
+	if len(cfg.EnrichmentProviders) > 0 {
+		if pipeline, err = newEnrichmentPipeline(ctx, cfg, secretResolver, logger); err != nil {
+			logger.Fatal("invalid enrichment configuration", zap.Error(err))
+		}
+		serviceOpts = append(serviceOpts, controller.WithEnricher(pipeline))
+	}
+	if len(cfg.ValidationHooks) > 0 {
+		validator, err := newValidator(ctx, cfg, secretResolver, logger)
+		if err != nil {
+			logger.Fatal("invalid validation hook configuration", zap.Error(err))
+		}
+		serviceOpts = append(serviceOpts, controller.WithValidator(validator))
+	}
+	companySvc := controller.NewCompanyService(svcRepo, svcProducer, logger, serviceOpts...)
+	if pipeline != nil {
+		pipeline.Start(ctx, companySvc)
+	}
+
+	if cfg.PurgeAfterDays > 0 {
+		retention := time.Duration(cfg.PurgeAfterDays) * 24 * time.Hour
+		janitor := controller.NewJanitor(repo, repo, retention, cfg.PurgeInterval, logger)
+		go janitor.Run(ctx)
+	}
+
+	jobs := scheduler.New(repo, logger)
+	if cfg.ArchiveAfterDays > 0 {
+		inactivity := time.Duration(cfg.ArchiveAfterDays) * 24 * time.Hour
+		err := jobs.Add("archive-inactive-companies", cfg.ArchiveSchedule, func(ctx context.Context) error {
+			_, err := companySvc.ArchiveInactiveCompanies(ctx, time.Now().Add(-inactivity))
+			return err
+		})
+		if err != nil {
+			logger.Fatal("invalid archive schedule", zap.Error(err))
+		}
+	}
+	if cfg.ComplianceMode && cfg.ComplianceRetentionDays > 0 {
+		retention := time.Duration(cfg.ComplianceRetentionDays) * 24 * time.Hour
+		err := jobs.Add("purge-compliance-records", cfg.CompliancePurgeSchedule, func(ctx context.Context) error {
+			purged, err := repo.PurgeComplianceRecords(ctx, time.Now().Add(-retention))
+			if purged > 0 {
+				logger.Info("Purged expired compliance records", zap.Int64("count", purged))
+			}
+			return err
+		})
+		if err != nil {
+			logger.Fatal("invalid compliance purge schedule", zap.Error(err))
+		}
+	}
+	if cfg.ReplayProtection {
+		err := jobs.Add("purge-used-tokens", usedTokenPurgeSchedule, func(ctx context.Context) error {
+			_, err := repo.PurgeUsedTokens(ctx, time.Now())
+			return err
+		})
+		if err != nil {
+			logger.Fatal("invalid used token purge schedule", zap.Error(err))
+		}
+	}
+	if cfg.DuplicateDetectionSchedule != "" {
+		err := jobs.Add("detect-duplicate-companies", cfg.DuplicateDetectionSchedule, func(ctx context.Context) error {
+			_, err := companySvc.DetectDuplicates(ctx)
+			return err
+		})
+		if err != nil {
+			logger.Fatal("invalid duplicate detection schedule", zap.Error(err))
+		}
+	}
+	var (
+		exportStore *export.S3Store
+		exporter    *export.Exporter
+	)
+	if cfg.ExportBucket != "" {
+		if exportStore, err = newExportStore(ctx, cfg, secretResolver); err != nil {
+			logger.Fatal("invalid export configuration", zap.Error(err))
+		}
+		exporter = newExporter(cfg, repo, exportStore, logger)
+		for _, job := range []struct {
+			name, spec string
+			kind       models.ExportKind
+		}{
+			{"export-companies", cfg.ExportSchedule, models.ExportIncremental},
+			{"export-companies-full", cfg.ExportFullSchedule, models.ExportFull},
+		} {
+			if job.spec == "" {
+				continue
+			}
+			err := jobs.Add(job.name, job.spec, func(ctx context.Context) error {
+				_, err := exporter.Export(ctx, job.kind)
+				return err
+			})
+			if err != nil {
+				logger.Fatal("invalid export schedule", zap.Error(err))
+			}
+		}
+	}
+	reportRunner := report.NewRunner(repo)
+	if len(cfg.ReportSchedules) > 0 {
+		deliverer, err := newReportDeliverer(ctx, cfg, secretResolver, reportRunner, exportStore, logger)
+		if err != nil {
+			logger.Fatal("invalid report configuration", zap.Error(err))
+		}
+		for _, schedule := range cfg.ReportSchedules {
+			err := jobs.Add("report-"+schedule.Name, schedule.Schedule, func(ctx context.Context) error {
+				return deliverer.Deliver(ctx, schedule)
+			})
+			if err != nil {
+				logger.Fatal("invalid report schedule", zap.String("schedule", schedule.Name), zap.Error(err))
+			}
+		}
+	}
+	go jobs.Run(ctx)
 
 	// Create handlers
 	companyHandler := handlers.NewCompanyHandler(companySvc, logger)
+	companyHandler.SetAlertWebhooks(alerter)
+	companyHandler.SetTenantQuotas(companySvc)
+	companyHandler.SetReports(companySvc)
+	companyHandler.SetEmployees(companySvc)
+	companyHandler.SetNotes(companySvc)
+	companyHandler.SetDuplicates(companySvc)
+	companyHandler.SetCompanyTypes(companySvc)
+	visibility, err := handlers.NewFieldVisibility(cfg.FieldVisibility)
+	if err != nil {
+		logger.Fatal("invalid field visibility", zap.Error(err))
+	}
+	companyHandler.SetFieldVisibility(visibility)
+	if exporter != nil {
+		companyHandler.SetExporter(exporter)
+	}
+	var jwtKeys *auth.Keyring
+	if cfg.JWTKeys != "" {
+		if jwtKeys, err = auth.NewKeyring(ctx, func(ctx context.Context) (auth.KeySet, error) {
+			data, err := secretResolver.Resolve(ctx, cfg.JWTKeys)
+			if err != nil {
+				return auth.KeySet{}, err
+			}
+			return auth.ParseKeySet(data)
+		}); err != nil {
+			logger.Fatal("failed to load JWT keys", zap.Error(err))
+		}
+		if secretResolver.IsRef(cfg.JWTKeys) {
+			go jwtKeys.Run(ctx, cfg.SecretsRefreshInterval, func(err error) {
+				logger.Warn("failed to refresh JWT keys", zap.Error(err))
+			})
+		}
+		companyHandler.SetJWTKeys(jwtKeys)
+	}
 
 	// Initialize auth interceptor
-	authInterceptor := auth.NewAuthInterceptor(cfg.JWTSecret)
+	authOpts := []auth.Option{
+		auth.WithRotatingSecret(rotatingSecret),
+		auth.WithAPIKeys(auth.NewAPIKeyAuthenticator(repo)),
+	}
+	if jwtKeys != nil {
+		authOpts = append(authOpts, auth.WithKeyring(jwtKeys))
+	}
+	if len(cfg.ProtectedMethods) > 0 {
+		authOpts = append(authOpts, auth.WithProtectedMethods(cfg.ProtectedMethods...))
+	}
+	if len(cfg.AdminMethods) > 0 {
+		authOpts = append(authOpts, auth.WithAdminMethods(cfg.AdminMethods...))
+	}
+	if err := auth.CheckMethods(slices.Concat(cfg.ProtectedMethods, cfg.AdminMethods, cfg.SingleUseMethods)...); err != nil {
+		logger.Fatal("Invalid auth method configuration", zap.Error(err))
+	}
+	if cfg.ReplayProtection {
+		authOpts = append(authOpts, auth.WithReplayCache(repo))
+	}
+	if len(cfg.SingleUseMethods) > 0 {
+		if !cfg.ReplayProtection {
+			logger.Fatal("SINGLE_USE_METHODS requires REPLAY_PROTECTION")
+		}
+		authOpts = append(authOpts, auth.WithSingleUseMethods(cfg.SingleUseMethods...))
+	}
+	if cfg.OIDCIssuerURL != "" {
+		verifier, err := auth.NewOIDCVerifier(ctx, auth.OIDCConfig{
+			IssuerURL: cfg.OIDCIssuerURL,
+			Audience:  cfg.OIDCAudience,
+		})
+		if err != nil {
+			logger.Fatal("Failed to initialize OIDC verifier", zap.Error(err))
+		}
+		authOpts = append(authOpts, auth.WithOIDC(verifier))
+	}
+	if cfg.PolicyFile != "" {
+		policy, err := auth.LoadPolicy(cfg.PolicyFile, companyResources(repo))
+		if err != nil {
+			logger.Fatal("Failed to load authorization policy", zap.Error(err))
+		}
+		authOpts = append(authOpts, auth.WithAuthorizer(policy))
+	}
+	if len(cfg.MTLSAllowlist) > 0 {
+		for _, methods := range cfg.MTLSAllowlist {
+			if err := auth.CheckMethods(methods...); err != nil {
+				logger.Fatal("Invalid mTLS allowlist", zap.Error(err))
+			}
+		}
+		authOpts = append(authOpts, auth.WithMTLSAllowlist(cfg.MTLSAllowlist))
+	}
+	authInterceptor := auth.NewAuthInterceptor(jwtSecret, authOpts...)
+
+	serverCreds, gatewayCreds, err := transportCredentials(cfg)
+	if err != nil {
+		logger.Fatal("Failed to load TLS credentials", zap.Error(err))
+	}
 	// Create server
-	server := handlers.NewServer(cfg.GRPCPort, cfg.HTTPPort, logger, grpc.UnaryInterceptor(authInterceptor.Unary()))
+	chain := handlers.NewChain(cfg.DisabledMiddleware...)
+	loggingInterceptor := handlers.NewLoggingInterceptor(logger,
+		handlers.WithPayloadSampling(cfg.LogPayloadSampleRate),
+		handlers.WithRedactedFields(cfg.LogRedactFields...),
+	)
+	middleware := []handlers.Middleware{
+		handlers.Recovery(logger),
+		{Name: "localize", Order: handlers.OrderLocalize, Unary: handlers.NewLocalizer().Unary()},
+		{Name: "logging", Order: handlers.OrderLogging, Unary: loggingInterceptor.Unary(), Stream: loggingInterceptor.Stream()},
+		{Name: "auth", Order: handlers.OrderAuth, Required: true, Unary: authInterceptor.Unary(), Stream: authInterceptor.Stream()},
+		handlers.QuotaWarnings(),
+	}
+	if cfg.AccessLog != "" {
+		accessLog, closeAccessLog, err := newAccessLog(cfg)
+		if err != nil {
+			logger.Fatal("Failed to open access log", zap.Error(err))
+		}
+		defer closeAccessLog()
+		middleware = append(middleware, accessLog.Middleware())
+	}
+	if cfg.LoadSheddingMaxInFlight > 0 {
+		sheddingOpts := []handlers.LoadShedderOption{handlers.WithTargetLatency(cfg.LoadSheddingTargetP99)}
+		if cfg.LoadSheddingRetryAfter > 0 {
+			sheddingOpts = append(sheddingOpts, handlers.WithRetryAfter(cfg.LoadSheddingRetryAfter))
+		}
+		shedder := handlers.NewLoadShedder(cfg.LoadSheddingMaxInFlight, sheddingOpts...)
+		expvar.Publish("load_shedding", shedder)
+		middleware = append(middleware, handlers.Middleware{Name: "load-shedding", Order: handlers.OrderLoadShedding, Unary: shedder.Unary()})
+	}
+	timeouts, err := newTimeouts(cfg)
+	if err != nil {
+		logger.Fatal("Invalid method timeouts", zap.Error(err))
+	}
+	expvar.Publish("timeouts", timeouts)
+	middleware = append(middleware, handlers.Middleware{Name: "timeouts", Order: handlers.OrderTimeouts, Unary: timeouts.Unary()})
+	if !cfg.V1DeprecatedSince.IsZero() {
+		middleware = append(middleware, handlers.Middleware{Name: "deprecation", Order: handlers.OrderDeprecation, Unary: handlers.Deprecation{
+			Service:   "definition.v1.CompanyService",
+			Since:     cfg.V1DeprecatedSince,
+			Sunset:    cfg.V1Sunset,
+			Successor: "/v2/companies",
+		}.Unary()})
+	}
+	if cfg.RequestTransactions {
+		transactions := handlers.NewTransactionInterceptor(repo, handlers.MutatingMethods, logger)
+		middleware = append(middleware, handlers.Middleware{Name: "transactions", Order: handlers.OrderTransactions, Unary: transactions.Unary()})
+	}
+	if cfg.ComplianceMode {
+		recorder := handlers.NewComplianceRecorder(repo, handlers.MutatingMethods, cfg.ComplianceRedactFields, logger)
+		middleware = append(middleware, handlers.Middleware{Name: "compliance", Order: handlers.OrderCompliance, Required: true, Unary: recorder.Unary()})
+	}
+	for _, m := range middleware {
+		if err := chain.Register(m); err != nil {
+			logger.Fatal("Invalid middleware configuration", zap.Error(err))
+		}
+	}
+	if err := chain.Check(); err != nil {
+		logger.Fatal("Invalid middleware configuration", zap.Error(err))
+	}
+	logger.Info("Middleware chain", zap.Strings("middleware", chain.Names()))
+	grpcOpts := append([]grpc.ServerOption{grpc.Creds(serverCreds)}, chain.ServerOptions()...)
+	// The gateway relays the same messages, so it gets matching call limits.
+	var gatewayCallOpts []grpc.CallOption
+	if cfg.MaxRecvMsgSize > 0 {
+		grpcOpts = append(grpcOpts, grpc.MaxRecvMsgSize(cfg.MaxRecvMsgSize))
+		gatewayCallOpts = append(gatewayCallOpts, grpc.MaxCallSendMsgSize(cfg.MaxRecvMsgSize))
+	}
+	if cfg.MaxSendMsgSize > 0 {
+		grpcOpts = append(grpcOpts, grpc.MaxSendMsgSize(cfg.MaxSendMsgSize))
+		gatewayCallOpts = append(gatewayCallOpts, grpc.MaxCallRecvMsgSize(cfg.MaxSendMsgSize))
+	}
+	server := handlers.NewServer(cfg.GRPCPort, cfg.HTTPPort, logger, grpcOpts...)
 	server.RegisterGRPCHandler(companyHandler)
+	companyHandlerV2 := handlers.NewCompanyHandlerV2(companySvc, logger)
+	companyHandlerV2.SetFieldVisibility(visibility)
+	server.RegisterGRPCHandlerV2(companyHandlerV2)
+	server.RegisterReportService(handlers.NewReportHandler(reportRunner, logger))
+	if cfg.AdminPort > 0 {
+		server.EnableAdmin(cfg.AdminPort)
+		server.AddReadinessCheck("database", repo.Ping)
+		server.AddReadinessStatus("migrations", func(ctx context.Context) (interface{}, error) {
+			version, err := repo.SchemaVersion(ctx)
+			if err != nil {
+				return nil, err
+			}
+			status := gorm.MigrationStatus{Version: version, Expected: gorm.SchemaVersion}
+			return status, status.Err()
+		})
+		if kafkaProducer != nil {
+			server.AddReadinessCheck("kafka", func(ctx context.Context) error {
+				// While read-only the service is ready to serve reads.
+				if cfg.ReadOnlyWithoutKafka && !kafkaProducer.Connected() {
+					return nil
+				}
+				return kafkaProducer.Ping(ctx)
+			})
+		}
+		server.HandleAdmin("/admin/loglevel", logLevel)
+		server.HandleAdmin("/admin/jobs", jobs)
+		if injector != nil {
+			server.HandleAdmin("/admin/faults", injector)
+		}
+	}
 
 	// Register HTTP gateway
+	server.LimitRequestBody(cfg.MaxHTTPBodySize)
+	server.UseHTTPMiddleware(chain)
 	if err := server.RegisterHTTPGateway(
-		context.Background(),
+		ctx,
 		[]grpc.DialOption{
-			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithTransportCredentials(gatewayCreds),
+			grpc.WithDefaultCallOptions(gatewayCallOpts...),
 		},
-		cfg.JWTSecret); err != nil {
+		jwtSecret,
+		authOpts...); err != nil {
 		logger.Fatal("Failed to register HTTP gateway", zap.Error(err))
 	}
 	// Start servers
-	if err := server.Start(); err != nil {
+	if err := serveUntilSignal(server, producer, cancel, logger, os.Interrupt, syscall.SIGTERM); err != nil {
 		logger.Fatal("Failed to start servers", zap.Error(err))
 	}
+}
 
-	waitForShutdown(server, logger)
+// initLogger initializes a Zap production logger, returning its level so it
+// can be changed at runtime.
+func initLogger() (*zap.Logger, zap.AtomicLevel) {
+	cfg := zap.NewProductionConfig()
+	logger, _ := cfg.Build()
+	return logger, cfg.Level
 }
 
-// initLogger initializes a Zap production logger.
-func initLogger() *zap.Logger {
-	logger, _ := zap.NewProduction()
-	return logger
+// newAccessLog returns the HTTP access log configured by cfg and a function
+// closing its file.
+func newAccessLog(cfg *Config) (*handlers.AccessLog, func(), error) {
+	format, err := handlers.ParseAccessLogFormat(cfg.AccessLogFormat)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.AccessLogSampleRate < 0 || cfg.AccessLogSampleRate > 1 {
+		return nil, nil, fmt.Errorf("access log sample rate %v not between 0 and 1", cfg.AccessLogSampleRate)
+	}
+	opts := []handlers.AccessLogOption{
+		handlers.WithAccessLogFormat(format),
+		handlers.WithAccessLogSampling(cfg.AccessLogSampleRate),
+	}
+	if cfg.AccessLog == "stdout" {
+		return handlers.NewAccessLog(os.Stdout, opts...), func() {}, nil
+	}
+	file, err := logfile.Open(cfg.AccessLog, int64(cfg.AccessLogMaxSizeMB)<<20, cfg.AccessLogMaxBackups)
+	if err != nil {
+		return nil, nil, err
+	}
+	return handlers.NewAccessLog(file, opts...), func() { _ = file.Close() }, nil
 }
 
 // loadConfig loads configuration. Use real config tooling (e.g. Viper) in production.
@@ -107,27 +907,395 @@ func loadConfig() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	defaultBackoff := startup.DefaultBackoff()
+	if cfg.StartupRetryAttempts <= 0 {
+		cfg.StartupRetryAttempts = defaultBackoff.Attempts
+	}
+	if cfg.StartupRetryBackoff <= 0 {
+		cfg.StartupRetryBackoff = defaultBackoff.Initial
+	}
+	if cfg.StartupRetryMaxBackoff <= 0 {
+		cfg.StartupRetryMaxBackoff = defaultBackoff.Max
+	}
+	if cfg.SecretsRefreshInterval <= 0 {
+		cfg.SecretsRefreshInterval = defaultSecretsRefreshInterval
+	}
+	defaultTopics := events.DefaultTopicSettings()
+	if cfg.TopicPartitions <= 0 {
+		cfg.TopicPartitions = defaultTopics.Partitions
+	}
+	if cfg.TopicReplicationFactor <= 0 {
+		cfg.TopicReplicationFactor = defaultTopics.ReplicationFactor
+	}
+	if cfg.ArchiveSchedule == "" {
+		cfg.ArchiveSchedule = defaultArchiveSchedule
+	}
+	if cfg.CompliancePurgeSchedule == "" {
+		cfg.CompliancePurgeSchedule = defaultCompliancePurgeSchedule
+	}
+	if cfg.AlertWebhookRefreshInterval <= 0 {
+		cfg.AlertWebhookRefreshInterval = defaultAlertWebhookRefreshInterval
+	}
+	if cfg.EnrichmentMaxAttempts <= 0 {
+		cfg.EnrichmentMaxAttempts = defaultEnrichmentMaxAttempts
+	}
+	if cfg.EnrichmentBackoff <= 0 {
+		cfg.EnrichmentBackoff = defaultEnrichmentBackoff
+	}
+	if cfg.EnrichmentBreakerFailures <= 0 {
+		cfg.EnrichmentBreakerFailures = defaultEnrichmentBreakerFailures
+	}
+	if cfg.EnrichmentBreakerCooldown <= 0 {
+		cfg.EnrichmentBreakerCooldown = defaultEnrichmentBreakerCooldown
+	}
 	return &cfg, nil
 }
 
+// newEnrichmentPipeline builds the enrichment pipeline asking the configured
+// providers, with their API keys resolved.
+func newEnrichmentPipeline(ctx context.Context, cfg *Config, resolver *secrets.Resolver, logger *zap.Logger) (*enrichment.Pipeline, error) {
+	providers := make([]enrichment.Provider, 0, len(cfg.EnrichmentProviders))
+	for _, providerCfg := range cfg.EnrichmentProviders {
+		apiKey, err := resolver.Resolve(ctx, providerCfg.APIKey)
+		if err != nil {
+			return nil, fmt.Errorf("enrichment provider %s: %w", providerCfg.Name, err)
+		}
+		providerCfg.APIKey = apiKey
+		provider, err := enrichment.NewHTTPProvider(providerCfg)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, provider)
+	}
+	return enrichment.NewPipeline(providers, logger,
+		enrichment.WithRetry(cfg.EnrichmentMaxAttempts, cfg.EnrichmentBackoff),
+		enrichment.WithCircuitBreaker(cfg.EnrichmentBreakerFailures, cfg.EnrichmentBreakerCooldown),
+	), nil
+}
+
+// newValidator builds the validator asking the configured hooks, with their
+// API keys resolved.
+func newValidator(ctx context.Context, cfg *Config, resolver *secrets.Resolver, logger *zap.Logger) (*validation.Validator, error) {
+	hooks := make([]validation.Hook, 0, len(cfg.ValidationHooks))
+	for _, hookCfg := range cfg.ValidationHooks {
+		apiKey, err := resolver.Resolve(ctx, hookCfg.APIKey)
+		if err != nil {
+			return nil, fmt.Errorf("validation hook %s: %w", hookCfg.Name, err)
+		}
+		hookCfg.APIKey = apiKey
+		hook, err := validation.NewHTTPHook(hookCfg)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return validation.NewValidator(logger, hooks...), nil
+}
+
+// newExportStore returns the configured bucket, with its keys resolved.
+func newExportStore(ctx context.Context, cfg *Config, resolver *secrets.Resolver) (*export.S3Store, error) {
+	accessKeyID, err := resolver.Resolve(ctx, cfg.ExportAccessKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("export access key ID: %w", err)
+	}
+	secretAccessKey, err := resolver.Resolve(ctx, cfg.ExportSecretAccessKey)
+	if err != nil {
+		return nil, fmt.Errorf("export secret access key: %w", err)
+	}
+	sessionToken, err := resolver.Resolve(ctx, cfg.ExportSessionToken)
+	if err != nil {
+		return nil, fmt.Errorf("export session token: %w", err)
+	}
+	return export.NewS3Store(export.S3Config{
+		Endpoint:        cfg.ExportEndpoint,
+		Region:          cfg.ExportRegion,
+		Bucket:          cfg.ExportBucket,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		SessionToken:    sessionToken,
+	})
+}
+
+// newExporter builds the exporter writing to store.
+func newExporter(cfg *Config, repo *gorm.Repository, store *export.S3Store, logger *zap.Logger) *export.Exporter {
+	opts := []export.Option{export.WithRowsPerFile(cfg.ExportRowsPerFile)}
+	if cfg.ExportPrefix != "" {
+		opts = append(opts, export.WithPrefix(cfg.ExportPrefix))
+	}
+	return export.New(repo, repo, store, logger, opts...)
+}
+
+// newReportDeliverer builds the deliverer of the report schedules, storing
+// reports in store, when there is an export bucket, and emailing them with
+// the configured mailer, when there is one.
+func newReportDeliverer(ctx context.Context, cfg *Config, resolver *secrets.Resolver, runner *report.Runner, store *export.S3Store, logger *zap.Logger) (*report.Deliverer, error) {
+	var objects report.ObjectStore
+	if store != nil {
+		objects = store
+	}
+	var mailer notifier.Mailer
+	switch cfg.ReportMailer {
+	case "":
+	case "smtp":
+		password, err := resolver.Resolve(ctx, cfg.ReportSMTPPassword)
+		if err != nil {
+			return nil, fmt.Errorf("report SMTP password: %w", err)
+		}
+		if mailer, err = notifier.NewSMTPMailer(cfg.ReportSMTPAddr, cfg.ReportSMTPUsername, password, cfg.ReportEmailFrom); err != nil {
+			return nil, err
+		}
+	case "sendgrid":
+		apiKey, err := resolver.Resolve(ctx, cfg.ReportSendGridAPIKey)
+		if err != nil {
+			return nil, fmt.Errorf("report SendGrid API key: %w", err)
+		}
+		if mailer, err = notifier.NewSendGridMailer(apiKey, cfg.ReportEmailFrom); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown report mailer %q", cfg.ReportMailer)
+	}
+	return report.NewDeliverer(runner, objects, cfg.ReportPrefix, mailer, logger), nil
+}
+
 // initDatabase initializes the database connection.
 func initDatabase(cfg *Config) *gorm.Config {
 	return &gorm.Config{
+		Driver:   cfg.DBDriver,
+		Path:     cfg.DBPath,
 		Host:     cfg.DBHost,
 		Port:     cfg.DBPort,
 		User:     cfg.DBUser,
 		Password: cfg.DBPassword,
 		DBName:   cfg.DBName,
 		SSLMode:  cfg.DBSSLMode,
+		Schema:   cfg.DBSchema,
 	}
 }
 
-// waitForShutdown blocks until an interrupt or SIGTERM is received, then shuts down servers.
-func waitForShutdown(server *handlers.Server, logger *zap.Logger) {
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
-	<-stop
+// eventProducer publishes the events of the company service, to Kafka or in
+// process.
+type eventProducer interface {
+	controller.EventProducer
+	Publish(ctx context.Context, event events.Event) error
+	Flush(ctx context.Context) error
+	Close()
+}
+
+// connectProducer connects the Kafka producer, retrying with backoff. With
+// ReadOnlyWithoutKafka, it keeps connecting in the background when Kafka
+// stays unreachable.
+func connectProducer(ctx context.Context, cfg *Config, backoff startup.Backoff, logger *zap.Logger) *events.StandbyProducer {
+	producerOpts, err := producerOptions(cfg)
+	if err != nil {
+		logger.Fatal("invalid Kafka settings", zap.Error(err))
+	}
+	connectKafka := func(context.Context) (*events.Producer, error) {
+		producer, err := events.NewProducer(cfg.KafkaBrokers, logger, cfg.Topic, producerOpts...)
+		if errors.Is(err, events.ErrTopicMissing) {
+			return nil, startup.Permanent(err)
+		}
+		return producer, err
+	}
+	producer := events.NewStandbyProducer(logger)
+	kafkaProducer, err := startup.Connect(ctx, logger, "kafka", backoff, connectKafka)
+	switch {
+	case err == nil:
+		producer.Connect(kafkaProducer)
+	case errors.Is(err, events.ErrTopicMissing):
+		logger.Fatal("Kafka topic missing, create it or enable topic creation", zap.Error(err))
+	case cfg.ReadOnlyWithoutKafka:
+		logger.Error("Kafka unavailable, serving reads only until it connects", zap.Error(err))
+		go func() {
+			unbounded := backoff
+			unbounded.Attempts = 0
+			if kafkaProducer, err := startup.Connect(ctx, logger, "kafka", unbounded, connectKafka); err == nil {
+				producer.Connect(kafkaProducer)
+				logger.Info("Kafka connected, accepting writes")
+			}
+		}()
+	default:
+		logger.Fatal("failed to initialize Kafka producer", zap.Error(err))
+	}
+	return producer
+}
+
+// newTimeouts returns the latency budgets of the methods.
+func newTimeouts(cfg *Config) (*handlers.Timeouts, error) {
+	if err := auth.CheckMethods(slices.Collect(maps.Keys(cfg.MethodTimeouts))...); err != nil {
+		return nil, err
+	}
+	return handlers.NewTimeouts(cfg.DefaultMethodTimeout, cfg.MethodTimeouts)
+}
+
+// producerOptions returns the options of the Kafka producer configured by
+// cfg.
+func producerOptions(cfg *Config) ([]events.ProducerOption, error) {
+	topicStrategy, err := events.ParseTopicStrategy(cfg.TopicStrategy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid topic strategy: %w", err)
+	}
+	codec, err := events.ParseCodec(cfg.EventEncoding)
+	if err != nil {
+		return nil, fmt.Errorf("invalid event encoding: %w", err)
+	}
+	kafkaTLS, err := kafkaTLSConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Kafka TLS settings: %w", err)
+	}
+	opts := []events.ProducerOption{
+		events.WithTransport(events.TransportSettings{
+			DialTimeout: cfg.KafkaDialTimeout,
+			MetadataTTL: cfg.KafkaMetadataTTL,
+			TLS:         kafkaTLS,
+		}),
+		events.WithTopicStrategy(topicStrategy),
+		events.WithCodec(codec),
+		events.WithTopicSettings(topicSettings(cfg)),
+	}
+	if cfg.AuditTopic != "" {
+		opts = append(opts, events.WithAuditTopic(cfg.AuditTopic, cfg.AuditTopicRetention))
+	}
+	return opts, nil
+}
+
+// topicSettings returns how the producer provisions its topics.
+func topicSettings(cfg *Config) events.TopicSettings {
+	return events.TopicSettings{
+		AutoCreate:        !cfg.DisableTopicCreation,
+		Partitions:        cfg.TopicPartitions,
+		ReplicationFactor: cfg.TopicReplicationFactor,
+		Retention:         cfg.TopicRetention,
+	}
+}
+
+// logEvent returns the in-process subscriber logging every event.
+func logEvent(logger *zap.Logger) func(context.Context, events.Event) error {
+	return func(_ context.Context, event events.Event) error {
+		logger.Debug("Event",
+			zap.String("event_type", string(event.Type)),
+			zap.String("event_id", event.EventID.String()),
+			zap.String("company_id", event.Company.ID.String()),
+			zap.String("actor", event.Actor),
+		)
+		return nil
+	}
+}
+
+// kafkaTLSConfig returns the TLS config for the broker connections, or nil
+// when cfg.KafkaTLSVersions is empty.
+func kafkaTLSConfig(cfg *Config) (*tls.Config, error) {
+	if len(cfg.KafkaTLSVersions) == 0 {
+		return nil, nil
+	}
+	minVersion, maxVersion, err := events.ParseTLSVersions(cfg.KafkaTLSVersions)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{MinVersion: minVersion, MaxVersion: maxVersion}
+	if cfg.KafkaTLSCAFile != "" {
+		if config.RootCAs, err = auth.LoadCertPool(cfg.KafkaTLSCAFile); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// transportCredentials returns the gRPC server credentials and those the HTTP
+// gateway dials it with: TLS when a server certificate is configured,
+// plaintext otherwise.
+func transportCredentials(cfg *Config) (credentials.TransportCredentials, credentials.TransportCredentials, error) {
+	if cfg.TLSCertFile == "" {
+		return insecure.NewCredentials(), insecure.NewCredentials(), nil
+	}
+
+	serverTLS, err := auth.ServerTLSConfig(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	gatewayTLS := &tls.Config{
+		ServerName: cfg.TLSServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if cfg.TLSCAFile != "" {
+		if gatewayTLS.RootCAs, err = auth.LoadCertPool(cfg.TLSCAFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	return credentials.NewTLS(serverTLS), credentials.NewTLS(gatewayTLS), nil
+}
+
+// companyResources resolves the company a request targets for authorization
+// policies. Requests without a valid company ID resolve to no resource.
+// Soft-deleted companies still resolve, so their owner may retry a delete.
+func companyResources(repo *gorm.Repository) auth.ResourceResolver {
+	return func(ctx context.Context, req interface{}) (map[string]string, error) {
+		r, ok := req.(interface{ GetId() string })
+		if !ok {
+			return nil, nil
+		}
+		id, err := uuid.Parse(r.GetId())
+		if err != nil {
+			return nil, nil
+		}
+		company, err := repo.GetCompanyIncludingDeleted(ctx, id)
+		if errors.Is(err, e.ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"created_by": company.CreatedBy}, nil
+	}
+}
+
+// servers is the lifecycle of the servers of the service; Start blocks
+// while they serve.
+type servers interface {
+	Start() error
+	Stop()
+}
+
+// serveUntilSignal runs server until one of signals is received or it fails
+// to serve. It then stops server, cancels the background work of the
+// service and flushes the events still queued in producer, waiting up to
+// producerFlushTimeout, and returns the error of serving, if any.
+func serveUntilSignal(server servers, producer eventProducer, cancel context.CancelFunc, logger *zap.Logger, signals ...os.Signal) error {
+	stopped, stop := signal.NotifyContext(context.Background(), signals...)
+	defer stop()
+
+	served := make(chan error, 1)
+	go func() { served <- server.Start() }()
+	var err error
+	select {
+	case <-stopped.Done():
+		logger.Info("Shutdown signal received")
+	case err = <-served:
+	}
 
 	server.Stop()
 	logger.Info("Servers stopped properly")
+	cancel()
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), producerFlushTimeout)
+	defer cancelFlush()
+	if err := producer.Flush(flushCtx); err != nil {
+		logger.Error("failed to flush pending events", zap.Error(err))
+	}
+	return err
+}
+
+// loadKeyring resolves the encryption keys, which may be secret references,
+// into a keyring encrypting with the key named primary.
+func loadKeyring(ctx context.Context, resolver *secrets.Resolver, primary string, refs map[string]string) (*gorm.Keyring, error) {
+	keys := make(map[string][]byte, len(refs))
+	for id, ref := range refs {
+		encoded, err := resolver.Resolve(ctx, ref)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		if keys[id], err = gorm.ParseKey(encoded); err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+	}
+	return gorm.NewKeyring(primary, keys)
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// blockingServers serves until stopped.
+type blockingServers struct {
+	started chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+func (s *blockingServers) Start() error {
+	close(s.started)
+	<-s.stopped
+	return nil
+}
+
+func (s *blockingServers) Stop() {
+	s.once.Do(func() { close(s.stopped) })
+}
+
+// TestServeUntilSignal verifies a shutdown signal stops the servers,
+// cancels the background work and flushes the queued events.
+func TestServeUntilSignal(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	bus := events.NewBus(logger)
+	var (
+		mu        sync.Mutex
+		delivered []events.Event
+	)
+	bus.Subscribe("slow", func(_ context.Context, event events.Event) error {
+		time.Sleep(100 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, event)
+		return nil
+	})
+	server := &blockingServers{started: make(chan struct{}), stopped: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- serveUntilSignal(server, bus, cancel, logger, syscall.SIGUSR1) }()
+	<-server.started
+	bus.Produce(events.Event{Type: events.CompanyCreated, Company: &models.Company{ID: uuid.New()}})
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the signal to shut the service down")
+	}
+	assert.ErrorIs(t, ctx.Err(), context.Canceled, "the background work should be canceled")
+	select {
+	case <-server.stopped:
+	default:
+		t.Error("expected the servers to be stopped")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, delivered, 1, "the queued event should be flushed before returning")
+}
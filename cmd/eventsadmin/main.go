@@ -0,0 +1,81 @@
+// Command eventsadmin inspects and adjusts consumer group offsets on the
+// company event topics, so reprocessing after a bug fix doesn't require
+// external Kafka tooling.
+//
+// Usage:
+//
+//	eventsadmin lag   -brokers localhost:9092 -group notifier -topic company_events
+//	eventsadmin reset -brokers localhost:9092 -group notifier -topic company_events -to 2025-03-01T00:00:00Z
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/events"
+)
+
+const requestTimeout = 30 * time.Second
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	brokers := fs.String("brokers", "localhost:9092", "comma-separated list of Kafka brokers")
+	group := fs.String("group", "", "consumer group ID")
+	topic := fs.String("topic", "", "topic name")
+	to := fs.String("to", "", "RFC3339 timestamp to reset offsets to (reset only)")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+	if *group == "" || *topic == "" {
+		log.Fatal("-group and -topic are required")
+	}
+
+	admin := events.NewGroupAdmin(strings.Split(*brokers, ","))
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	switch os.Args[1] {
+	case "lag":
+		lags, err := admin.Lag(ctx, *group, *topic)
+		if err != nil {
+			log.Fatalf("failed to fetch lag: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PARTITION\tCOMMITTED\tEND\tLAG")
+		for _, l := range lags {
+			fmt.Fprintf(w, "%d\t%d\t%d\t%d\n", l.Partition, l.CommittedOffset, l.EndOffset, l.Lag)
+		}
+		if err := w.Flush(); err != nil {
+			log.Fatal(err)
+		}
+	case "reset":
+		at, err := time.Parse(time.RFC3339, *to)
+		if err != nil {
+			log.Fatalf("invalid -to timestamp: %v", err)
+		}
+		offsets, err := admin.ResetOffsetsToTime(ctx, *group, *topic, at)
+		if err != nil {
+			log.Fatalf("failed to reset offsets: %v", err)
+		}
+		for partition, offset := range offsets {
+			log.Printf("partition %d reset to offset %d", partition, offset)
+		}
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: eventsadmin <lag|reset> -group <id> -topic <name> [-brokers <list>] [-to <RFC3339>]")
+	os.Exit(2)
+}
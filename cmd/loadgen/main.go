@@ -0,0 +1,260 @@
+// Command loadgen drives a running company service over gRPC at a fixed
+// request rate with a mix of creates, gets and updates, and reports
+// throughput and latency percentiles per operation.
+//
+// Usage:
+//
+//	loadgen -addr localhost:50051 -rps 200 -duration 30s -mix create=1,get=8,update=1 -token <JWT>
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	mathrand "math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/company/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const requestTimeout = 5 * time.Second
+
+// operation is one kind of request in the mix.
+type operation string
+
+const (
+	opCreate operation = "create"
+	opGet    operation = "get"
+	opUpdate operation = "update"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:50051", "gRPC address of the company service")
+	rps := flag.Int("rps", 100, "requests per second")
+	duration := flag.Duration("duration", 30*time.Second, "how long to send requests")
+	concurrency := flag.Int("concurrency", 32, "maximum requests in flight")
+	mix := flag.String("mix", "create=1,get=8,update=1", "relative weights of create, get and update")
+	token := flag.String("token", "", "JWT sent as a bearer token")
+	apiKey := flag.String("api-key", "", "API key sent instead of a token")
+	flag.Parse()
+
+	weights, err := parseMix(*mix)
+	if err != nil {
+		log.Fatalf("invalid -mix: %v", err)
+	}
+	if *rps <= 0 || *concurrency <= 0 {
+		log.Fatal("-rps and -concurrency must be positive")
+	}
+
+	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	var md metadata.MD
+	switch {
+	case *apiKey != "":
+		md = metadata.Pairs(auth.APIKeyHeader, *apiKey)
+	case *token != "":
+		md = metadata.Pairs("authorization", "Bearer "+*token)
+	}
+	g := &generator{client: pb.NewCompanyServiceClient(conn), md: md, stats: make(map[operation]*opStats)}
+
+	ticker := time.NewTicker(time.Second / time.Duration(*rps))
+	defer ticker.Stop()
+	deadline := time.After(*duration)
+	slots := make(chan struct{}, *concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	dropped := 0
+
+loop:
+	for {
+		select {
+		case <-deadline:
+			break loop
+		case <-ticker.C:
+			select {
+			case slots <- struct{}{}:
+			default:
+				// Every slot is busy: the service is slower than the rate.
+				dropped++
+				continue
+			}
+			op := weights.pick()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				g.run(op)
+			}()
+		}
+	}
+	wg.Wait()
+
+	g.report(time.Since(start), dropped)
+}
+
+// generator sends requests and records their outcome.
+type generator struct {
+	client pb.CompanyServiceClient
+	md     metadata.MD
+
+	mu    sync.Mutex
+	ids   []string
+	stats map[operation]*opStats
+}
+
+type opStats struct {
+	latencies []time.Duration
+	errors    int
+}
+
+func (g *generator) run(op operation) {
+	id, ok := g.randomID()
+	if !ok {
+		// Gets and updates need an existing company.
+		op = opCreate
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	if g.md != nil {
+		ctx = metadata.NewOutgoingContext(ctx, g.md)
+	}
+
+	start := time.Now()
+	var err error
+	switch op {
+	case opCreate:
+		var resp *pb.CreateCompanyResponse
+		resp, err = g.client.CreateCompany(ctx, &pb.CreateCompanyRequest{
+			Company: &pb.Company{Name: randomName(), Employees: mathrand.Int32N(1000), Type: pb.CompanyType_CORPORATIONS},
+			Force:   true,
+		})
+		if err == nil {
+			g.mu.Lock()
+			g.ids = append(g.ids, resp.GetCompany().GetId())
+			g.mu.Unlock()
+		}
+	case opGet:
+		_, err = g.client.GetCompany(ctx, &pb.GetCompanyRequest{Id: id})
+	case opUpdate:
+		_, err = g.client.UpdateCompany(ctx, &pb.UpdateCompanyRequest{
+			Id:      id,
+			Company: &pb.Company{Name: randomName(), Employees: mathrand.Int32N(1000), Type: pb.CompanyType_CORPORATIONS},
+		})
+	}
+	elapsed := time.Since(start)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s, ok := g.stats[op]
+	if !ok {
+		s = &opStats{}
+		g.stats[op] = s
+	}
+	s.latencies = append(s.latencies, elapsed)
+	if err != nil {
+		s.errors++
+	}
+}
+
+func (g *generator) randomID() (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.ids) == 0 {
+		return "", false
+	}
+	return g.ids[mathrand.IntN(len(g.ids))], true
+}
+
+func (g *generator) report(elapsed time.Duration, dropped int) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OP\tREQUESTS\tERRORS\tRPS\tP50\tP95\tP99")
+	for _, op := range []operation{opCreate, opGet, opUpdate} {
+		s, ok := g.stats[op]
+		if !ok {
+			continue
+		}
+		slices.Sort(s.latencies)
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f\t%v\t%v\t%v\n", op, len(s.latencies), s.errors,
+			float64(len(s.latencies))/elapsed.Seconds(),
+			percentile(s.latencies, 0.50), percentile(s.latencies, 0.95), percentile(s.latencies, 0.99))
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if dropped > 0 {
+		log.Printf("%d requests not sent because -concurrency requests were in flight", dropped)
+	}
+}
+
+// percentile returns the p-th percentile of sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
+
+// mixWeights holds the cumulative weights of the operations in the mix.
+type mixWeights struct {
+	ops   []operation
+	upper []int
+}
+
+// parseMix parses "create=1,get=8,update=1".
+func parseMix(s string) (mixWeights, error) {
+	var m mixWeights
+	total := 0
+	for _, part := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		op := operation(name)
+		if !ok || (op != opCreate && op != opGet && op != opUpdate) {
+			return m, fmt.Errorf("expected <create|get|update>=<weight>, got %q", part)
+		}
+		weight, err := strconv.Atoi(value)
+		if err != nil || weight < 0 {
+			return m, fmt.Errorf("invalid weight %q", value)
+		}
+		total += weight
+		m.ops = append(m.ops, op)
+		m.upper = append(m.upper, total)
+	}
+	if total == 0 {
+		return m, fmt.Errorf("weights must not all be zero")
+	}
+	return m, nil
+}
+
+func (m mixWeights) pick() operation {
+	n := mathrand.IntN(m.upper[len(m.upper)-1])
+	for i, upper := range m.upper {
+		if n < upper {
+			return m.ops[i]
+		}
+	}
+	return m.ops[len(m.ops)-1]
+}
+
+// randomName returns a unique name within the service's 15 character limit.
+func randomName() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return "lg-" + hex.EncodeToString(b)
+}
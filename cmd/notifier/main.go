@@ -0,0 +1,151 @@
+// Command notifier consumes company events and emails the recipients of the
+// routing rules in internal/notifier/config/config.yaml, through SMTP or
+// SendGrid. With -preview, it prints the emails the rules would send about
+// a JSON event read from stdin instead, sending nothing.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	gorm "github.com/gartstein/xm/internal/company/db"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/notifier"
+	"github.com/gartstein/xm/internal/pkg/secrets"
+	"github.com/gartstein/xm/internal/pkg/version"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// Config struct for YAML configuration
+type Config struct {
+	KafkaBrokers   []string        `yaml:"KAFKA_BROKERS"`
+	Topic          string          `yaml:"TOPIC"`
+	TopicStrategy  string          `yaml:"TOPIC_STRATEGY"` // must match the company service
+	GroupID        string          `yaml:"GROUP_ID"`
+	Concurrency    int             `yaml:"CONCURRENCY"` // events handled at once; a company's events stay in order
+	DBHost         string          `yaml:"DB_HOST"`     // empty runs without the dedup store
+	DBPort         int             `yaml:"DB_PORT"`
+	DBUser         string          `yaml:"DB_USER"`
+	DBPassword     string          `yaml:"DB_PASSWORD"`
+	DBName         string          `yaml:"DB_NAME"`
+	DBSSLMode      string          `yaml:"DB_SSLMODE"`
+	DBSchema       string          `yaml:"DB_SCHEMA"` // created if missing; empty uses the default search_path
+	Mailer         string          `yaml:"MAILER"`    // "smtp" or "sendgrid"
+	From           string          `yaml:"FROM"`
+	SMTPAddr       string          `yaml:"SMTP_ADDR"`
+	SMTPUsername   string          `yaml:"SMTP_USERNAME"`
+	SMTPPassword   string          `yaml:"SMTP_PASSWORD"`    // literal or secret reference
+	SendGridAPIKey string          `yaml:"SENDGRID_API_KEY"` // literal or secret reference
+	Rules          []notifier.Rule `yaml:"RULES"`
+}
+
+func main() {
+	previewOnly := flag.Bool("preview", false, "print the emails the rules would send about the JSON event on stdin, and exit")
+	flag.Parse()
+
+	logger, _ := zap.NewProduction()
+	defer func() { _ = logger.Sync() }()
+	build := version.Get()
+	logger.Info("Starting notifier",
+		zap.String("version", build.Version),
+		zap.String("commit", build.Commit),
+		zap.String("build_time", build.BuildTime),
+	)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Fatal("failed to load config", zap.Error(err))
+	}
+	if *previewOnly {
+		if err := preview(cfg.Rules, os.Stdin, os.Stdout, logger); err != nil {
+			logger.Fatal("failed to preview event", zap.Error(err))
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	resolver := secrets.FromEnv()
+	mailer, err := newMailer(ctx, cfg, resolver)
+	if err != nil {
+		logger.Fatal("failed to initialize mailer", zap.Error(err))
+	}
+	n, err := notifier.NewNotifier(mailer, cfg.Rules, logger)
+	if err != nil {
+		logger.Fatal("invalid notification rules", zap.Error(err))
+	}
+
+	strategy, err := events.ParseTopicStrategy(cfg.TopicStrategy)
+	if err != nil {
+		logger.Fatal("invalid topic strategy", zap.Error(err))
+	}
+	opts := []events.ConsumerOption{events.WithConcurrency(cfg.Concurrency)}
+	if cfg.DBHost != "" {
+		if cfg.DBPassword, err = resolver.Resolve(ctx, cfg.DBPassword); err != nil {
+			logger.Fatal("failed to resolve database password", zap.Error(err))
+		}
+		repo, err := gorm.NewRepository(&gorm.Config{
+			Host:     cfg.DBHost,
+			Port:     cfg.DBPort,
+			User:     cfg.DBUser,
+			Password: cfg.DBPassword,
+			DBName:   cfg.DBName,
+			SSLMode:  cfg.DBSSLMode,
+			Schema:   cfg.DBSchema,
+		})
+		if err != nil {
+			logger.Fatal("failed to initialize database", zap.Error(err))
+		}
+		opts = append(opts, events.WithDedupStore(repo))
+	}
+
+	topics := events.ConsumerTopics(strategy, cfg.Topic, n.EventTypes()...)
+	consumer := events.NewConsumer(cfg.KafkaBrokers, cfg.GroupID, topics, logger, opts...)
+	consumer.RegisterHandler(n.Handle)
+	consumer.Start(ctx)
+	logger.Info("Notifier started", zap.Strings("topics", topics), zap.String("group_id", cfg.GroupID))
+
+	<-ctx.Done()
+	consumer.Close()
+	logger.Info("Notifier stopped")
+}
+
+// loadConfig loads configuration.
+func loadConfig() (*Config, error) {
+	file, err := os.ReadFile(filepath.Join("internal", "notifier", "config", "config.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(file, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// newMailer returns the configured mailer, resolving its credentials.
+func newMailer(ctx context.Context, cfg *Config, resolver *secrets.Resolver) (notifier.Mailer, error) {
+	switch cfg.Mailer {
+	case "smtp":
+		password, err := resolver.Resolve(ctx, cfg.SMTPPassword)
+		if err != nil {
+			return nil, err
+		}
+		return notifier.NewSMTPMailer(cfg.SMTPAddr, cfg.SMTPUsername, password, cfg.From)
+	case "sendgrid":
+		apiKey, err := resolver.Resolve(ctx, cfg.SendGridAPIKey)
+		if err != nil {
+			return nil, err
+		}
+		return notifier.NewSendGridMailer(apiKey, cfg.From)
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", cfg.Mailer)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/notifier"
+	"go.uber.org/zap"
+)
+
+// preview renders the emails the rules would send about the JSON event read
+// from in, such as the event returned by the company service's PreviewEvent,
+// and writes them to out without sending them.
+func preview(rules []notifier.Rule, in io.Reader, out io.Writer, logger *zap.Logger) error {
+	n, err := notifier.NewNotifier(nil, rules, logger)
+	if err != nil {
+		return fmt.Errorf("invalid notification rules: %w", err)
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	var event events.Event
+	if err := (events.JSONCodec{}).Unmarshal(data, &event); err != nil {
+		return fmt.Errorf("invalid event: %w", err)
+	}
+	msgs, err := n.Preview(event)
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 {
+		_, err = fmt.Fprintf(out, "No rule matches %s events.\n", event.Type)
+		return err
+	}
+	for i, msg := range msgs {
+		if i > 0 {
+			fmt.Fprintln(out, strings.Repeat("-", 72))
+		}
+		if _, err := fmt.Fprintf(out, "To: %s\nSubject: %s\n\n%s\n", strings.Join(msg.To, ", "), msg.Subject, msg.Body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
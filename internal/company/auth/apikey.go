@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/models"
+)
+
+// APIKeyHeader is the HTTP header and gRPC metadata key carrying an API key.
+const APIKeyHeader = "x-api-key"
+
+// Scopes granted to API keys and service account tokens.
+const (
+	ScopeRead  = "companies.read"
+	ScopeWrite = "companies.write"
+	ScopeAdmin = "companies.admin"
+)
+
+// Scopes lists every scope.
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+// methodScopes maps gRPC methods to the scope an API key or service account
+// token needs to call them.
+var methodScopes = map[string]string{
+	"/definition.v1.CompanyService/GetCompany":                 ScopeRead,
+	"/definition.v1.CompanyService/ListCompanies":              ScopeRead,
+	"/definition.v1.CompanyService/ListMyCompanies":            ScopeRead,
+	"/definition.v1.CompanyService/CountCompanies":             ScopeRead,
+	"/definition.v1.CompanyService/GetCompanyByName":           ScopeRead,
+	"/definition.v1.CompanyService/GetCompanyByExternalRef":    ScopeRead,
+	"/definition.v1.CompanyService/GetCompanyHistory":          ScopeRead,
+	"/definition.v1.CompanyService/WatchCompanies":             ScopeRead,
+	"/definition.v1.CompanyService/CreateCompany":              ScopeWrite,
+	"/definition.v1.CompanyService/UpdateCompany":              ScopeWrite,
+	"/definition.v1.CompanyService/DeleteCompany":              ScopeWrite,
+	"/definition.v1.CompanyService/PurgeCompany":               ScopeAdmin,
+	"/definition.v1.CompanyService/ReplayCompanyEvents":        ScopeAdmin,
+	"/definition.v1.CompanyService/ApplyCompanies":             ScopeAdmin,
+	"/definition.v1.CompanyService/SuspendCompany":             ScopeAdmin,
+	"/definition.v1.CompanyService/ActivateCompany":            ScopeAdmin,
+	"/definition.v1.CompanyService/EraseCompanyData":           ScopeAdmin,
+	"/definition.v1.CompanyService/GetEmployee":                ScopeRead,
+	"/definition.v1.CompanyService/ListEmployees":              ScopeRead,
+	"/definition.v1.CompanyService/CreateEmployee":             ScopeWrite,
+	"/definition.v1.CompanyService/UpdateEmployee":             ScopeWrite,
+	"/definition.v1.CompanyService/DeleteEmployee":             ScopeWrite,
+	"/definition.v1.CompanyService/ListCompanyNotes":           ScopeRead,
+	"/definition.v1.CompanyService/AddCompanyNote":             ScopeWrite,
+	"/definition.v1.CompanyService/DeleteCompanyNote":          ScopeWrite,
+	"/definition.v1.CompanyService/CreateAlertWebhook":         ScopeAdmin,
+	"/definition.v1.CompanyService/ListAlertWebhooks":          ScopeAdmin,
+	"/definition.v1.CompanyService/DeleteAlertWebhook":         ScopeAdmin,
+	"/definition.v1.CompanyService/PreviewEvent":               ScopeAdmin,
+	"/definition.v1.CompanyService/GetTenantQuota":             ScopeAdmin,
+	"/definition.v1.CompanyService/UpdateTenantQuota":          ScopeAdmin,
+	"/definition.v1.CompanyService/CountCompaniesPerMonth":     ScopeAdmin,
+	"/definition.v1.ReportService/ListReports":                 ScopeAdmin,
+	"/definition.v1.ReportService/RunReport":                   ScopeAdmin,
+	"/definition.v1.CompanyService/MergeCompanies":             ScopeAdmin,
+	"/definition.v1.CompanyService/ListDuplicateSuggestions":   ScopeAdmin,
+	"/definition.v1.CompanyService/ResolveDuplicateSuggestion": ScopeAdmin,
+	"/definition.v1.CompanyService/ListCompanyTypes":           ScopeRead,
+	"/definition.v1.CompanyService/CreateCompanyType":          ScopeAdmin,
+	"/definition.v1.CompanyService/UpdateCompanyType":          ScopeAdmin,
+	"/definition.v1.CompanyService/DeleteCompanyType":          ScopeAdmin,
+	"/definition.v1.CompanyService/ExportCompanies":            ScopeAdmin,
+	"/definition.v1.CompanyService/RotateJWTKeys":              ScopeAdmin,
+	"/definition.v1.CompanyService/ListErrorCodes":             ScopeRead,
+	"/definition.v1.CompanyService/GetServiceInfo":             ScopeRead,
+	"/definition.v2.CompanyService/GetCompany":                 ScopeRead,
+	"/definition.v2.CompanyService/ListCompanies":              ScopeRead,
+	"/definition.v2.CompanyService/ListMyCompanies":            ScopeRead,
+	"/definition.v2.CompanyService/SearchCompanies":            ScopeRead,
+	"/definition.v2.CompanyService/CreateCompany":              ScopeWrite,
+	"/definition.v2.CompanyService/UpdateCompany":              ScopeWrite,
+	"/definition.v2.CompanyService/DeleteCompany":              ScopeWrite,
+	"/definition.v2.CompanyService/SuspendCompany":             ScopeAdmin,
+	"/definition.v2.CompanyService/ActivateCompany":            ScopeAdmin,
+}
+
+var (
+	errInvalidAPIKey = errors.New("invalid API key")
+	errMissingScope  = errors.New("API key lacks required scope")
+	errRateLimited   = errors.New("API key rate limit exceeded")
+)
+
+// APIKeyStore looks up API keys by the hash of their plaintext.
+type APIKeyStore interface {
+	GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error)
+}
+
+// APIKeyAuthenticator validates API keys, their scopes and per-key rate limits.
+type APIKeyAuthenticator struct {
+	store   APIKeyStore
+	limiter *rateLimiter
+}
+
+// NewAPIKeyAuthenticator constructs an APIKeyAuthenticator backed by store.
+func NewAPIKeyAuthenticator(store APIKeyStore) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{
+		store:   store,
+		limiter: newRateLimiter(time.Minute),
+	}
+}
+
+// HashAPIKey returns the hex-encoded SHA-256 hash under which a key is stored.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateAPIKey returns a new random plaintext key and its hash.
+func GenerateAPIKey() (key string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key = "xm_" + hex.EncodeToString(buf)
+	return key, HashAPIKey(key), nil
+}
+
+// Authenticate resolves key and checks it grants scope. An empty scope only
+// checks that the key is valid.
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context, key, scope string) (*models.APIKey, error) {
+	apiKey, err := a.store.GetAPIKeyByHash(ctx, HashAPIKey(key))
+	if err != nil || apiKey == nil || apiKey.RevokedAt != nil {
+		return nil, errInvalidAPIKey
+	}
+	if scope != "" && !slices.Contains(apiKey.Scopes, scope) {
+		return nil, errMissingScope
+	}
+	return apiKey, nil
+}
+
+// Allow consumes one request from the key's per-minute budget.
+func (a *APIKeyAuthenticator) Allow(apiKey *models.APIKey) error {
+	if !a.limiter.allow(apiKey.ID.String(), apiKey.RateLimit) {
+		return errRateLimited
+	}
+	return nil
+}
+
+// rateLimiter is a fixed-window request counter per key.
+type rateLimiter struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		window:  window,
+		now:     time.Now,
+		windows: make(map[string]*rateWindow),
+	}
+}
+
+// allow reports whether another request fits within limit for id in the
+// current window. A non-positive limit is unlimited.
+func (l *rateLimiter) allow(id string, limit int) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	w, ok := l.windows[id]
+	if !ok || now.Sub(w.start) >= l.window {
+		l.windows[id] = &rateWindow{start: now, count: 1}
+		return true
+	}
+	if w.count >= limit {
+		return false
+	}
+	w.count++
+	return true
+}
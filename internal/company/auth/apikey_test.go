@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// memoryKeyStore is an in-memory APIKeyStore keyed by hash.
+type memoryKeyStore map[string]*models.APIKey
+
+func (s memoryKeyStore) GetAPIKeyByHash(_ context.Context, hash string) (*models.APIKey, error) {
+	key, ok := s[hash]
+	if !ok {
+		return nil, assert.AnError
+	}
+	return key, nil
+}
+
+func newTestKeyStore(t *testing.T) (memoryKeyStore, map[string]string) {
+	t.Helper()
+	revokedAt := time.Now()
+	specs := map[string]*models.APIKey{
+		"reader":  {Scopes: []string{ScopeRead}},
+		"writer":  {Scopes: []string{ScopeRead, ScopeWrite}, RateLimit: 2},
+		"revoked": {Scopes: []string{ScopeWrite}, RevokedAt: &revokedAt},
+	}
+	store := memoryKeyStore{}
+	plaintexts := map[string]string{}
+	for name, key := range specs {
+		plaintext, hash, err := GenerateAPIKey()
+		require.NoError(t, err)
+		key.ID = uuid.New()
+		key.Name = name
+		key.KeyHash = hash
+		store[hash] = key
+		plaintexts[name] = plaintext
+	}
+	return store, plaintexts
+}
+
+func TestHashAPIKey(t *testing.T) {
+	key, hash, err := GenerateAPIKey()
+	require.NoError(t, err)
+	assert.Equal(t, hash, HashAPIKey(key))
+	assert.NotEqual(t, key, hash, "plaintext must not be stored")
+
+	other, _, err := GenerateAPIKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestAuthInterceptor_APIKeys(t *testing.T) {
+	store, keys := newTestKeyStore(t)
+	interceptor := NewAuthInterceptor("secret", WithAPIKeys(NewAPIKeyAuthenticator(store)))
+
+	call := func(method, key string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(APIKeyHeader, key))
+		info := &grpc.UnaryServerInfo{FullMethod: method}
+		_, err := interceptor.Unary()(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			id, ok := FromContext(ctx)
+			assert.True(t, ok, "API key identity should be stored in context")
+			assert.Contains(t, id.UserID, apiKeyUserPrefix)
+			return "ok", nil
+		})
+		return err
+	}
+
+	const (
+		get    = "/definition.v1.CompanyService/GetCompany"
+		create = "/definition.v1.CompanyService/CreateCompany"
+		purge  = "/definition.v1.CompanyService/PurgeCompany"
+	)
+
+	tests := []struct {
+		name     string
+		method   string
+		key      string
+		wantCode codes.Code
+	}{
+		{"read scope on read method", get, keys["reader"], codes.OK},
+		{"read scope on write method", create, keys["reader"], codes.PermissionDenied},
+		{"write scope on write method", create, keys["writer"], codes.OK},
+		{"write scope on admin method", purge, keys["writer"], codes.PermissionDenied},
+		{"revoked key", create, keys["revoked"], codes.Unauthenticated},
+		{"unknown key", get, "xm_unknown", codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantCode, status.Code(call(tt.method, tt.key)))
+		})
+	}
+
+	t.Run("rate limit", func(t *testing.T) {
+		// The writer key allows two requests per minute and one was used above.
+		require.NoError(t, call(get, keys["writer"]))
+		assert.Equal(t, codes.ResourceExhausted, status.Code(call(get, keys["writer"])))
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	l := newRateLimiter(time.Minute)
+	l.now = func() time.Time { return now }
+
+	assert.True(t, l.allow("k", 2))
+	assert.True(t, l.allow("k", 2))
+	assert.False(t, l.allow("k", 2), "third request should exceed the limit")
+	assert.True(t, l.allow("other", 2), "limits are per key")
+	assert.True(t, l.allow("k", 0), "zero limit is unlimited")
+
+	now = now.Add(time.Minute)
+	assert.True(t, l.allow("k", 2), "a new window should reset the count")
+}
+
+func TestGatewayMiddleware_APIKeys(t *testing.T) {
+	store, keys := newTestKeyStore(t)
+	handler, _ := newTestGateway(t, WithAPIKeys(NewAPIKeyAuthenticator(store)))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		want   int
+	}{
+		{"read key on get", http.MethodGet, "/v1/companies/1", keys["reader"], http.StatusNotImplemented},
+		{"read key on create", http.MethodPost, "/v1/companies", keys["reader"], http.StatusForbidden},
+		{"write key on create", http.MethodPost, "/v1/companies", keys["writer"], http.StatusNotImplemented},
+		{"write key on purge", http.MethodPost, "/v1/companies/1:purge", keys["writer"], http.StatusForbidden},
+		{"revoked key", http.MethodPatch, "/v1/companies/1", keys["revoked"], http.StatusUnauthorized},
+		{"no credentials", http.MethodDelete, "/v1/companies/1", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// tenantClaim is the JWT claim carrying the caller's tenant.
+	tenantClaim = "tenant_id"
+	// amrClaim is the JWT claim listing the authentication methods of the
+	// caller (RFC 8176), e.g. ["pwd", "otp"].
+	amrClaim = "amr"
+	// scopeClaim is the JWT claim carrying the space separated scopes of a
+	// service account token.
+	scopeClaim = "scope"
+	// apiKeyUserPrefix prefixes the UserID of callers authenticated by API key.
+	apiKeyUserPrefix = "apikey:"
+)
+
+// ServiceAccountPrefix prefixes the subject of service account tokens, e.g.
+// "svc:billing".
+const ServiceAccountPrefix = "svc:"
+
+// Identity describes the authenticated caller of a request.
+type Identity struct {
+	// UserID is the token subject, or "apikey:<id>" for API key callers.
+	UserID string
+	// Roles lists the roles granted by the token.
+	Roles []string
+	// TenantID is the tenant the caller belongs to, if any.
+	TenantID string
+	// AuthMethods lists how the caller authenticated, e.g. "pwd" and "otp".
+	AuthMethods []string
+	// Scopes lists the scopes granted to a service account token.
+	Scopes []string
+}
+
+// FromContext returns the identity of the authenticated caller, if any.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityContextKey).(Identity)
+	return id, ok
+}
+
+// NewContext returns a copy of ctx carrying id.
+func NewContext(ctx context.Context, id Identity) context.Context {
+	if rec, ok := ctx.Value(recorderContextKey).(*identityRecorder); ok {
+		rec.id, rec.ok = id, true
+	}
+	return context.WithValue(ctx, identityContextKey, id)
+}
+
+type identityRecorder struct {
+	id Identity
+	ok bool
+}
+
+// RecordIdentity returns a context under which the identity established by
+// later interceptors can be read back with the returned function, e.g. by an
+// access log wrapping the auth interceptor.
+func RecordIdentity(ctx context.Context) (context.Context, func() (Identity, bool)) {
+	rec := &identityRecorder{}
+	return context.WithValue(ctx, recorderContextKey, rec), func() (Identity, bool) {
+		return rec.id, rec.ok
+	}
+}
+
+// HasRole reports whether the identity was granted role.
+func (i Identity) HasRole(role string) bool {
+	for _, r := range i.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IsServiceAccount reports whether the caller is a service account, whose
+// access is limited to the scopes of its token rather than by roles.
+func (i Identity) IsServiceAccount() bool {
+	return strings.HasPrefix(i.UserID, ServiceAccountPrefix)
+}
+
+// HasAuthMethods reports whether the caller authenticated with every one of
+// methods.
+func (i Identity) HasAuthMethods(methods ...string) bool {
+	for _, m := range methods {
+		if !slices.Contains(i.AuthMethods, m) {
+			return false
+		}
+	}
+	return true
+}
+
+// identityFromClaims builds an Identity from validated JWT claims. The roles
+// and amr claims may be a single string or a list of strings.
+func identityFromClaims(claims jwt.MapClaims) Identity {
+	id := Identity{}
+	id.UserID, _ = claims.GetSubject()
+	id.TenantID, _ = claims[tenantClaim].(string)
+	id.Roles = stringsClaim(claims[rolesClaim])
+	id.AuthMethods = stringsClaim(claims[amrClaim])
+	if scope, ok := claims[scopeClaim].(string); ok {
+		id.Scopes = strings.Fields(scope)
+	}
+	return id
+}
+
+// stringsClaim returns the strings of a claim holding a string or a list.
+func stringsClaim(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var out []string
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentityFromClaims(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   Identity
+	}{
+		{
+			name:   "roles list and tenant",
+			claims: jwt.MapClaims{"sub": "u1", "roles": []interface{}{"admin", "editor"}, "tenant_id": "t1"},
+			want:   Identity{UserID: "u1", Roles: []string{"admin", "editor"}, TenantID: "t1"},
+		},
+		{
+			name:   "single role",
+			claims: jwt.MapClaims{"sub": "u2", "roles": "admin"},
+			want:   Identity{UserID: "u2", Roles: []string{"admin"}},
+		},
+		{
+			name:   "authentication methods",
+			claims: jwt.MapClaims{"sub": "u4", "amr": []interface{}{"pwd", "otp"}},
+			want:   Identity{UserID: "u4", AuthMethods: []string{"pwd", "otp"}},
+		},
+		{
+			name:   "no optional claims",
+			claims: jwt.MapClaims{"sub": "u3"},
+			want:   Identity{UserID: "u3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, identityFromClaims(tt.claims))
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok, "unauthenticated context has no identity")
+
+	want := Identity{UserID: "u1", Roles: []string{AdminRole}}
+	got, ok := FromContext(NewContext(context.Background(), want))
+	assert.True(t, ok)
+	assert.Equal(t, want, got)
+	assert.True(t, got.HasRole(AdminRole))
+	assert.False(t, got.HasRole("editor"))
+}
+
+func TestRecordIdentity(t *testing.T) {
+	ctx, recorded := RecordIdentity(context.Background())
+	_, ok := recorded()
+	assert.False(t, ok)
+
+	NewContext(ctx, Identity{UserID: "u1"})
+	id, ok := recorded()
+	assert.True(t, ok)
+	assert.Equal(t, "u1", id.UserID)
+}
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -83,12 +85,12 @@ func TestAuthInterceptor(t *testing.T) {
 				ctx = metadata.NewIncomingContext(ctx, md)
 			}
 
-			// Mock handler that checks for claims in context
+			// Mock handler that checks for the identity in context
 			handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
 				if tt.fullMethod == "/definition.v1.CompanyService/CreateCompany" {
-					claims, ok := ctx.Value(userContextKey).(jwt.MapClaims)
-					if !ok || claims["sub"] != userID {
-						return nil, status.Error(codes.Unauthenticated, "claims not in context")
+					identity, ok := FromContext(ctx)
+					if !ok || identity.UserID != userID {
+						return nil, status.Error(codes.Unauthenticated, "identity not in context")
 					}
 				}
 				return "response", nil
@@ -170,6 +172,43 @@ func TestExtractTokenFromMetadata(t *testing.T) {
 	}
 }
 
+// FuzzExtractToken feeds arbitrary authorization values through token
+// extraction and validation, as the gRPC interceptor and HTTP middleware do.
+func FuzzExtractToken(f *testing.F) {
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user"}).SignedString([]byte("secret"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add("Bearer " + signed)
+	f.Add("Bearer " + signed[:len(signed)/2])
+	f.Add("Bearer eyJhbGciOiJub25lIn0.e30.")
+	f.Add("Bearer a.b.c")
+	f.Add("Bearer ")
+	f.Add("Basic dXNlcjpwYXNz")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, header string) {
+		token, err := extractTokenFromMetadata(metadata.Pairs("authorization", header))
+		if err == nil {
+			if token == "" || "Bearer "+token != header {
+				t.Errorf("extracted %q from %q", token, header)
+			}
+		} else if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("expected code %v, got %v", codes.Unauthenticated, status.Code(err))
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/v1/companies", nil)
+		r.Header["Authorization"] = []string{header}
+		if token, err := extractTokenFromHeader(r); err == nil && token == "" {
+			t.Errorf("extracted an empty token from %q", header)
+		}
+
+		if claims, err := validateToken(token, "secret"); err == nil && claims == nil {
+			t.Errorf("expected claims for the accepted token %q", token)
+		}
+	})
+}
+
 func TestValidateToken(t *testing.T) {
 	const validSecret = "test-secret"
 	validToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
@@ -248,6 +287,7 @@ func TestNewAuthInterceptor(t *testing.T) {
 		"/definition.v1.CompanyService/CreateCompany",
 		"/definition.v1.CompanyService/UpdateCompany",
 		"/definition.v1.CompanyService/DeleteCompany",
+		"/definition.v1.CompanyService/PurgeCompany",
 	}
 
 	for _, method := range protectedMethods {
@@ -256,3 +296,146 @@ func TestNewAuthInterceptor(t *testing.T) {
 		}
 	}
 }
+
+func TestAuthInterceptor_AdminMethods(t *testing.T) {
+	const secret = "test-secret"
+	tokenWithRoles := func(roles interface{}) string {
+		claims := jwt.MapClaims{
+			"sub": "test-user",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		if roles != nil {
+			claims[rolesClaim] = roles
+		}
+		tokenString, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		return tokenString
+	}
+
+	tests := []struct {
+		name     string
+		roles    interface{}
+		wantCode codes.Code
+	}{
+		{name: "admin role list", roles: []string{"user", AdminRole}, wantCode: codes.OK},
+		{name: "admin role string", roles: AdminRole, wantCode: codes.OK},
+		{name: "non-admin role", roles: []string{"user"}, wantCode: codes.PermissionDenied},
+		{name: "no roles", wantCode: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptor := NewAuthInterceptor(secret)
+			ctx := metadata.NewIncomingContext(context.Background(),
+				metadata.Pairs("authorization", "Bearer "+tokenWithRoles(tt.roles)))
+			info := &grpc.UnaryServerInfo{FullMethod: "/definition.v1.CompanyService/PurgeCompany"}
+
+			_, err := interceptor.Unary()(ctx, nil, info, func(_ context.Context, _ interface{}) (interface{}, error) {
+				return "response", nil
+			})
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("expected code %v, got %v", tt.wantCode, status.Code(err))
+			}
+		})
+	}
+}
+
+func TestAuthInterceptor_ServiceAccountScopes(t *testing.T) {
+	const secret = "test-secret"
+	token := func(sub, scope string) string {
+		tokenString, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub":   sub,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"scope": scope,
+			"roles": AdminRole,
+		}).SignedString([]byte(secret))
+		return tokenString
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		method   string
+		wantCode codes.Code
+	}{
+		{"read scope reads", token("svc:billing", ScopeRead), "/definition.v1.CompanyService/ListMyCompanies", codes.OK},
+		{"read scope writes", token("svc:billing", ScopeRead), "/definition.v1.CompanyService/CreateCompany", codes.PermissionDenied},
+		{"write scope writes", token("svc:billing", ScopeRead+" "+ScopeWrite), "/definition.v1.CompanyService/CreateCompany", codes.OK},
+		{"admin role ignored", token("svc:billing", ScopeWrite), "/definition.v1.CompanyService/PurgeCompany", codes.PermissionDenied},
+		{"admin scope", token("svc:billing", ScopeAdmin), "/definition.v1.CompanyService/PurgeCompany", codes.OK},
+		{"no scopes", token("svc:billing", ""), "/definition.v1.CompanyService/ListMyCompanies", codes.PermissionDenied},
+		{"user scope claim ignored", token("alice", "openid"), "/definition.v1.CompanyService/CreateCompany", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptor := NewAuthInterceptor(secret)
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+tt.token))
+			info := &grpc.UnaryServerInfo{FullMethod: tt.method}
+
+			_, err := interceptor.Unary()(ctx, nil, info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+				return "response", nil
+			})
+			if status.Code(err) != tt.wantCode {
+				t.Errorf("expected code %v, got %v (%v)", tt.wantCode, status.Code(err), err)
+			}
+		})
+	}
+}
+
+// fakeStream is a ServerStream carrying only a context.
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestAuthInterceptor_Stream(t *testing.T) {
+	const secret = "test-secret"
+	token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "test-user",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(secret))
+
+	tests := []struct {
+		name     string
+		md       metadata.MD
+		method   string
+		wantCode codes.Code
+	}{
+		{"valid token", metadata.Pairs("authorization", "Bearer "+token), "/definition.v1.CompanyService/CreateCompany", codes.OK},
+		{"missing token", metadata.MD{}, "/definition.v1.CompanyService/CreateCompany", codes.Unauthenticated},
+		{"admin method", metadata.Pairs("authorization", "Bearer "+token), "/definition.v1.CompanyService/PurgeCompany", codes.PermissionDenied},
+		{"unprotected method", metadata.MD{}, "/definition.v1.CompanyService/GetCompany", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptor := NewAuthInterceptor(secret)
+			stream := &fakeStream{ctx: metadata.NewIncomingContext(context.Background(), tt.md)}
+			info := &grpc.StreamServerInfo{FullMethod: tt.method}
+
+			var identity Identity
+			err := interceptor.Stream()(nil, stream, info, func(_ interface{}, ss grpc.ServerStream) error {
+				identity, _ = FromContext(ss.Context())
+				return nil
+			})
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("expected code %v, got %v (%v)", tt.wantCode, status.Code(err), err)
+			}
+			if err == nil && len(tt.md) > 0 && identity.UserID != "test-user" {
+				t.Errorf("expected identity in stream context, got %+v", identity)
+			}
+		})
+	}
+}
+
+// TestMethodScopes checks every protected method has a scope, so that
+// service accounts can be granted access to it.
+func TestMethodScopes(t *testing.T) {
+	for _, method := range append(defaultProtectedMethods, defaultAdminMethods...) {
+		if _, ok := methodScopes[method]; !ok {
+			t.Errorf("missing scope for %s", method)
+		}
+	}
+}
@@ -1,12 +1,15 @@
-// Package auth provides a gRPC unary interceptor and JWT token validation
-// to secure protected gRPC methods.
+// Package auth provides a gRPC unary interceptor, JWT token validation and
+// API key authentication to secure protected gRPC methods.
 package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
+	"github.com/gartstein/xm/internal/company/models"
 	"github.com/golang-jwt/jwt/v5"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -14,30 +17,161 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-// Interceptor holds the JWT secret and a map of protected methods.
+// Interceptor holds the JWT secret, a map of protected methods and the subset
+// of those that additionally require the admin role.
 type Interceptor struct {
 	jwtSecret        string
 	protectedMethods map[string]bool
+	adminMethods     map[string]bool
+	apiKeys          *APIKeyAuthenticator
+	validate         tokenValidator
+	authorizer       Authorizer
+	mtls             map[string]map[string]bool
+	replay           ReplayCache
+	singleUse        map[string]bool
+}
+
+// Option configures the Interceptor and GatewayMiddleware.
+type Option func(*options)
+
+type options struct {
+	protected  map[string]bool
+	admin      map[string]bool
+	apiKeys    *APIKeyAuthenticator
+	oidc       *OIDCVerifier
+	secret     *RotatingSecret
+	keyring    *Keyring
+	authorizer Authorizer
+	mtls       map[string]map[string]bool
+	replay     ReplayCache
+	singleUse  map[string]bool
+}
+
+// tokenValidator validates a bearer token and returns its claims.
+type tokenValidator func(ctx context.Context, token string) (jwt.MapClaims, error)
+
+// WithAPIKeys accepts API keys in the x-api-key header as an alternative to
+// JWTs.
+func WithAPIKeys(a *APIKeyAuthenticator) Option {
+	return func(o *options) {
+		o.apiKeys = a
+	}
+}
+
+// WithProtectedMethods replaces the default set of full gRPC method names that
+// require authentication. HTTP routes are protected according to the gRPC
+// method they map to.
+func WithProtectedMethods(methods ...string) Option {
+	return func(o *options) {
+		o.protected = methodSet(methods)
+	}
+}
+
+// WithAdminMethods replaces the default set of methods that additionally
+// require the admin role. Admin methods are always protected.
+func WithAdminMethods(methods ...string) Option {
+	return func(o *options) {
+		o.admin = methodSet(methods)
+	}
+}
+
+// WithOIDC validates bearer tokens against an OpenID Connect provider instead
+// of the shared JWT secret.
+func WithOIDC(v *OIDCVerifier) Option {
+	return func(o *options) {
+		o.oidc = v
+	}
+}
+
+// WithAuthorizer adds a policy evaluation step after authentication.
+func WithAuthorizer(a Authorizer) Option {
+	return func(o *options) {
+		o.authorizer = a
+	}
+}
+
+// tokenValidator returns the OIDC verifier when configured and shared-secret
+// validation otherwise, using the rotating secret if one is set. With a
+// keyring, tokens carrying a kid header are validated against its keys.
+func (o options) tokenValidator(secret string) tokenValidator {
+	if o.oidc != nil {
+		return o.oidc.Verify
+	}
+	validate := func(_ context.Context, token string) (jwt.MapClaims, error) {
+		return validateToken(token, secret)
+	}
+	if o.secret != nil {
+		validate = func(_ context.Context, token string) (jwt.MapClaims, error) {
+			var err error
+			for _, s := range o.secret.secrets() {
+				var claims jwt.MapClaims
+				if claims, err = validateToken(token, s); err == nil {
+					return claims, nil
+				}
+			}
+			return nil, err
+		}
+	}
+	if o.keyring != nil {
+		return o.keyring.validator(validate)
+	}
+	return validate
+}
+
+func buildOptions(opts []Option) options {
+	o := options{
+		protected: methodSet(defaultProtectedMethods),
+		admin:     methodSet(defaultAdminMethods),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	for m := range o.admin {
+		o.protected[m] = true
+	}
+	for m := range o.singleUse {
+		o.protected[m] = true
+	}
+	return o
+}
+
+func methodSet(methods []string) map[string]bool {
+	set := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		set[m] = true
+	}
+	return set
 }
 
 type contextKey string
 
 const (
-	userContextKey contextKey = "user"
+	identityContextKey contextKey = "identity"
+	recorderContextKey contextKey = "identity_recorder"
 )
 
-// NewAuthInterceptor creates a new Interceptor with the given secret and
-// default protected methods.
-func NewAuthInterceptor(jwtSecret string) *Interceptor {
-	protected := map[string]bool{
-		"/definition.v1.CompanyService/CreateCompany": true,
-		"/definition.v1.CompanyService/UpdateCompany": true,
-		"/definition.v1.CompanyService/DeleteCompany": true,
-	}
+const (
+	// rolesClaim is the JWT claim listing the caller's roles.
+	rolesClaim = "roles"
+	// AdminRole grants access to administrative methods.
+	AdminRole = "admin"
+)
 
+// NewAuthInterceptor creates a new Interceptor with the given secret. Unless
+// configured otherwise, all mutating methods are protected and PurgeCompany
+// requires the admin role.
+func NewAuthInterceptor(jwtSecret string, opts ...Option) *Interceptor {
+	o := buildOptions(opts)
 	return &Interceptor{
 		jwtSecret:        jwtSecret,
-		protectedMethods: protected,
+		protectedMethods: o.protected,
+		adminMethods:     o.admin,
+		apiKeys:          o.apiKeys,
+		validate:         o.tokenValidator(jwtSecret),
+		authorizer:       o.authorizer,
+		mtls:             o.mtls,
+		replay:           o.replay,
+		singleUse:        o.singleUse,
 	}
 }
 
@@ -49,27 +183,167 @@ func (i *Interceptor) Unary() grpc.UnaryServerInterceptor {
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
-		if i.protectedMethods[info.FullMethod] {
-			md, ok := metadata.FromIncomingContext(ctx)
-			if !ok {
-				return nil, status.Error(codes.Unauthenticated, "metadata missing")
-			}
+		ctx, err := i.authenticate(ctx, info.FullMethod, req)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
 
-			tokenString, err := extractTokenFromMetadata(md)
-			if err != nil {
-				return nil, err
-			}
+// Stream returns a gRPC stream interceptor authenticating streaming calls
+// like unary ones when they start. The Authorizer sees no request message,
+// so policy owner conditions do not match streams.
+func (i *Interceptor) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := i.authenticate(ss.Context(), info.FullMethod, nil)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
 
-			claims, err := validateToken(tokenString, i.jwtSecret)
-			if err != nil {
-				return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
-			}
+// authenticate checks the caller of method may call it and returns ctx
+// carrying their identity.
+func (i *Interceptor) authenticate(ctx context.Context, method string, req interface{}) (context.Context, error) {
+	// Internal callers with an allowlisted client certificate skip token
+	// authentication but are limited to their configured methods.
+	if id, ok := peerIdentity(ctx); ok && i.mtls[id] != nil {
+		if !i.mtls[id][method] {
+			return nil, status.Errorf(codes.PermissionDenied, "method not allowed for %s", id)
+		}
+		return NewContext(ctx, Identity{UserID: id}), nil
+	}
 
-			ctx = context.WithValue(ctx, userContextKey, claims)
+	if key := apiKeyFromMetadata(ctx); key != "" && i.apiKeys != nil {
+		// API keys are reusable, so they cannot confirm single-use methods.
+		if i.singleUse[method] {
+			return nil, status.Error(codes.PermissionDenied, "single-use operation token required")
 		}
+		apiKey, err := i.authenticateAPIKey(ctx, key, method)
+		if err != nil {
+			return nil, err
+		}
+		identity := Identity{UserID: apiKeyUserPrefix + apiKey.ID.String()}
+		if err := i.authorize(ctx, identity, method, req); err != nil {
+			return nil, err
+		}
+		return NewContext(ctx, identity), nil
+	}
 
-		return handler(ctx, req)
+	if !i.protectedMethods[method] {
+		return ctx, nil
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "metadata missing")
+	}
+
+	tokenString, err := extractTokenFromMetadata(md)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := i.validate(ctx, tokenString)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+	}
+	identity := identityFromClaims(claims)
+	if err := checkAccess(identity, method, i.adminMethods[method]); err != nil {
+		return nil, err
+	}
+	if err := i.authorize(ctx, identity, method, req); err != nil {
+		return nil, err
+	}
+	if err := i.useOnce(ctx, claims, method); err != nil {
+		return nil, err
+	}
+	return NewContext(ctx, identity), nil
+}
+
+// contextStream is a ServerStream whose handlers see ctx.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticateAPIKey validates key for method and applies its rate limit.
+// Rate limits are only enforced here: HTTP requests reach this interceptor
+// through the gateway, so counting them in the middleware too would halve
+// the effective limit.
+func (i *Interceptor) authenticateAPIKey(ctx context.Context, key, method string) (*models.APIKey, error) {
+	apiKey, err := i.apiKeys.Authenticate(ctx, key, methodScopes[method])
+	if err == nil {
+		err = i.apiKeys.Allow(apiKey)
+	}
+	switch {
+	case err == nil:
+		return apiKey, nil
+	case errors.Is(err, errMissingScope):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, errRateLimited):
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	default:
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+}
+
+// checkAccess checks a token caller may call method: service accounts need
+// the method's scope, and cannot call methods without one, and other callers
+// need the admin role for admin methods.
+func checkAccess(identity Identity, method string, admin bool) error {
+	if identity.IsServiceAccount() {
+		scope, ok := methodScopes[method]
+		if !ok {
+			return status.Error(codes.PermissionDenied, "method not available to service accounts")
+		}
+		if !slices.Contains(identity.Scopes, scope) {
+			return status.Errorf(codes.PermissionDenied, "token lacks required scope %s", scope)
+		}
+		return nil
+	}
+	if admin && !identity.HasRole(AdminRole) {
+		return status.Error(codes.PermissionDenied, "admin role required")
 	}
+	return nil
+}
+
+// authorize runs the configured Authorizer, if any, for an authenticated call.
+func (i *Interceptor) authorize(ctx context.Context, identity Identity, method string, req interface{}) error {
+	if i.authorizer == nil {
+		return nil
+	}
+	err := i.authorizer.Authorize(ctx, AuthzRequest{Subject: identity, Method: method, Request: req})
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return status.Errorf(codes.Internal, "authorization failed: %v", err)
+	}
+}
+
+// apiKeyFromMetadata returns the API key sent in incoming metadata, if any.
+func apiKeyFromMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if keys := md.Get(APIKeyHeader); len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
 }
 
 // extractTokenFromMetadata retrieves a Bearer token from gRPC metadata.
@@ -94,11 +368,21 @@ func extractTokenFromMetadata(md metadata.MD) (string, error) {
 
 // validateToken checks the token signature and returns parsed claims if valid.
 func validateToken(tokenString, secret string) (jwt.MapClaims, error) {
+	return validateTokenWith(tokenString, func(string) (string, error) { return secret, nil })
+}
+
+// validateTokenWith checks the token signature against the secret secretFor
+// returns for the token's kid header, and returns parsed claims if valid.
+func validateTokenWith(tokenString string, secretFor func(kid string) (string, error)) (jwt.MapClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-
+		kid, _ := token.Header["kid"].(string)
+		secret, err := secretFor(kid)
+		if err != nil {
+			return nil, err
+		}
 		return []byte(secret), nil
 	})
 
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeySet holds the shared secrets JWTs are signed with, by key ID. Tokens
+// name their key in the kid header and are issued with the Primary key.
+type KeySet struct {
+	Primary string            `json:"primary"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// ParseKeySet parses a KeySet from JSON such as
+//
+//	{"primary": "2025-03", "keys": {"2025-03": "...", "2025-01": "..."}}
+//
+// Every key needs an ID and a secret, and the primary key must be listed.
+func ParseKeySet(data string) (KeySet, error) {
+	var set KeySet
+	if err := json.Unmarshal([]byte(data), &set); err != nil {
+		return KeySet{}, fmt.Errorf("invalid JWT key set: %w", err)
+	}
+	for id, secret := range set.Keys {
+		if id == "" || secret == "" {
+			return KeySet{}, errors.New("invalid JWT key set: empty key ID or secret")
+		}
+	}
+	if _, ok := set.Keys[set.Primary]; !ok {
+		return KeySet{}, fmt.Errorf("invalid JWT key set: primary key %q not listed", set.Primary)
+	}
+	return set, nil
+}
+
+// Keyring holds the KeySet JWTs are validated and issued with, reloading it,
+// e.g. from the secret store, so keys can be rotated without a restart.
+type Keyring struct {
+	load func(ctx context.Context) (KeySet, error)
+
+	mu  sync.RWMutex
+	set KeySet
+}
+
+// NewKeyring returns a Keyring holding the KeySet load returns. Reload calls
+// load again.
+func NewKeyring(ctx context.Context, load func(ctx context.Context) (KeySet, error)) (*Keyring, error) {
+	k := &Keyring{load: load}
+	if err := k.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reload replaces the keys with those load returns. Keys no longer listed
+// stop validating tokens at once. On error the keys are kept.
+func (k *Keyring) Reload(ctx context.Context) error {
+	set, err := k.load(ctx)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.set = set
+	return nil
+}
+
+// Run reloads the keys every interval until ctx is canceled, reporting
+// failures to onError.
+func (k *Keyring) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.Reload(ctx); err != nil && ctx.Err() == nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// KeyIDs returns the ID of the primary key and the IDs of every key, sorted.
+func (k *Keyring) KeyIDs() (primary string, ids []string) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for id := range k.set.Keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return k.set.Primary, ids
+}
+
+// Sign returns a token carrying claims, signed with the primary key and
+// naming it in the kid header.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	k.mu.RLock()
+	primary, secret := k.set.Primary, k.set.Keys[k.set.Primary]
+	k.mu.RUnlock()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = primary
+	return token.SignedString([]byte(secret))
+}
+
+// secret returns the secret of the key with the given ID.
+func (k *Keyring) secret(kid string) (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	secret, ok := k.set.Keys[kid]
+	if !ok {
+		return "", fmt.Errorf("unknown key ID %q", kid)
+	}
+	return secret, nil
+}
+
+// validator returns a tokenValidator checking tokens with a kid header
+// against the keys and passing those without one to fallback.
+func (k *Keyring) validator(fallback tokenValidator) tokenValidator {
+	return func(ctx context.Context, token string) (jwt.MapClaims, error) {
+		var kid string
+		if parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{}); err == nil {
+			kid, _ = parsed.Header["kid"].(string)
+		}
+		if kid == "" {
+			return fallback(ctx, token)
+		}
+		return validateTokenWith(token, k.secret)
+	}
+}
+
+// WithKeyring validates tokens carrying a kid header against the keys of k.
+// Tokens without one are still validated against the shared secret, so
+// tokens issued before the key set was introduced keep working.
+func WithKeyring(k *Keyring) Option {
+	return func(o *options) {
+		o.keyring = k
+	}
+}
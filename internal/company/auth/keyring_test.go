@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeySet(t *testing.T) {
+	set, err := ParseKeySet(`{"primary": "k2", "keys": {"k1": "one", "k2": "two"}}`)
+	require.NoError(t, err)
+	assert.Equal(t, KeySet{Primary: "k2", Keys: map[string]string{"k1": "one", "k2": "two"}}, set)
+
+	for name, data := range map[string]string{
+		"not JSON":         `k1=one`,
+		"no keys":          `{"primary": "k1"}`,
+		"unlisted primary": `{"primary": "k3", "keys": {"k1": "one"}}`,
+		"empty secret":     `{"primary": "k1", "keys": {"k1": ""}}`,
+	} {
+		_, err := ParseKeySet(data)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	set := KeySet{Primary: "k1", Keys: map[string]string{"k1": "one"}}
+	var loadErr error
+	keys, err := NewKeyring(context.Background(), func(context.Context) (KeySet, error) {
+		return set, loadErr
+	})
+	require.NoError(t, err)
+	validate := buildOptions([]Option{WithKeyring(keys)}).tokenValidator("legacy")
+
+	claims := jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()}
+	first, err := keys.Sign(claims)
+	require.NoError(t, err)
+	got, err := validate(context.Background(), first)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", got["sub"])
+
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("legacy"))
+	require.NoError(t, err)
+	_, err = validate(context.Background(), legacy)
+	assert.NoError(t, err, "tokens without a kid use the shared secret")
+
+	// Stage a new key, then make it primary.
+	set = KeySet{Primary: "k2", Keys: map[string]string{"k1": "one", "k2": "two"}}
+	require.NoError(t, keys.Reload(context.Background()))
+	primary, ids := keys.KeyIDs()
+	assert.Equal(t, "k2", primary)
+	assert.Equal(t, []string{"k1", "k2"}, ids)
+	second, err := keys.Sign(claims)
+	require.NoError(t, err)
+	_, err = validate(context.Background(), second)
+	assert.NoError(t, err)
+	_, err = validate(context.Background(), first)
+	assert.NoError(t, err, "tokens of the previous key stay valid while it is listed")
+
+	loadErr = errors.New("vault unavailable")
+	assert.Error(t, keys.Reload(context.Background()))
+	_, err = validate(context.Background(), second)
+	assert.NoError(t, err, "a failed reload keeps the keys")
+
+	loadErr = nil
+	set = KeySet{Primary: "k2", Keys: map[string]string{"k2": "two"}}
+	require.NoError(t, keys.Reload(context.Background()))
+	_, err = validate(context.Background(), first)
+	assert.Error(t, err, "tokens of a removed key are rejected")
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = "k1"
+	token, err := forged.SignedString([]byte("legacy"))
+	require.NoError(t, err)
+	_, err = validate(context.Background(), token)
+	assert.Error(t, err, "a token naming an unknown key does not fall back to the shared secret")
+}
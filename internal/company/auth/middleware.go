@@ -1,40 +1,71 @@
 package auth
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 )
 
-func HTTPMiddleware(next http.Handler, jwtSecret string) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip authentication for non-protected endpoints
-		if !isProtectedRequest(r) {
-			next.ServeHTTP(w, r)
-			return
-		}
+// GatewayMiddleware authenticates gateway requests whose route maps to a
+// protected gRPC method. It runs inside the gateway's ServeMux, so it checks
+// the route the mux matched instead of matching paths itself; requests the
+// mux does not route never reach it. Routes not bound to any gRPC method are
+// treated as protected. The mux must be created with
+// runtime.WithDisablePathLengthFallback: its POST to GET fallback serves a
+// route of another HTTP method than the request's.
+func GatewayMiddleware(jwtSecret string, opts ...Option) runtime.Middleware {
+	o := buildOptions(opts)
+	apiKeys, validate := o.apiKeys, o.tokenValidator(jwtSecret)
+	return func(next runtime.HandlerFunc) runtime.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+			pattern, _ := runtime.HTTPPattern(r.Context())
+			method := methodForRoute(r.Method, pattern.String())
 
-		// Extract token from Authorization header
-		tokenString, err := extractTokenFromHeader(r)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
-			return
-		}
+			// API keys are checked for validity and scope here; rate limits are
+			// applied by the gRPC interceptor the gateway forwards to.
+			if key := r.Header.Get(APIKeyHeader); key != "" && apiKeys != nil {
+				apiKey, err := apiKeys.Authenticate(r.Context(), key, methodScopes[method])
+				if errors.Is(err, errMissingScope) {
+					http.Error(w, err.Error(), http.StatusForbidden)
+					return
+				}
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+				next(w, r.WithContext(NewContext(r.Context(), Identity{UserID: apiKeyUserPrefix + apiKey.ID.String()})), pathParams)
+				return
+			}
 
-		// Validate token
-		claims, err := validateToken(tokenString, jwtSecret)
-		if err != nil {
-			http.Error(w, "invalid token", http.StatusUnauthorized)
-			return
-		}
+			// Skip authentication for non-protected endpoints
+			if method != "" && !o.protected[method] {
+				next(w, r, pathParams)
+				return
+			}
 
-		// Add claims to context
-		ctx := context.WithValue(r.Context(), userContextKey, claims)
-		r = r.WithContext(ctx)
+			// Extract token from Authorization header
+			tokenString, err := extractTokenFromHeader(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
 
-		next.ServeHTTP(w, r)
-	})
+			// Validate token
+			claims, err := validate(r.Context(), tokenString)
+			if err != nil {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+
+			// Add the caller's identity to context
+			r = r.WithContext(NewContext(r.Context(), identityFromClaims(claims)))
+
+			next(w, r, pathParams)
+		}
+	}
 }
 
 // Add these helper functions
@@ -51,19 +82,3 @@ func extractTokenFromHeader(r *http.Request) (string, error) {
 
 	return tokenString, nil
 }
-
-func isProtectedRequest(r *http.Request) bool {
-	// Map your HTTP routes to protected gRPC methods
-	protectedRoutes := map[string]string{
-		"POST":   "/v1/companies",  // CreateCompany
-		"PATCH":  "/v1/companies/", // UpdateCompany
-		"DELETE": "/v1/companies/", // DeleteCompany
-	}
-
-	if _, exists := protectedRoutes[r.Method]; !exists {
-		return false
-	}
-
-	expectedPath := protectedRoutes[r.Method]
-	return strings.HasPrefix(r.URL.Path, expectedPath)
-}
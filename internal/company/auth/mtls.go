@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// ServerTLSConfig returns a TLS config presenting certFile/keyFile. When
+// clientCAFile is set, client certificates signed by it are verified if
+// presented; clients without one still connect and authenticate by token.
+func ServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pool, err := LoadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg, nil
+}
+
+// LoadCertPool reads PEM-encoded CA certificates from path.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no certificates found in CA file")
+	}
+	return pool, nil
+}
+
+// WithMTLSAllowlist lets callers with a verified client certificate bypass
+// token authentication. The map keys are certificate identities (SPIFFE ID,
+// or subject common name when the certificate has none) and the values the
+// full gRPC method names each identity may call.
+func WithMTLSAllowlist(allowlist map[string][]string) Option {
+	return func(o *options) {
+		o.mtls = make(map[string]map[string]bool, len(allowlist))
+		for identity, methods := range allowlist {
+			o.mtls[identity] = methodSet(methods)
+		}
+	}
+}
+
+// peerIdentity returns the identity of the caller's verified client
+// certificate: its SPIFFE ID if present, otherwise its subject common name.
+func peerIdentity(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+
+	leaf := info.State.VerifiedChains[0][0]
+	for _, uri := range leaf.URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String(), true
+		}
+	}
+	if leaf.Subject.CommonName != "" {
+		return leaf.Subject.CommonName, true
+	}
+	return "", false
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// withVerifiedCert returns a context whose peer presented cert.
+func withVerifiedCert(cert *x509.Certificate) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{cert}},
+		}},
+	})
+}
+
+func TestPeerIdentity(t *testing.T) {
+	spiffeID, err := url.Parse("spiffe://xm.internal/billing")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		want   string
+		wantOK bool
+	}{
+		{"no peer", context.Background(), "", false},
+		{"unverified", peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{}}), "", false},
+		{"spiffe ID", withVerifiedCert(&x509.Certificate{URIs: []*url.URL{spiffeID}, Subject: pkix.Name{CommonName: "billing"}}), "spiffe://xm.internal/billing", true},
+		{"common name", withVerifiedCert(&x509.Certificate{Subject: pkix.Name{CommonName: "reporting"}}), "reporting", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := peerIdentity(tt.ctx)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAuthInterceptor_MTLS(t *testing.T) {
+	interceptor := NewAuthInterceptor("secret", WithMTLSAllowlist(map[string][]string{
+		"billing": {"/definition.v1.CompanyService/CreateCompany"},
+	}))
+
+	call := func(ctx context.Context, method string) codes.Code {
+		info := &grpc.UnaryServerInfo{FullMethod: method}
+		_, err := interceptor.Unary()(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			id, ok := FromContext(ctx)
+			assert.True(t, ok)
+			assert.Equal(t, "billing", id.UserID)
+			return "ok", nil
+		})
+		return status.Code(err)
+	}
+
+	billing := withVerifiedCert(&x509.Certificate{Subject: pkix.Name{CommonName: "billing"}})
+	unknown := withVerifiedCert(&x509.Certificate{Subject: pkix.Name{CommonName: "unknown"}})
+
+	assert.Equal(t, codes.OK, call(billing, "/definition.v1.CompanyService/CreateCompany"))
+	assert.Equal(t, codes.PermissionDenied, call(billing, "/definition.v1.CompanyService/DeleteCompany"))
+	assert.Equal(t, codes.Unauthenticated, call(unknown, "/definition.v1.CompanyService/CreateCompany"), "unlisted identities fall back to token auth")
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSignedCert(t, dir)
+
+	cfg, err := ServerTLSConfig(certFile, keyFile, certFile)
+	require.NoError(t, err)
+	assert.Len(t, cfg.Certificates, 1)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, cfg.ClientAuth)
+	assert.NotNil(t, cfg.ClientCAs)
+
+	cfg, err = ServerTLSConfig(certFile, keyFile, "")
+	require.NoError(t, err)
+	assert.Equal(t, tls.NoClientCert, cfg.ClientAuth, "client certs are not requested without a CA")
+
+	_, err = ServerTLSConfig(certFile, keyFile, keyFile)
+	assert.Error(t, err, "a CA file without certificates should be rejected")
+}
+
+func writeSelfSignedCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
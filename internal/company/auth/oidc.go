@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// defaultKeyCacheTTL is how long fetched provider keys are trusted.
+	defaultKeyCacheTTL = time.Hour
+	// minKeyRefreshInterval limits refetches triggered by unknown key IDs, so
+	// forged tokens cannot make us hammer the provider.
+	minKeyRefreshInterval = time.Minute
+)
+
+// OIDCConfig configures token validation against an OpenID Connect provider.
+type OIDCConfig struct {
+	// IssuerURL is the provider's issuer; discovery is fetched from
+	// IssuerURL + "/.well-known/openid-configuration".
+	IssuerURL string
+	// Audience is the value the aud claim must contain.
+	Audience string
+	// KeyCacheTTL is how long provider keys are cached. Defaults to one hour.
+	KeyCacheTTL time.Duration
+	// HTTPClient is used for discovery and key fetches. Defaults to a client
+	// with a 10 second timeout.
+	HTTPClient *http.Client
+}
+
+// OIDCVerifier validates tokens signed by an OpenID Connect provider, caching
+// the provider's signing keys.
+type OIDCVerifier struct {
+	issuer   string
+	audience string
+	jwksURI  string
+	ttl      time.Duration
+	client   *http.Client
+	now      func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// providerMetadata is the subset of the discovery document we use.
+type providerMetadata struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// jsonWebKey is a single entry of a JWKS document.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewOIDCVerifier fetches the provider's discovery document and signing keys.
+func NewOIDCVerifier(ctx context.Context, cfg OIDCConfig) (*OIDCVerifier, error) {
+	if cfg.IssuerURL == "" {
+		return nil, errors.New("OIDC issuer URL is required")
+	}
+	if cfg.KeyCacheTTL <= 0 {
+		cfg.KeyCacheTTL = defaultKeyCacheTTL
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	v := &OIDCVerifier{
+		audience: cfg.Audience,
+		ttl:      cfg.KeyCacheTTL,
+		client:   cfg.HTTPClient,
+		now:      time.Now,
+	}
+
+	var meta providerMetadata
+	discoveryURL := strings.TrimSuffix(cfg.IssuerURL, "/") + "/.well-known/openid-configuration"
+	if err := v.getJSON(ctx, discoveryURL, &meta); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != strings.TrimSuffix(cfg.IssuerURL, "/") {
+		return nil, fmt.Errorf("OIDC discovery issuer %q does not match %q", meta.Issuer, cfg.IssuerURL)
+	}
+	if meta.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document has no jwks_uri")
+	}
+	v.issuer = meta.Issuer
+	v.jwksURI = meta.JWKSURI
+
+	if err := v.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Verify checks the token's signature against the provider keys and validates
+// its iss, aud and expiry claims.
+func (v *OIDCVerifier) Verify(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithIssuer(v.issuer),
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+	}
+	if v.audience != "" {
+		opts = append(opts, jwt.WithAudience(v.audience))
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.key(ctx, kid)
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	return claims, nil
+}
+
+// key returns the signing key for kid, refetching the key set when the cache
+// has expired or the key is unknown (e.g. after provider key rotation).
+func (v *OIDCVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	age := v.now().Sub(v.fetchedAt)
+	key, ok := v.lookup(kid)
+	if (!ok && age >= minKeyRefreshInterval) || age >= v.ttl {
+		if err := v.refreshKeysLocked(ctx); err != nil {
+			return nil, err
+		}
+		key, ok = v.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// lookup finds kid in the cached keys. Tokens without a kid are accepted only
+// when the provider publishes a single key.
+func (v *OIDCVerifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k, true
+		}
+	}
+	k, ok := v.keys[kid]
+	return k, ok
+}
+
+func (v *OIDCVerifier) refreshKeys(ctx context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.refreshKeysLocked(ctx)
+}
+
+// refreshKeysLocked fetches the provider's JWKS. v.mu must be held.
+func (v *OIDCVerifier) refreshKeysLocked(ctx context.Context) error {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.getJSON(ctx, v.jwksURI, &set); err != nil {
+		return fmt.Errorf("failed to fetch OIDC keys: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Skip keys we cannot use rather than rejecting the whole set.
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("OIDC provider published no usable signing keys")
+	}
+
+	v.keys = keys
+	v.fetchedAt = v.now()
+	return nil
+}
+
+func (v *OIDCVerifier) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// publicKey decodes an RSA or EC JWK.
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() {
+			return nil, errors.New("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
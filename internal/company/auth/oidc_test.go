@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeProvider is an OIDC provider serving discovery and a rotatable JWKS.
+type fakeProvider struct {
+	server    *httptest.Server
+	keys      atomic.Value // map[string]*rsa.PrivateKey
+	jwksCount atomic.Int32 // number of JWKS fetches
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+	p := &fakeProvider{}
+	p.rotate(t, "key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(providerMetadata{Issuer: p.server.URL, JWKSURI: p.server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, _ *http.Request) {
+		p.jwksCount.Add(1)
+		var set struct {
+			Keys []jsonWebKey `json:"keys"`
+		}
+		for kid, key := range p.keys.Load().(map[string]*rsa.PrivateKey) {
+			set.Keys = append(set.Keys, jsonWebKey{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		_ = json.NewEncoder(w).Encode(set)
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// rotate replaces the published key set with a single new key.
+func (p *fakeProvider) rotate(t *testing.T, kid string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p.keys.Store(map[string]*rsa.PrivateKey{kid: key})
+}
+
+func (p *fakeProvider) sign(t *testing.T, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(p.keys.Load().(map[string]*rsa.PrivateKey)[kid])
+	require.NoError(t, err)
+	return signed
+}
+
+func (p *fakeProvider) claims(aud string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": "user-1",
+		"iss": p.server.URL,
+		"aud": aud,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestOIDCVerifier(t *testing.T) {
+	p := newFakeProvider(t)
+	ctx := context.Background()
+
+	v, err := NewOIDCVerifier(ctx, OIDCConfig{IssuerURL: p.server.URL, Audience: "company-service"})
+	require.NoError(t, err)
+
+	t.Run("valid token", func(t *testing.T) {
+		claims, err := v.Verify(ctx, p.sign(t, "key-1", p.claims("company-service")))
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", claims["sub"])
+	})
+
+	t.Run("wrong audience", func(t *testing.T) {
+		_, err := v.Verify(ctx, p.sign(t, "key-1", p.claims("other-service")))
+		assert.Error(t, err)
+	})
+
+	t.Run("wrong issuer", func(t *testing.T) {
+		claims := p.claims("company-service")
+		claims["iss"] = "https://evil.example.com"
+		_, err := v.Verify(ctx, p.sign(t, "key-1", claims))
+		assert.Error(t, err)
+	})
+
+	t.Run("shared secret token rejected", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, p.claims("company-service"))
+		signed, err := token.SignedString([]byte("secret"))
+		require.NoError(t, err)
+		_, err = v.Verify(ctx, signed)
+		assert.Error(t, err)
+	})
+
+	t.Run("cached keys", func(t *testing.T) {
+		served := p.jwksCount.Load()
+		_, err := v.Verify(ctx, p.sign(t, "key-1", p.claims("company-service")))
+		require.NoError(t, err)
+		assert.Equal(t, served, p.jwksCount.Load(), "known keys should not be refetched")
+	})
+
+	t.Run("key rotation", func(t *testing.T) {
+		p.rotate(t, "key-2")
+		token := p.sign(t, "key-2", p.claims("company-service"))
+
+		_, err := v.Verify(ctx, token)
+		assert.Error(t, err, "unknown keys are not refetched within the refresh interval")
+
+		v.fetchedAt = v.fetchedAt.Add(-minKeyRefreshInterval)
+		_, err = v.Verify(ctx, token)
+		assert.NoError(t, err, "unknown kid should trigger a key refresh")
+	})
+}
+
+func TestNewOIDCVerifier_IssuerMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(providerMetadata{Issuer: "https://other.example.com", JWKSURI: "https://other.example.com/keys"})
+	}))
+	defer server.Close()
+
+	_, err := NewOIDCVerifier(context.Background(), OIDCConfig{IssuerURL: server.URL})
+	assert.Error(t, err)
+}
+
+func TestAuthInterceptor_OIDC(t *testing.T) {
+	p := newFakeProvider(t)
+	v, err := NewOIDCVerifier(context.Background(), OIDCConfig{IssuerURL: p.server.URL, Audience: "company-service"})
+	require.NoError(t, err)
+	interceptor := NewAuthInterceptor("secret", WithOIDC(v))
+
+	call := func(token string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+		info := &grpc.UnaryServerInfo{FullMethod: "/definition.v1.CompanyService/CreateCompany"}
+		_, err := interceptor.Unary()(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return "ok", nil
+		})
+		return err
+	}
+
+	assert.NoError(t, call(p.sign(t, "key-1", p.claims("company-service"))))
+
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	assert.Equal(t, codes.Unauthenticated, status.Code(call(hmacToken)), "shared secret path is disabled with OIDC")
+}
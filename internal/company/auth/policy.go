@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// resourceOwnerAttr is the resource attribute compared against the caller's
+// UserID by owner conditions.
+const resourceOwnerAttr = "created_by"
+
+// ErrDenied is returned by an Authorizer to reject a request. Any other error
+// is treated as a failure to reach a decision.
+var ErrDenied = errors.New("denied by policy")
+
+// AuthzRequest is the input to an authorization decision.
+type AuthzRequest struct {
+	// Subject is the authenticated caller.
+	Subject Identity
+	// Method is the full gRPC method name.
+	Method string
+	// Request is the gRPC request message.
+	Request interface{}
+}
+
+// Authorizer decides whether an authenticated caller may perform a request,
+// returning ErrDenied if not. It runs after token validation; engines such as
+// OPA or casbin can be plugged in by implementing it.
+type Authorizer interface {
+	Authorize(ctx context.Context, req AuthzRequest) error
+}
+
+// ResourceResolver loads the attributes of the resource a request targets,
+// e.g. {"created_by": "user-1"}. It returns nil when there is no such
+// resource.
+type ResourceResolver func(ctx context.Context, req interface{}) (map[string]string, error)
+
+// Policy is a rule-based Authorizer loaded from a YAML file, so rules can
+// change without recompiling. Methods without rules are allowed; a method
+// with rules is allowed when any of its conditions matches.
+//
+//	rules:
+//	  - methods: [/definition.v1.CompanyService/DeleteCompany]
+//	    allow:
+//	      - roles: [admin]
+//	      - owner: true
+//
+// Adding amr: [otp] to a condition also requires the caller to have logged in
+// with a one-time code.
+type Policy struct {
+	rules     map[string][]PolicyCondition
+	resources ResourceResolver
+}
+
+// PolicyCondition grants access when all of its set fields match; a condition
+// with no fields set matches nothing.
+type PolicyCondition struct {
+	// Roles matches callers holding any of the listed roles.
+	Roles []string `yaml:"roles"`
+	// Owner matches callers whose UserID equals the resource's created_by.
+	Owner bool `yaml:"owner"`
+	// AMR matches callers who authenticated with all of the listed methods,
+	// as reported by the token's amr claim, e.g. [otp].
+	AMR []string `yaml:"amr"`
+}
+
+type policyFile struct {
+	Rules []struct {
+		Methods []string          `yaml:"methods"`
+		Allow   []PolicyCondition `yaml:"allow"`
+	} `yaml:"rules"`
+}
+
+// LoadPolicy reads a policy file. resources resolves resource attributes for
+// owner conditions and may be nil when none are used.
+func LoadPolicy(path string, resources ResourceResolver) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	return ParsePolicy(data, resources)
+}
+
+// ParsePolicy parses a YAML policy document.
+func ParsePolicy(data []byte, resources ResourceResolver) (*Policy, error) {
+	var file policyFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	p := &Policy{rules: make(map[string][]PolicyCondition), resources: resources}
+	for _, rule := range file.Rules {
+		if err := CheckMethods(rule.Methods...); err != nil {
+			return nil, fmt.Errorf("invalid policy rule: %w", err)
+		}
+		for _, c := range rule.Allow {
+			if c.Owner && resources == nil {
+				return nil, errors.New("policy uses owner conditions but no resource resolver is configured")
+			}
+		}
+		for _, m := range rule.Methods {
+			p.rules[m] = append(p.rules[m], rule.Allow...)
+		}
+	}
+	return p, nil
+}
+
+// Authorize implements Authorizer.
+func (p *Policy) Authorize(ctx context.Context, req AuthzRequest) error {
+	conditions, ok := p.rules[req.Method]
+	if !ok {
+		return nil
+	}
+
+	var resource map[string]string
+	resolved := false
+	for _, c := range conditions {
+		if len(c.Roles) > 0 && !hasAnyRole(req.Subject, c.Roles) {
+			continue
+		}
+		if len(c.AMR) > 0 && !req.Subject.HasAuthMethods(c.AMR...) {
+			continue
+		}
+		if c.Owner {
+			if !resolved {
+				var err error
+				if resource, err = p.resources(ctx, req.Request); err != nil {
+					return fmt.Errorf("failed to resolve resource: %w", err)
+				}
+				resolved = true
+			}
+			if req.Subject.UserID == "" || resource[resourceOwnerAttr] != req.Subject.UserID {
+				continue
+			}
+		}
+		if len(c.Roles) > 0 || c.Owner || len(c.AMR) > 0 {
+			return nil
+		}
+	}
+	return ErrDenied
+}
+
+func hasAnyRole(id Identity, roles []string) bool {
+	for _, r := range roles {
+		if id.HasRole(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testPolicy = `
+rules:
+  - methods: [/definition.v1.CompanyService/DeleteCompany]
+    allow:
+      - roles: [admin]
+      - owner: true
+  - methods: [/definition.v1.CompanyService/UpdateCompany]
+    allow:
+      - roles: [editor]
+  - methods: [/definition.v1.CompanyService/PurgeCompany]
+    allow:
+      - roles: [admin]
+        amr: [otp]
+`
+
+// ownedBy resolves every request to a resource created by owner.
+func ownedBy(owner string) ResourceResolver {
+	return func(context.Context, interface{}) (map[string]string, error) {
+		return map[string]string{resourceOwnerAttr: owner}, nil
+	}
+}
+
+func TestPolicy_Authorize(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy), ownedBy("creator"))
+	require.NoError(t, err)
+
+	const (
+		del    = "/definition.v1.CompanyService/DeleteCompany"
+		update = "/definition.v1.CompanyService/UpdateCompany"
+		create = "/definition.v1.CompanyService/CreateCompany"
+		purge  = "/definition.v1.CompanyService/PurgeCompany"
+	)
+
+	tests := []struct {
+		name    string
+		subject Identity
+		method  string
+		allowed bool
+	}{
+		{"admin deletes", Identity{UserID: "someone", Roles: []string{AdminRole}}, del, true},
+		{"creator deletes", Identity{UserID: "creator"}, del, true},
+		{"other user deletes", Identity{UserID: "someone"}, del, false},
+		{"editor updates", Identity{UserID: "someone", Roles: []string{"editor"}}, update, true},
+		{"owner without role updates", Identity{UserID: "creator"}, update, false},
+		{"method without rules", Identity{UserID: "someone"}, create, true},
+		{"admin purges with otp", Identity{UserID: "someone", Roles: []string{AdminRole}, AuthMethods: []string{"pwd", "otp"}}, purge, true},
+		{"admin purges with password only", Identity{UserID: "someone", Roles: []string{AdminRole}, AuthMethods: []string{"pwd"}}, purge, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Authorize(context.Background(), AuthzRequest{Subject: tt.subject, Method: tt.method})
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrDenied)
+			}
+		})
+	}
+}
+
+func TestParsePolicy_Invalid(t *testing.T) {
+	_, err := ParsePolicy([]byte(testPolicy), nil)
+	assert.Error(t, err, "owner conditions need a resource resolver")
+
+	_, err = ParsePolicy([]byte("rules:\n  - methods: [/company.v1.CompanyService/DeleteCompany]\n"), nil)
+	assert.Error(t, err, "unknown methods should be rejected")
+}
+
+func TestAuthInterceptor_Authorizer(t *testing.T) {
+	policy, err := ParsePolicy([]byte(testPolicy), ownedBy("creator"))
+	require.NoError(t, err)
+	interceptor := NewAuthInterceptor("secret", WithAuthorizer(policy))
+
+	call := func(sub string) error {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": sub,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte("secret"))
+		require.NoError(t, err)
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+		info := &grpc.UnaryServerInfo{FullMethod: "/definition.v1.CompanyService/DeleteCompany"}
+		_, err = interceptor.Unary()(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return "ok", nil
+		})
+		return err
+	}
+
+	assert.NoError(t, call("creator"))
+	assert.Equal(t, codes.PermissionDenied, status.Code(call("someone")))
+}
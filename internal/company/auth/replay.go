@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// operationClaim is the JWT claim of single-use operation tokens naming the
+// one gRPC method they may call, e.g. for confirming a deletion.
+const operationClaim = "op"
+
+// ReplayCache records the IDs of single-use tokens that have been presented;
+// *db.Repository implements it, sharing the record between instances.
+type ReplayCache interface {
+	// UseToken records jti as used until expires and reports whether it was
+	// unused.
+	UseToken(ctx context.Context, jti string, expires time.Time) (bool, error)
+}
+
+// WithReplayCache accepts single-use operation tokens, tokens carrying an op
+// claim, recording their jti in cache so that they are refused if replayed.
+// Without it, operation tokens are refused.
+func WithReplayCache(cache ReplayCache) Option {
+	return func(o *options) {
+		o.replay = cache
+	}
+}
+
+// WithSingleUseMethods requires a single-use operation token for the given
+// full gRPC method names, which are also protected. API keys are refused on
+// them.
+func WithSingleUseMethods(methods ...string) Option {
+	return func(o *options) {
+		o.singleUse = methodSet(methods)
+	}
+}
+
+// useOnce checks a token presented for method: an operation token must name
+// method and must not have been used before, and single-use methods require
+// one. Other tokens pass.
+func (i *Interceptor) useOnce(ctx context.Context, claims jwt.MapClaims, method string) error {
+	op, ok := claims[operationClaim].(string)
+	if !ok {
+		if i.singleUse[method] {
+			return status.Error(codes.PermissionDenied, "single-use operation token required")
+		}
+		return nil
+	}
+	if op != method {
+		return status.Error(codes.PermissionDenied, "token is for another operation")
+	}
+	if i.replay == nil {
+		return status.Error(codes.Unauthenticated, "single-use tokens are not accepted")
+	}
+	jti, _ := claims["jti"].(string)
+	exp, err := claims.GetExpirationTime()
+	if jti == "" || err != nil || exp == nil {
+		return status.Error(codes.Unauthenticated, "single-use token needs jti and exp claims")
+	}
+	fresh, err := i.replay.UseToken(ctx, jti, exp.Time)
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "failed to check token reuse: %v", err)
+	}
+	if !fresh {
+		return status.Error(codes.Unauthenticated, "token already used")
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// memoryReplayCache is a ReplayCache in a map.
+type memoryReplayCache map[string]bool
+
+func (c memoryReplayCache) UseToken(_ context.Context, jti string, _ time.Time) (bool, error) {
+	if c[jti] {
+		return false, nil
+	}
+	c[jti] = true
+	return true, nil
+}
+
+func TestAuthInterceptor_SingleUseTokens(t *testing.T) {
+	const (
+		secret = "test-secret"
+		del    = "/definition.v1.CompanyService/DeleteCompany"
+		update = "/definition.v1.CompanyService/UpdateCompany"
+	)
+	token := func(claims jwt.MapClaims) string {
+		claims["sub"] = "user-1"
+		claims["exp"] = time.Now().Add(time.Minute).Unix()
+		tokenString, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		return tokenString
+	}
+	call := func(interceptor *Interceptor, token, method string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+		_, err := interceptor.Unary()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, interface{}) (interface{}, error) {
+			return "response", nil
+		})
+		return err
+	}
+	expect := func(t *testing.T, err error, want codes.Code) {
+		t.Helper()
+		if status.Code(err) != want {
+			t.Errorf("expected code %v, got %v (%v)", want, status.Code(err), err)
+		}
+	}
+
+	interceptor := NewAuthInterceptor(secret, WithReplayCache(memoryReplayCache{}), WithSingleUseMethods(del))
+	opToken := token(jwt.MapClaims{"jti": "jti-1", "op": del})
+	expect(t, call(interceptor, opToken, del), codes.OK)
+	expect(t, call(interceptor, opToken, del), codes.Unauthenticated)
+	expect(t, call(interceptor, token(jwt.MapClaims{"jti": "jti-2", "op": del}), update), codes.PermissionDenied)
+	expect(t, call(interceptor, token(jwt.MapClaims{"op": del}), del), codes.Unauthenticated)
+	expect(t, call(interceptor, token(jwt.MapClaims{"jti": "jti-3"}), del), codes.PermissionDenied)
+
+	session := token(jwt.MapClaims{"jti": "jti-4"})
+	expect(t, call(interceptor, session, update), codes.OK)
+	expect(t, call(interceptor, session, update), codes.OK)
+
+	withoutCache := NewAuthInterceptor(secret)
+	expect(t, call(withoutCache, token(jwt.MapClaims{"jti": "jti-5", "op": del}), del), codes.Unauthenticated)
+}
+
+// TestAuthInterceptor_SingleUseAPIKeys verifies API keys, which carry no
+// jti to record, are refused on single-use methods however often they are
+// presented, and still accepted elsewhere.
+func TestAuthInterceptor_SingleUseAPIKeys(t *testing.T) {
+	const (
+		del    = "/definition.v1.CompanyService/DeleteCompany"
+		update = "/definition.v1.CompanyService/UpdateCompany"
+	)
+	store, keys := newTestKeyStore(t)
+	interceptor := NewAuthInterceptor("secret", WithAPIKeys(NewAPIKeyAuthenticator(store)),
+		WithReplayCache(memoryReplayCache{}), WithSingleUseMethods(del))
+	call := func(method string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(APIKeyHeader, keys["writer"]))
+		_, err := interceptor.Unary()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, interface{}) (interface{}, error) {
+			return "response", nil
+		})
+		return err
+	}
+
+	for range 2 {
+		if code := status.Code(call(del)); code != codes.PermissionDenied {
+			t.Errorf("expected code %v, got %v", codes.PermissionDenied, code)
+		}
+	}
+	if err := call(update); err != nil {
+		t.Errorf("expected the key to be accepted on other methods, got %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	pbv2 "github.com/gartstein/xm/api/gen/definition/v2"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Default method sets used when none are configured.
+var (
+	defaultProtectedMethods = []string{
+		"/definition.v1.CompanyService/CreateCompany",
+		"/definition.v1.CompanyService/UpdateCompany",
+		"/definition.v1.CompanyService/DeleteCompany",
+		"/definition.v1.CompanyService/PurgeCompany",
+		"/definition.v1.CompanyService/ReplayCompanyEvents",
+		"/definition.v1.CompanyService/ApplyCompanies",
+		"/definition.v1.CompanyService/SuspendCompany",
+		"/definition.v1.CompanyService/ActivateCompany",
+		"/definition.v1.CompanyService/EraseCompanyData",
+		"/definition.v1.CompanyService/ListMyCompanies",
+		"/definition.v1.CompanyService/GetCompanyHistory",
+		"/definition.v1.CompanyService/WatchCompanies",
+		"/definition.v1.CompanyService/CreateEmployee",
+		"/definition.v1.CompanyService/GetEmployee",
+		"/definition.v1.CompanyService/ListEmployees",
+		"/definition.v1.CompanyService/UpdateEmployee",
+		"/definition.v1.CompanyService/DeleteEmployee",
+		"/definition.v1.CompanyService/AddCompanyNote",
+		"/definition.v1.CompanyService/ListCompanyNotes",
+		"/definition.v1.CompanyService/DeleteCompanyNote",
+		"/definition.v1.CompanyService/CreateAlertWebhook",
+		"/definition.v1.CompanyService/ListAlertWebhooks",
+		"/definition.v1.CompanyService/DeleteAlertWebhook",
+		"/definition.v1.CompanyService/PreviewEvent",
+		"/definition.v1.CompanyService/GetTenantQuota",
+		"/definition.v1.CompanyService/UpdateTenantQuota",
+		"/definition.v1.CompanyService/CountCompaniesPerMonth",
+		"/definition.v1.ReportService/ListReports",
+		"/definition.v1.ReportService/RunReport",
+		"/definition.v1.CompanyService/MergeCompanies",
+		"/definition.v1.CompanyService/ListDuplicateSuggestions",
+		"/definition.v1.CompanyService/ResolveDuplicateSuggestion",
+		"/definition.v1.CompanyService/ListCompanyTypes",
+		"/definition.v1.CompanyService/CreateCompanyType",
+		"/definition.v1.CompanyService/UpdateCompanyType",
+		"/definition.v1.CompanyService/DeleteCompanyType",
+		"/definition.v1.CompanyService/ExportCompanies",
+		"/definition.v1.CompanyService/RotateJWTKeys",
+		"/definition.v2.CompanyService/CreateCompany",
+		"/definition.v2.CompanyService/UpdateCompany",
+		"/definition.v2.CompanyService/DeleteCompany",
+		"/definition.v2.CompanyService/SuspendCompany",
+		"/definition.v2.CompanyService/ActivateCompany",
+		"/definition.v2.CompanyService/ListMyCompanies",
+	}
+	defaultAdminMethods = []string{
+		"/definition.v1.CompanyService/PurgeCompany",
+		"/definition.v1.CompanyService/ReplayCompanyEvents",
+		"/definition.v1.CompanyService/ApplyCompanies",
+		"/definition.v1.CompanyService/SuspendCompany",
+		"/definition.v1.CompanyService/ActivateCompany",
+		"/definition.v1.CompanyService/EraseCompanyData",
+		"/definition.v1.CompanyService/CreateAlertWebhook",
+		"/definition.v1.CompanyService/ListAlertWebhooks",
+		"/definition.v1.CompanyService/DeleteAlertWebhook",
+		"/definition.v1.CompanyService/PreviewEvent",
+		"/definition.v1.CompanyService/GetTenantQuota",
+		"/definition.v1.CompanyService/UpdateTenantQuota",
+		"/definition.v1.CompanyService/CountCompaniesPerMonth",
+		"/definition.v1.ReportService/ListReports",
+		"/definition.v1.ReportService/RunReport",
+		"/definition.v1.CompanyService/MergeCompanies",
+		"/definition.v1.CompanyService/ListDuplicateSuggestions",
+		"/definition.v1.CompanyService/ResolveDuplicateSuggestion",
+		"/definition.v1.CompanyService/CreateCompanyType",
+		"/definition.v1.CompanyService/UpdateCompanyType",
+		"/definition.v1.CompanyService/DeleteCompanyType",
+		"/definition.v1.CompanyService/ExportCompanies",
+		"/definition.v1.CompanyService/RotateJWTKeys",
+		"/definition.v2.CompanyService/SuspendCompany",
+		"/definition.v2.CompanyService/ActivateCompany",
+	}
+)
+
+// gatewayRoutes lists the HTTP bindings of the gRPC methods, derived from the
+// google.api.http annotations in the protos so HTTP protection cannot drift
+// from the gRPC method list.
+var gatewayRoutes = slices.Concat(
+	routesFromService(pb.File_definition_v1_api_proto.Services().ByName("CompanyService")),
+	routesFromService(pb.File_definition_v1_api_proto.Services().ByName("ReportService")),
+	routesFromService(pbv2.File_definition_v2_api_proto.Services().ByName("CompanyService")),
+)
+
+// route is one HTTP binding of a gRPC method.
+type route struct {
+	fullMethod string
+	httpMethod string
+	// pattern is the path template as rendered by the gateway's
+	// runtime.Pattern, e.g. "/v1/companies/{id=*}:purge".
+	pattern string
+}
+
+// CheckMethods returns an error naming any method that is not part of the
+// service, so a misspelt protected method cannot silently leave it open.
+func CheckMethods(methods ...string) error {
+	known := make(map[string]bool, len(gatewayRoutes))
+	for _, r := range gatewayRoutes {
+		known[r.fullMethod] = true
+	}
+	for _, m := range methods {
+		if !known[m] {
+			return fmt.Errorf("unknown method %q", m)
+		}
+	}
+	return nil
+}
+
+// methodForRoute returns the full gRPC method name bound to httpMethod and
+// the path pattern the gateway matched, or "" when there is none.
+func methodForRoute(httpMethod, pattern string) string {
+	for _, rt := range gatewayRoutes {
+		if rt.httpMethod == httpMethod && rt.pattern == pattern {
+			return rt.fullMethod
+		}
+	}
+	return ""
+}
+
+func routesFromService(sd protoreflect.ServiceDescriptor) []route {
+	var routes []route
+	methods := sd.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		m := methods.Get(i)
+		rule, ok := proto.GetExtension(m.Options(), annotations.E_Http).(*annotations.HttpRule)
+		if !ok || rule == nil {
+			continue
+		}
+		fullMethod := fmt.Sprintf("/%s/%s", sd.FullName(), m.Name())
+		for _, binding := range append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...) {
+			if rt, ok := newRoute(fullMethod, binding); ok {
+				routes = append(routes, rt)
+			}
+		}
+	}
+	return routes
+}
+
+func newRoute(fullMethod string, rule *annotations.HttpRule) (route, bool) {
+	var httpMethod, path string
+	switch p := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		httpMethod, path = http.MethodGet, p.Get
+	case *annotations.HttpRule_Post:
+		httpMethod, path = http.MethodPost, p.Post
+	case *annotations.HttpRule_Put:
+		httpMethod, path = http.MethodPut, p.Put
+	case *annotations.HttpRule_Patch:
+		httpMethod, path = http.MethodPatch, p.Patch
+	case *annotations.HttpRule_Delete:
+		httpMethod, path = http.MethodDelete, p.Delete
+	case *annotations.HttpRule_Custom:
+		httpMethod, path = p.Custom.GetKind(), p.Custom.GetPath()
+	default:
+		return route{}, false
+	}
+	return route{fullMethod: fullMethod, httpMethod: httpMethod, pattern: patternString(path)}, true
+}
+
+// patternString renders a path template the way runtime.Pattern.String
+// does: single-segment variables such as "{id}" become "{id=*}".
+func patternString(template string) string {
+	path, verb := splitVerb(template)
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, "{") && !strings.Contains(s, "=") {
+			segments[i] = strings.TrimSuffix(s, "}") + "=*}"
+		}
+	}
+	pattern := "/" + strings.Join(segments, "/")
+	if verb != "" {
+		pattern += ":" + verb
+	}
+	return pattern
+}
+
+// splitVerb separates a trailing ":verb" from the last path segment.
+func splitVerb(path string) (string, string) {
+	last := strings.LastIndex(path, "/")
+	if i := strings.LastIndex(path, ":"); i > last && !strings.HasSuffix(path, "}") {
+		return path[:i], path[i+1:]
+	}
+	return path, ""
+}
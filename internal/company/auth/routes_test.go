@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	pbv2 "github.com/gartstein/xm/api/gen/definition/v2"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// notRouted is the routed value of requests the mux did not route.
+const notRouted = "(not routed)"
+
+// newTestGateway returns a gateway mux over services answering
+// Unimplemented, guarded by GatewayMiddleware with opts. routed is set to
+// the gRPC method each request the mux routes maps to, before the guard
+// runs.
+func newTestGateway(t *testing.T, opts ...Option) (handler http.Handler, routed *string) {
+	routed = new(string)
+	record := func(next runtime.HandlerFunc) runtime.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request, pathParams map[string]string) {
+			pattern, _ := runtime.HTTPPattern(r.Context())
+			*routed = methodForRoute(r.Method, pattern.String())
+			next(w, r, pathParams)
+		}
+	}
+	mux := runtime.NewServeMux(
+		runtime.WithDisablePathLengthFallback(),
+		runtime.WithMiddlewares(record, GatewayMiddleware("secret", opts...)),
+	)
+	require.NoError(t, pb.RegisterCompanyServiceHandlerServer(context.Background(), mux, pb.UnimplementedCompanyServiceServer{}))
+	require.NoError(t, pbv2.RegisterCompanyServiceHandlerServer(context.Background(), mux, pbv2.UnimplementedCompanyServiceServer{}))
+	return mux, routed
+}
+
+func TestGatewayRoutes(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{http.MethodPost, "/v1/companies", "/definition.v1.CompanyService/CreateCompany"},
+		{http.MethodGet, "/v1/companies/42", "/definition.v1.CompanyService/GetCompany"},
+		{http.MethodPatch, "/v1/companies/42", "/definition.v1.CompanyService/UpdateCompany"},
+		{http.MethodDelete, "/v1/companies/42", "/definition.v1.CompanyService/DeleteCompany"},
+		{http.MethodPost, "/v1/companies/42:purge", "/definition.v1.CompanyService/PurgeCompany"},
+		{http.MethodPost, "/v1/companies:replayEvents", "/definition.v1.CompanyService/ReplayCompanyEvents"},
+		{http.MethodPost, "/v1/companies:apply", "/definition.v1.CompanyService/ApplyCompanies"},
+		{http.MethodPost, "/v1/companies/42:suspend", "/definition.v1.CompanyService/SuspendCompany"},
+		{http.MethodPost, "/v1/companies/42:activate", "/definition.v1.CompanyService/ActivateCompany"},
+		{http.MethodPost, "/v1/companies/42:erase", "/definition.v1.CompanyService/EraseCompanyData"},
+		{http.MethodPost, "/v1/companies/42", ""},
+		{http.MethodGet, "/v1/companies", "/definition.v1.CompanyService/ListCompanies"},
+		{http.MethodGet, "/v1/companies:byName", "/definition.v1.CompanyService/GetCompanyByName"},
+		{http.MethodGet, "/v1/companies:byExternalRef", "/definition.v1.CompanyService/GetCompanyByExternalRef"},
+		{http.MethodGet, "/v1/companies:mine", "/definition.v1.CompanyService/ListMyCompanies"},
+		{http.MethodGet, "/v1/companies:count", "/definition.v1.CompanyService/CountCompanies"},
+		{http.MethodGet, "/v1/companies:watch", "/definition.v1.CompanyService/WatchCompanies"},
+		{http.MethodGet, "/v1/companies/42/history", "/definition.v1.CompanyService/GetCompanyHistory"},
+		{http.MethodPost, "/v1/companies/42/employees", "/definition.v1.CompanyService/CreateEmployee"},
+		{http.MethodGet, "/v1/companies/42/employees", "/definition.v1.CompanyService/ListEmployees"},
+		{http.MethodGet, "/v1/companies/42/employees/7", "/definition.v1.CompanyService/GetEmployee"},
+		{http.MethodPatch, "/v1/companies/42/employees/7", "/definition.v1.CompanyService/UpdateEmployee"},
+		{http.MethodDelete, "/v1/companies/42/employees/7", "/definition.v1.CompanyService/DeleteEmployee"},
+		{http.MethodPost, "/v1/companies/42/notes", "/definition.v1.CompanyService/AddCompanyNote"},
+		{http.MethodGet, "/v1/companies/42/notes", "/definition.v1.CompanyService/ListCompanyNotes"},
+		{http.MethodDelete, "/v1/companies/42/notes/7", "/definition.v1.CompanyService/DeleteCompanyNote"},
+		{http.MethodPost, "/v1/alertWebhooks", "/definition.v1.CompanyService/CreateAlertWebhook"},
+		{http.MethodGet, "/v1/alertWebhooks", "/definition.v1.CompanyService/ListAlertWebhooks"},
+		{http.MethodDelete, "/v1/alertWebhooks/42", "/definition.v1.CompanyService/DeleteAlertWebhook"},
+		{http.MethodGet, "/v1/tenants/acme/quota", "/definition.v1.CompanyService/GetTenantQuota"},
+		{http.MethodPut, "/v1/tenants/acme/quota", "/definition.v1.CompanyService/UpdateTenantQuota"},
+		{http.MethodPost, "/v1/companies:export", "/definition.v1.CompanyService/ExportCompanies"},
+		{http.MethodPost, "/v1/jwtKeys:rotate", "/definition.v1.CompanyService/RotateJWTKeys"},
+		{http.MethodGet, "/v1/errors", "/definition.v1.CompanyService/ListErrorCodes"},
+		{http.MethodGet, "/v1/serviceInfo", "/definition.v1.CompanyService/GetServiceInfo"},
+		{http.MethodPut, "/v1/companies", ""},
+		{http.MethodDelete, "/v1/companies", ""},
+		{http.MethodGet, "/v1/companies/42/extra", ""},
+		{http.MethodPost, "/v1/companies/42:archive", ""},
+		{http.MethodPost, "/v1//companies/42:purge", ""},
+		{http.MethodPost, "/v1/companies/42%3Apurge", "/definition.v1.CompanyService/PurgeCompany"},
+		{http.MethodDelete, "/v1/companies/42%2F43", ""},
+		{http.MethodDelete, "/v1/companies/42/", ""},
+		{http.MethodPost, "/v2/companies", "/definition.v2.CompanyService/CreateCompany"},
+		{http.MethodGet, "/v2/companies", "/definition.v2.CompanyService/ListCompanies"},
+		{http.MethodGet, "/v2/companies:search", "/definition.v2.CompanyService/SearchCompanies"},
+		{http.MethodGet, "/v2/companies:mine", "/definition.v2.CompanyService/ListMyCompanies"},
+		{http.MethodGet, "/v2/companies/42", "/definition.v2.CompanyService/GetCompany"},
+		{http.MethodPatch, "/v2/companies/42", "/definition.v2.CompanyService/UpdateCompany"},
+		{http.MethodDelete, "/v2/companies/42", "/definition.v2.CompanyService/DeleteCompany"},
+		{http.MethodPost, "/v2/companies/42:suspend", "/definition.v2.CompanyService/SuspendCompany"},
+		{http.MethodPost, "/v2/companies/42:activate", "/definition.v2.CompanyService/ActivateCompany"},
+		{http.MethodPost, "/v2/companies/42:purge", ""},
+	}
+	handler, routed := newTestGateway(t)
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			want := tt.want
+			if want == "" {
+				want = notRouted
+			}
+			*routed = notRouted
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+			assert.Equal(t, want, *routed)
+		})
+	}
+
+	t.Run("method override", func(t *testing.T) {
+		// The mux honours X-HTTP-Method-Override only with the path length
+		// fallback, which is disabled, so this stays a POST.
+		req := httptest.NewRequest(http.MethodPost, "/v1/companies/42", strings.NewReader("a=b"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-HTTP-Method-Override", http.MethodDelete)
+		*routed = notRouted
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Equal(t, notRouted, *routed)
+	})
+}
+
+func TestGatewayMiddleware_UnboundRoute(t *testing.T) {
+	mux := runtime.NewServeMux(runtime.WithMiddlewares(GatewayMiddleware("secret")))
+	require.NoError(t, mux.HandlePath(http.MethodGet, "/v1/internal", func(w http.ResponseWriter, _ *http.Request, _ map[string]string) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/internal", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "routes without a gRPC method should be protected")
+}
+
+func TestPatternString(t *testing.T) {
+	assert.Equal(t, "/v1/companies", patternString("/v1/companies"))
+	assert.Equal(t, "/v1/companies/{id=*}", patternString("/v1/companies/{id}"))
+	assert.Equal(t, "/v1/companies/{id=*}:purge", patternString("/v1/companies/{id}:purge"))
+	assert.Equal(t, "/v1/companies:mine", patternString("/v1/companies:mine"))
+}
+
+func TestCheckMethods(t *testing.T) {
+	assert.NoError(t, CheckMethods(defaultProtectedMethods...))
+	assert.Error(t, CheckMethods("/company.v1.CompanyService/CreateCompany"))
+}
+
+func TestConfiguredProtectedMethods(t *testing.T) {
+	opts := []Option{
+		WithProtectedMethods("/definition.v1.CompanyService/GetCompany"),
+		WithAdminMethods("/definition.v1.CompanyService/DeleteCompany"),
+	}
+
+	t.Run("gRPC", func(t *testing.T) {
+		interceptor := NewAuthInterceptor("secret", opts...)
+		call := func(method string) codes.Code {
+			info := &grpc.UnaryServerInfo{FullMethod: method}
+			_, err := interceptor.Unary()(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return "ok", nil
+			})
+			return status.Code(err)
+		}
+
+		assert.Equal(t, codes.Unauthenticated, call("/definition.v1.CompanyService/GetCompany"))
+		assert.Equal(t, codes.Unauthenticated, call("/definition.v1.CompanyService/DeleteCompany"), "admin methods are always protected")
+		assert.Equal(t, codes.OK, call("/definition.v1.CompanyService/CreateCompany"))
+	})
+
+	t.Run("HTTP", func(t *testing.T) {
+		handler, _ := newTestGateway(t, opts...)
+		serve := func(method, path string) int {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+			return rec.Code
+		}
+
+		assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/v1/companies/42"))
+		assert.Equal(t, http.StatusUnauthorized, serve(http.MethodDelete, "/v1/companies/42"))
+		assert.Equal(t, http.StatusNotImplemented, serve(http.MethodPost, "/v1/companies"), "unprotected routes should reach the service")
+	})
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// RotatingSecret holds the shared JWT secret and can be replaced at runtime,
+// e.g. when the secret store reports a rotation. The previous secret stays
+// valid for a grace period so tokens issued just before the rotation keep
+// working.
+type RotatingSecret struct {
+	grace time.Duration
+	now   func() time.Time
+
+	mu              sync.RWMutex
+	current         string
+	previous        string
+	previousExpires time.Time
+}
+
+// NewRotatingSecret returns a RotatingSecret starting at secret.
+func NewRotatingSecret(secret string, grace time.Duration) *RotatingSecret {
+	return &RotatingSecret{grace: grace, now: time.Now, current: secret}
+}
+
+// Rotate makes secret current, keeping the old one valid for the grace period.
+func (s *RotatingSecret) Rotate(secret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if secret == s.current {
+		return
+	}
+	s.previous = s.current
+	s.previousExpires = s.now().Add(s.grace)
+	s.current = secret
+}
+
+// secrets returns the secrets tokens may currently be signed with, current
+// first.
+func (s *RotatingSecret) secrets() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.previous != "" && s.now().Before(s.previousExpires) {
+		return []string{s.current, s.previous}
+	}
+	return []string{s.current}
+}
+
+// WithRotatingSecret validates tokens against s instead of the fixed secret
+// passed to the constructor.
+func WithRotatingSecret(s *RotatingSecret) Option {
+	return func(o *options) {
+		o.secret = s
+	}
+}
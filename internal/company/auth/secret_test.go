@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingSecret(t *testing.T) {
+	now := time.Now()
+	secret := NewRotatingSecret("old", time.Minute)
+	secret.now = func() time.Time { return now }
+	validate := buildOptions([]Option{WithRotatingSecret(secret)}).tokenValidator("unused")
+
+	sign := func(key string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": "user-1",
+			"exp": now.Add(time.Hour).Unix(),
+		}).SignedString([]byte(key))
+		require.NoError(t, err)
+		return token
+	}
+	oldToken, newToken := sign("old"), sign("new")
+
+	_, err := validate(context.Background(), oldToken)
+	assert.NoError(t, err)
+	_, err = validate(context.Background(), newToken)
+	assert.Error(t, err, "new secret is not valid before rotation")
+
+	secret.Rotate("new")
+	_, err = validate(context.Background(), newToken)
+	assert.NoError(t, err)
+	_, err = validate(context.Background(), oldToken)
+	assert.NoError(t, err, "old secret is valid during the grace period")
+
+	now = now.Add(time.Minute)
+	_, err = validate(context.Background(), oldToken)
+	assert.Error(t, err, "old secret expires after the grace period")
+}
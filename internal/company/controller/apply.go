@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/gartstein/xm/internal/company/db"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/pkg/utils"
+)
+
+// maxDesiredCompanies bounds the desired state ApplyCompanies accepts.
+const maxDesiredCompanies = 1000
+
+// ApplyCompanies converges the companies carrying an external reference to
+// desired, keyed by ExternalRef: companies not found are created, those
+// differing are updated and, with opts.Prune, those carrying a reference
+// not in desired are deleted. Status, type and contact email are only
+// compared when set. Names are not checked for similarity, as the desired
+// state is deliberate. Everything runs in one transaction and the events are
+// published once it committed, so a failing change leaves the companies
+// untouched. The changes are returned in the order made; unchanged
+// companies are left out. With opts.PlanOnly the changes are computed and
+// returned but rolled back. With opts.SuppressEvents, which requires the
+// admin role, the changes are neither published nor enriched.
+func (s *CompanyService) ApplyCompanies(ctx context.Context, desired []models.Company, opts models.ApplyOptions) ([]models.CompanyChange, error) {
+	if len(desired) > maxDesiredCompanies {
+		return nil, e.Invalid("companies", e.CodeDesiredStateTooLarge, fmt.Sprintf("desired state lists %d companies, at most %d are allowed", len(desired), maxDesiredCompanies))
+	}
+	var invalid e.ValidationError
+	wanted := make(map[string]bool, len(desired))
+	for i, company := range desired {
+		field := fmt.Sprintf("companies[%d].external_ref", i)
+		switch {
+		case company.ExternalRef == "":
+			invalid.Add(field, e.CodeExternalRefRequired, fmt.Sprintf("company %q has no external reference", company.Name))
+		case wanted[company.ExternalRef]:
+			invalid.Add(field, e.CodeExternalRefRepeated, fmt.Sprintf("external reference %q listed more than once", company.ExternalRef))
+		}
+		wanted[company.ExternalRef] = true
+	}
+	if err := invalid.Err(); err != nil {
+		return nil, err
+	}
+	svc := s
+	if opts.SuppressEvents {
+		var err error
+		if svc, err = s.suppressing(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if !opts.PlanOnly {
+		if err := s.checkWritable(); err != nil {
+			return nil, err
+		}
+		if err := s.checkQuota(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		changes []models.CompanyChange
+		pending []events.Event
+	)
+	err := s.repo.WithTransaction(ctx, func(tx *db.Repository) error {
+		bound := *svc
+		bound.repo = tx
+		bound.dryRun = opts.PlanOnly
+		bound.deferred = &pending
+		bound.writable = nil
+		bound.quotas = nil
+		bound.enricher = nil
+		if bound.outbox != nil {
+			bound.outbox = tx
+		}
+		var err error
+		if changes, err = bound.converge(ctx, desired, wanted, opts.Prune); err != nil {
+			return err
+		}
+		if opts.PlanOnly {
+			return errRollback
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errRollback) {
+		return nil, err
+	}
+
+	db.AfterCommit(ctx, func() {
+		for _, event := range pending {
+			s.producer.Produce(event)
+		}
+		if s.enricher == nil || opts.PlanOnly || opts.SuppressEvents {
+			return
+		}
+		for _, change := range changes {
+			if change.Action == models.ApplyCreate {
+				s.enricher.Enqueue(*change.Company)
+			}
+		}
+	})
+	return changes, nil
+}
+
+// converge makes the changes ApplyCompanies describes; wanted holds the
+// external references in desired.
+func (s *CompanyService) converge(ctx context.Context, desired []models.Company, wanted map[string]bool, prune bool) ([]models.CompanyChange, error) {
+	var changes []models.CompanyChange
+	for i := range desired {
+		want := desired[i]
+		current, err := s.repo.GetCompanyByExternalRef(ctx, want.ExternalRef)
+		switch {
+		case errors.Is(err, e.ErrNotFound):
+			created, err := s.CreateCompany(ctx, &want, models.CreateOptions{Force: true})
+			if err != nil {
+				return nil, fmt.Errorf("external reference %q: %w", want.ExternalRef, err)
+			}
+			changes = append(changes, models.CompanyChange{Action: models.ApplyCreate, Company: created})
+		case err != nil:
+			return nil, fmt.Errorf("failed to look up external reference %q: %w", want.ExternalRef, err)
+		default:
+			update, ok := convergeUpdate(current, &want)
+			if !ok {
+				continue
+			}
+			updated, err := s.UpdateCompany(ctx, update, models.UpdateOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("external reference %q: %w", want.ExternalRef, err)
+			}
+			changes = append(changes, models.CompanyChange{
+				Action:  models.ApplyUpdate,
+				Company: updated,
+				Fields:  changedFields(current, updated),
+			})
+		}
+	}
+	if !prune {
+		return changes, nil
+	}
+
+	// Stale companies are collected first, as deleting them while paging
+	// would shift the offsets.
+	var stale []models.Company
+	filter := models.CompanyFilter{HasExternalRef: true}
+	for offset := 0; ; offset += archiveBatchSize {
+		batch, err := s.repo.ListCompanies(ctx, filter, offset, archiveBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list companies to prune: %w", err)
+		}
+		for _, company := range batch {
+			if !wanted[company.ExternalRef] {
+				stale = append(stale, company)
+			}
+		}
+		if len(batch) < archiveBatchSize {
+			break
+		}
+	}
+	for i := range stale {
+		if _, err := s.DeleteCompany(ctx, stale[i].ID); err != nil {
+			return nil, fmt.Errorf("external reference %q: %w", stale[i].ExternalRef, err)
+		}
+		changes = append(changes, models.CompanyChange{Action: models.ApplyDelete, Company: &stale[i]})
+	}
+	return changes, nil
+}
+
+// convergeUpdate returns the update turning current into want, and false
+// when they already match.
+func convergeUpdate(current, want *models.Company) (*models.CompanyUpdate, bool) {
+	update := &models.CompanyUpdate{ID: current.ID}
+	changed := false
+	if want.Name != current.Name {
+		update.Name, changed = utils.Ptr(want.Name), true
+	}
+	if want.Description != current.Description {
+		update.Description, changed = utils.Ptr(want.Description), true
+	}
+	if want.Employees != current.Employees {
+		update.Employees, changed = utils.Ptr(want.Employees), true
+	}
+	if want.Registered != current.Registered {
+		update.Registered, changed = utils.Ptr(want.Registered), true
+	}
+	if want.Type != "" && want.Type != current.Type {
+		update.Type, changed = utils.Ptr(want.Type), true
+	}
+	if want.Status != "" && want.Status != current.Status {
+		update.Status, changed = utils.Ptr(want.Status), true
+	}
+	if want.ContactEmail != "" && want.ContactEmail != current.ContactEmail {
+		update.ContactEmail, changed = utils.Ptr(want.ContactEmail), true
+	}
+	return update, changed
+}
+
+// changedFields returns the sorted names of the fields differing between
+// before and after.
+func changedFields(before, after *models.Company) []string {
+	changes := diffCompanies(before, after)
+	fields := make([]string, 0, len(changes))
+	for field := range changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
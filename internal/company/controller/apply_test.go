@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gartstein/xm/internal/company/db"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"go.uber.org/zap/zaptest"
+	"gorm.io/driver/sqlite"
+)
+
+func TestCompanyService_ApplyCompanies(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	mockProducer := &MockProducer{}
+	service := NewCompanyService(repo, mockProducer, zaptest.NewLogger(t))
+	ctx := context.Background()
+
+	for _, company := range []models.Company{
+		{Name: "Acme", Employees: 5, ExternalRef: "ERP-1", Type: models.Corporations},
+		{Name: "Initech", Employees: 8, ExternalRef: "ERP-2", Type: models.Corporations},
+		{Name: "Local", Employees: 3, Type: models.Corporations},
+	} {
+		if _, err := service.CreateCompany(ctx, &company, models.CreateOptions{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	mockProducer.producedEvents = nil
+
+	desired := []models.Company{
+		{Name: "Acme", Employees: 60, ExternalRef: "ERP-1", Type: models.Corporations},
+		{Name: "Globex", Employees: 12, ExternalRef: "ERP-3", Type: models.NonProfit},
+	}
+	plan, err := service.ApplyCompanies(ctx, desired, models.ApplyOptions{PlanOnly: true, Prune: true})
+	if err != nil {
+		t.Fatalf("unexpected plan error: %v", err)
+	}
+	var actions []models.ApplyAction
+	for _, change := range plan {
+		actions = append(actions, change.Action)
+	}
+	if want := []models.ApplyAction{models.ApplyUpdate, models.ApplyCreate, models.ApplyDelete}; !reflect.DeepEqual(actions, want) {
+		t.Fatalf("expected actions %v, got %v", want, actions)
+	}
+	if want := []string{"employee_range", "employees"}; !reflect.DeepEqual(plan[0].Fields, want) {
+		t.Errorf("expected changed fields %v, got %v", want, plan[0].Fields)
+	}
+	if plan[2].Company.ExternalRef != "ERP-2" {
+		t.Errorf("expected ERP-2 to be pruned, got %q", plan[2].Company.ExternalRef)
+	}
+	if _, err := repo.GetCompanyByExternalRef(ctx, "ERP-3"); !errors.Is(err, e.ErrNotFound) {
+		t.Errorf("expected the plan not to persist, got %v", err)
+	}
+	if len(mockProducer.producedEvents) != 0 {
+		t.Errorf("expected no events for a plan, got %d", len(mockProducer.producedEvents))
+	}
+
+	changes, err := service.ApplyCompanies(ctx, desired, models.ApplyOptions{Prune: true})
+	if err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %d", len(changes))
+	}
+	acme, err := repo.GetCompanyByExternalRef(ctx, "ERP-1")
+	if err != nil || acme.Employees != 60 {
+		t.Errorf("expected ERP-1 to be updated, got %+v, %v", acme, err)
+	}
+	if _, err := repo.GetCompanyByExternalRef(ctx, "ERP-2"); !errors.Is(err, e.ErrNotFound) {
+		t.Errorf("expected ERP-2 to be deleted, got %v", err)
+	}
+	if _, err := repo.GetCompanyByName(ctx, "Local"); err != nil {
+		t.Errorf("expected companies without a reference to be kept, got %v", err)
+	}
+	var types []events.EventType
+	for _, event := range mockProducer.producedEvents {
+		types = append(types, event.Type)
+	}
+	if want := []events.EventType{events.CompanyUpdated, events.CompanyCreated, events.CompanyDeleted}; !reflect.DeepEqual(types, want) {
+		t.Errorf("expected events %v, got %v", want, types)
+	}
+
+	again, err := service.ApplyCompanies(ctx, desired, models.ApplyOptions{Prune: true})
+	if err != nil || len(again) != 0 {
+		t.Errorf("expected a converged state to need no changes, got %v, %v", again, err)
+	}
+
+	readded, err := service.ApplyCompanies(ctx, append(desired, models.Company{Name: "Initech", ExternalRef: "ERP-2"}), models.ApplyOptions{Prune: true})
+	if err != nil || len(readded) != 1 || readded[0].Action != models.ApplyCreate {
+		t.Fatalf("expected the pruned ERP-2 to be created again, got %v, %v", readded, err)
+	}
+	if _, err := service.ApplyCompanies(ctx, desired, models.ApplyOptions{Prune: true}); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	mockProducer.producedEvents = nil
+	failing := []models.Company{
+		{Name: "Acme", Employees: 70, ExternalRef: "ERP-1"},
+		{Name: "Globex", ExternalRef: "ERP-4"},
+	}
+	if _, err := service.ApplyCompanies(ctx, failing, models.ApplyOptions{}); !errors.Is(err, e.ErrDuplicateName) {
+		t.Errorf("expected ErrDuplicateName, got %v", err)
+	}
+	acme, _ = repo.GetCompanyByExternalRef(ctx, "ERP-1")
+	if acme.Employees != 60 || len(mockProducer.producedEvents) != 0 {
+		t.Errorf("expected a failed apply to change nothing, got %d employees and %d events", acme.Employees, len(mockProducer.producedEvents))
+	}
+
+	for _, tc := range []struct {
+		desired []models.Company
+		code    e.Code
+	}{
+		{[]models.Company{{Name: "Acme"}}, e.CodeExternalRefRequired},
+		{[]models.Company{{Name: "Acme", ExternalRef: "ERP-1"}, {Name: "Other", ExternalRef: "ERP-1"}}, e.CodeExternalRefRepeated},
+		{make([]models.Company, maxDesiredCompanies+1), e.CodeDesiredStateTooLarge},
+	} {
+		if _, err := service.ApplyCompanies(ctx, tc.desired, models.ApplyOptions{}); e.CodeOf(err) != tc.code {
+			t.Errorf("expected %s, got %v", tc.code, err)
+		}
+	}
+}
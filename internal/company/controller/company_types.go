@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+)
+
+// CompanyTypeStore keeps the company type taxonomy.
+type CompanyTypeStore interface {
+	ListCompanyTypes(ctx context.Context) ([]models.CompanyTypeDefinition, error)
+	GetCompanyType(ctx context.Context, code models.CompanyType) (*models.CompanyTypeDefinition, error)
+	GetCompanyTypeForUpdate(ctx context.Context, code models.CompanyType) (*models.CompanyTypeDefinition, error)
+	CreateCompanyType(ctx context.Context, companyType *models.CompanyTypeDefinition) error
+	UpdateCompanyType(ctx context.Context, code models.CompanyType, name, description, actor string, at time.Time) error
+	DeleteCompanyType(ctx context.Context, code models.CompanyType) error
+	CountCompaniesOfType(ctx context.Context, code models.CompanyType) (int64, error)
+}
+
+// companyTypeCode matches the codes of company types.
+var companyTypeCode = regexp.MustCompile(`^[A-Z][A-Z0-9_]{0,31}$`)
+
+// maxCompanyTypeNameLength limits the names of company types.
+const maxCompanyTypeNameLength = 100
+
+// WithCompanyTypes checks the types of created and updated companies
+// against the taxonomy kept in store, which admins manage. Without it only
+// the built-in types are accepted.
+func WithCompanyTypes(store CompanyTypeStore) ServiceOption {
+	return func(s *CompanyService) {
+		s.companyTypes = store
+	}
+}
+
+// ListCompanyTypes returns the company type taxonomy, ordered by code.
+func (s *CompanyService) ListCompanyTypes(ctx context.Context) ([]models.CompanyTypeDefinition, error) {
+	if s.companyTypes == nil {
+		return slices.Clone(models.BuiltinCompanyTypes), nil
+	}
+	types, err := s.companyTypes.ListCompanyTypes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list company types: %w", err)
+	}
+	return types, nil
+}
+
+// CreateCompanyType adds a type to the taxonomy, recording the caller as
+// the admin who added it.
+func (s *CompanyService) CreateCompanyType(ctx context.Context, companyType *models.CompanyTypeDefinition) (*models.CompanyTypeDefinition, error) {
+	var invalid e.ValidationError
+	if !companyTypeCode.MatchString(string(companyType.Code)) {
+		invalid.Add("code", e.CodeCompanyTypeCodeInvalid, fmt.Sprintf("invalid company type code %.40q", companyType.Code))
+	}
+	validateCompanyType(&invalid, companyType.Name, companyType.Description)
+	if err := invalid.Err(); err != nil {
+		return nil, err
+	}
+	if s.companyTypes == nil {
+		return nil, fmt.Errorf("the company type taxonomy is not enabled")
+	}
+	actor := actorFromContext(ctx)
+	now := s.now()
+	companyType.Builtin = false
+	companyType.CreatedBy, companyType.UpdatedBy = actor, actor
+	companyType.CreatedAt, companyType.UpdatedAt = now, now
+	if err := s.companyTypes.CreateCompanyType(ctx, companyType); err != nil {
+		if errors.Is(err, e.ErrInvalidInput) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create company type: %w", err)
+	}
+	return companyType, nil
+}
+
+// UpdateCompanyType sets the name and description of the type
+// companyType.Code, recording the caller as the admin who changed it. The
+// code of a type never changes, as companies refer to it.
+func (s *CompanyService) UpdateCompanyType(ctx context.Context, companyType *models.CompanyTypeDefinition) (*models.CompanyTypeDefinition, error) {
+	var invalid e.ValidationError
+	validateCompanyType(&invalid, companyType.Name, companyType.Description)
+	if err := invalid.Err(); err != nil {
+		return nil, err
+	}
+	if s.companyTypes == nil {
+		return nil, fmt.Errorf("the company type taxonomy is not enabled")
+	}
+	var updated *models.CompanyTypeDefinition
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		err := s.companyTypes.UpdateCompanyType(ctx, companyType.Code, companyType.Name, companyType.Description, actorFromContext(ctx), s.now())
+		if err != nil {
+			return err
+		}
+		updated, err = s.companyTypes.GetCompanyType(ctx, companyType.Code)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, e.ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update company type: %w", err)
+	}
+	return updated, nil
+}
+
+// DeleteCompanyType removes a type from the taxonomy. Built-in types fail
+// with CodeCompanyTypeBuiltin, and types live companies still have with
+// CodeCompanyTypeInUse.
+func (s *CompanyService) DeleteCompanyType(ctx context.Context, code models.CompanyType) error {
+	if s.companyTypes == nil {
+		return fmt.Errorf("the company type taxonomy is not enabled")
+	}
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		// Locking the type first waits for the transactions writing
+		// companies of that type, so they are counted.
+		companyType, err := s.companyTypes.GetCompanyTypeForUpdate(ctx, code)
+		if err != nil {
+			return err
+		}
+		if companyType.Builtin {
+			return e.Newf(e.CodeCompanyTypeBuiltin, "company type %s is built in", code)
+		}
+		count, err := s.companyTypes.CountCompaniesOfType(ctx, code)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return e.Newf(e.CodeCompanyTypeInUse, "%d companies have type %s", count, code)
+		}
+		return s.companyTypes.DeleteCompanyType(ctx, code)
+	})
+	if err != nil {
+		if errors.Is(err, e.ErrNotFound) || errors.Is(err, e.ErrInvalidInput) {
+			return err
+		}
+		return fmt.Errorf("failed to delete company type: %w", err)
+	}
+	return nil
+}
+
+// validateCompanyType adds the violations of a type's name and description
+// to invalid.
+func validateCompanyType(invalid *e.ValidationError, name, description string) {
+	if name == "" || len(name) > maxCompanyTypeNameLength {
+		invalid.Add("name", e.CodeCompanyTypeNameInvalid, "name empty or longer than 100 characters")
+	}
+	if len(description) > 3000 {
+		invalid.Add("description", e.CodeDescriptionTooLong, "description too long")
+	}
+}
+
+// checkCompanyType returns CodeCompanyTypeUnknown when t, if set, is not in
+// the taxonomy. Run in the transaction writing the company, it keeps the
+// type from being deleted until the write commits.
+func (s *CompanyService) checkCompanyType(ctx context.Context, t models.CompanyType) error {
+	if t == "" {
+		return nil
+	}
+	if s.companyTypes == nil {
+		if !t.Valid() {
+			return e.Invalid("type", e.CodeCompanyTypeUnknown, fmt.Sprintf("unknown company type %.40q", t))
+		}
+		return nil
+	}
+	if _, err := s.companyTypes.GetCompanyType(ctx, t); err != nil {
+		if errors.Is(err, e.ErrNotFound) {
+			return e.Invalid("type", e.CodeCompanyTypeUnknown, fmt.Sprintf("unknown company type %.40q", t))
+		}
+		return fmt.Errorf("failed to check company type: %w", err)
+	}
+	return nil
+}
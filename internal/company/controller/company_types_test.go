@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/gartstein/xm/internal/company/db"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"gorm.io/driver/sqlite"
+)
+
+func TestCompanyService_CompanyTypes(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	require.NoError(t, err)
+	service := NewCompanyService(repo, &MockProducer{}, zaptest.NewLogger(t), WithCompanyTypes(repo))
+	ctx := auth.NewContext(context.Background(), auth.Identity{UserID: "alice", Roles: []string{auth.AdminRole}})
+
+	types, err := service.ListCompanyTypes(ctx)
+	require.NoError(t, err)
+	require.Len(t, types, 4, "the taxonomy should start with the built-in types")
+	for _, companyType := range types {
+		assert.True(t, companyType.Builtin, companyType.Code)
+		assert.True(t, companyType.Code.Valid(), companyType.Code)
+	}
+
+	_, err = service.CreateCompany(ctx, &models.Company{Name: "Smith & Co", Type: "PARTNERSHIP"}, models.CreateOptions{})
+	assert.Equal(t, e.CodeCompanyTypeUnknown, e.CodeOf(err), "types outside the taxonomy should be rejected")
+
+	created, err := service.CreateCompanyType(ctx, &models.CompanyTypeDefinition{Code: "PARTNERSHIP", Name: "Partnership", Builtin: true})
+	require.NoError(t, err)
+	assert.False(t, created.Builtin, "callers should not add built-in types")
+	assert.Equal(t, "alice", created.CreatedBy)
+	_, err = service.CreateCompanyType(ctx, &models.CompanyTypeDefinition{Code: "PARTNERSHIP", Name: "Partnership"})
+	assert.Equal(t, e.CodeCompanyTypeExists, e.CodeOf(err))
+	_, err = service.CreateCompanyType(ctx, &models.CompanyTypeDefinition{Code: "llc", Name: ""})
+	assert.Equal(t, e.CodeCompanyTypeCodeInvalid, e.CodeOf(err))
+	assert.Len(t, err.(*e.ValidationError).Violations, 2)
+
+	company, err := service.CreateCompany(ctx, &models.Company{Name: "Smith & Co", Type: "PARTNERSHIP"}, models.CreateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, models.CompanyType("PARTNERSHIP"), company.Type)
+	_, err = service.UpdateCompany(ctx, &models.CompanyUpdate{ID: company.ID, Type: utils.Ptr(models.CompanyType("TRUST"))}, models.UpdateOptions{})
+	assert.Equal(t, e.CodeCompanyTypeUnknown, e.CodeOf(err))
+
+	untyped, err := service.CreateCompany(ctx, &models.Company{Name: "Untyped"}, models.CreateOptions{})
+	require.NoError(t, err)
+	_, err = service.ApplyEnrichment(ctx, untyped.ID, models.Enrichment{Type: utils.Ptr(models.CompanyType("TRUST"))})
+	assert.Equal(t, e.CodeCompanyTypeUnknown, e.CodeOf(err), "enrichment should be checked against the taxonomy")
+	enriched, err := service.ApplyEnrichment(ctx, untyped.ID, models.Enrichment{Type: utils.Ptr(models.CompanyType("PARTNERSHIP"))})
+	require.NoError(t, err)
+	assert.Equal(t, models.CompanyType("PARTNERSHIP"), enriched.Type)
+	_, err = service.DeleteCompany(ctx, untyped.ID)
+	require.NoError(t, err)
+
+	updated, err := service.UpdateCompanyType(ctx, &models.CompanyTypeDefinition{Code: "PARTNERSHIP", Name: "General partnership", Description: "Two or more owners."})
+	require.NoError(t, err)
+	assert.Equal(t, "General partnership", updated.Name)
+	assert.Equal(t, "alice", updated.UpdatedBy)
+	_, err = service.UpdateCompanyType(ctx, &models.CompanyTypeDefinition{Code: "TRUST", Name: "Trust"})
+	assert.Equal(t, e.CodeCompanyTypeNotFound, e.CodeOf(err))
+
+	err = service.DeleteCompanyType(ctx, "PARTNERSHIP")
+	assert.Equal(t, e.CodeCompanyTypeInUse, e.CodeOf(err))
+	err = service.DeleteCompanyType(ctx, models.Corporations)
+	assert.Equal(t, e.CodeCompanyTypeBuiltin, e.CodeOf(err))
+	_, err = service.UpdateCompany(ctx, &models.CompanyUpdate{ID: company.ID, Type: utils.Ptr(models.Cooperative)}, models.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, service.DeleteCompanyType(ctx, "PARTNERSHIP"))
+	err = service.DeleteCompanyType(ctx, "PARTNERSHIP")
+	assert.Equal(t, e.CodeCompanyTypeNotFound, e.CodeOf(err))
+}
+
+func TestCompanyService_BuiltinCompanyTypes(t *testing.T) {
+	service := NewCompanyService(&MockRepository{}, &MockProducer{}, zaptest.NewLogger(t))
+	ctx := context.Background()
+
+	assert.NoError(t, service.checkCompanyType(ctx, models.SoleProprietorship))
+	assert.NoError(t, service.checkCompanyType(ctx, ""), "companies may have no type")
+	assert.Equal(t, e.CodeCompanyTypeUnknown, e.CodeOf(service.checkCompanyType(ctx, "PARTNERSHIP")), "only the built-in types should be valid without a taxonomy")
+	types, err := service.ListCompanyTypes(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, models.BuiltinCompanyTypes, types)
+}
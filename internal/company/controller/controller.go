@@ -5,28 +5,100 @@ package controller
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"maps"
+	"net/mail"
+	"strconv"
+	"time"
 
+	"github.com/gartstein/xm/internal/company/auth"
 	"github.com/gartstein/xm/internal/company/db"
 	e "github.com/gartstein/xm/internal/company/errors"
 	"github.com/gartstein/xm/internal/company/events"
 	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/company/validation"
+	"github.com/gartstein/xm/internal/pkg/utils"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+// Page sizes used by ListCompanies.
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+// archiveBatchSize is the number of companies ArchiveInactiveCompanies reads
+// at a time.
+const archiveBatchSize = 100
+
+// maxExternalRefLength is the longest external reference accepted.
+const maxExternalRefLength = 255
+
+// maxSimilarNames bounds the candidates reported for a near-duplicate name.
+const maxSimilarNames = 5
+
+// enrichmentActor is recorded as the author of enrichment updates.
+const enrichmentActor = "enrichment"
+
+// EventProducer publishes domain events. Implementations are expected to
+// return quickly from Produce and own any asynchronous delivery themselves;
+// Replay writes synchronously to the given topic.
 type EventProducer interface {
-	Produce(eventType events.EventType, company *models.Company)
+	Produce(event events.Event)
+	Replay(ctx context.Context, topic string, event events.Event) error
+}
+
+// Enricher looks up data about new companies in the background and applies
+// it through CompanyService.ApplyEnrichment. Enqueue must not block.
+type Enricher interface {
+	Enqueue(company models.Company)
+}
+
+// Validator asks external services to approve company changes before they
+// are committed, returning their verdicts or an error rejecting the change.
+// *validation.Validator implements it.
+type Validator interface {
+	Validate(ctx context.Context, req validation.Request) ([]models.ValidationResult, error)
+}
+
+// UnitOfWork groups writes into one transaction. The repository calls made
+// with the context passed to fn commit together when fn returns nil and are
+// rolled back otherwise; events published meanwhile are produced once the
+// unit committed. A unit begun with a context already carrying a
+// transaction, such as the request transaction, joins it. db.Repository
+// implements it.
+type UnitOfWork interface {
+	RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 // Repository defines the storage interface for Company objects.
 type Repository interface {
+	UnitOfWork
 	CreateCompany(ctx context.Context, company *models.Company) error
 	GetCompany(ctx context.Context, id uuid.UUID) (*models.Company, error)
+	GetCompanyForUpdate(ctx context.Context, id uuid.UUID) (*models.Company, error)
+	GetCompanyByName(ctx context.Context, name string) (*models.Company, error)
+	GetCompanyByExternalRef(ctx context.Context, ref string) (*models.Company, error)
+	ListCompanies(ctx context.Context, filter models.CompanyFilter, offset, limit int) ([]models.Company, error)
+	CountCompanies(ctx context.Context, filter models.CompanyFilter) (int64, error)
 	UpdateCompany(ctx context.Context, company *models.CompanyUpdate) error
+	UpdateCompanyReturning(ctx context.Context, update *models.CompanyUpdate) (before, after *models.Company, err error)
 	DeleteCompany(ctx context.Context, id uuid.UUID) error
+	PurgeCompany(ctx context.Context, id uuid.UUID) error
+	PurgeDeletedCompanies(ctx context.Context, before time.Time) (int64, error)
+	EraseCompanyData(ctx context.Context, id uuid.UUID, actor string, at time.Time) (*models.Company, error)
 	CompanyExistsByName(ctx context.Context, name string) (bool, error)
+	FindSimilarCompanies(ctx context.Context, name string, threshold float64, limit int) ([]models.Company, error)
+	RecordCompanyEvent(ctx context.Context, event *models.CompanyEvent) error
+	ListCompanyEvents(ctx context.Context, filter models.CompanyEventFilter, limit int) ([]models.CompanyEvent, error)
+	ForEachCompanyEvent(ctx context.Context, filter models.CompanyEventFilter, fn func(*models.CompanyEvent) error) error
+	GetEmployee(ctx context.Context, company, id uuid.UUID) (*models.Employee, error)
+	ListEmployees(ctx context.Context, company uuid.UUID, offset, limit int) ([]models.Employee, error)
+	CountEmployees(ctx context.Context, company uuid.UUID) (int64, error)
+	ListCompanyNotes(ctx context.Context, company uuid.UUID, offset, limit int) ([]models.CompanyNote, error)
 	WithTransaction(ctx context.Context, fn func(repo *db.Repository) error) error
 	Close() error
 }
@@ -37,43 +109,260 @@ type CompanyService struct {
 	repo     Repository
 	producer EventProducer
 	logger   *zap.Logger
+	newID    func() uuid.UUID
+	// nameSimilarity is the trigram similarity (0-1) at which a new name is
+	// rejected as a near-duplicate; 0 disables the check.
+	nameSimilarity float64
+	// ownershipChecks limits modifying a company to its creator and admins.
+	ownershipChecks bool
+	// idempotentDeletes makes deleting an absent company succeed.
+	idempotentDeletes bool
+	// enricher, when set, is handed every created company.
+	enricher Enricher
+	// validator, when set, approves creates and updates.
+	validator Validator
+	// dryRun suppresses events while serving a validate-only request.
+	dryRun bool
+	// suppressEvents records events as suppressed, so only their audit
+	// entries are published, while serving a request of an admin asking so.
+	suppressEvents bool
+	// deferred, when set, collects the events to produce once the
+	// transaction of ApplyCompanies committed.
+	deferred *[]events.Event
+	// writable, when set, reports whether mutations are allowed.
+	writable func() bool
+	// quotas, when set, limits the mutations of tenants; tenants without a
+	// quota of their own get defaultQuota.
+	quotas       QuotaStore
+	defaultQuota models.TenantQuota
+	now          func() time.Time
+	// watchPollInterval and watchSettleDelay pace WatchCompanyEvents.
+	watchPollInterval time.Duration
+	watchSettleDelay  time.Duration
+	// reports, when set, runs the named queries backing reports.
+	reports ReportStore
+	// duplicates, when set, keeps the suggestions of duplicate companies,
+	// found among names at least duplicateSimilarity similar.
+	duplicates          DuplicateStore
+	duplicateSimilarity float64
+	// companyTypes, when set, holds the taxonomy the types of companies
+	// are checked against; otherwise only the built-in types are valid.
+	companyTypes CompanyTypeStore
+	// outbox, when set, queues events for the OutboxRelay instead of
+	// producing them.
+	outbox OutboxStore
+}
+
+// errRollback aborts the transaction of a successful validate-only request.
+var errRollback = errors.New("validate only: rolled back")
+
+// ServiceOption customizes a CompanyService created by NewCompanyService.
+type ServiceOption func(*CompanyService)
+
+// WithUUIDv7 makes new companies get time-ordered UUIDv7 IDs, which keep
+// inserts clustered at the end of the primary key index, instead of random
+// UUIDv4 ones.
+func WithUUIDv7() ServiceOption {
+	return func(s *CompanyService) {
+		s.newID = func() uuid.UUID { return uuid.Must(uuid.NewV7()) }
+	}
+}
+
+// WithNameSimilarity rejects new companies whose name has a trigram
+// similarity of at least threshold (0-1) with an existing name, unless the
+// caller forces creation. The repository must have name similarity enabled.
+func WithNameSimilarity(threshold float64) ServiceOption {
+	return func(s *CompanyService) {
+		s.nameSimilarity = threshold
+	}
+}
+
+// WithOwnershipChecks lets authenticated callers without the admin role
+// update or delete only the companies they created; others fail with
+// ErrNotOwner. Calls without a caller identity, such as scheduled jobs, are
+// not checked.
+func WithOwnershipChecks() ServiceOption {
+	return func(s *CompanyService) {
+		s.ownershipChecks = true
+	}
+}
+
+// WithIdempotentDeletes makes DeleteCompany succeed, reporting nothing was
+// deleted, for companies that do not exist instead of failing with
+// ErrCompanyNotFound, so retried and concurrent deletes all succeed.
+func WithIdempotentDeletes() ServiceOption {
+	return func(s *CompanyService) {
+		s.idempotentDeletes = true
+	}
+}
+
+// WithEnricher hands every created company to enricher, which fills in the
+// attributes left empty through ApplyEnrichment.
+func WithEnricher(enricher Enricher) ServiceOption {
+	return func(s *CompanyService) {
+		s.enricher = enricher
+	}
+}
+
+// WithValidator runs every create and every update changing a company past
+// validator before it is committed. A rejection fails the call and rolls
+// it back; the verdicts of an accepted change are recorded with its event.
+func WithValidator(validator Validator) ServiceOption {
+	return func(s *CompanyService) {
+		s.validator = validator
+	}
+}
+
+// WithWritesEnabled makes mutations fail with ErrReadOnly while enabled
+// returns false, so a service whose events cannot be published keeps
+// serving reads without losing events. Validate-only requests still run.
+func WithWritesEnabled(enabled func() bool) ServiceOption {
+	return func(s *CompanyService) {
+		s.writable = enabled
+	}
 }
 
 // NewCompanyService constructs a CompanyService with a repository,
 // an event producer, and a logger.
-func NewCompanyService(repo Repository, producer EventProducer, logger *zap.Logger) *CompanyService {
-	return &CompanyService{
-		repo:     repo,
-		producer: producer,
-		logger:   logger.Named("company_service"),
+func NewCompanyService(repo Repository, producer EventProducer, logger *zap.Logger, opts ...ServiceOption) *CompanyService {
+	s := &CompanyService{
+		repo:              repo,
+		producer:          producer,
+		logger:            logger.Named("company_service"),
+		newID:             uuid.New,
+		now:               time.Now,
+		watchPollInterval: defaultWatchPollInterval,
+		watchSettleDelay:  defaultWatchSettleDelay,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // CreateCompany adds a new Company after validating input data,
-// ensures uniqueness by checking the name, and triggers an event.
-func (s *CompanyService) CreateCompany(ctx context.Context, company *models.Company) (*models.Company, error) {
-	if company.Name == "" || len(company.Name) > 15 {
-		return nil, fmt.Errorf("%w: invalid name", e.ErrInvalidInput)
+// ensures uniqueness by checking the name, and triggers an event. Companies
+// start ACTIVE unless created as DRAFT. Unless
+// opts.Force is set, names too similar to existing ones are rejected with a
+// *errors.SimilarNameError listing the candidates. The uniqueness checks,
+// the insert and the event history entry form one unit of work, so a
+// company whose event could not be recorded is not created. With
+// opts.ValidateOnly the company that would be created is returned but
+// nothing is committed. With opts.SuppressEvents, which requires the admin
+// role, the company is neither published nor enriched.
+func (s *CompanyService) CreateCompany(ctx context.Context, company *models.Company, opts models.CreateOptions) (*models.Company, error) {
+	if opts.SuppressEvents {
+		quiet, err := s.suppressing(ctx)
+		if err != nil {
+			return nil, err
+		}
+		opts.SuppressEvents = false
+		return quiet.CreateCompany(ctx, company, opts)
 	}
-	if company.Description != "" && len(company.Description) > 3000 {
-		return nil, fmt.Errorf("%w: description too long", e.ErrInvalidInput)
+	if opts.ValidateOnly {
+		opts.ValidateOnly = false
+		return s.validateOnly(ctx, func(dry *CompanyService) (*models.Company, error) {
+			return dry.CreateCompany(ctx, company, opts)
+		})
+	}
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := s.checkQuota(ctx); err != nil {
+		return nil, err
 	}
 
-	exists, err := s.repo.CompanyExistsByName(ctx, company.Name)
-	if err != nil {
-		return nil, fmt.Errorf("failed to check name existence: %w", err)
+	var invalid e.ValidationError
+	switch {
+	case company.Name == "":
+		invalid.Add("name", e.CodeNameRequired, "name required")
+	case len(company.Name) > 15:
+		invalid.Add("name", e.CodeNameTooLong, "name longer than 15 characters")
 	}
-	if exists {
-		return nil, e.ErrDuplicateName
+	if len(company.Description) > 3000 {
+		invalid.Add("description", e.CodeDescriptionTooLong, "description too long")
+	}
+	if company.Employees < 0 {
+		invalid.Add("employees", e.CodeEmployeesNegative, "employees must not be negative")
+	}
+	if company.ContactEmail != "" && !validEmail(company.ContactEmail) {
+		invalid.Add("contact_email", e.CodeContactEmailInvalid, "invalid contact email")
+	}
+	if len(company.ExternalRef) > maxExternalRefLength {
+		invalid.Add("external_ref", e.CodeExternalRefTooLong, "external reference too long")
+	}
+	// Entries with an empty value are dropped, as they would be on update.
+	company.Metadata = models.Metadata(nil).Merge(company.Metadata)
+	validateMetadata(&invalid, company.Metadata)
+	if len(company.Metadata) > maxMetadataEntries {
+		invalid.Add("metadata", e.CodeMetadataTooLarge, "more than 50 metadata entries")
+	}
+	switch company.Status {
+	case "":
+		company.Status = models.StatusActive
+	case models.StatusDraft, models.StatusActive:
+	default:
+		invalid.Add("status", e.CodeInitialStatusInvalid, "new companies must be DRAFT or ACTIVE")
+	}
+	if err := invalid.Err(); err != nil {
+		return nil, err
 	}
 
-	company.ID = uuid.New()
-	if err := s.repo.CreateCompany(ctx, company); err != nil {
-		return nil, fmt.Errorf("failed to create company: %w", err)
+	actor := actorFromContext(ctx)
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		if err := s.checkCompanyQuota(ctx); err != nil {
+			return err
+		}
+		exists, err := s.repo.CompanyExistsByName(ctx, company.Name)
+		if err != nil {
+			return fmt.Errorf("failed to check name existence: %w", err)
+		}
+		if exists {
+			return e.ErrDuplicateName
+		}
+		if s.nameSimilarity > 0 && !opts.Force {
+			similar, err := s.repo.FindSimilarCompanies(ctx, company.Name, s.nameSimilarity, maxSimilarNames)
+			if err != nil {
+				return fmt.Errorf("failed to check similar names: %w", err)
+			}
+			if len(similar) > 0 {
+				return &e.SimilarNameError{Candidates: similar}
+			}
+		}
+		if err := s.checkExternalRef(ctx, company.ExternalRef, uuid.Nil); err != nil {
+			return err
+		}
+		if err := s.checkCompanyType(ctx, company.Type); err != nil {
+			return err
+		}
+
+		company.ID = s.newID()
+		company.CreatedBy = actor
+		company.UpdatedBy = actor
+		if identity, ok := auth.FromContext(ctx); ok {
+			company.TenantID = identity.TenantID
+		}
+		company.EmployeeRange = models.EmployeeRangeFor(company.Employees)
+		validations, err := s.validate(ctx, validation.Request{Operation: validation.OperationCreate, Company: *company})
+		if err != nil {
+			return err
+		}
+		if err := s.repo.CreateCompany(ctx, company); err != nil {
+			return fmt.Errorf("failed to create company: %w", err)
+		}
+		event := events.Event{Type: events.CompanyCreated, Company: company, Actor: actor, Validations: validations}
+		if err := s.recordEvent(ctx, event); err != nil {
+			return err
+		}
+		if s.enricher != nil && !s.dryRun && !s.suppressEvents {
+			created := *company
+			db.AfterCommit(ctx, func() { s.enricher.Enqueue(created) })
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	go func() {
-		s.producer.Produce(events.CompanyCreated, company)
-	}()
 	return company, nil
 }
 
@@ -89,52 +378,702 @@ func (s *CompanyService) GetCompany(ctx context.Context, id uuid.UUID) (*models.
 	return company, nil
 }
 
-// UpdateCompany modifies the specified Company fields,
-// then fetches the updated version for returning and event production.
-func (s *CompanyService) UpdateCompany(ctx context.Context, update *models.CompanyUpdate) (*models.Company, error) {
-	if update.ID == uuid.Nil {
-		return nil, fmt.Errorf("%w: invalid company ID", e.ErrInvalidInput)
+// GetCompanyByName retrieves a Company by name, ignoring case, returning an
+// error if not found.
+func (s *CompanyService) GetCompanyByName(ctx context.Context, name string) (*models.Company, error) {
+	if name == "" {
+		return nil, e.Newf(e.CodeNameRequired, "name required")
+	}
+	company, err := s.repo.GetCompanyByName(ctx, name)
+	if err != nil {
+		if errors.Is(err, e.ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get company: %w", err)
 	}
+	return company, nil
+}
 
-	err := s.repo.UpdateCompany(ctx, update)
+// GetCompanyByExternalRef retrieves the Company carrying the given external
+// reference, returning an error if not found.
+func (s *CompanyService) GetCompanyByExternalRef(ctx context.Context, ref string) (*models.Company, error) {
+	if ref == "" {
+		return nil, e.Newf(e.CodeExternalRefRequired, "external reference required")
+	}
+	company, err := s.repo.GetCompanyByExternalRef(ctx, ref)
 	if err != nil {
 		if errors.Is(err, e.ErrNotFound) {
 			return nil, err
 		}
-		return nil, fmt.Errorf("failed to update company: %w", err)
+		return nil, fmt.Errorf("failed to get company: %w", err)
+	}
+	return company, nil
+}
+
+// ListCompanies returns a page of companies matching filter, ordered by
+// creation time, and the token of the next page, which is empty on the last
+// page. A pageSize of 0 selects defaultPageSize.
+func (s *CompanyService) ListCompanies(ctx context.Context, filter models.CompanyFilter, pageSize int, pageToken string) ([]models.Company, string, error) {
+	if err := validateMetadataFilter(filter.Metadata); err != nil {
+		return nil, "", err
+	}
+	switch {
+	case pageSize < 0:
+		return nil, "", e.Invalid("page_size", e.CodePageSizeInvalid, "negative page size")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+	offset, err := decodePageToken(pageToken)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// One extra row tells whether another page follows.
+	companies, err := s.repo.ListCompanies(ctx, filter, offset, pageSize+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list companies: %w", err)
+	}
+	if len(companies) <= pageSize {
+		return companies, "", nil
+	}
+	return companies[:pageSize], encodePageToken(offset + pageSize), nil
+}
+
+// CountCompanies returns the number of companies matching filter.
+func (s *CompanyService) CountCompanies(ctx context.Context, filter models.CompanyFilter) (int64, error) {
+	if err := validateMetadataFilter(filter.Metadata); err != nil {
+		return 0, err
+	}
+	count, err := s.repo.CountCompanies(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count companies: %w", err)
+	}
+	return count, nil
+}
+
+// ListMyCompanies is ListCompanies restricted to the companies created by
+// the caller.
+func (s *CompanyService) ListMyCompanies(ctx context.Context, filter models.CompanyFilter, pageSize int, pageToken string) ([]models.Company, string, error) {
+	filter.CreatedBy = actorFromContext(ctx)
+	if filter.CreatedBy == "" {
+		return nil, "", e.Newf(e.CodeCallerUnidentified, "caller has no user ID")
+	}
+	return s.ListCompanies(ctx, filter, pageSize, pageToken)
+}
+
+// UpdateCompany modifies the specified Company fields and returns the
+// updated version, read under a row lock in the same transaction, for
+// returning and event production. The event carries the old and new value of
+// every changed field; it is a CompanyStatusChanged event when the status
+// changed, or CompanyArchived when the company was archived. The employee
+// count of a company with employee records is derived from them and can
+// only be "set" to its current value. Status changes the lifecycle does not
+// allow fail with ErrInvalidStatusTransition, and changes by a caller not
+// owning the company with ErrNotOwner when ownership checks are enabled.
+// The checks, the update and the event history entry form one unit of work.
+// With opts.ValidateOnly the company as it would be updated is returned but
+// nothing is committed. With opts.SuppressEvents, which requires the admin
+// role, the change is not published.
+func (s *CompanyService) UpdateCompany(ctx context.Context, update *models.CompanyUpdate, opts models.UpdateOptions) (*models.Company, error) {
+	if opts.SuppressEvents {
+		quiet, err := s.suppressing(ctx)
+		if err != nil {
+			return nil, err
+		}
+		opts.SuppressEvents = false
+		return quiet.UpdateCompany(ctx, update, opts)
+	}
+	if opts.ValidateOnly {
+		return s.validateOnly(ctx, func(dry *CompanyService) (*models.Company, error) {
+			return dry.UpdateCompany(ctx, update, models.UpdateOptions{})
+		})
+	}
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := s.checkQuota(ctx); err != nil {
+		return nil, err
 	}
 
-	updated, err := s.repo.GetCompany(context.Background(), update.ID)
+	var invalid e.ValidationError
+	if update.ID == uuid.Nil {
+		invalid.Add("id", e.CodeCompanyIDInvalid, "invalid company ID")
+	}
+	if update.Employees != nil && *update.Employees < 0 {
+		invalid.Add("employees", e.CodeEmployeesNegative, "employees must not be negative")
+	}
+	if update.ExternalRef != nil && len(*update.ExternalRef) > maxExternalRefLength {
+		invalid.Add("external_ref", e.CodeExternalRefTooLong, "external reference too long")
+	}
+	if update.Status != nil && !update.Status.Valid() {
+		invalid.Add("status", e.CodeStatusUnknown, fmt.Sprintf("unknown status %q", *update.Status))
+	}
+	if update.ContactEmail != nil && *update.ContactEmail != "" && !validEmail(*update.ContactEmail) {
+		invalid.Add("contact_email", e.CodeContactEmailInvalid, "invalid contact email")
+	}
+	validateMetadata(&invalid, update.Metadata)
+	if err := invalid.Err(); err != nil {
+		return nil, err
+	}
+	update.UpdatedBy = actorFromContext(ctx)
+	var updated *models.Company
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		if update.Employees != nil {
+			if err := s.checkEmployeesDerived(ctx, update.ID, *update.Employees); err != nil {
+				return err
+			}
+			update.EmployeeRange = utils.Ptr(models.EmployeeRangeFor(*update.Employees))
+		}
+		if update.ExternalRef != nil {
+			if err := s.checkExternalRef(ctx, *update.ExternalRef, update.ID); err != nil {
+				return err
+			}
+		}
+		if update.Type != nil {
+			if err := s.checkCompanyType(ctx, *update.Type); err != nil {
+				return err
+			}
+		}
+
+		var previous *models.Company
+		var err error
+		if previous, updated, err = s.updateReturning(ctx, update); err != nil {
+			if errors.Is(err, e.ErrNotFound) || errors.Is(err, e.ErrInvalidStatusTransition) || errors.Is(err, e.ErrNotOwner) || errors.Is(err, e.ErrInvalidInput) {
+				return err
+			}
+			return fmt.Errorf("failed to update company: %w", err)
+		}
+		changes := diffCompanies(previous, updated)
+		var validations []models.ValidationResult
+		if len(changes) > 0 {
+			req := validation.Request{Operation: validation.OperationUpdate, Company: *updated, Changes: changes}
+			if validations, err = s.validate(ctx, req); err != nil {
+				return err
+			}
+		}
+		eventType := events.CompanyUpdated
+		switch {
+		case previous.Status == updated.Status:
+		case updated.Status == models.StatusArchived:
+			eventType = events.CompanyArchived
+		default:
+			eventType = events.CompanyStatusChanged
+		}
+		return s.recordEvent(ctx, events.Event{
+			Type:        eventType,
+			Company:     updated,
+			Actor:       update.UpdatedBy,
+			Changes:     changes,
+			Validations: validations,
+		})
+	})
 	if err != nil {
-		s.logger.Error("Failed to get company for event",
-			zap.Error(err),
-			zap.String("company_id", update.ID.String()),
-		)
 		return nil, err
 	}
-	go func() {
-		s.producer.Produce(events.CompanyUpdated, updated)
-	}()
 	return updated, nil
 }
 
-// DeleteCompany removes a Company by ID and fires a deletion event.
-func (s *CompanyService) DeleteCompany(ctx context.Context, id uuid.UUID) error {
-	company, err := s.repo.GetCompany(ctx, id)
+// updateReturning applies update like Repository.UpdateCompanyReturning,
+// then checks the result against the company read under the row lock: the
+// caller must own it when ownership checks are enabled, a status change must
+// be a transition the lifecycle allows and merging metadata must not leave
+// too many entries. It runs in the unit of work of UpdateCompany, which
+// rolls the update back when a check fails.
+func (s *CompanyService) updateReturning(ctx context.Context, update *models.CompanyUpdate) (before, after *models.Company, err error) {
+	if before, after, err = s.repo.UpdateCompanyReturning(ctx, update); err != nil {
+		return nil, nil, err
+	}
+	if owner, ok := s.requiredOwner(ctx); ok && before.CreatedBy != owner {
+		return nil, nil, e.ErrNotOwner
+	}
+	if update.Status != nil && !before.Status.CanTransitionTo(after.Status) {
+		return nil, nil, e.Newf(e.CodeStatusTransitionNotAllowed, "%s to %s", before.Status, after.Status)
+	}
+	if len(after.Metadata) > maxMetadataEntries {
+		return nil, nil, e.Invalid("metadata", e.CodeMetadataTooLarge, "more than 50 metadata entries")
+	}
+	return before, after, nil
+}
+
+// ApplyEnrichment fills in the attributes of a company that are still empty
+// with those found by enrichment, leaving values set by users untouched, and
+// publishes a CompanyEnriched event listing the changes. It returns the
+// company unchanged, without an event, when there is nothing to fill in,
+// and ErrNotFound when the company was deleted meanwhile.
+func (s *CompanyService) ApplyEnrichment(ctx context.Context, id uuid.UUID, result models.Enrichment) (*models.Company, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	if result.Description != nil && len(*result.Description) > 3000 {
+		return nil, e.Newf(e.CodeDescriptionTooLong, "description too long")
+	}
+	if result.Employees != nil && *result.Employees < 0 {
+		return nil, e.Newf(e.CodeEmployeesNegative, "employees must not be negative")
+	}
+
+	var updated *models.Company
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		if result.Type != nil {
+			if err := s.checkCompanyType(ctx, *result.Type); err != nil {
+				return err
+			}
+		}
+		current, err := s.repo.GetCompanyForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		update, ok := enrichmentUpdate(current, result)
+		if !ok {
+			updated = current
+			return nil
+		}
+		var previous *models.Company
+		if previous, updated, err = s.repo.UpdateCompanyReturning(ctx, update); err != nil {
+			return err
+		}
+		changes := diffCompanies(previous, updated)
+		if len(changes) == 0 {
+			return nil
+		}
+		return s.recordEvent(ctx, events.Event{
+			Type:    events.CompanyEnriched,
+			Company: updated,
+			Actor:   enrichmentActor,
+			Changes: changes,
+		})
+	})
+	if err != nil {
+		if errors.Is(err, e.ErrNotFound) || errors.Is(err, e.ErrInvalidInput) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to apply enrichment: %w", err)
+	}
+	return updated, nil
+}
+
+// enrichmentUpdate returns the update filling the empty attributes of
+// company from result, and false when there are none to fill.
+func enrichmentUpdate(company *models.Company, result models.Enrichment) (*models.CompanyUpdate, bool) {
+	update := &models.CompanyUpdate{ID: company.ID, UpdatedBy: enrichmentActor}
+	ok := false
+	if result.Description != nil && *result.Description != "" && company.Description == "" {
+		update.Description = result.Description
+		ok = true
+	}
+	if result.Employees != nil && *result.Employees > 0 && company.Employees == 0 {
+		update.Employees = result.Employees
+		update.EmployeeRange = utils.Ptr(models.EmployeeRangeFor(*result.Employees))
+		ok = true
+	}
+	if result.Type != nil && company.Type == "" {
+		update.Type = result.Type
+		ok = true
+	}
+	// Unregistered and unknown look alike, so registration is only ever
+	// confirmed.
+	if result.Registered != nil && *result.Registered && !company.Registered {
+		update.Registered = result.Registered
+		ok = true
+	}
+	return update, ok
+}
+
+// SuspendCompany moves an ACTIVE company to SUSPENDED.
+func (s *CompanyService) SuspendCompany(ctx context.Context, id uuid.UUID) (*models.Company, error) {
+	return s.UpdateCompany(ctx, &models.CompanyUpdate{ID: id, Status: utils.Ptr(models.StatusSuspended)}, models.UpdateOptions{})
+}
+
+// ActivateCompany moves a DRAFT or SUSPENDED company to ACTIVE.
+func (s *CompanyService) ActivateCompany(ctx context.Context, id uuid.UUID) (*models.Company, error) {
+	return s.UpdateCompany(ctx, &models.CompanyUpdate{ID: id, Status: utils.Ptr(models.StatusActive)}, models.UpdateOptions{})
+}
+
+// DeleteCompany removes a Company by ID, fires a deletion event and reports
+// whether it deleted the company. The company is read, deleted and its
+// event recorded in one unit of work holding its row lock, so of concurrent deletes only one
+// deletes it and fires the event. The others fail with ErrCompanyNotFound,
+// or report false with idempotent deletes enabled. With ownership checks
+// enabled, callers not owning the company get ErrNotOwner.
+func (s *CompanyService) DeleteCompany(ctx context.Context, id uuid.UUID) (bool, error) {
+	if err := s.checkWritable(); err != nil {
+		return false, err
+	}
+	if err := s.checkQuota(ctx); err != nil {
+		return false, err
+	}
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		company, err := s.repo.GetCompanyForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if owner, ok := s.requiredOwner(ctx); ok && company.CreatedBy != owner {
+			return e.ErrNotOwner
+		}
+		if err := s.repo.DeleteCompany(ctx, id); err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, events.Event{Type: events.CompanyDeleted, Company: company, Actor: actorFromContext(ctx)})
+	})
+	switch {
+	case errors.Is(err, e.ErrNotFound) && s.idempotentDeletes:
+		return false, nil
+	case errors.Is(err, e.ErrNotFound) || errors.Is(err, e.ErrNotOwner):
+		return false, err
+	case err != nil:
+		return false, fmt.Errorf("failed to delete company: %w", err)
+	}
+	return true, nil
+}
+
+// EraseCompanyData scrubs the personal data of a company, live or
+// soft-deleted, to honour an erasure request, and publishes a
+// CompanyErased event naming the scrubbed fields without their values, so
+// consumers scrub their copies too. The rest of the company is kept. The
+// event, stored in the history, is the audit entry of the erasure.
+func (s *CompanyService) EraseCompanyData(ctx context.Context, id uuid.UUID) (*models.Company, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	actor := actorFromContext(ctx)
+	var company *models.Company
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if company, err = s.repo.EraseCompanyData(ctx, id, actor, s.now()); err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, events.Event{
+			Type:    events.CompanyErased,
+			Company: company,
+			Actor:   actor,
+			Changes: map[string]models.FieldChange{"contact_email": {}, "description": {}},
+		})
+	})
 	if err != nil {
 		if errors.Is(err, e.ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to erase company data: %w", err)
+	}
+	return company, nil
+}
+
+// PurgeCompany permanently removes a soft-deleted Company. No event is
+// emitted, as consumers were already notified of the deletion.
+func (s *CompanyService) PurgeCompany(ctx context.Context, id uuid.UUID) error {
+	if err := s.repo.PurgeCompany(ctx, id); err != nil {
+		if errors.Is(err, e.ErrNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to purge company: %w", err)
+	}
+	return nil
+}
+
+// ArchiveInactiveCompanies archives every company not yet archived that was
+// last updated before the given time, publishing a CompanyArchived event for
+// each, and returns how many were archived. Companies deleted meanwhile are
+// skipped.
+func (s *CompanyService) ArchiveInactiveCompanies(ctx context.Context, before time.Time) (int, error) {
+	filter := models.CompanyFilter{
+		Statuses:      []models.CompanyStatus{models.StatusDraft, models.StatusActive, models.StatusSuspended},
+		UpdatedBefore: before,
+	}
+	archived := 0
+	for {
+		batch, err := s.repo.ListCompanies(ctx, filter, 0, archiveBatchSize)
+		if err != nil {
+			return archived, fmt.Errorf("failed to list inactive companies: %w", err)
+		}
+		for _, company := range batch {
+			update := &models.CompanyUpdate{ID: company.ID, Status: utils.Ptr(models.StatusArchived)}
+			_, err := s.UpdateCompany(ctx, update, models.UpdateOptions{})
+			if errors.Is(err, e.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return archived, err
+			}
+			archived++
+		}
+		if len(batch) < archiveBatchSize {
+			if archived > 0 {
+				s.logger.Info("Archived inactive companies", zap.Int("count", archived))
+			}
+			return archived, nil
+		}
+	}
+}
+
+// ReplayCompanyEvents re-emits the stored events matching filter, oldest
+// first and with their original EventIDs, to topic so downstream read models
+// can be rebuilt. It returns the number of events written, which is also
+// meaningful when an error interrupts the replay.
+func (s *CompanyService) ReplayCompanyEvents(ctx context.Context, filter models.CompanyEventFilter, topic string) (int, error) {
+	var invalid e.ValidationError
+	if topic == "" {
+		invalid.Add("target_topic", e.CodeReplayTopicRequired, "target topic required")
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Until.After(filter.Since) {
+		invalid.Add("until", e.CodeTimeRangeInvalid, "until must be after since")
+	}
+	validateEventTypes(&invalid, filter.Types)
+	if err := invalid.Err(); err != nil {
+		return 0, err
+	}
+	if err := s.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	err := s.repo.ForEachCompanyEvent(ctx, filter, func(stored *models.CompanyEvent) error {
+		company := stored.Company
+		event := events.Event{
+			EventID: stored.ID,
+			Type:    events.EventType(stored.Type),
+			Company: &company,
+			Actor:   stored.Actor,
+			Changes: stored.Changes,
+		}
+		if err := s.producer.Replay(ctx, topic, event); err != nil {
 			return err
 		}
-		return fmt.Errorf("failed to get company for deletion: %w", err)
+		replayed++
+		return nil
+	})
+	if err != nil {
+		return replayed, fmt.Errorf("failed to replay company events: %w", err)
 	}
+	s.logger.Info("Replayed company events", zap.Int("count", replayed), zap.String("topic", topic))
+	return replayed, nil
+}
 
-	if err := s.repo.DeleteCompany(ctx, id); err != nil {
-		return fmt.Errorf("failed to delete company: %w", err)
+// validateOnly runs fn against a copy of the service bound to a transaction
+// that is always rolled back, with events suppressed, and returns what fn
+// would have returned. Every check, including uniqueness against the
+// database, runs exactly as in a real request.
+func (s *CompanyService) validateOnly(ctx context.Context, fn func(dry *CompanyService) (*models.Company, error)) (*models.Company, error) {
+	var result *models.Company
+	err := s.repo.WithTransaction(ctx, func(tx *db.Repository) error {
+		dry := *s
+		dry.repo = tx
+		dry.dryRun = true
+		dry.writable = nil
+		dry.quotas = nil
+		var err error
+		if result, err = fn(&dry); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		return nil, err
 	}
+	return result, nil
+}
 
-	go func() {
-		s.producer.Produce(events.CompanyDeleted, company)
-	}()
+// recordEvent records event in the company event history and produces it
+// once the unit of work ctx carries committed, or queues it in the outbox
+// with the unit, unless serving a validate-only request. A failure to
+// record is returned, so the unit rolls back together with the mutation
+// the event describes.
+func (s *CompanyService) recordEvent(ctx context.Context, event events.Event) error {
+	if s.dryRun {
+		return nil
+	}
+	event.EventID = uuid.New()
+	event.Suppressed = s.suppressEvents
+	if err := s.storeEvent(ctx, event); err != nil {
+		return fmt.Errorf("failed to record company event: %w", err)
+	}
+	if s.outbox != nil {
+		if err := s.outbox.EnqueueOutboxEvent(ctx, event.Company.ID, event.EventID); err != nil {
+			return fmt.Errorf("failed to queue company event: %w", err)
+		}
+		return nil
+	}
+	s.produceAfterCommit(ctx, event)
+	return nil
+}
+
+// storeEvent adds event to the company event history.
+func (s *CompanyService) storeEvent(ctx context.Context, event events.Event) error {
+	return s.repo.RecordCompanyEvent(ctx, &models.CompanyEvent{
+		ID:          event.EventID,
+		Type:        string(event.Type),
+		CompanyID:   event.Company.ID,
+		Actor:       event.Actor,
+		Company:     *event.Company,
+		Changes:     event.Changes,
+		Validations: event.Validations,
+		Suppressed:  event.Suppressed,
+	})
+}
+
+// suppressing returns a copy of the service recording its events as
+// suppressed. Callers without the admin role fail with ADMIN_REQUIRED;
+// calls without a caller identity, such as scheduled jobs, are not checked.
+func (s *CompanyService) suppressing(ctx context.Context) (*CompanyService, error) {
+	if identity, ok := auth.FromContext(ctx); ok && !identity.HasRole(auth.AdminRole) {
+		return nil, e.Newf(e.CodeAdminRequired, "suppressing events")
+	}
+	quiet := *s
+	quiet.suppressEvents = true
+	return &quiet, nil
+}
+
+// validate runs req past the validator, if any, returning the verdicts to
+// record with the event of the change.
+func (s *CompanyService) validate(ctx context.Context, req validation.Request) ([]models.ValidationResult, error) {
+	if s.validator == nil {
+		return nil, nil
+	}
+	return s.validator.Validate(ctx, req)
+}
+
+// produceAfterCommit hands event to the producer once the transaction ctx
+// carries committed, or right away without one. Inside ApplyCompanies it is
+// collected for ApplyCompanies to produce instead.
+func (s *CompanyService) produceAfterCommit(ctx context.Context, event events.Event) {
+	if s.deferred != nil {
+		*s.deferred = append(*s.deferred, event)
+		return
+	}
+	db.AfterCommit(ctx, func() { s.producer.Produce(event) })
+}
+
+// checkWritable returns ErrReadOnly while mutations are disabled.
+func (s *CompanyService) checkWritable() error {
+	if s.writable != nil && !s.writable() {
+		return e.ErrReadOnly
+	}
+	return nil
+}
+
+// checkExternalRef validates ref and returns ErrDuplicateExternalRef when a
+// company other than owner already carries it. Empty references are allowed.
+func (s *CompanyService) checkExternalRef(ctx context.Context, ref string, owner uuid.UUID) error {
+	if ref == "" {
+		return nil
+	}
+	if len(ref) > maxExternalRefLength {
+		return e.Invalid("external_ref", e.CodeExternalRefTooLong, "external reference too long")
+	}
+	existing, err := s.repo.GetCompanyByExternalRef(ctx, ref)
+	switch {
+	case errors.Is(err, e.ErrNotFound):
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to check external reference: %w", err)
+	case existing.ID != owner:
+		return e.ErrDuplicateExternalRef
+	}
+	return nil
+}
 
+// checkEmployeesDerived returns a CodeEmployeesDerived error when company
+// has employee records and employees is not their number.
+func (s *CompanyService) checkEmployeesDerived(ctx context.Context, company uuid.UUID, employees int) error {
+	count, err := s.repo.CountEmployees(ctx, company)
+	if err != nil {
+		return fmt.Errorf("failed to count employees: %w", err)
+	}
+	if count > 0 && count != int64(employees) {
+		return e.Invalid("employees", e.CodeEmployeesDerived,
+			fmt.Sprintf("employees is derived from the %d employee records", count))
+	}
 	return nil
 }
+
+// encodePageToken returns the opaque page token for offset.
+func encodePageToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+// decodePageToken returns the offset encoded in token; an empty token is the
+// first page.
+func decodePageToken(token string) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, e.Invalid("page_token", e.CodePageTokenInvalid, "invalid page token")
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, e.Invalid("page_token", e.CodePageTokenInvalid, "invalid page token")
+	}
+	return offset, nil
+}
+
+// actorFromContext returns the user ID of the authenticated caller, or "" for
+// unauthenticated calls.
+func actorFromContext(ctx context.Context) string {
+	identity, _ := auth.FromContext(ctx)
+	return identity.UserID
+}
+
+// requiredOwner returns the user ID a company must have been created by for
+// the caller to modify it, and false when the caller may modify any
+// company: ownership checks are disabled, the caller is an admin, or there
+// is no caller identity.
+func (s *CompanyService) requiredOwner(ctx context.Context) (string, bool) {
+	if !s.ownershipChecks {
+		return "", false
+	}
+	identity, ok := auth.FromContext(ctx)
+	if !ok || identity.HasRole(auth.AdminRole) {
+		return "", false
+	}
+	return identity.UserID, true
+}
+
+// diffCompanies returns the old and new value of every user-editable field
+// that differs between before and after, keyed by field name.
+func diffCompanies(before, after *models.Company) map[string]models.FieldChange {
+	changes := make(map[string]models.FieldChange)
+	if before.Name != after.Name {
+		changes["name"] = models.FieldChange{Old: before.Name, New: after.Name}
+	}
+	if before.Description != after.Description {
+		changes["description"] = models.FieldChange{Old: before.Description, New: after.Description}
+	}
+	if before.Employees != after.Employees {
+		changes["employees"] = models.FieldChange{Old: before.Employees, New: after.Employees}
+	}
+	if before.EmployeeRange != after.EmployeeRange {
+		changes["employee_range"] = models.FieldChange{Old: before.EmployeeRange, New: after.EmployeeRange}
+	}
+	if before.Registered != after.Registered {
+		changes["registered"] = models.FieldChange{Old: before.Registered, New: after.Registered}
+	}
+	if before.Status != after.Status {
+		changes["status"] = models.FieldChange{Old: before.Status, New: after.Status}
+	}
+	if before.Type != after.Type {
+		changes["type"] = models.FieldChange{Old: before.Type, New: after.Type}
+	}
+	if before.ExternalRef != after.ExternalRef {
+		changes["external_ref"] = models.FieldChange{Old: before.ExternalRef, New: after.ExternalRef}
+	}
+	if !maps.Equal(before.Metadata, after.Metadata) {
+		changes["metadata"] = models.FieldChange{Old: before.Metadata, New: after.Metadata}
+	}
+	if before.ContactEmail != after.ContactEmail {
+		// The addresses are encrypted at rest and kept out of events.
+		changes["contact_email"] = models.FieldChange{}
+	}
+	return changes
+}
+
+// validEmail reports whether address is a bare email address of at most
+// 254 characters.
+func validEmail(address string) bool {
+	if len(address) > 254 {
+		return false
+	}
+	parsed, err := mail.ParseAddress(address)
+	return err == nil && parsed.Address == address
+}
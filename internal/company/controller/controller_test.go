@@ -3,27 +3,52 @@ package controller
 import (
 	"context"
 	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/gartstein/xm/internal/company/auth"
 	"github.com/gartstein/xm/internal/company/db"
 	e "github.com/gartstein/xm/internal/company/errors"
 	"github.com/gartstein/xm/internal/company/events"
 	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/company/validation"
 	"github.com/gartstein/xm/internal/pkg/utils"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
+	"gorm.io/driver/sqlite"
 )
 
 // MockRepository implements the Repository interface for testing
 type MockRepository struct {
 	createCompany       func(context.Context, *models.Company) error
 	getCompany          func(context.Context, uuid.UUID) (*models.Company, error)
+	getForUpdate        func(context.Context, uuid.UUID) (*models.Company, error)
+	getByName           func(context.Context, string) (*models.Company, error)
+	getByExternalRef    func(context.Context, string) (*models.Company, error)
+	listCompanies       func(context.Context, models.CompanyFilter, int, int) ([]models.Company, error)
+	countCompanies      func(context.Context, models.CompanyFilter) (int64, error)
 	updateCompany       func(context.Context, *models.CompanyUpdate) error
+	updateReturning     func(context.Context, *models.CompanyUpdate) (*models.Company, *models.Company, error)
 	deleteCompany       func(context.Context, uuid.UUID) error
+	purgeCompany        func(context.Context, uuid.UUID) error
+	purgeDeleted        func(context.Context, time.Time) (int64, error)
+	eraseCompanyData    func(context.Context, uuid.UUID, string, time.Time) (*models.Company, error)
 	companyExistsByName func(context.Context, string) (bool, error)
+	findSimilar         func(context.Context, string, float64, int) ([]models.Company, error)
+	recordEvent         func(context.Context, *models.CompanyEvent) error
+	forEachEvent        func(context.Context, models.CompanyEventFilter, func(*models.CompanyEvent) error) error
+	listEvents          func(context.Context, models.CompanyEventFilter, int) ([]models.CompanyEvent, error)
+	getEmployee         func(context.Context, uuid.UUID, uuid.UUID) (*models.Employee, error)
+	listEmployees       func(context.Context, uuid.UUID, int, int) ([]models.Employee, error)
+	countEmployees      func(context.Context, uuid.UUID) (int64, error)
+	listNotes           func(context.Context, uuid.UUID, int, int) ([]models.CompanyNote, error)
 	withTransaction     func(context.Context, func(*db.Repository) error) error
+	runInTransaction    func(context.Context, func(context.Context) error) error
 }
 
 func (m *MockRepository) CreateCompany(ctx context.Context, c *models.Company) error {
@@ -34,10 +59,34 @@ func (m *MockRepository) GetCompany(ctx context.Context, id uuid.UUID) (*models.
 	return m.getCompany(ctx, id)
 }
 
+func (m *MockRepository) GetCompanyForUpdate(ctx context.Context, id uuid.UUID) (*models.Company, error) {
+	return m.getForUpdate(ctx, id)
+}
+
+func (m *MockRepository) GetCompanyByName(ctx context.Context, name string) (*models.Company, error) {
+	return m.getByName(ctx, name)
+}
+
+func (m *MockRepository) GetCompanyByExternalRef(ctx context.Context, ref string) (*models.Company, error) {
+	return m.getByExternalRef(ctx, ref)
+}
+
+func (m *MockRepository) ListCompanies(ctx context.Context, filter models.CompanyFilter, offset, limit int) ([]models.Company, error) {
+	return m.listCompanies(ctx, filter, offset, limit)
+}
+
+func (m *MockRepository) CountCompanies(ctx context.Context, filter models.CompanyFilter) (int64, error) {
+	return m.countCompanies(ctx, filter)
+}
+
 func (m *MockRepository) UpdateCompany(ctx context.Context, u *models.CompanyUpdate) error {
 	return m.updateCompany(ctx, u)
 }
 
+func (m *MockRepository) UpdateCompanyReturning(ctx context.Context, u *models.CompanyUpdate) (*models.Company, *models.Company, error) {
+	return m.updateReturning(ctx, u)
+}
+
 func (m *MockRepository) Close() error {
 	return nil
 }
@@ -46,31 +95,108 @@ func (m *MockRepository) DeleteCompany(ctx context.Context, id uuid.UUID) error
 	return m.deleteCompany(ctx, id)
 }
 
+func (m *MockRepository) PurgeCompany(ctx context.Context, id uuid.UUID) error {
+	return m.purgeCompany(ctx, id)
+}
+
+func (m *MockRepository) PurgeDeletedCompanies(ctx context.Context, before time.Time) (int64, error) {
+	return m.purgeDeleted(ctx, before)
+}
+
+func (m *MockRepository) EraseCompanyData(ctx context.Context, id uuid.UUID, actor string, at time.Time) (*models.Company, error) {
+	return m.eraseCompanyData(ctx, id, actor, at)
+}
+
 func (m *MockRepository) CompanyExistsByName(ctx context.Context, name string) (bool, error) {
 	return m.companyExistsByName(ctx, name)
 }
 
+// RecordCompanyEvent defaults to succeeding so tests only set it when they
+// inspect the event history.
+func (m *MockRepository) RecordCompanyEvent(ctx context.Context, ev *models.CompanyEvent) error {
+	if m.recordEvent == nil {
+		return nil
+	}
+	return m.recordEvent(ctx, ev)
+}
+
+func (m *MockRepository) ListCompanyEvents(ctx context.Context, filter models.CompanyEventFilter, limit int) ([]models.CompanyEvent, error) {
+	return m.listEvents(ctx, filter, limit)
+}
+
+func (m *MockRepository) ForEachCompanyEvent(ctx context.Context, filter models.CompanyEventFilter, fn func(*models.CompanyEvent) error) error {
+	return m.forEachEvent(ctx, filter, fn)
+}
+
+func (m *MockRepository) GetEmployee(ctx context.Context, company, id uuid.UUID) (*models.Employee, error) {
+	return m.getEmployee(ctx, company, id)
+}
+
+func (m *MockRepository) ListEmployees(ctx context.Context, company uuid.UUID, offset, limit int) ([]models.Employee, error) {
+	return m.listEmployees(ctx, company, offset, limit)
+}
+
+// CountEmployees defaults to no employee records, so tests updating the
+// employee count only set it when they cover derived counts.
+func (m *MockRepository) CountEmployees(ctx context.Context, company uuid.UUID) (int64, error) {
+	if m.countEmployees == nil {
+		return 0, nil
+	}
+	return m.countEmployees(ctx, company)
+}
+
+func (m *MockRepository) ListCompanyNotes(ctx context.Context, company uuid.UUID, offset, limit int) ([]models.CompanyNote, error) {
+	return m.listNotes(ctx, company, offset, limit)
+}
+
+func (m *MockRepository) FindSimilarCompanies(ctx context.Context, name string, threshold float64, limit int) ([]models.Company, error) {
+	return m.findSimilar(ctx, name, threshold, limit)
+}
+
 func (m *MockRepository) WithTransaction(ctx context.Context, fn func(*db.Repository) error) error {
 	return m.withTransaction(ctx, fn)
 }
 
+// RunInTransaction defaults to running fn directly, so tests only set it
+// when they cover the outcome of the unit of work.
+func (m *MockRepository) RunInTransaction(ctx context.Context, fn func(context.Context) error) error {
+	if m.runInTransaction == nil {
+		return fn(ctx)
+	}
+	return m.runInTransaction(ctx, fn)
+}
+
 // MockProducer is a test double for the Kafka producer.
 type MockProducer struct {
-	producedEvents []interface{}
+	mu             sync.Mutex
+	producedEvents []events.Event
+	replayedEvents []events.Event
+	replayTopic    string
+	replayErr      error
 	wg             *sync.WaitGroup
 }
 
-// Produce records the event and signals the wait group.
-func (m *MockProducer) Produce(eventType events.EventType, company *models.Company) {
-	m.producedEvents = append(m.producedEvents, struct {
-		EventType events.EventType
-		Company   *models.Company
-	}{eventType, company})
+// Produce records the event and signals the wait group. Concurrent requests
+// may produce at once.
+func (m *MockProducer) Produce(event events.Event) {
+	m.mu.Lock()
+	m.producedEvents = append(m.producedEvents, event)
+	m.mu.Unlock()
 	if m.wg != nil {
 		m.wg.Done()
 	}
 }
 
+// Replay records the event unless replayErr is set.
+func (m *MockProducer) Replay(_ context.Context, topic string, event events.Event) error {
+	if m.replayErr != nil {
+		return m.replayErr
+	}
+	m.replayTopic = topic
+	m.replayedEvents = append(m.replayedEvents, event)
+	return nil
+}
+
 func TestCompanyService_CreateCompany(t *testing.T) {
 	testID := uuid.New()
 	now := time.Now()
@@ -125,6 +251,26 @@ func TestCompanyService_CreateCompany(t *testing.T) {
 			mockSetup:   func(_ *MockRepository, _ *MockProducer) {},
 			expectError: true,
 		},
+		{
+			name: "negative employees",
+			input: &models.Company{
+				Name:      "Valid",
+				Employees: -1,
+			},
+			mockSetup:     func(_ *MockRepository, _ *MockProducer) {},
+			expectError:   true,
+			expectedError: e.ErrInvalidInput,
+		},
+		{
+			name: "invalid contact email",
+			input: &models.Company{
+				Name:         "Valid",
+				ContactEmail: "CEO <ceo@acme.test>",
+			},
+			mockSetup:     func(_ *MockRepository, _ *MockProducer) {},
+			expectError:   true,
+			expectedError: e.ErrInvalidInput,
+		},
 		{
 			name: "repository error",
 			input: &models.Company{
@@ -155,7 +301,7 @@ func TestCompanyService_CreateCompany(t *testing.T) {
 				mockProducer.wg.Add(1)
 			}
 
-			result, err := service.CreateCompany(context.Background(), tt.input)
+			result, err := service.CreateCompany(context.Background(), tt.input, models.CreateOptions{})
 
 			// Wait for the event production to complete.
 			if !tt.expectError {
@@ -176,6 +322,9 @@ func TestCompanyService_CreateCompany(t *testing.T) {
 				if result.ID == uuid.Nil {
 					t.Error("expected company ID to be set")
 				}
+				if result.EmployeeRange != models.Employees11To50 {
+					t.Errorf("expected employee range %s, got %q", models.Employees11To50, result.EmployeeRange)
+				}
 				if len(mockProducer.producedEvents) != 1 {
 					t.Error("expected creation event to be produced")
 				}
@@ -184,6 +333,37 @@ func TestCompanyService_CreateCompany(t *testing.T) {
 	}
 }
 
+func TestCompanyService_CreateCompanyReportsEveryViolation(t *testing.T) {
+	service := NewCompanyService(&MockRepository{}, &MockProducer{}, zaptest.NewLogger(t))
+	_, err := service.CreateCompany(context.Background(), &models.Company{
+		Employees:    -1,
+		ContactEmail: "not an email",
+		Status:       models.StatusArchived,
+	}, models.CreateOptions{})
+
+	var invalid *e.ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	var fields []string
+	for _, v := range invalid.Violations {
+		fields = append(fields, v.Field)
+	}
+	want := []string{"name", "employees", "contact_email", "status"}
+	if len(fields) != len(want) {
+		t.Fatalf("expected violations of %v, got %v", want, fields)
+	}
+	for i := range want {
+		if fields[i] != want[i] {
+			t.Errorf("expected violations of %v, got %v", want, fields)
+			break
+		}
+	}
+	if e.CodeOf(err) != e.CodeNameRequired || !errors.Is(err, e.ErrInvalidInput) {
+		t.Errorf("expected %s matching ErrInvalidInput, got %v", e.CodeNameRequired, err)
+	}
+}
+
 func TestCompanyService_GetCompany(t *testing.T) {
 	testID := uuid.New()
 	validCompany := &models.Company{
@@ -269,15 +449,23 @@ func TestCompanyService_UpdateCompany(t *testing.T) {
 			name:  "successful update",
 			input: validUpdate,
 			mockSetup: func(mr *MockRepository, _ *MockProducer) {
-				mr.updateCompany = func(_ context.Context, _ *models.CompanyUpdate) error {
-					return nil
-				}
-				mr.getCompany = func(_ context.Context, _ uuid.UUID) (*models.Company, error) {
-					return &models.Company{ID: testID}, nil
+				mr.updateReturning = func(_ context.Context, _ *models.CompanyUpdate) (*models.Company, *models.Company, error) {
+					return &models.Company{ID: testID}, &models.Company{ID: testID}, nil
 				}
 			},
 			expectError: false,
 		},
+		{
+			name:  "not found",
+			input: validUpdate,
+			mockSetup: func(mr *MockRepository, _ *MockProducer) {
+				mr.updateReturning = func(_ context.Context, _ *models.CompanyUpdate) (*models.Company, *models.Company, error) {
+					return nil, nil, e.ErrNotFound
+				}
+			},
+			expectError:   true,
+			expectedError: e.ErrNotFound,
+		},
 		{
 			name: "invalid ID",
 			input: &models.CompanyUpdate{
@@ -304,7 +492,7 @@ func TestCompanyService_UpdateCompany(t *testing.T) {
 				mockProducer.wg.Add(1)
 			}
 
-			_, err := service.UpdateCompany(context.Background(), tt.input)
+			_, err := service.UpdateCompany(context.Background(), tt.input, models.UpdateOptions{})
 
 			// Wait for the asynchronous event to be produced.
 			if !tt.expectError {
@@ -330,79 +518,1458 @@ func TestCompanyService_UpdateCompany(t *testing.T) {
 	}
 }
 
-func TestCompanyService_DeleteCompany(t *testing.T) {
+func TestCompanyService_RecordsActor(t *testing.T) {
+	const actor = "user-42"
+	ctx := auth.NewContext(context.Background(), auth.Identity{UserID: actor})
+	testID := uuid.New()
+
+	var created *models.Company
+	var update *models.CompanyUpdate
+	mockRepo := &MockRepository{
+		companyExistsByName: func(_ context.Context, _ string) (bool, error) { return false, nil },
+		createCompany: func(_ context.Context, c *models.Company) error {
+			created = c
+			return nil
+		},
+		updateReturning: func(_ context.Context, u *models.CompanyUpdate) (*models.Company, *models.Company, error) {
+			update = u
+			return &models.Company{ID: u.ID}, &models.Company{ID: u.ID, UpdatedBy: u.UpdatedBy}, nil
+		},
+		getForUpdate: func(_ context.Context, id uuid.UUID) (*models.Company, error) {
+			return &models.Company{ID: id, Name: "Acme"}, nil
+		},
+		deleteCompany: func(context.Context, uuid.UUID) error { return nil },
+	}
+	mockProducer := &MockProducer{}
+	service := NewCompanyService(mockRepo, mockProducer, zaptest.NewLogger(t))
+
+	if _, err := service.CreateCompany(ctx, &models.Company{Name: "Acme", Type: models.Corporations}, models.CreateOptions{}); err != nil {
+		t.Fatalf("unexpected create error: %v", err)
+	}
+	if created.CreatedBy != actor || created.UpdatedBy != actor {
+		t.Errorf("expected CreatedBy and UpdatedBy %q, got %q and %q", actor, created.CreatedBy, created.UpdatedBy)
+	}
+
+	if _, err := service.UpdateCompany(ctx, &models.CompanyUpdate{ID: testID, Name: utils.Ptr("Acme 2")}, models.UpdateOptions{}); err != nil {
+		t.Fatalf("unexpected update error: %v", err)
+	}
+	if update.UpdatedBy != actor {
+		t.Errorf("expected UpdatedBy %q, got %q", actor, update.UpdatedBy)
+	}
+
+	if _, err := service.DeleteCompany(ctx, testID); err != nil {
+		t.Fatalf("unexpected delete error: %v", err)
+	}
+
+	if len(mockProducer.producedEvents) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(mockProducer.producedEvents))
+	}
+	for _, ev := range mockProducer.producedEvents {
+		if ev.Actor != actor {
+			t.Errorf("expected %s event actor %q, got %q", ev.Type, actor, ev.Actor)
+		}
+	}
+}
+
+func TestCompanyService_UpdateCompanyChanges(t *testing.T) {
+	testID := uuid.New()
+	mockRepo := &MockRepository{
+		updateReturning: func(_ context.Context, _ *models.CompanyUpdate) (*models.Company, *models.Company, error) {
+			before := &models.Company{ID: testID, Name: "Acme", Employees: 10, Type: models.Corporations}
+			after := &models.Company{ID: testID, Name: "Acme 2", Employees: 12, Type: models.Corporations}
+			return before, after, nil
+		},
+	}
+	mockProducer := &MockProducer{}
+	service := NewCompanyService(mockRepo, mockProducer, zaptest.NewLogger(t))
+
+	update := &models.CompanyUpdate{ID: testID, Name: utils.Ptr("Acme 2"), Employees: utils.Ptr(12)}
+	if _, err := service.UpdateCompany(context.Background(), update, models.UpdateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(mockProducer.producedEvents) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(mockProducer.producedEvents))
+	}
+	changes := mockProducer.producedEvents[0].Changes
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changed fields, got %v", changes)
+	}
+	if got := changes["name"]; got.Old != "Acme" || got.New != "Acme 2" {
+		t.Errorf("unexpected name change: %+v", got)
+	}
+	if got := changes["employees"]; got.Old != 10 || got.New != 12 {
+		t.Errorf("unexpected employees change: %+v", got)
+	}
+}
+
+func TestCompanyService_UpdateContactEmail(t *testing.T) {
 	testID := uuid.New()
+	mockRepo := &MockRepository{
+		updateReturning: func(_ context.Context, _ *models.CompanyUpdate) (*models.Company, *models.Company, error) {
+			before := &models.Company{ID: testID, Name: "Acme", ContactEmail: "ceo@acme.test"}
+			after := &models.Company{ID: testID, Name: "Acme", ContactEmail: "cfo@acme.test"}
+			return before, after, nil
+		},
+	}
+	mockProducer := &MockProducer{}
+	service := NewCompanyService(mockRepo, mockProducer, zaptest.NewLogger(t))
 
+	_, err := service.UpdateCompany(context.Background(), &models.CompanyUpdate{ID: testID, ContactEmail: utils.Ptr("not an address")}, models.UpdateOptions{})
+	if !errors.Is(err, e.ErrInvalidInput) {
+		t.Fatalf("expected ErrInvalidInput, got %v", err)
+	}
+
+	if _, err := service.UpdateCompany(context.Background(), &models.CompanyUpdate{ID: testID, ContactEmail: utils.Ptr("cfo@acme.test")}, models.UpdateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mockProducer.producedEvents) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(mockProducer.producedEvents))
+	}
+	change, ok := mockProducer.producedEvents[0].Changes["contact_email"]
+	if !ok {
+		t.Fatal("expected the contact email change to be recorded")
+	}
+	if change.Old != nil || change.New != nil {
+		t.Errorf("expected the addresses to be left out of the event, got %+v", change)
+	}
+}
+
+func TestCompanyService_DeleteCompany(t *testing.T) {
 	tests := []struct {
 		name          string
-		input         uuid.UUID
-		mockSetup     func(*MockRepository, *MockProducer)
-		expectError   bool
+		opts          []ServiceOption
 		expectedError error
 	}{
-		{
-			name:  "successful deletion",
-			input: testID,
-			mockSetup: func(mr *MockRepository, _ *MockProducer) {
-				mr.getCompany = func(_ context.Context, _ uuid.UUID) (*models.Company, error) {
-					return &models.Company{ID: testID}, nil
-				}
-				mr.deleteCompany = func(_ context.Context, _ uuid.UUID) error {
-					return nil
-				}
-			},
-			expectError: false,
-		},
-		{
-			name:  "not found",
-			input: testID,
-			mockSetup: func(mr *MockRepository, _ *MockProducer) {
-				mr.getCompany = func(_ context.Context, _ uuid.UUID) (*models.Company, error) {
-					return nil, e.ErrNotFound
-				}
-			},
-			expectError:   true,
-			expectedError: e.ErrNotFound,
-		},
+		{name: "strict", expectedError: e.ErrCompanyNotFound},
+		{name: "idempotent", opts: []ServiceOption{WithIdempotentDeletes()}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			logger := zaptest.NewLogger(t)
-			mockRepo := &MockRepository{}
-			// Initialize the mock producer with a WaitGroup to wait for the async event.
-			mockProducer := &MockProducer{wg: new(sync.WaitGroup)}
-			tt.mockSetup(mockRepo, mockProducer)
-
-			service := NewCompanyService(mockRepo, mockProducer, logger)
-
-			// For a successful deletion, add one counter for the async deletion event.
-			if !tt.expectError {
-				mockProducer.wg.Add(1)
+			repo, err := db.Open(sqlite.Open(":memory:"))
+			if err != nil {
+				t.Fatalf("failed to open test database: %v", err)
+			}
+			mockProducer := &MockProducer{}
+			service := NewCompanyService(repo, mockProducer, zaptest.NewLogger(t), tt.opts...)
+			ctx := context.Background()
+			company, err := service.CreateCompany(ctx, &models.Company{Name: "Acme"}, models.CreateOptions{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
 			}
 
-			err := service.DeleteCompany(context.Background(), tt.input)
-
-			// Wait for the asynchronous deletion event to be produced.
-			if !tt.expectError {
-				mockProducer.wg.Wait()
+			deleted, err := service.DeleteCompany(ctx, company.ID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !deleted {
+				t.Error("expected the company to be reported deleted")
+			}
+			if _, err := repo.GetCompany(ctx, company.ID); !errors.Is(err, e.ErrNotFound) {
+				t.Errorf("expected the company to be gone, got %v", err)
 			}
 
-			if tt.expectError {
-				if err == nil {
-					t.Fatal("expected error but got none")
-				}
+			// Deleting again, as a retry or a concurrent delete would, fires
+			// no second event.
+			deleted, err = service.DeleteCompany(ctx, company.ID)
+			if tt.expectedError != nil {
 				if !errors.Is(err, tt.expectedError) {
 					t.Errorf("expected error %v, got %v", tt.expectedError, err)
 				}
-			} else {
-				if err != nil {
-					t.Fatalf("unexpected error: %v", err)
-				}
-				if len(mockProducer.producedEvents) != 1 {
-					t.Error("expected deletion event to be produced")
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if deleted {
+				t.Error("expected the second delete to report nothing deleted")
+			}
+
+			var deletions int
+			for _, ev := range mockProducer.producedEvents {
+				if ev.Type == events.CompanyDeleted {
+					deletions++
 				}
 			}
+			if deletions != 1 {
+				t.Errorf("expected 1 deletion event, got %d", deletions)
+			}
+		})
+	}
+
+	t.Run("repository error", func(t *testing.T) {
+		repoErr := errors.New("connection lost")
+		mockRepo := &MockRepository{
+			runInTransaction: func(context.Context, func(context.Context) error) error { return repoErr },
+		}
+		service := NewCompanyService(mockRepo, &MockProducer{}, zaptest.NewLogger(t), WithIdempotentDeletes())
+		if _, err := service.DeleteCompany(context.Background(), uuid.New()); !errors.Is(err, repoErr) {
+			t.Errorf("expected error %v, got %v", repoErr, err)
+		}
+	})
+}
+
+// failingHistory is a database whose event history rejects every event.
+type failingHistory struct {
+	*db.Repository
+}
+
+func (failingHistory) RecordCompanyEvent(context.Context, *models.CompanyEvent) error {
+	return errors.New("disk full")
+}
+
+func TestCompanyService_UnitOfWork(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	ctx := context.Background()
+	company, err := NewCompanyService(repo, &MockProducer{}, zaptest.NewLogger(t)).
+		CreateCompany(ctx, &models.Company{Name: "Acme"}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A mutation whose event cannot be recorded is rolled back with it, and
+	// nothing is produced.
+	mockProducer := &MockProducer{}
+	service := NewCompanyService(failingHistory{repo}, mockProducer, zaptest.NewLogger(t))
+	if _, err := service.CreateCompany(ctx, &models.Company{Name: "Globex"}, models.CreateOptions{}); err == nil {
+		t.Error("expected create to fail")
+	}
+	if exists, _ := repo.CompanyExistsByName(ctx, "Globex"); exists {
+		t.Error("expected the create to be rolled back")
+	}
+	if _, err := service.UpdateCompany(ctx, &models.CompanyUpdate{ID: company.ID, Name: utils.Ptr("Initech")}, models.UpdateOptions{}); err == nil {
+		t.Error("expected update to fail")
+	}
+	if _, err := service.DeleteCompany(ctx, company.ID); err == nil {
+		t.Error("expected delete to fail")
+	}
+	current, err := repo.GetCompany(ctx, company.ID)
+	if err != nil {
+		t.Fatalf("expected the delete to be rolled back, got %v", err)
+	}
+	if current.Name != "Acme" {
+		t.Errorf("expected the update to be rolled back, got name %q", current.Name)
+	}
+	if len(mockProducer.producedEvents) != 0 {
+		t.Errorf("expected no events, got %d", len(mockProducer.producedEvents))
+	}
+}
+
+// validatorFunc adapts a function to Validator.
+type validatorFunc func(ctx context.Context, req validation.Request) ([]models.ValidationResult, error)
+
+func (f validatorFunc) Validate(ctx context.Context, req validation.Request) ([]models.ValidationResult, error) {
+	return f(ctx, req)
+}
+
+func TestCompanyService_Validator(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	var requests []validation.Request
+	validator := validatorFunc(func(_ context.Context, req validation.Request) ([]models.ValidationResult, error) {
+		requests = append(requests, req)
+		if req.Company.Name == "Sanctioned" {
+			return nil, e.Newf(e.CodeValidationRejected, "rejected by sanctions: listed entity")
+		}
+		return []models.ValidationResult{{Hook: "sanctions", Allowed: true}}, nil
+	})
+	mockProducer := &MockProducer{}
+	service := NewCompanyService(repo, mockProducer, zaptest.NewLogger(t), WithValidator(validator))
+	ctx := context.Background()
+
+	company, err := service.CreateCompany(ctx, &models.Company{Name: "Acme"}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(requests) != 1 || requests[0].Operation != validation.OperationCreate || requests[0].Company.ID != company.ID {
+		t.Errorf("expected the new company to be validated, got %+v", requests)
+	}
+
+	// A rejected change fails and is rolled back.
+	if _, err := service.CreateCompany(ctx, &models.Company{Name: "Sanctioned"}, models.CreateOptions{}); !errors.Is(err, e.ErrValidationFailed) {
+		t.Errorf("expected the create to be rejected, got %v", err)
+	}
+	if exists, _ := repo.CompanyExistsByName(ctx, "Sanctioned"); exists {
+		t.Error("expected the rejected create to be rolled back")
+	}
+	if _, err := service.UpdateCompany(ctx, &models.CompanyUpdate{ID: company.ID, Name: utils.Ptr("Sanctioned")}, models.UpdateOptions{}); !errors.Is(err, e.ErrValidationFailed) {
+		t.Errorf("expected the update to be rejected, got %v", err)
+	}
+	if current, _ := repo.GetCompany(ctx, company.ID); current.Name != "Acme" {
+		t.Errorf("expected the rejected update to be rolled back, got name %q", current.Name)
+	}
+
+	// Updates are validated with their changes; unchanged companies are not.
+	requests = nil
+	if _, err := service.UpdateCompany(ctx, &models.CompanyUpdate{ID: company.ID, Name: utils.Ptr("Initech")}, models.UpdateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := service.UpdateCompany(ctx, &models.CompanyUpdate{ID: company.ID, Name: utils.Ptr("Initech")}, models.UpdateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(requests) != 1 || requests[0].Operation != validation.OperationUpdate || requests[0].Changes["name"].New != "Initech" {
+		t.Errorf("expected one validated update, got %+v", requests)
+	}
+
+	// The verdicts are recorded in the event history.
+	history, err := repo.ListCompanyEvents(ctx, models.CompanyEventFilter{CompanyIDs: []uuid.UUID{company.ID}}, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var validated int
+	for _, event := range history {
+		if len(event.Validations) == 1 && event.Validations[0].Hook == "sanctions" {
+			validated++
+		}
+	}
+	if len(history) != 3 || validated != 2 {
+		t.Errorf("expected 3 events, 2 with verdicts, got %d with %d", len(history), validated)
+	}
+	if len(mockProducer.producedEvents) != 3 {
+		t.Errorf("expected 3 produced events, got %d", len(mockProducer.producedEvents))
+	}
+}
+
+func TestCompanyService_SuppressEvents(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	mockProducer := &MockProducer{}
+	service := NewCompanyService(repo, mockProducer, zaptest.NewLogger(t))
+	user := auth.NewContext(context.Background(), auth.Identity{UserID: "alice"})
+	admin := auth.NewContext(context.Background(), auth.Identity{UserID: "root", Roles: []string{auth.AdminRole}})
+
+	if _, err := service.CreateCompany(user, &models.Company{Name: "Acme"}, models.CreateOptions{SuppressEvents: true}); e.CodeOf(err) != e.CodeAdminRequired {
+		t.Errorf("expected a non-admin to be refused, got %v", err)
+	}
+	company, err := service.CreateCompany(admin, &models.Company{Name: "Acme"}, models.CreateOptions{SuppressEvents: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := service.UpdateCompany(admin, &models.CompanyUpdate{ID: company.ID, Name: utils.Ptr("Initech")}, models.UpdateOptions{SuppressEvents: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	desired := []models.Company{{Name: "Globex", ExternalRef: "crm-1"}}
+	if _, err := service.ApplyCompanies(user, desired, models.ApplyOptions{SuppressEvents: true}); e.CodeOf(err) != e.CodeAdminRequired {
+		t.Errorf("expected a non-admin to be refused, got %v", err)
+	}
+	if _, err := service.ApplyCompanies(admin, desired, models.ApplyOptions{SuppressEvents: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The events are recorded as suppressed and handed to the producer,
+	// which only audits them; later changes are published again.
+	if _, err := service.UpdateCompany(admin, &models.CompanyUpdate{ID: company.ID, Name: utils.Ptr("Umbrella")}, models.UpdateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mockProducer.producedEvents) != 4 {
+		t.Fatalf("expected 4 produced events, got %d", len(mockProducer.producedEvents))
+	}
+	for i, event := range mockProducer.producedEvents {
+		if want := i < 3; event.Suppressed != want {
+			t.Errorf("event %d (%s): expected suppressed %v", i, event.Type, want)
+		}
+	}
+	history, err := repo.ListCompanyEvents(context.Background(), models.CompanyEventFilter{}, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var suppressed int
+	for _, event := range history {
+		if event.Suppressed {
+			suppressed++
+		}
+	}
+	if len(history) != 4 || suppressed != 3 {
+		t.Errorf("expected 4 recorded events, 3 suppressed, got %d with %d", len(history), suppressed)
+	}
+}
+
+func TestCompanyService_PurgeCompany(t *testing.T) {
+	tests := []struct {
+		name          string
+		repoErr       error
+		expectedError error
+	}{
+		{name: "successful purge"},
+		{name: "not soft-deleted", repoErr: e.ErrNotFound, expectedError: e.ErrNotFound},
+		{name: "repository error", repoErr: errors.New("database error")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockRepository{
+				purgeCompany: func(_ context.Context, _ uuid.UUID) error {
+					return tt.repoErr
+				},
+			}
+			mockProducer := &MockProducer{}
+			service := NewCompanyService(mockRepo, mockProducer, zaptest.NewLogger(t))
+
+			err := service.PurgeCompany(context.Background(), uuid.New())
+			if tt.repoErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.repoErr != nil && err == nil {
+				t.Fatal("expected error but got none")
+			}
+			if tt.expectedError != nil && !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if len(mockProducer.producedEvents) != 0 {
+				t.Error("purge should not produce events")
+			}
 		})
 	}
 }
+
+func TestCompanyService_EraseCompanyData(t *testing.T) {
+	id := uuid.New()
+	var gotActor string
+	mockRepo := &MockRepository{
+		eraseCompanyData: func(_ context.Context, got uuid.UUID, actor string, at time.Time) (*models.Company, error) {
+			if got != id {
+				return nil, e.ErrCompanyNotFound
+			}
+			gotActor = actor
+			return &models.Company{ID: id, Name: "Acme", ErasedAt: &at, UpdatedBy: actor}, nil
+		},
+	}
+	mockProducer := &MockProducer{}
+	service := NewCompanyService(mockRepo, mockProducer, zaptest.NewLogger(t))
+	ctx := auth.NewContext(context.Background(), auth.Identity{UserID: "dpo"})
+
+	company, err := service.EraseCompanyData(ctx, id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if company.ErasedAt == nil || gotActor != "dpo" {
+		t.Errorf("expected the company to be erased by dpo, got %+v by %q", company, gotActor)
+	}
+	if len(mockProducer.producedEvents) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(mockProducer.producedEvents))
+	}
+	event := mockProducer.producedEvents[0]
+	if event.Type != events.CompanyErased || event.Actor != "dpo" {
+		t.Errorf("expected a CompanyErased event by dpo, got %q by %q", event.Type, event.Actor)
+	}
+	for _, field := range []string{"contact_email", "description"} {
+		change, ok := event.Changes[field]
+		if !ok || change.Old != nil || change.New != nil {
+			t.Errorf("expected %s to be listed without values, got %+v", field, event.Changes)
+		}
+	}
+
+	if _, err := service.EraseCompanyData(ctx, uuid.New()); !errors.Is(err, e.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCompanyService_RecordsEventHistory(t *testing.T) {
+	var recorded []*models.CompanyEvent
+	mockRepo := &MockRepository{
+		companyExistsByName: func(_ context.Context, _ string) (bool, error) { return false, nil },
+		createCompany:       func(_ context.Context, _ *models.Company) error { return nil },
+		recordEvent: func(_ context.Context, ev *models.CompanyEvent) error {
+			recorded = append(recorded, ev)
+			return nil
+		},
+	}
+	mockProducer := &MockProducer{}
+	service := NewCompanyService(mockRepo, mockProducer, zaptest.NewLogger(t))
+
+	created, err := service.CreateCompany(context.Background(), &models.Company{Name: "Acme", Type: models.Corporations}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(recorded) != 1 || len(mockProducer.producedEvents) != 1 {
+		t.Fatalf("expected one recorded and one produced event, got %d and %d", len(recorded), len(mockProducer.producedEvents))
+	}
+	if recorded[0].ID != mockProducer.producedEvents[0].EventID {
+		t.Error("expected the recorded event to keep the published EventID")
+	}
+	if recorded[0].CompanyID != created.ID || recorded[0].Type != string(events.CompanyCreated) {
+		t.Errorf("unexpected recorded event: %+v", recorded[0])
+	}
+}
+
+func TestCompanyService_ReplayCompanyEvents(t *testing.T) {
+	stored := []models.CompanyEvent{
+		{ID: uuid.New(), Type: string(events.CompanyCreated), Company: models.Company{Name: "Acme"}},
+		{ID: uuid.New(), Type: string(events.CompanyUpdated), Company: models.Company{Name: "Acme 2"},
+			Changes: map[string]models.FieldChange{"name": {Old: "Acme", New: "Acme 2"}}},
+	}
+	var gotFilter models.CompanyEventFilter
+	newRepo := func() *MockRepository {
+		return &MockRepository{
+			forEachEvent: func(_ context.Context, filter models.CompanyEventFilter, fn func(*models.CompanyEvent) error) error {
+				gotFilter = filter
+				for i := range stored {
+					if err := fn(&stored[i]); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		}
+	}
+
+	t.Run("replays stored events", func(t *testing.T) {
+		mockProducer := &MockProducer{}
+		service := NewCompanyService(newRepo(), mockProducer, zaptest.NewLogger(t))
+		filter := models.CompanyEventFilter{Types: []string{"company_created", "company_updated"}}
+
+		n, err := service.ReplayCompanyEvents(context.Background(), filter, "rebuild")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != 2 || len(mockProducer.replayedEvents) != 2 {
+			t.Fatalf("expected 2 replayed events, got %d", n)
+		}
+		if len(gotFilter.Types) != 2 || mockProducer.replayTopic != "rebuild" {
+			t.Errorf("unexpected filter %+v or topic %q", gotFilter, mockProducer.replayTopic)
+		}
+		second := mockProducer.replayedEvents[1]
+		if second.EventID != stored[1].ID || second.Company.Name != "Acme 2" || second.Changes["name"].New != "Acme 2" {
+			t.Errorf("unexpected replayed event: %+v", second)
+		}
+	})
+
+	t.Run("producer error", func(t *testing.T) {
+		mockProducer := &MockProducer{replayErr: errors.New("kafka down")}
+		service := NewCompanyService(newRepo(), mockProducer, zaptest.NewLogger(t))
+
+		n, err := service.ReplayCompanyEvents(context.Background(), models.CompanyEventFilter{}, "rebuild")
+		if err == nil || n != 0 {
+			t.Errorf("expected error and no replayed events, got %d, %v", n, err)
+		}
+	})
+
+	invalid := []struct {
+		name   string
+		filter models.CompanyEventFilter
+		topic  string
+	}{
+		{name: "missing topic", topic: ""},
+		{name: "unknown type", filter: models.CompanyEventFilter{Types: []string{"company_renamed"}}, topic: "rebuild"},
+		{name: "empty window", filter: models.CompanyEventFilter{Since: time.Unix(100, 0), Until: time.Unix(100, 0)}, topic: "rebuild"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewCompanyService(newRepo(), &MockProducer{}, zaptest.NewLogger(t))
+			if _, err := service.ReplayCompanyEvents(context.Background(), tt.filter, tt.topic); !errors.Is(err, e.ErrInvalidInput) {
+				t.Errorf("expected ErrInvalidInput, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCompanyService_UpdateCompanyEmployees(t *testing.T) {
+	var got *models.CompanyUpdate
+	mockRepo := &MockRepository{
+		updateReturning: func(_ context.Context, u *models.CompanyUpdate) (*models.Company, *models.Company, error) {
+			got = u
+			return &models.Company{ID: u.ID}, &models.Company{ID: u.ID}, nil
+		},
+	}
+	service := NewCompanyService(mockRepo, &MockProducer{}, zaptest.NewLogger(t))
+
+	_, err := service.UpdateCompany(context.Background(), &models.CompanyUpdate{ID: uuid.New(), Employees: utils.Ptr(-5)}, models.UpdateOptions{})
+	if !errors.Is(err, e.ErrInvalidInput) {
+		t.Fatalf("expected ErrInvalidInput for negative employees, got %v", err)
+	}
+
+	if _, err := service.UpdateCompany(context.Background(), &models.CompanyUpdate{ID: uuid.New(), Employees: utils.Ptr(6000)}, models.UpdateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.EmployeeRange == nil || *got.EmployeeRange != models.Employees5001Plus {
+		t.Errorf("expected employee range %s, got %v", models.Employees5001Plus, got.EmployeeRange)
+	}
+
+	if _, err := service.UpdateCompany(context.Background(), &models.CompanyUpdate{ID: uuid.New(), Name: utils.Ptr("Acme")}, models.UpdateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.EmployeeRange != nil {
+		t.Errorf("expected employee range untouched when employees are not updated, got %v", *got.EmployeeRange)
+	}
+}
+
+func TestCompanyService_ListCompanies(t *testing.T) {
+	all := make([]models.Company, 5)
+	for i := range all {
+		all[i] = models.Company{ID: uuid.New()}
+	}
+	var gotFilter models.CompanyFilter
+	mockRepo := &MockRepository{
+		listCompanies: func(_ context.Context, filter models.CompanyFilter, offset, limit int) ([]models.Company, error) {
+			gotFilter = filter
+			end := min(offset+limit, len(all))
+			return all[min(offset, end):end], nil
+		},
+	}
+	service := NewCompanyService(mockRepo, &MockProducer{}, zaptest.NewLogger(t))
+	filter := models.CompanyFilter{EmployeeRanges: []models.EmployeeRange{models.Employees1To10}}
+
+	var listed []models.Company
+	token := ""
+	for pages := 0; ; pages++ {
+		if pages > len(all) {
+			t.Fatal("pagination did not terminate")
+		}
+		page, next, err := service.ListCompanies(context.Background(), filter, 2, token)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		listed = append(listed, page...)
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	if len(listed) != len(all) {
+		t.Fatalf("expected %d companies, got %d", len(all), len(listed))
+	}
+	for i := range all {
+		if listed[i].ID != all[i].ID {
+			t.Errorf("company %d out of order", i)
+		}
+	}
+	if len(gotFilter.EmployeeRanges) != 1 {
+		t.Errorf("expected filter to be passed through, got %+v", gotFilter)
+	}
+
+	for _, token := range []string{"not base64!", encodePageToken(-1)} {
+		if _, _, err := service.ListCompanies(context.Background(), filter, 2, token); !errors.Is(err, e.ErrInvalidInput) {
+			t.Errorf("expected ErrInvalidInput for token %q, got %v", token, err)
+		}
+	}
+	if _, _, err := service.ListCompanies(context.Background(), filter, -1, ""); !errors.Is(err, e.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for negative page size, got %v", err)
+	}
+}
+
+func TestCompanyService_UUIDv7(t *testing.T) {
+	mockRepo := &MockRepository{
+		companyExistsByName: func(_ context.Context, _ string) (bool, error) { return false, nil },
+		createCompany:       func(_ context.Context, _ *models.Company) error { return nil },
+	}
+
+	service := NewCompanyService(mockRepo, &MockProducer{}, zaptest.NewLogger(t))
+	created, err := service.CreateCompany(context.Background(), &models.Company{Name: "Acme"}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if created.ID.Version() != 4 {
+		t.Errorf("expected a version 4 ID by default, got version %d", created.ID.Version())
+	}
+
+	service = NewCompanyService(mockRepo, &MockProducer{}, zaptest.NewLogger(t), WithUUIDv7())
+	created, err = service.CreateCompany(context.Background(), &models.Company{Name: "Acme"}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if created.ID.Version() != 7 {
+		t.Errorf("expected a version 7 ID, got version %d", created.ID.Version())
+	}
+}
+
+func TestCompanyService_ExternalRef(t *testing.T) {
+	owner := &models.Company{ID: uuid.New(), Name: "Acme", ExternalRef: "ERP-1"}
+	mockRepo := &MockRepository{
+		companyExistsByName: func(_ context.Context, _ string) (bool, error) { return false, nil },
+		createCompany:       func(_ context.Context, _ *models.Company) error { return nil },
+		getByExternalRef: func(_ context.Context, ref string) (*models.Company, error) {
+			if ref == owner.ExternalRef {
+				return owner, nil
+			}
+			return nil, e.ErrNotFound
+		},
+		updateReturning: func(_ context.Context, u *models.CompanyUpdate) (*models.Company, *models.Company, error) {
+			return &models.Company{ID: u.ID}, &models.Company{ID: u.ID, ExternalRef: *u.ExternalRef}, nil
+		},
+	}
+	service := NewCompanyService(mockRepo, &MockProducer{}, zaptest.NewLogger(t))
+	ctx := context.Background()
+
+	got, err := service.GetCompanyByExternalRef(ctx, "ERP-1")
+	if err != nil || got.ID != owner.ID {
+		t.Fatalf("expected owner, got %v, %v", got, err)
+	}
+	if _, err := service.GetCompanyByExternalRef(ctx, "ERP-2"); !errors.Is(err, e.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := service.GetCompanyByExternalRef(ctx, ""); !errors.Is(err, e.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+
+	if _, err := service.CreateCompany(ctx, &models.Company{Name: "Other", ExternalRef: "ERP-1"}, models.CreateOptions{}); !errors.Is(err, e.ErrDuplicateExternalRef) {
+		t.Errorf("expected ErrDuplicateExternalRef on create, got %v", err)
+	}
+	if _, err := service.CreateCompany(ctx, &models.Company{Name: "Other", ExternalRef: "ERP-2"}, models.CreateOptions{}); err != nil {
+		t.Errorf("unexpected create error: %v", err)
+	}
+
+	if _, err := service.UpdateCompany(ctx, &models.CompanyUpdate{ID: uuid.New(), ExternalRef: utils.Ptr("ERP-1")}, models.UpdateOptions{}); !errors.Is(err, e.ErrDuplicateExternalRef) {
+		t.Errorf("expected ErrDuplicateExternalRef on update, got %v", err)
+	}
+	if _, err := service.UpdateCompany(ctx, &models.CompanyUpdate{ID: owner.ID, ExternalRef: utils.Ptr("ERP-1")}, models.UpdateOptions{}); err != nil {
+		t.Errorf("expected a company to keep its own reference, got %v", err)
+	}
+}
+
+func TestCompanyService_GetCompanyByName(t *testing.T) {
+	testID := uuid.New()
+	mockRepo := &MockRepository{
+		getByName: func(_ context.Context, name string) (*models.Company, error) {
+			if name == "acme" {
+				return &models.Company{ID: testID, Name: "Acme"}, nil
+			}
+			return nil, e.ErrNotFound
+		},
+	}
+	service := NewCompanyService(mockRepo, &MockProducer{}, zaptest.NewLogger(t))
+
+	got, err := service.GetCompanyByName(context.Background(), "acme")
+	if err != nil || got.ID != testID {
+		t.Fatalf("expected company %v, got %v, %v", testID, got, err)
+	}
+	if _, err := service.GetCompanyByName(context.Background(), "globex"); !errors.Is(err, e.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := service.GetCompanyByName(context.Background(), ""); !errors.Is(err, e.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+}
+
+func TestCompanyService_CreateCompanySimilarName(t *testing.T) {
+	existing := models.Company{ID: uuid.New(), Name: "Acme Corp"}
+	var gotThreshold float64
+	mockRepo := &MockRepository{
+		companyExistsByName: func(_ context.Context, _ string) (bool, error) { return false, nil },
+		createCompany:       func(_ context.Context, _ *models.Company) error { return nil },
+		findSimilar: func(_ context.Context, name string, threshold float64, _ int) ([]models.Company, error) {
+			gotThreshold = threshold
+			if name == "Acme Crop" {
+				return []models.Company{existing}, nil
+			}
+			return nil, nil
+		},
+	}
+	service := NewCompanyService(mockRepo, &MockProducer{}, zaptest.NewLogger(t), WithNameSimilarity(0.4))
+	ctx := context.Background()
+
+	_, err := service.CreateCompany(ctx, &models.Company{Name: "Acme Crop"}, models.CreateOptions{})
+	if !errors.Is(err, e.ErrDuplicateName) {
+		t.Fatalf("expected ErrDuplicateName, got %v", err)
+	}
+	var similarErr *e.SimilarNameError
+	if !errors.As(err, &similarErr) || len(similarErr.Candidates) != 1 || similarErr.Candidates[0].ID != existing.ID {
+		t.Errorf("expected the existing company as candidate, got %v", err)
+	}
+	if gotThreshold != 0.4 {
+		t.Errorf("expected threshold 0.4, got %v", gotThreshold)
+	}
+
+	if _, err := service.CreateCompany(ctx, &models.Company{Name: "Acme Crop"}, models.CreateOptions{Force: true}); err != nil {
+		t.Errorf("expected forced creation to succeed, got %v", err)
+	}
+	if _, err := service.CreateCompany(ctx, &models.Company{Name: "Globex"}, models.CreateOptions{}); err != nil {
+		t.Errorf("unexpected error for a distinct name: %v", err)
+	}
+}
+
+func TestCompanyService_ValidateOnly(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	mockProducer := &MockProducer{}
+	service := NewCompanyService(repo, mockProducer, zaptest.NewLogger(t))
+	ctx := context.Background()
+
+	existing, err := service.CreateCompany(ctx, &models.Company{Name: "Acme", Employees: 5}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	preview, err := service.CreateCompany(ctx, &models.Company{Name: "Globex", Employees: 20}, models.CreateOptions{ValidateOnly: true})
+	if err != nil {
+		t.Fatalf("unexpected validate-only create error: %v", err)
+	}
+	if preview.EmployeeRange != models.Employees11To50 {
+		t.Errorf("expected the preview to be fully populated, got range %q", preview.EmployeeRange)
+	}
+	if _, err := repo.GetCompany(ctx, preview.ID); !errors.Is(err, e.ErrNotFound) {
+		t.Errorf("expected validate-only create not to persist, got %v", err)
+	}
+
+	_, err = service.CreateCompany(ctx, &models.Company{Name: "Acme"}, models.CreateOptions{ValidateOnly: true})
+	if !errors.Is(err, e.ErrDuplicateName) {
+		t.Errorf("expected uniqueness to be checked, got %v", err)
+	}
+
+	updated, err := service.UpdateCompany(ctx, &models.CompanyUpdate{ID: existing.ID, Employees: utils.Ptr(600)}, models.UpdateOptions{ValidateOnly: true})
+	if err != nil {
+		t.Fatalf("unexpected validate-only update error: %v", err)
+	}
+	if updated.Employees != 600 || updated.EmployeeRange != models.Employees501To1000 {
+		t.Errorf("expected the updated preview, got %+v", updated)
+	}
+	stored, err := repo.GetCompany(ctx, existing.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored.Employees != 5 {
+		t.Errorf("expected validate-only update not to persist, got %d employees", stored.Employees)
+	}
+
+	if _, err := service.UpdateCompany(ctx, &models.CompanyUpdate{ID: uuid.New()}, models.UpdateOptions{ValidateOnly: true}); !errors.Is(err, e.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if len(mockProducer.producedEvents) != 1 {
+		t.Errorf("expected only the real create to emit an event, got %d", len(mockProducer.producedEvents))
+	}
+}
+
+func TestCompanyService_Lifecycle(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	mockProducer := &MockProducer{}
+	service := NewCompanyService(repo, mockProducer, zaptest.NewLogger(t))
+	ctx := context.Background()
+
+	if _, err := service.CreateCompany(ctx, &models.Company{Name: "Suspended", Status: models.StatusSuspended}, models.CreateOptions{}); !errors.Is(err, e.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for a new SUSPENDED company, got %v", err)
+	}
+	active, err := service.CreateCompany(ctx, &models.Company{Name: "Acme"}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if active.Status != models.StatusActive {
+		t.Errorf("expected new companies to be ACTIVE, got %q", active.Status)
+	}
+	draft, err := service.CreateCompany(ctx, &models.Company{Name: "Globex", Status: models.StatusDraft}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := service.SuspendCompany(ctx, draft.ID); !errors.Is(err, e.ErrInvalidStatusTransition) {
+		t.Errorf("expected ErrInvalidStatusTransition suspending a draft, got %v", err)
+	}
+	stored, err := repo.GetCompany(ctx, draft.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored.Status != models.StatusDraft {
+		t.Errorf("expected the rejected transition to be rolled back, got %q", stored.Status)
+	}
+
+	suspended, err := service.SuspendCompany(ctx, active.ID)
+	if err != nil {
+		t.Fatalf("unexpected suspend error: %v", err)
+	}
+	if suspended.Status != models.StatusSuspended {
+		t.Errorf("expected SUSPENDED, got %q", suspended.Status)
+	}
+	event := mockProducer.producedEvents[len(mockProducer.producedEvents)-1]
+	if event.Type != events.CompanyStatusChanged {
+		t.Errorf("expected a %s event, got %s", events.CompanyStatusChanged, event.Type)
+	}
+	want := models.FieldChange{Old: models.StatusActive, New: models.StatusSuspended}
+	if event.Changes["status"] != want {
+		t.Errorf("expected status change %v, got %v", want, event.Changes["status"])
+	}
+
+	if _, err := service.ActivateCompany(ctx, active.ID); err != nil {
+		t.Errorf("unexpected activate error: %v", err)
+	}
+	archived := models.StatusArchived
+	if _, err := service.UpdateCompany(ctx, &models.CompanyUpdate{ID: active.ID, Status: &archived}, models.UpdateOptions{}); err != nil {
+		t.Fatalf("unexpected archive error: %v", err)
+	}
+	if _, err := service.ActivateCompany(ctx, active.ID); !errors.Is(err, e.ErrInvalidStatusTransition) {
+		t.Errorf("expected archived companies to stay archived, got %v", err)
+	}
+
+	unknown := models.CompanyStatus("CLOSED")
+	if _, err := service.UpdateCompany(ctx, &models.CompanyUpdate{ID: active.ID, Status: &unknown}, models.UpdateOptions{}); !errors.Is(err, e.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for an unknown status, got %v", err)
+	}
+
+	renamed, err := service.UpdateCompany(ctx, &models.CompanyUpdate{ID: draft.ID, Name: utils.Ptr("Initech")}, models.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected update error: %v", err)
+	}
+	if renamed.Status != models.StatusDraft {
+		t.Errorf("expected updates without a status to keep it, got %q", renamed.Status)
+	}
+	if event := mockProducer.producedEvents[len(mockProducer.producedEvents)-1]; event.Type != events.CompanyUpdated {
+		t.Errorf("expected a %s event, got %s", events.CompanyUpdated, event.Type)
+	}
+}
+
+func TestCompanyService_ArchiveInactiveCompanies(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	mockProducer := &MockProducer{}
+	service := NewCompanyService(repo, mockProducer, zaptest.NewLogger(t))
+	ctx := context.Background()
+
+	old := time.Now().Add(-90 * 24 * time.Hour)
+	seed := []models.Company{
+		{ID: uuid.New(), Name: "Dormant", Status: models.StatusActive, CreatedAt: old, UpdatedAt: old},
+		{ID: uuid.New(), Name: "Paused", Status: models.StatusSuspended, CreatedAt: old, UpdatedAt: old},
+		{ID: uuid.New(), Name: "Archived", Status: models.StatusArchived, CreatedAt: old, UpdatedAt: old},
+		{ID: uuid.New(), Name: "Recent", Status: models.StatusActive},
+	}
+	for i := range seed {
+		if err := repo.CreateCompany(ctx, &seed[i]); err != nil {
+			t.Fatalf("failed to seed company: %v", err)
+		}
+	}
+
+	archived, err := service.ArchiveInactiveCompanies(ctx, time.Now().Add(-30*24*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if archived != 2 {
+		t.Errorf("expected 2 archived companies, got %d", archived)
+	}
+	for _, c := range seed {
+		stored, err := repo.GetCompany(ctx, c.ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := models.StatusArchived
+		if c.Name == "Recent" {
+			want = models.StatusActive
+		}
+		if stored.Status != want {
+			t.Errorf("expected %s to be %s, got %s", c.Name, want, stored.Status)
+		}
+	}
+	if len(mockProducer.producedEvents) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(mockProducer.producedEvents))
+	}
+	for _, event := range mockProducer.producedEvents {
+		if event.Type != events.CompanyArchived {
+			t.Errorf("expected %s events, got %s", events.CompanyArchived, event.Type)
+		}
+	}
+}
+
+// benchmarkService returns a CompanyService over an in-memory repository
+// mock, so the benchmarks measure the controller alone.
+func benchmarkService() *CompanyService {
+	var mu sync.Mutex
+	companies := make(map[uuid.UUID]models.Company)
+	repo := &MockRepository{
+		companyExistsByName: func(context.Context, string) (bool, error) { return false, nil },
+		createCompany: func(_ context.Context, c *models.Company) error {
+			c.ID = uuid.New()
+			mu.Lock()
+			companies[c.ID] = *c
+			mu.Unlock()
+			return nil
+		},
+		getCompany: func(_ context.Context, id uuid.UUID) (*models.Company, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			c, ok := companies[id]
+			if !ok {
+				return nil, e.ErrNotFound
+			}
+			return &c, nil
+		},
+	}
+	return NewCompanyService(repo, discardProducer{}, zap.NewNop())
+}
+
+// discardProducer drops events so long benchmarks do not accumulate them.
+type discardProducer struct{}
+
+func (discardProducer) Produce(events.Event) {}
+
+func (discardProducer) Replay(context.Context, string, events.Event) error { return nil }
+
+func BenchmarkCompanyService_CreateCompany(b *testing.B) {
+	service := benchmarkService()
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		company := &models.Company{Name: "Bench", Employees: i % 1000, Type: models.Corporations}
+		if _, err := service.CreateCompany(ctx, company, models.CreateOptions{Force: true}); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkCompanyService_GetCompany(b *testing.B) {
+	service := benchmarkService()
+	ctx := context.Background()
+	created, err := service.CreateCompany(ctx, &models.Company{Name: "Bench", Employees: 10}, models.CreateOptions{Force: true})
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := service.GetCompany(ctx, created.ID); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestCompanyService_OwnershipChecks(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	service := NewCompanyService(repo, &MockProducer{}, zaptest.NewLogger(t), WithOwnershipChecks())
+	alice := auth.NewContext(context.Background(), auth.Identity{UserID: "alice"})
+	bob := auth.NewContext(context.Background(), auth.Identity{UserID: "bob"})
+	admin := auth.NewContext(context.Background(), auth.Identity{UserID: "root", Roles: []string{auth.AdminRole}})
+
+	company, err := service.CreateCompany(alice, &models.Company{Name: "Acme"}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := service.CreateCompany(bob, &models.Company{Name: "Globex"}, models.CreateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := service.UpdateCompany(bob, &models.CompanyUpdate{ID: company.ID, Name: utils.Ptr("Stolen")}, models.UpdateOptions{}); !errors.Is(err, e.ErrNotOwner) {
+		t.Errorf("expected ErrNotOwner updating another user's company, got %v", err)
+	}
+	stored, err := repo.GetCompany(context.Background(), company.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored.Name != "Acme" {
+		t.Errorf("expected the rejected update to be rolled back, got %q", stored.Name)
+	}
+	if _, err := service.DeleteCompany(bob, company.ID); !errors.Is(err, e.ErrNotOwner) {
+		t.Errorf("expected ErrNotOwner deleting another user's company, got %v", err)
+	}
+
+	if _, err := service.UpdateCompany(alice, &models.CompanyUpdate{ID: company.ID, Name: utils.Ptr("Acme 2")}, models.UpdateOptions{}); err != nil {
+		t.Errorf("expected the owner to update, got %v", err)
+	}
+	if _, err := service.UpdateCompany(admin, &models.CompanyUpdate{ID: company.ID, Employees: utils.Ptr(5)}, models.UpdateOptions{}); err != nil {
+		t.Errorf("expected admins to update any company, got %v", err)
+	}
+	if _, err := service.UpdateCompany(context.Background(), &models.CompanyUpdate{ID: company.ID, Employees: utils.Ptr(6)}, models.UpdateOptions{}); err != nil {
+		t.Errorf("expected calls without an identity to be unchecked, got %v", err)
+	}
+
+	mine, _, err := service.ListMyCompanies(bob, models.CompanyFilter{}, 0, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mine) != 1 || mine[0].Name != "Globex" {
+		t.Errorf("expected only bob's company, got %+v", mine)
+	}
+	if _, _, err := service.ListMyCompanies(context.Background(), models.CompanyFilter{}, 0, ""); !errors.Is(err, e.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput listing without a caller, got %v", err)
+	}
+
+	if _, err := service.DeleteCompany(alice, company.ID); err != nil {
+		t.Errorf("expected the owner to delete, got %v", err)
+	}
+}
+
+func TestCompanyService_ReadOnly(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	writable := true
+	producer := &MockProducer{}
+	service := NewCompanyService(repo, producer, zaptest.NewLogger(t), WithWritesEnabled(func() bool { return writable }))
+	ctx := context.Background()
+	company, err := service.CreateCompany(ctx, &models.Company{Name: "Acme"}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	writable = false
+	if _, err := service.CreateCompany(ctx, &models.Company{Name: "Globex"}, models.CreateOptions{}); !errors.Is(err, e.ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly creating, got %v", err)
+	}
+	if _, err := service.SuspendCompany(ctx, company.ID); !errors.Is(err, e.ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly suspending, got %v", err)
+	}
+	if _, err := service.DeleteCompany(ctx, company.ID); !errors.Is(err, e.ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly deleting, got %v", err)
+	}
+	if _, err := service.ReplayCompanyEvents(ctx, models.CompanyEventFilter{}, "rebuild"); !errors.Is(err, e.ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly replaying, got %v", err)
+	}
+	if len(producer.producedEvents) != 1 {
+		t.Errorf("expected only the event of the first create, got %d", len(producer.producedEvents))
+	}
+
+	// Reads and validate-only requests are still served.
+	if _, err := service.GetCompany(ctx, company.ID); err != nil {
+		t.Errorf("expected reads to succeed, got %v", err)
+	}
+	if _, err := service.CreateCompany(ctx, &models.Company{Name: "Globex"}, models.CreateOptions{ValidateOnly: true}); err != nil {
+		t.Errorf("expected validate-only requests to succeed, got %v", err)
+	}
+
+	writable = true
+	if _, err := service.DeleteCompany(ctx, company.ID); err != nil {
+		t.Errorf("expected writes to resume, got %v", err)
+	}
+}
+
+func TestCompanyService_Quotas(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	service := NewCompanyService(repo, &MockProducer{}, zaptest.NewLogger(t),
+		WithQuotas(repo, models.TenantQuota{MaxCompanies: 2}))
+	now := time.Date(2025, 3, 1, 12, 0, 10, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	acme := auth.NewContext(context.Background(), auth.Identity{UserID: "alice", TenantID: "acme"})
+	admin := auth.NewContext(context.Background(), auth.Identity{UserID: "root", Roles: []string{auth.AdminRole}})
+
+	first, err := service.CreateCompany(acme, &models.Company{Name: "Acme 1"}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.TenantID != "acme" {
+		t.Errorf("expected the company to belong to the caller's tenant, got %q", first.TenantID)
+	}
+	if _, err := service.CreateCompany(acme, &models.Company{Name: "Acme 2"}, models.CreateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = service.CreateCompany(acme, &models.Company{Name: "Acme 3"}, models.CreateOptions{})
+	var quotaErr *e.QuotaExceededError
+	if !errors.As(err, &quotaErr) || quotaErr.Quota != e.QuotaCompanies || quotaErr.Limit != 2 {
+		t.Fatalf("expected the default company quota to be exceeded, got %v", err)
+	}
+	if _, err := service.CreateCompany(admin, &models.Company{Name: "Untenanted"}, models.CreateOptions{}); err != nil {
+		t.Errorf("expected callers without a tenant to be unlimited, got %v", err)
+	}
+
+	quota, err := service.UpdateTenantQuota(admin, &models.TenantQuota{TenantID: "acme", MaxCompanies: 3, MaxMutationsPerMinute: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if quota.UpdatedBy != "root" {
+		t.Errorf("expected the admin to be recorded, got %q", quota.UpdatedBy)
+	}
+	warnCtx, warnings := RecordQuotaWarnings(acme)
+	if _, err := service.CreateCompany(warnCtx, &models.Company{Name: "Acme 3"}, models.CreateOptions{}); err != nil {
+		t.Fatalf("expected the raised quota to apply, got %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := service.SuspendCompany(warnCtx, first.ID); err != nil && !errors.Is(err, e.ErrInvalidStatusTransition) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if got, want := warnings(), []QuotaWarning{
+		{Quota: e.QuotaCompanies, Used: 3, Limit: 3},
+		{Quota: e.QuotaMutationsPerMinute, Used: 5, Limit: 5},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected warnings %+v, got %+v", want, got)
+	}
+	_, err = service.DeleteCompany(acme, first.ID)
+	if !errors.As(err, &quotaErr) || quotaErr.Quota != e.QuotaMutationsPerMinute || quotaErr.RetryAfter != 50*time.Second {
+		t.Fatalf("expected the mutation rate to be exceeded for 50s, got %v", err)
+	}
+
+	quota, usage, err := service.GetTenantQuota(admin, "acme")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if quota.MaxMutationsPerMinute != 5 || usage.Companies != 3 || usage.MutationsThisMinute != 6 {
+		t.Errorf("unexpected quota %+v and usage %+v", quota, usage)
+	}
+	quota, _, err = service.GetTenantQuota(admin, "globex")
+	if err != nil || quota.MaxCompanies != 2 || quota.TenantID != "globex" {
+		t.Errorf("expected the defaults for a tenant without a quota, got %+v, %v", quota, err)
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := service.DeleteCompany(acme, first.ID); err != nil {
+		t.Errorf("expected the rate to reset the next minute, got %v", err)
+	}
+	if _, err := service.UpdateTenantQuota(admin, &models.TenantQuota{TenantID: "acme", MaxCompanies: -1}); !errors.Is(err, e.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for a negative limit, got %v", err)
+	}
+}
+
+// TestCompanyService_ConcurrentQuota verifies concurrent creates of a tenant
+// cannot go past its company quota together.
+func TestCompanyService_ConcurrentQuota(t *testing.T) {
+	repo, err := db.NewRepository(&db.Config{Driver: "sqlite", Path: filepath.Join(t.TempDir(), "company.db")})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	defer repo.Close()
+	service := NewCompanyService(repo, &MockProducer{}, zaptest.NewLogger(t),
+		WithQuotas(slowCountStore{repo}, models.TenantQuota{MaxCompanies: 3}))
+	acme := auth.NewContext(context.Background(), auth.Identity{UserID: "alice", TenantID: "acme"})
+
+	const creates = 10
+	errs := make(chan error, creates)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range creates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := service.CreateCompany(acme, &models.Company{Name: fmt.Sprintf("Acme %d", i)}, models.CreateOptions{})
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		var quotaErr *e.QuotaExceededError
+		switch {
+		case err == nil:
+			created++
+		case !errors.As(err, &quotaErr):
+			t.Errorf("expected the company quota to be exceeded, got %v", err)
+		}
+	}
+	if created != 3 {
+		t.Errorf("expected 3 companies to be created, got %d", created)
+	}
+	if count, err := repo.CountTenantCompanies(context.Background(), "acme"); err != nil || count != 3 {
+		t.Errorf("expected the tenant to have 3 companies, got %d, %v", count, err)
+	}
+}
+
+// TestCompanyService_DuplicateNameRace verifies a name taken after the check
+// of CreateCompany, or by a rename, yields ErrDuplicateName from the unique
+// index rather than an internal error.
+func TestCompanyService_DuplicateNameRace(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	service := NewCompanyService(racedNameStore{repo}, &MockProducer{}, zaptest.NewLogger(t))
+	ctx := context.Background()
+
+	if _, err := service.CreateCompany(ctx, &models.Company{Name: "Acme"}, models.CreateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = service.CreateCompany(ctx, &models.Company{Name: "Acme"}, models.CreateOptions{})
+	if !errors.Is(err, e.ErrDuplicateName) {
+		t.Errorf("expected ErrDuplicateName past the name check, got %v", err)
+	}
+
+	initech, err := service.CreateCompany(ctx, &models.Company{Name: "Initech"}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = service.UpdateCompany(ctx, &models.CompanyUpdate{ID: initech.ID, Name: utils.Ptr("Acme")}, models.UpdateOptions{})
+	if !errors.Is(err, e.ErrDuplicateName) {
+		t.Errorf("expected ErrDuplicateName for a rename collision, got %v", err)
+	}
+}
+
+// racedNameStore reports every name as free, as when a concurrent create
+// takes it between the check and the insert.
+type racedNameStore struct {
+	*db.Repository
+}
+
+func (racedNameStore) CompanyExistsByName(context.Context, string) (bool, error) {
+	return false, nil
+}
+
+// TestCompanyService_DuplicateExternalRefRace verifies an external
+// reference taken after the check of CreateCompany or UpdateCompany yields
+// ErrDuplicateExternalRef from the unique index rather than an internal
+// error.
+func TestCompanyService_DuplicateExternalRefRace(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	service := NewCompanyService(racedExternalRefStore{repo}, &MockProducer{}, zaptest.NewLogger(t))
+	ctx := context.Background()
+
+	if _, err := service.CreateCompany(ctx, &models.Company{Name: "Acme", ExternalRef: "ERP-1"}, models.CreateOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = service.CreateCompany(ctx, &models.Company{Name: "Globex", ExternalRef: "ERP-1"}, models.CreateOptions{})
+	if !errors.Is(err, e.ErrDuplicateExternalRef) {
+		t.Errorf("expected ErrDuplicateExternalRef past the reference check on create, got %v", err)
+	}
+
+	initech, err := service.CreateCompany(ctx, &models.Company{Name: "Initech"}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = service.UpdateCompany(ctx, &models.CompanyUpdate{ID: initech.ID, ExternalRef: utils.Ptr("ERP-1")}, models.UpdateOptions{})
+	if !errors.Is(err, e.ErrDuplicateExternalRef) {
+		t.Errorf("expected ErrDuplicateExternalRef past the reference check on update, got %v", err)
+	}
+}
+
+// racedExternalRefStore reports every external reference as free, as when
+// a concurrent write takes it between the check and the write.
+type racedExternalRefStore struct {
+	*db.Repository
+}
+
+func (racedExternalRefStore) GetCompanyByExternalRef(context.Context, string) (*models.Company, error) {
+	return nil, e.ErrCompanyNotFound
+}
+
+// slowCountStore widens the window between counting the companies of a
+// tenant and creating one, in which a concurrent create could count too.
+type slowCountStore struct {
+	*db.Repository
+}
+
+func (s slowCountStore) CountTenantCompanies(ctx context.Context, tenant string) (int64, error) {
+	count, err := s.Repository.CountTenantCompanies(ctx, tenant)
+	time.Sleep(20 * time.Millisecond)
+	return count, err
+}
+
+// recordingEnricher records the companies handed to it.
+type recordingEnricher struct {
+	companies []models.Company
+}
+
+func (r *recordingEnricher) Enqueue(company models.Company) {
+	r.companies = append(r.companies, company)
+}
+
+func TestCompanyService_Enrichment(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	mockProducer := &MockProducer{}
+	enricher := &recordingEnricher{}
+	service := NewCompanyService(repo, mockProducer, zaptest.NewLogger(t), WithEnricher(enricher))
+	ctx := context.Background()
+
+	if _, err := service.CreateCompany(ctx, &models.Company{Name: "Dry"}, models.CreateOptions{ValidateOnly: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	company, err := service.CreateCompany(ctx, &models.Company{Name: "Acme", Description: "Rockets", Type: models.Corporations}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(enricher.companies) != 1 || enricher.companies[0].ID != company.ID {
+		t.Fatalf("expected only the created company to be enqueued, got %+v", enricher.companies)
+	}
+
+	if _, err := service.ApplyEnrichment(ctx, company.ID, models.Enrichment{Type: utils.Ptr(models.CompanyType("LLC"))}); !errors.Is(err, e.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for an unknown type, got %v", err)
+	}
+
+	enriched, err := service.ApplyEnrichment(ctx, company.ID, models.Enrichment{
+		Description: utils.Ptr("Anvils"),
+		Employees:   utils.Ptr(120),
+		Type:        utils.Ptr(models.NonProfit),
+		Registered:  utils.Ptr(true),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if enriched.Description != "Rockets" || enriched.Type != models.Corporations {
+		t.Errorf("expected values set by users to be kept, got %+v", enriched)
+	}
+	if enriched.Employees != 120 || enriched.EmployeeRange != models.Employees51To200 || !enriched.Registered {
+		t.Errorf("expected empty attributes to be filled in, got %+v", enriched)
+	}
+	if enriched.UpdatedBy != "enrichment" {
+		t.Errorf("expected UpdatedBy %q, got %q", "enrichment", enriched.UpdatedBy)
+	}
+	event := mockProducer.producedEvents[len(mockProducer.producedEvents)-1]
+	if event.Type != events.CompanyEnriched || event.Actor != "enrichment" {
+		t.Errorf("expected a CompanyEnriched event by enrichment, got %q by %q", event.Type, event.Actor)
+	}
+	if len(event.Changes) != 3 {
+		t.Errorf("expected employees, employee_range and registered changes, got %v", event.Changes)
+	}
+
+	produced := len(mockProducer.producedEvents)
+	if _, err := service.ApplyEnrichment(ctx, company.ID, models.Enrichment{Employees: utils.Ptr(500)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mockProducer.producedEvents) != produced {
+		t.Errorf("expected no event when nothing is filled in")
+	}
+
+	if _, err := service.ApplyEnrichment(ctx, uuid.New(), models.Enrichment{Employees: utils.Ptr(5)}); !errors.Is(err, e.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// reportStoreFunc is a ReportStore running f.
+type reportStoreFunc func(name string, params map[string]interface{}, dest interface{}) error
+
+func (f reportStoreFunc) RunNamedQuery(_ context.Context, name string, params map[string]interface{}, dest interface{}) error {
+	return f(name, params, dest)
+}
+
+func TestCompanyService_CountCompaniesPerMonth(t *testing.T) {
+	var gotName string
+	var gotParams map[string]interface{}
+	store := reportStoreFunc(func(name string, params map[string]interface{}, dest interface{}) error {
+		gotName, gotParams = name, params
+		*dest.(*[]models.MonthlyCount) = []models.MonthlyCount{{Month: "2025-02", Key: "DE", Count: 3}}
+		return nil
+	})
+	service := NewCompanyService(&MockRepository{}, &MockProducer{}, zaptest.NewLogger(t), WithReports(store))
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	counts, err := service.CountCompaniesPerMonth(ctx, models.MonthlyCountFilter{MetadataKey: "country", TenantID: "acme"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(counts) != 1 || counts[0].Count != 3 {
+		t.Errorf("unexpected counts %+v", counts)
+	}
+	want := map[string]interface{}{"since": now.AddDate(-1, 0, 0), "until": now, "tenant": "acme", "key": "country"}
+	if gotName != "companies_per_month_by_metadata" || !reflect.DeepEqual(gotParams, want) {
+		t.Errorf("expected the last 12 months to be counted per country, ran %s with %v", gotName, gotParams)
+	}
+
+	if _, err := service.CountCompaniesPerMonth(ctx, models.MonthlyCountFilter{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotName != "companies_per_month_by_type" {
+		t.Errorf("expected the companies to be counted per type, ran %s", gotName)
+	}
+
+	_, err = service.CountCompaniesPerMonth(ctx, models.MonthlyCountFilter{Since: now, Until: now.Add(-time.Hour)})
+	if !errors.Is(err, e.ErrInvalidInput) {
+		t.Errorf("expected an inverted range to be rejected, got %v", err)
+	}
+	_, err = service.CountCompaniesPerMonth(ctx, models.MonthlyCountFilter{MetadataKey: "$.country"})
+	if !errors.Is(err, e.ErrInvalidInput) {
+		t.Errorf("expected an invalid metadata key to be rejected, got %v", err)
+	}
+}
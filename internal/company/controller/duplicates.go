@@ -0,0 +1,357 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/db"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/pkg/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// DuplicateStore keeps the duplicate suggestions and moves the records of
+// merged companies; *db.Repository implements it.
+type DuplicateStore interface {
+	SyncDuplicateSuggestions(ctx context.Context, found []models.DuplicateSuggestion, start time.Time) (int, error)
+	ListDuplicateSuggestions(ctx context.Context, filter models.DuplicateSuggestionFilter, offset, limit int) ([]models.DuplicateSuggestion, error)
+	GetDuplicateSuggestionForUpdate(ctx context.Context, id uuid.UUID) (*models.DuplicateSuggestion, error)
+	ResolveDuplicateSuggestion(ctx context.Context, id uuid.UUID, status models.DuplicateStatus, actor string, at time.Time) error
+	DeleteOpenDuplicateSuggestions(ctx context.Context, company uuid.UUID) error
+	MoveCompanyRecords(ctx context.Context, from, to uuid.UUID, actor string) (*models.Company, error)
+}
+
+const (
+	// defaultDuplicateSimilarity is the name similarity at which detection
+	// suggests a pair unless configured otherwise.
+	defaultDuplicateSimilarity = 0.6
+	// duplicateBatchSize is the number of companies DetectDuplicates reads
+	// at a time.
+	duplicateBatchSize = 100
+	// maxDuplicateCandidates bounds the similar names compared per company.
+	maxDuplicateCandidates = 10
+)
+
+// Metadata entries compared by duplicate detection.
+const (
+	countryMetadataKey = "country"
+	websiteMetadataKey = "website"
+)
+
+// WithDuplicates keeps duplicate suggestions in store and makes
+// DetectDuplicates suggest pairs whose names have a trigram similarity of
+// at least similarity (0-1); 0 selects 0.6.
+func WithDuplicates(store DuplicateStore, similarity float64) ServiceOption {
+	return func(s *CompanyService) {
+		s.duplicates = store
+		s.duplicateSimilarity = similarity
+		if similarity <= 0 {
+			s.duplicateSimilarity = defaultDuplicateSimilarity
+		}
+	}
+}
+
+// DetectDuplicates compares every live company with those of the same
+// tenant having a similar name and records the likely duplicate pairs as
+// suggestions, returning the number of pairs found. Pairs whose "country"
+// metadata entries differ are left out; a shared country or website is
+// recorded with the pair. Open suggestions no longer found, e.g. because a
+// company was renamed or deleted, are removed.
+func (s *CompanyService) DetectDuplicates(ctx context.Context) (int, error) {
+	if s.duplicates == nil {
+		return 0, fmt.Errorf("duplicate detection is not enabled")
+	}
+	start := s.now()
+	pairs := make(map[[2]uuid.UUID]models.DuplicateSuggestion)
+	for offset := 0; ; offset += duplicateBatchSize {
+		batch, err := s.repo.ListCompanies(ctx, models.CompanyFilter{}, offset, duplicateBatchSize)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list companies: %w", err)
+		}
+		for _, company := range batch {
+			candidates, err := s.repo.FindSimilarCompanies(ctx, company.Name, s.duplicateSimilarity, maxDuplicateCandidates)
+			if err != nil {
+				return 0, fmt.Errorf("failed to find companies similar to %s: %w", company.ID, err)
+			}
+			for _, candidate := range candidates {
+				suggestion, ok := duplicatePair(company, candidate)
+				if !ok {
+					continue
+				}
+				pairs[[2]uuid.UUID{suggestion.CompanyID, suggestion.DuplicateID}] = suggestion
+			}
+		}
+		if len(batch) < duplicateBatchSize {
+			break
+		}
+	}
+	found := make([]models.DuplicateSuggestion, 0, len(pairs))
+	for _, suggestion := range pairs {
+		found = append(found, suggestion)
+	}
+	added, err := s.duplicates.SyncDuplicateSuggestions(ctx, found, start)
+	if err != nil {
+		return 0, fmt.Errorf("failed to store duplicate suggestions: %w", err)
+	}
+	s.logger.Info("Detected duplicate companies", zap.Int("pairs", len(found)), zap.Int("new", added))
+	return len(found), nil
+}
+
+// duplicatePair returns the suggestion for a and b, the company created
+// first on the CompanyID side, and false when they are not a candidate
+// pair: the same company, of different tenants or of different countries.
+func duplicatePair(a, b models.Company) (models.DuplicateSuggestion, bool) {
+	if a.ID == b.ID || a.TenantID != b.TenantID {
+		return models.DuplicateSuggestion{}, false
+	}
+	countryA, countryB := strings.ToLower(strings.TrimSpace(a.Metadata[countryMetadataKey])), strings.ToLower(strings.TrimSpace(b.Metadata[countryMetadataKey]))
+	if countryA != "" && countryB != "" && countryA != countryB {
+		return models.DuplicateSuggestion{}, false
+	}
+	if b.CreatedAt.Before(a.CreatedAt) || (b.CreatedAt.Equal(a.CreatedAt) && b.ID.String() < a.ID.String()) {
+		a, b = b, a
+	}
+	websiteA, websiteB := websiteHost(a.Metadata[websiteMetadataKey]), websiteHost(b.Metadata[websiteMetadataKey])
+	return models.DuplicateSuggestion{
+		CompanyID:      a.ID,
+		DuplicateID:    b.ID,
+		TenantID:       a.TenantID,
+		NameSimilarity: db.NameSimilarity(a.Name, b.Name),
+		SameCountry:    countryA != "" && countryA == countryB,
+		SameWebsite:    websiteA != "" && websiteA == websiteB,
+	}, true
+}
+
+// websiteHost returns the lower-cased host of a website, with or without
+// scheme, dropping a leading "www.", or "" when there is none.
+func websiteHost(website string) string {
+	website = strings.ToLower(strings.TrimSpace(website))
+	if website == "" {
+		return ""
+	}
+	if !strings.Contains(website, "://") {
+		website = "https://" + website
+	}
+	u, err := url.Parse(website)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
+
+// ListDuplicateSuggestions returns a page of suggestions matching filter,
+// pairs sharing a website first, then by decreasing name similarity, and
+// the token of the next page, which is empty on the last page. A pageSize
+// of 0 selects defaultPageSize.
+func (s *CompanyService) ListDuplicateSuggestions(ctx context.Context, filter models.DuplicateSuggestionFilter, pageSize int, pageToken string) ([]models.DuplicateSuggestion, string, error) {
+	if filter.Status != "" && !filter.Status.Valid() {
+		return nil, "", e.Invalid("status", e.CodeDuplicateStatusUnknown, fmt.Sprintf("unknown status %.32q", filter.Status))
+	}
+	switch {
+	case pageSize < 0:
+		return nil, "", e.Invalid("page_size", e.CodePageSizeInvalid, "negative page size")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+	offset, err := decodePageToken(pageToken)
+	if err != nil {
+		return nil, "", err
+	}
+	if s.duplicates == nil {
+		return nil, "", fmt.Errorf("duplicate detection is not enabled")
+	}
+	suggestions, err := s.duplicates.ListDuplicateSuggestions(ctx, filter, offset, pageSize+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list duplicate suggestions: %w", err)
+	}
+	if len(suggestions) <= pageSize {
+		return suggestions, "", nil
+	}
+	return suggestions[:pageSize], encodePageToken(offset + pageSize), nil
+}
+
+// ResolveDuplicateSuggestion resolves an open suggestion. Dismissing it
+// keeps the pair from being suggested again; merging it merges the pair
+// with MergeCompanies into keep, which must be one of the pair and defaults
+// to the company created first. It returns the resolved suggestion and,
+// when merging, the company kept.
+func (s *CompanyService) ResolveDuplicateSuggestion(ctx context.Context, id uuid.UUID, resolution models.DuplicateResolution, keep uuid.UUID) (*models.DuplicateSuggestion, *models.Company, error) {
+	status := models.DuplicateMerged
+	switch resolution {
+	case models.ResolveMerge:
+	case models.ResolveDismiss:
+		status = models.DuplicateDismissed
+	default:
+		return nil, nil, e.Invalid("resolution", e.CodeDuplicateResolutionUnknown, fmt.Sprintf("unknown resolution %.32q", resolution))
+	}
+	if err := s.checkWritable(); err != nil {
+		return nil, nil, err
+	}
+	if s.duplicates == nil {
+		return nil, nil, fmt.Errorf("duplicate detection is not enabled")
+	}
+	actor := actorFromContext(ctx)
+	var (
+		suggestion *models.DuplicateSuggestion
+		kept       *models.Company
+	)
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if suggestion, err = s.duplicates.GetDuplicateSuggestionForUpdate(ctx, id); err != nil {
+			return err
+		}
+		if suggestion.Status != models.DuplicateOpen {
+			return e.Newf(e.CodeDuplicateSuggestionResolved, "suggestion %s is %s", id, suggestion.Status)
+		}
+		now := s.now()
+		if err := s.duplicates.ResolveDuplicateSuggestion(ctx, id, status, actor, now); err != nil {
+			return err
+		}
+		suggestion.Status, suggestion.ResolvedBy, suggestion.ResolvedAt, suggestion.UpdatedAt = status, actor, &now, now
+		if status != models.DuplicateMerged {
+			return nil
+		}
+		merged := suggestion.DuplicateID
+		switch keep {
+		case uuid.Nil, suggestion.CompanyID:
+			keep = suggestion.CompanyID
+		case suggestion.DuplicateID:
+			merged = suggestion.CompanyID
+		default:
+			return e.Invalid("keep_company_id", e.CodeMergeKeepInvalid, "the company to keep is not one of the pair")
+		}
+		kept, err = s.MergeCompanies(ctx, keep, merged)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, e.ErrNotFound) || errors.Is(err, e.ErrInvalidInput) || errors.Is(err, e.ErrNotOwner) || errors.Is(err, e.ErrValidationFailed) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("failed to resolve duplicate suggestion: %w", err)
+	}
+	return suggestion, kept, nil
+}
+
+// MergeCompanies merges company merged into company keep of the same
+// tenant: the employees and notes of merged move to keep, keep gets the
+// description, contact email, employee count, registration and metadata
+// entries of merged it lacks, and merged is deleted. It publishes a
+// CompanyUpdated event for keep, when it changed, and a CompanyDeleted
+// event for merged naming keep under the "merged_into" change, and returns
+// keep as merged. With ownership checks enabled, callers must own both.
+func (s *CompanyService) MergeCompanies(ctx context.Context, keep, merged uuid.UUID) (*models.Company, error) {
+	var invalid e.ValidationError
+	if keep == uuid.Nil {
+		invalid.Add("id", e.CodeCompanyIDInvalid, "invalid company ID")
+	}
+	if merged == uuid.Nil {
+		invalid.Add("merged_id", e.CodeCompanyIDInvalid, "invalid company ID")
+	}
+	if keep != uuid.Nil && keep == merged {
+		invalid.Add("merged_id", e.CodeMergeSameCompany, "a company cannot be merged into itself")
+	}
+	if err := invalid.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := s.checkQuota(ctx); err != nil {
+		return nil, err
+	}
+	if s.duplicates == nil {
+		return nil, fmt.Errorf("merging companies is not enabled")
+	}
+	actor := actorFromContext(ctx)
+	var updated *models.Company
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		before, err := s.repo.GetCompanyForUpdate(ctx, keep)
+		if err != nil {
+			return err
+		}
+		gone, err := s.repo.GetCompanyForUpdate(ctx, merged)
+		if err != nil {
+			return err
+		}
+		if owner, ok := s.requiredOwner(ctx); ok && (before.CreatedBy != owner || gone.CreatedBy != owner) {
+			return e.ErrNotOwner
+		}
+		if before.TenantID != gone.TenantID {
+			return e.Newf(e.CodeMergeTenantMismatch, "companies of tenants %q and %q", before.TenantID, gone.TenantID)
+		}
+		if updated, err = s.duplicates.MoveCompanyRecords(ctx, merged, keep, actor); err != nil {
+			return err
+		}
+		if update := mergeUpdate(updated, gone); update != nil {
+			update.UpdatedBy = actor
+			if _, updated, err = s.updateReturning(ctx, update); err != nil {
+				return err
+			}
+		}
+		if err := s.repo.DeleteCompany(ctx, merged); err != nil {
+			return err
+		}
+		if err := s.duplicates.DeleteOpenDuplicateSuggestions(ctx, merged); err != nil {
+			return err
+		}
+		if changes := diffCompanies(before, updated); len(changes) > 0 {
+			if err := s.recordEvent(ctx, events.Event{Type: events.CompanyUpdated, Company: updated, Actor: actor, Changes: changes}); err != nil {
+				return err
+			}
+		}
+		return s.recordEvent(ctx, events.Event{
+			Type:    events.CompanyDeleted,
+			Company: gone,
+			Actor:   actor,
+			Changes: map[string]models.FieldChange{"merged_into": {New: keep.String()}},
+		})
+	})
+	if err != nil {
+		if errors.Is(err, e.ErrNotFound) || errors.Is(err, e.ErrInvalidInput) || errors.Is(err, e.ErrNotOwner) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to merge companies: %w", err)
+	}
+	return updated, nil
+}
+
+// mergeUpdate returns the update filling in the attributes of keep that
+// merged has and keep lacks, or nil when there are none. Employee counts
+// are only taken when keep has no employee records deriving its own.
+func mergeUpdate(keep, merged *models.Company) *models.CompanyUpdate {
+	update := &models.CompanyUpdate{ID: keep.ID}
+	changed := false
+	if keep.Description == "" && merged.Description != "" {
+		update.Description, changed = utils.Ptr(merged.Description), true
+	}
+	if keep.ContactEmail == "" && merged.ContactEmail != "" {
+		update.ContactEmail, changed = utils.Ptr(merged.ContactEmail), true
+	}
+	if keep.Employees == 0 && merged.Employees > 0 {
+		update.Employees, update.EmployeeRange, changed = utils.Ptr(merged.Employees), utils.Ptr(models.EmployeeRangeFor(merged.Employees)), true
+	}
+	if !keep.Registered && merged.Registered {
+		update.Registered, changed = utils.Ptr(true), true
+	}
+	for key, value := range merged.Metadata {
+		if _, ok := keep.Metadata[key]; !ok {
+			if update.Metadata == nil {
+				update.Metadata = models.Metadata{}
+			}
+			update.Metadata[key], changed = value, true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return update
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/gartstein/xm/internal/company/db"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"gorm.io/driver/sqlite"
+)
+
+func TestCompanyService_Duplicates(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	require.NoError(t, err)
+	producer := &MockProducer{}
+	service := NewCompanyService(repo, producer, zaptest.NewLogger(t), WithDuplicates(repo, 0))
+	acme := auth.NewContext(context.Background(), auth.Identity{UserID: "alice", TenantID: "acme", Roles: []string{auth.AdminRole}})
+	globex := auth.NewContext(context.Background(), auth.Identity{UserID: "bob", TenantID: "globex"})
+	create := func(ctx context.Context, c models.Company) *models.Company {
+		t.Helper()
+		created, err := service.CreateCompany(ctx, &c, models.CreateOptions{})
+		require.NoError(t, err)
+		return created
+	}
+	original := create(acme, models.Company{Name: "Acme Corp", Metadata: models.Metadata{"country": "US", "website": "https://www.acme.com"}})
+	duplicate := create(acme, models.Company{Name: "ACME Corp.", Description: "Anvils", Metadata: models.Metadata{"country": "us", "website": "acme.com/about", "vat": "US123"}})
+	create(acme, models.Company{Name: "Acme Corp,", Metadata: models.Metadata{"country": "DE"}})
+	other := create(globex, models.Company{Name: "Acme Corp Ltd"})
+	_, err = service.CreateEmployee(acme, &models.Employee{CompanyID: duplicate.ID, Name: "Wile E."})
+	require.NoError(t, err)
+	_, err = service.AddCompanyNote(acme, &models.CompanyNote{CompanyID: duplicate.ID, Body: "Same as Acme Corp"})
+	require.NoError(t, err)
+
+	pairs, err := service.DetectDuplicates(acme)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pairs, "companies of other countries or tenants should not be paired")
+	suggestions, next, err := service.ListDuplicateSuggestions(acme, models.DuplicateSuggestionFilter{Status: models.DuplicateOpen}, 0, "")
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, suggestions, 1)
+	suggestion := suggestions[0]
+	assert.Equal(t, original.ID, suggestion.CompanyID, "the company created first should be kept by default")
+	assert.Equal(t, duplicate.ID, suggestion.DuplicateID)
+	assert.Equal(t, "acme", suggestion.TenantID)
+	assert.True(t, suggestion.SameCountry)
+	assert.True(t, suggestion.SameWebsite)
+	assert.InDelta(t, 1, suggestion.NameSimilarity, 0.001)
+
+	pairs, err = service.DetectDuplicates(acme)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pairs)
+	again, _, err := service.ListDuplicateSuggestions(acme, models.DuplicateSuggestionFilter{CompanyID: duplicate.ID}, 0, "")
+	require.NoError(t, err)
+	require.Len(t, again, 1)
+	assert.Equal(t, suggestion.ID, again[0].ID, "a pair found again should keep its suggestion")
+
+	producer.producedEvents = nil
+	resolved, kept, err := service.ResolveDuplicateSuggestion(acme, suggestion.ID, models.ResolveMerge, uuid.Nil)
+	require.NoError(t, err)
+	assert.Equal(t, models.DuplicateMerged, resolved.Status)
+	assert.Equal(t, "alice", resolved.ResolvedBy)
+	assert.Equal(t, original.ID, kept.ID)
+	assert.Equal(t, "Anvils", kept.Description)
+	assert.Equal(t, 1, kept.Employees, "the employees should move to the company kept")
+	assert.Equal(t, models.Metadata{"country": "US", "website": "https://www.acme.com", "vat": "US123"}, kept.Metadata)
+	notes, err := repo.ListCompanyNotes(acme, original.ID, 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, notes, 1)
+	_, err = service.GetCompany(acme, duplicate.ID)
+	assert.ErrorIs(t, err, e.ErrNotFound)
+	require.Len(t, producer.producedEvents, 2)
+	assert.Equal(t, events.CompanyUpdated, producer.producedEvents[0].Type)
+	assert.Equal(t, events.CompanyDeleted, producer.producedEvents[1].Type)
+	assert.Equal(t, original.ID.String(), producer.producedEvents[1].Changes["merged_into"].New)
+
+	_, _, err = service.ResolveDuplicateSuggestion(acme, suggestion.ID, models.ResolveDismiss, uuid.Nil)
+	assert.Equal(t, e.CodeDuplicateSuggestionResolved, e.CodeOf(err))
+	_, _, err = service.ResolveDuplicateSuggestion(acme, uuid.New(), models.ResolveDismiss, uuid.Nil)
+	assert.Equal(t, e.CodeDuplicateSuggestionNotFound, e.CodeOf(err))
+	_, _, err = service.ResolveDuplicateSuggestion(acme, suggestion.ID, "IGNORE", uuid.Nil)
+	assert.Equal(t, e.CodeDuplicateResolutionUnknown, e.CodeOf(err))
+
+	_, err = service.MergeCompanies(acme, original.ID, original.ID)
+	assert.Equal(t, e.CodeMergeSameCompany, e.CodeOf(err))
+	_, err = service.MergeCompanies(acme, original.ID, other.ID)
+	assert.Equal(t, e.CodeMergeTenantMismatch, e.CodeOf(err))
+}
+
+func TestCompanyService_DismissDuplicate(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	require.NoError(t, err)
+	service := NewCompanyService(repo, &MockProducer{}, zaptest.NewLogger(t), WithDuplicates(repo, 0))
+	ctx := auth.NewContext(context.Background(), auth.Identity{UserID: "alice"})
+	for _, name := range []string{"Globex", "Globex Inc"} {
+		_, err := service.CreateCompany(ctx, &models.Company{Name: name}, models.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	_, err = service.DetectDuplicates(ctx)
+	require.NoError(t, err)
+	suggestions, _, err := service.ListDuplicateSuggestions(ctx, models.DuplicateSuggestionFilter{}, 0, "")
+	require.NoError(t, err)
+	require.Len(t, suggestions, 1)
+	_, _, err = service.ResolveDuplicateSuggestion(ctx, suggestions[0].ID, models.ResolveMerge, uuid.New())
+	assert.Equal(t, e.CodeMergeKeepInvalid, e.CodeOf(err))
+	resolved, kept, err := service.ResolveDuplicateSuggestion(ctx, suggestions[0].ID, models.ResolveDismiss, uuid.Nil)
+	require.NoError(t, err)
+	assert.Equal(t, models.DuplicateDismissed, resolved.Status)
+	assert.Nil(t, kept)
+
+	_, err = service.DetectDuplicates(ctx)
+	require.NoError(t, err)
+	open, _, err := service.ListDuplicateSuggestions(ctx, models.DuplicateSuggestionFilter{Status: models.DuplicateOpen}, 0, "")
+	require.NoError(t, err)
+	assert.Empty(t, open, "a dismissed pair should not be suggested again")
+
+	_, _, err = service.ListDuplicateSuggestions(ctx, models.DuplicateSuggestionFilter{Status: "CLOSED"}, 0, "")
+	assert.Equal(t, e.CodeDuplicateStatusUnknown, e.CodeOf(err))
+}
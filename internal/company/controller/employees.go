@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gartstein/xm/internal/company/db"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+)
+
+// maxEmployeeFieldLength limits employee names and titles.
+const maxEmployeeFieldLength = 100
+
+// CreateEmployee adds an employee to the company employee.CompanyID and
+// returns it. The company's employee count is set to the number of its
+// employee records in the same transaction, publishing a CompanyUpdated
+// event when it changed. With ownership checks enabled, callers not owning
+// the company get ErrNotOwner.
+func (s *CompanyService) CreateEmployee(ctx context.Context, employee *models.Employee) (*models.Employee, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := s.checkQuota(ctx); err != nil {
+		return nil, err
+	}
+	var invalid e.ValidationError
+	validateEmployee(&invalid, &employee.Name, &employee.Title, &employee.Email)
+	if err := invalid.Err(); err != nil {
+		return nil, err
+	}
+
+	actor := actorFromContext(ctx)
+	employee.ID = s.newID()
+	employee.CreatedBy = actor
+	employee.UpdatedBy = actor
+	err := s.changeEmployees(ctx, employee.CompanyID, func(tx *db.Repository) error {
+		return tx.CreateEmployee(ctx, employee)
+	})
+	if err != nil {
+		if errors.Is(err, e.ErrNotFound) || errors.Is(err, e.ErrNotOwner) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create employee: %w", err)
+	}
+	return employee, nil
+}
+
+// GetEmployee retrieves an employee of a company by ID.
+func (s *CompanyService) GetEmployee(ctx context.Context, company, id uuid.UUID) (*models.Employee, error) {
+	employee, err := s.repo.GetEmployee(ctx, company, id)
+	if err != nil {
+		if errors.Is(err, e.ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get employee: %w", err)
+	}
+	return employee, nil
+}
+
+// ListEmployees returns a page of the employees of company, in the order
+// they were added, and the token of the next page, paged like
+// ListCompanies. Unknown companies yield ErrCompanyNotFound.
+func (s *CompanyService) ListEmployees(ctx context.Context, company uuid.UUID, pageSize int, pageToken string) ([]models.Employee, string, error) {
+	switch {
+	case pageSize < 0:
+		return nil, "", e.Invalid("page_size", e.CodePageSizeInvalid, "negative page size")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+	offset, err := decodePageToken(pageToken)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := s.GetCompany(ctx, company); err != nil {
+		return nil, "", err
+	}
+
+	employees, err := s.repo.ListEmployees(ctx, company, offset, pageSize+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list employees: %w", err)
+	}
+	if len(employees) <= pageSize {
+		return employees, "", nil
+	}
+	return employees[:pageSize], encodePageToken(offset + pageSize), nil
+}
+
+// UpdateEmployee modifies the non-nil fields of an employee and returns the
+// updated version. The company's employee count is unaffected.
+func (s *CompanyService) UpdateEmployee(ctx context.Context, update *models.EmployeeUpdate) (*models.Employee, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := s.checkQuota(ctx); err != nil {
+		return nil, err
+	}
+	var invalid e.ValidationError
+	if update.ID == uuid.Nil {
+		invalid.Add("id", e.CodeEmployeeIDInvalid, "invalid employee ID")
+	}
+	validateEmployee(&invalid, update.Name, update.Title, update.Email)
+	if err := invalid.Err(); err != nil {
+		return nil, err
+	}
+
+	update.UpdatedBy = actorFromContext(ctx)
+	var employee *models.Employee
+	err := s.repo.WithTransaction(ctx, func(tx *db.Repository) error {
+		company, err := tx.GetCompanyForUpdate(ctx, update.CompanyID)
+		if err != nil {
+			return err
+		}
+		if owner, ok := s.requiredOwner(ctx); ok && company.CreatedBy != owner {
+			return e.ErrNotOwner
+		}
+		if err := tx.UpdateEmployee(ctx, update); err != nil {
+			return err
+		}
+		employee, err = tx.GetEmployee(ctx, update.CompanyID, update.ID)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, e.ErrNotFound) || errors.Is(err, e.ErrNotOwner) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update employee: %w", err)
+	}
+	return employee, nil
+}
+
+// DeleteEmployee removes an employee from a company, updating the
+// company's employee count like CreateEmployee.
+func (s *CompanyService) DeleteEmployee(ctx context.Context, company, id uuid.UUID) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.checkQuota(ctx); err != nil {
+		return err
+	}
+	err := s.changeEmployees(ctx, company, func(tx *db.Repository) error {
+		return tx.DeleteEmployee(ctx, company, id)
+	})
+	if err != nil {
+		if errors.Is(err, e.ErrNotFound) || errors.Is(err, e.ErrNotOwner) {
+			return err
+		}
+		return fmt.Errorf("failed to delete employee: %w", err)
+	}
+	return nil
+}
+
+// changeEmployees runs fn in a transaction holding the row lock of company,
+// then derives the company's employee count from its employee records and
+// records the change, if any, in the same transaction.
+func (s *CompanyService) changeEmployees(ctx context.Context, company uuid.UUID, fn func(tx *db.Repository) error) error {
+	actor := actorFromContext(ctx)
+	return s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		var before, after *models.Company
+		err := s.repo.WithTransaction(ctx, func(tx *db.Repository) error {
+			var err error
+			if before, err = tx.GetCompanyForUpdate(ctx, company); err != nil {
+				return err
+			}
+			if owner, ok := s.requiredOwner(ctx); ok && before.CreatedBy != owner {
+				return e.ErrNotOwner
+			}
+			if err := fn(tx); err != nil {
+				return err
+			}
+			after, err = tx.SyncEmployeeCount(ctx, company, actor)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if changes := diffCompanies(before, after); len(changes) > 0 {
+			return s.recordEvent(ctx, events.Event{Type: events.CompanyUpdated, Company: after, Actor: actor, Changes: changes})
+		}
+		return nil
+	})
+}
+
+// validateEmployee adds the violations of the given employee fields to
+// invalid; nil fields are not checked.
+func validateEmployee(invalid *e.ValidationError, name, title, email *string) {
+	switch {
+	case name == nil:
+	case *name == "":
+		invalid.Add("name", e.CodeEmployeeNameRequired, "name required")
+	case len(*name) > maxEmployeeFieldLength:
+		invalid.Add("name", e.CodeEmployeeNameTooLong, "name longer than 100 characters")
+	}
+	if title != nil && len(*title) > maxEmployeeFieldLength {
+		invalid.Add("title", e.CodeEmployeeTitleTooLong, "title longer than 100 characters")
+	}
+	if email != nil && *email != "" && !validEmail(*email) {
+		invalid.Add("email", e.CodeEmployeeEmailInvalid, "invalid email")
+	}
+}
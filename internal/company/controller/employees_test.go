@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/gartstein/xm/internal/company/db"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/pkg/utils"
+	"github.com/google/uuid"
+	"go.uber.org/zap/zaptest"
+	"gorm.io/driver/sqlite"
+)
+
+func TestCompanyService_Employees(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	mockProducer := &MockProducer{}
+	service := NewCompanyService(repo, mockProducer, zaptest.NewLogger(t), WithOwnershipChecks())
+	ctx := auth.NewContext(context.Background(), auth.Identity{UserID: "alice"})
+
+	company, err := service.CreateCompany(ctx, &models.Company{Name: "Acme", Employees: 250}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mockProducer.producedEvents = nil
+
+	var created []*models.Employee
+	for _, name := range []string{"Jane Doe", "John Roe"} {
+		employee, err := service.CreateEmployee(ctx, &models.Employee{CompanyID: company.ID, Name: name})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if employee.ID == uuid.Nil || employee.CreatedBy != "alice" {
+			t.Errorf("expected an ID and the creator, got %+v", employee)
+		}
+		created = append(created, employee)
+	}
+	stored, err := service.GetCompany(ctx, company.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored.Employees != 2 || stored.EmployeeRange != models.Employees1To10 {
+		t.Errorf("expected the count derived from the records, got %d in %q", stored.Employees, stored.EmployeeRange)
+	}
+	if len(mockProducer.producedEvents) != 2 {
+		t.Fatalf("expected an event per count change, got %d", len(mockProducer.producedEvents))
+	}
+	event := mockProducer.producedEvents[0]
+	if event.Type != events.CompanyUpdated || event.Changes["employees"] != (models.FieldChange{Old: 250, New: 1}) {
+		t.Errorf("expected the employee count change, got %q with %v", event.Type, event.Changes)
+	}
+
+	page, next, err := service.ListEmployees(ctx, company.ID, 1, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page) != 1 || page[0].ID != created[0].ID || next == "" {
+		t.Errorf("expected the first employee and a next page, got %v, %q", page, next)
+	}
+	if _, _, err := service.ListEmployees(ctx, uuid.New(), 0, ""); !errors.Is(err, e.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown company, got %v", err)
+	}
+
+	updated, err := service.UpdateEmployee(ctx, &models.EmployeeUpdate{ID: created[0].ID, CompanyID: company.ID, Title: utils.Ptr("CEO")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Name != "Jane Doe" || updated.Title != "CEO" {
+		t.Errorf("expected only the title to change, got %+v", updated)
+	}
+
+	_, err = service.UpdateCompany(ctx, &models.CompanyUpdate{ID: company.ID, Employees: utils.Ptr(40)}, models.UpdateOptions{})
+	if e.CodeOf(err) != e.CodeEmployeesDerived {
+		t.Errorf("expected EMPLOYEES_DERIVED, got %v", err)
+	}
+	if _, err := service.UpdateCompany(ctx, &models.CompanyUpdate{ID: company.ID, Employees: utils.Ptr(2)}, models.UpdateOptions{}); err != nil {
+		t.Errorf("expected the current count to be accepted, got %v", err)
+	}
+
+	other := auth.NewContext(context.Background(), auth.Identity{UserID: "mallory"})
+	if _, err := service.CreateEmployee(other, &models.Employee{CompanyID: company.ID, Name: "Mallory"}); !errors.Is(err, e.ErrNotOwner) {
+		t.Errorf("expected ErrNotOwner, got %v", err)
+	}
+
+	if err := service.DeleteEmployee(ctx, company.ID, created[1].ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := service.DeleteEmployee(ctx, company.ID, created[1].ID); !errors.Is(err, e.ErrEmployeeNotFound) {
+		t.Errorf("expected ErrEmployeeNotFound, got %v", err)
+	}
+	stored, err = service.GetCompany(ctx, company.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stored.Employees != 1 {
+		t.Errorf("expected 1 employee after the delete, got %d", stored.Employees)
+	}
+}
+
+func TestCompanyService_CreateEmployee_Validation(t *testing.T) {
+	service := NewCompanyService(&MockRepository{}, &MockProducer{}, zaptest.NewLogger(t))
+
+	tests := []struct {
+		name     string
+		employee models.Employee
+		want     e.Code
+	}{
+		{"missing name", models.Employee{}, e.CodeEmployeeNameRequired},
+		{"long name", models.Employee{Name: string(make([]byte, 101))}, e.CodeEmployeeNameTooLong},
+		{"long title", models.Employee{Name: "Jane", Title: string(make([]byte, 101))}, e.CodeEmployeeTitleTooLong},
+		{"invalid email", models.Employee{Name: "Jane", Email: "jane"}, e.CodeEmployeeEmailInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.CreateEmployee(context.Background(), &tt.employee)
+			if e.CodeOf(err) != tt.want {
+				t.Errorf("expected %s, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+)
+
+// GetCompanyHistory returns a page of the stored events of company published
+// in [since, until), oldest first, and the token of the next page, empty on
+// the last one. Zero times leave the range open. Pages continue after the
+// last event returned, so events recorded meanwhile neither shift nor repeat
+// them.
+func (s *CompanyService) GetCompanyHistory(ctx context.Context, company uuid.UUID, since, until time.Time, pageSize int, pageToken string) ([]models.CompanyEvent, string, error) {
+	if !since.IsZero() && !until.IsZero() && !until.After(since) {
+		return nil, "", e.Invalid("until", e.CodeTimeRangeInvalid, "until must be after since")
+	}
+	switch {
+	case pageSize < 0:
+		return nil, "", e.Invalid("page_size", e.CodePageSizeInvalid, "negative page size")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+	after, ok := decodeEventPosition(pageToken)
+	if !ok {
+		return nil, "", e.Invalid("page_token", e.CodePageTokenInvalid, "invalid page token")
+	}
+
+	filter := models.CompanyEventFilter{CompanyIDs: []uuid.UUID{company}, Since: since, Until: until, After: after}
+	// One extra event tells whether another page follows.
+	events, err := s.repo.ListCompanyEvents(ctx, filter, pageSize+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list company history: %w", err)
+	}
+	if len(events) <= pageSize {
+		return events, "", nil
+	}
+	events = events[:pageSize]
+	return events, encodeEventPosition(events[pageSize-1].Position()), nil
+}
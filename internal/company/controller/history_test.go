@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCompanyService_GetCompanyHistory(t *testing.T) {
+	companyID := uuid.New()
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	history := &eventHistory{}
+	for i := 0; i < 5; i++ {
+		history.append(models.CompanyEvent{ID: uuid.New(), CompanyID: companyID, CreatedAt: start.Add(time.Duration(i) * time.Hour)})
+	}
+	var limits []int
+	repo := &MockRepository{listEvents: func(ctx context.Context, filter models.CompanyEventFilter, limit int) ([]models.CompanyEvent, error) {
+		if len(filter.CompanyIDs) != 1 || filter.CompanyIDs[0] != companyID {
+			t.Errorf("unexpected companies %v", filter.CompanyIDs)
+		}
+		limits = append(limits, limit)
+		var events []models.CompanyEvent
+		err := history.forEach(ctx, filter, func(event *models.CompanyEvent) error {
+			if len(events) < limit {
+				events = append(events, *event)
+			}
+			return nil
+		})
+		return events, err
+	}}
+	service := NewCompanyService(repo, &MockProducer{}, zaptest.NewLogger(t))
+	until := start.Add(24 * time.Hour)
+
+	var got []uuid.UUID
+	token := ""
+	for page := 0; ; page++ {
+		events, next, err := service.GetCompanyHistory(context.Background(), companyID, start, until, 2, token)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, event := range events {
+			got = append(got, event.ID)
+		}
+		if next == "" {
+			break
+		}
+		if page > 3 {
+			t.Fatal("expected the last page to have no next page token")
+		}
+		token = next
+	}
+	if len(got) != 5 {
+		t.Fatalf("expected 5 events over the pages, got %d", len(got))
+	}
+	for i, id := range got {
+		if id != history.events[i].ID {
+			t.Errorf("expected event %d at position %d", i, i)
+		}
+	}
+	if limits[0] != 3 {
+		t.Errorf("expected one event beyond the page to be requested, got %d", limits[0])
+	}
+
+	invalid := []struct {
+		name         string
+		since, until time.Time
+		pageSize     int
+		pageToken    string
+	}{
+		{name: "empty window", since: start, until: start},
+		{name: "negative page size", pageSize: -1},
+		{name: "invalid page token", pageToken: "not a token"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := service.GetCompanyHistory(context.Background(), companyID, tt.since, tt.until, tt.pageSize, tt.pageToken)
+			if !errors.Is(err, e.ErrInvalidInput) {
+				t.Errorf("expected ErrInvalidInput, got %v", err)
+			}
+		})
+	}
+}
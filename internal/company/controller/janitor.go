@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/gartstein/xm/internal/pkg/leader"
+	"go.uber.org/zap"
+)
+
+// CompanyPurger permanently removes soft-deleted companies.
+type CompanyPurger interface {
+	PurgeDeletedCompanies(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Janitor periodically purges companies that were soft-deleted longer ago
+// than the retention period, keeping the table and its indexes bounded.
+// With several instances of the service, each purge runs on the one
+// instance electing itself leader.
+type Janitor struct {
+	repo      CompanyPurger
+	locker    leader.Locker
+	retention time.Duration
+	interval  time.Duration
+	logger    *zap.Logger
+}
+
+// defaultJanitorInterval is used when no positive interval is configured.
+const defaultJanitorInterval = time.Hour
+
+// janitorLockKey is the advisory lock key electing the instance purging.
+var janitorLockKey = leader.Key("xm.janitor")
+
+// NewJanitor constructs a Janitor purging rows deleted more than retention
+// ago, once every interval, on the instance taking its lock from locker.
+func NewJanitor(repo CompanyPurger, locker leader.Locker, retention, interval time.Duration, logger *zap.Logger) *Janitor {
+	if interval <= 0 {
+		interval = defaultJanitorInterval
+	}
+	return &Janitor{
+		repo:      repo,
+		locker:    locker,
+		retention: retention,
+		interval:  interval,
+		logger:    logger.Named("janitor"),
+	}
+}
+
+// Run purges immediately and then on every tick until ctx is canceled,
+// skipping the purges another instance is leading.
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		ran, err := leader.Do(ctx, j.locker, janitorLockKey, func(ctx context.Context) error {
+			_, err := j.RunOnce(ctx)
+			return err
+		})
+		switch {
+		case err != nil && ctx.Err() == nil:
+			j.logger.Error("Failed to purge deleted companies", zap.Error(err))
+		case !ran && err == nil:
+			j.logger.Debug("Purge running on another instance")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce performs a single purge pass and returns the number of rows removed.
+func (j *Janitor) RunOnce(ctx context.Context) (int64, error) {
+	purged, err := j.repo.PurgeDeletedCompanies(ctx, time.Now().Add(-j.retention))
+	if err != nil {
+		return 0, err
+	}
+	if purged > 0 {
+		j.logger.Info("Purged deleted companies", zap.Int64("count", purged))
+	}
+	return purged, nil
+}
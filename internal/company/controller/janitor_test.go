@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zaptest"
+)
+
+type mockPurger struct {
+	purge func(context.Context, time.Time) (int64, error)
+}
+
+func (m *mockPurger) PurgeDeletedCompanies(ctx context.Context, before time.Time) (int64, error) {
+	return m.purge(ctx, before)
+}
+
+// fakeLocker grants its lock unless held is set.
+type fakeLocker struct {
+	held bool
+}
+
+func (l *fakeLocker) TryAdvisoryLock(context.Context, int64) (func(), bool, error) {
+	if l.held {
+		return nil, false, nil
+	}
+	return func() {}, true, nil
+}
+
+func TestJanitor_RunOnce(t *testing.T) {
+	retention := 30 * 24 * time.Hour
+	var cutoff time.Time
+	purger := &mockPurger{purge: func(_ context.Context, before time.Time) (int64, error) {
+		cutoff = before
+		return 3, nil
+	}}
+
+	j := NewJanitor(purger, &fakeLocker{}, retention, time.Hour, zaptest.NewLogger(t))
+	purged, err := j.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if purged != 3 {
+		t.Errorf("expected 3 purged rows, got %d", purged)
+	}
+	if drift := time.Since(cutoff) - retention; drift < 0 || drift > time.Minute {
+		t.Errorf("expected cutoff about %v ago, got %v", retention, time.Since(cutoff))
+	}
+}
+
+func TestJanitor_RunStopsOnCancel(t *testing.T) {
+	calls := make(chan struct{}, 10)
+	purger := &mockPurger{purge: func(context.Context, time.Time) (int64, error) {
+		calls <- struct{}{}
+		return 0, errors.New("database unavailable")
+	}}
+
+	j := NewJanitor(purger, &fakeLocker{}, time.Hour, 10*time.Millisecond, zaptest.NewLogger(t))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		j.Run(ctx)
+		close(done)
+	}()
+
+	// Errors are logged and the janitor keeps ticking.
+	for i := 0; i < 2; i++ {
+		select {
+		case <-calls:
+		case <-time.After(time.Second):
+			t.Fatal("janitor did not run")
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("janitor did not stop after cancel")
+	}
+}
+
+func TestJanitor_RunSkipsWithoutLock(t *testing.T) {
+	calls := 0
+	purger := &mockPurger{purge: func(context.Context, time.Time) (int64, error) {
+		calls++
+		return 0, nil
+	}}
+
+	j := NewJanitor(purger, &fakeLocker{held: true}, time.Hour, time.Millisecond, zaptest.NewLogger(t))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	j.Run(ctx)
+	if calls != 0 {
+		t.Errorf("expected no purge while another instance holds the lock, got %d", calls)
+	}
+}
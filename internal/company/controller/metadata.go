@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+)
+
+// Limits of company metadata.
+const (
+	maxMetadataEntries     = 50
+	maxMetadataValueLength = 256
+)
+
+// metadataKeyPattern matches valid metadata keys. Keys end up in JSON paths
+// of metadata filters, so they are kept to characters that need no quoting.
+var metadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,62}$`)
+
+// validateMetadata adds the violations of the entries of metadata to
+// invalid in key order, naming a too long value "metadata.<key>".
+func validateMetadata(invalid *e.ValidationError, metadata models.Metadata) {
+	for _, key := range slices.Sorted(maps.Keys(metadata)) {
+		switch value := metadata[key]; {
+		case !metadataKeyPattern.MatchString(key):
+			invalid.Add("metadata", e.CodeMetadataKeyInvalid, fmt.Sprintf("invalid metadata key %.64q", key))
+		case len(value) > maxMetadataValueLength:
+			invalid.Add("metadata."+key, e.CodeMetadataValueTooLong, "metadata value longer than 256 characters")
+		}
+	}
+}
+
+// validateMetadataFilter checks the keys of a metadata filter.
+func validateMetadataFilter(metadata models.Metadata) error {
+	for key := range metadata {
+		if !metadataKeyPattern.MatchString(key) {
+			return e.Invalid("metadata", e.CodeMetadataKeyInvalid, fmt.Sprintf("invalid metadata key %.64q", key))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/gartstein/xm/internal/company/db"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"go.uber.org/zap/zaptest"
+	"gorm.io/driver/sqlite"
+)
+
+func TestCompanyService_Metadata(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	mockProducer := &MockProducer{}
+	service := NewCompanyService(repo, mockProducer, zaptest.NewLogger(t))
+	ctx := auth.NewContext(context.Background(), auth.Identity{UserID: "alice"})
+
+	company, err := service.CreateCompany(ctx, &models.Company{
+		Name:     "Acme",
+		Metadata: models.Metadata{"crm": "salesforce", "tier": ""},
+	}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(company.Metadata) != 1 || company.Metadata["crm"] != "salesforce" {
+		t.Errorf("expected empty values to be dropped, got %v", company.Metadata)
+	}
+	mockProducer.producedEvents = nil
+
+	updated, err := service.UpdateCompany(ctx, &models.CompanyUpdate{
+		ID:       company.ID,
+		Metadata: models.Metadata{"region": "emea"},
+	}, models.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.Metadata["crm"] != "salesforce" || updated.Metadata["region"] != "emea" {
+		t.Errorf("expected the entries to be merged, got %v", updated.Metadata)
+	}
+	if len(mockProducer.producedEvents) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(mockProducer.producedEvents))
+	}
+	if _, ok := mockProducer.producedEvents[0].Changes["metadata"]; !ok {
+		t.Errorf("expected a metadata change, got %v", mockProducer.producedEvents[0].Changes)
+	}
+
+	page, _, err := service.ListCompanies(ctx, models.CompanyFilter{Metadata: models.Metadata{"region": "emea"}}, 0, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page) != 1 || page[0].ID != company.ID {
+		t.Errorf("expected the tagged company, got %v", page)
+	}
+	if _, _, err := service.ListCompanies(ctx, models.CompanyFilter{Metadata: models.Metadata{"a b": "c"}}, 0, ""); e.CodeOf(err) != e.CodeMetadataKeyInvalid {
+		t.Errorf("expected METADATA_KEY_INVALID, got %v", err)
+	}
+
+	// The limit applies to the merged entries, and the update is rolled back.
+	patch := make(models.Metadata)
+	for i := 0; i < maxMetadataEntries-1; i++ {
+		patch[fmt.Sprintf("key%d", i)] = "value"
+	}
+	if _, err := service.UpdateCompany(ctx, &models.CompanyUpdate{ID: company.ID, Metadata: patch}, models.UpdateOptions{}); e.CodeOf(err) != e.CodeMetadataTooLarge {
+		t.Fatalf("expected METADATA_TOO_LARGE, got %v", err)
+	}
+	stored, err := service.GetCompany(ctx, company.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stored.Metadata) != 2 {
+		t.Errorf("expected the rejected update to be rolled back, got %d entries", len(stored.Metadata))
+	}
+}
+
+func TestCompanyService_MetadataValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata models.Metadata
+		want     e.Code
+	}{
+		{"invalid key", models.Metadata{"-crm": "x"}, e.CodeMetadataKeyInvalid},
+		{"long key", models.Metadata{strings.Repeat("k", 64): "x"}, e.CodeMetadataKeyInvalid},
+		{"long value", models.Metadata{"crm": strings.Repeat("v", 257)}, e.CodeMetadataValueTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewCompanyService(&MockRepository{}, &MockProducer{}, zaptest.NewLogger(t))
+			_, err := service.CreateCompany(context.Background(), &models.Company{Name: "Acme", Metadata: tt.metadata}, models.CreateOptions{})
+			if e.CodeOf(err) != tt.want {
+				t.Errorf("expected %s, got %v", tt.want, err)
+			}
+		})
+	}
+}
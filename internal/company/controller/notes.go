@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/gartstein/xm/internal/company/db"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+)
+
+// maxNoteLength limits note bodies.
+const maxNoteLength = 10000
+
+// AddCompanyNote records a note by the caller on the company
+// note.CompanyID and publishes a CompanyNoteAdded event, which puts it in
+// the company's history. Any authenticated caller may add notes; callers
+// without a user ID get CodeCallerUnidentified.
+func (s *CompanyService) AddCompanyNote(ctx context.Context, note *models.CompanyNote) (*models.CompanyNote, error) {
+	if err := s.checkWritable(); err != nil {
+		return nil, err
+	}
+	if err := s.checkQuota(ctx); err != nil {
+		return nil, err
+	}
+	note.Author = actorFromContext(ctx)
+	if note.Author == "" {
+		return nil, e.Newf(e.CodeCallerUnidentified, "caller has no user ID")
+	}
+	switch {
+	case note.Body == "":
+		return nil, e.Invalid("body", e.CodeNoteBodyRequired, "body required")
+	case len(note.Body) > maxNoteLength:
+		return nil, e.Invalid("body", e.CodeNoteBodyTooLong, "body longer than 10000 characters")
+	}
+
+	note.ID = s.newID()
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		var company *models.Company
+		err := s.repo.WithTransaction(ctx, func(tx *db.Repository) error {
+			var err error
+			if company, err = tx.GetCompany(ctx, note.CompanyID); err != nil {
+				return err
+			}
+			return tx.CreateCompanyNote(ctx, note)
+		})
+		if err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, events.Event{
+			Type:    events.CompanyNoteAdded,
+			Company: company,
+			Actor:   note.Author,
+			Changes: map[string]models.FieldChange{"note": {New: note.ID.String()}},
+		})
+	})
+	if err != nil {
+		if errors.Is(err, e.ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to add note: %w", err)
+	}
+	return note, nil
+}
+
+// ListCompanyNotes returns a page of the notes recorded on company, newest
+// first, and the token of the next page, paged like ListCompanies. Unknown
+// companies yield ErrCompanyNotFound.
+func (s *CompanyService) ListCompanyNotes(ctx context.Context, company uuid.UUID, pageSize int, pageToken string) ([]models.CompanyNote, string, error) {
+	switch {
+	case pageSize < 0:
+		return nil, "", e.Invalid("page_size", e.CodePageSizeInvalid, "negative page size")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+	offset, err := decodePageToken(pageToken)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := s.GetCompany(ctx, company); err != nil {
+		return nil, "", err
+	}
+
+	notes, err := s.repo.ListCompanyNotes(ctx, company, offset, pageSize+1)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list notes: %w", err)
+	}
+	if len(notes) <= pageSize {
+		return notes, "", nil
+	}
+	return notes[:pageSize], encodePageToken(offset + pageSize), nil
+}
+
+// DeleteCompanyNote removes a note from a company and publishes a
+// CompanyNoteDeleted event. Only the author of the note and admins may
+// delete it; others get CodeNoteNotAuthor.
+func (s *CompanyService) DeleteCompanyNote(ctx context.Context, company, id uuid.UUID) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.checkQuota(ctx); err != nil {
+		return err
+	}
+	identity, _ := auth.FromContext(ctx)
+	err := s.repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		var current *models.Company
+		err := s.repo.WithTransaction(ctx, func(tx *db.Repository) error {
+			var err error
+			if current, err = tx.GetCompany(ctx, company); err != nil {
+				return err
+			}
+			note, err := tx.GetCompanyNote(ctx, company, id)
+			if err != nil {
+				return err
+			}
+			if note.Author != identity.UserID && !identity.HasRole(auth.AdminRole) {
+				return e.Newf(e.CodeNoteNotAuthor, "note was written by another user")
+			}
+			return tx.DeleteCompanyNote(ctx, company, id)
+		})
+		if err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, events.Event{
+			Type:    events.CompanyNoteDeleted,
+			Company: current,
+			Actor:   identity.UserID,
+			Changes: map[string]models.FieldChange{"note": {Old: id.String()}},
+		})
+	})
+	if err != nil {
+		if errors.Is(err, e.ErrNotFound) || errors.Is(err, e.ErrNotOwner) {
+			return err
+		}
+		return fmt.Errorf("failed to delete note: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/gartstein/xm/internal/company/db"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap/zaptest"
+	"gorm.io/driver/sqlite"
+)
+
+func TestCompanyService_CompanyNotes(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	mockProducer := &MockProducer{}
+	service := NewCompanyService(repo, mockProducer, zaptest.NewLogger(t))
+	alice := auth.NewContext(context.Background(), auth.Identity{UserID: "alice"})
+	bob := auth.NewContext(context.Background(), auth.Identity{UserID: "bob"})
+	admin := auth.NewContext(context.Background(), auth.Identity{UserID: "root", Roles: []string{auth.AdminRole}})
+
+	company, err := service.CreateCompany(alice, &models.Company{Name: "Acme"}, models.CreateOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mockProducer.producedEvents = nil
+
+	note, err := service.AddCompanyNote(bob, &models.CompanyNote{CompanyID: company.ID, Author: "mallory", Body: "Renewal due in May"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if note.Author != "bob" {
+		t.Errorf("expected the author from the caller, got %q", note.Author)
+	}
+	if len(mockProducer.producedEvents) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(mockProducer.producedEvents))
+	}
+	event := mockProducer.producedEvents[0]
+	if event.Type != events.CompanyNoteAdded || event.Actor != "bob" || event.Changes["note"].New != note.ID.String() {
+		t.Errorf("expected a CompanyNoteAdded event by bob, got %q by %q with %v", event.Type, event.Actor, event.Changes)
+	}
+
+	for _, tt := range []struct {
+		ctx  context.Context
+		note models.CompanyNote
+		want e.Code
+	}{
+		{bob, models.CompanyNote{CompanyID: company.ID}, e.CodeNoteBodyRequired},
+		{bob, models.CompanyNote{CompanyID: company.ID, Body: strings.Repeat("x", maxNoteLength+1)}, e.CodeNoteBodyTooLong},
+		{context.Background(), models.CompanyNote{CompanyID: company.ID, Body: "anonymous"}, e.CodeCallerUnidentified},
+		{bob, models.CompanyNote{CompanyID: uuid.New(), Body: "lost"}, e.CodeCompanyNotFound},
+	} {
+		if _, err := service.AddCompanyNote(tt.ctx, &tt.note); e.CodeOf(err) != tt.want {
+			t.Errorf("expected %s, got %v", tt.want, err)
+		}
+	}
+
+	notes, next, err := service.ListCompanyNotes(alice, company.ID, 0, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(notes) != 1 || notes[0].Body != "Renewal due in May" || next != "" {
+		t.Errorf("expected the note, got %v, %q", notes, next)
+	}
+
+	if err := service.DeleteCompanyNote(alice, company.ID, note.ID); !errors.Is(err, e.ErrNotOwner) || e.CodeOf(err) != e.CodeNoteNotAuthor {
+		t.Errorf("expected NOTE_NOT_AUTHOR for another user, got %v", err)
+	}
+	if err := service.DeleteCompanyNote(admin, company.ID, note.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := service.DeleteCompanyNote(bob, company.ID, note.ID); !errors.Is(err, e.ErrNoteNotFound) {
+		t.Errorf("expected ErrNoteNotFound, got %v", err)
+	}
+	last := mockProducer.producedEvents[len(mockProducer.producedEvents)-1]
+	if last.Type != events.CompanyNoteDeleted || last.Actor != "root" || last.Changes["note"].Old != note.ID.String() {
+		t.Errorf("expected a CompanyNoteDeleted event by root, got %q by %q with %v", last.Type, last.Actor, last.Changes)
+	}
+}
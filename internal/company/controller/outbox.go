@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/pkg/leader"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// OutboxStore queues the recorded events of mutations, in their
+// transaction, for an OutboxRelay; *db.Repository implements it.
+type OutboxStore interface {
+	EnqueueOutboxEvent(ctx context.Context, company, event uuid.UUID) error
+}
+
+// WithOutbox queues the events of mutations in store, in the transaction of
+// the mutation, instead of producing them once it committed; an OutboxRelay
+// publishes them. An event is then published exactly when its mutation
+// committed, even if the service stops right after, and the events of a
+// company in the order their mutations committed.
+func WithOutbox(store OutboxStore) ServiceOption {
+	return func(s *CompanyService) {
+		s.outbox = store
+	}
+}
+
+// EventPublisher publishes events synchronously, returning delivery
+// failures; events.Producer implements it.
+type EventPublisher interface {
+	Publish(ctx context.Context, event events.Event) error
+}
+
+// OutboxSource lists the events queued with WithOutbox, in the order they
+// were queued, and removes them once published; *db.Repository implements
+// it.
+type OutboxSource interface {
+	ListOutboxEvents(ctx context.Context, limit int) ([]models.CompanyEvent, error)
+	DeleteOutboxEvents(ctx context.Context, events []uuid.UUID) error
+}
+
+// OutboxRelay periodically publishes the events queued with WithOutbox.
+// With several instances of the service, the events are relayed by the one
+// instance electing itself leader, one at a time, so those of a company
+// reach their partition in the order they were queued.
+type OutboxRelay struct {
+	store     OutboxSource
+	publisher EventPublisher
+	locker    leader.Locker
+	interval  time.Duration
+	logger    *zap.Logger
+}
+
+const (
+	// defaultOutboxRelayInterval is used when no positive interval is
+	// configured.
+	defaultOutboxRelayInterval = time.Second
+	// outboxBatchSize is the number of queued events read at a time.
+	outboxBatchSize = 100
+)
+
+// outboxRelayLockKey is the advisory lock key electing the instance relaying.
+var outboxRelayLockKey = leader.Key("xm.outbox_relay")
+
+// NewOutboxRelay constructs an OutboxRelay publishing the events queued in
+// store through publisher, once every interval, on the instance taking its
+// lock from locker.
+func NewOutboxRelay(store OutboxSource, publisher EventPublisher, locker leader.Locker, interval time.Duration, logger *zap.Logger) *OutboxRelay {
+	if interval <= 0 {
+		interval = defaultOutboxRelayInterval
+	}
+	return &OutboxRelay{
+		store:     store,
+		publisher: publisher,
+		locker:    locker,
+		interval:  interval,
+		logger:    logger.Named("outbox_relay"),
+	}
+}
+
+// Run relays immediately and then on every tick until ctx is canceled,
+// skipping the runs another instance is leading.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := leader.Do(ctx, r.locker, outboxRelayLockKey, func(ctx context.Context) error {
+			_, err := r.RunOnce(ctx)
+			return err
+		})
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("Failed to relay company events", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce publishes the queued events one at a time, in the order they were
+// queued, until none is left, and returns the number published. It stops at
+// the first event failing to publish, which the next run retries before any
+// later event, so no event overtakes an earlier one of its company. An event
+// published but not yet removed when the relay stops is published again;
+// consumers drop the redelivery by its EventID.
+func (r *OutboxRelay) RunOnce(ctx context.Context) (int, error) {
+	relayed := 0
+	for {
+		queued, err := r.store.ListOutboxEvents(ctx, outboxBatchSize)
+		if err != nil {
+			return relayed, fmt.Errorf("failed to list queued events: %w", err)
+		}
+		if len(queued) == 0 {
+			return relayed, nil
+		}
+		published := make([]uuid.UUID, 0, len(queued))
+		var publishErr error
+		for i := range queued {
+			if publishErr = r.publisher.Publish(ctx, outboxEvent(&queued[i])); publishErr != nil {
+				publishErr = fmt.Errorf("failed to publish event %s: %w", queued[i].ID, publishErr)
+				break
+			}
+			published = append(published, queued[i].ID)
+		}
+		if err := r.store.DeleteOutboxEvents(ctx, published); err != nil {
+			return relayed, fmt.Errorf("failed to remove published events: %w", err)
+		}
+		relayed += len(published)
+		if publishErr != nil {
+			return relayed, publishErr
+		}
+	}
+}
+
+// outboxEvent returns the event to publish for its recorded copy.
+func outboxEvent(stored *models.CompanyEvent) events.Event {
+	company := stored.Company
+	return events.Event{
+		EventID:     stored.ID,
+		Type:        events.EventType(stored.Type),
+		Company:     &company,
+		Actor:       stored.Actor,
+		Changes:     stored.Changes,
+		Validations: stored.Validations,
+		Suppressed:  stored.Suppressed,
+	}
+}
@@ -0,0 +1,174 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/gartstein/xm/internal/company/db"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"gorm.io/driver/sqlite"
+)
+
+// recordingPublisher records the events published through it, per company,
+// failing the publication of the event fail, if any, once.
+type recordingPublisher struct {
+	mu        sync.Mutex
+	published map[uuid.UUID][]events.Event
+	fail      uuid.UUID
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, event events.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if event.EventID == p.fail {
+		p.fail = uuid.Nil
+		return errors.New("broker unavailable")
+	}
+	if p.published == nil {
+		p.published = make(map[uuid.UUID][]events.Event)
+	}
+	p.published[event.Company.ID] = append(p.published[event.Company.ID], event)
+	return nil
+}
+
+// failingOutbox is an outbox rejecting every event.
+type failingOutbox struct{}
+
+func (failingOutbox) EnqueueOutboxEvent(context.Context, uuid.UUID, uuid.UUID) error {
+	return errors.New("disk full")
+}
+
+// TestOutboxRelay_Order verifies the events of concurrent create, update and
+// delete bursts are relayed once each, in the order their mutations
+// committed per company, and not produced directly.
+func TestOutboxRelay_Order(t *testing.T) {
+	repo, err := db.NewRepository(&db.Config{Driver: "sqlite", Path: filepath.Join(t.TempDir(), "company.db")})
+	require.NoError(t, err)
+	defer repo.Close()
+	producer := &MockProducer{}
+	service := NewCompanyService(repo, producer, zaptest.NewLogger(t), WithOutbox(repo))
+	ctx := auth.NewContext(context.Background(), auth.Identity{UserID: "alice"})
+
+	const (
+		companies = 5
+		updates   = 8
+	)
+	var wg sync.WaitGroup
+	for i := range companies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			company, err := service.CreateCompany(ctx, &models.Company{Name: fmt.Sprintf("Burst %d", i)}, models.CreateOptions{})
+			if !assert.NoError(t, err) {
+				return
+			}
+			var burst sync.WaitGroup
+			for n := range updates {
+				burst.Add(1)
+				go func() {
+					defer burst.Done()
+					_, err := service.UpdateCompany(ctx, &models.CompanyUpdate{ID: company.ID, Employees: utils.Ptr(n + 1)}, models.UpdateOptions{})
+					assert.NoError(t, err)
+				}()
+			}
+			burst.Add(1)
+			go func() {
+				defer burst.Done()
+				_, err := service.AddCompanyNote(ctx, &models.CompanyNote{CompanyID: company.ID, Body: "Called the CFO"})
+				assert.NoError(t, err)
+			}()
+			burst.Wait()
+			_, err = service.DeleteCompany(ctx, company.ID)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Empty(t, producer.producedEvents, "queued events should not be produced directly")
+
+	publisher := &recordingPublisher{}
+	relay := NewOutboxRelay(repo, publisher, &fakeLocker{}, 0, zaptest.NewLogger(t))
+	relayed, err := relay.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, companies*(updates+3), relayed)
+
+	require.Len(t, publisher.published, companies)
+	for id, published := range publisher.published {
+		require.Len(t, published, updates+3, id)
+		assert.Equal(t, events.CompanyCreated, published[0].Type)
+		assert.Equal(t, events.CompanyDeleted, published[len(published)-1].Type)
+		employees := 0
+		for _, event := range published[1 : len(published)-1] {
+			if event.Type == events.CompanyNoteAdded {
+				continue
+			}
+			require.Equal(t, events.CompanyUpdated, event.Type)
+			assert.Equal(t, fmt.Sprint(employees), fmt.Sprint(event.Changes["employees"].Old),
+				"each update should follow the one committed before it")
+			employees = event.Company.Employees
+		}
+	}
+
+	relayed, err = relay.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, relayed, "relayed events should be removed from the outbox")
+}
+
+// TestOutboxRelay_Retry verifies a relay stops at an event failing to
+// publish and resumes with it, so later events never overtake it.
+func TestOutboxRelay_Retry(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	require.NoError(t, err)
+	service := NewCompanyService(repo, &MockProducer{}, zaptest.NewLogger(t), WithOutbox(repo))
+	ctx := context.Background()
+
+	company, err := service.CreateCompany(ctx, &models.Company{Name: "Acme"}, models.CreateOptions{})
+	require.NoError(t, err)
+	for n := range 3 {
+		_, err := service.UpdateCompany(ctx, &models.CompanyUpdate{ID: company.ID, Employees: utils.Ptr(n + 1)}, models.UpdateOptions{})
+		require.NoError(t, err)
+	}
+	queued, err := repo.ListOutboxEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, queued, 4)
+
+	publisher := &recordingPublisher{fail: queued[2].ID}
+	relay := NewOutboxRelay(repo, publisher, &fakeLocker{}, 0, zaptest.NewLogger(t))
+	relayed, err := relay.RunOnce(ctx)
+	assert.ErrorContains(t, err, "broker unavailable")
+	assert.Equal(t, 2, relayed)
+	relayed, err = relay.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, relayed)
+
+	var ids []uuid.UUID
+	for _, event := range publisher.published[company.ID] {
+		ids = append(ids, event.EventID)
+	}
+	assert.Equal(t, []uuid.UUID{queued[0].ID, queued[1].ID, queued[2].ID, queued[3].ID}, ids)
+
+	_, err = service.ApplyCompanies(ctx, []models.Company{{Name: "Initech", ExternalRef: "ERP-1"}}, models.ApplyOptions{})
+	require.NoError(t, err)
+	queued, err = repo.ListOutboxEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, queued, 1, "applied changes should be queued in the transaction of the apply")
+	assert.Equal(t, string(events.CompanyCreated), queued[0].Type)
+
+	// A mutation whose event cannot be queued is rolled back with it.
+	failing := NewCompanyService(repo, &MockProducer{}, zaptest.NewLogger(t), WithOutbox(failingOutbox{}))
+	_, err = failing.CreateCompany(ctx, &models.Company{Name: "Globex"}, models.CreateOptions{})
+	assert.ErrorContains(t, err, "failed to queue company event")
+	exists, err := repo.CompanyExistsByName(ctx, "Globex")
+	require.NoError(t, err)
+	assert.False(t, exists, "the create should be rolled back")
+}
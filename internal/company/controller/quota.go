@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+)
+
+// QuotaStore keeps the tenant quotas and the usage they are checked
+// against.
+type QuotaStore interface {
+	GetTenantQuota(ctx context.Context, tenant string) (*models.TenantQuota, error)
+	SetTenantQuota(ctx context.Context, quota *models.TenantQuota) error
+	CountTenantCompanies(ctx context.Context, tenant string) (int64, error)
+	LockTenantCompanies(ctx context.Context, tenant string) error
+	IncrementTenantMutations(ctx context.Context, tenant string, window time.Time) (int, error)
+	TenantMutations(ctx context.Context, tenant string, window time.Time) (int, error)
+}
+
+// quotaWarningThreshold is the share of a quota past which mutations
+// record a QuotaWarning.
+const quotaWarningThreshold = 0.8
+
+// QuotaWarning tells that a tenant is close to one of its quotas.
+type QuotaWarning struct {
+	// Quota is errors.QuotaCompanies or errors.QuotaMutationsPerMinute.
+	Quota string
+	// Used counts the companies, including the one being created, or the
+	// mutations of the current minute.
+	Used  int64
+	Limit int
+}
+
+type quotaWarningsKey struct{}
+
+type quotaWarnings struct {
+	mu       sync.Mutex
+	warnings []QuotaWarning
+}
+
+// RecordQuotaWarnings returns a context under which the quota warnings of
+// the mutations made with it can be read back with the returned function,
+// e.g. by an interceptor passing them on to the caller. A quota warned
+// about several times is reported once, with its latest usage.
+func RecordQuotaWarnings(ctx context.Context) (context.Context, func() []QuotaWarning) {
+	rec := &quotaWarnings{}
+	return context.WithValue(ctx, quotaWarningsKey{}, rec), func() []QuotaWarning {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return slices.Clone(rec.warnings)
+	}
+}
+
+// warnQuota records a warning when used is past quotaWarningThreshold of
+// limit and ctx records warnings.
+func warnQuota(ctx context.Context, quota string, used int64, limit int) {
+	rec, ok := ctx.Value(quotaWarningsKey{}).(*quotaWarnings)
+	if !ok || float64(used) <= quotaWarningThreshold*float64(limit) {
+		return
+	}
+	warning := QuotaWarning{Quota: quota, Used: used, Limit: limit}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if i := slices.IndexFunc(rec.warnings, func(w QuotaWarning) bool { return w.Quota == quota }); i >= 0 {
+		rec.warnings[i] = warning
+		return
+	}
+	rec.warnings = append(rec.warnings, warning)
+}
+
+// WithQuotas enforces the tenant quotas kept in store on the creates,
+// updates and deletes of callers with a tenant, failing them with a
+// *errors.QuotaExceededError. Tenants without a quota of their own get
+// defaults. Mutations are counted per calendar minute, rejected ones
+// included.
+func WithQuotas(store QuotaStore, defaults models.TenantQuota) ServiceOption {
+	return func(s *CompanyService) {
+		s.quotas = store
+		s.defaultQuota = defaults
+	}
+}
+
+// GetTenantQuota returns the quota of tenant, the defaults when none was
+// set, and what the tenant currently uses of it.
+func (s *CompanyService) GetTenantQuota(ctx context.Context, tenant string) (*models.TenantQuota, *models.TenantUsage, error) {
+	if tenant == "" {
+		return nil, nil, e.Invalid("tenant_id", e.CodeTenantIDRequired, "tenant ID required")
+	}
+	quota, err := s.tenantQuota(ctx, tenant)
+	if err != nil {
+		return nil, nil, err
+	}
+	usage := &models.TenantUsage{}
+	if usage.Companies, err = s.quotas.CountTenantCompanies(ctx, tenant); err != nil {
+		return nil, nil, fmt.Errorf("failed to count tenant companies: %w", err)
+	}
+	if usage.MutationsThisMinute, err = s.quotas.TenantMutations(ctx, tenant, s.now().Truncate(time.Minute)); err != nil {
+		return nil, nil, fmt.Errorf("failed to read tenant mutations: %w", err)
+	}
+	return quota, usage, nil
+}
+
+// UpdateTenantQuota sets the quota of quota.TenantID, recording the caller
+// as the admin who set it.
+func (s *CompanyService) UpdateTenantQuota(ctx context.Context, quota *models.TenantQuota) (*models.TenantQuota, error) {
+	var invalid e.ValidationError
+	if quota.TenantID == "" {
+		invalid.Add("tenant_id", e.CodeTenantIDRequired, "tenant ID required")
+	}
+	if quota.MaxCompanies < 0 {
+		invalid.Add("max_companies", e.CodeQuotaLimitNegative, "quota limits must not be negative")
+	}
+	if quota.MaxMutationsPerMinute < 0 {
+		invalid.Add("max_mutations_per_minute", e.CodeQuotaLimitNegative, "quota limits must not be negative")
+	}
+	if err := invalid.Err(); err != nil {
+		return nil, err
+	}
+	if s.quotas == nil {
+		return nil, fmt.Errorf("tenant quotas are not enabled")
+	}
+	quota.UpdatedBy = actorFromContext(ctx)
+	quota.UpdatedAt = s.now()
+	if err := s.quotas.SetTenantQuota(ctx, quota); err != nil {
+		return nil, fmt.Errorf("failed to set tenant quota: %w", err)
+	}
+	return quota, nil
+}
+
+// tenantQuota returns the quota set for tenant, or the defaults.
+func (s *CompanyService) tenantQuota(ctx context.Context, tenant string) (*models.TenantQuota, error) {
+	if s.quotas == nil {
+		return nil, fmt.Errorf("tenant quotas are not enabled")
+	}
+	quota, err := s.quotas.GetTenantQuota(ctx, tenant)
+	switch {
+	case errors.Is(err, e.ErrNotFound):
+		defaults := s.defaultQuota
+		defaults.TenantID = tenant
+		return &defaults, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to get tenant quota: %w", err)
+	}
+	return quota, nil
+}
+
+// checkQuota counts a mutation against the quota of the caller's tenant and
+// returns a *errors.QuotaExceededError when it exceeds the mutation rate.
+// Callers without a tenant are not limited. Usage past
+// quotaWarningThreshold of the limit records a QuotaWarning.
+func (s *CompanyService) checkQuota(ctx context.Context) error {
+	if s.quotas == nil {
+		return nil
+	}
+	identity, _ := auth.FromContext(ctx)
+	if identity.TenantID == "" {
+		return nil
+	}
+	quota, err := s.tenantQuota(ctx, identity.TenantID)
+	if err != nil {
+		return err
+	}
+	if quota.MaxMutationsPerMinute > 0 {
+		now := s.now()
+		window := now.Truncate(time.Minute)
+		count, err := s.quotas.IncrementTenantMutations(ctx, identity.TenantID, window)
+		if err != nil {
+			return fmt.Errorf("failed to count tenant mutations: %w", err)
+		}
+		if count > quota.MaxMutationsPerMinute {
+			return &e.QuotaExceededError{
+				TenantID:   identity.TenantID,
+				Quota:      e.QuotaMutationsPerMinute,
+				Limit:      quota.MaxMutationsPerMinute,
+				RetryAfter: window.Add(time.Minute).Sub(now),
+			}
+		}
+		warnQuota(ctx, e.QuotaMutationsPerMinute, int64(count), quota.MaxMutationsPerMinute)
+	}
+	return nil
+}
+
+// checkCompanyQuota returns a *errors.QuotaExceededError when the caller's
+// tenant already has its maximum of companies. It must run in the
+// transaction creating the company: it locks the tenant's companies first,
+// so concurrent creates are counted one after the other instead of all
+// seeing room for one more. Usage past quotaWarningThreshold of the limit
+// records a QuotaWarning.
+func (s *CompanyService) checkCompanyQuota(ctx context.Context) error {
+	if s.quotas == nil {
+		return nil
+	}
+	identity, _ := auth.FromContext(ctx)
+	if identity.TenantID == "" {
+		return nil
+	}
+	quota, err := s.tenantQuota(ctx, identity.TenantID)
+	if err != nil || quota.MaxCompanies <= 0 {
+		return err
+	}
+	if err := s.quotas.LockTenantCompanies(ctx, identity.TenantID); err != nil {
+		return fmt.Errorf("failed to lock tenant companies: %w", err)
+	}
+	count, err := s.quotas.CountTenantCompanies(ctx, identity.TenantID)
+	if err != nil {
+		return fmt.Errorf("failed to count tenant companies: %w", err)
+	}
+	if count >= int64(quota.MaxCompanies) {
+		return &e.QuotaExceededError{
+			TenantID: identity.TenantID,
+			Quota:    e.QuotaCompanies,
+			Limit:    quota.MaxCompanies,
+		}
+	}
+	warnQuota(ctx, e.QuotaCompanies, count+1, quota.MaxCompanies)
+	return nil
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+)
+
+// ReportStore runs the named queries of the db package registry;
+// *db.Repository implements it.
+type ReportStore interface {
+	RunNamedQuery(ctx context.Context, name string, params map[string]interface{}, dest interface{}) error
+}
+
+// WithReports serves reports from the named queries of store.
+func WithReports(store ReportStore) ServiceOption {
+	return func(s *CompanyService) {
+		s.reports = store
+	}
+}
+
+// defaultReportMonths is how far back monthly counts go by default.
+const defaultReportMonths = 12
+
+// CountCompaniesPerMonth counts the companies created per calendar month in
+// [filter.Since, filter.Until), grouped by type or by the value of the
+// metadata entry filter.MetadataKey, ordered by month and group. Until
+// defaults to now and Since to 12 months before Until.
+func (s *CompanyService) CountCompaniesPerMonth(ctx context.Context, filter models.MonthlyCountFilter) ([]models.MonthlyCount, error) {
+	if filter.Until.IsZero() {
+		filter.Until = s.now()
+	}
+	if filter.Since.IsZero() {
+		filter.Since = filter.Until.AddDate(0, -defaultReportMonths, 0)
+	}
+	if !filter.Until.After(filter.Since) {
+		return nil, e.Invalid("until", e.CodeTimeRangeInvalid, "until must be after since")
+	}
+	params := map[string]interface{}{
+		"since":  filter.Since.UTC(),
+		"until":  filter.Until.UTC(),
+		"tenant": filter.TenantID,
+	}
+	query := "companies_per_month_by_type"
+	if filter.MetadataKey != "" {
+		if !metadataKeyPattern.MatchString(filter.MetadataKey) {
+			return nil, e.Invalid("metadata_key", e.CodeMetadataKeyInvalid, fmt.Sprintf("invalid metadata key %.64q", filter.MetadataKey))
+		}
+		query = "companies_per_month_by_metadata"
+		params["key"] = filter.MetadataKey
+	}
+	if s.reports == nil {
+		return nil, fmt.Errorf("reports are not enabled")
+	}
+	var counts []models.MonthlyCount
+	if err := s.reports.RunNamedQuery(ctx, query, params, &counts); err != nil {
+		return nil, fmt.Errorf("failed to count companies per month: %w", err)
+	}
+	return counts, nil
+}
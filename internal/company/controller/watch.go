@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+)
+
+// Defaults of WithWatchPolling.
+const (
+	defaultWatchPollInterval = time.Second
+	defaultWatchSettleDelay  = 2 * time.Second
+)
+
+// WithWatchPolling sets how often WatchCompanyEvents polls the event history
+// for new events, and how long it waits before streaming an event. Events
+// are recorded in the transaction of their call, so one may commit after
+// later ones were streamed; settle must exceed the longest such transaction
+// for watchers not to skip it. Non-positive durations keep the defaults.
+func WithWatchPolling(interval, settle time.Duration) ServiceOption {
+	return func(s *CompanyService) {
+		if interval > 0 {
+			s.watchPollInterval = interval
+		}
+		if settle > 0 {
+			s.watchSettleDelay = settle
+		}
+	}
+}
+
+// WatchCompanyEvents calls fn, oldest first, for the stored events matching
+// filter published after the event resumeToken identifies or, without a
+// token, from now on, along with the token resuming after each. It polls for
+// new events until ctx is done or fn fails, and returns that error. The next
+// batch of events is only loaded once fn returned for the previous one, so a
+// slow watcher falls behind in the history instead of buffering events.
+// Events recorded with events suppressed are not published, so they are
+// skipped.
+func (s *CompanyService) WatchCompanyEvents(ctx context.Context, filter models.CompanyEventFilter, resumeToken string, fn func(event *models.CompanyEvent, resumeToken string) error) error {
+	var invalid e.ValidationError
+	validateEventTypes(&invalid, filter.Types)
+	if err := invalid.Err(); err != nil {
+		return err
+	}
+	after, ok := decodeEventPosition(resumeToken)
+	if !ok {
+		return e.Invalid("resume_token", e.CodeResumeTokenInvalid, "invalid resume token")
+	}
+	filter.After = after
+	if after == nil {
+		filter.Since = s.now()
+	}
+
+	for {
+		filter.Until = s.now().Add(-s.watchSettleDelay)
+		err := s.repo.ForEachCompanyEvent(ctx, filter, func(event *models.CompanyEvent) error {
+			position := event.Position()
+			filter.After = &position
+			if event.Suppressed {
+				return nil
+			}
+			return fn(event, encodeEventPosition(position))
+		})
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.watchPollInterval):
+		}
+	}
+}
+
+// validateEventTypes adds an error to invalid for every unknown event type
+// in types.
+func validateEventTypes(invalid *e.ValidationError, types []string) {
+	for i, t := range types {
+		if !events.EventType(t).Valid() {
+			invalid.Add(fmt.Sprintf("event_types[%d]", i), e.CodeEventTypeUnknown, fmt.Sprintf("unknown event type %q", t))
+		}
+	}
+}
+
+// encodeEventPosition returns an opaque token for position, resuming a
+// watch or listing after it.
+func encodeEventPosition(position models.CompanyEventPosition) string {
+	raw := strconv.FormatInt(position.CreatedAt.UnixNano(), 10) + "/" + position.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeEventPosition returns the position encoded in token, nil for an empty
+// token, and false for a token encodeEventPosition did not return.
+func decodeEventPosition(token string) (*models.CompanyEventPosition, bool) {
+	if token == "" {
+		return nil, true
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, false
+	}
+	nanos, id, ok := strings.Cut(string(raw), "/")
+	if !ok {
+		return nil, false
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	position := models.CompanyEventPosition{CreatedAt: time.Unix(0, n)}
+	if position.ID, err = uuid.Parse(id); err != nil {
+		return nil, false
+	}
+	return &position, true
+}
@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap/zaptest"
+)
+
+// eventHistory serves stored events to ForEachCompanyEvent like the
+// database does.
+type eventHistory struct {
+	mu     sync.Mutex
+	events []models.CompanyEvent
+}
+
+func (h *eventHistory) append(event models.CompanyEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event)
+}
+
+func (h *eventHistory) forEach(_ context.Context, filter models.CompanyEventFilter, fn func(*models.CompanyEvent) error) error {
+	h.mu.Lock()
+	events := append([]models.CompanyEvent(nil), h.events...)
+	h.mu.Unlock()
+	for i := range events {
+		event := &events[i]
+		if filter.After != nil && !event.CreatedAt.After(filter.After.CreatedAt) {
+			continue
+		}
+		if event.CreatedAt.Before(filter.Since) || (!filter.Until.IsZero() && !event.CreatedAt.Before(filter.Until)) {
+			continue
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestCompanyService_WatchCompanyEvents(t *testing.T) {
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	event := func(i int) models.CompanyEvent {
+		return models.CompanyEvent{ID: uuid.New(), Type: "company_updated", CreatedAt: start.Add(time.Duration(i) * time.Second)}
+	}
+	history := &eventHistory{}
+	for i := 0; i < 3; i++ {
+		history.append(event(i))
+	}
+	event3 := event(3)
+	service := NewCompanyService(&MockRepository{forEachEvent: history.forEach}, &MockProducer{}, zaptest.NewLogger(t),
+		WithWatchPolling(time.Millisecond, time.Second))
+	service.now = func() time.Time { return start.Add(time.Minute) }
+
+	// watch streams events until fn returns false.
+	watch := func(resumeToken string, fn func(event *models.CompanyEvent, token string) bool) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		err := service.WatchCompanyEvents(ctx, models.CompanyEventFilter{}, resumeToken, func(event *models.CompanyEvent, token string) error {
+			if !fn(event, token) {
+				cancel()
+			}
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected the watch to end with the context, got %v", err)
+		}
+	}
+
+	// A watcher resuming after the first event gets the others, then the
+	// one added while it runs.
+	var resumeToken string
+	watch(encodeEventPosition(models.CompanyEventPosition{CreatedAt: start.Add(-time.Second)}), func(_ *models.CompanyEvent, token string) bool {
+		if resumeToken == "" {
+			resumeToken = token
+		}
+		return false
+	})
+	var ids []uuid.UUID
+	watch(resumeToken, func(event *models.CompanyEvent, _ string) bool {
+		ids = append(ids, event.ID)
+		if len(ids) == 2 {
+			history.append(event3)
+		}
+		return len(ids) < 3
+	})
+	want := []uuid.UUID{history.events[1].ID, history.events[2].ID, event3.ID}
+	if !slices.Equal(ids, want) {
+		t.Errorf("expected events %v, got %v", want, ids)
+	}
+
+	t.Run("without token", func(t *testing.T) {
+		var since time.Time
+		ctx, cancel := context.WithCancel(context.Background())
+		repo := &MockRepository{forEachEvent: func(_ context.Context, filter models.CompanyEventFilter, _ func(*models.CompanyEvent) error) error {
+			since = filter.Since
+			cancel()
+			return nil
+		}}
+		watcher := NewCompanyService(repo, &MockProducer{}, zaptest.NewLogger(t))
+		watcher.now = service.now
+		_ = watcher.WatchCompanyEvents(ctx, models.CompanyEventFilter{}, "", func(*models.CompanyEvent, string) error { return nil })
+		if !since.Equal(service.now()) {
+			t.Errorf("expected the watch to start now, got %v", since)
+		}
+	})
+
+	invalid := []struct {
+		name   string
+		filter models.CompanyEventFilter
+		token  string
+	}{
+		{name: "invalid token", token: "not a token"},
+		{name: "malformed token", token: "YWJj"},
+		{name: "unknown type", filter: models.CompanyEventFilter{Types: []string{"company_renamed"}}},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.WatchCompanyEvents(context.Background(), tt.filter, tt.token, func(*models.CompanyEvent, string) error { return nil })
+			if !errors.Is(err, e.ErrInvalidInput) {
+				t.Errorf("expected ErrInvalidInput, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEventPosition(t *testing.T) {
+	position := models.CompanyEventPosition{CreatedAt: time.Unix(0, 1740787200123456789), ID: uuid.New()}
+	got, ok := decodeEventPosition(encodeEventPosition(position))
+	if !ok {
+		t.Fatal("expected the token to decode")
+	}
+	if !got.CreatedAt.Equal(position.CreatedAt) || got.ID != position.ID {
+		t.Errorf("expected %+v, got %+v", position, got)
+	}
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ListCompanyTypes returns the company type taxonomy, ordered by code.
+func (r *Repository) ListCompanyTypes(ctx context.Context) ([]models.CompanyTypeDefinition, error) {
+	var types []models.CompanyTypeDefinition
+	err := r.conn(ctx).Order("code").Find(&types).Error
+	return types, err
+}
+
+// GetCompanyType returns the company type with the given code, keeping it
+// from being deleted until the transaction ctx carries ends on databases
+// supporting row locks, so a company written in that transaction cannot be
+// left with a type no longer in the taxonomy.
+func (r *Repository) GetCompanyType(ctx context.Context, code models.CompanyType) (*models.CompanyTypeDefinition, error) {
+	return r.getCompanyType(ctx, code, "SHARE")
+}
+
+// GetCompanyTypeForUpdate returns the company type with the given code,
+// locking its row until the transaction ctx carries ends on databases
+// supporting row locks.
+func (r *Repository) GetCompanyTypeForUpdate(ctx context.Context, code models.CompanyType) (*models.CompanyTypeDefinition, error) {
+	return r.getCompanyType(ctx, code, "UPDATE")
+}
+
+func (r *Repository) getCompanyType(ctx context.Context, code models.CompanyType, strength string) (*models.CompanyTypeDefinition, error) {
+	var companyType models.CompanyTypeDefinition
+	result := r.conn(ctx).
+		Clauses(clause.Locking{Strength: strength}).
+		First(&companyType, "code = ?", code)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, e.ErrCompanyTypeNotFound
+		}
+		return nil, result.Error
+	}
+	return &companyType, nil
+}
+
+// CreateCompanyType adds a company type to the taxonomy. A type with the
+// same code yields COMPANY_TYPE_EXISTS.
+func (r *Repository) CreateCompanyType(ctx context.Context, companyType *models.CompanyTypeDefinition) error {
+	result := r.conn(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(companyType)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return e.Newf(e.CodeCompanyTypeExists, "company type %s exists", companyType.Code)
+	}
+	return nil
+}
+
+// UpdateCompanyType sets the name and description of the company type with
+// the given code, recording actor as the admin who changed it at at.
+func (r *Repository) UpdateCompanyType(ctx context.Context, code models.CompanyType, name, description, actor string, at time.Time) error {
+	result := r.conn(ctx).Model(&models.CompanyTypeDefinition{}).Where("code = ?", code).Updates(map[string]interface{}{
+		"name":        name,
+		"description": description,
+		"updated_by":  actor,
+		"updated_at":  at,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return e.ErrCompanyTypeNotFound
+	}
+	return nil
+}
+
+// DeleteCompanyType removes the company type with the given code from the
+// taxonomy.
+func (r *Repository) DeleteCompanyType(ctx context.Context, code models.CompanyType) error {
+	result := r.conn(ctx).Delete(&models.CompanyTypeDefinition{}, "code = ?", code)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return e.ErrCompanyTypeNotFound
+	}
+	return nil
+}
+
+// CountCompaniesOfType returns the number of live companies of the given
+// type.
+func (r *Repository) CountCompaniesOfType(ctx context.Context, code models.CompanyType) (int64, error) {
+	var count int64
+	err := r.conn(ctx).Model(&models.Company{}).Where("type = ?", code).Count(&count).Error
+	return count, err
+}
+
+// seedCompanyTypes adds the built-in company types the taxonomy lacks,
+// leaving the names admins gave them.
+func seedCompanyTypes(db *gorm.DB) error {
+	types := make([]models.CompanyTypeDefinition, len(models.BuiltinCompanyTypes))
+	copy(types, models.BuiltinCompanyTypes)
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&types).Error
+}
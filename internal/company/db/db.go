@@ -2,96 +2,818 @@ package db
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
+	dbmodels "github.com/gartstein/xm/internal/company/db/models"
 	e "github.com/gartstein/xm/internal/company/errors"
 	"github.com/gartstein/xm/internal/company/models"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
 )
 
 type Repository struct {
 	db *gorm.DB
+	// schema is the PostgreSQL schema of the tables; empty for the default.
+	schema string
 }
 
 type Config struct {
+	// Driver is "postgres" (default) or "sqlite". SQLite keeps the database
+	// in the file at Path and ignores the other settings, for edge and demo
+	// deployments without a database server.
+	Driver   string
+	Path     string
 	Host     string
 	Port     int
 	User     string
 	Password string
 	DBName   string
 	SSLMode  string
+	// Schema, when set, is the PostgreSQL schema the tables are created and
+	// used in, so several environments can share a database. It is created
+	// if missing. Extensions are still found in public.
+	Schema string
 }
 
-func NewRepository(cfg *Config) (*Repository, error) {
+// schemaName matches the schema names Config accepts: unquoted lower case
+// identifiers, which need no escaping in the search_path.
+var schemaName = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// Option customizes a Repository opened by Open or NewRepository.
+type Option func(*openConfig)
+
+type openConfig struct {
+	gorm      gorm.Config
+	plugins   []gorm.Plugin
+	schema    string
+	noMigrate bool
+}
+
+// WithoutMigration opens the database as it is, leaving the schema to the
+// service, e.g. to check with PendingMigrations whether it is current.
+func WithoutMigration() Option {
+	return func(c *openConfig) {
+		c.noMigrate = true
+	}
+}
+
+// WithQueryMetrics times every statement with metrics. GORM's own slow
+// query log is turned off in favour of the one of metrics, and the errors
+// GORM still logs show placeholders instead of bound parameters.
+func WithQueryMetrics(metrics *QueryMetrics) Option {
+	return func(c *openConfig) {
+		c.gorm.Logger = logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+			LogLevel:             logger.Error,
+			ParameterizedQueries: true,
+			Colorful:             true,
+		})
+		c.plugins = append(c.plugins, metrics)
+	}
+}
+
+func NewRepository(cfg *Config, opts ...Option) (*Repository, error) {
+	switch cfg.Driver {
+	case "", "postgres":
+	case "sqlite":
+		if cfg.Path == "" {
+			return nil, errors.New("the sqlite driver needs the path of the database file")
+		}
+		return Open(sqlite.Open(sqliteDataSourceName(cfg.Path)), opts...)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", cfg.Driver)
+	}
+	dsn, err := dataSourceName(cfg)
+	if err != nil {
+		return nil, err
+	}
+	withSchema := func(c *openConfig) { c.schema = cfg.Schema }
+	return Open(postgres.Open(dsn), append([]Option{withSchema}, opts...)...)
+}
+
+// dataSourceName returns the connection string for cfg. A schema is put
+// first on the search_path of every connection, followed by public for
+// extensions such as pg_trgm.
+func dataSourceName(cfg *Config) (string, error) {
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode)
+	if cfg.Schema == "" {
+		return dsn, nil
+	}
+	if !schemaName.MatchString(cfg.Schema) {
+		return "", fmt.Errorf("invalid schema name %q: use lower case letters, digits and underscores", cfg.Schema)
+	}
+	return dsn + " search_path=" + cfg.Schema + ",public", nil
+}
+
+// sqliteDataSourceName returns the connection string of the SQLite database
+// at path. The write-ahead log lets readers proceed while a transaction
+// writes; transactions take the write lock when they begin, instead of
+// failing when they first write, and wait up to five seconds for it.
+func sqliteDataSourceName(path string) string {
+	return "file:" + path + "?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000&_txlock=immediate&_foreign_keys=on"
+}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// Open connects through dialector and migrates the schema, unless
+// WithoutMigration is given. NewRepository uses it for the configured
+// driver; tests pass other dialectors, such as an in-memory SQLite
+// database.
+func Open(dialector gorm.Dialector, opts ...Option) (*Repository, error) {
+	var cfg openConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	db, err := gorm.Open(dialector, &cfg.gorm)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
+	for _, plugin := range cfg.plugins {
+		if err := db.Use(plugin); err != nil {
+			return nil, fmt.Errorf("failed to register %s: %w", plugin.Name(), err)
+		}
+	}
 
-	if err := db.AutoMigrate(&models.Company{}); err != nil {
+	if cfg.noMigrate {
+		return &Repository{db: db, schema: cfg.schema}, nil
+	}
+	if cfg.schema != "" && db.Dialector.Name() == "postgres" {
+		if err := db.Exec("CREATE SCHEMA IF NOT EXISTS " + cfg.schema).Error; err != nil {
+			return nil, fmt.Errorf("failed to create schema %s: %w", cfg.schema, err)
+		}
+	}
+	if err := migrate(db); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	return &Repository{db: db}, nil
+	return &Repository{db: db, schema: cfg.schema}, nil
+}
+
+// tables lists the models of every table owned by the repository.
+var tables = []interface{}{&models.Company{}, &models.APIKey{}, &models.CompanyEvent{}, &models.AlertWebhook{}, &dbmodels.ProcessedEvent{},
+	&models.TenantQuota{}, &dbmodels.TenantMutationCount{}, &models.ComplianceRecord{}, &models.Employee{}, &models.CompanyNote{}, &models.ExportRun{}, &dbmodels.UsedToken{},
+	&models.DuplicateSuggestion{}, &models.CompanyTypeDefinition{}, &dbmodels.OutboxEvent{}, &dbmodels.SchemaVersion{}}
+
+// migrate creates or updates every table owned by the repository and records
+// the SchemaVersion. A schema already migrated by a newer build is left as
+// it is.
+func migrate(db *gorm.DB) error {
+	version, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if version > SchemaVersion {
+		return nil
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		return err
+	}
+	if err := protectComplianceRecords(db); err != nil {
+		return err
+	}
+	if err := indexCompanyMetadata(db); err != nil {
+		return err
+	}
+	if err := dropDeletedExternalRefIndex(db); err != nil {
+		return err
+	}
+	if err := backfillEmployeeRanges(db); err != nil {
+		return err
+	}
+	if err := seedCompanyTypes(db); err != nil {
+		return fmt.Errorf("failed to seed company types: %w", err)
+	}
+	return recordSchemaVersion(db)
+}
+
+// indexCompanyMetadata adds a GIN index serving the containment queries of
+// metadata filters on PostgreSQL. Other databases scan.
+func indexCompanyMetadata(db *gorm.DB) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	err := db.Exec("CREATE INDEX IF NOT EXISTS idx_companies_metadata ON companies USING gin (metadata jsonb_path_ops)").Error
+	if err != nil {
+		return fmt.Errorf("failed to index company metadata: %w", err)
+	}
+	return nil
+}
+
+// dropDeletedExternalRefIndex drops the unique index on external references
+// created before version 4, which also covered deleted companies, so that
+// their references can be reused as their names can.
+// idx_companies_live_external_ref replaces it.
+func dropDeletedExternalRefIndex(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasIndex(&models.Company{}, "idx_companies_external_ref") {
+		return nil
+	}
+	if err := migrator.DropIndex(&models.Company{}, "idx_companies_external_ref"); err != nil {
+		return fmt.Errorf("failed to drop the external reference index: %w", err)
+	}
+	return nil
+}
+
+// protectComplianceRecords makes PostgreSQL reject updates of compliance
+// records, so they cannot be altered even through other clients. Deletes
+// stay possible to enforce the retention period. The only exemption is
+// EraseCompanyData, which clears payloads in a transaction setting
+// xm.erasing. Other databases rely on the repository never updating them
+// otherwise.
+func protectComplianceRecords(db *gorm.DB) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	for _, stmt := range []string{
+		`CREATE OR REPLACE FUNCTION compliance_records_append_only() RETURNS trigger AS $$
+BEGIN
+	IF current_setting('xm.erasing', true) = 'on'
+		AND (NEW.id, NEW.method, NEW.request_id, NEW.user_id, NEW.code, NEW.error, NEW.created_at)
+			IS NOT DISTINCT FROM (OLD.id, OLD.method, OLD.request_id, OLD.user_id, OLD.code, OLD.error, OLD.created_at) THEN
+		RETURN NEW;
+	END IF;
+	RAISE EXCEPTION 'compliance records are append-only';
+END
+$$ LANGUAGE plpgsql`,
+		"DROP TRIGGER IF EXISTS compliance_records_append_only ON compliance_records",
+		"CREATE TRIGGER compliance_records_append_only BEFORE UPDATE ON compliance_records FOR EACH ROW EXECUTE FUNCTION compliance_records_append_only()",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to protect compliance records: %w", err)
+		}
+	}
+	return nil
+}
+
+// backfillEmployeeRanges derives the employee range of companies created
+// before the column existed. It is a no-op once every row is populated.
+func backfillEmployeeRanges(db *gorm.DB) error {
+	var companies []models.Company
+	return db.Unscoped().Select("id", "employees").
+		Where("employee_range = '' AND employees > 0").
+		FindInBatches(&companies, 500, func(tx *gorm.DB, _ int) error {
+			for _, c := range companies {
+				err := db.Unscoped().Model(&models.Company{}).
+					Where("id = ?", c.ID).
+					UpdateColumn("employee_range", models.EmployeeRangeFor(c.Employees)).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// PendingMigrations returns the tables and columns, as "table" or
+// "table.column", that migrating would add, so that an empty result means
+// the schema is current.
+func (r *Repository) PendingMigrations(ctx context.Context) ([]string, error) {
+	db := r.db.WithContext(ctx)
+	var pending []string
+	for _, model := range tables {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		table := stmt.Schema.Table
+		migrator := db.Migrator()
+		if !migrator.HasTable(table) {
+			pending = append(pending, table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !migrator.HasColumn(model, field.DBName) {
+				pending = append(pending, table+"."+field.DBName)
+			}
+		}
+	}
+	return pending, nil
+}
+
+// uniqueViolations maps the unique indexes of companies, by their name on
+// PostgreSQL and by the columns SQLite reports, to the error returned when
+// a write violates them.
+var uniqueViolations = map[string]error{
+	"idx_companies_name":              e.ErrDuplicateName,
+	"companies.name":                  e.ErrDuplicateName,
+	"idx_companies_live_external_ref": e.ErrDuplicateExternalRef,
+	"companies.external_ref":          e.ErrDuplicateExternalRef,
 }
 
+// translateUniqueViolation returns the error of uniqueViolations when err
+// reports a violation of one of those indexes, and err otherwise. The
+// service checks names before writing, but a concurrent write can take the
+// name or external reference in between; the index has the last word.
+func translateUniqueViolation(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		if mapped, ok := uniqueViolations[pgErr.ConstraintName]; ok {
+			return mapped
+		}
+		return err
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		// The message lists the indexed columns, as in
+		// "UNIQUE constraint failed: companies.name".
+		columns := strings.TrimPrefix(sqliteErr.Error(), "UNIQUE constraint failed: ")
+		if mapped, ok := uniqueViolations[columns]; ok {
+			return mapped
+		}
+	}
+	return err
+}
+
+// CreateCompany inserts company. A name or external reference already
+// taken by a live company yields ErrDuplicateName or
+// ErrDuplicateExternalRef.
 func (r *Repository) CreateCompany(ctx context.Context, company *models.Company) error {
-	result := r.db.WithContext(ctx).Create(company)
+	if err := r.conn(ctx).Create(company).Error; err != nil {
+		return translateUniqueViolation(err)
+	}
+	return nil
+}
+
+// DefaultUpsertBatchSize is the number of companies UpsertCompanies writes
+// per statement when no batch size is given.
+const DefaultUpsertBatchSize = 500
+
+// upsertColumns are overwritten when an upserted company's name already
+// exists; the existing ID, status and creation fields are kept.
+var upsertColumns = []string{
+	"description", "employees", "employee_range", "registered", "type",
+	"external_ref", "contact_email", "updated_by", "updated_at",
+}
+
+// UpsertCompanies inserts companies, updating the live company of the same
+// name instead where one exists, with one INSERT ... ON CONFLICT (name) DO
+// UPDATE statement per batchSize companies; batchSize <= 0 uses
+// DefaultUpsertBatchSize. All batches run in one transaction. It returns the
+// number of rows inserted or updated.
+//
+// Derived fields such as EmployeeRange are stored as given. The IDs of
+// companies that already existed are not written back to companies.
+func (r *Repository) UpsertCompanies(ctx context.Context, companies []models.Company, batchSize int) (int64, error) {
+	if len(companies) == 0 {
+		return 0, nil
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultUpsertBatchSize
+	}
+	result := r.conn(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "name"}},
+			// Matches the partial unique index on live names.
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "deleted_at IS NULL"}}},
+			DoUpdates:   clause.AssignmentColumns(upsertColumns),
+		}).
+		CreateInBatches(&companies, batchSize)
+	return result.RowsAffected, translateUniqueViolation(result.Error)
+}
+
+// GetCompanyByName returns the company whose name matches name, ignoring
+// case. When several names differ only in case, the oldest company wins.
+func (r *Repository) GetCompanyByName(ctx context.Context, name string) (*models.Company, error) {
+	var company models.Company
+	result := r.conn(ctx).
+		Where("lower(name) = lower(?)", name).
+		Order("created_at, id").
+		First(&company)
 	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
-			return e.ErrDuplicateName
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, e.ErrCompanyNotFound
 		}
-		return result.Error
+		return nil, result.Error
 	}
-	return nil
+	return &company, nil
+}
+
+// GetCompanyByExternalRef returns the company carrying ref.
+func (r *Repository) GetCompanyByExternalRef(ctx context.Context, ref string) (*models.Company, error) {
+	var company models.Company
+	result := r.conn(ctx).First(&company, "external_ref = ?", ref)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, e.ErrCompanyNotFound
+		}
+		return nil, result.Error
+	}
+	return &company, nil
 }
 
 func (r *Repository) GetCompany(ctx context.Context, id uuid.UUID) (*models.Company, error) {
 	var company models.Company
-	result := r.db.WithContext(ctx).First(&company, "id = ?", id)
+	result := r.conn(ctx).First(&company, "id = ?", id)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, e.ErrNotFound
+			return nil, e.ErrCompanyNotFound
 		}
 		return nil, result.Error
 	}
 	return &company, nil
 }
 
+// likeEscaper escapes the LIKE wildcards in user input.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// ListCompanies returns up to limit companies matching filter, ordered by
+// creation time, skipping the first offset.
+func (r *Repository) ListCompanies(ctx context.Context, filter models.CompanyFilter, offset, limit int) ([]models.Company, error) {
+	var companies []models.Company
+	err := r.companiesMatching(ctx, filter).Order("created_at, id").Offset(offset).Limit(limit).Find(&companies).Error
+	return companies, err
+}
+
+// CountCompanies returns the number of companies matching filter.
+func (r *Repository) CountCompanies(ctx context.Context, filter models.CompanyFilter) (int64, error) {
+	var count int64
+	err := r.companiesMatching(ctx, filter).Count(&count).Error
+	return count, err
+}
+
+// companiesMatching returns a query selecting the companies matching filter.
+func (r *Repository) companiesMatching(ctx context.Context, filter models.CompanyFilter) *gorm.DB {
+	query := r.conn(ctx).Model(&models.Company{})
+	if len(filter.EmployeeRanges) > 0 {
+		query = query.Where("employee_range IN ?", filter.EmployeeRanges)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if !filter.UpdatedBefore.IsZero() {
+		query = query.Where("updated_at < ?", filter.UpdatedBefore)
+	}
+	if filter.CreatedBy != "" {
+		query = query.Where("created_by = ?", filter.CreatedBy)
+	}
+	if filter.HasExternalRef {
+		query = query.Where("external_ref <> ''")
+	}
+	if filter.NameContains != "" {
+		query = query.Where(`lower(name) LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(strings.ToLower(filter.NameContains))+"%")
+	}
+	if len(filter.Metadata) > 0 {
+		query = r.metadataMatching(query, filter.Metadata)
+	}
+	return query
+}
+
+// metadataMatching restricts query to companies carrying every entry of
+// metadata. Keys are interpolated into JSON paths on other databases than
+// PostgreSQL, so callers validate them.
+func (r *Repository) metadataMatching(query *gorm.DB, metadata models.Metadata) *gorm.DB {
+	if r.db.Dialector.Name() == "postgres" {
+		filter, _ := json.Marshal(metadata)
+		return query.Where("metadata @> ?", string(filter))
+	}
+	for key, value := range metadata {
+		query = query.Where("json_extract(metadata, ?) = ?", `$."`+key+`"`, value)
+	}
+	return query
+}
+
+// UpdateCompany applies the non-nil fields of update. Metadata is read,
+// merged and written back, so callers changing it hold the row lock, as
+// UpdateCompanyReturning does. A new name or external reference already
+// taken by a live company yields ErrDuplicateName or
+// ErrDuplicateExternalRef.
 func (r *Repository) UpdateCompany(ctx context.Context, update *models.CompanyUpdate) error {
-	result := r.db.WithContext(ctx).Model(&models.Company{}).
+	result := r.conn(ctx).Model(&models.Company{}).
 		Where("id = ?", update.ID).
 		Updates(update)
 
 	if result.Error != nil {
-		return result.Error
+		return translateUniqueViolation(result.Error)
 	}
 	if result.RowsAffected == 0 {
-		return e.ErrNotFound
+		return e.ErrCompanyNotFound
+	}
+	if update.Metadata != nil {
+		return r.mergeMetadata(ctx, update.ID, update.Metadata)
 	}
 	return nil
 }
 
+// mergeMetadata applies patch to the metadata of the company with the given
+// ID like CompanyUpdate.Metadata.
+func (r *Repository) mergeMetadata(ctx context.Context, id uuid.UUID, patch models.Metadata) error {
+	var company models.Company
+	if err := r.conn(ctx).Select("metadata").First(&company, "id = ?", id).Error; err != nil {
+		return err
+	}
+	merged, err := json.Marshal(company.Metadata.Merge(patch))
+	if err != nil {
+		return err
+	}
+	return r.conn(ctx).Model(&models.Company{}).Where("id = ?", id).UpdateColumn("metadata", string(merged)).Error
+}
+
+// UpdateCompanyReturning applies update while holding a row lock
+// (SELECT ... FOR UPDATE) and returns the row as it was before and after the
+// change, both read in the same transaction, so callers never observe
+// another writer's changes.
+func (r *Repository) UpdateCompanyReturning(ctx context.Context, update *models.CompanyUpdate) (before, after *models.Company, err error) {
+	err = r.WithTransaction(ctx, func(tx *Repository) error {
+		if before, err = tx.GetCompanyForUpdate(ctx, update.ID); err != nil {
+			return err
+		}
+		if err = tx.UpdateCompany(ctx, update); err != nil {
+			return err
+		}
+		after, err = tx.GetCompany(ctx, update.ID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+// GetCompanyIncludingDeleted returns the company with the given ID, even
+// when it was soft-deleted; only purged companies yield ErrCompanyNotFound.
+func (r *Repository) GetCompanyIncludingDeleted(ctx context.Context, id uuid.UUID) (*models.Company, error) {
+	var company models.Company
+	result := r.conn(ctx).Unscoped().First(&company, "id = ?", id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, e.ErrCompanyNotFound
+		}
+		return nil, result.Error
+	}
+	return &company, nil
+}
+
+// GetCompanyForUpdate reads a company and locks its row until the surrounding
+// transaction ends.
+func (r *Repository) GetCompanyForUpdate(ctx context.Context, id uuid.UUID) (*models.Company, error) {
+	var company models.Company
+	result := r.conn(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&company, "id = ?", id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, e.ErrCompanyNotFound
+		}
+		return nil, result.Error
+	}
+	return &company, nil
+}
+
 func (r *Repository) DeleteCompany(ctx context.Context, id uuid.UUID) error {
-	result := r.db.WithContext(ctx).Delete(&models.Company{}, "id = ?", id)
+	result := r.conn(ctx).Delete(&models.Company{}, "id = ?", id)
 	if result.Error != nil {
 		return result.Error
 	}
 	if result.RowsAffected == 0 {
-		return e.ErrNotFound
+		return e.ErrCompanyNotFound
+	}
+	return nil
+}
+
+// PurgeCompany permanently removes a soft-deleted company, its employees
+// and notes. Companies that do not exist or have not been deleted yield
+// ErrNotFound.
+func (r *Repository) PurgeCompany(ctx context.Context, id uuid.UUID) error {
+	return r.WithTransaction(ctx, func(tx *Repository) error {
+		result := tx.conn(ctx).Unscoped().
+			Where("id = ? AND deleted_at IS NOT NULL", id).
+			Delete(&models.Company{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return e.ErrCompanyNotFound
+		}
+		return tx.deleteDependents(ctx, []uuid.UUID{id})
+	})
+}
+
+// EraseCompanyData clears the personal data of the company with the given
+// ID, even when it was soft-deleted: its contact email and description,
+// both in the company row and in the snapshots and diffs of its stored
+// events, and its employee records and notes. The employee count is kept.
+// The compliance records mentioning the company keep their metadata, but
+// their payloads lose the erased fields and those of employees and notes.
+// The company is stamped as erased at at by actor and returned as kept.
+// Purged companies yield ErrCompanyNotFound.
+func (r *Repository) EraseCompanyData(ctx context.Context, id uuid.UUID, actor string, at time.Time) (*models.Company, error) {
+	var company models.Company
+	err := r.WithTransaction(ctx, func(tx *Repository) error {
+		result := tx.conn(ctx).Unscoped().
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&company, "id = ?", id)
+		if result.Error != nil {
+			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				return e.ErrCompanyNotFound
+			}
+			return result.Error
+		}
+		err := tx.conn(ctx).Unscoped().Model(&company).Updates(map[string]interface{}{
+			"contact_email": "",
+			"description":   "",
+			"erased_at":     at,
+			"updated_by":    actor,
+		}).Error
+		if err != nil {
+			return err
+		}
+		company.ContactEmail, company.Description, company.ErasedAt, company.UpdatedBy = "", "", &at, actor
+		if err := tx.deleteDependents(ctx, []uuid.UUID{id}); err != nil {
+			return err
+		}
+
+		var stored []models.CompanyEvent
+		if err := tx.conn(ctx).Where("company_id = ?", id).Find(&stored).Error; err != nil {
+			return err
+		}
+		for _, event := range stored {
+			event.Company.Description = ""
+			if _, ok := event.Changes["description"]; ok {
+				// Like contact emails, the change is kept without its values.
+				event.Changes["description"] = models.FieldChange{}
+			}
+			err := tx.conn(ctx).Model(&event).Select("company", "changes").Updates(&event).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.eraseComplianceRecords(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &company, nil
+}
+
+// erasedPayloadFields are the JSON fields eraseCompliancePayload clears: the
+// contact email and description of companies, and the employees and notes
+// erasure deletes.
+var erasedPayloadFields = map[string]bool{"contactEmail": true, "description": true, "employee": true, "note": true}
+
+// eraseComplianceRecords clears the erased fields from the payloads of the
+// compliance records mentioning the company with the given ID, in the
+// transaction ctx carries. On PostgreSQL the append-only trigger lets the
+// payloads, and only them, change while xm.erasing is set.
+func (r *Repository) eraseComplianceRecords(ctx context.Context, id uuid.UUID) error {
+	var records []models.ComplianceRecord
+	pattern := "%" + id.String() + "%"
+	if err := r.conn(ctx).Where("request LIKE ? OR response LIKE ?", pattern, pattern).Find(&records).Error; err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	onPostgres := r.db.Dialector.Name() == "postgres"
+	if onPostgres {
+		if err := r.conn(ctx).Exec("SELECT set_config('xm.erasing', 'on', true)").Error; err != nil {
+			return err
+		}
+	}
+	for _, record := range records {
+		record.Request = eraseCompliancePayload(record.Request)
+		record.Response = eraseCompliancePayload(record.Response)
+		if err := r.conn(ctx).Model(&record).Select("request", "response").Updates(&record).Error; err != nil {
+			return err
+		}
+	}
+	if onPostgres {
+		return r.conn(ctx).Exec("SELECT set_config('xm.erasing', '', true)").Error
+	}
+	return nil
+}
+
+// eraseCompliancePayload returns the JSON payload with the values of
+// erasedPayloadFields, at any depth, set to null. Other payloads, such as
+// empty responses, are returned as they are.
+func eraseCompliancePayload(payload string) string {
+	var v interface{}
+	if err := json.Unmarshal([]byte(payload), &v); err != nil {
+		return payload
+	}
+	b, err := json.Marshal(eraseValue(v))
+	if err != nil {
+		return payload
+	}
+	return string(b)
+}
+
+func eraseValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if erasedPayloadFields[k] {
+				t[k] = nil
+				continue
+			}
+			t[k] = eraseValue(val)
+		}
+	case []interface{}:
+		for i, val := range t {
+			t[i] = eraseValue(val)
+		}
+	}
+	return v
+}
+
+// deleteDependents removes the employees and notes of the companies matching
+// companies, a query or a list of IDs.
+func (r *Repository) deleteDependents(ctx context.Context, companies interface{}) error {
+	for _, model := range []interface{}{&models.Employee{}, &models.CompanyNote{}} {
+		if err := r.conn(ctx).Where("company_id IN (?)", companies).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PurgeDeletedCompanies permanently removes companies soft-deleted before the
+// given time, with their employees and notes, and returns how many companies
+// were removed.
+func (r *Repository) PurgeDeletedCompanies(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	err := r.WithTransaction(ctx, func(tx *Repository) error {
+		expired := tx.conn(ctx).Unscoped().Model(&models.Company{}).Select("id").
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before)
+		if err := tx.deleteDependents(ctx, expired); err != nil {
+			return err
+		}
+		result := tx.conn(ctx).Unscoped().
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+			Delete(&models.Company{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, err
+}
+
+// EnableNameSimilarity prepares the database for FindSimilarCompanies. On
+// PostgreSQL it installs pg_trgm and a trigram index on lower(name), which
+// requires the CREATE privilege on the database; other databases need no
+// preparation.
+func (r *Repository) EnableNameSimilarity(ctx context.Context) error {
+	if r.db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	for _, stmt := range []string{
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+		"CREATE INDEX IF NOT EXISTS idx_companies_name_trgm ON companies USING gin (lower(name) gin_trgm_ops)",
+	} {
+		if err := r.conn(ctx).Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to enable name similarity: %w", err)
+		}
 	}
 	return nil
 }
 
+// FindSimilarCompanies returns up to limit companies whose name has a trigram
+// similarity of at least threshold (0-1) with name, most similar first.
+// Other databases than PostgreSQL compute the similarity in Go, reading every
+// name, which is only suitable for small tables.
+func (r *Repository) FindSimilarCompanies(ctx context.Context, name string, threshold float64, limit int) ([]models.Company, error) {
+	var companies []models.Company
+	if r.db.Dialector.Name() == "postgres" {
+		err := r.conn(ctx).
+			Where("similarity(lower(name), lower(?)) >= ?", name, threshold).
+			Order(clause.OrderBy{Expression: clause.Expr{SQL: "similarity(lower(name), lower(?)) DESC", Vars: []interface{}{name}}}).
+			Limit(limit).
+			Find(&companies).Error
+		return companies, err
+	}
+
+	if err := r.conn(ctx).Find(&companies).Error; err != nil {
+		return nil, err
+	}
+	scores := make(map[uuid.UUID]float64, len(companies))
+	similar := companies[:0]
+	for _, c := range companies {
+		if score := trigramSimilarity(c.Name, name); score >= threshold {
+			scores[c.ID] = score
+			similar = append(similar, c)
+		}
+	}
+	sort.SliceStable(similar, func(i, j int) bool { return scores[similar[i].ID] > scores[similar[j].ID] })
+	if len(similar) > limit {
+		similar = similar[:limit]
+	}
+	return similar, nil
+}
+
 func (r *Repository) CompanyExistsByName(ctx context.Context, name string) (bool, error) {
 	var count int64
-	result := r.db.WithContext(ctx).Model(&models.Company{}).
+	result := r.conn(ctx).Model(&models.Company{}).
 		Select("name").
 		Where("name = ?", name).
 		Limit(1).
@@ -100,19 +822,236 @@ func (r *Repository) CompanyExistsByName(ctx context.Context, name string) (bool
 }
 
 func (r *Repository) WithTransaction(ctx context.Context, fn func(repo *Repository) error) error {
-	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		return fn(&Repository{db: tx})
+	return r.conn(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&Repository{db: tx, schema: r.schema})
 	})
 }
 
+// TryAdvisoryLock takes the PostgreSQL session-level advisory lock key on a
+// connection reserved until release is called, so that only one instance of
+// the service runs a job at a time. ok is false when another session holds
+// the lock. Other databases have no advisory locks and always grant it.
+// Locks are per database, so with a schema key is mixed with its name to
+// keep environments sharing the database from blocking each other's jobs.
+func (r *Repository) TryAdvisoryLock(ctx context.Context, key int64) (release func(), ok bool, err error) {
+	if r.db.Dialector.Name() != "postgres" {
+		return func() {}, true, nil
+	}
+	key = r.lockKey(key)
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&ok); err != nil || !ok {
+		conn.Close()
+		return nil, false, err
+	}
+	return func() {
+		// Closing the connection without unlocking would return the lock
+		// to the pool with it, so unlock with a fresh context.
+		_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		conn.Close()
+	}, true, nil
+}
+
+// lockKey returns the advisory lock key for key within the schema of r.
+func (r *Repository) lockKey(key int64) int64 {
+	if r.schema == "" {
+		return key
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(r.schema))
+	return key ^ int64(h.Sum64())
+}
+
+// IsEventProcessed reports whether groupID has already recorded eventID.
+func (r *Repository) IsEventProcessed(ctx context.Context, groupID string, eventID uuid.UUID) (bool, error) {
+	var count int64
+	result := r.conn(ctx).Model(&dbmodels.ProcessedEvent{}).
+		Where("group_id = ? AND event_id = ?", groupID, eventID).
+		Count(&count)
+	return count > 0, result.Error
+}
+
+// MarkEventProcessed records eventID as handled by groupID. Recording the same
+// event twice is not an error.
+func (r *Repository) MarkEventProcessed(ctx context.Context, groupID string, eventID uuid.UUID) error {
+	return r.conn(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&dbmodels.ProcessedEvent{GroupID: groupID, EventID: eventID, ProcessedAt: time.Now()}).
+		Error
+}
+
+// PurgeProcessedEvents deletes dedup records older than before, bounding the
+// table to the redelivery window, and returns the number of rows removed.
+func (r *Repository) PurgeProcessedEvents(ctx context.Context, before time.Time) (int64, error) {
+	result := r.conn(ctx).
+		Where("processed_at < ?", before).
+		Delete(&dbmodels.ProcessedEvent{})
+	return result.RowsAffected, result.Error
+}
+
+// companyEventBatchSize is the number of stored events loaded per query when
+// iterating over the event history.
+var companyEventBatchSize = 500
+
+// RecordCompanyEvent appends event to the company event history.
+func (r *Repository) RecordCompanyEvent(ctx context.Context, event *models.CompanyEvent) error {
+	return r.conn(ctx).Create(event).Error
+}
+
+// RecordCompliance appends record to the compliance records. Records of
+// successful calls join the transaction ctx carries, so a call whose record
+// cannot be stored is rolled back with it; records of failed calls, which
+// carry an Error, are stored on a connection of their own, as the
+// transaction of the call rolls back.
+func (r *Repository) RecordCompliance(ctx context.Context, record *models.ComplianceRecord) error {
+	if record.Error != "" {
+		return r.db.WithContext(ctx).Create(record).Error
+	}
+	return r.conn(ctx).Create(record).Error
+}
+
+// PurgeComplianceRecords removes the compliance records created before
+// before and returns how many were removed.
+func (r *Repository) PurgeComplianceRecords(ctx context.Context, before time.Time) (int64, error) {
+	result := r.conn(ctx).Where("created_at < ?", before).Delete(&models.ComplianceRecord{})
+	return result.RowsAffected, result.Error
+}
+
+// ListCompanyEvents returns up to limit stored events matching filter,
+// oldest first. Pages are read with filter.After set to the position of the
+// last event of the previous one, which the indexes serve without scanning
+// the skipped events.
+func (r *Repository) ListCompanyEvents(ctx context.Context, filter models.CompanyEventFilter, limit int) ([]models.CompanyEvent, error) {
+	var events []models.CompanyEvent
+	err := r.companyEvents(ctx, filter).Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// ForEachCompanyEvent calls fn for every stored event matching filter, oldest
+// first, loading them in batches. Iteration stops at the first error.
+func (r *Repository) ForEachCompanyEvent(ctx context.Context, filter models.CompanyEventFilter, fn func(*models.CompanyEvent) error) error {
+	for {
+		var events []models.CompanyEvent
+		if err := r.companyEvents(ctx, filter).Limit(companyEventBatchSize).Find(&events).Error; err != nil {
+			return err
+		}
+		for i := range events {
+			if err := fn(&events[i]); err != nil {
+				return err
+			}
+		}
+		if len(events) < companyEventBatchSize {
+			return nil
+		}
+		last := events[len(events)-1].Position()
+		filter.After = &last
+	}
+}
+
+// companyEvents returns the query for the stored events matching filter, in
+// history order.
+func (r *Repository) companyEvents(ctx context.Context, filter models.CompanyEventFilter) *gorm.DB {
+	query := r.conn(ctx).Model(&models.CompanyEvent{})
+	if len(filter.CompanyIDs) > 0 {
+		query = query.Where("company_id IN ?", filter.CompanyIDs)
+	}
+	if len(filter.Types) > 0 {
+		query = query.Where("type IN ?", filter.Types)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+	if filter.After != nil {
+		// Keyset pagination keeps the order stable while events are appended.
+		query = query.Where("(created_at > ?) OR (created_at = ? AND id > ?)", filter.After.CreatedAt, filter.After.CreatedAt, filter.After.ID)
+	}
+	return query.Order("created_at, id")
+}
+
+// CreateAPIKey stores a new API key. Only its hash is persisted.
+func (r *Repository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	return r.conn(ctx).Create(key).Error
+}
+
+// GetAPIKeyByHash returns the API key stored under hash.
+func (r *Repository) GetAPIKeyByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	result := r.conn(ctx).First(&key, "key_hash = ?", hash)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, e.ErrNotFound
+		}
+		return nil, result.Error
+	}
+	return &key, nil
+}
+
+// RevokeAPIKey marks the key as revoked so it is no longer accepted.
+func (r *Repository) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	result := r.conn(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return e.ErrNotFound
+	}
+	return nil
+}
+
+// CreateAlertWebhook stores a new alert webhook.
+func (r *Repository) CreateAlertWebhook(ctx context.Context, webhook *models.AlertWebhook) error {
+	return r.conn(ctx).Create(webhook).Error
+}
+
+// ListAlertWebhooks returns every alert webhook, oldest first.
+func (r *Repository) ListAlertWebhooks(ctx context.Context) ([]models.AlertWebhook, error) {
+	var webhooks []models.AlertWebhook
+	err := r.conn(ctx).Order("created_at, id").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// DeleteAlertWebhook removes the alert webhook with the given ID.
+func (r *Repository) DeleteAlertWebhook(ctx context.Context, id uuid.UUID) error {
+	result := r.conn(ctx).Delete(&models.AlertWebhook{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return e.ErrNotFound
+	}
+	return nil
+}
+
+// Exec runs an arbitrary statement. It is meant for tests setting up data;
+// reports run the reviewed queries of RunNamedQuery.
 func (r *Repository) Exec(ctx context.Context, query string, params ...interface{}) error {
-	result := r.db.WithContext(ctx).Exec(query, params...)
+	result := r.conn(ctx).Exec(query, params...)
 	if result.Error != nil {
 		return result.Error
 	}
 	return nil
 }
 
+// Ping checks that the database is reachable.
+func (r *Repository) Ping(ctx context.Context) error {
+	db, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	return db.PingContext(ctx)
+}
+
 func (r *Repository) Close() error {
 	db, err := r.db.DB()
 	if err != nil {
@@ -120,3 +1059,86 @@ func (r *Repository) Close() error {
 	}
 	return db.Close()
 }
+
+// GetTenantQuota returns the quota set for tenant, or ErrNotFound when none
+// was set.
+func (r *Repository) GetTenantQuota(ctx context.Context, tenant string) (*models.TenantQuota, error) {
+	var quota models.TenantQuota
+	result := r.conn(ctx).First(&quota, "tenant_id = ?", tenant)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, e.ErrNotFound
+		}
+		return nil, result.Error
+	}
+	return &quota, nil
+}
+
+// SetTenantQuota stores quota, replacing the one of the same tenant.
+func (r *Repository) SetTenantQuota(ctx context.Context, quota *models.TenantQuota) error {
+	return r.conn(ctx).
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(quota).
+		Error
+}
+
+// CountTenantCompanies returns the number of live companies of tenant.
+func (r *Repository) CountTenantCompanies(ctx context.Context, tenant string) (int64, error) {
+	var count int64
+	result := r.conn(ctx).Model(&models.Company{}).
+		Where("tenant_id = ?", tenant).
+		Count(&count)
+	return count, result.Error
+}
+
+// LockTenantCompanies serializes the transactions creating companies of
+// tenant until the one ctx carries ends, so that each counts the companies
+// of the others. On PostgreSQL it takes a transaction-level advisory lock;
+// SQLite transactions already take the write lock when they begin.
+func (r *Repository) LockTenantCompanies(ctx context.Context, tenant string) error {
+	if r.db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("tenant-companies:" + tenant))
+	return r.conn(ctx).Exec("SELECT pg_advisory_xact_lock(?)", r.lockKey(int64(h.Sum64()))).Error
+}
+
+// IncrementTenantMutations counts a mutation of tenant in the minute starting
+// at window and returns the mutations counted in it so far. The counts of
+// earlier minutes are deleted when a new minute starts. The count never joins
+// a request transaction, so failed mutations are counted too and the
+// counter row is not locked while the request runs.
+func (r *Repository) IncrementTenantMutations(ctx context.Context, tenant string, window time.Time) (int, error) {
+	counter := dbmodels.TenantMutationCount{TenantID: tenant, WindowStart: window, Count: 1}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "window_start"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"count": gorm.Expr("tenant_mutation_counts.count + 1")}),
+		}).Create(&counter).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&counter, "tenant_id = ? AND window_start = ?", tenant, window).Error; err != nil {
+			return err
+		}
+		if counter.Count > 1 {
+			return nil
+		}
+		return tx.Where("tenant_id = ? AND window_start < ?", tenant, window).
+			Delete(&dbmodels.TenantMutationCount{}).
+			Error
+	})
+	return counter.Count, err
+}
+
+// TenantMutations returns the mutations of tenant counted in the minute
+// starting at window.
+func (r *Repository) TenantMutations(ctx context.Context, tenant string, window time.Time) (int, error) {
+	var counter dbmodels.TenantMutationCount
+	result := r.conn(ctx).
+		Where("tenant_id = ? AND window_start = ?", tenant, window).
+		Limit(1).
+		Find(&counter)
+	return counter.Count, result.Error
+}
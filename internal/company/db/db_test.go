@@ -2,7 +2,11 @@ package db
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
 	"testing"
+	"time"
 
 	e "github.com/gartstein/xm/internal/company/errors"
 	"github.com/gartstein/xm/internal/company/models"
@@ -15,16 +19,108 @@ import (
 )
 
 // SetupTestDB initializes an in-memory SQLite database for testing.
-func SetupTestDB(t *testing.T) *Repository {
+func SetupTestDB(t testing.TB) *Repository {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err, "failed to open test database")
 
-	err = db.AutoMigrate(&models.Company{})
+	err = migrate(db)
 	require.NoError(t, err, "failed to migrate test database")
 
 	return &Repository{db: db}
 }
 
+// TestDataSourceName verifies schemas are validated and put on the
+// search_path.
+func TestDataSourceName(t *testing.T) {
+	cfg := &Config{Host: "postgres", Port: 5432, User: "xm", Password: "xm", DBName: "xm", SSLMode: "disable"}
+	dsn, err := dataSourceName(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "host=postgres port=5432 user=xm password=xm dbname=xm sslmode=disable", dsn)
+
+	cfg.Schema = "staging"
+	dsn, err = dataSourceName(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "host=postgres port=5432 user=xm password=xm dbname=xm sslmode=disable search_path=staging,public", dsn)
+
+	for _, schema := range []string{"Staging", "env-1", "1env", "a b", "x;DROP TABLE companies"} {
+		cfg.Schema = schema
+		_, err := dataSourceName(cfg)
+		assert.ErrorContains(t, err, "invalid schema name", schema)
+	}
+}
+
+// TestNewRepository_SQLite verifies a file-backed SQLite database is
+// migrated, survives reopening and takes concurrent writes.
+func TestNewRepository_SQLite(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{Driver: "sqlite", Path: filepath.Join(t.TempDir(), "company.db")}
+	repo, err := NewRepository(cfg)
+	require.NoError(t, err)
+
+	var journalMode string
+	require.NoError(t, repo.db.Raw("PRAGMA journal_mode").Scan(&journalMode).Error)
+	assert.Equal(t, "wal", journalMode)
+
+	errs := make(chan error, 20)
+	for i := range cap(errs) {
+		go func() {
+			errs <- repo.WithTransaction(ctx, func(tx *Repository) error {
+				return tx.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: fmt.Sprintf("Company %d", i)})
+			})
+		}()
+	}
+	for range cap(errs) {
+		require.NoError(t, <-errs)
+	}
+	sqlDB, err := repo.db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+
+	repo, err = NewRepository(cfg)
+	require.NoError(t, err)
+	count, err := repo.CountCompanies(ctx, models.CompanyFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(cap(errs)), count)
+
+	_, err = NewRepository(&Config{Driver: "sqlite"})
+	assert.ErrorContains(t, err, "needs the path")
+	_, err = NewRepository(&Config{Driver: "mysql"})
+	assert.EqualError(t, err, `unknown database driver "mysql"`)
+}
+
+// TestPendingMigrations verifies missing tables and columns are reported
+// without being created, and nothing once the schema is migrated.
+func TestPendingMigrations(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{Driver: "sqlite", Path: filepath.Join(t.TempDir(), "company.db")}
+	repo, err := NewRepository(cfg, WithoutMigration())
+	require.NoError(t, err)
+	pending, err := repo.PendingMigrations(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, pending, "companies")
+	assert.Len(t, pending, len(tables))
+
+	require.NoError(t, migrate(repo.db))
+	require.NoError(t, repo.db.Exec("ALTER TABLE companies DROP COLUMN contact_email").Error)
+	pending, err = repo.PendingMigrations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"companies.contact_email"}, pending)
+
+	require.NoError(t, migrate(repo.db))
+	pending, err = repo.PendingMigrations(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+// TestLockKey verifies advisory lock keys differ between schemas.
+func TestLockKey(t *testing.T) {
+	assert.Equal(t, int64(42), (&Repository{}).lockKey(42))
+	staging, production := &Repository{schema: "staging"}, &Repository{schema: "production"}
+	assert.NotEqual(t, staging.lockKey(42), production.lockKey(42))
+	assert.NotEqual(t, staging.lockKey(42), staging.lockKey(43))
+	assert.Equal(t, staging.lockKey(42), staging.lockKey(42))
+}
+
 // TestCreateCompany tests the creation of a company record.
 func TestCreateCompany(t *testing.T) {
 	repo := SetupTestDB(t)
@@ -96,6 +192,342 @@ func TestUpdateCompany(t *testing.T) {
 	assert.Equal(t, "New Name", updated.Name, "Company name should be updated")
 }
 
+// TestCreateCompanyDuplicateName verifies the unique index on live names
+// yields ErrDuplicateName, as when a concurrent create takes a name between
+// the check of the service and the insert.
+func TestCreateCompanyDuplicateName(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "Acme"}))
+	err := repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "Acme"})
+	assert.ErrorIs(t, err, e.ErrDuplicateName)
+}
+
+// TestUpdateCompanyDuplicateName verifies renaming a company to the name of
+// another live company yields ErrDuplicateName.
+func TestUpdateCompanyDuplicateName(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	acme := &models.Company{ID: uuid.New(), Name: "Acme"}
+	initech := &models.Company{ID: uuid.New(), Name: "Initech"}
+	require.NoError(t, repo.CreateCompany(ctx, acme))
+	require.NoError(t, repo.CreateCompany(ctx, initech))
+
+	err := repo.UpdateCompany(ctx, &models.CompanyUpdate{ID: initech.ID, Name: utils.Ptr("Acme")})
+	assert.ErrorIs(t, err, e.ErrDuplicateName)
+
+	// Deleted companies release their name.
+	require.NoError(t, repo.DeleteCompany(ctx, acme.ID))
+	assert.NoError(t, repo.UpdateCompany(ctx, &models.CompanyUpdate{ID: initech.ID, Name: utils.Ptr("Acme")}))
+}
+
+// TestUpdateCompanyClearsEmployeeRange checks a range can be reset to unknown.
+func TestUpdateCompanyClearsEmployeeRange(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	company := &models.Company{ID: uuid.New(), Name: "Acme", Employees: 5, EmployeeRange: models.Employees1To10}
+	require.NoError(t, repo.CreateCompany(ctx, company))
+
+	require.NoError(t, repo.UpdateCompany(ctx, &models.CompanyUpdate{
+		ID:            company.ID,
+		Employees:     utils.Ptr(0),
+		EmployeeRange: utils.Ptr(models.EmployeeRangeUnknown),
+	}))
+
+	updated, err := repo.GetCompany(ctx, company.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, updated.Employees)
+	assert.Equal(t, models.EmployeeRangeUnknown, updated.EmployeeRange)
+}
+
+// TestBackfillEmployeeRanges checks rows predating the column get a range.
+func TestBackfillEmployeeRanges(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	legacy := &models.Company{ID: uuid.New(), Name: "Legacy", Employees: 120}
+	empty := &models.Company{ID: uuid.New(), Name: "Empty"}
+	require.NoError(t, repo.CreateCompany(ctx, legacy))
+	require.NoError(t, repo.CreateCompany(ctx, empty))
+
+	require.NoError(t, backfillEmployeeRanges(repo.db))
+
+	got, err := repo.GetCompany(ctx, legacy.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.Employees51To200, got.EmployeeRange)
+	got, err = repo.GetCompany(ctx, empty.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.EmployeeRangeUnknown, got.EmployeeRange)
+}
+
+// TestGetCompanyByName tests case-insensitive lookups by name.
+func TestGetCompanyByName(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	company := &models.Company{ID: uuid.New(), Name: "Acme Corp"}
+	require.NoError(t, repo.CreateCompany(ctx, company))
+
+	for _, name := range []string{"Acme Corp", "acme corp", "ACME CORP"} {
+		got, err := repo.GetCompanyByName(ctx, name)
+		require.NoError(t, err, name)
+		assert.Equal(t, company.ID, got.ID, name)
+	}
+
+	_, err := repo.GetCompanyByName(ctx, "Acme")
+	assert.ErrorIs(t, err, e.ErrNotFound)
+}
+
+// TestFindSimilarCompanies tests the trigram fallback used outside PostgreSQL.
+func TestFindSimilarCompanies(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+	require.NoError(t, repo.EnableNameSimilarity(ctx))
+
+	for _, name := range []string{"Acme Corp", "Acme Corporation", "Globex"} {
+		require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: name}))
+	}
+
+	similar, err := repo.FindSimilarCompanies(ctx, "Acme Crop", 0.25, 5)
+	require.NoError(t, err)
+	require.Len(t, similar, 2)
+	assert.Equal(t, "Acme Corp", similar[0].Name, "closest match should come first")
+
+	similar, err = repo.FindSimilarCompanies(ctx, "Acme Crop", 0.25, 1)
+	require.NoError(t, err)
+	assert.Len(t, similar, 1)
+
+	similar, err = repo.FindSimilarCompanies(ctx, "Initech", 0.3, 5)
+	require.NoError(t, err)
+	assert.Empty(t, similar)
+}
+
+// TestGetCompanyByExternalRef tests lookups and uniqueness of external references.
+func TestGetCompanyByExternalRef(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	company := &models.Company{ID: uuid.New(), Name: "Acme", ExternalRef: "ERP-1"}
+	require.NoError(t, repo.CreateCompany(ctx, company))
+	// Companies without a reference do not collide with each other.
+	require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "No Ref 1"}))
+	require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "No Ref 2"}))
+
+	got, err := repo.GetCompanyByExternalRef(ctx, "ERP-1")
+	require.NoError(t, err)
+	assert.Equal(t, company.ID, got.ID)
+
+	_, err = repo.GetCompanyByExternalRef(ctx, "ERP-2")
+	assert.ErrorIs(t, err, e.ErrNotFound)
+
+	err = repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "Dup", ExternalRef: "ERP-1"})
+	assert.ErrorIs(t, err, e.ErrDuplicateExternalRef, "duplicate external references should be rejected")
+	other := &models.Company{ID: uuid.New(), Name: "Other"}
+	require.NoError(t, repo.CreateCompany(ctx, other))
+	err = repo.UpdateCompany(ctx, &models.CompanyUpdate{ID: other.ID, ExternalRef: utils.Ptr("ERP-1")})
+	assert.ErrorIs(t, err, e.ErrDuplicateExternalRef, "taking the reference of another company should be rejected")
+
+	// Deleted companies release their reference, as they do their name.
+	require.NoError(t, repo.DeleteCompany(ctx, company.ID))
+	_, err = repo.GetCompanyByExternalRef(ctx, "ERP-1")
+	assert.ErrorIs(t, err, e.ErrNotFound)
+	recreated := &models.Company{ID: uuid.New(), Name: "Acme", ExternalRef: "ERP-1"}
+	require.NoError(t, repo.CreateCompany(ctx, recreated))
+	got, err = repo.GetCompanyByExternalRef(ctx, "ERP-1")
+	require.NoError(t, err)
+	assert.Equal(t, recreated.ID, got.ID)
+}
+
+func TestUpsertCompanies(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	existing := &models.Company{ID: uuid.New(), Name: "Acme", Employees: 10, CreatedBy: "alice"}
+	require.NoError(t, repo.CreateCompany(ctx, existing))
+	deleted := &models.Company{ID: uuid.New(), Name: "Gone"}
+	require.NoError(t, repo.CreateCompany(ctx, deleted))
+	require.NoError(t, repo.DeleteCompany(ctx, deleted.ID))
+
+	companies := []models.Company{
+		{ID: uuid.New(), Name: "Acme", Employees: 20, Description: "updated", CreatedBy: "importer"},
+		{ID: uuid.New(), Name: "Globex", Employees: 5},
+		{ID: uuid.New(), Name: "Initech", Employees: 7},
+		{ID: uuid.New(), Name: "Gone", Employees: 3},
+	}
+	rows, err := repo.UpsertCompanies(ctx, companies, 3)
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, rows)
+
+	got, err := repo.GetCompany(ctx, existing.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 20, got.Employees)
+	assert.Equal(t, "updated", got.Description)
+	assert.Equal(t, "alice", got.CreatedBy, "creation fields should be kept")
+
+	for _, name := range []string{"Globex", "Initech", "Gone"} {
+		got, err := repo.GetCompanyByName(ctx, name)
+		require.NoError(t, err, name)
+		assert.NotEqual(t, deleted.ID, got.ID, "deleted companies should not be revived")
+	}
+
+	rows, err = repo.UpsertCompanies(ctx, nil, 0)
+	require.NoError(t, err)
+	assert.Zero(t, rows)
+}
+
+// TestListCompanies tests filtering and paging through companies.
+func TestListCompanies(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	ranges := []models.EmployeeRange{models.Employees1To10, models.Employees11To50, models.Employees1To10}
+	for i, r := range ranges {
+		require.NoError(t, repo.CreateCompany(ctx, &models.Company{
+			ID:            uuid.New(),
+			Name:          fmt.Sprintf("Company %d", i),
+			EmployeeRange: r,
+			CreatedAt:     start.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+
+	page, err := repo.ListCompanies(ctx, models.CompanyFilter{}, 0, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "Company 0", page[0].Name)
+	assert.Equal(t, "Company 1", page[1].Name)
+
+	page, err = repo.ListCompanies(ctx, models.CompanyFilter{}, 2, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "Company 2", page[0].Name)
+
+	page, err = repo.ListCompanies(ctx, models.CompanyFilter{EmployeeRanges: []models.EmployeeRange{models.Employees1To10}}, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "Company 2", page[1].Name)
+
+	require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "100% Acme_Labs"}))
+	page, err = repo.ListCompanies(ctx, models.CompanyFilter{NameContains: "PANY 1"}, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "Company 1", page[0].Name)
+
+	// Wildcards in the query match literally.
+	page, err = repo.ListCompanies(ctx, models.CompanyFilter{NameContains: "0% acme_"}, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	page, err = repo.ListCompanies(ctx, models.CompanyFilter{NameContains: "_"}, 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, page, 1)
+}
+
+// TestListCompaniesByStatus checks the status filter and that companies
+// stored without a status are ACTIVE.
+func TestListCompaniesByStatus(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "Legacy"}))
+	require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "Draft", Status: models.StatusDraft}))
+	require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "Suspended", Status: models.StatusSuspended}))
+
+	page, err := repo.ListCompanies(ctx, models.CompanyFilter{Statuses: []models.CompanyStatus{models.StatusActive}}, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "Legacy", page[0].Name)
+
+	page, err = repo.ListCompanies(ctx, models.CompanyFilter{Statuses: []models.CompanyStatus{models.StatusDraft, models.StatusSuspended}}, 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, page, 2)
+}
+
+// TestCompanyMetadata checks metadata is merged on update and filterable.
+func TestCompanyMetadata(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	tagged := &models.Company{ID: uuid.New(), Name: "Tagged", Metadata: models.Metadata{"crm": "salesforce", "tier": "gold"}}
+	require.NoError(t, repo.CreateCompany(ctx, tagged))
+	require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "Plain"}))
+
+	require.NoError(t, repo.UpdateCompany(ctx, &models.CompanyUpdate{
+		ID:       tagged.ID,
+		Metadata: models.Metadata{"tier": "", "region": "emea"},
+	}))
+	got, err := repo.GetCompany(ctx, tagged.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.Metadata{"crm": "salesforce", "region": "emea"}, got.Metadata)
+
+	page, err := repo.ListCompanies(ctx, models.CompanyFilter{Metadata: models.Metadata{"crm": "salesforce", "region": "emea"}}, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "Tagged", page[0].Name)
+
+	count, err := repo.CountCompanies(ctx, models.CompanyFilter{Metadata: models.Metadata{"crm": "hubspot"}})
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	require.NoError(t, repo.UpdateCompany(ctx, &models.CompanyUpdate{
+		ID:       tagged.ID,
+		Metadata: models.Metadata{"crm": "", "region": ""},
+	}))
+	got, err = repo.GetCompany(ctx, tagged.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got.Metadata, "removing every key clears the metadata")
+}
+
+func TestCountCompanies(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "Active"}))
+	require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "Draft", Status: models.StatusDraft}))
+	deleted := &models.Company{ID: uuid.New(), Name: "Deleted"}
+	require.NoError(t, repo.CreateCompany(ctx, deleted))
+	require.NoError(t, repo.DeleteCompany(ctx, deleted.ID))
+
+	count, err := repo.CountCompanies(ctx, models.CompanyFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count, "deleted companies are not counted")
+
+	count, err = repo.CountCompanies(ctx, models.CompanyFilter{Statuses: []models.CompanyStatus{models.StatusDraft}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+// TestListCompaniesCreatedBy checks the creator filter.
+func TestListCompaniesCreatedBy(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "Mine", CreatedBy: "alice"}))
+	require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "Theirs", CreatedBy: "bob"}))
+
+	page, err := repo.ListCompanies(ctx, models.CompanyFilter{CreatedBy: "alice"}, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "Mine", page[0].Name)
+}
+
+// TestListCompaniesUpdatedBefore checks the inactivity filter.
+func TestListCompaniesUpdatedBefore(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	old := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "Old", CreatedAt: old, UpdatedAt: old}))
+	require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "New"}))
+
+	page, err := repo.ListCompanies(ctx, models.CompanyFilter{UpdatedBefore: time.Now().Add(-24 * time.Hour)}, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "Old", page[0].Name)
+}
+
 // TestUpdateCompanyNotFound tests updating a non-existing company.
 func TestUpdateCompanyNotFound(t *testing.T) {
 	repo := SetupTestDB(t)
@@ -110,6 +542,39 @@ func TestUpdateCompanyNotFound(t *testing.T) {
 	assert.ErrorIs(t, err, e.ErrNotFound, "UpdateCompany should return ErrNotFound for missing company")
 }
 
+// TestUpdateCompanyReturning checks the previous and updated rows are returned from the same transaction.
+func TestUpdateCompanyReturning(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	company := &models.Company{
+		ID:        uuid.New(),
+		Name:      "Old Name",
+		Employees: 10,
+		CreatedBy: "creator",
+	}
+	require.NoError(t, repo.CreateCompany(ctx, company), "CreateCompany should succeed")
+
+	before, updated, err := repo.UpdateCompanyReturning(ctx, &models.CompanyUpdate{
+		ID:        company.ID,
+		Name:      utils.Ptr("New Name"),
+		UpdatedBy: "editor",
+	})
+	require.NoError(t, err, "UpdateCompanyReturning should not return an error")
+	assert.Equal(t, "Old Name", before.Name, "Previous row should be returned unchanged")
+	assert.Equal(t, company.ID, updated.ID, "Company ID should match")
+	assert.Equal(t, "New Name", updated.Name, "Returned company should carry the update")
+	assert.Equal(t, 10, updated.Employees, "Untouched fields should be preserved")
+	assert.Equal(t, "creator", updated.CreatedBy, "CreatedBy should be preserved")
+	assert.Equal(t, "editor", updated.UpdatedBy, "UpdatedBy should record the editor")
+
+	_, _, err = repo.UpdateCompanyReturning(ctx, &models.CompanyUpdate{
+		ID:   uuid.New(),
+		Name: utils.Ptr("Missing"),
+	})
+	assert.ErrorIs(t, err, e.ErrNotFound, "UpdateCompanyReturning should return ErrNotFound for missing company")
+}
+
 // TestDeleteCompany ensures companies are deleted correctly.
 func TestDeleteCompany(t *testing.T) {
 	repo := SetupTestDB(t)
@@ -129,6 +594,84 @@ func TestDeleteCompany(t *testing.T) {
 	assert.ErrorIs(t, err, e.ErrNotFound, "Deleted company should not be found")
 }
 
+// TestDeleteCompanyIsSoft verifies deleted rows are kept until purged.
+func TestDeleteCompanyIsSoft(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	company := &models.Company{
+		ID:   uuid.New(),
+		Name: "Soft Deleted",
+	}
+	require.NoError(t, repo.CreateCompany(ctx, company), "CreateCompany should succeed")
+	require.NoError(t, repo.DeleteCompany(ctx, company.ID), "DeleteCompany should succeed")
+
+	var count int64
+	require.NoError(t, repo.db.Unscoped().Model(&models.Company{}).Where("id = ?", company.ID).Count(&count).Error)
+	assert.Equal(t, int64(1), count, "Soft-deleted row should remain in the table")
+
+	deleted, err := repo.GetCompanyIncludingDeleted(ctx, company.ID)
+	require.NoError(t, err, "GetCompanyIncludingDeleted should find soft-deleted companies")
+	assert.Equal(t, company.Name, deleted.Name)
+	_, err = repo.GetCompanyIncludingDeleted(ctx, uuid.New())
+	assert.ErrorIs(t, err, e.ErrNotFound)
+}
+
+// TestPurgeCompany ensures only soft-deleted companies can be purged.
+func TestPurgeCompany(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	company := &models.Company{
+		ID:   uuid.New(),
+		Name: "To Be Purged",
+	}
+	require.NoError(t, repo.CreateCompany(ctx, company), "CreateCompany should succeed")
+
+	err := repo.PurgeCompany(ctx, company.ID)
+	assert.ErrorIs(t, err, e.ErrNotFound, "Active companies should not be purged")
+
+	require.NoError(t, repo.DeleteCompany(ctx, company.ID), "DeleteCompany should succeed")
+	assert.NoError(t, repo.PurgeCompany(ctx, company.ID), "Soft-deleted company should be purged")
+
+	var count int64
+	require.NoError(t, repo.db.Unscoped().Model(&models.Company{}).Where("id = ?", company.ID).Count(&count).Error)
+	assert.Zero(t, count, "Purged row should be gone")
+}
+
+// TestPurgeDeletedCompanies checks the retention cut-off is honored.
+func TestPurgeDeletedCompanies(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	old := &models.Company{ID: uuid.New(), Name: "Old"}
+	recent := &models.Company{ID: uuid.New(), Name: "Recent"}
+	active := &models.Company{ID: uuid.New(), Name: "Active"}
+	for _, c := range []*models.Company{old, recent, active} {
+		require.NoError(t, repo.CreateCompany(ctx, c), "CreateCompany should succeed")
+		require.NoError(t, repo.CreateEmployee(ctx, &models.Employee{ID: uuid.New(), CompanyID: c.ID, Name: "Jane"}))
+	}
+	require.NoError(t, repo.DeleteCompany(ctx, old.ID))
+	require.NoError(t, repo.DeleteCompany(ctx, recent.ID))
+	require.NoError(t, repo.db.Unscoped().Model(&models.Company{}).
+		Where("id = ?", old.ID).
+		Update("deleted_at", time.Now().Add(-48*time.Hour)).Error)
+
+	purged, err := repo.PurgeDeletedCompanies(ctx, time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged, "Only the old soft-deleted company should be purged")
+
+	var remaining int64
+	require.NoError(t, repo.db.Unscoped().Model(&models.Company{}).Count(&remaining).Error)
+	assert.Equal(t, int64(2), remaining)
+	employees, err := repo.CountEmployees(ctx, old.ID)
+	require.NoError(t, err)
+	assert.Zero(t, employees, "The employees of purged companies should be purged")
+	employees, err = repo.CountEmployees(ctx, recent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), employees)
+}
+
 // TestDeleteCompanyNotFound checks behavior when trying to delete a non-existent company.
 func TestDeleteCompanyNotFound(t *testing.T) {
 	repo := SetupTestDB(t)
@@ -177,3 +720,632 @@ func TestWithTransaction(t *testing.T) {
 	exists, _ := repo.CompanyExistsByName(ctx, "Transactional Company")
 	assert.True(t, exists, "Company should exist after transaction")
 }
+
+// TestRunInTransaction ensures repository calls join the transaction carried
+// by their context and deferred work only runs after a commit.
+func TestRunInTransaction(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	committed := false
+	err := repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "Committed"}))
+		AfterCommit(ctx, func() { committed = true })
+		// Nested calls and WithTransaction join the outer transaction.
+		return repo.RunInTransaction(ctx, func(ctx context.Context) error {
+			return repo.WithTransaction(ctx, func(tx *Repository) error {
+				exists, err := tx.CompanyExistsByName(ctx, "Committed")
+				assert.True(t, exists, "the company should be visible inside the transaction")
+				return err
+			})
+		})
+	})
+	require.NoError(t, err)
+	assert.True(t, committed, "AfterCommit should run once committed")
+
+	rolledBack := false
+	err = repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		require.NoError(t, repo.CreateCompany(ctx, &models.Company{ID: uuid.New(), Name: "Rolled Back"}))
+		require.NoError(t, repo.RecordCompanyEvent(ctx, &models.CompanyEvent{ID: uuid.New(), Type: "company_created"}))
+		AfterCommit(ctx, func() { rolledBack = true })
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+	assert.False(t, rolledBack, "AfterCommit should not run after a rollback")
+
+	exists, err := repo.CompanyExistsByName(ctx, "Committed")
+	require.NoError(t, err)
+	assert.True(t, exists, "the committed company should persist")
+	exists, err = repo.CompanyExistsByName(ctx, "Rolled Back")
+	require.NoError(t, err)
+	assert.False(t, exists, "the rolled back company should not persist")
+
+	ran := false
+	AfterCommit(ctx, func() { ran = true })
+	assert.True(t, ran, "AfterCommit should run right away without a transaction")
+}
+
+// TestProcessedEvents verifies dedup records are scoped per consumer group and purgeable.
+func TestProcessedEvents(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+	eventID := uuid.New()
+
+	processed, err := repo.IsEventProcessed(ctx, "group-a", eventID)
+	require.NoError(t, err)
+	assert.False(t, processed, "unseen event should not be processed")
+
+	require.NoError(t, repo.MarkEventProcessed(ctx, "group-a", eventID))
+	require.NoError(t, repo.MarkEventProcessed(ctx, "group-a", eventID), "marking twice should be idempotent")
+
+	processed, err = repo.IsEventProcessed(ctx, "group-a", eventID)
+	require.NoError(t, err)
+	assert.True(t, processed, "marked event should be processed")
+
+	processed, err = repo.IsEventProcessed(ctx, "group-b", eventID)
+	require.NoError(t, err)
+	assert.False(t, processed, "other groups should not see the record")
+
+	purged, err := repo.PurgeProcessedEvents(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	processed, err = repo.IsEventProcessed(ctx, "group-a", eventID)
+	require.NoError(t, err)
+	assert.False(t, processed, "purged event should no longer be processed")
+}
+
+// TestUsedTokens verifies a single-use token is only accepted once and its
+// record is purged after expiry.
+func TestUsedTokens(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+	expires := time.Now().Add(time.Minute)
+
+	fresh, err := repo.UseToken(ctx, "jti-1", expires)
+	require.NoError(t, err)
+	assert.True(t, fresh)
+	fresh, err = repo.UseToken(ctx, "jti-1", expires)
+	require.NoError(t, err)
+	assert.False(t, fresh, "a replayed token is not fresh")
+
+	purged, err := repo.PurgeUsedTokens(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, purged, "unexpired tokens are kept")
+	purged, err = repo.PurgeUsedTokens(ctx, expires.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+}
+
+// TestOutboxEvents verifies queued events are listed in the order they were
+// queued, only once their transaction committed, until deleted.
+func TestOutboxEvents(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+	company := uuid.New()
+	errAbort := errors.New("abort")
+
+	var queued []uuid.UUID
+	for _, eventType := range []string{"company_created", "company_updated", "company_deleted"} {
+		id := uuid.New()
+		err := repo.RunInTransaction(ctx, func(ctx context.Context) error {
+			if err := repo.RecordCompanyEvent(ctx, &models.CompanyEvent{ID: id, Type: eventType, CompanyID: company}); err != nil {
+				return err
+			}
+			return repo.EnqueueOutboxEvent(ctx, company, id)
+		})
+		require.NoError(t, err)
+		queued = append(queued, id)
+	}
+	err := repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		id := uuid.New()
+		require.NoError(t, repo.RecordCompanyEvent(ctx, &models.CompanyEvent{ID: id, Type: "company_updated", CompanyID: company}))
+		require.NoError(t, repo.EnqueueOutboxEvent(ctx, company, id))
+		return errAbort
+	})
+	require.ErrorIs(t, err, errAbort)
+
+	listed, err := repo.ListOutboxEvents(ctx, 10)
+	require.NoError(t, err)
+	var ids []uuid.UUID
+	for _, event := range listed {
+		ids = append(ids, event.ID)
+	}
+	assert.Equal(t, queued, ids, "rolled back events should not be queued")
+	assert.Equal(t, "company_created", listed[0].Type)
+
+	listed, err = repo.ListOutboxEvents(ctx, 2)
+	require.NoError(t, err)
+	assert.Len(t, listed, 2)
+
+	require.NoError(t, repo.DeleteOutboxEvents(ctx, queued[:2]))
+	listed, err = repo.ListOutboxEvents(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, queued[2], listed[0].ID)
+}
+
+// TestEraseCompanyData verifies personal data is scrubbed from soft-deleted
+// companies and their event history, and the rest is kept.
+func TestEraseCompanyData(t *testing.T) {
+	useKeyring(t, "k1")
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	company := &models.Company{ID: uuid.New(), Name: "Acme", Description: "Run by Jane Doe", Employees: 12, ContactEmail: "jane@acme.test"}
+	require.NoError(t, repo.CreateCompany(ctx, company))
+	require.NoError(t, repo.RecordCompanyEvent(ctx, &models.CompanyEvent{
+		ID:        uuid.New(),
+		Type:      "company_updated",
+		CompanyID: company.ID,
+		Company:   *company,
+		Changes:   map[string]models.FieldChange{"description": {Old: "", New: company.Description}, "employees": {Old: float64(10), New: float64(12)}},
+	}))
+	require.NoError(t, repo.CreateEmployee(ctx, &models.Employee{ID: uuid.New(), CompanyID: company.ID, Name: "Jane Doe", Email: "jane@acme.test"}))
+	updateCall := &models.ComplianceRecord{
+		Method:   "/definition.v1.CompanyService/UpdateCompany",
+		UserID:   "alice",
+		Code:     "OK",
+		Request:  `{"id":"` + company.ID.String() + `","company":{"description":"Run by Jane Doe","contactEmail":"jane@acme.test"}}`,
+		Response: `{"company":{"id":"` + company.ID.String() + `","name":"Acme","description":"Run by Jane Doe","employees":12}}`,
+	}
+	employeeCall := &models.ComplianceRecord{
+		Method:  "/definition.v1.CompanyService/CreateEmployee",
+		Code:    "InvalidArgument",
+		Error:   "invalid employee",
+		Request: `{"companyId":"` + company.ID.String() + `","employee":{"name":"Jane Doe","email":"jane@acme.test"}}`,
+	}
+	otherCall := &models.ComplianceRecord{
+		Method:   "/definition.v1.CompanyService/UpdateCompany",
+		Code:     "OK",
+		Request:  `{"id":"` + uuid.NewString() + `","company":{"description":"Run by John Roe"}}`,
+		Response: `{"company":{"description":"Run by John Roe"}}`,
+	}
+	for _, record := range []*models.ComplianceRecord{updateCall, employeeCall, otherCall} {
+		require.NoError(t, repo.RecordCompliance(ctx, record))
+	}
+	require.NoError(t, repo.DeleteCompany(ctx, company.ID))
+
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	erased, err := repo.EraseCompanyData(ctx, company.ID, "dpo", at)
+	require.NoError(t, err)
+	assert.Empty(t, erased.Description)
+	assert.Empty(t, erased.ContactEmail)
+	require.NotNil(t, erased.ErasedAt)
+	assert.True(t, at.Equal(*erased.ErasedAt))
+
+	stored, err := repo.GetCompanyIncludingDeleted(ctx, company.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Acme", stored.Name)
+	assert.Equal(t, 12, stored.Employees)
+	assert.Empty(t, stored.Description)
+	assert.Empty(t, rawContactEmail(t, repo, company.ID))
+	assert.Equal(t, "dpo", stored.UpdatedBy)
+	assert.True(t, stored.DeletedAt.Valid, "the company stays deleted")
+	employees, err := repo.CountEmployees(ctx, company.ID)
+	require.NoError(t, err)
+	assert.Zero(t, employees, "employee records hold personal data too")
+
+	var history []models.CompanyEvent
+	require.NoError(t, repo.db.Find(&history, "company_id = ?", company.ID).Error)
+	require.Len(t, history, 1)
+	assert.Empty(t, history[0].Company.Description)
+	assert.Equal(t, models.FieldChange{}, history[0].Changes["description"])
+	assert.Equal(t, models.FieldChange{Old: float64(10), New: float64(12)}, history[0].Changes["employees"])
+
+	var records []models.ComplianceRecord
+	require.NoError(t, repo.db.Order("id").Find(&records).Error)
+	require.Len(t, records, 3, "compliance records are kept")
+	assert.Equal(t, updateCall.UserID, records[0].UserID)
+	assert.JSONEq(t, `{"id":"`+company.ID.String()+`","company":{"description":null,"contactEmail":null}}`, records[0].Request)
+	assert.JSONEq(t, `{"company":{"id":"`+company.ID.String()+`","name":"Acme","description":null,"employees":12}}`, records[0].Response)
+	assert.JSONEq(t, `{"companyId":"`+company.ID.String()+`","employee":null}`, records[1].Request)
+	assert.Equal(t, "invalid employee", records[1].Error)
+	assert.Empty(t, records[1].Response)
+	assert.Equal(t, otherCall.Request, records[2].Request, "records of other companies are left alone")
+
+	_, err = repo.EraseCompanyData(ctx, uuid.New(), "dpo", at)
+	assert.ErrorIs(t, err, e.ErrCompanyNotFound)
+}
+
+func TestEmployees(t *testing.T) {
+	useKeyring(t, "k1")
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	company := &models.Company{ID: uuid.New(), Name: "Acme", Employees: 250}
+	other := &models.Company{ID: uuid.New(), Name: "Other"}
+	require.NoError(t, repo.CreateCompany(ctx, company))
+	require.NoError(t, repo.CreateCompany(ctx, other))
+
+	jane := &models.Employee{ID: uuid.New(), CompanyID: company.ID, Name: "Jane Doe", Title: "CEO", Email: "jane@acme.test"}
+	john := &models.Employee{ID: uuid.New(), CompanyID: company.ID, Name: "John Roe"}
+	require.NoError(t, repo.CreateEmployee(ctx, jane))
+	require.NoError(t, repo.CreateEmployee(ctx, john))
+
+	got, err := repo.GetEmployee(ctx, company.ID, jane.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "jane@acme.test", got.Email)
+	_, err = repo.GetEmployee(ctx, other.ID, jane.ID)
+	assert.ErrorIs(t, err, e.ErrEmployeeNotFound, "employees of other companies should not be found")
+
+	listed, err := repo.ListEmployees(ctx, company.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	assert.Equal(t, jane.ID, listed[0].ID)
+	listed, err = repo.ListEmployees(ctx, company.ID, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, john.ID, listed[0].ID)
+
+	title := "Chair"
+	require.NoError(t, repo.UpdateEmployee(ctx, &models.EmployeeUpdate{ID: jane.ID, CompanyID: company.ID, Title: &title, UpdatedBy: "admin"}))
+	got, err = repo.GetEmployee(ctx, company.ID, jane.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Jane Doe", got.Name)
+	assert.Equal(t, "Chair", got.Title)
+	assert.Equal(t, "admin", got.UpdatedBy)
+	err = repo.UpdateEmployee(ctx, &models.EmployeeUpdate{ID: jane.ID, CompanyID: other.ID, Title: &title})
+	assert.ErrorIs(t, err, e.ErrEmployeeNotFound)
+
+	synced, err := repo.SyncEmployeeCount(ctx, company.ID, "admin")
+	require.NoError(t, err)
+	assert.Equal(t, 2, synced.Employees)
+	assert.Equal(t, models.Employees1To10, synced.EmployeeRange)
+
+	assert.ErrorIs(t, repo.DeleteEmployee(ctx, other.ID, john.ID), e.ErrEmployeeNotFound)
+	require.NoError(t, repo.DeleteEmployee(ctx, company.ID, john.ID))
+	count, err := repo.CountEmployees(ctx, company.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	assert.ErrorIs(t, repo.DeleteEmployee(ctx, company.ID, john.ID), e.ErrEmployeeNotFound)
+}
+
+func TestCompanyNotes(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	company := &models.Company{ID: uuid.New(), Name: "Acme"}
+	require.NoError(t, repo.CreateCompany(ctx, company))
+	first := &models.CompanyNote{ID: uuid.New(), CompanyID: company.ID, Author: "alice", Body: "Renewal due in **May**", CreatedAt: time.Now().Add(-time.Hour)}
+	second := &models.CompanyNote{ID: uuid.New(), CompanyID: company.ID, Author: "bob", Body: "Asked for a discount"}
+	require.NoError(t, repo.CreateCompanyNote(ctx, first))
+	require.NoError(t, repo.CreateCompanyNote(ctx, second))
+
+	notes, err := repo.ListCompanyNotes(ctx, company.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, notes, 2)
+	assert.Equal(t, second.ID, notes[0].ID, "newest notes should come first")
+	assert.Equal(t, "Renewal due in **May**", notes[1].Body)
+
+	got, err := repo.GetCompanyNote(ctx, company.ID, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", got.Author)
+	_, err = repo.GetCompanyNote(ctx, uuid.New(), first.ID)
+	assert.ErrorIs(t, err, e.ErrNoteNotFound)
+
+	require.NoError(t, repo.DeleteCompanyNote(ctx, company.ID, first.ID))
+	assert.ErrorIs(t, repo.DeleteCompanyNote(ctx, company.ID, first.ID), e.ErrNoteNotFound)
+
+	require.NoError(t, repo.DeleteCompany(ctx, company.ID))
+	require.NoError(t, repo.PurgeCompany(ctx, company.ID))
+	notes, err = repo.ListCompanyNotes(ctx, company.ID, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, notes, "purging a company should remove its notes")
+}
+
+// TestComplianceRecords verifies records of failed calls survive the
+// rollback of the call's transaction and old records are purged.
+func TestComplianceRecords(t *testing.T) {
+	// Every connection to an in-memory database opens a database of its
+	// own, so records outside the transaction need a file.
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "compliance.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, migrate(db))
+	repo := &Repository{db: db}
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+
+	err = repo.RunInTransaction(ctx, func(ctx context.Context) error {
+		require.NoError(t, repo.RecordCompliance(ctx, &models.ComplianceRecord{Method: "/failed", Code: "Internal", Error: "boom"}))
+		require.NoError(t, repo.RecordCompliance(ctx, &models.ComplianceRecord{Method: "/ok", Code: "OK"}))
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+
+	var records []models.ComplianceRecord
+	require.NoError(t, repo.db.Find(&records).Error)
+	require.Len(t, records, 1, "the record of the successful call rolls back")
+	assert.Equal(t, "/failed", records[0].Method)
+
+	require.NoError(t, repo.RecordCompliance(ctx, &models.ComplianceRecord{Method: "/ok", Code: "OK"}))
+	require.NoError(t, repo.db.Model(&models.ComplianceRecord{}).
+		Where("id = ?", records[0].ID).
+		Update("created_at", time.Now().Add(-48*time.Hour)).Error)
+
+	purged, err := repo.PurgeComplianceRecords(ctx, time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	var remaining int64
+	require.NoError(t, repo.db.Model(&models.ComplianceRecord{}).Count(&remaining).Error)
+	assert.Equal(t, int64(1), remaining)
+}
+
+// TestAPIKeys verifies API keys are looked up by hash and can be revoked.
+func TestAPIKeys(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	key := &models.APIKey{
+		ID:        uuid.New(),
+		Name:      "billing",
+		KeyHash:   "abc123",
+		Scopes:    []string{"companies.read"},
+		RateLimit: 60,
+	}
+	require.NoError(t, repo.CreateAPIKey(ctx, key))
+
+	got, err := repo.GetAPIKeyByHash(ctx, "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, got.ID)
+	assert.Equal(t, []string{"companies.read"}, got.Scopes)
+	assert.Equal(t, 60, got.RateLimit)
+	assert.Nil(t, got.RevokedAt)
+
+	_, err = repo.GetAPIKeyByHash(ctx, "unknown")
+	assert.ErrorIs(t, err, e.ErrNotFound)
+
+	require.NoError(t, repo.RevokeAPIKey(ctx, key.ID))
+	got, err = repo.GetAPIKeyByHash(ctx, "abc123")
+	require.NoError(t, err)
+	assert.NotNil(t, got.RevokedAt)
+
+	assert.ErrorIs(t, repo.RevokeAPIKey(ctx, key.ID), e.ErrNotFound, "revoking twice should report not found")
+}
+
+func TestAlertWebhooks(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	webhook := &models.AlertWebhook{
+		ID:           uuid.New(),
+		Name:         "#sales",
+		Kind:         models.WebhookSlack,
+		URL:          "https://hooks.slack.com/services/T0/B0/secret",
+		EventTypes:   []string{"company_created"},
+		MinEmployees: 1000,
+	}
+	require.NoError(t, repo.CreateAlertWebhook(ctx, webhook))
+
+	webhooks, err := repo.ListAlertWebhooks(ctx)
+	require.NoError(t, err)
+	require.Len(t, webhooks, 1)
+	assert.Equal(t, []string{"company_created"}, webhooks[0].EventTypes)
+	assert.Equal(t, 1000, webhooks[0].MinEmployees)
+
+	require.NoError(t, repo.DeleteAlertWebhook(ctx, webhook.ID))
+	assert.ErrorIs(t, repo.DeleteAlertWebhook(ctx, webhook.ID), e.ErrNotFound)
+	webhooks, err = repo.ListAlertWebhooks(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, webhooks)
+}
+
+func TestTenantQuotas(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	_, err := repo.GetTenantQuota(ctx, "acme")
+	assert.ErrorIs(t, err, e.ErrNotFound)
+
+	require.NoError(t, repo.SetTenantQuota(ctx, &models.TenantQuota{TenantID: "acme", MaxCompanies: 10, UpdatedBy: "admin"}))
+	require.NoError(t, repo.SetTenantQuota(ctx, &models.TenantQuota{TenantID: "acme", MaxCompanies: 20, MaxMutationsPerMinute: 5, UpdatedBy: "admin"}))
+	quota, err := repo.GetTenantQuota(ctx, "acme")
+	require.NoError(t, err)
+	assert.Equal(t, 20, quota.MaxCompanies)
+	assert.Equal(t, 5, quota.MaxMutationsPerMinute)
+
+	for _, c := range []*models.Company{
+		{ID: uuid.New(), Name: "Acme 1", TenantID: "acme"},
+		{ID: uuid.New(), Name: "Acme 2", TenantID: "acme"},
+		{ID: uuid.New(), Name: "Other", TenantID: "other"},
+	} {
+		require.NoError(t, repo.CreateCompany(ctx, c))
+	}
+	acme, err := repo.GetCompanyByName(ctx, "Acme 2")
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteCompany(ctx, acme.ID))
+	count, err := repo.CountTenantCompanies(ctx, "acme")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count, "deleted companies do not count")
+}
+
+func TestIncrementTenantMutations(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+	minute := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for want := 1; want <= 3; want++ {
+		got, err := repo.IncrementTenantMutations(ctx, "acme", minute)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	got, err := repo.IncrementTenantMutations(ctx, "other", minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got, "tenants are counted apart")
+
+	next := minute.Add(time.Minute)
+	got, err = repo.IncrementTenantMutations(ctx, "acme", next)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got)
+
+	count, err := repo.TenantMutations(ctx, "acme", minute)
+	require.NoError(t, err)
+	assert.Zero(t, count, "earlier minutes are deleted")
+	count, err = repo.TenantMutations(ctx, "other", minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestForEachCompanyEvent(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	companyID, otherID := uuid.New(), uuid.New()
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i, ev := range []struct {
+		companyID uuid.UUID
+		eventType string
+	}{
+		{companyID, "company_created"},
+		{otherID, "company_created"},
+		{companyID, "company_updated"},
+		{companyID, "company_updated"},
+		{companyID, "company_deleted"},
+	} {
+		require.NoError(t, repo.RecordCompanyEvent(ctx, &models.CompanyEvent{
+			ID:        uuid.New(),
+			Type:      ev.eventType,
+			CompanyID: ev.companyID,
+			Company:   models.Company{ID: ev.companyID, Name: "Acme"},
+			Changes:   map[string]models.FieldChange{"employees": {Old: float64(i), New: float64(i + 1)}},
+			CreatedAt: start.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+
+	// A small batch size exercises the pagination.
+	defer func(size int) { companyEventBatchSize = size }(companyEventBatchSize)
+	companyEventBatchSize = 2
+
+	collect := func(filter models.CompanyEventFilter) []models.CompanyEvent {
+		var got []models.CompanyEvent
+		require.NoError(t, repo.ForEachCompanyEvent(ctx, filter, func(ev *models.CompanyEvent) error {
+			got = append(got, *ev)
+			return nil
+		}))
+		return got
+	}
+
+	all := collect(models.CompanyEventFilter{})
+	require.Len(t, all, 5)
+	for i := 1; i < len(all); i++ {
+		assert.True(t, all[i-1].CreatedAt.Before(all[i].CreatedAt), "events should be ordered oldest first")
+	}
+	assert.Equal(t, "Acme", all[0].Company.Name)
+	assert.Equal(t, models.FieldChange{Old: float64(0), New: float64(1)}, all[0].Changes["employees"])
+
+	assert.Len(t, collect(models.CompanyEventFilter{CompanyIDs: []uuid.UUID{companyID}}), 4)
+	assert.Len(t, collect(models.CompanyEventFilter{Types: []string{"company_updated"}}), 2)
+	assert.Len(t, collect(models.CompanyEventFilter{
+		CompanyIDs: []uuid.UUID{companyID},
+		Since:      start.Add(time.Hour),
+		Until:      start.Add(4 * time.Hour),
+	}), 2)
+
+	after := all[1].Position()
+	resumed := collect(models.CompanyEventFilter{After: &after})
+	require.Len(t, resumed, 3)
+	assert.Equal(t, all[2].ID, resumed[0].ID, "iteration should resume after the position")
+
+	page, err := repo.ListCompanyEvents(ctx, models.CompanyEventFilter{CompanyIDs: []uuid.UUID{companyID}, After: &after}, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, []uuid.UUID{all[2].ID, all[3].ID}, []uuid.UUID{page[0].ID, page[1].ID})
+
+	stop := assert.AnError
+	err = repo.ForEachCompanyEvent(ctx, models.CompanyEventFilter{}, func(*models.CompanyEvent) error { return stop })
+	assert.ErrorIs(t, err, stop)
+}
+
+func BenchmarkCreateCompany(b *testing.B) {
+	repo := SetupTestDB(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		company := &models.Company{ID: uuid.New(), Name: fmt.Sprintf("Bench %d", i), Employees: i % 1000}
+		if err := repo.CreateCompany(ctx, company); err != nil {
+			b.Fatalf("CreateCompany failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkGetCompany(b *testing.B) {
+	repo := SetupTestDB(b)
+	ctx := context.Background()
+	ids := seedCompanies(b, repo, 1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.GetCompany(ctx, ids[i%len(ids)]); err != nil {
+			b.Fatalf("GetCompany failed: %v", err)
+		}
+	}
+}
+
+func BenchmarkListCompanies(b *testing.B) {
+	repo := SetupTestDB(b)
+	ctx := context.Background()
+	seedCompanies(b, repo, 1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.ListCompanies(ctx, models.CompanyFilter{}, (i*50)%1000, 50); err != nil {
+			b.Fatalf("ListCompanies failed: %v", err)
+		}
+	}
+}
+
+// seedCompanies creates n companies and returns their IDs.
+func seedCompanies(b *testing.B, repo *Repository, n int) []uuid.UUID {
+	b.Helper()
+	ctx := context.Background()
+	ids := make([]uuid.UUID, n)
+	for i := range ids {
+		ids[i] = uuid.New()
+		company := &models.Company{ID: ids[i], Name: fmt.Sprintf("Seed %d", i), Employees: i}
+		require.NoError(b, repo.CreateCompany(ctx, company))
+	}
+	return ids
+}
+
+func TestExportRuns(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	last, err := repo.LastExportRun(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, last, "nothing was exported yet")
+
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	old := &models.Company{ID: uuid.New(), Name: "Old", CreatedAt: start, UpdatedAt: start}
+	changed := &models.Company{ID: uuid.New(), Name: "Changed", CreatedAt: start, UpdatedAt: start.Add(2 * time.Hour)}
+	deleted := &models.Company{ID: uuid.New(), Name: "Deleted", CreatedAt: start, UpdatedAt: start}
+	for _, c := range []*models.Company{old, changed, deleted} {
+		require.NoError(t, repo.CreateCompany(ctx, c))
+	}
+	require.NoError(t, repo.db.Model(deleted).Update("deleted_at", start.Add(2*time.Hour)).Error)
+
+	exported := func(since *time.Time, until time.Time) []string {
+		var names []string
+		require.NoError(t, repo.ForEachExportedCompany(ctx, since, until, func(c *models.Company) error {
+			assert.Empty(t, c.ContactEmail)
+			names = append(names, c.Name)
+			return nil
+		}))
+		return names
+	}
+	until := start.Add(3 * time.Hour)
+	assert.ElementsMatch(t, []string{"Old", "Changed"}, exported(nil, until), "full exports hold the live companies")
+	since := start.Add(time.Hour)
+	assert.ElementsMatch(t, []string{"Changed", "Deleted"}, exported(&since, until), "incremental exports hold changes and deletions")
+	assert.Empty(t, exported(&since, since.Add(time.Minute)))
+
+	for _, end := range []time.Time{start, until} {
+		require.NoError(t, repo.CreateExportRun(ctx, &models.ExportRun{ID: uuid.New(), Kind: models.ExportFull, Until: end}))
+	}
+	last, err = repo.LastExportRun(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, last)
+	assert.True(t, last.Until.Equal(until), "got %v", last.Until)
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NameSimilarity returns the trigram similarity (0-1) of two names, as
+// FindSimilarCompanies compares them.
+func NameSimilarity(a, b string) float64 {
+	return trigramSimilarity(a, b)
+}
+
+// SyncDuplicateSuggestions records the pairs found by a detection run
+// started at start, which the suggestions are stamped with: pairs not
+// suggested before are added as open, open ones get the signals found, and
+// open suggestions the run did not find again are removed. Resolved pairs are left as they are, so dismissed
+// pairs are not suggested again. It returns the number of pairs added.
+func (r *Repository) SyncDuplicateSuggestions(ctx context.Context, found []models.DuplicateSuggestion, start time.Time) (int, error) {
+	added := 0
+	err := r.RunInTransaction(ctx, func(ctx context.Context) error {
+		for _, s := range found {
+			var existing models.DuplicateSuggestion
+			err := r.conn(ctx).First(&existing, "company_id = ? AND duplicate_id = ?", s.CompanyID, s.DuplicateID).Error
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				s.ID = uuid.New()
+				s.Status = models.DuplicateOpen
+				s.CreatedAt, s.UpdatedAt = start, start
+				if err := r.conn(ctx).Create(&s).Error; err != nil {
+					return err
+				}
+				added++
+			case err != nil:
+				return err
+			case existing.Status == models.DuplicateOpen:
+				err := r.conn(ctx).Model(&existing).Updates(map[string]interface{}{
+					"name_similarity": s.NameSimilarity,
+					"same_country":    s.SameCountry,
+					"same_website":    s.SameWebsite,
+					"updated_at":      start,
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return r.conn(ctx).
+			Where("status = ? AND updated_at < ?", models.DuplicateOpen, start).
+			Delete(&models.DuplicateSuggestion{}).Error
+	})
+	return added, err
+}
+
+// ListDuplicateSuggestions returns up to limit suggestions matching filter,
+// pairs sharing a website first, then by decreasing name similarity,
+// skipping the first offset.
+func (r *Repository) ListDuplicateSuggestions(ctx context.Context, filter models.DuplicateSuggestionFilter, offset, limit int) ([]models.DuplicateSuggestion, error) {
+	query := r.conn(ctx).Model(&models.DuplicateSuggestion{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.TenantID != "" {
+		query = query.Where("tenant_id = ?", filter.TenantID)
+	}
+	if filter.CompanyID != uuid.Nil {
+		query = query.Where("company_id = ? OR duplicate_id = ?", filter.CompanyID, filter.CompanyID)
+	}
+	var suggestions []models.DuplicateSuggestion
+	err := query.Order("same_website DESC, name_similarity DESC, id").Offset(offset).Limit(limit).Find(&suggestions).Error
+	return suggestions, err
+}
+
+// GetDuplicateSuggestionForUpdate returns the suggestion with the given ID,
+// locking its row until the transaction ctx carries ends on databases
+// supporting row locks.
+func (r *Repository) GetDuplicateSuggestionForUpdate(ctx context.Context, id uuid.UUID) (*models.DuplicateSuggestion, error) {
+	var suggestion models.DuplicateSuggestion
+	result := r.conn(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&suggestion, "id = ?", id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, e.ErrDuplicateSuggestionNotFound
+		}
+		return nil, result.Error
+	}
+	return &suggestion, nil
+}
+
+// ResolveDuplicateSuggestion records that actor resolved the suggestion
+// with the given ID at at, leaving it in status.
+func (r *Repository) ResolveDuplicateSuggestion(ctx context.Context, id uuid.UUID, status models.DuplicateStatus, actor string, at time.Time) error {
+	result := r.conn(ctx).Model(&models.DuplicateSuggestion{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":      status,
+		"resolved_by": actor,
+		"resolved_at": at,
+		"updated_at":  at,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return e.ErrDuplicateSuggestionNotFound
+	}
+	return nil
+}
+
+// MoveCompanyRecords moves the employees and notes of company from to
+// company to and returns to, with its employee count derived from its
+// employee records when it then has any. Callers hold the row locks of
+// both companies.
+func (r *Repository) MoveCompanyRecords(ctx context.Context, from, to uuid.UUID, actor string) (*models.Company, error) {
+	for _, model := range []interface{}{&models.Employee{}, &models.CompanyNote{}} {
+		if err := r.conn(ctx).Model(model).Where("company_id = ?", from).Update("company_id", to).Error; err != nil {
+			return nil, err
+		}
+	}
+	count, err := r.CountEmployees(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return r.SyncEmployeeCount(ctx, to, actor)
+	}
+	return r.GetCompany(ctx, to)
+}
+
+// DeleteOpenDuplicateSuggestions removes the open suggestions with company
+// on either side.
+func (r *Repository) DeleteOpenDuplicateSuggestions(ctx context.Context, company uuid.UUID) error {
+	return r.conn(ctx).
+		Where("status = ? AND (company_id = ? OR duplicate_id = ?)", models.DuplicateOpen, company, company).
+		Delete(&models.DuplicateSuggestion{}).Error
+}
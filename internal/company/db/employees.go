@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreateEmployee inserts a new employee record.
+func (r *Repository) CreateEmployee(ctx context.Context, employee *models.Employee) error {
+	return r.conn(ctx).Create(employee).Error
+}
+
+// GetEmployee returns the employee with the given ID working for company.
+// Employees of other companies yield ErrEmployeeNotFound.
+func (r *Repository) GetEmployee(ctx context.Context, company, id uuid.UUID) (*models.Employee, error) {
+	var employee models.Employee
+	result := r.conn(ctx).First(&employee, "id = ? AND company_id = ?", id, company)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, e.ErrEmployeeNotFound
+		}
+		return nil, result.Error
+	}
+	return &employee, nil
+}
+
+// ListEmployees returns up to limit employees of company, ordered by the
+// time they were added, skipping the first offset.
+func (r *Repository) ListEmployees(ctx context.Context, company uuid.UUID, offset, limit int) ([]models.Employee, error) {
+	var employees []models.Employee
+	err := r.conn(ctx).Where("company_id = ?", company).
+		Order("created_at, id").Offset(offset).Limit(limit).
+		Find(&employees).Error
+	return employees, err
+}
+
+// CountEmployees returns the number of employee records of company.
+func (r *Repository) CountEmployees(ctx context.Context, company uuid.UUID) (int64, error) {
+	var count int64
+	err := r.conn(ctx).Model(&models.Employee{}).Where("company_id = ?", company).Count(&count).Error
+	return count, err
+}
+
+// UpdateEmployee applies the non-nil fields of update to the employee with
+// update.ID working for update.CompanyID.
+func (r *Repository) UpdateEmployee(ctx context.Context, update *models.EmployeeUpdate) error {
+	result := r.conn(ctx).Model(&models.Employee{}).
+		Where("id = ? AND company_id = ?", update.ID, update.CompanyID).
+		Updates(update)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return e.ErrEmployeeNotFound
+	}
+	return nil
+}
+
+// DeleteEmployee removes the employee with the given ID working for company.
+func (r *Repository) DeleteEmployee(ctx context.Context, company, id uuid.UUID) error {
+	result := r.conn(ctx).Delete(&models.Employee{}, "id = ? AND company_id = ?", id, company)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return e.ErrEmployeeNotFound
+	}
+	return nil
+}
+
+// SyncEmployeeCount sets the employee count and range of company to the
+// number of its employee records, recording actor as the last to update it,
+// and returns the company as updated. Callers hold the company's row lock,
+// so concurrent changes to its employees cannot interleave.
+func (r *Repository) SyncEmployeeCount(ctx context.Context, company uuid.UUID, actor string) (*models.Company, error) {
+	count, err := r.CountEmployees(ctx, company)
+	if err != nil {
+		return nil, err
+	}
+	err = r.conn(ctx).Model(&models.Company{}).Where("id = ?", company).Updates(map[string]interface{}{
+		"employees":      count,
+		"employee_range": models.EmployeeRangeFor(int(count)),
+		"updated_by":     actor,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.GetCompany(ctx, company)
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// encryptedPrefix starts every encrypted column value, followed by the key
+// ID and the base64 encoded nonce and ciphertext: enc:v1:<key ID>:<data>.
+const encryptedPrefix = "enc:v1:"
+
+// ErrNoEncryptionKey is returned when a value of an encrypted column is
+// written or read without a keyring, or with a keyring missing its key.
+var ErrNoEncryptionKey = errors.New("encryption key not configured")
+
+// Keyring holds the AES-256 keys protecting sensitive columns. Values are
+// encrypted with the primary key and can be decrypted with any key in the
+// ring, so a new primary key can be introduced before the rows encrypted
+// with the old one are rewritten by ReencryptCompanies.
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+// NewKeyring returns a Keyring encrypting with keys[primary]. Keys must be
+// 32 bytes long and their IDs must not contain ':'.
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("primary encryption key %q not found", primary)
+	}
+	k := &Keyring{primary: primary, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid encryption key ID %q", id)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %q must be 32 bytes, got %d", id, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if k.aeads[id], err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// ParseKey decodes a base64 encoded encryption key, as stored in the
+// secrets provider.
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not valid base64: %w", err)
+	}
+	return key, nil
+}
+
+// Encrypt seals plaintext with the primary key. additionalData, such as the
+// column name, must be passed again to Decrypt.
+func (k *Keyring) Encrypt(plaintext, additionalData []byte) (string, error) {
+	aead := k.aeads[k.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, additionalData)
+	return encryptedPrefix + k.primary + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value returned by Encrypt with the key it names.
+func (k *Keyring) Decrypt(value string, additionalData []byte) ([]byte, error) {
+	id, data, ok := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if !ok || !strings.HasPrefix(value, encryptedPrefix) {
+		return nil, errors.New("malformed encrypted value")
+	}
+	aead, ok := k.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrNoEncryptionKey, id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(data)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+}
+
+// encryptedColumns is the "encrypted" GORM serializer for string fields,
+// registered for every repository: tag a field with
+// `gorm:"serializer:encrypted"` to store it encrypted. Empty strings are
+// stored as is. Values written before a column was encrypted are read as
+// plaintext until ReencryptCompanies rewrites them.
+var encryptedColumns = &encryptedSerializer{}
+
+func init() {
+	schema.RegisterSerializer("encrypted", encryptedColumns)
+}
+
+type encryptedSerializer struct {
+	keyring atomic.Pointer[Keyring]
+}
+
+// Scan implements schema.SerializerInterface.
+func (s *encryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("unsupported type %T for encrypted column %s", dbValue, field.DBName)
+	}
+	if strings.HasPrefix(value, encryptedPrefix) {
+		keyring := s.keyring.Load()
+		if keyring == nil {
+			return fmt.Errorf("%s: %w", field.DBName, ErrNoEncryptionKey)
+		}
+		plaintext, err := keyring.Decrypt(value, []byte(field.DBName))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", field.DBName, err)
+		}
+		value = string(plaintext)
+	}
+	return field.Set(ctx, dst, value)
+}
+
+// Value implements schema.SerializerValuerInterface.
+func (s *encryptedSerializer) Value(_ context.Context, field *schema.Field, _ reflect.Value, fieldValue interface{}) (interface{}, error) {
+	var value string
+	switch v := fieldValue.(type) {
+	case string:
+		value = v
+	case *string:
+		if v == nil {
+			return nil, nil
+		}
+		value = *v
+	default:
+		return nil, fmt.Errorf("unsupported type %T for encrypted column %s", fieldValue, field.DBName)
+	}
+	if value == "" {
+		return "", nil
+	}
+	keyring := s.keyring.Load()
+	if keyring == nil {
+		return nil, fmt.Errorf("%s: %w", field.DBName, ErrNoEncryptionKey)
+	}
+	return keyring.Encrypt([]byte(value), []byte(field.DBName))
+}
+
+// WithEncryption encrypts the columns tagged with the "encrypted" serializer
+// using keyring. GORM serializers are shared by every connection, so the
+// keyring applies to the whole process.
+func WithEncryption(keyring *Keyring) Option {
+	return func(*openConfig) {
+		encryptedColumns.keyring.Store(keyring)
+	}
+}
+
+// ReencryptCompanies rewrites the encrypted columns of companies, including
+// soft-deleted ones, that are stored in plaintext or under a key other than
+// the primary one, and returns how many it rewrote. Run it after rotating
+// the primary key; older keys can be dropped from the keyring once it
+// returns 0.
+func (r *Repository) ReencryptCompanies(ctx context.Context) (int64, error) {
+	keyring := encryptedColumns.keyring.Load()
+	if keyring == nil {
+		return 0, ErrNoEncryptionKey
+	}
+	current := likeEscaper.Replace(encryptedPrefix+keyring.primary+":") + "%"
+
+	var rewritten int64
+	var companies []models.Company
+	result := r.conn(ctx).Unscoped().
+		Where(`contact_email <> '' AND contact_email NOT LIKE ? ESCAPE '\'`, current).
+		FindInBatches(&companies, 500, func(tx *gorm.DB, _ int) error {
+			for i := range companies {
+				err := r.conn(ctx).Unscoped().Model(&companies[i]).
+					Select("contact_email").
+					UpdateColumns(&companies[i]).Error
+				if err != nil {
+					return err
+				}
+				rewritten++
+			}
+			return nil
+		})
+	return rewritten, result.Error
+}
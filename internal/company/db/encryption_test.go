@@ -0,0 +1,144 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useKeyring installs a keyring with the given primary key for the test.
+func useKeyring(t *testing.T, primary string, ids ...string) {
+	t.Helper()
+	keys := make(map[string][]byte)
+	for _, id := range append(ids, primary) {
+		keys[id] = bytes.Repeat([]byte(id[:1]), 32)
+	}
+	keyring, err := NewKeyring(primary, keys)
+	require.NoError(t, err)
+	WithEncryption(keyring)(nil)
+	t.Cleanup(func() { encryptedColumns.keyring.Store(nil) })
+}
+
+// rawContactEmail reads the stored contact_email bypassing the serializer.
+func rawContactEmail(t *testing.T, repo *Repository, id uuid.UUID) string {
+	t.Helper()
+	var raw string
+	require.NoError(t, repo.db.Raw("SELECT contact_email FROM companies WHERE id = ?", id).Scan(&raw).Error)
+	return raw
+}
+
+func TestEncryptedColumns(t *testing.T) {
+	useKeyring(t, "k1")
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	company := &models.Company{ID: uuid.New(), Name: "Acme", ContactEmail: "ceo@acme.test"}
+	require.NoError(t, repo.CreateCompany(ctx, company))
+
+	raw := rawContactEmail(t, repo, company.ID)
+	assert.True(t, strings.HasPrefix(raw, "enc:v1:k1:"), raw)
+	assert.NotContains(t, raw, "acme", "the address should be encrypted at rest")
+
+	got, err := repo.GetCompany(ctx, company.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "ceo@acme.test", got.ContactEmail)
+
+	require.NoError(t, repo.UpdateCompany(ctx, &models.CompanyUpdate{ID: company.ID, ContactEmail: utils.Ptr("cfo@acme.test")}))
+	assert.NotContains(t, rawContactEmail(t, repo, company.ID), "acme")
+	got, err = repo.GetCompany(ctx, company.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "cfo@acme.test", got.ContactEmail)
+
+	empty := &models.Company{ID: uuid.New(), Name: "No Contact"}
+	require.NoError(t, repo.CreateCompany(ctx, empty))
+	assert.Empty(t, rawContactEmail(t, repo, empty.ID), "empty values should not be encrypted")
+}
+
+func TestEncryptedColumnsTampered(t *testing.T) {
+	useKeyring(t, "k1")
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	company := &models.Company{ID: uuid.New(), Name: "Acme", ContactEmail: "ceo@acme.test"}
+	require.NoError(t, repo.CreateCompany(ctx, company))
+	raw := rawContactEmail(t, repo, company.ID)
+	// Swap the last base64 character to corrupt the authentication tag.
+	last := "A"
+	if strings.HasSuffix(raw, "A") {
+		last = "B"
+	}
+	require.NoError(t, repo.db.Exec("UPDATE companies SET contact_email = ? WHERE id = ?", raw[:len(raw)-1]+last, company.ID).Error)
+
+	_, err := repo.GetCompany(ctx, company.ID)
+	assert.Error(t, err)
+}
+
+func TestReencryptCompanies(t *testing.T) {
+	useKeyring(t, "old")
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+
+	rotated := &models.Company{ID: uuid.New(), Name: "Acme", ContactEmail: "ceo@acme.test"}
+	require.NoError(t, repo.CreateCompany(ctx, rotated))
+	deleted := &models.Company{ID: uuid.New(), Name: "Gone", ContactEmail: "ceo@gone.test"}
+	require.NoError(t, repo.CreateCompany(ctx, deleted))
+	require.NoError(t, repo.DeleteCompany(ctx, deleted.ID))
+	// A value written before the column was encrypted.
+	legacy := &models.Company{ID: uuid.New(), Name: "Legacy"}
+	require.NoError(t, repo.CreateCompany(ctx, legacy))
+	require.NoError(t, repo.db.Exec("UPDATE companies SET contact_email = 'ceo@legacy.test' WHERE id = ?", legacy.ID).Error)
+	got, err := repo.GetCompany(ctx, legacy.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "ceo@legacy.test", got.ContactEmail, "plaintext values should be readable")
+
+	useKeyring(t, "new", "old")
+	got, err = repo.GetCompany(ctx, rotated.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "ceo@acme.test", got.ContactEmail, "values under a retired key should be readable")
+
+	n, err := repo.ReencryptCompanies(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+	for _, id := range []uuid.UUID{rotated.ID, deleted.ID, legacy.ID} {
+		assert.True(t, strings.HasPrefix(rawContactEmail(t, repo, id), "enc:v1:new:"))
+	}
+
+	n, err = repo.ReencryptCompanies(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	useKeyring(t, "new")
+	got, err = repo.GetCompany(ctx, rotated.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "ceo@acme.test", got.ContactEmail, "the old key should no longer be needed")
+}
+
+func TestEncryptedColumnsWithoutKey(t *testing.T) {
+	repo := SetupTestDB(t)
+	err := repo.CreateCompany(context.Background(), &models.Company{ID: uuid.New(), Name: "Acme", ContactEmail: "ceo@acme.test"})
+	assert.ErrorIs(t, err, ErrNoEncryptionKey)
+	require.NoError(t, repo.CreateCompany(context.Background(), &models.Company{ID: uuid.New(), Name: "No Contact"}))
+}
+
+func TestNewKeyring(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	_, err := NewKeyring("missing", map[string][]byte{"k1": key})
+	assert.Error(t, err)
+	_, err = NewKeyring("k1", map[string][]byte{"k1": key[:16]})
+	assert.Error(t, err, "keys should be AES-256")
+	_, err = NewKeyring("k:1", map[string][]byte{"k:1": key})
+	assert.Error(t, err)
+
+	_, err = ParseKey("not base64!")
+	assert.Error(t, err)
+	parsed, err := ParseKey("AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=\n")
+	require.NoError(t, err)
+	assert.Equal(t, key, parsed)
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is the number of companies read per query while
+// exporting.
+const exportBatchSize = 1000
+
+// ForEachExportedCompany calls fn with every company an export holds, in ID
+// order, reading them in batches. With since nil these are the live
+// companies; otherwise they are the companies, deleted ones included,
+// updated or deleted after since and at or before until. Contact emails are
+// personal data and are not read.
+func (r *Repository) ForEachExportedCompany(ctx context.Context, since *time.Time, until time.Time, fn func(*models.Company) error) error {
+	query := r.conn(ctx).Model(&models.Company{}).Omit("contact_email")
+	if since == nil {
+		query = query.Where("created_at <= ?", until)
+	} else {
+		query = query.Unscoped().Where(
+			"(updated_at > ? AND updated_at <= ?) OR (deleted_at > ? AND deleted_at <= ?)",
+			*since, until, *since, until)
+	}
+
+	var lastID uuid.UUID
+	for first := true; ; first = false {
+		batch := query.Session(&gorm.Session{}).Order("id").Limit(exportBatchSize)
+		if !first {
+			batch = batch.Where("id > ?", lastID)
+		}
+		var companies []models.Company
+		if err := batch.Find(&companies).Error; err != nil {
+			return err
+		}
+		for i := range companies {
+			if err := fn(&companies[i]); err != nil {
+				return err
+			}
+		}
+		if len(companies) < exportBatchSize {
+			return nil
+		}
+		lastID = companies[len(companies)-1].ID
+	}
+}
+
+// CreateExportRun records a completed export.
+func (r *Repository) CreateExportRun(ctx context.Context, run *models.ExportRun) error {
+	return r.conn(ctx).Create(run).Error
+}
+
+// LastExportRun returns the export with the latest Until, or nil when
+// nothing was exported yet.
+func (r *Repository) LastExportRun(ctx context.Context) (*models.ExportRun, error) {
+	var run models.ExportRun
+	err := r.conn(ctx).Order("until DESC").First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
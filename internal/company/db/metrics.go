@@ -0,0 +1,182 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// queryStartKey holds the start time of a statement in its gorm.DB instance.
+const queryStartKey = "xm:query_start"
+
+// latencyBuckets are the upper bounds of the query latency histograms.
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+}
+
+// QueryMetrics is a GORM plugin timing every statement. It keeps a latency
+// histogram per operation, such as "companies.query", and logs statements
+// slower than a threshold. Logged SQL keeps its placeholders: bound
+// parameters are never written out.
+//
+// QueryMetrics implements expvar.Var, so it can be published next to the
+// other runtime metrics.
+type QueryMetrics struct {
+	logger        *zap.Logger
+	slowThreshold time.Duration
+
+	mu  sync.Mutex
+	ops map[string]*latencyHistogram
+}
+
+// latencyHistogram counts the statements of one operation per latency
+// bucket; the last count is for statements slower than every bucket.
+type latencyHistogram struct {
+	counts []int64
+	errors int64
+	sum    time.Duration
+}
+
+// NewQueryMetrics returns a QueryMetrics logging statements slower than
+// slowThreshold; 0 disables slow query logging.
+func NewQueryMetrics(logger *zap.Logger, slowThreshold time.Duration) *QueryMetrics {
+	return &QueryMetrics{
+		logger:        logger.Named("db"),
+		slowThreshold: slowThreshold,
+		ops:           make(map[string]*latencyHistogram),
+	}
+}
+
+// Name implements gorm.Plugin.
+func (m *QueryMetrics) Name() string {
+	return "xm:query_metrics"
+}
+
+// Initialize implements gorm.Plugin by registering callbacks around each
+// kind of statement.
+func (m *QueryMetrics) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	name := m.Name()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register(name+":start_create", m.start),
+		cb.Create().After("gorm:create").Register(name+":finish_create", m.finish("create")),
+		cb.Query().Before("gorm:query").Register(name+":start_query", m.start),
+		cb.Query().After("gorm:query").Register(name+":finish_query", m.finish("query")),
+		cb.Update().Before("gorm:update").Register(name+":start_update", m.start),
+		cb.Update().After("gorm:update").Register(name+":finish_update", m.finish("update")),
+		cb.Delete().Before("gorm:delete").Register(name+":start_delete", m.start),
+		cb.Delete().After("gorm:delete").Register(name+":finish_delete", m.finish("delete")),
+		cb.Row().Before("gorm:row").Register(name+":start_row", m.start),
+		cb.Row().After("gorm:row").Register(name+":finish_row", m.finish("row")),
+		cb.Raw().Before("gorm:raw").Register(name+":start_raw", m.start),
+		cb.Raw().After("gorm:raw").Register(name+":finish_raw", m.finish("raw")),
+	)
+}
+
+func (m *QueryMetrics) start(db *gorm.DB) {
+	db.InstanceSet(queryStartKey, time.Now())
+}
+
+// finish records the latency of a statement of the given kind.
+func (m *QueryMetrics) finish(kind string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(queryStartKey)
+		if !ok {
+			return
+		}
+		elapsed := time.Since(v.(time.Time))
+		failed := db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound)
+
+		op := kind
+		if table := db.Statement.Table; table != "" {
+			op = table + "." + kind
+		}
+		m.observe(op, elapsed, failed)
+
+		if m.slowThreshold > 0 && elapsed >= m.slowThreshold {
+			m.logger.Warn("Slow query",
+				zap.String("op", op),
+				zap.Duration("elapsed", elapsed),
+				zap.String("sql", db.Statement.SQL.String()),
+				zap.Int("params", len(db.Statement.Vars)),
+				zap.Int64("rows", db.RowsAffected),
+			)
+		}
+	}
+}
+
+func (m *QueryMetrics) observe(op string, elapsed time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.ops[op]
+	if !ok {
+		h = &latencyHistogram{counts: make([]int64, len(latencyBuckets)+1)}
+		m.ops[op] = h
+	}
+	i := sort.Search(len(latencyBuckets), func(i int) bool { return elapsed <= latencyBuckets[i] })
+	h.counts[i]++
+	h.sum += elapsed
+	if failed {
+		h.errors++
+	}
+}
+
+// OperationStats is the JSON form of the latency histogram of an
+// operation. Buckets are cumulative, as in Prometheus: each counts the
+// statements at most LeMillis long.
+type OperationStats struct {
+	Count     int64         `json:"count"`
+	Errors    int64         `json:"errors"`
+	SumMillis float64       `json:"sum_ms"`
+	Buckets   []BucketCount `json:"buckets"`
+}
+
+// BucketCount is one cumulative histogram bucket.
+type BucketCount struct {
+	LeMillis float64 `json:"le_ms"`
+	Count    int64   `json:"count"`
+}
+
+// Stats returns the latency histogram of every operation seen so far.
+func (m *QueryMetrics) Stats() map[string]OperationStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := make(map[string]OperationStats, len(m.ops))
+	for op, h := range m.ops {
+		s := OperationStats{
+			Errors:    h.errors,
+			SumMillis: float64(h.sum) / float64(time.Millisecond),
+			Buckets:   make([]BucketCount, len(latencyBuckets)),
+		}
+		for i, bound := range latencyBuckets {
+			s.Count += h.counts[i]
+			s.Buckets[i] = BucketCount{LeMillis: float64(bound) / float64(time.Millisecond), Count: s.Count}
+		}
+		s.Count += h.counts[len(latencyBuckets)]
+		stats[op] = s
+	}
+	return stats
+}
+
+// String implements expvar.Var.
+func (m *QueryMetrics) String() string {
+	b, err := json.Marshal(m.Stats())
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
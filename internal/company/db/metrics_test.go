@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/driver/sqlite"
+)
+
+func TestQueryMetrics(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	// Every statement is slow with a 1ns threshold.
+	metrics := NewQueryMetrics(zap.New(core), time.Nanosecond)
+	repo, err := Open(sqlite.Open(":memory:"), WithQueryMetrics(metrics))
+	require.NoError(t, err)
+	ctx := context.Background()
+	migrationQueries := metrics.Stats()["companies.query"].Count
+
+	company := &models.Company{ID: uuid.New(), Name: "Secret Corp"}
+	require.NoError(t, repo.CreateCompany(ctx, company))
+	_, err = repo.GetCompany(ctx, company.ID)
+	require.NoError(t, err)
+	_, err = repo.GetCompany(ctx, uuid.New())
+	require.Error(t, err)
+
+	stats := metrics.Stats()
+	require.Contains(t, stats, "companies.create")
+	require.Contains(t, stats, "companies.query")
+	query := stats["companies.query"]
+	assert.EqualValues(t, 2, query.Count-migrationQueries)
+	assert.Zero(t, query.Errors, "not found should not count as an error")
+	assert.Len(t, query.Buckets, len(latencyBuckets))
+	last := query.Buckets[len(query.Buckets)-1]
+	assert.LessOrEqual(t, last.Count, query.Count, "buckets should be cumulative")
+
+	var published map[string]OperationStats
+	require.NoError(t, json.Unmarshal([]byte(metrics.String()), &published))
+	assert.Equal(t, stats, published)
+
+	slow := logs.FilterMessage("Slow query").All()
+	require.NotEmpty(t, slow)
+	for _, entry := range slow {
+		sql := entry.ContextMap()["sql"].(string)
+		assert.NotContains(t, sql, "Secret Corp", "bound parameters should be redacted")
+		assert.NotContains(t, sql, company.ID.String(), "bound parameters should be redacted")
+	}
+}
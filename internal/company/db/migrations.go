@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	dbmodels "github.com/gartstein/xm/internal/company/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SchemaVersion is the version of the schema this build migrates to. Bump it
+// whenever migrate changes the tables. Changes must be additive, so that
+// instances of the previous version keep working on the migrated schema
+// during a rolling deployment.
+const SchemaVersion = 5
+
+// MigrationStatus compares the schema of the database with the one this
+// build expects.
+type MigrationStatus struct {
+	// Version is the latest version the schema was migrated to, 0 when it
+	// was never recorded.
+	Version  int `json:"version"`
+	Expected int `json:"expected"`
+	// Pending lists the tables and columns missing, as PendingMigrations.
+	Pending []string `json:"pending,omitempty"`
+}
+
+// Err returns why this build cannot run on the schema, or nil. A schema
+// migrated by a newer build is compatible, as migrations are additive.
+func (s MigrationStatus) Err() error {
+	if s.Version < s.Expected {
+		return fmt.Errorf("schema version %d, expected %d", s.Version, s.Expected)
+	}
+	if len(s.Pending) > 0 {
+		return fmt.Errorf("schema not migrated, missing %s", strings.Join(s.Pending, ", "))
+	}
+	return nil
+}
+
+// SchemaVersion returns the latest version the schema was migrated to, 0
+// when none was recorded.
+func (r *Repository) SchemaVersion(ctx context.Context) (int, error) {
+	return schemaVersion(r.db.WithContext(ctx))
+}
+
+// MigrationStatus returns the version of the schema and the tables and
+// columns it lacks.
+func (r *Repository) MigrationStatus(ctx context.Context) (MigrationStatus, error) {
+	version, err := r.SchemaVersion(ctx)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	pending, err := r.PendingMigrations(ctx)
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	return MigrationStatus{Version: version, Expected: SchemaVersion, Pending: pending}, nil
+}
+
+// schemaVersion returns the latest version recorded in db, 0 when none was.
+func schemaVersion(db *gorm.DB) (int, error) {
+	if !db.Migrator().HasTable(&dbmodels.SchemaVersion{}) {
+		return 0, nil
+	}
+	var version int
+	err := db.Model(&dbmodels.SchemaVersion{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// recordSchemaVersion records that the schema was migrated to
+// SchemaVersion. Instances migrating concurrently record it once.
+func recordSchemaVersion(db *gorm.DB) error {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&dbmodels.SchemaVersion{Version: SchemaVersion, MigratedAt: time.Now().UTC()}).Error
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	dbmodels "github.com/gartstein/xm/internal/company/db/models"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMigrationStatus verifies the schema version is recorded by migrating,
+// an unmigrated schema is reported as behind, and a schema migrated by a
+// newer build is compatible and left alone.
+func TestMigrationStatus(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{Driver: "sqlite", Path: filepath.Join(t.TempDir(), "company.db")}
+	repo, err := NewRepository(cfg, WithoutMigration())
+	require.NoError(t, err)
+	status, err := repo.MigrationStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, status.Version)
+	assert.Equal(t, SchemaVersion, status.Expected)
+	assert.EqualError(t, status.Err(), "schema version 0, expected 5")
+
+	require.NoError(t, migrate(repo.db))
+	require.NoError(t, migrate(repo.db), "migrating twice should record the version once")
+	status, err = repo.MigrationStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, MigrationStatus{Version: SchemaVersion, Expected: SchemaVersion}, status)
+	assert.NoError(t, status.Err())
+
+	require.NoError(t, repo.db.Create(&dbmodels.SchemaVersion{Version: SchemaVersion + 1}).Error)
+	require.NoError(t, repo.db.Exec("ALTER TABLE companies DROP COLUMN contact_email").Error)
+	require.NoError(t, migrate(repo.db))
+	status, err = repo.MigrationStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, SchemaVersion+1, status.Version)
+	assert.Equal(t, []string{"companies.contact_email"}, status.Pending, "a newer schema should not be migrated")
+	assert.EqualError(t, status.Err(), "schema not migrated, missing companies.contact_email")
+}
+
+// TestMigrateExternalRefIndex verifies migrating drops the external
+// reference index of version 3, which also covered deleted companies.
+func TestMigrateExternalRefIndex(t *testing.T) {
+	cfg := &Config{Driver: "sqlite", Path: filepath.Join(t.TempDir(), "company.db")}
+	repo, err := NewRepository(cfg)
+	require.NoError(t, err)
+	require.NoError(t, repo.db.Exec("CREATE UNIQUE INDEX idx_companies_external_ref ON companies (external_ref) WHERE external_ref <> ''").Error)
+
+	require.NoError(t, migrate(repo.db))
+	assert.False(t, repo.db.Migrator().HasIndex(&models.Company{}, "idx_companies_external_ref"))
+	assert.True(t, repo.db.Migrator().HasIndex(&models.Company{}, "idx_companies_live_external_ref"))
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEvent queues a company event recorded by a committed mutation until
+// the outbox relay publishes it. Seq orders the events of a company as their
+// mutations committed.
+type OutboxEvent struct {
+	Seq       uint64    `gorm:"primaryKey;autoIncrement"`
+	EventID   uuid.UUID `gorm:"type:uuid;uniqueIndex"`
+	CompanyID uuid.UUID `gorm:"type:uuid"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProcessedEvent records that a consumer group has handled an event, letting
+// consumers drop redelivered messages.
+type ProcessedEvent struct {
+	GroupID     string    `gorm:"primaryKey;size:255"`
+	EventID     uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ProcessedAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// SchemaVersion records that the schema was migrated to Version, once per
+// version, so instances can tell whether the schema is behind or ahead of
+// them.
+type SchemaVersion struct {
+	Version    int `gorm:"primaryKey;autoIncrement:false"`
+	MigratedAt time.Time
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// TenantMutationCount counts the mutations a tenant made in the minute
+// starting at WindowStart, to enforce its mutations-per-minute quota.
+type TenantMutationCount struct {
+	TenantID    string    `gorm:"primaryKey;size:64"`
+	WindowStart time.Time `gorm:"primaryKey"`
+	Count       int
+}
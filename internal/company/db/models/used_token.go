@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// UsedToken records the ID of a single-use token that has been presented,
+// so that it is refused if replayed before it expires.
+type UsedToken struct {
+	JTI       string    `gorm:"primaryKey;size:255"`
+	ExpiresAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreateCompanyNote inserts a new note.
+func (r *Repository) CreateCompanyNote(ctx context.Context, note *models.CompanyNote) error {
+	return r.conn(ctx).Create(note).Error
+}
+
+// GetCompanyNote returns the note with the given ID recorded on company.
+// Notes on other companies yield ErrNoteNotFound.
+func (r *Repository) GetCompanyNote(ctx context.Context, company, id uuid.UUID) (*models.CompanyNote, error) {
+	var note models.CompanyNote
+	result := r.conn(ctx).First(&note, "id = ? AND company_id = ?", id, company)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, e.ErrNoteNotFound
+		}
+		return nil, result.Error
+	}
+	return &note, nil
+}
+
+// ListCompanyNotes returns up to limit notes recorded on company, newest
+// first, skipping the first offset.
+func (r *Repository) ListCompanyNotes(ctx context.Context, company uuid.UUID, offset, limit int) ([]models.CompanyNote, error) {
+	var notes []models.CompanyNote
+	err := r.conn(ctx).Where("company_id = ?", company).
+		Order("created_at DESC, id DESC").Offset(offset).Limit(limit).
+		Find(&notes).Error
+	return notes, err
+}
+
+// DeleteCompanyNote removes the note with the given ID recorded on company.
+func (r *Repository) DeleteCompanyNote(ctx context.Context, company, id uuid.UUID) error {
+	result := r.conn(ctx).Delete(&models.CompanyNote{}, "id = ? AND company_id = ?", id, company)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return e.ErrNoteNotFound
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"hash/fnv"
+
+	dbmodels "github.com/gartstein/xm/internal/company/db/models"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+)
+
+// EnqueueOutboxEvent queues the recorded event of company for the outbox
+// relay, in the transaction ctx carries. On PostgreSQL it first takes a
+// transaction-level advisory lock of company, held until the transaction
+// ends, so the events of a company are numbered in the order their
+// transactions commit; SQLite transactions already take the write lock
+// when they begin.
+func (r *Repository) EnqueueOutboxEvent(ctx context.Context, company, event uuid.UUID) error {
+	conn := r.conn(ctx)
+	if r.db.Dialector.Name() == "postgres" {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte("company-outbox:"))
+		_, _ = h.Write(company[:])
+		if err := conn.Exec("SELECT pg_advisory_xact_lock(?)", r.lockKey(int64(h.Sum64()))).Error; err != nil {
+			return err
+		}
+	}
+	return conn.Create(&dbmodels.OutboxEvent{EventID: event, CompanyID: company}).Error
+}
+
+// ListOutboxEvents returns the recorded copies of up to limit queued events,
+// in the order they were queued.
+func (r *Repository) ListOutboxEvents(ctx context.Context, limit int) ([]models.CompanyEvent, error) {
+	var queued []models.CompanyEvent
+	err := r.conn(ctx).
+		Select("company_events.*").
+		Joins("JOIN outbox_events ON outbox_events.event_id = company_events.id").
+		Order("outbox_events.seq").
+		Limit(limit).
+		Find(&queued).Error
+	return queued, err
+}
+
+// DeleteOutboxEvents removes the given events from the outbox once they were
+// published.
+func (r *Repository) DeleteOutboxEvents(ctx context.Context, events []uuid.UUID) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return r.conn(ctx).Where("event_id IN ?", events).Delete(&dbmodels.OutboxEvent{}).Error
+}
@@ -0,0 +1,179 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// queryFiles holds the named queries, one directory per dialect and one
+// file per query.
+//
+//go:embed queries
+var queryFiles embed.FS
+
+// namedQuery is a read-only query kept in the registry, so that reports
+// run reviewed SQL rather than strings assembled by callers.
+//
+// A query file starts with comment lines: its description, then one
+// "-- param <name> <type>" line per parameter, referenced as @name in the
+// query. Types are time, string and int.
+type namedQuery struct {
+	Name        string
+	Description string
+	// Params maps the names of the parameters to their types.
+	Params map[string]string
+	// sql holds the query per dialect.
+	sql map[string]string
+}
+
+// queryParamTypes checks the parameter values of each type.
+var queryParamTypes = map[string]func(v interface{}) bool{
+	"time":   func(v interface{}) bool { _, ok := v.(time.Time); return ok },
+	"string": func(v interface{}) bool { _, ok := v.(string); return ok },
+	"int":    func(v interface{}) bool { _, ok := v.(int); return ok },
+}
+
+// queryParamPattern matches the parameter references of a query.
+var queryParamPattern = regexp.MustCompile(`@([a-z_]+)`)
+
+// queryDialects are the dialects every query must be written for.
+var queryDialects = []string{"postgres", "sqlite"}
+
+var namedQueries = mustLoadQueries(queryFiles)
+
+func mustLoadQueries(fsys fs.FS) map[string]*namedQuery {
+	queries, err := loadQueries(fsys)
+	if err != nil {
+		panic(err)
+	}
+	return queries
+}
+
+// loadQueries parses the queries of fsys, checking that each is written for
+// every dialect with the same parameters, and that the parameters it
+// references are the ones it declares.
+func loadQueries(fsys fs.FS) (map[string]*namedQuery, error) {
+	queries := map[string]*namedQuery{}
+	for _, dialect := range queryDialects {
+		files, err := fs.Glob(fsys, path.Join("queries", dialect, "*.sql"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			b, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return nil, err
+			}
+			query, sql, err := parseQuery(strings.TrimSuffix(path.Base(file), ".sql"), string(b))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			known, ok := queries[query.Name]
+			switch {
+			case !ok && dialect != queryDialects[0]:
+				return nil, fmt.Errorf("%s: query missing for %s", file, queryDialects[0])
+			case !ok:
+				query.sql = map[string]string{dialect: sql}
+				queries[query.Name] = query
+			case !maps.Equal(known.Params, query.Params):
+				return nil, fmt.Errorf("%s: parameters differ from the %s query", file, queryDialects[0])
+			default:
+				known.sql[dialect] = sql
+			}
+		}
+	}
+	for name, query := range queries {
+		for _, dialect := range queryDialects {
+			if _, ok := query.sql[dialect]; !ok {
+				return nil, fmt.Errorf("query %s missing for %s", name, dialect)
+			}
+		}
+	}
+	return queries, nil
+}
+
+// parseQuery parses the query file named name, returning its SQL apart.
+func parseQuery(name, text string) (*namedQuery, string, error) {
+	query := &namedQuery{Name: name, Params: map[string]string{}}
+	var description []string
+	lines := strings.Split(text, "\n")
+	i := 0
+	for ; i < len(lines) && strings.HasPrefix(lines[i], "--"); i++ {
+		line := strings.TrimSpace(strings.TrimPrefix(lines[i], "--"))
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "param" {
+			description = append(description, line)
+			continue
+		}
+		if len(fields) != 3 || !queryParamPattern.MatchString("@"+fields[1]) {
+			return nil, "", fmt.Errorf("invalid parameter declaration %q", line)
+		}
+		if queryParamTypes[fields[2]] == nil {
+			return nil, "", fmt.Errorf("parameter %s has unknown type %q", fields[1], fields[2])
+		}
+		query.Params[fields[1]] = fields[2]
+	}
+	query.Description = strings.Join(description, " ")
+	sql := strings.TrimSpace(strings.Join(lines[i:], "\n"))
+	if sql == "" {
+		return nil, "", fmt.Errorf("query is empty")
+	}
+	used := map[string]bool{}
+	for _, match := range queryParamPattern.FindAllStringSubmatch(sql, -1) {
+		used[match[1]] = true
+		if _, ok := query.Params[match[1]]; !ok {
+			return nil, "", fmt.Errorf("parameter %s is not declared", match[1])
+		}
+	}
+	for param := range query.Params {
+		if !used[param] {
+			return nil, "", fmt.Errorf("parameter %s is not used", param)
+		}
+	}
+	return query, sql, nil
+}
+
+// checkParams checks that params holds a value of the declared type for
+// every parameter of q, and nothing else.
+func (q *namedQuery) checkParams(params map[string]interface{}) error {
+	for name, typ := range q.Params {
+		v, ok := params[name]
+		if !ok {
+			return fmt.Errorf("parameter %s missing", name)
+		}
+		if !queryParamTypes[typ](v) {
+			return fmt.Errorf("parameter %s is %T, not %s", name, v, typ)
+		}
+	}
+	for name := range params {
+		if _, ok := q.Params[name]; !ok {
+			return fmt.Errorf("unknown parameter %s", name)
+		}
+	}
+	return nil
+}
+
+// RunNamedQuery runs the registry query name with params and scans its rows
+// into dest, a pointer to a slice of structs whose fields match its
+// columns.
+func (r *Repository) RunNamedQuery(ctx context.Context, name string, params map[string]interface{}, dest interface{}) error {
+	query, ok := namedQueries[name]
+	if !ok {
+		return fmt.Errorf("unknown query %s", name)
+	}
+	if err := query.checkParams(params); err != nil {
+		return fmt.Errorf("query %s: %w", name, err)
+	}
+	sql, ok := query.sql[r.db.Dialector.Name()]
+	if !ok {
+		return fmt.Errorf("query %s is not available on %s", name, r.db.Dialector.Name())
+	}
+	return r.conn(ctx).Raw(sql, params).Scan(dest).Error
+}
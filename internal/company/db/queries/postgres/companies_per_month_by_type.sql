@@ -0,0 +1,14 @@
+-- Companies created per calendar month (UTC) and type, including those
+-- deleted since.
+-- param since time
+-- param until time
+-- param tenant string
+SELECT to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM') AS month,
+       type AS key,
+       COUNT(*) AS count
+FROM companies
+WHERE created_at >= @since
+  AND created_at < @until
+  AND (@tenant = '' OR tenant_id = @tenant)
+GROUP BY 1, 2
+ORDER BY 1, 2
@@ -0,0 +1,8 @@
+-- Live companies per type and status.
+-- param tenant string
+SELECT type, status, COUNT(*) AS companies
+FROM companies
+WHERE deleted_at IS NULL
+  AND (@tenant = '' OR tenant_id = @tenant)
+GROUP BY type, status
+ORDER BY type, status
@@ -0,0 +1,16 @@
+-- Companies created per calendar month (UTC) and value of the metadata
+-- entry key, e.g. per country, including those deleted since. Companies
+-- without the entry are counted under an empty value.
+-- param since time
+-- param until time
+-- param tenant string
+-- param key string
+SELECT strftime('%Y-%m', created_at) AS month,
+       COALESCE(json_extract(metadata, '$."' || @key || '"'), '') AS key,
+       COUNT(*) AS count
+FROM companies
+WHERE created_at >= @since
+  AND created_at < @until
+  AND (@tenant = '' OR tenant_id = @tenant)
+GROUP BY 1, 2
+ORDER BY 1, 2
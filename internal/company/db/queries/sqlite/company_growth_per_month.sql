@@ -0,0 +1,21 @@
+-- Companies created and deleted per calendar month (UTC). Purged companies
+-- are gone and not counted.
+-- param since time
+-- param until time
+-- param tenant string
+SELECT month, SUM(created) AS created, SUM(deleted) AS deleted
+FROM (
+    SELECT strftime('%Y-%m', created_at) AS month, 1 AS created, 0 AS deleted
+    FROM companies
+    WHERE created_at >= @since
+      AND created_at < @until
+      AND (@tenant = '' OR tenant_id = @tenant)
+    UNION ALL
+    SELECT strftime('%Y-%m', deleted_at), 0, 1
+    FROM companies
+    WHERE deleted_at >= @since
+      AND deleted_at < @until
+      AND (@tenant = '' OR tenant_id = @tenant)
+) AS changes
+GROUP BY month
+ORDER BY month
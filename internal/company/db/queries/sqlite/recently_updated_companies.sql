@@ -0,0 +1,13 @@
+-- Live companies last updated in a time range, most recent first.
+-- param since time
+-- param until time
+-- param tenant string
+-- param limit int
+SELECT id, name, type, status, updated_by, updated_at
+FROM companies
+WHERE deleted_at IS NULL
+  AND updated_at >= @since
+  AND updated_at < @until
+  AND (@tenant = '' OR tenant_id = @tenant)
+ORDER BY updated_at DESC, id
+LIMIT @limit
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadQueries(t *testing.T) {
+	queries, err := loadQueries(queryFiles)
+	require.NoError(t, err)
+	query := queries["companies_per_month_by_metadata"]
+	require.NotNil(t, query)
+	assert.Equal(t, map[string]string{"since": "time", "until": "time", "tenant": "string", "key": "string"}, query.Params)
+	assert.Contains(t, query.Description, "per country")
+
+	const valid = "-- Counts.\n-- param since time\nSELECT COUNT(*) FROM companies WHERE created_at >= @since\n"
+	for name, tc := range map[string]struct {
+		postgres, sqlite string
+		err              string
+	}{
+		"undeclared parameter": {"SELECT @since", "SELECT @since", "parameter since is not declared"},
+		"unused parameter":     {"-- param since time\nSELECT 1", valid, "parameter since is not used"},
+		"unknown type":         {"-- param since date\nSELECT @since", valid, `unknown type "date"`},
+		"missing dialect":      {valid, "", "query q missing for sqlite"},
+		"differing parameters": {valid, "-- param until time\nSELECT @until", "parameters differ"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fsys := fstest.MapFS{"queries/postgres/q.sql": {Data: []byte(tc.postgres)}}
+			if tc.sqlite != "" {
+				fsys["queries/sqlite/q.sql"] = &fstest.MapFile{Data: []byte(tc.sqlite)}
+			}
+			_, err := loadQueries(fsys)
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestRunNamedQuery(t *testing.T) {
+	repo := SetupTestDB(t)
+	ctx := context.Background()
+	create := func(created time.Time, tenant string, companyType models.CompanyType, metadata models.Metadata) {
+		require.NoError(t, repo.CreateCompany(ctx, &models.Company{
+			ID:        uuid.New(),
+			Name:      uuid.NewString(),
+			Type:      companyType,
+			TenantID:  tenant,
+			Metadata:  metadata,
+			CreatedAt: created,
+		}))
+	}
+	jan := time.Date(2026, time.January, 10, 12, 0, 0, 0, time.UTC)
+	feb := time.Date(2026, time.February, 3, 8, 0, 0, 0, time.UTC)
+	create(jan, "acme", models.Corporations, models.Metadata{"country": "DE"})
+	create(jan, "acme", models.Corporations, models.Metadata{"country": "FR"})
+	create(feb, "acme", models.NonProfit, models.Metadata{"country": "DE"})
+	create(feb, "globex", models.Corporations, nil)
+	create(jan.AddDate(-1, 0, 0), "acme", models.Corporations, nil)
+
+	params := map[string]interface{}{
+		"since":  time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC),
+		"until":  time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC),
+		"tenant": "",
+	}
+	var counts []models.MonthlyCount
+	require.NoError(t, repo.RunNamedQuery(ctx, "companies_per_month_by_type", params, &counts))
+	assert.Equal(t, []models.MonthlyCount{
+		{Month: "2026-01", Key: string(models.Corporations), Count: 2},
+		{Month: "2026-02", Key: string(models.Corporations), Count: 1},
+		{Month: "2026-02", Key: string(models.NonProfit), Count: 1},
+	}, counts)
+
+	params["tenant"], params["key"] = "acme", "country"
+	counts = nil
+	require.NoError(t, repo.RunNamedQuery(ctx, "companies_per_month_by_metadata", params, &counts))
+	assert.Equal(t, []models.MonthlyCount{
+		{Month: "2026-01", Key: "DE", Count: 1},
+		{Month: "2026-01", Key: "FR", Count: 1},
+		{Month: "2026-02", Key: "DE", Count: 1},
+	}, counts)
+
+	assert.ErrorContains(t, repo.RunNamedQuery(ctx, "companies_per_country", params, &counts), "unknown query")
+	delete(params, "key")
+	assert.ErrorContains(t, repo.RunNamedQuery(ctx, "companies_per_month_by_metadata", params, &counts), "parameter key missing")
+	assert.ErrorContains(t, repo.RunNamedQuery(ctx, "companies_per_month_by_type", map[string]interface{}{
+		"since": "2026-01-01", "until": time.Now(), "tenant": "",
+	}, &counts), "parameter since is string, not time")
+	params["limit"] = 10
+	assert.ErrorContains(t, repo.RunNamedQuery(ctx, "companies_per_month_by_type", params, &counts), "unknown parameter limit")
+}
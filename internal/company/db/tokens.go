@@ -0,0 +1,28 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	dbmodels "github.com/gartstein/xm/internal/company/db/models"
+	"gorm.io/gorm/clause"
+)
+
+// UseToken records the single-use token jti as used until expires and
+// reports whether it was unused, so that every instance of the service
+// refuses a replayed token.
+func (r *Repository) UseToken(ctx context.Context, jti string, expires time.Time) (bool, error) {
+	result := r.conn(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&dbmodels.UsedToken{JTI: jti, ExpiresAt: expires})
+	return result.RowsAffected == 1, result.Error
+}
+
+// PurgeUsedTokens deletes the records of tokens expired before before, which
+// are refused for their expiry anyway, and returns the number removed.
+func (r *Repository) PurgeUsedTokens(ctx context.Context, before time.Time) (int64, error) {
+	result := r.conn(ctx).
+		Where("expires_at < ?", before).
+		Delete(&dbmodels.UsedToken{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"strings"
+	"unicode"
+)
+
+// trigramSimilarity mirrors pg_trgm's similarity(): the number of trigrams
+// shared by a and b divided by the number of distinct trigrams in either. It
+// backs name similarity on databases without pg_trgm.
+func trigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// trigrams returns the set of trigrams of s the way pg_trgm extracts them:
+// lower-cased alphanumeric words, each padded with two spaces in front and
+// one behind.
+func trigrams(s string) map[string]bool {
+	set := make(map[string]bool)
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		padded := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
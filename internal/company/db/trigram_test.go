@@ -0,0 +1,28 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrigramSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"word", "word", 1},
+		{"Word", "wORD", 1},
+		// The example from the pg_trgm documentation.
+		{"word", "two words", 0.363636},
+		// 6 shared of 14 distinct trigrams.
+		{"Acme Corp", "Acme Crop", 0.428571},
+		{"abc", "xyz", 0},
+		{"", "abc", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			assert.InDelta(t, tt.want, trigramSimilarity(tt.a, tt.b), 1e-6)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// requestTx is the transaction a context carries, with the database it was
+// begun on, so only repositories of that database join it.
+type requestTx struct {
+	root        *gorm.DB
+	tx          *gorm.DB
+	afterCommit []func()
+}
+
+type requestTxKey struct{}
+
+// RunInTransaction runs fn with a context carrying a new transaction. The
+// methods of r called with that context run in the transaction instead of
+// on a connection of their own, so multi-step flows are atomic without
+// managing transactions themselves. The transaction commits when fn returns
+// nil and rolls back otherwise; the functions registered with AfterCommit
+// run once it committed. Calls with a context already carrying a
+// transaction of r join it.
+func (r *Repository) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if current, ok := ctx.Value(requestTxKey{}).(*requestTx); ok && current.root == r.db {
+		return fn(ctx)
+	}
+	current := &requestTx{root: r.db}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		current.tx = tx
+		return fn(context.WithValue(ctx, requestTxKey{}, current))
+	})
+	if err != nil {
+		return err
+	}
+	for _, fn := range current.afterCommit {
+		fn()
+	}
+	return nil
+}
+
+// AfterCommit defers fn until the transaction begun by RunInTransaction that
+// ctx carries committed; fn never runs if it rolls back. Without a
+// transaction fn runs right away.
+func AfterCommit(ctx context.Context, fn func()) {
+	if current, ok := ctx.Value(requestTxKey{}).(*requestTx); ok {
+		current.afterCommit = append(current.afterCommit, fn)
+		return
+	}
+	fn()
+}
+
+// conn returns the session statements of r run in: the transaction ctx
+// carries when it was begun on r, or r's own.
+func (r *Repository) conn(ctx context.Context) *gorm.DB {
+	if current, ok := ctx.Value(requestTxKey{}).(*requestTx); ok && current.root == r.db {
+		return current.tx.WithContext(ctx)
+	}
+	return r.db.WithContext(ctx)
+}
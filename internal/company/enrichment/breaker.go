@@ -0,0 +1,68 @@
+package enrichment
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// errCircuitOpen is returned for lookups skipped because the provider's
+// circuit breaker is open.
+var errCircuitOpen = errors.New("circuit breaker open")
+
+// breaker is a circuit breaker guarding one provider. It opens after a
+// number of consecutive failures, rejects calls for a cooldown, then lets a
+// single probe call through: success closes it, failure opens it again.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	open     bool
+	probing  bool
+}
+
+// newBreaker returns a closed breaker opening after threshold consecutive
+// failures; a threshold of 0 never opens.
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow reports whether a call may go through.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return true
+	}
+	if b.probing || b.now().Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// success records a successful call, closing the breaker.
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.open = false
+	b.probing = false
+}
+
+// failure records a failed call, opening the breaker once the threshold is
+// reached or when the probe call failed.
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.probing || (b.threshold > 0 && b.failures >= b.threshold) {
+		b.open = true
+		b.probing = false
+		b.openedAt = b.now()
+	}
+}
@@ -0,0 +1,240 @@
+// Package enrichment looks up data about new companies, such as their
+// headcount in a business registry, through pluggable providers and fills
+// in the attributes their creators left empty. Lookups run in the
+// background after create, are retried with exponential backoff and go
+// through a circuit breaker per provider.
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// queueSize bounds the companies waiting for enrichment; further
+	// companies are not enriched.
+	queueSize = 100
+	// workers is the number of companies enriched concurrently.
+	workers = 4
+	// lookupTimeout bounds each provider call.
+	lookupTimeout = 10 * time.Second
+)
+
+// ErrNoMatch is returned by providers that know nothing about a company. It
+// is neither retried nor counted against the provider's circuit breaker.
+var ErrNoMatch = errors.New("no matching company")
+
+// Provider looks up a company in one data source.
+type Provider interface {
+	// Name identifies the provider in logs and in Enrichment.Sources.
+	Name() string
+	// Enrich returns the attributes found for company, or ErrNoMatch.
+	Enrich(ctx context.Context, company models.Company) (models.Enrichment, error)
+}
+
+// Applier stores enrichment results, usually the company service.
+type Applier interface {
+	ApplyEnrichment(ctx context.Context, id uuid.UUID, result models.Enrichment) (*models.Company, error)
+}
+
+// Pipeline enriches the companies handed to Enqueue by asking every
+// provider in turn; attributes found by earlier providers take precedence.
+type Pipeline struct {
+	providers []Provider
+	breakers  map[string]*breaker
+	logger    *zap.Logger
+	queue     chan models.Company
+
+	maxAttempts     int
+	backoff         time.Duration
+	breakerFailures int
+	breakerCooldown time.Duration
+	sleep           func(ctx context.Context, d time.Duration) error
+}
+
+// Option customizes a Pipeline created by NewPipeline.
+type Option func(*Pipeline)
+
+// WithRetry makes each lookup try a provider up to maxAttempts times,
+// waiting backoff, then twice as long after every further failure.
+func WithRetry(maxAttempts int, backoff time.Duration) Option {
+	return func(p *Pipeline) {
+		p.maxAttempts = maxAttempts
+		p.backoff = backoff
+	}
+}
+
+// WithCircuitBreaker stops calling a provider for cooldown after failures
+// consecutive failed calls; a single call then probes whether it recovered.
+// A failures of 0 disables the breaker.
+func WithCircuitBreaker(failures int, cooldown time.Duration) Option {
+	return func(p *Pipeline) {
+		p.breakerFailures = failures
+		p.breakerCooldown = cooldown
+	}
+}
+
+// NewPipeline returns a Pipeline asking providers, in order. By default
+// each provider is tried once and breaks after 5 consecutive failures for a
+// minute. Call Start to process the enqueued companies.
+func NewPipeline(providers []Provider, logger *zap.Logger, opts ...Option) *Pipeline {
+	p := &Pipeline{
+		providers:       providers,
+		breakers:        make(map[string]*breaker, len(providers)),
+		logger:          logger.Named("enrichment"),
+		queue:           make(chan models.Company, queueSize),
+		maxAttempts:     1,
+		breakerFailures: 5,
+		breakerCooldown: time.Minute,
+		sleep:           sleep,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	for _, provider := range providers {
+		p.breakers[provider.Name()] = newBreaker(p.breakerFailures, p.breakerCooldown)
+	}
+	return p
+}
+
+// Enqueue implements controller.Enricher. It never blocks: when the queue
+// is full the company is not enriched.
+func (p *Pipeline) Enqueue(company models.Company) {
+	select {
+	case p.queue <- company:
+	default:
+		p.logger.Warn("Enrichment queue full, skipping company", zap.String("company_id", company.ID.String()))
+	}
+}
+
+// Start enriches the enqueued companies and hands the results to applier
+// until ctx is done.
+func (p *Pipeline) Start(ctx context.Context, applier Applier) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case company := <-p.queue:
+					p.process(ctx, applier, company)
+				}
+			}
+		}()
+	}
+}
+
+// process enriches company and applies the result, if any.
+func (p *Pipeline) process(ctx context.Context, applier Applier, company models.Company) {
+	logger := p.logger.With(zap.String("company_id", company.ID.String()))
+	result := p.Enrich(ctx, company)
+	if len(result.Sources) == 0 {
+		return
+	}
+	_, err := applier.ApplyEnrichment(ctx, company.ID, result)
+	switch {
+	case errors.Is(err, e.ErrNotFound):
+		logger.Debug("Company deleted before enrichment")
+	case err != nil:
+		logger.Error("Failed to apply enrichment", zap.Error(err), zap.Strings("sources", result.Sources))
+	default:
+		logger.Info("Company enriched", zap.Strings("sources", result.Sources))
+	}
+}
+
+// Enrich asks every provider about company and merges what they found.
+// Failing providers are logged and skipped.
+func (p *Pipeline) Enrich(ctx context.Context, company models.Company) models.Enrichment {
+	var result models.Enrichment
+	for _, provider := range p.providers {
+		found, err := p.lookup(ctx, provider, company)
+		if errors.Is(err, ErrNoMatch) {
+			continue
+		}
+		if err != nil {
+			p.logger.Warn("Enrichment lookup failed",
+				zap.String("provider", provider.Name()),
+				zap.String("company_id", company.ID.String()),
+				zap.Error(err),
+			)
+			continue
+		}
+		merge(&result, found, provider.Name())
+	}
+	return result
+}
+
+// lookup calls provider through its breaker, retrying failures.
+func (p *Pipeline) lookup(ctx context.Context, provider Provider, company models.Company) (models.Enrichment, error) {
+	b := p.breakers[provider.Name()]
+	backoff := p.backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if !b.allow() {
+			return models.Enrichment{}, errCircuitOpen
+		}
+		var found models.Enrichment
+		found, err = p.call(ctx, provider, company)
+		if err == nil || errors.Is(err, ErrNoMatch) {
+			b.success()
+			return found, err
+		}
+		b.failure()
+		if attempt >= p.maxAttempts {
+			return models.Enrichment{}, err
+		}
+		if err := p.sleep(ctx, backoff); err != nil {
+			return models.Enrichment{}, err
+		}
+		backoff *= 2
+	}
+}
+
+// call runs a single provider call with a timeout.
+func (p *Pipeline) call(ctx context.Context, provider Provider, company models.Company) (models.Enrichment, error) {
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+	return provider.Enrich(ctx, company)
+}
+
+// merge copies into result the attributes of found it does not have yet.
+func merge(result *models.Enrichment, found models.Enrichment, source string) {
+	contributed := false
+	if result.Description == nil && found.Description != nil {
+		result.Description = found.Description
+		contributed = true
+	}
+	if result.Employees == nil && found.Employees != nil {
+		result.Employees = found.Employees
+		contributed = true
+	}
+	if result.Type == nil && found.Type != nil {
+		result.Type = found.Type
+		contributed = true
+	}
+	if result.Registered == nil && found.Registered != nil {
+		result.Registered = found.Registered
+		contributed = true
+	}
+	if contributed {
+		result.Sources = append(result.Sources, source)
+	}
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
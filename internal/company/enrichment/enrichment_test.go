@@ -0,0 +1,157 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// stubProvider answers with result after failing its first failures calls.
+type stubProvider struct {
+	name     string
+	result   models.Enrichment
+	err      error
+	failures int
+	calls    int
+}
+
+func (p *stubProvider) Name() string { return p.name }
+
+func (p *stubProvider) Enrich(context.Context, models.Company) (models.Enrichment, error) {
+	p.calls++
+	if p.calls <= p.failures {
+		return models.Enrichment{}, errors.New("unavailable")
+	}
+	return p.result, p.err
+}
+
+// recordingApplier records the enrichment results applied.
+type recordingApplier struct {
+	mu      sync.Mutex
+	results map[uuid.UUID]models.Enrichment
+}
+
+func (a *recordingApplier) ApplyEnrichment(_ context.Context, id uuid.UUID, result models.Enrichment) (*models.Company, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.results[id] = result
+	return &models.Company{ID: id}, nil
+}
+
+func (a *recordingApplier) applied(id uuid.UUID) (models.Enrichment, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	result, ok := a.results[id]
+	return result, ok
+}
+
+// noSleep makes retries immediate.
+func noSleep(context.Context, time.Duration) error { return nil }
+
+func TestPipeline_Enrich(t *testing.T) {
+	registry := &stubProvider{name: "registry", result: models.Enrichment{
+		Employees:  utils.Ptr(120),
+		Registered: utils.Ptr(true),
+	}}
+	web := &stubProvider{name: "web", result: models.Enrichment{
+		Description: utils.Ptr("Anvils"),
+		Employees:   utils.Ptr(100),
+	}}
+	unknown := &stubProvider{name: "unknown", err: ErrNoMatch}
+	p := NewPipeline([]Provider{unknown, registry, web}, zaptest.NewLogger(t))
+
+	result := p.Enrich(context.Background(), models.Company{Name: "Acme"})
+	assert.Equal(t, 120, *result.Employees, "earlier providers should take precedence")
+	assert.Equal(t, "Anvils", *result.Description)
+	assert.True(t, *result.Registered)
+	assert.Nil(t, result.Type)
+	assert.Equal(t, []string{"registry", "web"}, result.Sources)
+}
+
+func TestPipeline_Retry(t *testing.T) {
+	flaky := &stubProvider{name: "flaky", failures: 2, result: models.Enrichment{Employees: utils.Ptr(5)}}
+	p := NewPipeline([]Provider{flaky}, zaptest.NewLogger(t), WithRetry(3, time.Second))
+	var waits []time.Duration
+	p.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	result := p.Enrich(context.Background(), models.Company{Name: "Acme"})
+	assert.Equal(t, 5, *result.Employees)
+	assert.Equal(t, 3, flaky.calls)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, waits)
+
+	down := &stubProvider{name: "down", failures: 10}
+	p = NewPipeline([]Provider{down}, zaptest.NewLogger(t), WithRetry(3, time.Second))
+	p.sleep = noSleep
+	assert.Empty(t, p.Enrich(context.Background(), models.Company{Name: "Acme"}).Sources)
+	assert.Equal(t, 3, down.calls)
+
+	unknown := &stubProvider{name: "unknown", err: ErrNoMatch}
+	p = NewPipeline([]Provider{unknown}, zaptest.NewLogger(t), WithRetry(3, time.Second))
+	p.sleep = noSleep
+	p.Enrich(context.Background(), models.Company{Name: "Acme"})
+	assert.Equal(t, 1, unknown.calls, "unknown companies should not be retried")
+}
+
+func TestPipeline_CircuitBreaker(t *testing.T) {
+	down := &stubProvider{name: "down", failures: 4, result: models.Enrichment{Employees: utils.Ptr(5)}}
+	p := NewPipeline([]Provider{down}, zaptest.NewLogger(t), WithCircuitBreaker(3, time.Minute))
+	now := time.Now()
+	p.breakers["down"].now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		p.Enrich(context.Background(), models.Company{Name: "Acme"})
+	}
+	assert.Equal(t, 3, down.calls, "an open breaker should skip the provider")
+
+	now = now.Add(time.Minute)
+	p.Enrich(context.Background(), models.Company{Name: "Acme"})
+	assert.Equal(t, 4, down.calls, "a probe call should go through after the cooldown")
+	p.Enrich(context.Background(), models.Company{Name: "Acme"})
+	assert.Equal(t, 4, down.calls, "a failed probe should open the breaker again")
+
+	now = now.Add(time.Minute)
+	result := p.Enrich(context.Background(), models.Company{Name: "Acme"})
+	assert.Equal(t, []string{"down"}, result.Sources)
+	p.Enrich(context.Background(), models.Company{Name: "Acme"})
+	assert.Equal(t, 6, down.calls, "a successful probe should close the breaker")
+}
+
+func TestPipeline_Start(t *testing.T) {
+	registry := &stubProvider{name: "registry", result: models.Enrichment{Employees: utils.Ptr(120)}}
+	p := NewPipeline([]Provider{registry}, zaptest.NewLogger(t))
+	applier := &recordingApplier{results: map[uuid.UUID]models.Enrichment{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	company := models.Company{ID: uuid.New(), Name: "Acme"}
+	p.Enqueue(company)
+	p.Start(ctx, applier)
+
+	require.Eventually(t, func() bool {
+		_, ok := applier.applied(company.ID)
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	result, _ := applier.applied(company.ID)
+	assert.Equal(t, 120, *result.Employees)
+	assert.Equal(t, []string{"registry"}, result.Sources)
+}
+
+func TestPipeline_EnqueueFull(t *testing.T) {
+	p := NewPipeline(nil, zaptest.NewLogger(t))
+	for i := 0; i < queueSize+1; i++ {
+		p.Enqueue(models.Company{ID: uuid.New()})
+	}
+	assert.Len(t, p.queue, queueSize)
+}
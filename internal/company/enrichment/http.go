@@ -0,0 +1,106 @@
+package enrichment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/gartstein/xm/internal/company/models"
+)
+
+// maxResponseSize bounds the provider responses read.
+const maxResponseSize = 1 << 20
+
+// HTTPConfig configures an HTTPProvider.
+type HTTPConfig struct {
+	// Name identifies the provider.
+	Name string `yaml:"NAME"`
+	// URL is the lookup endpoint, called as URL?name=<company name>.
+	URL string `yaml:"URL"`
+	// APIKey, when set, is sent as a bearer token.
+	APIKey string `yaml:"API_KEY"`
+}
+
+// HTTPProvider looks companies up by name in a JSON HTTP API, such as a
+// business registry or a gateway in front of one. The API answers with
+// {"description", "employees", "type", "registered"}, any of which may be
+// missing, or 404 when it knows no such company.
+type HTTPProvider struct {
+	name   string
+	url    *url.URL
+	apiKey string
+	client *http.Client
+}
+
+// httpResult is the JSON answered by the provider API.
+type httpResult struct {
+	Description *string `json:"description"`
+	Employees   *int    `json:"employees"`
+	Type        *string `json:"type"`
+	Registered  *bool   `json:"registered"`
+}
+
+// NewHTTPProvider returns an HTTPProvider for cfg.
+func NewHTTPProvider(cfg HTTPConfig) (*HTTPProvider, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("enrichment provider name required")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("enrichment provider %s: invalid URL %q", cfg.Name, cfg.URL)
+	}
+	return &HTTPProvider{name: cfg.Name, url: u, apiKey: cfg.APIKey, client: &http.Client{}}, nil
+}
+
+// Name implements Provider.
+func (p *HTTPProvider) Name() string {
+	return p.name
+}
+
+// Enrich implements Provider. Company types the service does not know are
+// ignored.
+func (p *HTTPProvider) Enrich(ctx context.Context, company models.Company) (models.Enrichment, error) {
+	u := *p.url
+	query := u.Query()
+	query.Set("name", company.Name)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return models.Enrichment{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return models.Enrichment{}, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return models.Enrichment{}, ErrNoMatch
+	case resp.StatusCode/100 != 2:
+		return models.Enrichment{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var result httpResult
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&result); err != nil {
+		return models.Enrichment{}, fmt.Errorf("invalid response: %w", err)
+	}
+	found := models.Enrichment{
+		Description: result.Description,
+		Employees:   result.Employees,
+		Registered:  result.Registered,
+	}
+	if result.Type != nil {
+		if t := models.CompanyType(*result.Type); t.Valid() {
+			found.Type = &t
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,57 @@
+package enrichment
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		switch r.URL.Query().Get("name") {
+		case "Acme & Co":
+			_, _ = w.Write([]byte(`{"employees": 120, "type": "NON_PROFIT", "registered": true}`))
+		case "Globex":
+			_, _ = w.Write([]byte(`{"description": "Anvils", "type": "LLC"}`))
+		case "Broken":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p, err := NewHTTPProvider(HTTPConfig{Name: "registry", URL: server.URL + "/lookup", APIKey: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "registry", p.Name())
+
+	found, err := p.Enrich(context.Background(), models.Company{Name: "Acme & Co"})
+	require.NoError(t, err)
+	assert.Equal(t, 120, *found.Employees)
+	assert.Equal(t, models.NonProfit, *found.Type)
+	assert.True(t, *found.Registered)
+	assert.Nil(t, found.Description)
+
+	found, err = p.Enrich(context.Background(), models.Company{Name: "Globex"})
+	require.NoError(t, err)
+	assert.Equal(t, "Anvils", *found.Description)
+	assert.Nil(t, found.Type, "unknown company types should be ignored")
+
+	_, err = p.Enrich(context.Background(), models.Company{Name: "Initech"})
+	assert.ErrorIs(t, err, ErrNoMatch)
+	_, err = p.Enrich(context.Background(), models.Company{Name: "Broken"})
+	assert.ErrorContains(t, err, "unexpected status 503")
+}
+
+func TestNewHTTPProvider_Invalid(t *testing.T) {
+	_, err := NewHTTPProvider(HTTPConfig{URL: "https://registry.example.com"})
+	assert.Error(t, err)
+	_, err = NewHTTPProvider(HTTPConfig{Name: "registry", URL: "ftp://registry.example.com"})
+	assert.Error(t, err)
+}
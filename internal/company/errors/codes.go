@@ -0,0 +1,289 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Code is a stable, machine-readable error code. Codes are part of the API:
+// clients and support staff may rely on them, so existing codes are never
+// renamed or reused for another meaning.
+type Code string
+
+const (
+	CodeNotFound                    Code = "NOT_FOUND"
+	CodeCompanyNotFound             Code = "COMPANY_NOT_FOUND"
+	CodeNameTaken                   Code = "NAME_TAKEN"
+	CodeNameSimilar                 Code = "NAME_SIMILAR"
+	CodeNameRequired                Code = "NAME_REQUIRED"
+	CodeNameTooLong                 Code = "NAME_TOO_LONG"
+	CodeDescriptionTooLong          Code = "DESCRIPTION_TOO_LONG"
+	CodeEmployeesNegative           Code = "EMPLOYEES_NEGATIVE"
+	CodeContactEmailInvalid         Code = "CONTACT_EMAIL_INVALID"
+	CodeCompanyIDInvalid            Code = "COMPANY_ID_INVALID"
+	CodeCompanyTypeUnknown          Code = "COMPANY_TYPE_UNKNOWN"
+	CodeInitialStatusInvalid        Code = "INITIAL_STATUS_INVALID"
+	CodeStatusUnknown               Code = "STATUS_UNKNOWN"
+	CodeStatusTransitionNotAllowed  Code = "STATUS_TRANSITION_NOT_ALLOWED"
+	CodeExternalRefRequired         Code = "EXTERNAL_REF_REQUIRED"
+	CodeExternalRefTooLong          Code = "EXTERNAL_REF_TOO_LONG"
+	CodeExternalRefTaken            Code = "EXTERNAL_REF_TAKEN"
+	CodeExternalRefRepeated         Code = "EXTERNAL_REF_REPEATED"
+	CodeDesiredStateTooLarge        Code = "DESIRED_STATE_TOO_LARGE"
+	CodePageSizeInvalid             Code = "PAGE_SIZE_INVALID"
+	CodePageTokenInvalid            Code = "PAGE_TOKEN_INVALID"
+	CodeCallerUnidentified          Code = "CALLER_UNIDENTIFIED"
+	CodeReplayTopicRequired         Code = "REPLAY_TOPIC_REQUIRED"
+	CodeTimeRangeInvalid            Code = "TIME_RANGE_INVALID"
+	CodeEventTypeUnknown            Code = "EVENT_TYPE_UNKNOWN"
+	CodeWebhookNameInvalid          Code = "WEBHOOK_NAME_INVALID"
+	CodeWebhookKindUnknown          Code = "WEBHOOK_KIND_UNKNOWN"
+	CodeWebhookURLInvalid           Code = "WEBHOOK_URL_INVALID"
+	CodeMinEmployeesNegative        Code = "MIN_EMPLOYEES_NEGATIVE"
+	CodeNotOwner                    Code = "NOT_OWNER"
+	CodeReadOnly                    Code = "READ_ONLY"
+	CodeQuotaExceeded               Code = "QUOTA_EXCEEDED"
+	CodeOverloaded                  Code = "OVERLOADED"
+	CodeLatencyBudgetExceeded       Code = "LATENCY_BUDGET_EXCEEDED"
+	CodeValidationRejected          Code = "VALIDATION_REJECTED"
+	CodeValidationUnavailable       Code = "VALIDATION_UNAVAILABLE"
+	CodeAdminRequired               Code = "ADMIN_REQUIRED"
+	CodeTenantIDRequired            Code = "TENANT_ID_REQUIRED"
+	CodeQuotaLimitNegative          Code = "QUOTA_LIMIT_NEGATIVE"
+	CodeEmployeeNotFound            Code = "EMPLOYEE_NOT_FOUND"
+	CodeEmployeeIDInvalid           Code = "EMPLOYEE_ID_INVALID"
+	CodeEmployeeNameRequired        Code = "EMPLOYEE_NAME_REQUIRED"
+	CodeEmployeeNameTooLong         Code = "EMPLOYEE_NAME_TOO_LONG"
+	CodeEmployeeTitleTooLong        Code = "EMPLOYEE_TITLE_TOO_LONG"
+	CodeEmployeeEmailInvalid        Code = "EMPLOYEE_EMAIL_INVALID"
+	CodeEmployeesDerived            Code = "EMPLOYEES_DERIVED"
+	CodeMetadataKeyInvalid          Code = "METADATA_KEY_INVALID"
+	CodeMetadataValueTooLong        Code = "METADATA_VALUE_TOO_LONG"
+	CodeMetadataTooLarge            Code = "METADATA_TOO_LARGE"
+	CodeNoteNotFound                Code = "NOTE_NOT_FOUND"
+	CodeNoteBodyRequired            Code = "NOTE_BODY_REQUIRED"
+	CodeNoteBodyTooLong             Code = "NOTE_BODY_TOO_LONG"
+	CodeNoteNotAuthor               Code = "NOTE_NOT_AUTHOR"
+	CodeResumeTokenInvalid          Code = "RESUME_TOKEN_INVALID"
+	CodeReportNotFound              Code = "REPORT_NOT_FOUND"
+	CodeReportFormatUnsupported     Code = "REPORT_FORMAT_UNSUPPORTED"
+	CodeReportLimitInvalid          Code = "REPORT_LIMIT_INVALID"
+	CodeDuplicateSuggestionNotFound Code = "DUPLICATE_SUGGESTION_NOT_FOUND"
+	CodeDuplicateSuggestionResolved Code = "DUPLICATE_SUGGESTION_RESOLVED"
+	CodeDuplicateResolutionUnknown  Code = "DUPLICATE_RESOLUTION_UNKNOWN"
+	CodeDuplicateStatusUnknown      Code = "DUPLICATE_STATUS_UNKNOWN"
+	CodeMergeSameCompany            Code = "MERGE_SAME_COMPANY"
+	CodeMergeKeepInvalid            Code = "MERGE_KEEP_INVALID"
+	CodeMergeTenantMismatch         Code = "MERGE_TENANT_MISMATCH"
+	CodeCompanyTypeNotFound         Code = "COMPANY_TYPE_NOT_FOUND"
+	CodeCompanyTypeExists           Code = "COMPANY_TYPE_EXISTS"
+	CodeCompanyTypeCodeInvalid      Code = "COMPANY_TYPE_CODE_INVALID"
+	CodeCompanyTypeNameInvalid      Code = "COMPANY_TYPE_NAME_INVALID"
+	CodeCompanyTypeBuiltin          Code = "COMPANY_TYPE_BUILTIN"
+	CodeCompanyTypeInUse            Code = "COMPANY_TYPE_IN_USE"
+	CodeInvalidInput                Code = "INVALID_INPUT"
+	CodeInternal                    Code = "INTERNAL"
+)
+
+// Broad error reasons, attached to API errors next to the code. Each code
+// refines exactly one reason, so clients that only know the reasons keep
+// working when codes are added.
+const (
+	ReasonNotFound                = "NOT_FOUND"
+	ReasonDuplicateName           = "DUPLICATE_NAME"
+	ReasonSimilarName             = "SIMILAR_NAME"
+	ReasonDuplicateExternalRef    = "DUPLICATE_EXTERNAL_REF"
+	ReasonInvalidInput            = "INVALID_INPUT"
+	ReasonInvalidStatusTransition = "INVALID_STATUS_TRANSITION"
+	ReasonNotOwner                = "NOT_OWNER"
+	ReasonReadOnly                = "READ_ONLY"
+	ReasonQuotaExceeded           = "QUOTA_EXCEEDED"
+	ReasonOverloaded              = "OVERLOADED"
+	ReasonTimeout                 = "TIMEOUT"
+	ReasonValidationFailed        = "VALIDATION_FAILED"
+	ReasonAdminRequired           = "ADMIN_REQUIRED"
+	ReasonInternal                = "INTERNAL"
+)
+
+// CodeInfo describes a Code.
+type CodeInfo struct {
+	Code        Code
+	Reason      string
+	GRPCCode    codes.Code
+	Description string
+	// parent is the sentinel errors of the code match; nil for INTERNAL.
+	parent error
+}
+
+// registry holds every Code. It is the single source of the error table
+// served by the API.
+var registry = map[Code]CodeInfo{}
+
+func init() {
+	for _, info := range []CodeInfo{
+		{CodeNotFound, ReasonNotFound, codes.NotFound, "The requested resource does not exist.", ErrNotFound},
+		{CodeCompanyNotFound, ReasonNotFound, codes.NotFound, "No company exists with the given ID, name or external reference.", ErrNotFound},
+		{CodeNameTaken, ReasonDuplicateName, codes.AlreadyExists, "Another company already has this name.", ErrDuplicateName},
+		{CodeNameSimilar, ReasonSimilarName, codes.AlreadyExists, "Existing companies have names close to this one; they are listed in the error details.", ErrDuplicateName},
+		{CodeNameRequired, ReasonInvalidInput, codes.InvalidArgument, "The company name is empty.", ErrInvalidInput},
+		{CodeNameTooLong, ReasonInvalidInput, codes.InvalidArgument, "The company name is longer than 15 characters.", ErrInvalidInput},
+		{CodeDescriptionTooLong, ReasonInvalidInput, codes.InvalidArgument, "The description is longer than 3000 characters.", ErrInvalidInput},
+		{CodeEmployeesNegative, ReasonInvalidInput, codes.InvalidArgument, "The number of employees is negative.", ErrInvalidInput},
+		{CodeContactEmailInvalid, ReasonInvalidInput, codes.InvalidArgument, "The contact email is not a valid address.", ErrInvalidInput},
+		{CodeCompanyIDInvalid, ReasonInvalidInput, codes.InvalidArgument, "The company ID is missing or not a UUID.", ErrInvalidInput},
+		{CodeCompanyTypeUnknown, ReasonInvalidInput, codes.InvalidArgument, "The company type is not one of the types of the taxonomy.", ErrInvalidInput},
+		{CodeInitialStatusInvalid, ReasonInvalidInput, codes.InvalidArgument, "New companies must be DRAFT or ACTIVE.", ErrInvalidInput},
+		{CodeStatusUnknown, ReasonInvalidInput, codes.InvalidArgument, "The status is not one of the known statuses.", ErrInvalidInput},
+		{CodeStatusTransitionNotAllowed, ReasonInvalidStatusTransition, codes.FailedPrecondition, "The company lifecycle does not allow moving from its current status to the requested one.", ErrInvalidStatusTransition},
+		{CodeExternalRefRequired, ReasonInvalidInput, codes.InvalidArgument, "The external reference is empty.", ErrInvalidInput},
+		{CodeExternalRefTooLong, ReasonInvalidInput, codes.InvalidArgument, "The external reference is longer than 255 characters.", ErrInvalidInput},
+		{CodeExternalRefTaken, ReasonDuplicateExternalRef, codes.AlreadyExists, "Another company already carries this external reference.", ErrDuplicateExternalRef},
+		{CodeExternalRefRepeated, ReasonInvalidInput, codes.InvalidArgument, "The desired state lists an external reference more than once.", ErrInvalidInput},
+		{CodeDesiredStateTooLarge, ReasonInvalidInput, codes.InvalidArgument, "The desired state lists more than 1000 companies.", ErrInvalidInput},
+		{CodePageSizeInvalid, ReasonInvalidInput, codes.InvalidArgument, "The page size is negative.", ErrInvalidInput},
+		{CodePageTokenInvalid, ReasonInvalidInput, codes.InvalidArgument, "The page token was not returned by a previous list call.", ErrInvalidInput},
+		{CodeCallerUnidentified, ReasonInvalidInput, codes.InvalidArgument, "The caller's credentials carry no user ID.", ErrInvalidInput},
+		{CodeReplayTopicRequired, ReasonInvalidInput, codes.InvalidArgument, "No target topic was given for the replay.", ErrInvalidInput},
+		{CodeTimeRangeInvalid, ReasonInvalidInput, codes.InvalidArgument, "The end of the time range is not after its start.", ErrInvalidInput},
+		{CodeEventTypeUnknown, ReasonInvalidInput, codes.InvalidArgument, "An event type is not one of the known types.", ErrInvalidInput},
+		{CodeWebhookNameInvalid, ReasonInvalidInput, codes.InvalidArgument, "The webhook name is empty or longer than 100 characters.", ErrInvalidInput},
+		{CodeWebhookKindUnknown, ReasonInvalidInput, codes.InvalidArgument, "The webhook kind is neither Slack nor Teams.", ErrInvalidInput},
+		{CodeWebhookURLInvalid, ReasonInvalidInput, codes.InvalidArgument, "The webhook URL is not an https URL.", ErrInvalidInput},
+		{CodeMinEmployeesNegative, ReasonInvalidInput, codes.InvalidArgument, "The minimum number of employees is negative.", ErrInvalidInput},
+		{CodeNotOwner, ReasonNotOwner, codes.PermissionDenied, "Only the creator of the company or an admin may change it.", ErrNotOwner},
+		{CodeReadOnly, ReasonReadOnly, codes.Unavailable, "Changes are suspended while the event broker is unreachable; retry later.", ErrReadOnly},
+		{CodeQuotaExceeded, ReasonQuotaExceeded, codes.ResourceExhausted, "The tenant reached one of its quotas; the limit and, for rates, when to retry are in the error details.", ErrQuotaExceeded},
+		{CodeOverloaded, ReasonOverloaded, codes.Unavailable, "The service is shedding load to stay responsive; retry after the delay in the error details.", ErrOverloaded},
+		{CodeLatencyBudgetExceeded, ReasonTimeout, codes.DeadlineExceeded, "The call did not finish within the time the service allows the method; it may have taken effect, so read before retrying changes.", ErrTimeout},
+		{CodeValidationRejected, ReasonValidationFailed, codes.FailedPrecondition, "An external validator, such as sanctions screening, rejected the change; its reason is in the message.", ErrValidationFailed},
+		{CodeValidationUnavailable, ReasonValidationFailed, codes.Unavailable, "An external validator the change must pass did not answer; retry later.", ErrValidationFailed},
+		{CodeAdminRequired, ReasonAdminRequired, codes.PermissionDenied, "The request asks for something only admins may do, such as suppressing events.", ErrAdminRequired},
+		{CodeTenantIDRequired, ReasonInvalidInput, codes.InvalidArgument, "The tenant ID is empty.", ErrInvalidInput},
+		{CodeQuotaLimitNegative, ReasonInvalidInput, codes.InvalidArgument, "A quota limit is negative.", ErrInvalidInput},
+		{CodeEmployeeNotFound, ReasonNotFound, codes.NotFound, "The company has no employee with the given ID.", ErrNotFound},
+		{CodeEmployeeIDInvalid, ReasonInvalidInput, codes.InvalidArgument, "The employee ID is missing or not a UUID.", ErrInvalidInput},
+		{CodeEmployeeNameRequired, ReasonInvalidInput, codes.InvalidArgument, "The employee name is empty.", ErrInvalidInput},
+		{CodeEmployeeNameTooLong, ReasonInvalidInput, codes.InvalidArgument, "The employee name is longer than 100 characters.", ErrInvalidInput},
+		{CodeEmployeeTitleTooLong, ReasonInvalidInput, codes.InvalidArgument, "The employee title is longer than 100 characters.", ErrInvalidInput},
+		{CodeEmployeeEmailInvalid, ReasonInvalidInput, codes.InvalidArgument, "The employee email is not a valid address.", ErrInvalidInput},
+		{CodeEmployeesDerived, ReasonInvalidInput, codes.InvalidArgument, "The number of employees is counted from the company's employee records and cannot be set.", ErrInvalidInput},
+		{CodeMetadataKeyInvalid, ReasonInvalidInput, codes.InvalidArgument, "A metadata key is not 1 to 63 letters, digits, '_', '-' or '.', starting with a letter or digit.", ErrInvalidInput},
+		{CodeMetadataValueTooLong, ReasonInvalidInput, codes.InvalidArgument, "A metadata value is longer than 256 characters.", ErrInvalidInput},
+		{CodeMetadataTooLarge, ReasonInvalidInput, codes.InvalidArgument, "The company would carry more than 50 metadata entries.", ErrInvalidInput},
+		{CodeNoteNotFound, ReasonNotFound, codes.NotFound, "The company has no note with the given ID.", ErrNotFound},
+		{CodeNoteBodyRequired, ReasonInvalidInput, codes.InvalidArgument, "The note body is empty.", ErrInvalidInput},
+		{CodeNoteBodyTooLong, ReasonInvalidInput, codes.InvalidArgument, "The note body is longer than 10000 characters.", ErrInvalidInput},
+		{CodeNoteNotAuthor, ReasonNotOwner, codes.PermissionDenied, "Only the author of the note or an admin may delete it.", ErrNotOwner},
+		{CodeResumeTokenInvalid, ReasonInvalidInput, codes.InvalidArgument, "The resume token was not returned by a previous watch.", ErrInvalidInput},
+		{CodeReportNotFound, ReasonNotFound, codes.NotFound, "No report exists with the given name.", ErrNotFound},
+		{CodeReportFormatUnsupported, ReasonInvalidInput, codes.InvalidArgument, "The report was requested in a format other than CSV or JSON.", ErrInvalidInput},
+		{CodeReportLimitInvalid, ReasonInvalidInput, codes.InvalidArgument, "The row limit of the report is negative.", ErrInvalidInput},
+		{CodeDuplicateSuggestionNotFound, ReasonNotFound, codes.NotFound, "No duplicate suggestion exists with the given ID.", ErrNotFound},
+		{CodeDuplicateSuggestionResolved, ReasonInvalidInput, codes.FailedPrecondition, "The duplicate suggestion was already merged or dismissed.", ErrInvalidInput},
+		{CodeDuplicateResolutionUnknown, ReasonInvalidInput, codes.InvalidArgument, "The resolution is neither MERGE nor DISMISS.", ErrInvalidInput},
+		{CodeDuplicateStatusUnknown, ReasonInvalidInput, codes.InvalidArgument, "The duplicate suggestion status is not one of the known statuses.", ErrInvalidInput},
+		{CodeMergeSameCompany, ReasonInvalidInput, codes.InvalidArgument, "A company cannot be merged into itself.", ErrInvalidInput},
+		{CodeMergeKeepInvalid, ReasonInvalidInput, codes.InvalidArgument, "The company to keep is not one of the suggested pair.", ErrInvalidInput},
+		{CodeMergeTenantMismatch, ReasonInvalidInput, codes.FailedPrecondition, "The companies to merge belong to different tenants.", ErrInvalidInput},
+		{CodeCompanyTypeNotFound, ReasonNotFound, codes.NotFound, "No company type exists with the given code.", ErrNotFound},
+		{CodeCompanyTypeExists, ReasonInvalidInput, codes.AlreadyExists, "A company type with this code already exists.", ErrInvalidInput},
+		{CodeCompanyTypeCodeInvalid, ReasonInvalidInput, codes.InvalidArgument, "The company type code is not 1 to 32 upper-case letters, digits or '_', starting with a letter.", ErrInvalidInput},
+		{CodeCompanyTypeNameInvalid, ReasonInvalidInput, codes.InvalidArgument, "The company type name is empty or longer than 100 characters.", ErrInvalidInput},
+		{CodeCompanyTypeBuiltin, ReasonInvalidInput, codes.FailedPrecondition, "The built-in company types, which the CompanyType enum maps to, cannot be deleted.", ErrInvalidInput},
+		{CodeCompanyTypeInUse, ReasonInvalidInput, codes.FailedPrecondition, "Live companies still have this type; change their type first.", ErrInvalidInput},
+		{CodeInvalidInput, ReasonInvalidInput, codes.InvalidArgument, "The request is invalid.", ErrInvalidInput},
+		{CodeInternal, ReasonInternal, codes.Internal, "An unexpected server error; report it with the request ID.", nil},
+	} {
+		registry[info.Code] = info
+	}
+}
+
+// sentinelCodes are the codes of errors that carry no Code of their own.
+var sentinelCodes = []struct {
+	err  error
+	code Code
+}{
+	{ErrNotFound, CodeNotFound},
+	{ErrDuplicateName, CodeNameTaken},
+	{ErrDuplicateExternalRef, CodeExternalRefTaken},
+	{ErrInvalidInput, CodeInvalidInput},
+	{ErrInvalidStatusTransition, CodeStatusTransitionNotAllowed},
+	{ErrNotOwner, CodeNotOwner},
+	{ErrReadOnly, CodeReadOnly},
+	{ErrQuotaExceeded, CodeQuotaExceeded},
+	{ErrOverloaded, CodeOverloaded},
+	{ErrTimeout, CodeLatencyBudgetExceeded},
+	{ErrValidationFailed, CodeValidationRejected},
+	{ErrAdminRequired, CodeAdminRequired},
+}
+
+// Lookup returns the description of code, and false for unknown codes.
+func Lookup(code Code) (CodeInfo, bool) {
+	info, ok := registry[code]
+	return info, ok
+}
+
+// Codes returns every Code, sorted.
+func Codes() []CodeInfo {
+	infos := make([]CodeInfo, 0, len(registry))
+	for _, info := range registry {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Code < infos[j].Code })
+	return infos
+}
+
+// CodeOf returns the Code of err: the one it carries, the one of the
+// sentinel it wraps, or INTERNAL.
+func CodeOf(err error) Code {
+	var coded interface{ ErrorCode() Code }
+	if errors.As(err, &coded) {
+		return coded.ErrorCode()
+	}
+	for _, s := range sentinelCodes {
+		if errors.Is(err, s.err) {
+			return s.code
+		}
+	}
+	return CodeInternal
+}
+
+// Error is an error carrying a Code. It matches the sentinel error its code
+// refines, e.g. a NAME_TOO_LONG error matches ErrInvalidInput, and any
+// other Error with the same code.
+type Error struct {
+	code Code
+	msg  string
+}
+
+// Newf returns an Error with the given code, whose message is that of the
+// code's sentinel followed by the formatted detail.
+func Newf(code Code, format string, args ...interface{}) error {
+	return &Error{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+func (err *Error) Error() string {
+	parent := registry[err.code].parent
+	switch {
+	case parent == nil:
+		return err.msg
+	case err.msg == "":
+		return parent.Error()
+	}
+	return parent.Error() + ": " + err.msg
+}
+
+func (err *Error) Is(target error) bool {
+	if t, ok := target.(*Error); ok {
+		return t.code == err.code
+	}
+	return target != nil && target == registry[err.code].parent
+}
+
+// ErrorCode returns the code of err.
+func (err *Error) ErrorCode() Code {
+	return err.code
+}
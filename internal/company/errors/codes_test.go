@@ -0,0 +1,85 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodeOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want Code
+	}{
+		{Newf(CodeNameTooLong, "name too long"), CodeNameTooLong},
+		{fmt.Errorf("failed: %w", Newf(CodeNameTooLong, "name too long")), CodeNameTooLong},
+		{ErrCompanyNotFound, CodeCompanyNotFound},
+		{ErrEmployeeNotFound, CodeEmployeeNotFound},
+		{ErrNoteNotFound, CodeNoteNotFound},
+		{ErrNotFound, CodeNotFound},
+		{fmt.Errorf("%w: bad", ErrInvalidInput), CodeInvalidInput},
+		{ErrNotOwner, CodeNotOwner},
+		{fmt.Errorf("create: %w", ErrReadOnly), CodeReadOnly},
+		{&SimilarNameError{}, CodeNameSimilar},
+		{fmt.Errorf("update: %w", &QuotaExceededError{}), CodeQuotaExceeded},
+		{ErrOverloaded, CodeOverloaded},
+		{errors.New("boom"), CodeInternal},
+		{nil, CodeInternal},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, CodeOf(tt.err), "%v", tt.err)
+	}
+}
+
+func TestError(t *testing.T) {
+	err := Newf(CodeNameTooLong, "name longer than %d characters", 15)
+	assert.Equal(t, "invalid input: name longer than 15 characters", err.Error())
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.ErrorIs(t, err, Newf(CodeNameTooLong, "other detail"))
+	assert.NotErrorIs(t, err, Newf(CodeNameRequired, ""))
+	assert.NotErrorIs(t, err, ErrNotFound)
+
+	assert.Equal(t, "not found", ErrCompanyNotFound.Error())
+	assert.ErrorIs(t, ErrCompanyNotFound, ErrNotFound)
+
+	quota := &QuotaExceededError{TenantID: "acme", Quota: QuotaMutationsPerMinute, Limit: 60}
+	assert.Equal(t, `quota exceeded: tenant "acme" is limited to 60 mutations per minute`, quota.Error())
+	assert.ErrorIs(t, quota, ErrQuotaExceeded)
+}
+
+func TestValidationError(t *testing.T) {
+	var invalid ValidationError
+	assert.NoError(t, invalid.Err())
+
+	invalid.Add("name", CodeNameTooLong, "name longer than 15 characters")
+	invalid.Add("employees", CodeEmployeesNegative, "employees must not be negative")
+	err := fmt.Errorf("create: %w", invalid.Err())
+	assert.Equal(t, "create: invalid input: name longer than 15 characters; employees must not be negative", err.Error())
+	assert.Equal(t, CodeNameTooLong, CodeOf(err))
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.ErrorIs(t, err, Newf(CodeEmployeesNegative, ""))
+	assert.NotErrorIs(t, err, Newf(CodeNameRequired, ""))
+	assert.NotErrorIs(t, err, ErrNotFound)
+}
+
+func TestCodes(t *testing.T) {
+	infos := Codes()
+	for i, info := range infos {
+		assert.NotEmpty(t, info.Reason, info.Code)
+		assert.NotEmpty(t, info.Description, info.Code)
+		if i > 0 {
+			assert.Less(t, infos[i-1].Code, info.Code)
+		}
+		found, ok := Lookup(info.Code)
+		assert.True(t, ok)
+		assert.Equal(t, info, found)
+	}
+	for _, s := range sentinelCodes {
+		_, ok := Lookup(s.code)
+		assert.True(t, ok, s.code)
+	}
+	_, ok := Lookup("NO_SUCH_CODE")
+	assert.False(t, ok)
+}
@@ -2,10 +2,107 @@ package errors
 
 import (
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/models"
 )
 
 var (
-	ErrNotFound      = fmt.Errorf("not found")
-	ErrDuplicateName = fmt.Errorf("duplicate name")
-	ErrInvalidInput  = fmt.Errorf("invalid input")
+	ErrNotFound             = fmt.Errorf("not found")
+	ErrDuplicateName        = fmt.Errorf("duplicate name")
+	ErrInvalidInput         = fmt.Errorf("invalid input")
+	ErrDuplicateExternalRef = fmt.Errorf("duplicate external reference")
+	// ErrInvalidStatusTransition is returned when a company cannot move from
+	// its current status to the requested one.
+	ErrInvalidStatusTransition = fmt.Errorf("invalid status transition")
+	// ErrNotOwner is returned when ownership checks are enabled and a caller
+	// other than an admin modifies a company it did not create.
+	ErrNotOwner = fmt.Errorf("not the owner of the company")
+	// ErrReadOnly is returned by mutations while the service runs without
+	// its event broker and so cannot publish their events.
+	ErrReadOnly = fmt.Errorf("service is read-only")
+	// ErrQuotaExceeded is returned when a tenant reaches one of its quotas.
+	ErrQuotaExceeded = fmt.Errorf("quota exceeded")
+	// ErrOverloaded is returned when a call is shed because the service is
+	// under more load than it can serve.
+	ErrOverloaded = fmt.Errorf("service overloaded")
+	// ErrTimeout is returned when a call runs out of the time the service
+	// allows its method.
+	ErrTimeout = fmt.Errorf("latency budget exceeded")
+	// ErrAdminRequired is returned when a caller without the admin role asks
+	// for something only admins may do.
+	ErrAdminRequired = fmt.Errorf("admin role required")
+	// ErrValidationFailed is returned when an external validator rejects a
+	// company change or cannot be asked about it.
+	ErrValidationFailed = fmt.Errorf("external validation failed")
+	// ErrCompanyNotFound is returned when no company matches a lookup. It
+	// matches ErrNotFound.
+	ErrCompanyNotFound error = &Error{code: CodeCompanyNotFound}
+	// ErrEmployeeNotFound is returned when a company has no employee with
+	// the requested ID. It matches ErrNotFound.
+	ErrEmployeeNotFound error = &Error{code: CodeEmployeeNotFound}
+	// ErrNoteNotFound is returned when a company has no note with the
+	// requested ID. It matches ErrNotFound.
+	ErrNoteNotFound error = &Error{code: CodeNoteNotFound}
+	// ErrDuplicateSuggestionNotFound is returned when no duplicate
+	// suggestion has the requested ID. It matches ErrNotFound.
+	ErrDuplicateSuggestionNotFound error = &Error{code: CodeDuplicateSuggestionNotFound}
+	// ErrCompanyTypeNotFound is returned when the taxonomy has no company
+	// type with the requested code. It matches ErrNotFound.
+	ErrCompanyTypeNotFound error = &Error{code: CodeCompanyTypeNotFound}
 )
+
+// SimilarNameError reports existing companies whose names are close to the
+// name of a company being created. It matches ErrDuplicateName.
+type SimilarNameError struct {
+	Candidates []models.Company
+}
+
+func (err *SimilarNameError) Error() string {
+	names := make([]string, len(err.Candidates))
+	for i, c := range err.Candidates {
+		names[i] = fmt.Sprintf("%q", c.Name)
+	}
+	return fmt.Sprintf("%v: similar to %s", ErrDuplicateName, strings.Join(names, ", "))
+}
+
+func (err *SimilarNameError) Is(target error) bool {
+	return target == ErrDuplicateName
+}
+
+// ErrorCode returns NAME_SIMILAR.
+func (err *SimilarNameError) ErrorCode() Code {
+	return CodeNameSimilar
+}
+
+// Quotas a QuotaExceededError may name.
+const (
+	QuotaCompanies          = "companies"
+	QuotaMutationsPerMinute = "mutations_per_minute"
+)
+
+// QuotaExceededError reports a tenant reaching one of its quotas. It matches
+// ErrQuotaExceeded.
+type QuotaExceededError struct {
+	TenantID string
+	// Quota is QuotaCompanies or QuotaMutationsPerMinute.
+	Quota string
+	Limit int
+	// RetryAfter is how long until a rate quota resets; 0 for quotas that
+	// only free up when companies are deleted.
+	RetryAfter time.Duration
+}
+
+func (err *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%v: tenant %q is limited to %d %s", ErrQuotaExceeded, err.TenantID, err.Limit, strings.ReplaceAll(err.Quota, "_", " "))
+}
+
+func (err *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// ErrorCode returns QUOTA_EXCEEDED.
+func (err *QuotaExceededError) ErrorCode() Code {
+	return CodeQuotaExceeded
+}
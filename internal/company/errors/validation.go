@@ -0,0 +1,68 @@
+package errors
+
+import "strings"
+
+// FieldViolation describes one invalid field of a request.
+type FieldViolation struct {
+	// Field is the snake_case name of the field, as in the API, e.g.
+	// "contact_email" or "companies[2].external_ref".
+	Field string
+	// Rule is the code of the rule the field breaks, e.g. NAME_TOO_LONG.
+	Rule    Code
+	Message string
+}
+
+// ValidationError reports every invalid field of a request at once, so
+// clients can point at each of them. It matches ErrInvalidInput and any
+// Error carrying the rule of one of its violations; its code is the rule of
+// the first violation.
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+// Add records that field breaks rule.
+func (err *ValidationError) Add(field string, rule Code, message string) {
+	err.Violations = append(err.Violations, FieldViolation{Field: field, Rule: rule, Message: message})
+}
+
+// Err returns err, or nil when no violation was added.
+func (err *ValidationError) Err() error {
+	if len(err.Violations) == 0 {
+		return nil
+	}
+	return err
+}
+
+func (err *ValidationError) Error() string {
+	messages := make([]string, len(err.Violations))
+	for i, v := range err.Violations {
+		messages[i] = v.Message
+	}
+	return ErrInvalidInput.Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (err *ValidationError) Is(target error) bool {
+	if t, ok := target.(*Error); ok {
+		for _, v := range err.Violations {
+			if v.Rule == t.code {
+				return true
+			}
+		}
+		return false
+	}
+	return target == ErrInvalidInput
+}
+
+// ErrorCode returns the rule of the first violation, or INVALID_INPUT when
+// there is none.
+func (err *ValidationError) ErrorCode() Code {
+	if len(err.Violations) == 0 {
+		return CodeInvalidInput
+	}
+	return err.Violations[0].Rule
+}
+
+// Invalid returns a ValidationError with a single violation.
+func Invalid(field string, rule Code, message string) error {
+	return &ValidationError{Violations: []FieldViolation{{Field: field, Rule: rule, Message: message}}}
+}
@@ -0,0 +1,80 @@
+package events
+
+import (
+	"time"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+)
+
+// AuditEntry is the record of a change published to the audit topic. Its
+// schema is independent of Event and of the configured Codec, so security
+// tooling can ingest it without following changes to the events.
+type AuditEntry struct {
+	// EventID is the ID of the event recording the same change.
+	EventID uuid.UUID `json:"event_id"`
+	// Time is when the entry was published.
+	Time time.Time `json:"time"`
+	// Actor is the user ID of the caller that made the change.
+	Actor string `json:"actor"`
+	// Action is the type of the event, e.g. "company_updated".
+	Action      EventType `json:"action"`
+	CompanyID   uuid.UUID `json:"company_id"`
+	CompanyName string    `json:"company_name"`
+	// Changes holds the old and new value of every modified field, for the
+	// actions whose events carry them.
+	Changes map[string]models.FieldChange `json:"changes,omitempty"`
+	// Validations holds the verdicts of the external validators that
+	// approved the change.
+	Validations []models.ValidationResult `json:"validations,omitempty"`
+	// EventsSuppressed reports that the change was made with events
+	// suppressed, so this entry is its only publication.
+	EventsSuppressed bool `json:"events_suppressed,omitempty"`
+}
+
+// auditSettings configures the audit topic.
+type auditSettings struct {
+	topic string
+	// retention overrides the retention of the event topics when the audit
+	// topic is created.
+	retention time.Duration
+}
+
+// WithAuditTopic publishes an AuditEntry for every produced event to topic,
+// in the same batch as the event. When the topic is created, it gets
+// retention instead of the TopicSettings one; 0 keeps the broker default.
+func WithAuditTopic(topic string, retention time.Duration) ProducerOption {
+	return func(p *Producer) {
+		p.audit = auditSettings{topic: topic, retention: retention}
+	}
+}
+
+// newAuditMessage returns the JSON AuditEntry of event as a Kafka message
+// for topic, keyed by company ID like the event.
+func newAuditMessage(topic string, event Event) (kafka.Message, error) {
+	value, err := jsonMarshal(AuditEntry{
+		EventID:          event.EventID,
+		Time:             time.Now().UTC(),
+		Actor:            event.Actor,
+		Action:           event.Type,
+		CompanyID:        event.Company.ID,
+		CompanyName:      event.Company.Name,
+		Changes:          event.Changes,
+		Validations:      event.Validations,
+		EventsSuppressed: event.Suppressed,
+	})
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	return kafka.Message{
+		Topic: topic,
+		Key:   []byte(event.Company.ID.String()),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: EventTypeHeader, Value: []byte(event.Type)},
+			{Key: ContentTypeHeader, Value: []byte(ContentTypeJSON)},
+			{Key: ProducerHeader, Value: producerTag},
+		},
+	}, nil
+}
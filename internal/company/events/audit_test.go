@@ -0,0 +1,92 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestProducer_AuditTopic(t *testing.T) {
+	mockWriter := new(MockKafkaWriter)
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+	producer := &Producer{writer: mockWriter, topic: "company_events", logger: zaptest.NewLogger(t)}
+	WithAuditTopic("company_audit", 0)(producer)
+	WithCodec(ProtoCodec{})(producer)
+
+	company := &models.Company{ID: uuid.New(), Name: "Acme Corp"}
+	event := Event{
+		EventID: uuid.New(),
+		Type:    CompanyUpdated,
+		Company: company,
+		Actor:   "alice",
+		Changes: map[string]models.FieldChange{"name": {Old: "Acme", New: "Acme Corp"}},
+	}
+	producer.sendEvent(context.Background(), event)
+
+	// The event and its audit entry are written in one batch.
+	msgs := mockWriter.Calls[0].Arguments.Get(1).([]kafka.Message)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "company_events", msgs[0].Topic)
+	audit := msgs[1]
+	assert.Equal(t, "company_audit", audit.Topic)
+	assert.Equal(t, company.ID.String(), string(audit.Key))
+	assert.Equal(t, ContentTypeJSON, headerValue(audit, ContentTypeHeader), "audit entries are JSON whatever the codec")
+	assert.Equal(t, "company-service/dev", headerValue(audit, ProducerHeader))
+
+	var entry AuditEntry
+	require.NoError(t, json.Unmarshal(audit.Value, &entry))
+	assert.Equal(t, event.EventID, entry.EventID)
+	assert.Equal(t, "alice", entry.Actor)
+	assert.Equal(t, CompanyUpdated, entry.Action)
+	assert.Equal(t, company.ID, entry.CompanyID)
+	assert.Equal(t, "Acme Corp", entry.CompanyName)
+	assert.Equal(t, map[string]models.FieldChange{"name": {Old: "Acme", New: "Acme Corp"}}, entry.Changes)
+	assert.False(t, entry.Time.IsZero())
+
+	// Replays are not audited again.
+	require.NoError(t, producer.Replay(context.Background(), "company_events_rebuild", event))
+	assert.Len(t, mockWriter.Calls[1].Arguments.Get(1).([]kafka.Message), 1)
+}
+
+func TestProducer_AuditTopicDisabled(t *testing.T) {
+	mockWriter := new(MockKafkaWriter)
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(errors.New("kafka error"))
+	producer := &Producer{writer: mockWriter, topic: "company_events", logger: zaptest.NewLogger(t)}
+
+	msgs, err := producer.messages(Event{Type: CompanyCreated, Company: &models.Company{ID: uuid.New()}})
+	require.NoError(t, err)
+	assert.Len(t, msgs, 1)
+
+	err = producer.write(context.Background(), msgs...)
+	assert.ErrorContains(t, err, "failed to write event: kafka error")
+	assert.Equal(t, int64(1), producer.Health().Failed)
+}
+
+func TestProducer_AuditSuppressed(t *testing.T) {
+	mockWriter := new(MockKafkaWriter)
+	producer := &Producer{writer: mockWriter, topic: "company_events", logger: zaptest.NewLogger(t)}
+	event := Event{EventID: uuid.New(), Type: CompanyCreated, Company: &models.Company{ID: uuid.New()}, Suppressed: true}
+
+	// Without an audit topic, a suppressed event is not written at all.
+	require.NoError(t, producer.ProduceContext(context.Background(), event))
+	mockWriter.AssertNotCalled(t, "WriteMessages", mock.Anything, mock.Anything)
+
+	// With one, only its audit entry is.
+	WithAuditTopic("company_audit", 0)(producer)
+	msgs, err := producer.messages(event)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "company_audit", msgs[0].Topic)
+	var entry AuditEntry
+	require.NoError(t, json.Unmarshal(msgs[0].Value, &entry))
+	assert.True(t, entry.EventsSuppressed)
+}
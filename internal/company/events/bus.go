@@ -0,0 +1,220 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Bus dispatches events to subscribers in the same process instead of
+// Kafka, so a small deployment can run the company service without a
+// broker. It offers the same calls as Producer.
+//
+// Every subscriber has its own queue and goroutine: it sees events in the
+// order they were produced, and a slow subscriber does not hold up the
+// others. Events are not persisted; those still queued when the process
+// dies are lost.
+type Bus struct {
+	logger    *zap.Logger
+	queueSize int
+
+	// mu guards subscribers and flushed; Produce holds it for reading so
+	// Flush never closes a queue underneath a pending send.
+	mu          sync.RWMutex
+	subscribers []*subscriber
+	flushed     bool
+	workers     sync.WaitGroup
+
+	health health
+}
+
+// subscriber is a handler registered with Bus.Subscribe.
+type subscriber struct {
+	name    string
+	types   []EventType
+	handler func(context.Context, Event) error
+	queue   chan Event
+	// mu serializes the calls of handler, so replayed events are not
+	// handled concurrently with queued ones.
+	mu sync.Mutex
+}
+
+// NewBus returns a Bus without subscribers, buffering up to
+// defaultQueueSize events per subscriber.
+func NewBus(logger *zap.Logger) *Bus {
+	return &Bus{logger: logger.Named("event_bus"), queueSize: defaultQueueSize}
+}
+
+// Subscribe calls handler with every event of the given types, or of every
+// type when none are given. name identifies the subscriber in logs. Errors
+// returned by handler are logged; the event is not redelivered.
+func (b *Bus) Subscribe(name string, handler func(context.Context, Event) error, types ...EventType) {
+	s := &subscriber{name: name, types: types, handler: handler, queue: make(chan Event, b.queueSize)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, s)
+	if b.flushed {
+		// Events are delivered synchronously from now on.
+		close(s.queue)
+		return
+	}
+	b.workers.Add(1)
+	go func() {
+		defer b.workers.Done()
+		for event := range s.queue {
+			_ = b.deliver(context.Background(), s, event)
+		}
+	}()
+}
+
+// wants reports whether s subscribed to events of type t.
+func (s *subscriber) wants(t EventType) bool {
+	return len(s.types) == 0 || slices.Contains(s.types, t)
+}
+
+// deliver calls the handler of s with event, logging its failure.
+func (b *Bus) deliver(ctx context.Context, s *subscriber, event Event) error {
+	s.mu.Lock()
+	err := s.handler(ctx, event)
+	s.mu.Unlock()
+	b.health.record(err)
+	if err != nil {
+		b.logger.Error("Event subscriber failed",
+			zap.Error(err),
+			zap.String("subscriber", s.name),
+			zap.String("event_type", string(event.Type)),
+			zap.String("event_id", event.EventID.String()),
+		)
+	}
+	return err
+}
+
+// Produce queues an event for every subscriber to it, assigning an EventID
+// when it has none. When a subscriber's queue is full, Produce waits for
+// space rather than dropping the event; once the Bus has been flushed, the
+// subscribers are called synchronously instead. Suppressed events are
+// dropped, as there is no audit topic in process.
+func (b *Bus) Produce(event Event) {
+	if event.Suppressed {
+		return
+	}
+	if event.EventID == uuid.Nil {
+		event.EventID = uuid.New()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, s := range b.subscribers {
+		if !s.wants(event.Type) {
+			continue
+		}
+		if b.flushed {
+			_ = b.deliver(context.Background(), s, event)
+			continue
+		}
+		select {
+		case s.queue <- event:
+		default:
+			b.logger.Warn("Event subscriber queue full, waiting for space",
+				zap.String("subscriber", s.name),
+				zap.String("event_type", string(event.Type)),
+			)
+			s.queue <- event
+		}
+	}
+}
+
+// Replay synchronously hands a previously published event, unchanged, to
+// every subscriber to it and returns their errors. There are no topics in
+// process, so topic is ignored.
+func (b *Bus) Replay(ctx context.Context, _ string, event Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var errs []error
+	for _, s := range b.subscribers {
+		if !s.wants(event.Type) {
+			continue
+		}
+		if err := b.deliver(ctx, s, event); err != nil {
+			errs = append(errs, fmt.Errorf("subscriber %s: %w", s.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Publish synchronously hands event to every subscriber to it, like
+// Replay, and returns their errors. Suppressed events are dropped, as by
+// Produce.
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	if event.Suppressed {
+		return nil
+	}
+	return b.Replay(ctx, "", event)
+}
+
+// Ping always succeeds: the Bus has no broker to reach.
+func (b *Bus) Ping(context.Context) error {
+	return nil
+}
+
+// Flush stops queueing new events and blocks until the subscribers have
+// handled everything already queued, or until ctx is done.
+func (b *Bus) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.flushed {
+		b.flushed = true
+		for _, s := range b.subscribers {
+			close(s.queue)
+		}
+	}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close flushes the Bus, waiting for the subscribers to handle the queued
+// events.
+func (b *Bus) Close() {
+	_ = b.Flush(context.Background())
+}
+
+// Health returns the queued events and the outcome of the deliveries;
+// Written counts the events subscribers handled and Failed those they
+// failed to.
+func (b *Bus) Health() ProducerHealth {
+	b.mu.RLock()
+	var status ProducerHealth
+	for _, s := range b.subscribers {
+		status.QueueLength += len(s.queue)
+		status.QueueCapacity += cap(s.queue)
+	}
+	b.mu.RUnlock()
+	b.health.fill(&status)
+	return status
+}
+
+// String implements expvar.Var, publishing Health as JSON.
+func (b *Bus) String() string {
+	out, err := json.Marshal(b.Health())
+	if err != nil {
+		return "{}"
+	}
+	return string(out)
+}
@@ -0,0 +1,128 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// recorder is a Bus subscriber remembering the events it handled.
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+	err    error
+}
+
+func (r *recorder) handle(_ context.Context, event Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return r.err
+}
+
+func (r *recorder) types() []EventType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var types []EventType
+	for _, event := range r.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestBus_Produce(t *testing.T) {
+	bus := NewBus(zaptest.NewLogger(t))
+	all, deletes := &recorder{}, &recorder{}
+	bus.Subscribe("all", all.handle)
+	bus.Subscribe("deletes", deletes.handle, CompanyDeleted)
+
+	company := &models.Company{ID: uuid.New()}
+	for _, eventType := range []EventType{CompanyCreated, CompanyUpdated, CompanyDeleted} {
+		bus.Produce(Event{Type: eventType, Company: company})
+	}
+	require.NoError(t, bus.Flush(context.Background()))
+
+	assert.Equal(t, []EventType{CompanyCreated, CompanyUpdated, CompanyDeleted}, all.types())
+	assert.Equal(t, []EventType{CompanyDeleted}, deletes.types())
+	assert.NotEqual(t, uuid.Nil, all.events[0].EventID)
+	assert.Equal(t, all.events[2].EventID, deletes.events[0].EventID)
+	assert.Equal(t, int64(4), bus.Health().Written)
+
+	// After the flush, events are handled before Produce returns, also by
+	// late subscribers.
+	late := &recorder{}
+	bus.Subscribe("late", late.handle)
+	bus.Produce(Event{Type: CompanyCreated, Company: company})
+	assert.Len(t, all.events, 4)
+	assert.Len(t, late.events, 1)
+}
+
+func TestBus_SlowSubscriber(t *testing.T) {
+	bus := NewBus(zaptest.NewLogger(t))
+	bus.queueSize = 1
+	release := make(chan struct{})
+	slow, fast := &recorder{}, &recorder{}
+	bus.Subscribe("slow", func(ctx context.Context, event Event) error {
+		<-release
+		return slow.handle(ctx, event)
+	})
+	bus.Subscribe("fast", fast.handle)
+
+	company := &models.Company{ID: uuid.New()}
+	bus.Produce(Event{Type: CompanyCreated, Company: company})
+	bus.Produce(Event{Type: CompanyUpdated, Company: company})
+	assert.Eventually(t, func() bool { return len(fast.types()) == 2 }, time.Second, time.Millisecond)
+
+	// The slow subscriber's queue is full: Produce waits for space instead
+	// of dropping the event.
+	produced := make(chan struct{})
+	go func() {
+		bus.Produce(Event{Type: CompanyDeleted, Company: company})
+		close(produced)
+	}()
+	select {
+	case <-produced:
+		t.Fatal("expected Produce to wait for the slow subscriber")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-produced
+	require.NoError(t, bus.Flush(context.Background()))
+	assert.Equal(t, []EventType{CompanyCreated, CompanyUpdated, CompanyDeleted}, slow.types())
+}
+
+func TestBus_Replay(t *testing.T) {
+	bus := NewBus(zaptest.NewLogger(t))
+	ok, failing := &recorder{}, &recorder{err: errors.New("mailer down")}
+	bus.Subscribe("ok", ok.handle)
+	bus.Subscribe("failing", failing.handle, CompanyUpdated)
+
+	company := &models.Company{ID: uuid.New()}
+	require.NoError(t, bus.Replay(context.Background(), "company_events", Event{Type: CompanyCreated, Company: company}))
+	assert.Len(t, ok.events, 1)
+
+	err := bus.Replay(context.Background(), "company_events", Event{Type: CompanyUpdated, Company: company})
+	assert.EqualError(t, err, "subscriber failing: mailer down")
+	assert.Len(t, ok.events, 2)
+
+	err = bus.Publish(context.Background(), Event{Type: CompanyUpdated, Company: company})
+	assert.EqualError(t, err, "subscriber failing: mailer down", "published events should be delivered synchronously")
+	require.NoError(t, bus.Publish(context.Background(), Event{Type: CompanyUpdated, Company: company, Suppressed: true}))
+	assert.Len(t, ok.events, 3, "suppressed events should not be delivered")
+
+	// Failures of queued events are only logged and counted.
+	bus.Produce(Event{Type: CompanyUpdated, Company: company})
+	require.NoError(t, bus.Flush(context.Background()))
+	health := bus.Health()
+	assert.Equal(t, int64(3), health.Failed)
+	assert.Equal(t, "mailer down", health.LastError)
+	assert.NoError(t, bus.Ping(context.Background()))
+}
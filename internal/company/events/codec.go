@@ -0,0 +1,220 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	eventsv1 "github.com/gartstein/xm/api/gen/definition/events/v1"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ContentTypeHeader is the Kafka message header naming the encoding of the
+// message value. Messages without it are JSON.
+const ContentTypeHeader = "content_type"
+
+// Content types of the built-in codecs.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// Codec encodes events as Kafka message values and decodes them back.
+type Codec interface {
+	// ContentType is written to the ContentTypeHeader of every message, so
+	// consumers pick the matching codec.
+	ContentType() string
+	Marshal(event Event) ([]byte, error)
+	Unmarshal(data []byte, event *Event) error
+}
+
+// JSONCodec encodes events as the JSON form of Event. It is the default.
+type JSONCodec struct{}
+
+// ContentType implements Codec.
+func (JSONCodec) ContentType() string { return ContentTypeJSON }
+
+// Marshal implements Codec.
+func (JSONCodec) Marshal(event Event) ([]byte, error) { return jsonMarshal(event) }
+
+// Unmarshal implements Codec.
+func (JSONCodec) Unmarshal(data []byte, event *Event) error { return json.Unmarshal(data, event) }
+
+// ProtoCodec encodes events as definition.events.v1.CompanyEvent messages,
+// for which consumers in any language can generate types.
+type ProtoCodec struct{}
+
+// ContentType implements Codec.
+func (ProtoCodec) ContentType() string { return ContentTypeProtobuf }
+
+// Marshal implements Codec.
+func (ProtoCodec) Marshal(event Event) ([]byte, error) {
+	msg, err := eventToProto(event)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+// Unmarshal implements Codec.
+func (ProtoCodec) Unmarshal(data []byte, event *Event) error {
+	var msg eventsv1.CompanyEvent
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	decoded, err := eventFromProto(&msg)
+	if err != nil {
+		return err
+	}
+	*event = decoded
+	return nil
+}
+
+// ParseCodec converts a configuration value ("json" or "protobuf") into a
+// Codec. An empty value selects JSONCodec.
+func ParseCodec(value string) (Codec, error) {
+	switch value {
+	case "", "json":
+		return JSONCodec{}, nil
+	case "protobuf":
+		return ProtoCodec{}, nil
+	default:
+		return nil, fmt.Errorf("unknown event encoding %q", value)
+	}
+}
+
+// codecFor returns the codec decoding messages of the given content type.
+func codecFor(contentType string) (Codec, error) {
+	switch contentType {
+	case "", ContentTypeJSON:
+		return JSONCodec{}, nil
+	case ContentTypeProtobuf:
+		return ProtoCodec{}, nil
+	default:
+		return nil, fmt.Errorf("unknown content type %q", contentType)
+	}
+}
+
+func eventToProto(event Event) (*eventsv1.CompanyEvent, error) {
+	msg := &eventsv1.CompanyEvent{
+		EventId: event.EventID.String(),
+		Type:    string(event.Type),
+		Actor:   event.Actor,
+	}
+	if c := event.Company; c != nil {
+		msg.Company = &eventsv1.Company{
+			Id:            c.ID.String(),
+			Name:          c.Name,
+			Description:   c.Description,
+			Employees:     int32(c.Employees),
+			EmployeeRange: string(c.EmployeeRange),
+			Registered:    c.Registered,
+			Status:        string(c.Status),
+			Type:          string(c.Type),
+			ExternalRef:   c.ExternalRef,
+			CreatedBy:     c.CreatedBy,
+			UpdatedBy:     c.UpdatedBy,
+			CreatedAt:     timestamppb.New(c.CreatedAt),
+			UpdatedAt:     timestamppb.New(c.UpdatedAt),
+			Metadata:      c.Metadata,
+		}
+		if c.ErasedAt != nil {
+			msg.Company.ErasedAt = timestamppb.New(*c.ErasedAt)
+		}
+	}
+	if len(event.Changes) > 0 {
+		msg.Changes = make(map[string]*eventsv1.FieldChange, len(event.Changes))
+		for field, change := range event.Changes {
+			old, err := toValue(change.Old)
+			if err != nil {
+				return nil, fmt.Errorf("change of %s: %w", field, err)
+			}
+			updated, err := toValue(change.New)
+			if err != nil {
+				return nil, fmt.Errorf("change of %s: %w", field, err)
+			}
+			msg.Changes[field] = &eventsv1.FieldChange{Old: old, New: updated}
+		}
+	}
+	return msg, nil
+}
+
+func eventFromProto(msg *eventsv1.CompanyEvent) (Event, error) {
+	event := Event{Type: EventType(msg.GetType()), Actor: msg.GetActor()}
+	if msg.GetEventId() != "" {
+		id, err := uuid.Parse(msg.GetEventId())
+		if err != nil {
+			return Event{}, fmt.Errorf("invalid event ID: %w", err)
+		}
+		event.EventID = id
+	}
+	if c := msg.GetCompany(); c != nil {
+		company := &models.Company{
+			Name:          c.GetName(),
+			Description:   c.GetDescription(),
+			Employees:     int(c.GetEmployees()),
+			EmployeeRange: models.EmployeeRange(c.GetEmployeeRange()),
+			Registered:    c.GetRegistered(),
+			Status:        models.CompanyStatus(c.GetStatus()),
+			Type:          models.CompanyType(c.GetType()),
+			ExternalRef:   c.GetExternalRef(),
+			CreatedBy:     c.GetCreatedBy(),
+			UpdatedBy:     c.GetUpdatedBy(),
+			CreatedAt:     fromTimestamp(c.GetCreatedAt()),
+			UpdatedAt:     fromTimestamp(c.GetUpdatedAt()),
+		}
+		if len(c.GetMetadata()) > 0 {
+			company.Metadata = c.GetMetadata()
+		}
+		if c.GetErasedAt() != nil {
+			erasedAt := c.GetErasedAt().AsTime()
+			company.ErasedAt = &erasedAt
+		}
+		if c.GetId() != "" {
+			id, err := uuid.Parse(c.GetId())
+			if err != nil {
+				return Event{}, fmt.Errorf("invalid company ID: %w", err)
+			}
+			company.ID = id
+		}
+		event.Company = company
+	}
+	if len(msg.GetChanges()) > 0 {
+		event.Changes = make(map[string]models.FieldChange, len(msg.GetChanges()))
+		for field, change := range msg.GetChanges() {
+			event.Changes[field] = models.FieldChange{
+				Old: change.GetOld().AsInterface(),
+				New: change.GetNew().AsInterface(),
+			}
+		}
+	}
+	return event, nil
+}
+
+// fromTimestamp returns the time of ts, or the zero time when ts is unset.
+func fromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+// toValue converts a field value into a structpb.Value through its JSON
+// form, so decoded values match what JSON consumers see: named string types
+// become strings and numbers float64.
+func toValue(v interface{}) (*structpb.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	value := &structpb.Value{}
+	if err := protojson.Unmarshal(data, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,118 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func testEvent() Event {
+	now := time.Date(2026, 10, 16, 12, 30, 0, 0, time.UTC)
+	return Event{
+		EventID: uuid.New(),
+		Type:    CompanyStatusChanged,
+		Company: &models.Company{
+			ID:            uuid.New(),
+			Name:          "Acme",
+			Description:   "Anvils",
+			Employees:     12,
+			EmployeeRange: models.Employees11To50,
+			Registered:    true,
+			Status:        models.StatusSuspended,
+			Type:          models.Corporations,
+			ExternalRef:   "ERP-1",
+			Metadata:      models.Metadata{"crm": "salesforce"},
+			CreatedBy:     "alice",
+			UpdatedBy:     "bob",
+			CreatedAt:     now.Add(-time.Hour),
+			UpdatedAt:     now,
+		},
+		Actor: "bob",
+		Changes: map[string]models.FieldChange{
+			"status":    {Old: models.StatusActive, New: models.StatusSuspended},
+			"employees": {Old: 10, New: 12},
+		},
+	}
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec{}, ProtoCodec{}} {
+		t.Run(codec.ContentType(), func(t *testing.T) {
+			event := testEvent()
+			data, err := codec.Marshal(event)
+			require.NoError(t, err)
+
+			var decoded Event
+			require.NoError(t, codec.Unmarshal(data, &decoded))
+			assert.Equal(t, event.EventID, decoded.EventID)
+			assert.Equal(t, event.Type, decoded.Type)
+			assert.Equal(t, event.Actor, decoded.Actor)
+			assert.Equal(t, event.Company.ID, decoded.Company.ID)
+			assert.Equal(t, event.Company.Status, decoded.Company.Status)
+			assert.Equal(t, event.Company.EmployeeRange, decoded.Company.EmployeeRange)
+			assert.Equal(t, event.Company.Metadata, decoded.Company.Metadata)
+			assert.True(t, event.Company.UpdatedAt.Equal(decoded.Company.UpdatedAt))
+			// Both codecs decode changes the way JSON does.
+			assert.Equal(t, map[string]models.FieldChange{
+				"status":    {Old: "ACTIVE", New: "SUSPENDED"},
+				"employees": {Old: float64(10), New: float64(12)},
+			}, decoded.Changes)
+		})
+	}
+}
+
+func TestProtoCodec_InvalidPayload(t *testing.T) {
+	var event Event
+	assert.Error(t, ProtoCodec{}.Unmarshal([]byte{0xff, 0xff}, &event))
+
+	data, err := ProtoCodec{}.Marshal(Event{Type: CompanyCreated, Company: &models.Company{ID: uuid.New()}})
+	require.NoError(t, err)
+	require.NoError(t, ProtoCodec{}.Unmarshal(data, &event))
+	assert.Empty(t, event.Changes)
+	assert.True(t, event.Company.CreatedAt.IsZero())
+}
+
+func TestParseCodec(t *testing.T) {
+	for value, want := range map[string]Codec{"": JSONCodec{}, "json": JSONCodec{}, "protobuf": ProtoCodec{}} {
+		got, err := ParseCodec(value)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ParseCodec("avro")
+	assert.Error(t, err)
+}
+
+func TestProducerConsumer_ProtoCodec(t *testing.T) {
+	mockWriter := new(MockKafkaWriter)
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+	producer := &Producer{writer: mockWriter, topic: "company_events", logger: zaptest.NewLogger(t)}
+	WithCodec(ProtoCodec{})(producer)
+
+	event := testEvent()
+	producer.sendEvent(context.Background(), event)
+	msg := mockWriter.Calls[0].Arguments.Get(1).([]kafka.Message)[0]
+	assert.Equal(t, ContentTypeProtobuf, headerValue(msg, ContentTypeHeader))
+
+	// Consumers pick the codec from the header.
+	var handled Event
+	c := &Consumer{logger: zaptest.NewLogger(t)}
+	c.RegisterHandler(func(_ context.Context, e Event) error {
+		handled = e
+		return nil
+	})
+	_, ok := c.process(context.Background(), msg)
+	assert.True(t, ok)
+	assert.Equal(t, event.EventID, handled.EventID)
+
+	msg.Headers = []kafka.Header{{Key: ContentTypeHeader, Value: []byte("application/avro")}}
+	_, ok = c.process(context.Background(), msg)
+	assert.False(t, ok, "messages of unknown content types cannot be handled")
+}
@@ -0,0 +1,190 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// AdminClient is the subset of kafka.Client used by GroupAdmin.
+type AdminClient interface {
+	Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error)
+	OffsetFetch(ctx context.Context, req *kafka.OffsetFetchRequest) (*kafka.OffsetFetchResponse, error)
+	ListOffsets(ctx context.Context, req *kafka.ListOffsetsRequest) (*kafka.ListOffsetsResponse, error)
+	OffsetCommit(ctx context.Context, req *kafka.OffsetCommitRequest) (*kafka.OffsetCommitResponse, error)
+}
+
+// PartitionLag describes how far a consumer group is behind on one partition.
+type PartitionLag struct {
+	Partition int
+	// CommittedOffset is the next offset the group will read, or -1 when the
+	// group has never committed on this partition.
+	CommittedOffset int64
+	// EndOffset is the offset the next produced message will receive.
+	EndOffset int64
+	Lag       int64
+}
+
+// GroupAdmin inspects and adjusts consumer group offsets, so reprocessing
+// does not require external Kafka tooling.
+type GroupAdmin struct {
+	client AdminClient
+}
+
+// NewGroupAdmin returns a GroupAdmin talking to the given brokers.
+func NewGroupAdmin(brokers []string) *GroupAdmin {
+	return &GroupAdmin{
+		client: &kafka.Client{Addr: kafka.TCP(brokers...)},
+	}
+}
+
+// Lag reports the committed offset, end offset and lag of groupID for every
+// partition of topic, ordered by partition.
+func (a *GroupAdmin) Lag(ctx context.Context, groupID, topic string) ([]PartitionLag, error) {
+	partitions, err := a.partitions(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	committed, err := a.client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: groupID,
+		Topics:  map[string][]int{topic: partitions},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch committed offsets: %w", err)
+	}
+	if committed.Error != nil {
+		return nil, fmt.Errorf("failed to fetch committed offsets: %w", committed.Error)
+	}
+
+	ends, err := a.listOffsets(ctx, topic, offsetRequests(partitions, kafka.LastOffsetOf))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]PartitionLag, 0, len(partitions))
+	for _, p := range committed.Topics[topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("partition %d: %w", p.Partition, p.Error)
+		}
+		end := ends[p.Partition].LastOffset
+		lag := end - p.CommittedOffset
+		if p.CommittedOffset < 0 {
+			lag = end
+		}
+		result = append(result, PartitionLag{
+			Partition:       p.Partition,
+			CommittedOffset: p.CommittedOffset,
+			EndOffset:       end,
+			Lag:             lag,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Partition < result[j].Partition })
+	return result, nil
+}
+
+// ResetOffsetsToTime commits, for every partition of topic, the offset of the
+// first message produced at or after t, so groupID re-reads from that point.
+// Partitions with no such message are moved to their end. The group must have
+// no active members, as Kafka rejects commits from outside a live generation.
+func (a *GroupAdmin) ResetOffsetsToTime(ctx context.Context, groupID, topic string, t time.Time) (map[int]int64, error) {
+	partitions, err := a.partitions(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	ends, err := a.listOffsets(ctx, topic, offsetRequests(partitions, kafka.LastOffsetOf))
+	if err != nil {
+		return nil, err
+	}
+	// Looked up separately: kafka-go reports "no message after t" with the
+	// same sentinel it uses for end-offset requests.
+	byTime, err := a.listOffsets(ctx, topic, offsetRequests(partitions, func(p int) kafka.OffsetRequest {
+		return kafka.TimeOffsetOf(p, t)
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make(map[int]int64, len(partitions))
+	commits := make([]kafka.OffsetCommit, 0, len(partitions))
+	for _, p := range partitions {
+		target := ends[p].LastOffset
+		for offset := range byTime[p].Offsets {
+			if offset >= 0 && offset < target {
+				target = offset
+			}
+		}
+		targets[p] = target
+		commits = append(commits, kafka.OffsetCommit{Partition: p, Offset: target})
+	}
+
+	resp, err := a.client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      groupID,
+		GenerationID: -1,
+		Topics:       map[string][]kafka.OffsetCommit{topic: commits},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit offsets: %w", err)
+	}
+	for _, p := range resp.Topics[topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("failed to commit offset for partition %d: %w", p.Partition, p.Error)
+		}
+	}
+	return targets, nil
+}
+
+// partitions returns the partition IDs of topic.
+func (a *GroupAdmin) partitions(ctx context.Context, topic string) ([]int, error) {
+	meta, err := a.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata: %w", err)
+	}
+	for _, t := range meta.Topics {
+		if t.Name != topic {
+			continue
+		}
+		if t.Error != nil {
+			return nil, fmt.Errorf("topic %q: %w", topic, t.Error)
+		}
+		ids := make([]int, 0, len(t.Partitions))
+		for _, p := range t.Partitions {
+			ids = append(ids, p.ID)
+		}
+		sort.Ints(ids)
+		return ids, nil
+	}
+	return nil, fmt.Errorf("topic %q not found", topic)
+}
+
+// offsetRequests builds one offset request per partition.
+func offsetRequests(partitions []int, request func(partition int) kafka.OffsetRequest) []kafka.OffsetRequest {
+	requests := make([]kafka.OffsetRequest, 0, len(partitions))
+	for _, p := range partitions {
+		requests = append(requests, request(p))
+	}
+	return requests
+}
+
+// listOffsets runs a ListOffsets request for topic and indexes the result by
+// partition.
+func (a *GroupAdmin) listOffsets(ctx context.Context, topic string, requests []kafka.OffsetRequest) (map[int]kafka.PartitionOffsets, error) {
+	resp, err := a.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{topic: requests},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offsets: %w", err)
+	}
+	byPartition := make(map[int]kafka.PartitionOffsets, len(resp.Topics[topic]))
+	for _, p := range resp.Topics[topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("failed to list offsets for partition %d: %w", p.Partition, p.Error)
+		}
+		byPartition[p.Partition] = p
+	}
+	return byPartition, nil
+}
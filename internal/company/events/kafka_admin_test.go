@@ -0,0 +1,107 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAdminClient serves canned offsets for a two-partition topic.
+type fakeAdminClient struct {
+	committed map[int]int64
+	ends      map[int]int64
+	byTime    map[int]int64
+	commitErr error
+	commits   []kafka.OffsetCommit
+}
+
+func (f *fakeAdminClient) Metadata(_ context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error) {
+	return &kafka.MetadataResponse{Topics: []kafka.Topic{{
+		Name:       req.Topics[0],
+		Partitions: []kafka.Partition{{ID: 1}, {ID: 0}},
+	}}}, nil
+}
+
+func (f *fakeAdminClient) OffsetFetch(_ context.Context, req *kafka.OffsetFetchRequest) (*kafka.OffsetFetchResponse, error) {
+	resp := &kafka.OffsetFetchResponse{Topics: map[string][]kafka.OffsetFetchPartition{}}
+	for topic, partitions := range req.Topics {
+		for _, p := range partitions {
+			resp.Topics[topic] = append(resp.Topics[topic], kafka.OffsetFetchPartition{Partition: p, CommittedOffset: f.committed[p]})
+		}
+	}
+	return resp, nil
+}
+
+func (f *fakeAdminClient) ListOffsets(_ context.Context, req *kafka.ListOffsetsRequest) (*kafka.ListOffsetsResponse, error) {
+	resp := &kafka.ListOffsetsResponse{Topics: map[string][]kafka.PartitionOffsets{}}
+	for topic, requests := range req.Topics {
+		for _, r := range requests {
+			po := kafka.PartitionOffsets{Partition: r.Partition, LastOffset: -1, Offsets: map[int64]time.Time{}}
+			if r.Timestamp == kafka.LastOffset {
+				po.LastOffset = f.ends[r.Partition]
+			} else if offset, ok := f.byTime[r.Partition]; ok {
+				po.Offsets[offset] = time.Unix(0, r.Timestamp*int64(time.Millisecond))
+			}
+			resp.Topics[topic] = append(resp.Topics[topic], po)
+		}
+	}
+	return resp, nil
+}
+
+func (f *fakeAdminClient) OffsetCommit(_ context.Context, req *kafka.OffsetCommitRequest) (*kafka.OffsetCommitResponse, error) {
+	if f.commitErr != nil {
+		return nil, f.commitErr
+	}
+	resp := &kafka.OffsetCommitResponse{Topics: map[string][]kafka.OffsetCommitPartition{}}
+	for topic, commits := range req.Topics {
+		f.commits = append(f.commits, commits...)
+		for _, c := range commits {
+			resp.Topics[topic] = append(resp.Topics[topic], kafka.OffsetCommitPartition{Partition: c.Partition})
+		}
+	}
+	return resp, nil
+}
+
+func TestGroupAdmin_Lag(t *testing.T) {
+	admin := &GroupAdmin{client: &fakeAdminClient{
+		committed: map[int]int64{0: 5, 1: -1},
+		ends:      map[int]int64{0: 12, 1: 7},
+	}}
+
+	lags, err := admin.Lag(context.Background(), "group", "company_events")
+	require.NoError(t, err)
+	assert.Equal(t, []PartitionLag{
+		{Partition: 0, CommittedOffset: 5, EndOffset: 12, Lag: 7},
+		{Partition: 1, CommittedOffset: -1, EndOffset: 7, Lag: 7},
+	}, lags)
+}
+
+func TestGroupAdmin_ResetOffsetsToTime(t *testing.T) {
+	client := &fakeAdminClient{
+		ends:   map[int]int64{0: 12, 1: 7},
+		byTime: map[int]int64{0: 3},
+	}
+	admin := &GroupAdmin{client: client}
+
+	offsets, err := admin.ResetOffsetsToTime(context.Background(), "group", "company_events", time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+
+	// Partition 1 has no message after the timestamp and moves to its end.
+	assert.Equal(t, map[int]int64{0: 3, 1: 7}, offsets)
+	assert.ElementsMatch(t, []kafka.OffsetCommit{{Partition: 0, Offset: 3}, {Partition: 1, Offset: 7}}, client.commits)
+}
+
+func TestGroupAdmin_ResetOffsetsToTimeCommitError(t *testing.T) {
+	admin := &GroupAdmin{client: &fakeAdminClient{
+		ends:      map[int]int64{0: 1, 1: 1},
+		commitErr: errors.New("rebalance in progress"),
+	}}
+
+	_, err := admin.ResetOffsetsToTime(context.Background(), "group", "company_events", time.Now())
+	assert.ErrorContains(t, err, "failed to commit offsets")
+}
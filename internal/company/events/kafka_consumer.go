@@ -2,76 +2,315 @@ package events
 
 import (
 	"context"
-	"encoding/json"
+	"hash/fnv"
+	"strconv"
+	"sync"
 
+	"github.com/google/uuid"
 	"github.com/segmentio/kafka-go"
 	"go.uber.org/zap"
 )
 
+// DedupStore records which events a consumer group has already processed, so
+// redelivered events are not applied twice under at-least-once delivery.
+type DedupStore interface {
+	IsEventProcessed(ctx context.Context, groupID string, eventID uuid.UUID) (bool, error)
+	MarkEventProcessed(ctx context.Context, groupID string, eventID uuid.UUID) error
+}
+
+// KafkaReader is the subset of kafka.Reader used by Consumer.
+type KafkaReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
 type Consumer struct {
-	reader  *kafka.Reader
+	reader  KafkaReader
+	groupID string
+	dedup   DedupStore
 	logger  *zap.Logger
 	handler func(context.Context, Event) error
+	// concurrency is the number of messages handled at once.
+	concurrency int
+
+	// stop cancels the fetch loop started by Start, which closes done once
+	// the in-flight messages have been handled and committed.
+	stop context.CancelFunc
+	done chan struct{}
+
+	// mu guards resume, which is non-nil while consumption is paused and is
+	// closed by Resume.
+	mu     sync.Mutex
+	resume chan struct{}
+}
+
+// ConsumerOption customizes a Consumer created by NewConsumer.
+type ConsumerOption func(*Consumer)
+
+// WithDedupStore makes the consumer skip events whose EventID the store has
+// already seen for this consumer group.
+func WithDedupStore(store DedupStore) ConsumerOption {
+	return func(c *Consumer) {
+		c.dedup = store
+	}
+}
+
+// WithConcurrency lets the consumer handle up to n messages at once. Messages
+// with the same key on the same partition, such as the events of one
+// company, are still handled one at a time, in order. The default is 1.
+func WithConcurrency(n int) ConsumerOption {
+	return func(c *Consumer) {
+		c.concurrency = n
+	}
 }
 
-// NewConsumer consumes kafka events.
-// TODO: implement consuming logic if there is time
-func NewConsumer(brokers []string, groupID string, logger *zap.Logger) *Consumer {
-	return &Consumer{
+// NewConsumer consumes the events written to topics as a member of the
+// groupID consumer group. ConsumerTopics lists the topics a producer writes
+// to.
+func NewConsumer(brokers []string, groupID string, topics []string, logger *zap.Logger, opts ...ConsumerOption) *Consumer {
+	c := &Consumer{
 		reader: kafka.NewReader(kafka.ReaderConfig{
-			Brokers: brokers,
-			GroupID: groupID,
-			Topic:   "company.*",
-			Dialer:  kafka.DefaultDialer,
+			Brokers:     brokers,
+			GroupID:     groupID,
+			GroupTopics: topics,
+			Dialer:      kafka.DefaultDialer,
 		}),
-		logger: logger.Named("kafka_consumer"),
+		groupID:     groupID,
+		logger:      logger.Named("kafka_consumer"),
+		concurrency: 1,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ConsumerTopics returns the topics a producer using strategy writes events
+// of the given types to; topic is the topic of SingleTopic.
+func ConsumerTopics(strategy TopicStrategy, topic string, types ...EventType) []string {
+	if strategy != TopicPerEvent {
+		return []string{topic}
+	}
+	topics := make([]string, 0, len(types))
+	for _, eventType := range types {
+		topics = append(topics, string(eventType))
 	}
+	return topics
 }
 
+// Start consumes messages in the background until ctx is done or Close is
+// called. A message is committed only once it and every message fetched
+// before it from the same partition have been handled, so concurrent
+// handlers never commit past a message still in flight.
 func (c *Consumer) Start(ctx context.Context) {
+	ctx, c.stop = context.WithCancel(ctx)
+	c.done = make(chan struct{})
 	go func() {
-		for {
-			msg, err := c.reader.FetchMessage(ctx)
-			if err != nil {
-				if ctx.Err() != nil {
-					return
-				}
-				c.logger.Error("Failed to fetch message", zap.Error(err))
-				continue
-			}
+		defer close(c.done)
+		c.run(ctx)
+	}()
+}
 
-			var event Event
-			if err := json.Unmarshal(msg.Value, &event); err != nil {
-				c.logger.Error("Failed to parse event",
-					zap.Error(err),
-					zap.ByteString("value", msg.Value),
-				)
-				continue
+// run fetches messages until ctx is done, dispatching each to the worker
+// owning its key, then waits for the workers to finish.
+func (c *Consumer) run(ctx context.Context) {
+	// Handlers and commits outlive ctx, so messages in flight at shutdown
+	// are finished and committed rather than redelivered.
+	workCtx := context.WithoutCancel(ctx)
+	offsets := newOffsetTracker()
+	queues := make([]chan *trackedMessage, max(c.concurrency, 1))
+	var workers sync.WaitGroup
+	for i := range queues {
+		queue := make(chan *trackedMessage)
+		queues[i] = queue
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for tracked := range queue {
+				event, ok := c.process(workCtx, tracked.msg)
+				offsets.complete(tracked, ok, func(commit kafka.Message) {
+					if err := c.reader.CommitMessages(workCtx, commit); err != nil {
+						c.logger.Error("Failed to commit message",
+							zap.Error(err),
+							zap.String("event_type", string(event.Type)),
+						)
+					}
+				})
 			}
+		}()
+	}
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+		workers.Wait()
+	}()
 
-			if err := c.handler(ctx, event); err != nil {
-				c.logger.Error("Failed to handle event",
-					zap.Error(err),
-					zap.String("event_type", string(event.Type)),
-				)
-				continue
-			}
+	for {
+		if err := c.waitWhilePaused(ctx); err != nil {
+			return
+		}
 
-			if err := c.reader.CommitMessages(ctx, msg); err != nil {
-				c.logger.Error("Failed to commit message",
-					zap.Error(err),
-					zap.String("event_type", string(event.Type)),
-				)
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
 			}
+			c.logger.Error("Failed to fetch message", zap.Error(err))
+			continue
 		}
-	}()
+
+		tracked, reset := offsets.add(msg)
+		if reset {
+			c.logger.Info("Partition redelivered after a rebalance",
+				zap.String("topic", msg.Topic),
+				zap.Int("partition", msg.Partition),
+				zap.Int64("offset", msg.Offset),
+			)
+		}
+		select {
+		case queues[workerFor(msg, len(queues))] <- tracked:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// workerFor returns the worker handling msg: the same one for every message
+// with its key on its partition.
+func workerFor(msg kafka.Message, workers int) int {
+	h := fnv.New32a()
+	h.Write([]byte(msg.Topic))
+	h.Write([]byte(strconv.Itoa(msg.Partition)))
+	h.Write(msg.Key)
+	return int(h.Sum32() % uint32(workers))
+}
+
+// process decodes and handles a single message, returning the event and
+// whether the message may be committed. Events already recorded in the dedup
+// store are committed without invoking the handler.
+func (c *Consumer) process(ctx context.Context, msg kafka.Message) (Event, bool) {
+	var event Event
+	codec, err := codecFor(headerValue(msg, ContentTypeHeader))
+	if err == nil {
+		err = codec.Unmarshal(msg.Value, &event)
+	}
+	if err != nil {
+		c.logger.Error("Failed to parse event",
+			zap.Error(err),
+			zap.ByteString("value", msg.Value),
+		)
+		return event, false
+	}
+
+	dedup := c.dedup != nil && event.EventID != uuid.Nil
+	if dedup {
+		processed, err := c.dedup.IsEventProcessed(ctx, c.groupID, event.EventID)
+		if err != nil {
+			c.logger.Error("Failed to check processed events",
+				zap.Error(err),
+				zap.String("event_id", event.EventID.String()),
+			)
+			return event, false
+		}
+		if processed {
+			c.logger.Debug("Skipping already processed event",
+				zap.String("event_id", event.EventID.String()),
+				zap.String("event_type", string(event.Type)),
+			)
+			return event, true
+		}
+	}
+
+	if err := c.handler(ctx, event); err != nil {
+		c.logger.Error("Failed to handle event",
+			zap.Error(err),
+			zap.String("event_type", string(event.Type)),
+		)
+		return event, false
+	}
+
+	if dedup {
+		if err := c.dedup.MarkEventProcessed(ctx, c.groupID, event.EventID); err != nil {
+			// The handler already ran; a redelivery may apply it again.
+			c.logger.Error("Failed to record processed event",
+				zap.Error(err),
+				zap.String("event_id", event.EventID.String()),
+			)
+		}
+	}
+	return event, true
+}
+
+// headerValue returns the value of the header of msg named key, or "".
+func headerValue(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Pause stops the consumer from fetching further messages; those already
+// fetched are still handled. The consumer stays in its group while paused.
+func (c *Consumer) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resume == nil {
+		c.resume = make(chan struct{})
+		c.logger.Info("Consumption paused")
+	}
+}
+
+// Resume continues consumption after Pause.
+func (c *Consumer) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.resume != nil {
+		close(c.resume)
+		c.resume = nil
+		c.logger.Info("Consumption resumed")
+	}
+}
+
+// Paused reports whether consumption is currently paused.
+func (c *Consumer) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resume != nil
+}
+
+// waitWhilePaused blocks until the consumer is resumed or ctx is done.
+func (c *Consumer) waitWhilePaused(ctx context.Context) error {
+	c.mu.Lock()
+	resume := c.resume
+	c.mu.Unlock()
+	if resume == nil {
+		return nil
+	}
+
+	select {
+	case <-resume:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (c *Consumer) RegisterHandler(fn func(context.Context, Event) error) {
 	c.handler = fn
 }
 
+// Close stops fetching, waits for the handlers of the messages in flight
+// and commits them, then leaves the consumer group, so the partitions are
+// handed to another member without being processed twice.
 func (c *Consumer) Close() {
+	if c.stop != nil {
+		c.stop()
+		<-c.done
+	}
 	if err := c.reader.Close(); err != nil {
 		c.logger.Error("Failed to close Kafka reader", zap.Error(err))
 	}
@@ -0,0 +1,309 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// memoryDedupStore is an in-memory DedupStore.
+type memoryDedupStore struct {
+	seen map[string]bool
+}
+
+func (m *memoryDedupStore) IsEventProcessed(_ context.Context, groupID string, eventID uuid.UUID) (bool, error) {
+	return m.seen[groupID+"/"+eventID.String()], nil
+}
+
+func (m *memoryDedupStore) MarkEventProcessed(_ context.Context, groupID string, eventID uuid.UUID) error {
+	m.seen[groupID+"/"+eventID.String()] = true
+	return nil
+}
+
+func eventMessage(t *testing.T, event Event) kafka.Message {
+	value, err := json.Marshal(event)
+	require.NoError(t, err)
+	return kafka.Message{Value: value}
+}
+
+func TestConsumer_ProcessDeduplicates(t *testing.T) {
+	store := &memoryDedupStore{seen: map[string]bool{}}
+	handled := 0
+	c := &Consumer{groupID: "notifier", dedup: store, logger: zaptest.NewLogger(t)}
+	c.RegisterHandler(func(context.Context, Event) error {
+		handled++
+		return nil
+	})
+
+	msg := eventMessage(t, Event{EventID: uuid.New(), Type: CompanyCreated, Company: &models.Company{ID: uuid.New()}})
+
+	_, ok := c.process(context.Background(), msg)
+	assert.True(t, ok)
+	_, ok = c.process(context.Background(), msg)
+	assert.True(t, ok, "redelivered event should still be committed")
+	assert.Equal(t, 1, handled, "redelivered event should not reach the handler")
+}
+
+func TestConsumer_ProcessHandlerError(t *testing.T) {
+	store := &memoryDedupStore{seen: map[string]bool{}}
+	c := &Consumer{groupID: "notifier", dedup: store, logger: zaptest.NewLogger(t)}
+	c.RegisterHandler(func(context.Context, Event) error {
+		return errors.New("downstream unavailable")
+	})
+
+	event := Event{EventID: uuid.New(), Type: CompanyCreated, Company: &models.Company{ID: uuid.New()}}
+	_, ok := c.process(context.Background(), eventMessage(t, event))
+	assert.False(t, ok)
+	assert.Empty(t, store.seen, "failed events must not be recorded as processed")
+}
+
+func TestConsumer_ProcessWithoutEventID(t *testing.T) {
+	store := &memoryDedupStore{seen: map[string]bool{}}
+	handled := 0
+	c := &Consumer{groupID: "notifier", dedup: store, logger: zaptest.NewLogger(t)}
+	c.RegisterHandler(func(context.Context, Event) error {
+		handled++
+		return nil
+	})
+
+	// Events produced before EventID existed are always handled.
+	msg := eventMessage(t, Event{Type: CompanyCreated, Company: &models.Company{ID: uuid.New()}})
+	c.process(context.Background(), msg)
+	c.process(context.Background(), msg)
+	assert.Equal(t, 2, handled)
+	assert.Empty(t, store.seen)
+}
+
+func TestConsumer_ProcessMalformed(t *testing.T) {
+	c := &Consumer{logger: zaptest.NewLogger(t)}
+	_, ok := c.process(context.Background(), kafka.Message{Value: []byte("{")})
+	assert.False(t, ok)
+}
+
+// FuzzConsumer_Process feeds arbitrary message values to the consumer,
+// covering truncated payloads and malformed event IDs.
+func FuzzConsumer_Process(f *testing.F) {
+	valid, err := json.Marshal(Event{EventID: uuid.New(), Type: CompanyUpdated, Company: &models.Company{ID: uuid.New(), Name: "Acme"},
+		Changes: map[string]models.FieldChange{"employees": {Old: 1, New: 2}}})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(valid)
+	f.Add(valid[:len(valid)/2])
+	f.Add([]byte(`{"EventID":"not-a-uuid","Type":"company_created"}`))
+	f.Add([]byte(`{"EventID":"00000000-0000-0000-0000-000000000000","Company":null}`))
+	f.Add([]byte(`{"Company":{"ID":"1234","Employees":"many"}}`))
+	f.Add([]byte("null"))
+
+	f.Fuzz(func(t *testing.T, value []byte) {
+		store := &memoryDedupStore{seen: map[string]bool{}}
+		c := &Consumer{groupID: "notifier", dedup: store, logger: zap.NewNop()}
+		handled := 0
+		c.RegisterHandler(func(context.Context, Event) error {
+			handled++
+			return nil
+		})
+
+		_, ok := c.process(context.Background(), kafka.Message{Value: value})
+		var event Event
+		decodable := json.Unmarshal(value, &event) == nil
+		if ok != decodable {
+			t.Errorf("expected commit %v for %q, got %v", decodable, value, ok)
+		}
+		if decodable != (handled == 1) {
+			t.Errorf("expected the handler to run once for decodable events, ran %d times", handled)
+		}
+		if len(store.seen) > 0 && event.EventID == uuid.Nil {
+			t.Errorf("recorded an event without an ID: %q", value)
+		}
+	})
+}
+
+func TestConsumer_PauseResume(t *testing.T) {
+	c := &Consumer{logger: zaptest.NewLogger(t)}
+	assert.False(t, c.Paused())
+	assert.NoError(t, c.waitWhilePaused(context.Background()))
+
+	c.Pause()
+	c.Pause() // pausing twice is a no-op
+	assert.True(t, c.Paused())
+
+	waited := make(chan error, 1)
+	go func() {
+		waited <- c.waitWhilePaused(context.Background())
+	}()
+
+	select {
+	case <-waited:
+		t.Fatal("waitWhilePaused returned while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	c.Resume()
+	select {
+	case err := <-waited:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("waitWhilePaused did not return after Resume")
+	}
+	assert.False(t, c.Paused())
+}
+
+func TestConsumer_WaitWhilePausedCanceled(t *testing.T) {
+	c := &Consumer{logger: zaptest.NewLogger(t)}
+	c.Pause()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, c.waitWhilePaused(ctx), context.Canceled)
+}
+
+// fakeReader serves msgs, then blocks until the fetch is canceled, and
+// records the offsets committed per partition.
+type fakeReader struct {
+	mu        sync.Mutex
+	msgs      []kafka.Message
+	committed map[int][]int64
+	closed    bool
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	r.mu.Lock()
+	if len(r.msgs) > 0 {
+		msg := r.msgs[0]
+		r.msgs = r.msgs[1:]
+		r.mu.Unlock()
+		return msg, nil
+	}
+	r.mu.Unlock()
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (r *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, msg := range msgs {
+		r.committed[msg.Partition] = append(r.committed[msg.Partition], msg.Offset)
+	}
+	return nil
+}
+
+func (r *fakeReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+func (r *fakeReader) lastCommitted(partition int) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	offsets := r.committed[partition]
+	if len(offsets) == 0 {
+		return -1
+	}
+	return offsets[len(offsets)-1]
+}
+
+func TestConsumer_ConcurrentInOrderPerKey(t *testing.T) {
+	companies := make([]uuid.UUID, 8)
+	for i := range companies {
+		companies[i] = uuid.New()
+	}
+	burst := []EventType{CompanyCreated, CompanyUpdated, CompanyUpdated, CompanyDeleted}
+	reader := &fakeReader{committed: map[int][]int64{}}
+	for _, eventType := range burst {
+		for i, id := range companies {
+			msg := eventMessage(t, Event{EventID: uuid.New(), Type: eventType, Company: &models.Company{ID: id}})
+			msg.Partition = i % 2
+			msg.Key = []byte(id.String())
+			msg.Offset = int64(len(reader.msgs))
+			reader.msgs = append(reader.msgs, msg)
+		}
+	}
+	last := reader.msgs[len(reader.msgs)-1].Offset
+
+	var mu sync.Mutex
+	seen := map[uuid.UUID][]EventType{}
+	running, peak := 0, 0
+	c := &Consumer{reader: reader, logger: zaptest.NewLogger(t), concurrency: 4}
+	c.RegisterHandler(func(_ context.Context, event Event) error {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running--
+		seen[event.Company.ID] = append(seen[event.Company.ID], event.Type)
+		mu.Unlock()
+		return nil
+	})
+
+	c.Start(context.Background())
+	assert.Eventually(t, func() bool {
+		return reader.lastCommitted(0) >= last-1 && reader.lastCommitted(1) == last
+	}, 5*time.Second, time.Millisecond)
+	c.Close()
+
+	for _, id := range companies {
+		assert.Equal(t, burst, seen[id], "events of a company must be handled in order")
+	}
+	assert.Greater(t, peak, 1, "companies should be handled concurrently")
+	for partition, offsets := range reader.committed {
+		assert.IsIncreasing(t, offsets, "commits on partition %d must never go back", partition)
+	}
+	assert.True(t, reader.closed)
+}
+
+func TestConsumer_CloseFinishesInFlight(t *testing.T) {
+	msg := eventMessage(t, Event{EventID: uuid.New(), Type: CompanyCreated, Company: &models.Company{ID: uuid.New()}})
+	msg.Offset = 41
+	reader := &fakeReader{msgs: []kafka.Message{msg}, committed: map[int][]int64{}}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	c := &Consumer{reader: reader, logger: zaptest.NewLogger(t), concurrency: 2}
+	c.RegisterHandler(func(ctx context.Context, _ Event) error {
+		close(started)
+		<-release
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.Start(ctx)
+	<-started
+	cancel()
+
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned before the in-flight handler finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-closed
+	assert.Equal(t, int64(41), reader.lastCommitted(0), "the in-flight message should be committed with a live context")
+	assert.True(t, reader.closed)
+}
+
+func TestConsumerTopics(t *testing.T) {
+	assert.Equal(t, []string{"company_events"}, ConsumerTopics(SingleTopic, "company_events", CompanyCreated, CompanyDeleted))
+	assert.Equal(t, []string{"company_created", "company_deleted"}, ConsumerTopics(TopicPerEvent, "company_events", CompanyCreated, CompanyDeleted))
+}
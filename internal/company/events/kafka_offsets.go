@@ -0,0 +1,81 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// partitionKey identifies a partition of a topic.
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+// trackedMessage is a fetched message and the outcome of handling it.
+type trackedMessage struct {
+	msg     kafka.Message
+	handled bool
+	ok      bool
+}
+
+// offsetTracker decides which offsets a consumer with concurrent handlers
+// may commit. Committing an offset commits every earlier one of the
+// partition, so a message is only committed once the messages fetched
+// before it from its partition have all been handled.
+type offsetTracker struct {
+	mu      sync.Mutex
+	pending map[partitionKey][]*trackedMessage
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{pending: make(map[partitionKey][]*trackedMessage)}
+}
+
+// add records a fetched message, to be passed to complete once handled. A
+// message not following those pending for its partition means the partition
+// was reassigned and is being read again from its committed offset; the
+// stale messages are forgotten, so they are never committed, and reset is
+// true.
+func (t *offsetTracker) add(msg kafka.Message) (tracked *trackedMessage, reset bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := partitionKey{msg.Topic, msg.Partition}
+	pending := t.pending[key]
+	reset = len(pending) > 0 && msg.Offset <= pending[len(pending)-1].msg.Offset
+	if reset {
+		pending = nil
+	}
+	tracked = &trackedMessage{msg: msg}
+	t.pending[key] = append(pending, tracked)
+	return tracked, reset
+}
+
+// complete records the outcome of handling a message returned by add. If this completes a run of
+// handled messages at the head of the partition, commit is called with the
+// last of them handled successfully, as a sequential consumer would have
+// committed it. commit runs under the tracker's lock, so commits are never
+// reordered.
+func (t *offsetTracker) complete(tracked *trackedMessage, ok bool, commit func(kafka.Message)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tracked.handled, tracked.ok = true, ok
+	key := partitionKey{tracked.msg.Topic, tracked.msg.Partition}
+	pending := t.pending[key]
+
+	var last *trackedMessage
+	for len(pending) > 0 && pending[0].handled {
+		if pending[0].ok {
+			last = pending[0]
+		}
+		pending = pending[1:]
+	}
+	if len(pending) == 0 {
+		delete(t.pending, key)
+	} else {
+		t.pending[key] = pending
+	}
+	if last != nil {
+		commit(last.msg)
+	}
+}
@@ -0,0 +1,72 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffsetTracker_CommitsContiguousPrefix(t *testing.T) {
+	tracker := newOffsetTracker()
+	var committed []int64
+	commit := func(msg kafka.Message) { committed = append(committed, msg.Offset) }
+
+	m0, _ := tracker.add(kafka.Message{Topic: "company_events", Partition: 0, Offset: 0})
+	m1, _ := tracker.add(kafka.Message{Topic: "company_events", Partition: 0, Offset: 1})
+	m2, _ := tracker.add(kafka.Message{Topic: "company_events", Partition: 0, Offset: 2})
+	other, _ := tracker.add(kafka.Message{Topic: "company_events", Partition: 1, Offset: 7})
+
+	// Offset 0 is still in flight, so later ones must wait.
+	tracker.complete(m2, true, commit)
+	tracker.complete(m1, true, commit)
+	assert.Empty(t, committed)
+
+	// Partitions are tracked independently.
+	tracker.complete(other, true, commit)
+	assert.Equal(t, []int64{7}, committed)
+
+	tracker.complete(m0, true, commit)
+	assert.Equal(t, []int64{7, 2}, committed)
+	assert.Empty(t, tracker.pending)
+}
+
+func TestOffsetTracker_FailedMessages(t *testing.T) {
+	tracker := newOffsetTracker()
+	var committed []int64
+	commit := func(msg kafka.Message) { committed = append(committed, msg.Offset) }
+
+	m0, _ := tracker.add(kafka.Message{Partition: 0, Offset: 0})
+	m1, _ := tracker.add(kafka.Message{Partition: 0, Offset: 1})
+	m2, _ := tracker.add(kafka.Message{Partition: 0, Offset: 2})
+
+	// As with a sequential consumer, a failed message is not committed
+	// itself, but a later success commits past it.
+	tracker.complete(m0, true, commit)
+	tracker.complete(m1, false, commit)
+	assert.Equal(t, []int64{0}, committed)
+	tracker.complete(m2, true, commit)
+	assert.Equal(t, []int64{0, 2}, committed)
+}
+
+func TestOffsetTracker_Rebalance(t *testing.T) {
+	tracker := newOffsetTracker()
+	var committed []int64
+	commit := func(msg kafka.Message) { committed = append(committed, msg.Offset) }
+
+	stale, reset := tracker.add(kafka.Message{Partition: 0, Offset: 5})
+	assert.False(t, reset)
+	_, _ = tracker.add(kafka.Message{Partition: 0, Offset: 6})
+
+	// The partition is read again from its committed offset after being
+	// reassigned.
+	redelivered, reset := tracker.add(kafka.Message{Partition: 0, Offset: 5})
+	assert.True(t, reset)
+
+	// The handler of the stale copy finishing does not commit anything for
+	// the redelivered one.
+	tracker.complete(stale, true, commit)
+	assert.Empty(t, committed)
+	tracker.complete(redelivered, true, commit)
+	assert.Equal(t, []int64{5}, committed)
+}
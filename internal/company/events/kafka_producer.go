@@ -3,24 +3,125 @@ package events
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
 	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/pkg/version"
+	"github.com/google/uuid"
 	"github.com/segmentio/kafka-go"
 	"go.uber.org/zap"
 )
 
 var jsonMarshal = json.Marshal
 
+const (
+	// defaultWorkers is the number of goroutines delivering queued events.
+	defaultWorkers = 4
+	// defaultQueueSize bounds the number of events buffered for the workers,
+	// split evenly between their queues.
+	defaultQueueSize = 1000
+)
+
 type EventType string
 
 const (
 	CompanyCreated EventType = "company_created"
 	CompanyUpdated EventType = "company_updated"
 	CompanyDeleted EventType = "company_deleted"
+	// CompanyStatusChanged is emitted instead of CompanyUpdated when an update
+	// changes the company's lifecycle status.
+	CompanyStatusChanged EventType = "company_status_changed"
+	// CompanyArchived is emitted instead of CompanyStatusChanged when the new
+	// status is ARCHIVED.
+	CompanyArchived EventType = "company_archived"
+	// CompanyEnriched is emitted when enrichment providers fill in
+	// attributes of a new company.
+	CompanyEnriched EventType = "company_enriched"
+	// CompanyErased is emitted when the personal data of a company was
+	// erased on request. Its Changes name the erased fields, without
+	// values; consumers must scrub those fields from their copies.
+	CompanyErased EventType = "company_erased"
+	// CompanyNoteAdded and CompanyNoteDeleted are emitted when a note is
+	// recorded on a company or removed. Their "note" change carries the ID
+	// of the note, not its body.
+	CompanyNoteAdded   EventType = "company_note_added"
+	CompanyNoteDeleted EventType = "company_note_deleted"
 )
 
+// eventTypes lists every event the producer may emit, used to provision
+// topics when routing per event type.
+var eventTypes = []EventType{CompanyCreated, CompanyUpdated, CompanyDeleted, CompanyStatusChanged, CompanyArchived, CompanyEnriched, CompanyErased, CompanyNoteAdded, CompanyNoteDeleted}
+
+// Valid reports whether t is one of the event types the producer emits.
+func (t EventType) Valid() bool {
+	for _, known := range eventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// EventTypeHeader is the Kafka message header carrying the event type,
+// set regardless of the topic strategy.
+const EventTypeHeader = "event_type"
+
+// ProducerHeader is the Kafka message header naming the service and version
+// that produced the message, e.g. "company-service/v1.4.0", so consumers can
+// correlate events with deploys.
+const ProducerHeader = "producer"
+
+// producerTag is the value of ProducerHeader.
+var producerTag = []byte(version.UserAgent("company-service"))
+
+// TopicStrategy selects how events are mapped onto Kafka topics.
+type TopicStrategy int
+
+const (
+	// SingleTopic writes every event to the configured topic; consumers tell
+	// events apart by the EventTypeHeader.
+	SingleTopic TopicStrategy = iota
+	// TopicPerEvent writes each event to a topic named after its EventType.
+	TopicPerEvent
+)
+
+// ParseTopicStrategy converts a configuration value ("single" or
+// "per_event") into a TopicStrategy. An empty value selects SingleTopic.
+func ParseTopicStrategy(value string) (TopicStrategy, error) {
+	switch value {
+	case "", "single":
+		return SingleTopic, nil
+	case "per_event":
+		return TopicPerEvent, nil
+	default:
+		return SingleTopic, fmt.Errorf("unknown topic strategy %q", value)
+	}
+}
+
 type Event struct {
+	// EventID uniquely identifies the event so consumers can drop redeliveries.
+	EventID uuid.UUID
 	Type    EventType
 	Company *models.Company
+	// Actor is the user ID of the caller that triggered the event.
+	Actor string
+	// Changes holds the old and new value of every field modified by a
+	// CompanyUpdated, CompanyStatusChanged, CompanyArchived or
+	// CompanyEnriched event, keyed by field name, the fields erased by a
+	// CompanyErased event, without values, the note added or deleted by a
+	// CompanyNoteAdded or CompanyNoteDeleted event, and, under
+	// "merged_into", the ID of the company a CompanyDeleted event's company
+	// was merged into. It is empty for other events.
+	Changes map[string]models.FieldChange `json:",omitempty"`
+	// Validations holds the verdicts of the external validators that
+	// approved a CompanyCreated or update event. They go to the event
+	// history and the audit topic, not to the event consumers.
+	Validations []models.ValidationResult `json:"-"`
+	// Suppressed marks the event of a change made with events suppressed,
+	// such as a bulk back-fill: only its audit entry is published.
+	Suppressed bool `json:"-"`
 }
 
 type KafkaWriter interface {
@@ -29,62 +130,250 @@ type KafkaWriter interface {
 }
 
 type Producer struct {
-	writer    KafkaWriter // Use interface instead of concrete type
-	events    chan Event
+	writer   KafkaWriter // Use interface instead of concrete type
+	topic    string
+	strategy TopicStrategy
+	// topicSettings controls how NewProducer provisions the topics.
+	topicSettings TopicSettings
+	// transport controls how NewProducer connects to the brokers, and
+	// conns holds the connections it opened; Close releases them.
+	transport TransportSettings
+	conns     *kafka.Transport
+	// codec encodes the message values; nil means JSONCodec.
+	codec Codec
+	// audit configures the audit topic, if any.
+	audit auditSettings
+	// queues holds one queue per worker. All events of a company go through
+	// the same queue, so they are written in the order they were produced.
+	queues    []chan Event
 	logger    *zap.Logger
 	closeChan chan struct{}
+
+	// mu guards flushed; Produce holds it for reading so Flush never closes
+	// a queue underneath a pending send.
+	mu      sync.RWMutex
+	flushed bool
+	workers sync.WaitGroup
+
+	// ping checks that a broker is reachable.
+	ping   func(ctx context.Context) error
+	health health
 }
 
-func NewProducer(brokers []string, logger *zap.Logger, topic string) (*Producer, error) {
-	// Create topic if it doesn't exist
-	conn, err := kafka.Dial("tcp", brokers[0])
-	if err != nil {
-		return nil, err
-	}
-	defer conn.Close()
+// ProducerOption customizes a Producer created by NewProducer.
+type ProducerOption func(*Producer)
 
-	topicConfigs := []kafka.TopicConfig{
-		{
-			Topic:             topic,
-			NumPartitions:     3,
-			ReplicationFactor: 1,
-		},
+// WithTopicStrategy selects how events are routed to topics. The default is
+// SingleTopic.
+func WithTopicStrategy(strategy TopicStrategy) ProducerOption {
+	return func(p *Producer) {
+		p.strategy = strategy
 	}
+}
 
-	err = conn.CreateTopics(topicConfigs...)
-	if err != nil {
-		logger.Warn("failed to create topic (may already exist)", zap.Error(err))
+// WithCodec selects how events are encoded. The default is JSONCodec.
+func WithCodec(codec Codec) ProducerOption {
+	return func(p *Producer) {
+		p.codec = codec
 	}
+}
+
+// NewProducer returns a Producer writing to brokers. Its topics are created,
+// or checked to exist, according to the TopicSettings, and an error is
+// returned when they are missing and cannot be created.
+func NewProducer(brokers []string, logger *zap.Logger, topic string, opts ...ProducerOption) (*Producer, error) {
 	p := &Producer{
-		writer: &kafka.Writer{
-			Addr:     kafka.TCP(brokers...),
-			Balancer: &kafka.LeastBytes{},
-			Topic:    topic,
-		},
-		events:    make(chan Event, 1000), // Buffered channel
-		logger:    logger.Named("kafka_producer"),
-		closeChan: make(chan struct{}),
+		topic:         topic,
+		logger:        logger.Named("kafka_producer"),
+		closeChan:     make(chan struct{}),
+		topicSettings: DefaultTopicSettings(),
+		transport:     DefaultTransportSettings(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.conns = p.transport.roundTripper()
+	// The topic is set per message so a single writer can serve every
+	// strategy. Hashing the key sends every event of a company to the same
+	// partition. Writes are retried long enough for the cluster to fail
+	// over when a broker goes away.
+	p.writer = &kafka.Writer{
+		Addr:            kafka.TCP(brokers...),
+		Balancer:        &kafka.Hash{},
+		Transport:       p.conns,
+		MaxAttempts:     p.transport.writeAttempts(),
+		WriteBackoffMax: writeBackoffMax,
+	}
+	p.ping = dialBrokers(brokers, p.transport.dialer())
+	if err := p.provisionTopics(brokers); err != nil {
+		p.conns.CloseIdleConnections()
+		return nil, err
 	}
 
-	go p.eventLoop()
+	p.startWorkers(defaultWorkers, defaultQueueSize/defaultWorkers)
 	return p, nil
 }
 
-func (p *Producer) Produce(eventType EventType, company *models.Company) {
-	select {
-	case p.events <- Event{Type: eventType, Company: company}:
-	default:
-		p.logger.Warn("Kafka producer queue full, dropping event",
-			zap.String("event_type", string(eventType)),
-			zap.String("company_id", company.ID.String()),
+// provisionTopics waits for a broker to be reachable, then creates or checks
+// the topics according to the TopicSettings.
+func (p *Producer) provisionTopics(brokers []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), topicSetupTimeout)
+	defer cancel()
+	if err := p.ping(ctx); err != nil {
+		return err
+	}
+	client := &kafka.Client{Addr: kafka.TCP(brokers...), Transport: p.conns}
+	if err := ensureTopics(ctx, client, p.topics(), p.topicSettings, p.logger); err != nil {
+		return err
+	}
+	if p.audit.topic != "" {
+		settings := p.topicSettings
+		settings.Retention = p.audit.retention
+		return ensureTopics(ctx, client, []string{p.audit.topic}, settings, p.logger)
+	}
+	return nil
+}
+
+// topicFor returns the topic an event of the given type is written to.
+func (p *Producer) topicFor(eventType EventType) string {
+	if p.strategy == TopicPerEvent {
+		return string(eventType)
+	}
+	return p.topic
+}
+
+// topics returns every topic the producer may write to.
+func (p *Producer) topics() []string {
+	if p.strategy != TopicPerEvent {
+		return []string{p.topic}
+	}
+	names := make([]string, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		names = append(names, p.topicFor(eventType))
+	}
+	return names
+}
+
+// Produce queues an event for asynchronous delivery, assigning an EventID
+// when it has none. Events of the same company are delivered one at a time,
+// in the order they were produced. When the company's queue is full, Produce
+// waits for space rather than overtaking the queued events; once the producer
+// has been flushed or closed, the event is written synchronously instead.
+func (p *Producer) Produce(event Event) {
+	if err := p.produce(context.Background(), event); err != nil {
+		p.logger.Error("Failed to produce event",
+			zap.Error(err),
+			zap.String("event_type", string(event.Type)),
+			zap.String("company_id", event.Company.ID.String()),
 		)
 	}
 }
 
-func (p *Producer) eventLoop() {
+// ProduceContext is Produce for callers opting into backpressure: when the
+// company's queue is full it waits for space only until ctx is done, then
+// returns ctx's error without queueing the event. Events written
+// synchronously after a flush report their delivery failures.
+func (p *Producer) ProduceContext(ctx context.Context, event Event) error {
+	return p.produce(ctx, event)
+}
+
+func (p *Producer) produce(ctx context.Context, event Event) error {
+	if event.EventID == uuid.Nil {
+		event.EventID = uuid.New()
+	}
+	if event.Suppressed && p.audit.topic == "" {
+		return nil
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if !p.flushed {
+		queue := p.queueFor(event.Company.ID)
+		select {
+		case queue <- event:
+			return nil
+		default:
+			p.logger.Warn("Kafka producer queue full, waiting for space",
+				zap.String("event_type", string(event.Type)),
+				zap.String("company_id", event.Company.ID.String()),
+			)
+		}
+		select {
+		case queue <- event:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.closeChan:
+		}
+	}
+	// Earlier events of the company must be out before this one is written.
+	p.workers.Wait()
+	msgs, err := p.messages(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize event: %w", err)
+	}
+	return p.write(ctx, msgs...)
+}
+
+// queueFor returns the queue of the worker delivering the events of the
+// given company.
+func (p *Producer) queueFor(companyID uuid.UUID) chan Event {
+	h := fnv.New32a()
+	h.Write(companyID[:])
+	return p.queues[h.Sum32()%uint32(len(p.queues))]
+}
+
+// Flush stops queueing new events and blocks until the workers have delivered
+// everything already buffered, or until ctx is done.
+func (p *Producer) Flush(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.flushed {
+		p.flushed = true
+		for _, queue := range p.queues {
+			close(queue)
+		}
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// startWorkers launches n goroutines, each draining its own queue of the
+// given size.
+func (p *Producer) startWorkers(n, queueSize int) {
+	p.queues = make([]chan Event, n)
+	p.workers.Add(n)
+	for i := range n {
+		queue := make(chan Event, queueSize)
+		p.queues[i] = queue
+		go func() {
+			defer p.workers.Done()
+			p.eventLoop(queue)
+		}()
+	}
+}
+
+// eventLoop writes the events of queue one at a time until the queue is
+// closed or the producer is closed.
+func (p *Producer) eventLoop(queue <-chan Event) {
 	for {
 		select {
-		case event := <-p.events:
+		case event, ok := <-queue:
+			if !ok {
+				return
+			}
 			p.sendEvent(context.Background(), event)
 		case <-p.closeChan:
 			return
@@ -93,7 +382,7 @@ func (p *Producer) eventLoop() {
 }
 
 func (p *Producer) sendEvent(ctx context.Context, event Event) {
-	value, err := jsonMarshal(event)
+	msgs, err := p.messages(event)
 	if err != nil {
 		p.logger.Error("Failed to serialize event",
 			zap.Error(err),
@@ -101,11 +390,7 @@ func (p *Producer) sendEvent(ctx context.Context, event Event) {
 		)
 		return
 	}
-	err = p.writer.WriteMessages(ctx, kafka.Message{
-		Key:   []byte(event.Company.ID.String()),
-		Value: value,
-	})
-	if err != nil {
+	if err := p.write(ctx, msgs...); err != nil {
 		p.logger.Error("Failed to produce event",
 			zap.Error(err),
 			zap.String("event_type", string(event.Type)),
@@ -115,9 +400,91 @@ func (p *Producer) sendEvent(ctx context.Context, event Event) {
 	}
 }
 
+// Replay synchronously writes a previously published event, unchanged, to
+// topic. It bypasses the queue so callers learn about delivery failures.
+// Replayed events are not audited again.
+func (p *Producer) Replay(ctx context.Context, topic string, event Event) error {
+	msg, err := p.newMessage(topic, event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize event: %w", err)
+	}
+	return p.write(ctx, msg)
+}
+
+// Publish synchronously writes event, and its audit entry, bypassing the
+// queues so callers learn about delivery failures, e.g. the outbox relay,
+// which publishes each company's events one after the other itself.
+func (p *Producer) Publish(ctx context.Context, event Event) error {
+	msgs, err := p.messages(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize event: %w", err)
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return p.write(ctx, msgs...)
+}
+
+// write synchronously writes msgs in one batch.
+func (p *Producer) write(ctx context.Context, msgs ...kafka.Message) error {
+	err := p.writer.WriteMessages(ctx, msgs...)
+	p.health.record(err)
+	if err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
+
+// messages returns the messages publishing event: the event itself, unless
+// suppressed, and, with an audit topic, its audit entry.
+func (p *Producer) messages(event Event) ([]kafka.Message, error) {
+	var msgs []kafka.Message
+	if !event.Suppressed {
+		msg, err := p.newMessage(p.topicFor(event.Type), event)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	if p.audit.topic == "" {
+		return msgs, nil
+	}
+	audit, err := newAuditMessage(p.audit.topic, event)
+	if err != nil {
+		return nil, err
+	}
+	return append(msgs, audit), nil
+}
+
+// newMessage encodes event as a Kafka message for topic, keyed by company ID
+// so every event of a company lands on the same partition.
+func (p *Producer) newMessage(topic string, event Event) (kafka.Message, error) {
+	codec := p.codec
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	value, err := codec.Marshal(event)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	return kafka.Message{
+		Topic: topic,
+		Key:   []byte(event.Company.ID.String()),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: EventTypeHeader, Value: []byte(event.Type)},
+			{Key: ContentTypeHeader, Value: []byte(codec.ContentType())},
+			{Key: ProducerHeader, Value: producerTag},
+		},
+	}, nil
+}
+
 func (p *Producer) Close() {
 	close(p.closeChan)
 	if err := p.writer.Close(); err != nil {
 		p.logger.Error("Failed to close Kafka writer", zap.Error(err))
 	}
+	if p.conns != nil {
+		p.conns.CloseIdleConnections()
+	}
 }
@@ -0,0 +1,123 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// backpressureThreshold is the queue utilization (0-1) from which the
+// producer reports itself unready, so load balancers steer traffic away
+// before requests start waiting for queue space.
+const backpressureThreshold = 0.9
+
+// ProducerHealth describes the state of a Producer.
+type ProducerHealth struct {
+	// QueueLength is the number of events waiting for delivery and
+	// QueueCapacity the number the queues can hold.
+	QueueLength   int `json:"queue_length"`
+	QueueCapacity int `json:"queue_capacity"`
+	// Written and Failed count the writes to Kafka since startup.
+	Written int64 `json:"written"`
+	Failed  int64 `json:"failed"`
+	// LastWriteAt is the time of the last successful write.
+	LastWriteAt time.Time `json:"last_write_at"`
+	// LastError is the error of the last failed write, at LastErrorAt.
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at"`
+}
+
+// Utilization returns the fraction (0-1) of the queue capacity in use.
+func (h ProducerHealth) Utilization() float64 {
+	if h.QueueCapacity == 0 {
+		return 0
+	}
+	return float64(h.QueueLength) / float64(h.QueueCapacity)
+}
+
+// health tracks the outcome of the writes to Kafka.
+type health struct {
+	mu          sync.Mutex
+	written     int64
+	failed      int64
+	lastWriteAt time.Time
+	lastErr     error
+	lastErrAt   time.Time
+}
+
+// record notes the outcome of a write.
+func (h *health) record(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		h.failed++
+		h.lastErr, h.lastErrAt = err, time.Now()
+		return
+	}
+	h.written++
+	h.lastWriteAt = time.Now()
+}
+
+// Health returns the current queue utilization and write statistics.
+func (p *Producer) Health() ProducerHealth {
+	var status ProducerHealth
+	for _, queue := range p.queues {
+		status.QueueLength += len(queue)
+		status.QueueCapacity += cap(queue)
+	}
+
+	p.health.fill(&status)
+	return status
+}
+
+// fill copies the write statistics into status.
+func (h *health) fill(status *ProducerHealth) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	status.Written = h.written
+	status.Failed = h.failed
+	status.LastWriteAt = h.lastWriteAt
+	status.LastErrorAt = h.lastErrAt
+	if h.lastErr != nil {
+		status.LastError = h.lastErr.Error()
+	}
+}
+
+// String implements expvar.Var, publishing Health as JSON.
+func (p *Producer) String() string {
+	b, err := json.Marshal(p.Health())
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
+
+// Ping is a readiness check: it fails when no broker is reachable, or when
+// the queues are so full that new events are about to wait for space.
+func (p *Producer) Ping(ctx context.Context) error {
+	if status := p.Health(); status.Utilization() >= backpressureThreshold {
+		return fmt.Errorf("event queue %d/%d full", status.QueueLength, status.QueueCapacity)
+	}
+	return p.ping(ctx)
+}
+
+// dialBrokers returns a check connecting to each broker in turn until one
+// answers.
+func dialBrokers(brokers []string, dialer *kafka.Dialer) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, broker := range brokers {
+			conn, err := dialer.DialContext(ctx, "tcp", broker)
+			if err == nil {
+				return conn.Close()
+			}
+			errs = append(errs, err)
+		}
+		return fmt.Errorf("no Kafka broker reachable: %w", errors.Join(errs...))
+	}
+}
@@ -0,0 +1,103 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestProducer_Health(t *testing.T) {
+	mockWriter := new(MockKafkaWriter)
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil).Once()
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(errors.New("broker down")).Once()
+
+	producer := &Producer{writer: mockWriter, topic: "company_events", logger: zaptest.NewLogger(t)}
+	producer.queues = []chan Event{make(chan Event, 2), make(chan Event, 2)}
+	producer.queues[0] <- Event{}
+
+	event := Event{Type: CompanyCreated, Company: &models.Company{ID: uuid.New()}}
+	producer.sendEvent(context.Background(), event)
+	producer.sendEvent(context.Background(), event)
+
+	status := producer.Health()
+	assert.Equal(t, 1, status.QueueLength)
+	assert.Equal(t, 4, status.QueueCapacity)
+	assert.InDelta(t, 0.25, status.Utilization(), 1e-9)
+	assert.Equal(t, int64(1), status.Written)
+	assert.Equal(t, int64(1), status.Failed)
+	assert.Equal(t, "broker down", status.LastError)
+	assert.False(t, status.LastWriteAt.IsZero())
+	assert.False(t, status.LastErrorAt.IsZero())
+
+	var published ProducerHealth
+	require.NoError(t, json.Unmarshal([]byte(producer.String()), &published))
+	assert.Equal(t, "broker down", published.LastError)
+}
+
+func TestProducer_Ping(t *testing.T) {
+	reachable := true
+	producer := &Producer{ping: func(context.Context) error {
+		if !reachable {
+			return errors.New("no Kafka broker reachable")
+		}
+		return nil
+	}}
+	producer.queues = []chan Event{make(chan Event, 10)}
+
+	assert.NoError(t, producer.Ping(context.Background()))
+
+	reachable = false
+	assert.Error(t, producer.Ping(context.Background()))
+
+	reachable = true
+	for range 9 {
+		producer.queues[0] <- Event{}
+	}
+	assert.ErrorContains(t, producer.Ping(context.Background()), "9/10 full")
+}
+
+func TestProducer_ProduceContext(t *testing.T) {
+	mockWriter := new(MockKafkaWriter)
+	release := make(chan struct{})
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { <-release }).Return(nil)
+
+	producer := &Producer{
+		writer:    mockWriter,
+		logger:    zaptest.NewLogger(t),
+		closeChan: make(chan struct{}),
+	}
+	producer.startWorkers(1, 1)
+	company := &models.Company{ID: uuid.New()}
+
+	assert.NoError(t, producer.ProduceContext(context.Background(), Event{Type: CompanyCreated, Company: company}))
+	assert.Eventually(t, func() bool { return len(producer.queues[0]) == 0 }, time.Second, time.Millisecond)
+	assert.NoError(t, producer.ProduceContext(context.Background(), Event{Type: CompanyUpdated, Company: company}))
+
+	// The queue is full, so the deadline passes before the event is queued.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := producer.ProduceContext(ctx, Event{Type: CompanyDeleted, Company: company})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), time.Second)
+	defer cancelFlush()
+	require.NoError(t, producer.Flush(flushCtx))
+	assert.Equal(t, []EventType{CompanyCreated, CompanyUpdated}, writtenTypes(t, mockWriter, company.ID))
+
+	// After a flush, delivery failures are returned to the caller.
+	mockWriter.ExpectedCalls = nil
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(errors.New("kafka error"))
+	err = producer.ProduceContext(context.Background(), Event{Type: CompanyDeleted, Company: company})
+	assert.ErrorContains(t, err, "kafka error")
+}
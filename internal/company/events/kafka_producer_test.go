@@ -46,7 +46,7 @@ func TestNewProducer(t *testing.T) {
 	}
 
 	assert.NotNil(t, producer.writer)
-	assert.NotNil(t, producer.events)
+	assert.Len(t, producer.queues, defaultWorkers)
 	assert.NotNil(t, producer.closeChan)
 
 	// Check logger name safely
@@ -60,6 +60,7 @@ func TestProducer_SendEvent(t *testing.T) {
 
 	producer := &Producer{
 		writer: mockWriter,
+		topic:  "company_events",
 		logger: logger,
 	}
 
@@ -71,8 +72,14 @@ func TestProducer_SendEvent(t *testing.T) {
 
 		mockWriter.AssertCalled(t, "WriteMessages", mock.Anything, []kafka.Message{
 			{
+				Topic: "company_events",
 				Key:   []byte(company.ID.String()),
 				Value: mustMarshal(&event),
+				Headers: []kafka.Header{
+					{Key: EventTypeHeader, Value: []byte(CompanyCreated)},
+					{Key: ContentTypeHeader, Value: []byte(ContentTypeJSON)},
+					{Key: ProducerHeader, Value: []byte("company-service/dev")},
+				},
 			},
 		})
 	})
@@ -112,6 +119,110 @@ func TestProducer_SendEvent(t *testing.T) {
 	})
 }
 
+func TestProducer_Replay(t *testing.T) {
+	mockWriter := new(MockKafkaWriter)
+	producer := &Producer{writer: mockWriter, topic: "company_events", logger: zaptest.NewLogger(t)}
+	event := Event{EventID: uuid.New(), Type: CompanyUpdated, Company: &models.Company{ID: uuid.New()}}
+
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil).Once()
+	assert.NoError(t, producer.Replay(context.Background(), "company_events_rebuild", event))
+	mockWriter.AssertCalled(t, "WriteMessages", mock.Anything, []kafka.Message{
+		{
+			Topic: "company_events_rebuild",
+			Key:   []byte(event.Company.ID.String()),
+			Value: mustMarshal(&event),
+			Headers: []kafka.Header{
+				{Key: EventTypeHeader, Value: []byte(CompanyUpdated)},
+				{Key: ContentTypeHeader, Value: []byte(ContentTypeJSON)},
+				{Key: ProducerHeader, Value: []byte("company-service/dev")},
+			},
+		},
+	})
+
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(errors.New("kafka error")).Once()
+	assert.Error(t, producer.Replay(context.Background(), "company_events_rebuild", event))
+}
+
+func TestProducer_Publish(t *testing.T) {
+	mockWriter := new(MockKafkaWriter)
+	producer := &Producer{writer: mockWriter, topic: "company_events", logger: zaptest.NewLogger(t)}
+	event := Event{EventID: uuid.New(), Type: CompanyUpdated, Company: &models.Company{ID: uuid.New()}}
+
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil).Once()
+	assert.NoError(t, producer.Publish(context.Background(), event))
+	assert.Equal(t, []EventType{CompanyUpdated}, writtenTypes(t, mockWriter, event.Company.ID))
+
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(errors.New("kafka error")).Once()
+	assert.Error(t, producer.Publish(context.Background(), event), "delivery failures should be returned")
+
+	event.Suppressed = true
+	assert.NoError(t, producer.Publish(context.Background(), event))
+	mockWriter.AssertNumberOfCalls(t, "WriteMessages", 2)
+}
+
+func TestEventType_Valid(t *testing.T) {
+	assert.True(t, CompanyUpdated.Valid())
+	assert.False(t, EventType("company_renamed").Valid())
+}
+
+func TestProducer_TopicRouting(t *testing.T) {
+	tests := []struct {
+		name      string
+		strategy  TopicStrategy
+		eventType EventType
+		wantTopic string
+	}{
+		{"single topic created", SingleTopic, CompanyCreated, "company_events"},
+		{"single topic deleted", SingleTopic, CompanyDeleted, "company_events"},
+		{"per event created", TopicPerEvent, CompanyCreated, "company_created"},
+		{"per event updated", TopicPerEvent, CompanyUpdated, "company_updated"},
+		{"per event deleted", TopicPerEvent, CompanyDeleted, "company_deleted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockWriter := new(MockKafkaWriter)
+			mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+
+			producer := &Producer{
+				writer: mockWriter,
+				topic:  "company_events",
+				logger: zaptest.NewLogger(t),
+			}
+			WithTopicStrategy(tt.strategy)(producer)
+
+			producer.sendEvent(context.Background(), Event{Type: tt.eventType, Company: &models.Company{ID: uuid.New()}})
+
+			msgs := mockWriter.Calls[0].Arguments.Get(1).([]kafka.Message)
+			assert.Equal(t, tt.wantTopic, msgs[0].Topic)
+			assert.Equal(t, string(tt.eventType), headerValue(msgs[0], EventTypeHeader))
+		})
+	}
+}
+
+func TestProducer_Topics(t *testing.T) {
+	single := &Producer{topic: "company_events"}
+	assert.Equal(t, []string{"company_events"}, single.topics())
+
+	perEvent := &Producer{topic: "company_events", strategy: TopicPerEvent}
+	assert.Equal(t, []string{"company_created", "company_updated", "company_deleted", "company_status_changed", "company_archived", "company_enriched", "company_erased", "company_note_added", "company_note_deleted"}, perEvent.topics())
+}
+
+func TestParseTopicStrategy(t *testing.T) {
+	for value, want := range map[string]TopicStrategy{
+		"":          SingleTopic,
+		"single":    SingleTopic,
+		"per_event": TopicPerEvent,
+	} {
+		got, err := ParseTopicStrategy(value)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := ParseTopicStrategy("fanout")
+	assert.Error(t, err)
+}
+
 func TestProducer_Close(t *testing.T) {
 	mockWriter := new(MockKafkaWriter)
 	mockWriter.On("Close").Return(nil)
@@ -140,18 +251,18 @@ func TestProducer_EventLoop(t *testing.T) {
 
 	producer := &Producer{
 		writer: mockWriter,
-		events: make(chan Event, 1),
 		logger: zaptest.NewLogger(t),
 	}
+	queue := make(chan Event, 1)
 
 	company := &models.Company{ID: uuid.New()}
 	event := Event{Type: CompanyCreated, Company: company}
 
 	// Start event loop
-	go producer.eventLoop()
+	go producer.eventLoop(queue)
 
 	// Send event
-	producer.events <- event
+	queue <- event
 
 	// Give time for processing
 	time.Sleep(100 * time.Millisecond)
@@ -159,6 +270,175 @@ func TestProducer_EventLoop(t *testing.T) {
 	mockWriter.AssertCalled(t, "WriteMessages", mock.Anything, mock.Anything)
 }
 
+func TestProducer_Flush(t *testing.T) {
+	mockWriter := new(MockKafkaWriter)
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+
+	producer := &Producer{
+		writer:    mockWriter,
+		logger:    zaptest.NewLogger(t),
+		closeChan: make(chan struct{}),
+	}
+	producer.startWorkers(2, 10)
+
+	for i := 0; i < 5; i++ {
+		producer.Produce(Event{Type: CompanyCreated, Company: &models.Company{ID: uuid.New()}})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, producer.Flush(ctx))
+	mockWriter.AssertNumberOfCalls(t, "WriteMessages", 5)
+
+	// Events produced after a flush are written synchronously.
+	producer.Produce(Event{Type: CompanyUpdated, Company: &models.Company{ID: uuid.New()}})
+	mockWriter.AssertNumberOfCalls(t, "WriteMessages", 6)
+
+	// Flushing twice is safe.
+	assert.NoError(t, producer.Flush(ctx))
+}
+
+// TestProducer_DropsFailedWrites verifies queued events whose write fails
+// are logged, counted and dropped, never retried, while later events of the
+// same company are still written: without the outbox, delivery is best
+// effort.
+func TestProducer_DropsFailedWrites(t *testing.T) {
+	company := &models.Company{ID: uuid.New()}
+	failed := Event{EventID: uuid.New(), Type: CompanyCreated, Company: company}
+	next := Event{EventID: uuid.New(), Type: CompanyUpdated, Company: company}
+
+	mockWriter := new(MockKafkaWriter)
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(errors.New("kafka error")).Once()
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+	core, recorded := observer.New(zap.ErrorLevel)
+	producer := &Producer{
+		writer:    mockWriter,
+		logger:    zap.New(core),
+		closeChan: make(chan struct{}),
+	}
+	producer.startWorkers(1, 10)
+
+	producer.Produce(failed)
+	producer.Produce(next)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, producer.Flush(ctx), "a failed write is not reported to the caller")
+
+	mockWriter.AssertNumberOfCalls(t, "WriteMessages", 2)
+	written := mockWriter.Calls[1].Arguments.Get(1).([]kafka.Message)
+	var event Event
+	assert.NoError(t, json.Unmarshal(written[0].Value, &event))
+	assert.Equal(t, next.EventID, event.EventID, "the failed event should not be retried")
+	assert.Equal(t, 1, recorded.FilterMessage("Failed to produce event").Len())
+	assert.Equal(t, int64(1), producer.Health().Failed)
+}
+
+func TestProducer_ProduceQueueFull(t *testing.T) {
+	mockWriter := new(MockKafkaWriter)
+	release := make(chan struct{})
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { <-release }).Return(nil)
+
+	core, recorded := observer.New(zap.WarnLevel)
+	producer := &Producer{
+		writer:    mockWriter,
+		logger:    zap.New(core),
+		closeChan: make(chan struct{}),
+	}
+	producer.startWorkers(1, 1)
+	company := &models.Company{ID: uuid.New()}
+
+	// The worker blocks on the first event and the second fills the queue,
+	// so the third must wait for space.
+	producer.Produce(Event{Type: CompanyCreated, Company: company})
+	assert.Eventually(t, func() bool { return len(producer.queues[0]) == 0 }, time.Second, time.Millisecond)
+	producer.Produce(Event{Type: CompanyUpdated, Company: company})
+	produced := make(chan struct{})
+	go func() {
+		producer.Produce(Event{Type: CompanyDeleted, Company: company})
+		close(produced)
+	}()
+
+	select {
+	case <-produced:
+		t.Fatal("Produce should wait while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-produced
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, producer.Flush(ctx))
+	assert.Equal(t, []EventType{CompanyCreated, CompanyUpdated, CompanyDeleted}, writtenTypes(t, mockWriter, company.ID))
+	assert.Equal(t, 1, recorded.FilterMessage("Kafka producer queue full, waiting for space").Len())
+}
+
+func TestProducer_OrderPerCompany(t *testing.T) {
+	mockWriter := new(MockKafkaWriter)
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+
+	producer := &Producer{
+		writer:    mockWriter,
+		logger:    zaptest.NewLogger(t),
+		closeChan: make(chan struct{}),
+	}
+	// Tiny queues make Produce wait for space, exercising both paths.
+	producer.startWorkers(defaultWorkers, 2)
+
+	companies := make([]*models.Company, 50)
+	for i := range companies {
+		companies[i] = &models.Company{ID: uuid.New()}
+	}
+	burst := []EventType{CompanyCreated, CompanyUpdated, CompanyStatusChanged, CompanyUpdated, CompanyDeleted}
+	for _, eventType := range burst {
+		for _, company := range companies {
+			producer.Produce(Event{Type: eventType, Company: company})
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, producer.Flush(ctx))
+	for _, company := range companies {
+		assert.Equal(t, burst, writtenTypes(t, mockWriter, company.ID))
+	}
+
+	// Events produced after the flush follow the delivered ones.
+	producer.Produce(Event{Type: CompanyCreated, Company: companies[0]})
+	types := writtenTypes(t, mockWriter, companies[0].ID)
+	assert.Equal(t, CompanyCreated, types[len(types)-1])
+}
+
+func TestProducer_QueueFor(t *testing.T) {
+	producer := &Producer{}
+	producer.queues = []chan Event{make(chan Event), make(chan Event), make(chan Event)}
+
+	id := uuid.New()
+	assert.Equal(t, producer.queueFor(id), producer.queueFor(id), "a company should always use the same queue")
+
+	used := make(map[chan Event]bool)
+	for range 100 {
+		used[producer.queueFor(uuid.New())] = true
+	}
+	assert.Len(t, used, 3, "companies should be spread over every queue")
+}
+
+// writtenTypes returns the types of the events written for companyID, in
+// the order they were written.
+func writtenTypes(t *testing.T, writer *MockKafkaWriter, companyID uuid.UUID) []EventType {
+	t.Helper()
+	var types []EventType
+	for _, call := range writer.Calls {
+		for _, msg := range call.Arguments.Get(1).([]kafka.Message) {
+			if string(msg.Key) == companyID.String() {
+				types = append(types, EventType(headerValue(msg, EventTypeHeader)))
+			}
+		}
+	}
+	return types
+}
+
 func mustMarshal(c *Event) []byte {
 	data, _ := json.Marshal(c)
 	return data
@@ -0,0 +1,106 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// ErrNotConnected is returned by a StandbyProducer that has no Producer yet.
+var ErrNotConnected = errors.New("kafka producer not connected")
+
+// StandbyProducer stands in for a Producer that is still connecting, so the
+// company service can start, serving reads, while Kafka is unreachable.
+// Once Connect hands it the Producer, every call is forwarded to it; until
+// then events are dropped and the other calls fail with ErrNotConnected.
+type StandbyProducer struct {
+	mu       sync.RWMutex
+	producer *Producer
+	logger   *zap.Logger
+}
+
+// NewStandbyProducer returns a StandbyProducer without a Producer.
+func NewStandbyProducer(logger *zap.Logger) *StandbyProducer {
+	return &StandbyProducer{logger: logger}
+}
+
+// Connect makes s forward to producer.
+func (s *StandbyProducer) Connect(producer *Producer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.producer = producer
+}
+
+// Connected reports whether s has a Producer.
+func (s *StandbyProducer) Connected() bool {
+	return s.get() != nil
+}
+
+func (s *StandbyProducer) get() *Producer {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.producer
+}
+
+// Produce forwards to Producer.Produce, or logs and drops the event.
+func (s *StandbyProducer) Produce(event Event) {
+	if p := s.get(); p != nil {
+		p.Produce(event)
+		return
+	}
+	s.logger.Error("Dropping event, Kafka producer not connected",
+		zap.String("event_type", string(event.Type)),
+		zap.String("company_id", event.Company.ID.String()),
+	)
+}
+
+// Replay forwards to Producer.Replay.
+func (s *StandbyProducer) Replay(ctx context.Context, topic string, event Event) error {
+	if p := s.get(); p != nil {
+		return p.Replay(ctx, topic, event)
+	}
+	return ErrNotConnected
+}
+
+// Publish forwards to Producer.Publish.
+func (s *StandbyProducer) Publish(ctx context.Context, event Event) error {
+	if p := s.get(); p != nil {
+		return p.Publish(ctx, event)
+	}
+	return ErrNotConnected
+}
+
+// Ping forwards to Producer.Ping.
+func (s *StandbyProducer) Ping(ctx context.Context) error {
+	if p := s.get(); p != nil {
+		return p.Ping(ctx)
+	}
+	return ErrNotConnected
+}
+
+// Flush forwards to Producer.Flush. Without a Producer there is nothing to
+// flush.
+func (s *StandbyProducer) Flush(ctx context.Context) error {
+	if p := s.get(); p != nil {
+		return p.Flush(ctx)
+	}
+	return nil
+}
+
+// Close forwards to Producer.Close.
+func (s *StandbyProducer) Close() {
+	if p := s.get(); p != nil {
+		p.Close()
+	}
+}
+
+// String implements expvar.Var, publishing the Producer's health, or
+// {"connected":false}.
+func (s *StandbyProducer) String() string {
+	if p := s.get(); p != nil {
+		return p.String()
+	}
+	return `{"connected":false}`
+}
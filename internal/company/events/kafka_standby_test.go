@@ -0,0 +1,36 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestStandbyProducer(t *testing.T) {
+	standby := NewStandbyProducer(zaptest.NewLogger(t))
+	event := Event{Type: CompanyCreated, Company: &models.Company{ID: uuid.New()}}
+
+	assert.False(t, standby.Connected())
+	standby.Produce(event)
+	assert.ErrorIs(t, standby.Replay(context.Background(), "rebuild", event), ErrNotConnected)
+	assert.ErrorIs(t, standby.Publish(context.Background(), event), ErrNotConnected)
+	assert.ErrorIs(t, standby.Ping(context.Background()), ErrNotConnected)
+	assert.NoError(t, standby.Flush(context.Background()))
+	assert.JSONEq(t, `{"connected":false}`, standby.String())
+	standby.Close()
+
+	mockWriter := new(MockKafkaWriter)
+	mockWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+	standby.Connect(&Producer{writer: mockWriter, topic: "company_events", logger: zaptest.NewLogger(t)})
+
+	assert.True(t, standby.Connected())
+	assert.NoError(t, standby.Replay(context.Background(), "rebuild", event))
+	assert.NoError(t, standby.Publish(context.Background(), event))
+	mockWriter.AssertNumberOfCalls(t, "WriteMessages", 2)
+	assert.Contains(t, standby.String(), `"written":2`)
+}
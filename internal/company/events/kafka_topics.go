@@ -0,0 +1,198 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// topicSetupTimeout bounds how long NewProducer spends provisioning topics.
+const topicSetupTimeout = 30 * time.Second
+
+// topicCheckAttempts and topicCheckDelay bound how often the topic metadata
+// is read before giving up; the delay doubles after every attempt. They are
+// variables so tests can shorten them.
+var (
+	topicCheckAttempts = 6
+	topicCheckDelay    = 250 * time.Millisecond
+)
+
+// ErrTopicMissing is returned by NewProducer when a topic it writes to does
+// not exist and is not created. Retrying does not help until an operator
+// creates the topic.
+var ErrTopicMissing = errors.New("kafka topic does not exist")
+
+// TopicSettings controls how NewProducer provisions the topics it writes to.
+type TopicSettings struct {
+	// AutoCreate creates missing topics on startup. When it is false, or
+	// the cluster does not allow clients to create topics, the topics must
+	// already exist.
+	AutoCreate bool
+	// Partitions and ReplicationFactor apply to created topics.
+	Partitions        int
+	ReplicationFactor int
+	// Retention sets retention.ms on created topics; 0 keeps the broker
+	// default.
+	Retention time.Duration
+}
+
+// DefaultTopicSettings creates missing topics with 3 partitions and a
+// replication factor of 1, suitable for a single local broker.
+func DefaultTopicSettings() TopicSettings {
+	return TopicSettings{AutoCreate: true, Partitions: 3, ReplicationFactor: 1}
+}
+
+// WithTopicSettings overrides DefaultTopicSettings.
+func WithTopicSettings(settings TopicSettings) ProducerOption {
+	return func(p *Producer) {
+		p.topicSettings = settings
+	}
+}
+
+// TopicClient is the subset of kafka.Client used to provision topics.
+type TopicClient interface {
+	Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error)
+	CreateTopics(ctx context.Context, req *kafka.CreateTopicsRequest) (*kafka.CreateTopicsResponse, error)
+}
+
+// ensureTopics makes sure every one of topics exists. With AutoCreate the
+// missing ones are created; if the cluster refuses, as managed clusters
+// usually do, creation is skipped and the topics are only checked. Either
+// way the topics are then verified in the cluster metadata.
+func ensureTopics(ctx context.Context, client TopicClient, topics []string, settings TopicSettings, logger *zap.Logger) error {
+	if !settings.AutoCreate {
+		if err := waitForTopics(ctx, client, topics, false); err != nil {
+			return fmt.Errorf("%w; automatic topic creation is disabled", err)
+		}
+		return nil
+	}
+	created, err := createTopics(ctx, client, topics, settings)
+	if err != nil {
+		return err
+	}
+	if created {
+		if err := waitForTopics(ctx, client, topics, true); err != nil {
+			// The topics were just created, so a retry may well succeed:
+			// %v keeps ErrTopicMissing out of the chain.
+			return fmt.Errorf("created topics %v are not available: %v", topics, err)
+		}
+		return nil
+	}
+	logger.Warn("Kafka cluster does not allow creating topics, expecting them to exist",
+		zap.Strings("topics", topics))
+	if err := waitForTopics(ctx, client, topics, false); err != nil {
+		return fmt.Errorf("%w; the cluster does not allow creating topics", err)
+	}
+	return nil
+}
+
+// createTopics creates the missing topics, returning false when the cluster
+// does not allow it.
+func createTopics(ctx context.Context, client TopicClient, topics []string, settings TopicSettings) (bool, error) {
+	if settings.Partitions <= 0 || settings.ReplicationFactor <= 0 {
+		return false, fmt.Errorf("invalid topic settings: %d partitions, replication factor %d", settings.Partitions, settings.ReplicationFactor)
+	}
+	var entries []kafka.ConfigEntry
+	if settings.Retention > 0 {
+		entries = append(entries, kafka.ConfigEntry{
+			ConfigName:  "retention.ms",
+			ConfigValue: strconv.FormatInt(settings.Retention.Milliseconds(), 10),
+		})
+	}
+	req := &kafka.CreateTopicsRequest{}
+	for _, name := range topics {
+		req.Topics = append(req.Topics, kafka.TopicConfig{
+			Topic:             name,
+			NumPartitions:     settings.Partitions,
+			ReplicationFactor: settings.ReplicationFactor,
+			ConfigEntries:     entries,
+		})
+	}
+
+	resp, err := client.CreateTopics(ctx, req)
+	if err != nil {
+		return false, fmt.Errorf("failed to create topics %v: %w", topics, err)
+	}
+	for _, name := range topics {
+		err := resp.Errors[name]
+		switch {
+		case err == nil, errors.Is(err, kafka.TopicAlreadyExists):
+		case notAllowed(err):
+			return false, nil
+		default:
+			return false, fmt.Errorf("failed to create topic %q: %w", name, err)
+		}
+	}
+	return true, nil
+}
+
+// notAllowed reports whether err is a broker refusing to create topics.
+func notAllowed(err error) bool {
+	return errors.Is(err, kafka.TopicAuthorizationFailed) ||
+		errors.Is(err, kafka.ClusterAuthorizationFailed) ||
+		errors.Is(err, kafka.PolicyViolation)
+}
+
+// waitForTopics checks topics until all of them are available, retrying
+// failed metadata requests and temporary topic errors such as a leader
+// that is still being elected. A topic unknown to the cluster is only
+// retried when created is set, as brokers learn about new topics
+// asynchronously; otherwise it fails at once with ErrTopicMissing.
+func waitForTopics(ctx context.Context, client TopicClient, topics []string, created bool) error {
+	delay := topicCheckDelay
+	for attempt := 1; ; attempt++ {
+		err := checkTopics(ctx, client, topics)
+		if err == nil || attempt >= topicCheckAttempts || !retryTopicCheck(err, created) {
+			return err
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// retryTopicCheck reports whether a failed checkTopics may succeed later.
+func retryTopicCheck(err error, created bool) bool {
+	if errors.Is(err, ErrTopicMissing) {
+		return created
+	}
+	var kafkaErr kafka.Error
+	if errors.As(err, &kafkaErr) {
+		return kafkaErr.Temporary()
+	}
+	// The metadata request itself failed.
+	return true
+}
+
+// checkTopics returns an error naming the first of topics that does not
+// exist or is not available.
+func checkTopics(ctx context.Context, client TopicClient, topics []string) error {
+	resp, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return fmt.Errorf("failed to read topic metadata: %w", err)
+	}
+	found := make(map[string]error, len(resp.Topics))
+	for _, topic := range resp.Topics {
+		found[topic.Name] = topic.Error
+	}
+	for _, name := range topics {
+		err, ok := found[name]
+		switch {
+		case !ok, errors.Is(err, kafka.UnknownTopicOrPartition):
+			return fmt.Errorf("%w: %q", ErrTopicMissing, name)
+		case err != nil:
+			return fmt.Errorf("topic %q: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeTopicClient knows the topics in existing and answers creation
+// requests with createErr, or with the per-topic errors in topicErrs.
+// Created topics show up in the metadata after lag metadata requests, and
+// the first metadata requests fail with the errors in metadataErrs.
+type fakeTopicClient struct {
+	existing     map[string]bool
+	createErr    error
+	topicErrs    map[string]error
+	created      []kafka.TopicConfig
+	lag          int
+	metadataErrs []error
+	metadataReqs int
+}
+
+func (f *fakeTopicClient) Metadata(_ context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error) {
+	f.metadataReqs++
+	if len(f.metadataErrs) > 0 {
+		err := f.metadataErrs[0]
+		f.metadataErrs = f.metadataErrs[1:]
+		return nil, err
+	}
+	resp := &kafka.MetadataResponse{}
+	for _, name := range req.Topics {
+		topic := kafka.Topic{Name: name}
+		if !f.existing[name] && !(f.isCreated(name) && f.metadataReqs > f.lag) {
+			topic.Error = kafka.UnknownTopicOrPartition
+		}
+		resp.Topics = append(resp.Topics, topic)
+	}
+	return resp, nil
+}
+
+func (f *fakeTopicClient) isCreated(name string) bool {
+	for _, topic := range f.created {
+		if topic.Topic == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeTopicClient) CreateTopics(_ context.Context, req *kafka.CreateTopicsRequest) (*kafka.CreateTopicsResponse, error) {
+	if f.createErr != nil {
+		return nil, f.createErr
+	}
+	resp := &kafka.CreateTopicsResponse{Errors: map[string]error{}}
+	for _, topic := range req.Topics {
+		if err, ok := f.topicErrs[topic.Topic]; ok {
+			resp.Errors[topic.Topic] = err
+			continue
+		}
+		f.created = append(f.created, topic)
+		resp.Errors[topic.Topic] = nil
+	}
+	return resp, nil
+}
+
+// fastTopicChecks shortens the waits between topic metadata checks.
+func fastTopicChecks(t *testing.T) {
+	delay := topicCheckDelay
+	topicCheckDelay = time.Millisecond
+	t.Cleanup(func() { topicCheckDelay = delay })
+}
+
+func TestEnsureTopics_Create(t *testing.T) {
+	fastTopicChecks(t)
+	client := &fakeTopicClient{
+		existing:  map[string]bool{"company_updated": true},
+		topicErrs: map[string]error{"company_updated": kafka.TopicAlreadyExists},
+		lag:       2,
+	}
+	settings := TopicSettings{AutoCreate: true, Partitions: 6, ReplicationFactor: 3, Retention: 7 * 24 * time.Hour}
+
+	err := ensureTopics(context.Background(), client, []string{"company_created", "company_updated"}, settings, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	require.Len(t, client.created, 1)
+	assert.Equal(t, kafka.TopicConfig{
+		Topic:             "company_created",
+		NumPartitions:     6,
+		ReplicationFactor: 3,
+		ConfigEntries:     []kafka.ConfigEntry{{ConfigName: "retention.ms", ConfigValue: "604800000"}},
+	}, client.created[0])
+	// The created topic is waited for until the brokers know it.
+	assert.Equal(t, 3, client.metadataReqs)
+
+	// A topic that never shows up fails startup, but as a retryable error.
+	client = &fakeTopicClient{lag: topicCheckAttempts}
+	err = ensureTopics(context.Background(), client, []string{"company_events"}, DefaultTopicSettings(), zaptest.NewLogger(t))
+	assert.ErrorContains(t, err, `created topics [company_events] are not available: kafka topic does not exist: "company_events"`)
+	assert.NotErrorIs(t, err, ErrTopicMissing)
+	assert.Equal(t, topicCheckAttempts, client.metadataReqs)
+}
+
+func TestEnsureTopics_TransientMetadataErrors(t *testing.T) {
+	fastTopicChecks(t)
+	client := &fakeTopicClient{
+		existing:     map[string]bool{"company_events": true},
+		metadataErrs: []error{errors.New("connection reset"), kafka.LeaderNotAvailable},
+	}
+	require.NoError(t, ensureTopics(context.Background(), client, []string{"company_events"}, TopicSettings{}, zaptest.NewLogger(t)))
+	assert.Equal(t, 3, client.metadataReqs)
+
+	client = &fakeTopicClient{metadataErrs: []error{kafka.TopicAuthorizationFailed}}
+	err := ensureTopics(context.Background(), client, []string{"company_events"}, TopicSettings{}, zaptest.NewLogger(t))
+	assert.ErrorIs(t, err, kafka.TopicAuthorizationFailed)
+	assert.Equal(t, 1, client.metadataReqs)
+}
+
+func TestEnsureTopics_CreationNotAllowed(t *testing.T) {
+	client := &fakeTopicClient{
+		existing:  map[string]bool{"company_events": true},
+		topicErrs: map[string]error{"company_events": kafka.TopicAuthorizationFailed},
+	}
+	settings := DefaultTopicSettings()
+
+	assert.NoError(t, ensureTopics(context.Background(), client, []string{"company_events"}, settings, zaptest.NewLogger(t)))
+
+	client.existing = nil
+	err := ensureTopics(context.Background(), client, []string{"company_events"}, settings, zaptest.NewLogger(t))
+	assert.EqualError(t, err, `kafka topic does not exist: "company_events"; the cluster does not allow creating topics`)
+	assert.ErrorIs(t, err, ErrTopicMissing)
+}
+
+func TestEnsureTopics_AutoCreateDisabled(t *testing.T) {
+	client := &fakeTopicClient{existing: map[string]bool{"company_events": true}}
+	settings := TopicSettings{}
+
+	assert.NoError(t, ensureTopics(context.Background(), client, []string{"company_events"}, settings, zaptest.NewLogger(t)))
+	assert.Empty(t, client.created)
+
+	err := ensureTopics(context.Background(), client, []string{"company_events", "company_audit"}, settings, zaptest.NewLogger(t))
+	assert.EqualError(t, err, `kafka topic does not exist: "company_audit"; automatic topic creation is disabled`)
+	assert.ErrorIs(t, err, ErrTopicMissing)
+	// A missing topic is not retried when nothing creates it.
+	assert.Equal(t, 2, client.metadataReqs)
+}
+
+func TestEnsureTopics_Errors(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	client := &fakeTopicClient{createErr: errors.New("connection refused")}
+	err := ensureTopics(context.Background(), client, []string{"company_events"}, DefaultTopicSettings(), logger)
+	assert.ErrorContains(t, err, "failed to create topics [company_events]: connection refused")
+
+	client = &fakeTopicClient{topicErrs: map[string]error{"company_events": kafka.InvalidReplicationFactor}}
+	err = ensureTopics(context.Background(), client, []string{"company_events"}, DefaultTopicSettings(), logger)
+	assert.ErrorIs(t, err, kafka.InvalidReplicationFactor)
+
+	err = ensureTopics(context.Background(), &fakeTopicClient{}, []string{"company_events"}, TopicSettings{AutoCreate: true}, logger)
+	assert.ErrorContains(t, err, "invalid topic settings")
+}
@@ -0,0 +1,98 @@
+package events
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// writeBackoffMax is the longest wait between two attempts of a write.
+const writeBackoffMax = time.Second
+
+// leaderElectionWindow is how long the cluster is given to elect a new
+// leader for the partitions of a broker that went away.
+const leaderElectionWindow = 10 * time.Second
+
+// TransportSettings controls how the producer connects to the brokers.
+type TransportSettings struct {
+	// DialTimeout bounds establishing a connection to a broker.
+	DialTimeout time.Duration
+	// MetadataTTL is how long the cluster metadata, such as the leader of
+	// each partition, is cached before it is read again.
+	MetadataTTL time.Duration
+	// TLS encrypts the broker connections; nil connects in plaintext.
+	TLS *tls.Config
+}
+
+// DefaultTransportSettings connects in plaintext with kafka-go's default
+// timeouts.
+func DefaultTransportSettings() TransportSettings {
+	return TransportSettings{DialTimeout: 5 * time.Second, MetadataTTL: 6 * time.Second}
+}
+
+// WithTransport overrides DefaultTransportSettings. Non-positive durations
+// keep their defaults.
+func WithTransport(settings TransportSettings) ProducerOption {
+	return func(p *Producer) {
+		defaults := DefaultTransportSettings()
+		if settings.DialTimeout <= 0 {
+			settings.DialTimeout = defaults.DialTimeout
+		}
+		if settings.MetadataTTL <= 0 {
+			settings.MetadataTTL = defaults.MetadataTTL
+		}
+		p.transport = settings
+	}
+}
+
+// roundTripper returns the transport shared by the writer and the topic
+// client.
+func (s TransportSettings) roundTripper() *kafka.Transport {
+	return &kafka.Transport{
+		DialTimeout: s.DialTimeout,
+		MetadataTTL: s.MetadataTTL,
+		TLS:         s.TLS,
+		ClientID:    "company-service",
+	}
+}
+
+// dialer returns the dialer used to check that a broker is reachable.
+func (s TransportSettings) dialer() *kafka.Dialer {
+	return &kafka.Dialer{Timeout: s.DialTimeout, DualStack: true, TLS: s.TLS}
+}
+
+// writeAttempts returns how often a write is attempted before it fails.
+// When a broker goes away, writes to the partitions it led fail until the
+// cluster elected new leaders and the cached metadata expired, so the
+// attempts span both, at one attempt per writeBackoffMax.
+func (s TransportSettings) writeAttempts() int {
+	return int((leaderElectionWindow+2*s.MetadataTTL)/writeBackoffMax) + 1
+}
+
+// ParseTLSVersions converts configured TLS versions ("1.2", "1.3") into the
+// lowest and highest of them, as used for tls.Config's MinVersion and
+// MaxVersion.
+func ParseTLSVersions(names []string) (minVersion, maxVersion uint16, err error) {
+	if len(names) == 0 {
+		return 0, 0, errors.New("no TLS versions given")
+	}
+	for _, name := range names {
+		var version uint16
+		switch name {
+		case "1.2":
+			version = tls.VersionTLS12
+		case "1.3":
+			version = tls.VersionTLS13
+		default:
+			return 0, 0, fmt.Errorf("unsupported TLS version %q", name)
+		}
+		if minVersion == 0 || version < minVersion {
+			minVersion = version
+		}
+		maxVersion = max(maxVersion, version)
+	}
+	return minVersion, maxVersion, nil
+}
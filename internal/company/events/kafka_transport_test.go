@@ -0,0 +1,49 @@
+package events
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTransport(t *testing.T) {
+	p := &Producer{}
+	WithTransport(TransportSettings{MetadataTTL: 2 * time.Second})(p)
+	assert.Equal(t, 5*time.Second, p.transport.DialTimeout)
+	assert.Equal(t, 2*time.Second, p.transport.MetadataTTL)
+
+	transport := p.transport.roundTripper()
+	assert.Equal(t, 5*time.Second, transport.DialTimeout)
+	assert.Equal(t, 2*time.Second, transport.MetadataTTL)
+	assert.Nil(t, transport.TLS)
+}
+
+func TestTransportSettings_WriteAttempts(t *testing.T) {
+	// The retries outlast a leader election plus the metadata going stale
+	// twice, even at the longest backoff.
+	for _, ttl := range []time.Duration{time.Second, 6 * time.Second, time.Minute} {
+		settings := TransportSettings{MetadataTTL: ttl}
+		window := time.Duration(settings.writeAttempts()) * writeBackoffMax
+		assert.Greater(t, window, leaderElectionWindow+2*ttl, "metadata TTL %v", ttl)
+	}
+}
+
+func TestParseTLSVersions(t *testing.T) {
+	minVersion, maxVersion, err := ParseTLSVersions([]string{"1.3", "1.2"})
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), minVersion)
+	assert.Equal(t, uint16(tls.VersionTLS13), maxVersion)
+
+	minVersion, maxVersion, err = ParseTLSVersions([]string{"1.3"})
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), minVersion)
+	assert.Equal(t, uint16(tls.VersionTLS13), maxVersion)
+
+	_, _, err = ParseTLSVersions([]string{"1.2", "1.1"})
+	assert.EqualError(t, err, `unsupported TLS version "1.1"`)
+	_, _, err = ParseTLSVersions(nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,84 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/models"
+)
+
+// columns are the columns of the data files. Contact emails are personal
+// data and are left out. deleted_at is set for the companies an incremental
+// export holds because they were deleted.
+var columns = []string{
+	"id", "name", "description", "employees", "employee_range", "registered",
+	"status", "type", "external_ref", "metadata", "created_by", "updated_by",
+	"created_at", "updated_at", "erased_at", "deleted_at",
+}
+
+// csvWriter writes companies as CSV rows under a header of columns.
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+// write adds the row of company. Times are RFC 3339 in UTC and metadata is a
+// JSON object; unset values are empty.
+func (c *csvWriter) write(company *models.Company) error {
+	if !c.header {
+		if err := c.w.Write(columns); err != nil {
+			return err
+		}
+		c.header = true
+	}
+	metadata := ""
+	if len(company.Metadata) > 0 {
+		b, err := json.Marshal(company.Metadata)
+		if err != nil {
+			return err
+		}
+		metadata = string(b)
+	}
+	var erasedAt, deletedAt string
+	if company.ErasedAt != nil {
+		erasedAt = formatTime(*company.ErasedAt)
+	}
+	if company.DeletedAt.Valid {
+		deletedAt = formatTime(company.DeletedAt.Time)
+	}
+	return c.w.Write([]string{
+		company.ID.String(),
+		company.Name,
+		company.Description,
+		strconv.Itoa(company.Employees),
+		string(company.EmployeeRange),
+		strconv.FormatBool(company.Registered),
+		string(company.Status),
+		string(company.Type),
+		company.ExternalRef,
+		metadata,
+		company.CreatedBy,
+		company.UpdatedBy,
+		formatTime(company.CreatedAt),
+		formatTime(company.UpdatedAt),
+		erasedAt,
+		deletedAt,
+	})
+}
+
+// flush writes buffered rows to the underlying writer.
+func (c *csvWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
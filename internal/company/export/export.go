@@ -0,0 +1,278 @@
+// Package export writes snapshots of the companies to object storage for
+// analytics pipelines: full exports of every live company, or incremental
+// ones holding the companies changed since the previous export. An export is
+// a directory of gzipped CSV files and a manifest listing them, written last
+// so that its presence marks the export complete.
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/pkg/leader"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultRowsPerFile bounds the companies per data file.
+	defaultRowsPerFile = 100000
+	// settleDelay is how far before its start an export ends, so that
+	// changes of transactions still committing are picked up by the next
+	// incremental export rather than missed.
+	settleDelay = time.Minute
+	// dirTimeFormat names export directories after their end.
+	dirTimeFormat = "20060102T150405Z"
+)
+
+// ErrInProgress is returned when another export, possibly on another
+// instance of the service, is running.
+var ErrInProgress = errors.New("another export is in progress")
+
+// Source reads the companies to export and records completed exports;
+// *db.Repository implements it.
+type Source interface {
+	ForEachExportedCompany(ctx context.Context, since *time.Time, until time.Time, fn func(*models.Company) error) error
+	LastExportRun(ctx context.Context) (*models.ExportRun, error)
+	CreateExportRun(ctx context.Context, run *models.ExportRun) error
+}
+
+// ObjectStore stores the exported files.
+type ObjectStore interface {
+	// Put stores body under key, replacing any object stored there.
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+	// URL returns the URL of the object stored under key, e.g.
+	// s3://bucket/key.
+	URL(key string) string
+}
+
+// Manifest lists the files of an export. It is stored as manifest.json
+// next to them.
+type Manifest struct {
+	ID          uuid.UUID         `json:"id"`
+	Kind        models.ExportKind `json:"kind"`
+	Format      string            `json:"format"`
+	Compression string            `json:"compression"`
+	Columns     []string          `json:"columns"`
+	Since       *time.Time        `json:"since,omitempty"`
+	Until       time.Time         `json:"until"`
+	Companies   int64             `json:"companies"`
+	Files       []ManifestFile    `json:"files"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// ManifestFile describes a data file of an export.
+type ManifestFile struct {
+	// Key is the object key of the file.
+	Key    string `json:"key"`
+	Rows   int    `json:"rows"`
+	Bytes  int    `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// Exporter writes exports to an ObjectStore. Exports are serialized across
+// the instances of the service through an advisory lock.
+type Exporter struct {
+	source      Source
+	locker      leader.Locker
+	store       ObjectStore
+	prefix      string
+	rowsPerFile int
+	logger      *zap.Logger
+	now         func() time.Time
+}
+
+// Option configures an Exporter.
+type Option func(*Exporter)
+
+// WithPrefix stores the exports under prefix, "companies" by default.
+func WithPrefix(prefix string) Option {
+	return func(x *Exporter) {
+		x.prefix = strings.Trim(prefix, "/")
+	}
+}
+
+// WithRowsPerFile bounds the companies per data file; n <= 0 keeps the
+// default of 100000.
+func WithRowsPerFile(n int) Option {
+	return func(x *Exporter) {
+		if n > 0 {
+			x.rowsPerFile = n
+		}
+	}
+}
+
+// New returns an Exporter reading from source and writing to store.
+func New(source Source, locker leader.Locker, store ObjectStore, logger *zap.Logger, opts ...Option) *Exporter {
+	x := &Exporter{
+		source:      source,
+		locker:      locker,
+		store:       store,
+		prefix:      "companies",
+		rowsPerFile: defaultRowsPerFile,
+		logger:      logger.Named("export"),
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(x)
+	}
+	return x
+}
+
+// lockKey is the advisory lock key serializing exports.
+var lockKey = leader.Key("xm.export")
+
+// Export writes an export of the given kind and records it. An incremental
+// export holds the changes since the end of the previous export; with none
+// before it, it is full. Files are written under
+// <prefix>/<kind>/<end>/ as part-00000.csv.gz and so on, followed by
+// manifest.json. It fails with ErrInProgress while another export runs.
+func (x *Exporter) Export(ctx context.Context, kind models.ExportKind) (*models.ExportRun, error) {
+	var run *models.ExportRun
+	ran, err := leader.Do(ctx, x.locker, lockKey, func(ctx context.Context) error {
+		var err error
+		run, err = x.export(ctx, kind)
+		return err
+	})
+	switch {
+	case err != nil && !ran:
+		return nil, fmt.Errorf("failed to take export lock: %w", err)
+	case !ran:
+		return nil, ErrInProgress
+	}
+	return run, err
+}
+
+// export writes an export of the given kind while holding the export lock.
+func (x *Exporter) export(ctx context.Context, kind models.ExportKind) (*models.ExportRun, error) {
+	run := &models.ExportRun{
+		ID:    uuid.New(),
+		Kind:  models.ExportFull,
+		Until: x.now().Add(-settleDelay).UTC().Truncate(time.Microsecond),
+	}
+	if kind == models.ExportIncremental {
+		last, err := x.source.LastExportRun(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the last export: %w", err)
+		}
+		if last != nil {
+			since := last.Until
+			run.Kind, run.Since = models.ExportIncremental, &since
+		}
+	}
+	if id, ok := auth.FromContext(ctx); ok {
+		run.CreatedBy = id.UserID
+	}
+
+	dir := path.Join(x.prefix, strings.ToLower(string(run.Kind)), run.Until.Format(dirTimeFormat))
+	manifest := &Manifest{
+		ID:          run.ID,
+		Kind:        run.Kind,
+		Format:      "csv",
+		Compression: "gzip",
+		Columns:     columns,
+		Since:       run.Since,
+		Until:       run.Until,
+		Files:       []ManifestFile{},
+	}
+	part := newPart()
+	flush := func() error {
+		if part.rows == 0 {
+			return nil
+		}
+		body, err := part.close()
+		if err != nil {
+			return err
+		}
+		key := path.Join(dir, fmt.Sprintf("part-%05d.csv.gz", len(manifest.Files)))
+		if err := x.store.Put(ctx, key, body, "application/gzip"); err != nil {
+			return fmt.Errorf("failed to store %s: %w", key, err)
+		}
+		sum := sha256.Sum256(body)
+		manifest.Files = append(manifest.Files, ManifestFile{Key: key, Rows: part.rows, Bytes: len(body), SHA256: hex.EncodeToString(sum[:])})
+		part = newPart()
+		return nil
+	}
+	err := x.source.ForEachExportedCompany(ctx, run.Since, run.Until, func(company *models.Company) error {
+		if err := part.write(company); err != nil {
+			return err
+		}
+		manifest.Companies++
+		if part.rows >= x.rowsPerFile {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	manifest.CreatedAt = x.now().UTC()
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	manifestKey := path.Join(dir, "manifest.json")
+	if err := x.store.Put(ctx, manifestKey, body, "application/json"); err != nil {
+		return nil, fmt.Errorf("failed to store %s: %w", manifestKey, err)
+	}
+
+	run.Companies, run.Files, run.Manifest = manifest.Companies, len(manifest.Files), x.store.URL(manifestKey)
+	if err := x.source.CreateExportRun(ctx, run); err != nil {
+		return nil, fmt.Errorf("failed to record export: %w", err)
+	}
+	x.logger.Info("Exported companies",
+		zap.String("kind", string(run.Kind)),
+		zap.Int64("companies", run.Companies),
+		zap.String("manifest", run.Manifest))
+	return run, nil
+}
+
+// part is a data file being written.
+type part struct {
+	buf  bytes.Buffer
+	gz   *gzip.Writer
+	csv  *csvWriter
+	rows int
+}
+
+func newPart() *part {
+	p := &part{}
+	p.gz = gzip.NewWriter(&p.buf)
+	p.csv = newCSVWriter(p.gz)
+	return p
+}
+
+// write adds company as a row, preceded by the header in the first row.
+func (p *part) write(company *models.Company) error {
+	if err := p.csv.write(company); err != nil {
+		return err
+	}
+	p.rows++
+	return nil
+}
+
+// close finishes the file and returns its content.
+func (p *part) close() ([]byte, error) {
+	if err := p.csv.flush(); err != nil {
+		return nil, err
+	}
+	if err := p.gz.Close(); err != nil {
+		return nil, err
+	}
+	return p.buf.Bytes(), nil
+}
@@ -0,0 +1,170 @@
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+type fakeSource struct {
+	companies []models.Company
+	runs      []*models.ExportRun
+	since     *time.Time
+}
+
+func (f *fakeSource) ForEachExportedCompany(_ context.Context, since *time.Time, _ time.Time, fn func(*models.Company) error) error {
+	f.since = since
+	for i := range f.companies {
+		if err := fn(&f.companies[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeSource) LastExportRun(context.Context) (*models.ExportRun, error) {
+	if len(f.runs) == 0 {
+		return nil, nil
+	}
+	return f.runs[len(f.runs)-1], nil
+}
+
+func (f *fakeSource) CreateExportRun(_ context.Context, run *models.ExportRun) error {
+	f.runs = append(f.runs, run)
+	return nil
+}
+
+type fakeLocker struct{ held bool }
+
+func (l *fakeLocker) TryAdvisoryLock(context.Context, int64) (func(), bool, error) {
+	if l.held {
+		return nil, false, nil
+	}
+	return func() {}, true, nil
+}
+
+type memoryStore struct {
+	objects map[string][]byte
+	keys    []string
+}
+
+func (s *memoryStore) Put(_ context.Context, key string, body []byte, _ string) error {
+	if s.objects == nil {
+		s.objects = make(map[string][]byte)
+	}
+	s.objects[key] = body
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+func (s *memoryStore) URL(key string) string {
+	return "mem://bucket/" + key
+}
+
+// readPart decompresses and parses a data file.
+func readPart(t *testing.T, body []byte) [][]string {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	require.NoError(t, err)
+	records, err := csv.NewReader(gz).ReadAll()
+	require.NoError(t, err)
+	return records
+}
+
+func TestExporter_Full(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 1, 0, 0, time.UTC)
+	source := &fakeSource{}
+	for _, name := range []string{"Acme", "Globex", "Initech"} {
+		source.companies = append(source.companies, models.Company{ID: uuid.New(), Name: name, CreatedAt: now})
+	}
+	source.companies[0].Metadata = models.Metadata{"crm": "salesforce"}
+	store := &memoryStore{}
+	x := New(source, &fakeLocker{}, store, zaptest.NewLogger(t), WithPrefix("/exports/"), WithRowsPerFile(2))
+	x.now = func() time.Time { return now }
+	ctx := auth.NewContext(context.Background(), auth.Identity{UserID: "admin"})
+
+	run, err := x.Export(ctx, models.ExportFull)
+	require.NoError(t, err)
+	assert.Equal(t, models.ExportFull, run.Kind)
+	assert.Nil(t, run.Since)
+	assert.Equal(t, now.Add(-settleDelay), run.Until)
+	assert.Equal(t, int64(3), run.Companies)
+	assert.Equal(t, 2, run.Files)
+	assert.Equal(t, "admin", run.CreatedBy)
+	assert.Equal(t, "mem://bucket/exports/full/20250301T120000Z/manifest.json", run.Manifest)
+	assert.Equal(t, []*models.ExportRun{run}, source.runs)
+
+	require.Equal(t, []string{
+		"exports/full/20250301T120000Z/part-00000.csv.gz",
+		"exports/full/20250301T120000Z/part-00001.csv.gz",
+		"exports/full/20250301T120000Z/manifest.json",
+	}, store.keys, "the manifest is written last")
+
+	first := readPart(t, store.objects[store.keys[0]])
+	require.Len(t, first, 3)
+	assert.Equal(t, columns, first[0])
+	assert.Equal(t, "Acme", first[1][1])
+	assert.Equal(t, `{"crm":"salesforce"}`, first[1][9])
+	assert.Equal(t, "2025-03-01T12:01:00Z", first[1][12])
+	assert.Empty(t, first[1][15], "live companies have no deletion time")
+	second := readPart(t, store.objects[store.keys[1]])
+	require.Len(t, second, 2, "every file has a header")
+	assert.Equal(t, "Initech", second[1][1])
+
+	var manifest Manifest
+	require.NoError(t, json.Unmarshal(store.objects[store.keys[2]], &manifest))
+	assert.Equal(t, run.ID, manifest.ID)
+	assert.Equal(t, int64(3), manifest.Companies)
+	require.Len(t, manifest.Files, 2)
+	assert.Equal(t, store.keys[0], manifest.Files[0].Key)
+	assert.Equal(t, 2, manifest.Files[0].Rows)
+	assert.Equal(t, len(store.objects[store.keys[0]]), manifest.Files[0].Bytes)
+	assert.Len(t, manifest.Files[0].SHA256, 64)
+}
+
+func TestExporter_Incremental(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 1, 0, 0, time.UTC)
+	source := &fakeSource{}
+	store := &memoryStore{}
+	x := New(source, &fakeLocker{}, store, zaptest.NewLogger(t))
+	x.now = func() time.Time { return now }
+
+	first, err := x.Export(context.Background(), models.ExportIncremental)
+	require.NoError(t, err)
+	assert.Equal(t, models.ExportFull, first.Kind, "the first incremental export is full")
+	assert.Zero(t, first.Files)
+	assert.Nil(t, source.since)
+
+	now = now.Add(time.Hour)
+	source.companies = []models.Company{{ID: uuid.New(), Name: "Acme"}}
+	second, err := x.Export(context.Background(), models.ExportIncremental)
+	require.NoError(t, err)
+	assert.Equal(t, models.ExportIncremental, second.Kind)
+	require.NotNil(t, second.Since)
+	assert.Equal(t, first.Until, *second.Since, "incremental exports continue where the last one ended")
+	assert.Equal(t, second.Since, source.since)
+	assert.True(t, strings.HasPrefix(second.Manifest, "mem://bucket/companies/incremental/20250301T130000Z/"), second.Manifest)
+	assert.Empty(t, second.CreatedBy, "scheduled exports have no requester")
+}
+
+func TestExporter_InProgress(t *testing.T) {
+	source := &fakeSource{}
+	x := New(source, &fakeLocker{held: true}, &memoryStore{}, zaptest.NewLogger(t))
+
+	_, err := x.Export(context.Background(), models.ExportFull)
+	assert.True(t, errors.Is(err, ErrInProgress), "got %v", err)
+	assert.Empty(t, source.runs)
+}
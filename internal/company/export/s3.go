@@ -0,0 +1,137 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gartstein/xm/internal/pkg/awssig"
+)
+
+// gcsHost is the host of the S3-compatible XML API of Google Cloud Storage.
+const gcsHost = "storage.googleapis.com"
+
+// S3Config configures an S3Store.
+type S3Config struct {
+	// Endpoint is the base URL of the API, https://s3.<Region>.amazonaws.com
+	// by default. Use https://storage.googleapis.com for Google Cloud
+	// Storage, with HMAC keys, or the URL of another S3-compatible store.
+	Endpoint string
+	// Region is the region requests are signed for, e.g. "eu-west-1"; GCS
+	// accepts "auto".
+	Region string
+	Bucket string
+	// AccessKeyID and SecretAccessKey sign the requests. SessionToken is
+	// set for temporary credentials, such as those of an assumed role.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// S3Store stores objects in a bucket through the S3 API, signing requests
+// with AWS Signature Version 4. Objects are addressed path-style, as
+// <Endpoint>/<Bucket>/<key>.
+type S3Store struct {
+	endpoint *url.URL
+	region   string
+	bucket   string
+	creds    awssig.Credentials
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3Store returns an S3Store for cfg.
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("export bucket required")
+	}
+	if cfg.Region == "" {
+		return nil, errors.New("export region required")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("export access key required")
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid export endpoint %q", endpoint)
+	}
+	return &S3Store{
+		endpoint: u,
+		region:   cfg.Region,
+		bucket:   cfg.Bucket,
+		creds: awssig.Credentials{
+			AccessKeyID:     cfg.AccessKeyID,
+			SecretAccessKey: cfg.SecretAccessKey,
+			SessionToken:    cfg.SessionToken,
+		},
+		client: &http.Client{Timeout: 5 * time.Minute},
+		now:    time.Now,
+	}, nil
+}
+
+// URL implements ObjectStore, returning a gs:// URL for Google Cloud Storage
+// and an s3:// URL otherwise.
+func (s *S3Store) URL(key string) string {
+	scheme := "s3"
+	if s.endpoint.Host == gcsHost {
+		scheme = "gs"
+	}
+	return scheme + "://" + s.bucket + "/" + key
+}
+
+// Put implements ObjectStore.
+func (s *S3Store) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + key
+	u.RawPath = strings.TrimSuffix(s.endpoint.EscapedPath(), "/") + "/" + uriEncode(s.bucket) + "/" + uriEncode(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	s.sign(req, body)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("object store answered %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// sign adds the Signature Version 4 headers to req, which carries body.
+func (s *S3Store) sign(req *http.Request, body []byte) {
+	req.Header.Set("X-Amz-Content-Sha256", awssig.HashHex(body))
+	awssig.Sign(req, body, s.creds, s.region, "s3", s.now())
+}
+
+// uriEncode percent-encodes every byte of path but the unreserved characters
+// and '/', as Signature Version 4 requires of object keys.
+func uriEncode(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
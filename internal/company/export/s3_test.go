@@ -0,0 +1,90 @@
+package export
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/pkg/awssig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Store_Put(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:        server.URL,
+		Region:          "eu-west-1",
+		Bucket:          "analytics",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+	})
+	require.NoError(t, err)
+	store.now = func() time.Time { return time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	require.NoError(t, store.Put(context.Background(), "companies/full/a b.csv.gz", []byte("data"), "application/gzip"))
+	require.NotNil(t, got)
+	assert.Equal(t, http.MethodPut, got.Method)
+	assert.Equal(t, "/analytics/companies/full/a%20b.csv.gz", got.URL.EscapedPath())
+	assert.Equal(t, "data", string(body))
+	assert.Equal(t, "application/gzip", got.Header.Get("Content-Type"))
+	assert.Equal(t, "20250301T120000Z", got.Header.Get("X-Amz-Date"))
+	assert.Equal(t, awssig.HashHex([]byte("data")), got.Header.Get("X-Amz-Content-Sha256"))
+	assert.True(t, strings.HasPrefix(got.Header.Get("Authorization"),
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20250301/eu-west-1/s3/aws4_request, SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature="),
+		got.Header.Get("Authorization"))
+	assert.Equal(t, "s3://analytics/companies/full/a b.csv.gz", store.URL("companies/full/a b.csv.gz"))
+}
+
+func TestS3Store_PutSessionToken(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(S3Config{Endpoint: server.URL, Region: "eu-west-1", Bucket: "b", AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"})
+	require.NoError(t, err)
+	require.NoError(t, store.Put(context.Background(), "k", nil, "application/json"))
+	require.NotNil(t, got)
+	assert.Equal(t, "token", got.Header.Get("X-Amz-Security-Token"))
+	assert.Contains(t, got.Header.Get("Authorization"), "SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date;x-amz-security-token,")
+}
+
+func TestS3Store_PutFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(S3Config{Endpoint: server.URL, Region: "auto", Bucket: "b", AccessKeyID: "id", SecretAccessKey: "secret"})
+	require.NoError(t, err)
+	err = store.Put(context.Background(), "k", nil, "application/json")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "AccessDenied")
+}
+
+func TestNewS3Store(t *testing.T) {
+	_, err := NewS3Store(S3Config{Region: "eu-west-1", AccessKeyID: "id", SecretAccessKey: "secret"})
+	assert.Error(t, err, "a bucket is required")
+	_, err = NewS3Store(S3Config{Endpoint: "ftp://example.com", Region: "eu-west-1", Bucket: "b", AccessKeyID: "id", SecretAccessKey: "secret"})
+	assert.Error(t, err, "the endpoint must be http(s)")
+
+	store, err := NewS3Store(S3Config{Endpoint: "https://storage.googleapis.com", Region: "auto", Bucket: "b", AccessKeyID: "id", SecretAccessKey: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "gs://b/k", store.URL("k"))
+	store, err = NewS3Store(S3Config{Region: "eu-west-1", Bucket: "b", AccessKeyID: "id", SecretAccessKey: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "s3.eu-west-1.amazonaws.com", store.endpoint.Host)
+}
@@ -0,0 +1,387 @@
+// Package faults injects failures into the service's dependencies so that
+// resilience features can be exercised end-to-end. It is meant for
+// development and test environments only.
+package faults
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/controller"
+	"github.com/gartstein/xm/internal/company/db"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrInjected is returned by repository calls failed on purpose.
+var ErrInjected = errors.New("injected transient fault")
+
+// Config describes the faults to inject. Rates are fractions between 0 and
+// 1; the zero value injects nothing.
+type Config struct {
+	// RepoErrorRate is the fraction of repository calls failing with
+	// ErrInjected.
+	RepoErrorRate float64 `yaml:"REPO_ERROR_RATE"`
+	// RepoLatency is added to every repository call.
+	RepoLatency time.Duration `yaml:"REPO_LATENCY"`
+	// EventDropRate is the fraction of produced events silently dropped.
+	EventDropRate float64 `yaml:"EVENT_DROP_RATE"`
+	// EventLatency is added to every produced or replayed event.
+	EventLatency time.Duration `yaml:"EVENT_LATENCY"`
+}
+
+// configJSON is the JSON form of Config served by the admin API, with
+// latencies written as durations such as "250ms".
+type configJSON struct {
+	RepoErrorRate float64 `json:"repo_error_rate"`
+	RepoLatency   string  `json:"repo_latency"`
+	EventDropRate float64 `json:"event_drop_rate"`
+	EventLatency  string  `json:"event_latency"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(configJSON{
+		RepoErrorRate: c.RepoErrorRate,
+		RepoLatency:   c.RepoLatency.String(),
+		EventDropRate: c.EventDropRate,
+		EventLatency:  c.EventLatency.String(),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Omitted latencies are zero.
+func (c *Config) UnmarshalJSON(b []byte) error {
+	var raw configJSON
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	cfg := Config{RepoErrorRate: raw.RepoErrorRate, EventDropRate: raw.EventDropRate}
+	for _, d := range []struct {
+		value string
+		dst   *time.Duration
+	}{{raw.RepoLatency, &cfg.RepoLatency}, {raw.EventLatency, &cfg.EventLatency}} {
+		if d.value == "" {
+			continue
+		}
+		var err error
+		if *d.dst, err = time.ParseDuration(d.value); err != nil {
+			return err
+		}
+	}
+	*c = cfg
+	return nil
+}
+
+// Validate reports whether the rates are within [0, 1] and the latencies
+// are not negative.
+func (c Config) Validate() error {
+	for name, rate := range map[string]float64{"repo_error_rate": c.RepoErrorRate, "event_drop_rate": c.EventDropRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s must be between 0 and 1, got %v", name, rate)
+		}
+	}
+	if c.RepoLatency < 0 || c.EventLatency < 0 {
+		return errors.New("latencies must not be negative")
+	}
+	return nil
+}
+
+// Injector holds the active fault configuration, which can be changed at
+// runtime through its HTTP handler.
+type Injector struct {
+	logger *zap.Logger
+	// roll returns a random number in [0, 1).
+	roll func() float64
+
+	mu  sync.RWMutex
+	cfg Config
+}
+
+// NewInjector returns an Injector starting with cfg.
+func NewInjector(cfg Config, logger *zap.Logger) (*Injector, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Injector{logger: logger.Named("faults"), roll: rand.Float64, cfg: cfg}, nil
+}
+
+// Config returns the active configuration.
+func (i *Injector) Config() Config {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.cfg
+}
+
+// Set replaces the active configuration.
+func (i *Injector) Set(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	i.mu.Lock()
+	i.cfg = cfg
+	i.mu.Unlock()
+	i.logger.Warn("Fault injection changed",
+		zap.Float64("repo_error_rate", cfg.RepoErrorRate),
+		zap.Duration("repo_latency", cfg.RepoLatency),
+		zap.Float64("event_drop_rate", cfg.EventDropRate),
+		zap.Duration("event_latency", cfg.EventLatency),
+	)
+	return nil
+}
+
+// ServeHTTP reports the active configuration on GET and replaces it with the
+// JSON body on PUT.
+func (i *Injector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var cfg Config
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			http.Error(w, fmt.Sprintf("invalid fault configuration: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := i.Set(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(i.Config())
+}
+
+// repoFault delays a repository call by the configured latency and returns
+// ErrInjected for the configured fraction of calls.
+func (i *Injector) repoFault(ctx context.Context, op string) error {
+	cfg := i.Config()
+	if err := sleep(ctx, cfg.RepoLatency); err != nil {
+		return err
+	}
+	if cfg.RepoErrorRate > 0 && i.roll() < cfg.RepoErrorRate {
+		i.logger.Debug("Injected repository error", zap.String("op", op))
+		return fmt.Errorf("%s: %w", op, ErrInjected)
+	}
+	return nil
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Repository wraps repo so its calls are subject to the injected faults.
+func (i *Injector) Repository(repo controller.Repository) controller.Repository {
+	return &faultyRepository{Repository: repo, faults: i}
+}
+
+// Producer wraps producer so its events are subject to the injected faults.
+func (i *Injector) Producer(producer controller.EventProducer) controller.EventProducer {
+	return &faultyProducer{EventProducer: producer, faults: i}
+}
+
+type faultyRepository struct {
+	controller.Repository
+	faults *Injector
+}
+
+func (r *faultyRepository) CreateCompany(ctx context.Context, company *models.Company) error {
+	if err := r.faults.repoFault(ctx, "CreateCompany"); err != nil {
+		return err
+	}
+	return r.Repository.CreateCompany(ctx, company)
+}
+
+func (r *faultyRepository) GetCompany(ctx context.Context, id uuid.UUID) (*models.Company, error) {
+	if err := r.faults.repoFault(ctx, "GetCompany"); err != nil {
+		return nil, err
+	}
+	return r.Repository.GetCompany(ctx, id)
+}
+
+func (r *faultyRepository) GetCompanyByName(ctx context.Context, name string) (*models.Company, error) {
+	if err := r.faults.repoFault(ctx, "GetCompanyByName"); err != nil {
+		return nil, err
+	}
+	return r.Repository.GetCompanyByName(ctx, name)
+}
+
+func (r *faultyRepository) GetCompanyByExternalRef(ctx context.Context, ref string) (*models.Company, error) {
+	if err := r.faults.repoFault(ctx, "GetCompanyByExternalRef"); err != nil {
+		return nil, err
+	}
+	return r.Repository.GetCompanyByExternalRef(ctx, ref)
+}
+
+func (r *faultyRepository) ListCompanies(ctx context.Context, filter models.CompanyFilter, offset, limit int) ([]models.Company, error) {
+	if err := r.faults.repoFault(ctx, "ListCompanies"); err != nil {
+		return nil, err
+	}
+	return r.Repository.ListCompanies(ctx, filter, offset, limit)
+}
+
+func (r *faultyRepository) CountCompanies(ctx context.Context, filter models.CompanyFilter) (int64, error) {
+	if err := r.faults.repoFault(ctx, "CountCompanies"); err != nil {
+		return 0, err
+	}
+	return r.Repository.CountCompanies(ctx, filter)
+}
+
+func (r *faultyRepository) UpdateCompany(ctx context.Context, update *models.CompanyUpdate) error {
+	if err := r.faults.repoFault(ctx, "UpdateCompany"); err != nil {
+		return err
+	}
+	return r.Repository.UpdateCompany(ctx, update)
+}
+
+func (r *faultyRepository) UpdateCompanyReturning(ctx context.Context, update *models.CompanyUpdate) (*models.Company, *models.Company, error) {
+	if err := r.faults.repoFault(ctx, "UpdateCompanyReturning"); err != nil {
+		return nil, nil, err
+	}
+	return r.Repository.UpdateCompanyReturning(ctx, update)
+}
+
+func (r *faultyRepository) DeleteCompany(ctx context.Context, id uuid.UUID) error {
+	if err := r.faults.repoFault(ctx, "DeleteCompany"); err != nil {
+		return err
+	}
+	return r.Repository.DeleteCompany(ctx, id)
+}
+
+func (r *faultyRepository) PurgeCompany(ctx context.Context, id uuid.UUID) error {
+	if err := r.faults.repoFault(ctx, "PurgeCompany"); err != nil {
+		return err
+	}
+	return r.Repository.PurgeCompany(ctx, id)
+}
+
+func (r *faultyRepository) EraseCompanyData(ctx context.Context, id uuid.UUID, actor string, at time.Time) (*models.Company, error) {
+	if err := r.faults.repoFault(ctx, "EraseCompanyData"); err != nil {
+		return nil, err
+	}
+	return r.Repository.EraseCompanyData(ctx, id, actor, at)
+}
+
+func (r *faultyRepository) PurgeDeletedCompanies(ctx context.Context, before time.Time) (int64, error) {
+	if err := r.faults.repoFault(ctx, "PurgeDeletedCompanies"); err != nil {
+		return 0, err
+	}
+	return r.Repository.PurgeDeletedCompanies(ctx, before)
+}
+
+func (r *faultyRepository) CompanyExistsByName(ctx context.Context, name string) (bool, error) {
+	if err := r.faults.repoFault(ctx, "CompanyExistsByName"); err != nil {
+		return false, err
+	}
+	return r.Repository.CompanyExistsByName(ctx, name)
+}
+
+func (r *faultyRepository) FindSimilarCompanies(ctx context.Context, name string, threshold float64, limit int) ([]models.Company, error) {
+	if err := r.faults.repoFault(ctx, "FindSimilarCompanies"); err != nil {
+		return nil, err
+	}
+	return r.Repository.FindSimilarCompanies(ctx, name, threshold, limit)
+}
+
+func (r *faultyRepository) RecordCompanyEvent(ctx context.Context, event *models.CompanyEvent) error {
+	if err := r.faults.repoFault(ctx, "RecordCompanyEvent"); err != nil {
+		return err
+	}
+	return r.Repository.RecordCompanyEvent(ctx, event)
+}
+
+func (r *faultyRepository) ListCompanyEvents(ctx context.Context, filter models.CompanyEventFilter, limit int) ([]models.CompanyEvent, error) {
+	if err := r.faults.repoFault(ctx, "ListCompanyEvents"); err != nil {
+		return nil, err
+	}
+	return r.Repository.ListCompanyEvents(ctx, filter, limit)
+}
+
+func (r *faultyRepository) ForEachCompanyEvent(ctx context.Context, filter models.CompanyEventFilter, fn func(*models.CompanyEvent) error) error {
+	if err := r.faults.repoFault(ctx, "ForEachCompanyEvent"); err != nil {
+		return err
+	}
+	return r.Repository.ForEachCompanyEvent(ctx, filter, fn)
+}
+
+func (r *faultyRepository) GetEmployee(ctx context.Context, company, id uuid.UUID) (*models.Employee, error) {
+	if err := r.faults.repoFault(ctx, "GetEmployee"); err != nil {
+		return nil, err
+	}
+	return r.Repository.GetEmployee(ctx, company, id)
+}
+
+func (r *faultyRepository) ListEmployees(ctx context.Context, company uuid.UUID, offset, limit int) ([]models.Employee, error) {
+	if err := r.faults.repoFault(ctx, "ListEmployees"); err != nil {
+		return nil, err
+	}
+	return r.Repository.ListEmployees(ctx, company, offset, limit)
+}
+
+func (r *faultyRepository) CountEmployees(ctx context.Context, company uuid.UUID) (int64, error) {
+	if err := r.faults.repoFault(ctx, "CountEmployees"); err != nil {
+		return 0, err
+	}
+	return r.Repository.CountEmployees(ctx, company)
+}
+
+func (r *faultyRepository) ListCompanyNotes(ctx context.Context, company uuid.UUID, offset, limit int) ([]models.CompanyNote, error) {
+	if err := r.faults.repoFault(ctx, "ListCompanyNotes"); err != nil {
+		return nil, err
+	}
+	return r.Repository.ListCompanyNotes(ctx, company, offset, limit)
+}
+
+// WithTransaction may fail before the transaction starts; calls made within
+// it go to the transaction directly and are not subject to faults.
+func (r *faultyRepository) WithTransaction(ctx context.Context, fn func(repo *db.Repository) error) error {
+	if err := r.faults.repoFault(ctx, "WithTransaction"); err != nil {
+		return err
+	}
+	return r.Repository.WithTransaction(ctx, fn)
+}
+
+type faultyProducer struct {
+	controller.EventProducer
+	faults *Injector
+}
+
+func (p *faultyProducer) Produce(event events.Event) {
+	cfg := p.faults.Config()
+	_ = sleep(context.Background(), cfg.EventLatency)
+	if cfg.EventDropRate > 0 && p.faults.roll() < cfg.EventDropRate {
+		p.faults.logger.Warn("Dropped event",
+			zap.String("event_id", event.EventID.String()),
+			zap.String("event_type", string(event.Type)),
+		)
+		return
+	}
+	p.EventProducer.Produce(event)
+}
+
+func (p *faultyProducer) Replay(ctx context.Context, topic string, event events.Event) error {
+	if err := sleep(ctx, p.faults.Config().EventLatency); err != nil {
+		return err
+	}
+	return p.EventProducer.Replay(ctx, topic, event)
+}
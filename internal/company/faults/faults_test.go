@@ -0,0 +1,124 @@
+package faults
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/controller"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// stubRepository answers GetCompany; other methods are not used.
+type stubRepository struct {
+	controller.Repository
+	calls int
+}
+
+func (r *stubRepository) GetCompany(_ context.Context, id uuid.UUID) (*models.Company, error) {
+	r.calls++
+	return &models.Company{ID: id}, nil
+}
+
+// recordingProducer records produced events.
+type recordingProducer struct {
+	produced []events.Event
+}
+
+func (p *recordingProducer) Produce(event events.Event) {
+	p.produced = append(p.produced, event)
+}
+
+func (p *recordingProducer) Replay(context.Context, string, events.Event) error {
+	return nil
+}
+
+func TestRepositoryErrors(t *testing.T) {
+	injector, err := NewInjector(Config{RepoErrorRate: 0.5}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	rolls := []float64{0.2, 0.7}
+	injector.roll = func() float64 {
+		r := rolls[0]
+		rolls = rolls[1:]
+		return r
+	}
+	stub := &stubRepository{}
+	repo := injector.Repository(stub)
+
+	_, err = repo.GetCompany(context.Background(), uuid.New())
+	assert.ErrorIs(t, err, ErrInjected)
+	assert.Equal(t, 0, stub.calls, "failed calls should not reach the repository")
+
+	_, err = repo.GetCompany(context.Background(), uuid.New())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stub.calls)
+}
+
+func TestRepositoryLatency(t *testing.T) {
+	injector, err := NewInjector(Config{RepoLatency: time.Hour}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	repo := injector.Repository(&stubRepository{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = repo.GetCompany(ctx, uuid.New())
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "latency should end with the request")
+
+	require.NoError(t, injector.Set(Config{RepoLatency: 10 * time.Millisecond}))
+	start := time.Now()
+	_, err = repo.GetCompany(context.Background(), uuid.New())
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+}
+
+func TestProducerDrops(t *testing.T) {
+	injector, err := NewInjector(Config{EventDropRate: 1}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	recorder := &recordingProducer{}
+	producer := injector.Producer(recorder)
+
+	producer.Produce(events.Event{Type: events.CompanyCreated})
+	assert.Empty(t, recorder.produced)
+
+	require.NoError(t, injector.Set(Config{}))
+	producer.Produce(events.Event{Type: events.CompanyCreated})
+	assert.Len(t, recorder.produced, 1)
+}
+
+func TestInjectorServeHTTP(t *testing.T) {
+	injector, err := NewInjector(Config{}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	injector.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/faults",
+		strings.NewReader(`{"repo_error_rate":0.1,"repo_latency":"250ms","event_drop_rate":0.5}`)))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"repo_error_rate":0.1,"repo_latency":"250ms","event_drop_rate":0.5,"event_latency":"0s"}`, rec.Body.String())
+	assert.Equal(t, Config{RepoErrorRate: 0.1, RepoLatency: 250 * time.Millisecond, EventDropRate: 0.5}, injector.Config())
+
+	for _, body := range []string{`{"event_drop_rate":2}`, `{"repo_latency":"soon"}`, `{`} {
+		rec = httptest.NewRecorder()
+		injector.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/faults", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+	assert.Equal(t, 0.5, injector.Config().EventDropRate, "rejected updates should keep the configuration")
+
+	rec = httptest.NewRecorder()
+	injector.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/faults", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestNewInjectorInvalidConfig(t *testing.T) {
+	_, err := NewInjector(Config{RepoErrorRate: -0.1}, zaptest.NewLogger(t))
+	assert.Error(t, err)
+	_, err = NewInjector(Config{EventLatency: -time.Second}, zaptest.NewLogger(t))
+	assert.Error(t, err)
+}
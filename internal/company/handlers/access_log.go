@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/google/uuid"
+)
+
+// AccessLogFormat is the line format of the HTTP access log.
+type AccessLogFormat string
+
+const (
+	// AccessLogCombined is the Apache combined log format followed by the
+	// latency in milliseconds and the request ID.
+	AccessLogCombined AccessLogFormat = "combined"
+	// AccessLogJSON writes one JSON object per request.
+	AccessLogJSON AccessLogFormat = "json"
+)
+
+// ParseAccessLogFormat returns the format named by name; empty selects
+// AccessLogCombined.
+func ParseAccessLogFormat(name string) (AccessLogFormat, error) {
+	switch format := AccessLogFormat(name); format {
+	case "":
+		return AccessLogCombined, nil
+	case AccessLogCombined, AccessLogJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown access log format %q", name)
+	}
+}
+
+// AccessLog writes one line per HTTP request served by the gateway, with
+// its method, path, status, latency, response size, user and request ID.
+// Unlike the logging interceptor it also sees requests that never reach
+// gRPC, such as CORS preflights, unrouted paths, oversized bodies and
+// rejected credentials.
+type AccessLog struct {
+	out        io.Writer
+	format     AccessLogFormat
+	sampleRate float64
+	sample     func() float64
+	// mu keeps lines written by concurrent requests whole.
+	mu sync.Mutex
+}
+
+// AccessLogOption configures an AccessLog.
+type AccessLogOption func(*AccessLog)
+
+// WithAccessLogFormat selects the line format; the default is
+// AccessLogCombined.
+func WithAccessLogFormat(format AccessLogFormat) AccessLogOption {
+	return func(l *AccessLog) {
+		l.format = format
+	}
+}
+
+// WithAccessLogSampling logs the given fraction of requests, between 0 and
+// 1 (all, the default). Server errors are always logged.
+func WithAccessLogSampling(rate float64) AccessLogOption {
+	return func(l *AccessLog) {
+		l.sampleRate = rate
+	}
+}
+
+// NewAccessLog returns an AccessLog writing to out.
+func NewAccessLog(out io.Writer, opts ...AccessLogOption) *AccessLog {
+	l := &AccessLog{out: out, format: AccessLogCombined, sampleRate: 1, sample: rand.Float64}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Middleware returns the access log as the "access-log" middleware. It runs
+// before recovery, so requests whose handler panicked are logged with the
+// 500 they were answered with.
+func (l *AccessLog) Middleware() Middleware {
+	return Middleware{Name: "access-log", Order: OrderAccessLog, HTTP: l.Handler}
+}
+
+// Handler logs the requests served by next. Requests without a request ID
+// get one, which the gateway forwards, so the access log and the gRPC call
+// log share it. Response headers are left untouched, so CORS and caching
+// headers reach clients as next set them.
+func (l *AccessLog) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.NewString()
+			r.Header.Set(RequestIDHeader, requestID)
+		}
+		ctx, identity := auth.RecordIdentity(r.Context())
+		rw := &accessLogWriter{ResponseWriter: w}
+
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		if rw.status < http.StatusInternalServerError && l.sampleRate < 1 && l.sample() >= l.sampleRate {
+			return
+		}
+		entry := accessLogEntry{
+			Time:      start,
+			RemoteIP:  remoteIP(r),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Proto:     r.Proto,
+			Status:    rw.statusCode(),
+			Bytes:     rw.bytes,
+			Latency:   time.Since(start),
+			Referer:   r.Referer(),
+			UserAgent: r.UserAgent(),
+			RequestID: requestID,
+		}
+		if id, ok := identity(); ok {
+			entry.User = id.UserID
+		}
+		l.write(entry)
+	})
+}
+
+// accessLogEntry describes one logged request. The query string is left
+// out, as it may carry page tokens and other values not meant for logs.
+type accessLogEntry struct {
+	Time      time.Time
+	RemoteIP  string
+	Method    string
+	Path      string
+	Proto     string
+	Status    int
+	Bytes     int64
+	Latency   time.Duration
+	Referer   string
+	UserAgent string
+	User      string
+	RequestID string
+}
+
+func (l *AccessLog) write(entry accessLogEntry) {
+	var line []byte
+	switch l.format {
+	case AccessLogJSON:
+		line, _ = json.Marshal(struct {
+			Time      string  `json:"time"`
+			RemoteIP  string  `json:"remote_ip"`
+			Method    string  `json:"method"`
+			Path      string  `json:"path"`
+			Status    int     `json:"status"`
+			Bytes     int64   `json:"bytes"`
+			LatencyMS float64 `json:"latency_ms"`
+			User      string  `json:"user,omitempty"`
+			RequestID string  `json:"request_id"`
+			Referer   string  `json:"referer,omitempty"`
+			UserAgent string  `json:"user_agent,omitempty"`
+		}{
+			Time:      entry.Time.UTC().Format(time.RFC3339Nano),
+			RemoteIP:  entry.RemoteIP,
+			Method:    entry.Method,
+			Path:      entry.Path,
+			Status:    entry.Status,
+			Bytes:     entry.Bytes,
+			LatencyMS: float64(entry.Latency.Microseconds()) / 1000,
+			User:      entry.User,
+			RequestID: entry.RequestID,
+			Referer:   entry.Referer,
+			UserAgent: entry.UserAgent,
+		})
+	default:
+		line = fmt.Appendf(nil, "%s - %s [%s] %s %d %s %s %s %.3f %s",
+			entry.RemoteIP,
+			orDash(entry.User),
+			entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+			strconv.Quote(entry.Method+" "+entry.Path+" "+entry.Proto),
+			entry.Status,
+			bytesField(entry.Bytes),
+			strconv.Quote(orDash(entry.Referer)),
+			strconv.Quote(orDash(entry.UserAgent)),
+			float64(entry.Latency.Microseconds())/1000,
+			entry.RequestID,
+		)
+	}
+	line = append(line, '\n')
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.out.Write(line)
+}
+
+// orDash returns s, or "-" for an empty value as the combined format has it.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// bytesField renders a response size, "-" for none, as the combined format
+// has it.
+func bytesField(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// remoteIP returns the address of the peer of r, without its port.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// accessLogWriter records the status and size of a response.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	// Informational responses precede the final one.
+	if w.status == 0 && status >= http.StatusOK {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush passes flushes on, so streamed responses are not held back.
+func (w *accessLogWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// statusCode returns the status sent, 200 when the handler sent nothing.
+func (w *accessLogWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestAccessLog_Combined(t *testing.T) {
+	var out bytes.Buffer
+	chain := NewChain()
+	for _, m := range []Middleware{NewAccessLog(&out).Middleware(), Recovery(zaptest.NewLogger(t))} {
+		if err := chain.Register(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var forwardedID string
+	handler := chain.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedID = r.Header.Get(RequestIDHeader)
+		if r.URL.Path == "/v1/panic" {
+			panic("boom")
+		}
+		// The gateway's auth middleware records the caller.
+		auth.NewContext(r.Context(), auth.Identity{UserID: "user-42"})
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"1"}`))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/companies?page_token=secret", nil)
+	req.Header.Set("User-Agent", "curl/8.0")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("expected the response headers to be passed on")
+	}
+	if forwardedID == "" {
+		t.Error("expected a request ID to be forwarded")
+	}
+
+	pattern := regexp.MustCompile(`^192\.0\.2\.1 - user-42 \[[^\]]+\] "POST /v1/companies HTTP/1\.1" 201 10 "-" "curl/8\.0" \d+\.\d{3} ` + regexp.QuoteMeta(forwardedID) + "\n$")
+	if !pattern.MatchString(out.String()) {
+		t.Errorf("unexpected access log line %q", out.String())
+	}
+
+	// A panicking handler is logged with the 500 recovery answered.
+	out.Reset()
+	req = httptest.NewRequest(http.MethodGet, "/v1/panic", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if !strings.Contains(out.String(), `"GET /v1/panic HTTP/1.1" 500 `) || !strings.HasSuffix(out.String(), " req-1\n") {
+		t.Errorf("unexpected access log line %q", out.String())
+	}
+}
+
+func TestAccessLog_JSONSampled(t *testing.T) {
+	var out bytes.Buffer
+	accessLog := NewAccessLog(&out, WithAccessLogFormat(AccessLogJSON), WithAccessLogSampling(0.5))
+	accessLog.sample = func() float64 { return 0.7 }
+	status := http.StatusOK
+	handler := accessLog.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/companies", nil))
+	if out.Len() != 0 {
+		t.Errorf("expected the request to be sampled out, got %q", out.String())
+	}
+
+	// Server errors are logged regardless of sampling.
+	status = http.StatusServiceUnavailable
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/companies", nil))
+	var entry map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("expected one JSON line, got %q: %v", out.String(), err)
+	}
+	if entry["method"] != "GET" || entry["path"] != "/v1/companies" || entry["status"] != float64(503) || entry["request_id"] == "" {
+		t.Errorf("unexpected access log entry %v", entry)
+	}
+	if _, ok := entry["user"]; ok {
+		t.Errorf("expected no user for an anonymous request, got %v", entry["user"])
+	}
+}
+
+func TestParseAccessLogFormat(t *testing.T) {
+	if format, err := ParseAccessLogFormat(""); err != nil || format != AccessLogCombined {
+		t.Errorf("expected the combined format by default, got %q, %v", format, err)
+	}
+	if _, err := ParseAccessLogFormat("common"); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
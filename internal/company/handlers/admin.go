@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+	"time"
+)
+
+// readinessTimeout bounds how long readiness checks may take.
+const readinessTimeout = 2 * time.Second
+
+// adminServer serves operational endpoints on an internal port, keeping them
+// off the public HTTP gateway.
+type adminServer struct {
+	server *http.Server
+	mux    *http.ServeMux
+
+	mu     sync.RWMutex
+	checks map[string]func(context.Context) (interface{}, error)
+}
+
+// EnableAdmin serves health, metrics and pprof endpoints on port:
+//
+//	/healthz          liveness
+//	/readyz           readiness, running the checks added with AddReadinessCheck
+//	                  and AddReadinessStatus
+//	/metrics          expvar metrics
+//	/debug/pprof/...  runtime profiles
+//
+// Further admin APIs can be mounted with HandleAdmin. It must be called
+// before Start.
+func (s *Server) EnableAdmin(port int) {
+	a := &adminServer{
+		mux:    http.NewServeMux(),
+		checks: make(map[string]func(context.Context) (interface{}, error)),
+	}
+	a.server = &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: a.mux}
+
+	a.mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	a.mux.HandleFunc("/readyz", a.ready)
+	a.mux.Handle("/metrics", expvar.Handler())
+	a.mux.HandleFunc("/debug/pprof/", pprof.Index)
+	a.mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	a.mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	a.mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	a.mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	s.admin = a
+}
+
+// HandleAdmin mounts handler on the admin port. EnableAdmin must be called
+// first.
+func (s *Server) HandleAdmin(pattern string, handler http.Handler) {
+	s.admin.mux.Handle(pattern, handler)
+}
+
+// AddReadinessCheck registers a check reported by /readyz. EnableAdmin must
+// be called first.
+func (s *Server) AddReadinessCheck(name string, check func(context.Context) error) {
+	s.AddReadinessStatus(name, func(ctx context.Context) (interface{}, error) {
+		return nil, check(ctx)
+	})
+}
+
+// AddReadinessStatus registers a check reported by /readyz along with the
+// status it returns, such as the schema version, which /readyz shows under
+// its name whether or not the check fails. EnableAdmin must be called first.
+func (s *Server) AddReadinessStatus(name string, check func(context.Context) (interface{}, error)) {
+	s.admin.mu.Lock()
+	defer s.admin.mu.Unlock()
+	s.admin.checks[name] = check
+}
+
+// readiness is the body of /readyz.
+type readiness struct {
+	Failures map[string]string      `json:"failures"`
+	Status   map[string]interface{} `json:"status,omitempty"`
+}
+
+// ready runs every readiness check, responding 503 with the failures if any
+// check fails.
+func (a *adminServer) ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	body := readiness{Failures: make(map[string]string), Status: make(map[string]interface{})}
+	for name, check := range a.checks {
+		status, err := check(ctx)
+		if err != nil {
+			body.Failures[name] = err.Error()
+		}
+		if status != nil {
+			body.Status[name] = status
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(body.Failures) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestServer_Admin(t *testing.T) {
+	s := NewServer(50051, 8080, zaptest.NewLogger(t))
+	s.EnableAdmin(9090)
+
+	healthy := true
+	s.AddReadinessCheck("database", func(context.Context) error {
+		if !healthy {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	s.AddReadinessStatus("migrations", func(context.Context) (interface{}, error) {
+		return map[string]int{"version": 3}, nil
+	})
+	s.HandleAdmin("/admin/ping", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.admin.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/healthz", http.StatusOK},
+		{"/readyz", http.StatusOK},
+		{"/metrics", http.StatusOK},
+		{"/debug/pprof/", http.StatusOK},
+		{"/admin/ping", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		if got := get(tt.path).Code; got != tt.want {
+			t.Errorf("GET %s: expected status %d, got %d", tt.path, tt.want, got)
+		}
+	}
+
+	if body := get("/readyz").Body.String(); !strings.Contains(body, `"status":{"migrations":{"version":3}}`) {
+		t.Errorf("expected the migration status in body, got %q", body)
+	}
+
+	healthy = false
+	rec := get("/readyz")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 from failing readiness check, got %d", rec.Code)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "connection refused") {
+		t.Errorf("expected failure details in body, got %q", body)
+	}
+}
+
+func TestServer_AdminNotOnGateway(t *testing.T) {
+	s := NewServer(50051, 8080, zaptest.NewLogger(t))
+	s.EnableAdmin(9090)
+	if err := s.RegisterHTTPGateway(context.Background(), []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, "secret"); err != nil {
+		t.Fatalf("RegisterHTTPGateway failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	s.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected operational endpoints to be absent from the gateway, got %d", rec.Code)
+	}
+}
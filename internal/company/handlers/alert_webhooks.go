@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/integrations"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// webhookKinds maps the proto webhook kinds to the model ones.
+var webhookKinds = map[pb.AlertWebhookKind]models.WebhookKind{
+	pb.AlertWebhookKind_SLACK: models.WebhookSlack,
+	pb.AlertWebhookKind_TEAMS: models.WebhookTeams,
+}
+
+// SetAlertWebhooks serves the alert webhook methods with alerts.
+func (h *CompanyHandler) SetAlertWebhooks(alerts AlertWebhookManager) {
+	h.alerts = alerts
+}
+
+// CreateAlertWebhook registers a chat webhook alerted about company events.
+func (h *CompanyHandler) CreateAlertWebhook(ctx context.Context, req *pb.CreateAlertWebhookRequest) (*pb.CreateAlertWebhookResponse, error) {
+	if h.alerts == nil {
+		return nil, status.Error(codes.Unimplemented, "alert webhooks are not enabled")
+	}
+	in := req.GetWebhook()
+	if in == nil {
+		return nil, status.Error(codes.InvalidArgument, "webhook required")
+	}
+	kind, ok := webhookKinds[in.GetKind()]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "webhook kind required")
+	}
+	webhook := &models.AlertWebhook{
+		Name:         in.GetName(),
+		Kind:         kind,
+		URL:          in.GetUrl(),
+		EventTypes:   in.GetEventTypes(),
+		MinEmployees: int(in.GetMinEmployees()),
+	}
+	if err := h.alerts.CreateAlertWebhook(ctx, webhook); err != nil {
+		h.logger.Error("Create alert webhook failed", zap.Error(err))
+		return nil, h.mapServiceError(err)
+	}
+	return &pb.CreateAlertWebhookResponse{Webhook: alertWebhookToProto(webhook)}, nil
+}
+
+// ListAlertWebhooks returns every alert webhook, with redacted URLs.
+func (h *CompanyHandler) ListAlertWebhooks(ctx context.Context, _ *pb.ListAlertWebhooksRequest) (*pb.ListAlertWebhooksResponse, error) {
+	if h.alerts == nil {
+		return nil, status.Error(codes.Unimplemented, "alert webhooks are not enabled")
+	}
+	webhooks, err := h.alerts.ListAlertWebhooks(ctx)
+	if err != nil {
+		h.logger.Error("List alert webhooks failed", zap.Error(err))
+		return nil, h.mapServiceError(err)
+	}
+	resp := &pb.ListAlertWebhooksResponse{Webhooks: make([]*pb.AlertWebhook, 0, len(webhooks))}
+	for i := range webhooks {
+		resp.Webhooks = append(resp.Webhooks, alertWebhookToProto(&webhooks[i]))
+	}
+	return resp, nil
+}
+
+// DeleteAlertWebhook removes an alert webhook.
+func (h *CompanyHandler) DeleteAlertWebhook(ctx context.Context, req *pb.DeleteAlertWebhookRequest) (*pb.DeleteAlertWebhookResponse, error) {
+	if h.alerts == nil {
+		return nil, status.Error(codes.Unimplemented, "alert webhooks are not enabled")
+	}
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid webhook ID")
+	}
+	if err := h.alerts.DeleteAlertWebhook(ctx, id); err != nil {
+		h.logger.Error("Delete alert webhook failed", zap.Error(err), zap.String("webhook_id", id.String()))
+		return nil, h.mapServiceError(err)
+	}
+	return &pb.DeleteAlertWebhookResponse{}, nil
+}
+
+// PreviewEvent renders the event a change of a company would publish and
+// what each alert webhook would be posted about it, delivering nothing.
+func (h *CompanyHandler) PreviewEvent(ctx context.Context, req *pb.PreviewEventRequest) (*pb.PreviewEventResponse, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+	eventType := events.EventType(req.GetEventType())
+	if !eventType.Valid() {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown event type %q", req.GetEventType())
+	}
+	company, err := h.service.GetCompany(ctx, id)
+	if err != nil {
+		return nil, h.mapServiceError(err)
+	}
+	identity, _ := auth.FromContext(ctx)
+	event := events.Event{EventID: uuid.New(), Type: eventType, Company: company, Actor: identity.UserID}
+	data, err := events.JSONCodec{}.Marshal(event)
+	if err != nil {
+		h.logger.Error("Encode event preview failed", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to encode event")
+	}
+	resp := &pb.PreviewEventResponse{Event: string(data), Alerts: []*pb.AlertPreview{}}
+	if h.alerts == nil {
+		return resp, nil
+	}
+	webhooks, err := h.alerts.ListAlertWebhooks(ctx)
+	if err != nil {
+		h.logger.Error("List alert webhooks failed", zap.Error(err))
+		return nil, h.mapServiceError(err)
+	}
+	for i := range webhooks {
+		payload, err := integrations.Payload(webhooks[i].Kind, event)
+		if err != nil {
+			h.logger.Error("Encode alert preview failed", zap.Error(err))
+			return nil, status.Error(codes.Internal, "failed to encode alert")
+		}
+		resp.Alerts = append(resp.Alerts, &pb.AlertPreview{
+			Webhook: alertWebhookToProto(&webhooks[i]),
+			Matches: integrations.Matches(webhooks[i], event),
+			Payload: string(payload),
+		})
+	}
+	return resp, nil
+}
+
+// alertWebhookToProto converts a webhook for a response, leaving the secret
+// part of its URL out.
+func alertWebhookToProto(webhook *models.AlertWebhook) *pb.AlertWebhook {
+	out := &pb.AlertWebhook{
+		Id:           webhook.ID.String(),
+		Name:         webhook.Name,
+		Url:          integrations.RedactURL(webhook.URL),
+		EventTypes:   webhook.EventTypes,
+		MinEmployees: int32(webhook.MinEmployees),
+		CreatedBy:    webhook.CreatedBy,
+		CreatedAt:    timestamppb.New(webhook.CreatedAt),
+	}
+	for kind, k := range webhookKinds {
+		if k == webhook.Kind {
+			out.Kind = kind
+		}
+	}
+	return out
+}
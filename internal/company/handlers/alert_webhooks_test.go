@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/company/auth"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mockAlertWebhooks is an AlertWebhookManager keeping webhooks in memory.
+type mockAlertWebhooks struct {
+	webhooks []models.AlertWebhook
+}
+
+func (m *mockAlertWebhooks) CreateAlertWebhook(_ context.Context, webhook *models.AlertWebhook) error {
+	if webhook.Name == "" {
+		return e.ErrInvalidInput
+	}
+	webhook.ID = uuid.New()
+	m.webhooks = append(m.webhooks, *webhook)
+	return nil
+}
+
+func (m *mockAlertWebhooks) ListAlertWebhooks(context.Context) ([]models.AlertWebhook, error) {
+	return m.webhooks, nil
+}
+
+func (m *mockAlertWebhooks) DeleteAlertWebhook(_ context.Context, id uuid.UUID) error {
+	for i, w := range m.webhooks {
+		if w.ID == id {
+			m.webhooks = append(m.webhooks[:i], m.webhooks[i+1:]...)
+			return nil
+		}
+	}
+	return e.ErrNotFound
+}
+
+func TestCompanyHandler_AlertWebhooks(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("NotEnabled", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.ListAlertWebhooks(context.Background(), &pb.ListAlertWebhooksRequest{})
+		if status.Code(err) != codes.Unimplemented {
+			t.Errorf("expected code %v, got %v", codes.Unimplemented, status.Code(err))
+		}
+	})
+
+	alerts := &mockAlertWebhooks{}
+	handler := NewCompanyHandler(&mockCompanyController{}, logger)
+	handler.SetAlertWebhooks(alerts)
+
+	t.Run("CreateUnspecifiedKind", func(t *testing.T) {
+		_, err := handler.CreateAlertWebhook(context.Background(), &pb.CreateAlertWebhookRequest{
+			Webhook: &pb.AlertWebhook{Name: "#sales", Url: "https://hooks.slack.com/services/secret"},
+		})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("CreateInvalid", func(t *testing.T) {
+		_, err := handler.CreateAlertWebhook(context.Background(), &pb.CreateAlertWebhookRequest{
+			Webhook: &pb.AlertWebhook{Kind: pb.AlertWebhookKind_SLACK},
+		})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	var created *pb.AlertWebhook
+	t.Run("Create", func(t *testing.T) {
+		resp, err := handler.CreateAlertWebhook(context.Background(), &pb.CreateAlertWebhookRequest{
+			Webhook: &pb.AlertWebhook{
+				Name:         "#sales",
+				Kind:         pb.AlertWebhookKind_TEAMS,
+				Url:          "https://acme.webhook.office.com/webhookb2/secret",
+				EventTypes:   []string{"company_created"},
+				MinEmployees: 1000,
+			},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		created = resp.GetWebhook()
+		if created.GetUrl() != "https://acme.webhook.office.com" {
+			t.Errorf("expected the URL to be redacted, got %q", created.GetUrl())
+		}
+		if created.GetKind() != pb.AlertWebhookKind_TEAMS || created.GetMinEmployees() != 1000 {
+			t.Errorf("unexpected webhook %v", created)
+		}
+		if got := alerts.webhooks[0]; got.Kind != models.WebhookTeams || got.URL != "https://acme.webhook.office.com/webhookb2/secret" {
+			t.Errorf("unexpected stored webhook %+v", got)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		resp, err := handler.ListAlertWebhooks(context.Background(), &pb.ListAlertWebhooksRequest{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(resp.GetWebhooks()) != 1 || resp.GetWebhooks()[0].GetId() != created.GetId() {
+			t.Errorf("unexpected webhooks %v", resp.GetWebhooks())
+		}
+	})
+
+	t.Run("PreviewEvent", func(t *testing.T) {
+		company := &models.Company{ID: uuid.New(), Name: "Acme", Employees: 1200}
+		svc := &mockCompanyController{getCompanyFunc: func(context.Context, uuid.UUID) (*models.Company, error) {
+			return company, nil
+		}}
+		handler := NewCompanyHandler(svc, logger)
+		handler.SetAlertWebhooks(alerts)
+		ctx := auth.NewContext(context.Background(), auth.Identity{UserID: "root"})
+
+		_, err := handler.PreviewEvent(ctx, &pb.PreviewEventRequest{Id: company.ID.String(), EventType: "company_renamed"})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v for an unknown event type, got %v", codes.InvalidArgument, status.Code(err))
+		}
+		resp, err := handler.PreviewEvent(ctx, &pb.PreviewEventRequest{Id: company.ID.String(), EventType: "company_updated"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(resp.GetEvent(), `"Type":"company_updated"`) || !strings.Contains(resp.GetEvent(), `"Actor":"root"`) {
+			t.Errorf("unexpected event %s", resp.GetEvent())
+		}
+		if len(resp.GetAlerts()) != 1 {
+			t.Fatalf("expected one alert preview, got %v", resp.GetAlerts())
+		}
+		alert := resp.GetAlerts()[0]
+		if alert.GetMatches() {
+			t.Error("expected the update not to match a webhook filtering on creations")
+		}
+		if !strings.Contains(alert.GetPayload(), `"title":"Company updated"`) || alert.GetWebhook().GetUrl() != "https://acme.webhook.office.com" {
+			t.Errorf("unexpected alert preview %v", alert)
+		}
+		resp, err = handler.PreviewEvent(ctx, &pb.PreviewEventRequest{Id: company.ID.String(), EventType: "company_created"})
+		if err != nil || !resp.GetAlerts()[0].GetMatches() {
+			t.Errorf("expected the creation to match, got %v, %v", resp, err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if _, err := handler.DeleteAlertWebhook(context.Background(), &pb.DeleteAlertWebhookRequest{Id: "bad"}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+		if _, err := handler.DeleteAlertWebhook(context.Background(), &pb.DeleteAlertWebhookRequest{Id: created.GetId()}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, err := handler.DeleteAlertWebhook(context.Background(), &pb.DeleteAlertWebhookRequest{Id: created.GetId()})
+		if status.Code(err) != codes.NotFound {
+			t.Errorf("expected code %v, got %v", codes.NotFound, status.Code(err))
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+
+	"google.golang.org/grpc"
+)
+
+// Positions of the built-in middleware in a Chain; lower runs first. They
+// are spaced so that new middleware can be placed between them.
+const (
+	OrderAccessLog     = 50
+	OrderRecovery      = 100
+	OrderLocalize      = 200
+	OrderLogging       = 300
+	OrderLoadShedding  = 400
+	OrderTimeouts      = 450
+	OrderDeprecation   = 500
+	OrderAuth          = 600
+	OrderQuotaWarnings = 650
+	OrderTransactions  = 700
+	OrderCompliance    = 800
+)
+
+// Middleware is a cross-cutting feature applied to the calls of the server:
+// a gRPC interceptor, an HTTP middleware wrapping the gateway, or both.
+// Gateway requests reach the gRPC interceptors too, so HTTP middleware is
+// only needed for what happens before the gateway, such as recovering from
+// its panics.
+type Middleware struct {
+	// Name identifies the middleware in configuration, e.g. "logging".
+	Name string
+	// Order positions the middleware in the chain; lower runs first.
+	Order int
+	// Required middleware, such as authentication, cannot be disabled.
+	Required bool
+	Unary    grpc.UnaryServerInterceptor
+	Stream   grpc.StreamServerInterceptor
+	HTTP     func(http.Handler) http.Handler
+}
+
+// Chain is an ordered registry of Middleware, so that features are added by
+// registering them instead of rewiring the server. Middleware can be
+// disabled by name.
+type Chain struct {
+	middleware []Middleware
+	disabled   map[string]bool
+}
+
+// NewChain returns an empty Chain skipping the middleware named in disabled.
+func NewChain(disabled ...string) *Chain {
+	c := &Chain{disabled: make(map[string]bool, len(disabled))}
+	for _, name := range disabled {
+		c.disabled[name] = true
+	}
+	return c
+}
+
+// Register adds m to the chain. Middleware of the same order runs in the
+// order registered.
+func (c *Chain) Register(m Middleware) error {
+	if m.Name == "" {
+		return fmt.Errorf("middleware needs a name")
+	}
+	if slices.ContainsFunc(c.middleware, func(r Middleware) bool { return r.Name == m.Name }) {
+		return fmt.Errorf("middleware %q registered twice", m.Name)
+	}
+	if m.Required && c.disabled[m.Name] {
+		return fmt.Errorf("middleware %q cannot be disabled", m.Name)
+	}
+	c.middleware = append(c.middleware, m)
+	sort.SliceStable(c.middleware, func(i, j int) bool { return c.middleware[i].Order < c.middleware[j].Order })
+	return nil
+}
+
+// Check reports disabled names matching no registered middleware, typically
+// misspelled configuration.
+func (c *Chain) Check() error {
+	for name := range c.disabled {
+		if !slices.ContainsFunc(c.middleware, func(m Middleware) bool { return m.Name == name }) {
+			return fmt.Errorf("unknown middleware %q", name)
+		}
+	}
+	return nil
+}
+
+// Names returns the names of the enabled middleware in the order they run.
+func (c *Chain) Names() []string {
+	var names []string
+	for _, m := range c.enabled() {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+// ServerOptions returns the gRPC server options installing the unary and
+// stream interceptors of the enabled middleware.
+func (c *Chain) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(c.unaryInterceptors()...),
+		grpc.ChainStreamInterceptor(c.streamInterceptors()...),
+	}
+}
+
+func (c *Chain) unaryInterceptors() []grpc.UnaryServerInterceptor {
+	var interceptors []grpc.UnaryServerInterceptor
+	for _, m := range c.enabled() {
+		if m.Unary != nil {
+			interceptors = append(interceptors, m.Unary)
+		}
+	}
+	return interceptors
+}
+
+func (c *Chain) streamInterceptors() []grpc.StreamServerInterceptor {
+	var interceptors []grpc.StreamServerInterceptor
+	for _, m := range c.enabled() {
+		if m.Stream != nil {
+			interceptors = append(interceptors, m.Stream)
+		}
+	}
+	return interceptors
+}
+
+// Handler wraps h in the HTTP middleware of the enabled middleware, the
+// first running outermost.
+func (c *Chain) Handler(h http.Handler) http.Handler {
+	enabled := c.enabled()
+	for i := len(enabled) - 1; i >= 0; i-- {
+		if enabled[i].HTTP != nil {
+			h = enabled[i].HTTP(h)
+		}
+	}
+	return h
+}
+
+func (c *Chain) enabled() []Middleware {
+	var enabled []Middleware
+	for _, m := range c.middleware {
+		if !c.disabled[m.Name] {
+			enabled = append(enabled, m)
+		}
+	}
+	return enabled
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+// recording returns middleware appending its name to calls when it runs.
+func recording(name string, order int, calls *[]string) Middleware {
+	return Middleware{
+		Name:  name,
+		Order: order,
+		Unary: func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			*calls = append(*calls, name)
+			return handler(ctx, req)
+		},
+		Stream: func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			*calls = append(*calls, name)
+			return handler(srv, ss)
+		},
+		HTTP: func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				*calls = append(*calls, name)
+				next.ServeHTTP(w, r)
+			})
+		},
+	}
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	chain := NewChain("logging")
+	for _, m := range []Middleware{
+		recording("auth", OrderAuth, &calls),
+		recording("logging", OrderLogging, &calls),
+		recording("recovery", OrderRecovery, &calls),
+		{Name: "grpc-only", Order: OrderAuth, Unary: recording("grpc-only", 0, &calls).Unary},
+	} {
+		if err := chain.Register(m); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := chain.Check(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"recovery", "auth", "grpc-only"}; !slices.Equal(chain.Names(), want) {
+		t.Errorf("expected %v, got %v", want, chain.Names())
+	}
+
+	handler := chain.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { calls = append(calls, "handler") }))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/companies", nil))
+	if want := []string{"recovery", "auth", "handler"}; !slices.Equal(calls, want) {
+		t.Errorf("expected HTTP calls %v, got %v", want, calls)
+	}
+
+	calls = nil
+	var unary grpc.UnaryHandler = func(context.Context, interface{}) (interface{}, error) {
+		calls = append(calls, "handler")
+		return nil, nil
+	}
+	interceptors := chain.unaryInterceptors()
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], unary
+		unary = func(ctx context.Context, req interface{}) (interface{}, error) {
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{}, next)
+		}
+	}
+	_, _ = unary(context.Background(), nil)
+	if want := []string{"recovery", "auth", "grpc-only", "handler"}; !slices.Equal(calls, want) {
+		t.Errorf("expected gRPC calls %v, got %v", want, calls)
+	}
+
+	calls = nil
+	var stream grpc.StreamHandler = func(interface{}, grpc.ServerStream) error {
+		calls = append(calls, "handler")
+		return nil
+	}
+	streamInterceptors := chain.streamInterceptors()
+	for i := len(streamInterceptors) - 1; i >= 0; i-- {
+		interceptor, next := streamInterceptors[i], stream
+		stream = func(srv interface{}, ss grpc.ServerStream) error {
+			return interceptor(srv, ss, &grpc.StreamServerInfo{}, next)
+		}
+	}
+	_ = stream(nil, nil)
+	if want := []string{"recovery", "auth", "handler"}; !slices.Equal(calls, want) {
+		t.Errorf("expected gRPC stream calls %v, got %v", want, calls)
+	}
+}
+
+func TestChain_Invalid(t *testing.T) {
+	chain := NewChain("auth", "typo")
+	if err := chain.Register(Middleware{Name: "auth", Order: OrderAuth, Required: true}); err == nil {
+		t.Error("expected required middleware to refuse being disabled")
+	}
+	if err := chain.Register(Middleware{Name: "logging", Order: OrderLogging}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := chain.Register(Middleware{Name: "logging", Order: OrderLogging}); err == nil {
+		t.Error("expected duplicate names to be refused")
+	}
+	if err := chain.Check(); err == nil {
+		t.Error("expected unknown disabled names to be reported")
+	}
+}
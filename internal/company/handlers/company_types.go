@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/company/models"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SetCompanyTypes serves the company type methods with companyTypes.
+func (h *CompanyHandler) SetCompanyTypes(companyTypes CompanyTypeManager) {
+	h.companyTypes = companyTypes
+}
+
+// errCompanyTypesDisabled answers the company type methods while no
+// CompanyTypeManager is set.
+var errCompanyTypesDisabled = status.Error(codes.Unimplemented, "the company type taxonomy is not enabled")
+
+// ListCompanyTypes returns the company type taxonomy.
+func (h *CompanyHandler) ListCompanyTypes(ctx context.Context, _ *pb.ListCompanyTypesRequest) (*pb.ListCompanyTypesResponse, error) {
+	if h.companyTypes == nil {
+		return nil, errCompanyTypesDisabled
+	}
+	types, err := h.companyTypes.ListCompanyTypes(ctx)
+	if err != nil {
+		return nil, h.mapServiceError(err)
+	}
+	resp := &pb.ListCompanyTypesResponse{}
+	for i := range types {
+		resp.CompanyTypes = append(resp.CompanyTypes, companyTypeToProto(&types[i]))
+	}
+	return resp, nil
+}
+
+// CreateCompanyType adds a type to the taxonomy.
+func (h *CompanyHandler) CreateCompanyType(ctx context.Context, req *pb.CreateCompanyTypeRequest) (*pb.CreateCompanyTypeResponse, error) {
+	if h.companyTypes == nil {
+		return nil, errCompanyTypesDisabled
+	}
+	if req.GetCompanyType() == nil {
+		return nil, status.Error(codes.InvalidArgument, "company type required")
+	}
+
+	created, err := h.companyTypes.CreateCompanyType(ctx, &models.CompanyTypeDefinition{
+		Code:        models.CompanyType(req.GetCompanyType().GetCode()),
+		Name:        req.GetCompanyType().GetName(),
+		Description: req.GetCompanyType().GetDescription(),
+	})
+	if err != nil {
+		h.logger.Error("Create company type failed", zap.Error(err), zap.String("code", req.GetCompanyType().GetCode()))
+		return nil, h.mapServiceError(err)
+	}
+	setCreated(ctx, "/v1/companyTypes/"+string(created.Code))
+	return &pb.CreateCompanyTypeResponse{CompanyType: companyTypeToProto(created)}, nil
+}
+
+// UpdateCompanyType sets the name and description of a type.
+func (h *CompanyHandler) UpdateCompanyType(ctx context.Context, req *pb.UpdateCompanyTypeRequest) (*pb.UpdateCompanyTypeResponse, error) {
+	if h.companyTypes == nil {
+		return nil, errCompanyTypesDisabled
+	}
+	if req.GetCompanyType() == nil {
+		return nil, status.Error(codes.InvalidArgument, "company type required")
+	}
+
+	updated, err := h.companyTypes.UpdateCompanyType(ctx, &models.CompanyTypeDefinition{
+		Code:        models.CompanyType(req.GetCode()),
+		Name:        req.GetCompanyType().GetName(),
+		Description: req.GetCompanyType().GetDescription(),
+	})
+	if err != nil {
+		h.logger.Error("Update company type failed", zap.Error(err), zap.String("code", req.GetCode()))
+		return nil, h.mapServiceError(err)
+	}
+	return &pb.UpdateCompanyTypeResponse{CompanyType: companyTypeToProto(updated)}, nil
+}
+
+// DeleteCompanyType removes a type from the taxonomy.
+func (h *CompanyHandler) DeleteCompanyType(ctx context.Context, req *pb.DeleteCompanyTypeRequest) (*pb.DeleteCompanyTypeResponse, error) {
+	if h.companyTypes == nil {
+		return nil, errCompanyTypesDisabled
+	}
+	if err := h.companyTypes.DeleteCompanyType(ctx, models.CompanyType(req.GetCode())); err != nil {
+		return nil, h.mapServiceError(err)
+	}
+	setNoContent(ctx)
+	return &pb.DeleteCompanyTypeResponse{}, nil
+}
+
+// companyTypeToProto converts a company type for a response.
+func companyTypeToProto(companyType *models.CompanyTypeDefinition) *pb.CompanyTypeDefinition {
+	resp := &pb.CompanyTypeDefinition{
+		Code:        string(companyType.Code),
+		Name:        companyType.Name,
+		Description: companyType.Description,
+		Builtin:     companyType.Builtin,
+		CreatedBy:   companyType.CreatedBy,
+		UpdatedBy:   companyType.UpdatedBy,
+	}
+	if companyType.Builtin {
+		resp.LegacyType = pb.CompanyType(pb.CompanyType_value[string(companyType.Code)])
+	}
+	if !companyType.CreatedAt.IsZero() {
+		resp.CreatedAt = timestamppb.New(companyType.CreatedAt)
+	}
+	if !companyType.UpdatedAt.IsZero() {
+		resp.UpdatedAt = timestamppb.New(companyType.UpdatedAt)
+	}
+	return resp
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mockCompanyTypes is a CompanyTypeManager starting with the built-in types.
+type mockCompanyTypes struct {
+	types []models.CompanyTypeDefinition
+}
+
+func (m *mockCompanyTypes) ListCompanyTypes(context.Context) ([]models.CompanyTypeDefinition, error) {
+	return m.types, nil
+}
+
+func (m *mockCompanyTypes) CreateCompanyType(_ context.Context, companyType *models.CompanyTypeDefinition) (*models.CompanyTypeDefinition, error) {
+	if m.find(companyType.Code) >= 0 {
+		return nil, e.Newf(e.CodeCompanyTypeExists, "company type %s exists", companyType.Code)
+	}
+	m.types = append(m.types, *companyType)
+	return companyType, nil
+}
+
+func (m *mockCompanyTypes) UpdateCompanyType(_ context.Context, companyType *models.CompanyTypeDefinition) (*models.CompanyTypeDefinition, error) {
+	i := m.find(companyType.Code)
+	if i < 0 {
+		return nil, e.ErrCompanyTypeNotFound
+	}
+	m.types[i].Name, m.types[i].Description = companyType.Name, companyType.Description
+	return &m.types[i], nil
+}
+
+func (m *mockCompanyTypes) DeleteCompanyType(_ context.Context, code models.CompanyType) error {
+	i := m.find(code)
+	if i < 0 {
+		return e.ErrCompanyTypeNotFound
+	}
+	if m.types[i].Builtin {
+		return e.Newf(e.CodeCompanyTypeBuiltin, "company type %s is built in", code)
+	}
+	m.types = slices.Delete(m.types, i, i+1)
+	return nil
+}
+
+func (m *mockCompanyTypes) find(code models.CompanyType) int {
+	return slices.IndexFunc(m.types, func(t models.CompanyTypeDefinition) bool { return t.Code == code })
+}
+
+func TestCompanyHandler_CompanyTypes(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	t.Run("NotEnabled", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.ListCompanyTypes(ctx, &pb.ListCompanyTypesRequest{})
+		if status.Code(err) != codes.Unimplemented {
+			t.Errorf("expected code %v, got %v", codes.Unimplemented, status.Code(err))
+		}
+	})
+
+	handler := NewCompanyHandler(&mockCompanyController{}, logger)
+	handler.SetCompanyTypes(&mockCompanyTypes{types: slices.Clone(models.BuiltinCompanyTypes)})
+
+	listed, err := handler.ListCompanyTypes(ctx, &pb.ListCompanyTypesRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(listed.GetCompanyTypes()) != len(models.BuiltinCompanyTypes) {
+		t.Fatalf("expected %d types, got %d", len(models.BuiltinCompanyTypes), len(listed.GetCompanyTypes()))
+	}
+	for _, companyType := range listed.GetCompanyTypes() {
+		if !companyType.GetBuiltin() || companyType.GetLegacyType().String() != companyType.GetCode() {
+			t.Errorf("expected built-in type %s to map to its enum value, got %v", companyType.GetCode(), companyType.GetLegacyType())
+		}
+	}
+
+	if _, err := handler.CreateCompanyType(ctx, &pb.CreateCompanyTypeRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected code %v without a company type, got %v", codes.InvalidArgument, status.Code(err))
+	}
+	created, err := handler.CreateCompanyType(ctx, &pb.CreateCompanyTypeRequest{
+		CompanyType: &pb.CompanyTypeDefinition{Code: "PARTNERSHIP", Name: "Partnership"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if created.GetCompanyType().GetBuiltin() || created.GetCompanyType().GetLegacyType() != pb.CompanyType_UNSPECIFIED {
+		t.Errorf("expected a custom type without an enum value, got %v", created.GetCompanyType())
+	}
+	_, err = handler.CreateCompanyType(ctx, &pb.CreateCompanyTypeRequest{
+		CompanyType: &pb.CompanyTypeDefinition{Code: "PARTNERSHIP", Name: "Partnership"},
+	})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("expected code %v for an existing type, got %v", codes.AlreadyExists, status.Code(err))
+	}
+
+	updated, err := handler.UpdateCompanyType(ctx, &pb.UpdateCompanyTypeRequest{
+		Code:        "PARTNERSHIP",
+		CompanyType: &pb.CompanyTypeDefinition{Name: "General partnership"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if updated.GetCompanyType().GetName() != "General partnership" {
+		t.Errorf("unexpected company type %v", updated.GetCompanyType())
+	}
+	_, err = handler.UpdateCompanyType(ctx, &pb.UpdateCompanyTypeRequest{
+		Code:        "TRUST",
+		CompanyType: &pb.CompanyTypeDefinition{Name: "Trust"},
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected code %v for an unknown type, got %v", codes.NotFound, status.Code(err))
+	}
+
+	if _, err := handler.DeleteCompanyType(ctx, &pb.DeleteCompanyTypeRequest{Code: string(models.Corporations)}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected code %v for a built-in type, got %v", codes.FailedPrecondition, status.Code(err))
+	}
+	if _, err := handler.DeleteCompanyType(ctx, &pb.DeleteCompanyTypeRequest{Code: "PARTNERSHIP"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// ComplianceStore appends compliance records, like
+// db.Repository.RecordCompliance.
+type ComplianceStore interface {
+	RecordCompliance(ctx context.Context, record *models.ComplianceRecord) error
+}
+
+// ComplianceRecorder stores the request and response payloads of every call
+// of the given methods, for regulated customers who must be able to show
+// what was changed, by whom and with which outcome. Failed calls are
+// recorded with their status instead of a response.
+type ComplianceRecorder struct {
+	store   ComplianceStore
+	methods map[string]bool
+	redact  redactor
+	logger  *zap.Logger
+}
+
+// NewComplianceRecorder returns a ComplianceRecorder appending the calls of
+// methods to store, with the values of redactFields replaced. Names match
+// the JSON (lowerCamelCase) field names; unlike request logging, no fields
+// are redacted by default, except credentials.
+func NewComplianceRecorder(store ComplianceStore, methods []string, redactFields []string, logger *zap.Logger) *ComplianceRecorder {
+	c := &ComplianceRecorder{
+		store:   store,
+		methods: make(map[string]bool, len(methods)),
+		redact:  make(redactor),
+		logger:  logger.Named("compliance"),
+	}
+	for _, method := range methods {
+		c.methods[method] = true
+	}
+	c.redact.add("password", "token", "secret", "apiKey", "authorization")
+	c.redact.add(redactFields...)
+	return c
+}
+
+// Unary returns the interceptor. It must run after the
+// TransactionInterceptor, so the record of a successful call commits with
+// its changes: a call whose record cannot be stored fails with INTERNAL and
+// is rolled back, and no change is made without a record.
+func (c *ComplianceRecorder) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if !c.methods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		resp, err := handler(ctx, req)
+
+		record := &models.ComplianceRecord{
+			Method:    info.FullMethod,
+			RequestID: RequestIDFromContext(ctx),
+			Code:      status.Code(err).String(),
+			Request:   c.payload(req),
+		}
+		if id, ok := auth.FromContext(ctx); ok {
+			record.UserID = id.UserID
+		}
+		if err != nil {
+			record.Error = status.Convert(err).Message()
+		} else {
+			record.Response = c.payload(resp)
+		}
+
+		if storeErr := c.store.RecordCompliance(ctx, record); storeErr != nil {
+			c.logger.Error("Failed to store compliance record",
+				zap.String("method", info.FullMethod),
+				zap.String("request_id", record.RequestID),
+				zap.Error(storeErr))
+			if err == nil {
+				internal, _ := e.Lookup(e.CodeInternal)
+				return nil, codeStatus(internal, "internal server error: failed to store compliance record").Err()
+			}
+		}
+		return resp, err
+	}
+}
+
+// payload renders msg as JSON with the configured fields redacted.
+func (c *ComplianceRecorder) payload(msg interface{}) string {
+	m, ok := msg.(proto.Message)
+	if !ok || m == nil {
+		return ""
+	}
+	v, err := c.redact.value(m)
+	if err != nil {
+		return "unmarshalable: " + err.Error()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "unmarshalable: " + err.Error()
+	}
+	return string(b)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/gartstein/xm/internal/company/models"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeComplianceStore struct {
+	records []*models.ComplianceRecord
+	err     error
+}
+
+func (f *fakeComplianceStore) RecordCompliance(_ context.Context, record *models.ComplianceRecord) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.records = append(f.records, record)
+	return nil
+}
+
+func TestComplianceRecorder(t *testing.T) {
+	store := &fakeComplianceStore{}
+	create := &grpc.UnaryServerInfo{FullMethod: "/definition.v1.CompanyService/CreateCompany"}
+	interceptor := NewComplianceRecorder(store, []string{create.FullMethod}, []string{"contactEmail"}, zaptest.NewLogger(t)).Unary()
+	ctx := auth.NewContext(context.WithValue(context.Background(), requestIDKey{}, "req-1"), auth.Identity{UserID: "user-1"})
+	req := &pb.CreateCompanyRequest{Company: &pb.Company{Name: "Acme", ContactEmail: "ceo@acme.test"}}
+
+	_, err := interceptor(ctx, req, create, func(context.Context, interface{}) (interface{}, error) {
+		return &pb.CreateCompanyResponse{Company: &pb.Company{Id: "c-1", Name: "Acme"}}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(store.records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(store.records))
+	}
+	record := store.records[0]
+	if record.Method != create.FullMethod || record.RequestID != "req-1" || record.UserID != "user-1" || record.Code != "OK" {
+		t.Errorf("unexpected record %+v", record)
+	}
+	var payload struct {
+		Company map[string]interface{} `json:"company"`
+	}
+	if err := json.Unmarshal([]byte(record.Request), &payload); err != nil {
+		t.Fatalf("expected a JSON request, got %q: %v", record.Request, err)
+	}
+	if payload.Company["name"] != "Acme" || payload.Company["contactEmail"] != redacted {
+		t.Errorf("expected the contact email to be redacted, got %v", payload.Company)
+	}
+	if record.Response == "" {
+		t.Error("expected the response to be recorded")
+	}
+
+	_, err = interceptor(ctx, req, create, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.AlreadyExists, "name taken")
+	})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("expected the handler error, got %v", err)
+	}
+	failed := store.records[1]
+	if failed.Code != "AlreadyExists" || failed.Error != "name taken" || failed.Response != "" {
+		t.Errorf("unexpected record of a failed call %+v", failed)
+	}
+
+	if _, err := interceptor(ctx, &pb.GetCompanyRequest{Id: "c-1"}, &grpc.UnaryServerInfo{FullMethod: "/definition.v1.CompanyService/GetCompany"},
+		func(context.Context, interface{}) (interface{}, error) { return &pb.GetCompanyResponse{}, nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(store.records) != 2 {
+		t.Errorf("expected other methods not to be recorded, got %d records", len(store.records))
+	}
+}
+
+// TestComplianceRecorder_FailsClosed verifies a successful call fails when
+// its record cannot be stored, while failed calls keep their error.
+func TestComplianceRecorder_FailsClosed(t *testing.T) {
+	store := &fakeComplianceStore{err: errors.New("disk full")}
+	create := &grpc.UnaryServerInfo{FullMethod: "/definition.v1.CompanyService/CreateCompany"}
+	interceptor := NewComplianceRecorder(store, []string{create.FullMethod}, nil, zaptest.NewLogger(t)).Unary()
+
+	resp, err := interceptor(context.Background(), &pb.CreateCompanyRequest{}, create, func(context.Context, interface{}) (interface{}, error) {
+		return &pb.CreateCompanyResponse{}, nil
+	})
+	if resp != nil || status.Code(err) != codes.Internal {
+		t.Errorf("expected INTERNAL, got %v, %v", resp, err)
+	}
+
+	_, err = interceptor(context.Background(), &pb.CreateCompanyRequest{}, create, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected the handler error, got %v", err)
+	}
+}
@@ -0,0 +1,484 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/pkg/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+var updateContracts = flag.Bool("update", false, "rewrite the responses in testdata/contract from the running server")
+
+const contractSecret = "contract-secret"
+
+var (
+	contractCompanyID = uuid.MustParse("7f3b2c1a-5d4e-4f60-8a9b-0c1d2e3f4a5b")
+	contractCreatedID = uuid.MustParse("00000000-0000-4000-8000-000000000001")
+	// contractDeletedID is a company already deleted, as seen by a service
+	// with idempotent deletes.
+	contractDeletedID = uuid.MustParse("00000000-0000-4000-8000-000000000002")
+	contractTime      = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+)
+
+// contractHeaders are the response headers recorded in, and compared
+// against, the fixtures. Others, such as Date, vary between runs.
+var contractHeaders = []string{"Content-Type", "Etag", "Cache-Control", "Www-Authenticate", "Deprecation", "Sunset", "Link", "Location"}
+
+// contractFixture is a request sent to the HTTP gateway and the response
+// clients rely on.
+type contractFixture struct {
+	Request struct {
+		Method  string            `json:"method"`
+		Path    string            `json:"path"`
+		Headers map[string]string `json:"headers,omitempty"`
+		Body    json.RawMessage   `json:"body,omitempty"`
+		// Anonymous sends the request without a bearer token.
+		Anonymous bool `json:"anonymous,omitempty"`
+	} `json:"request"`
+	Response contractResponse `json:"response"`
+}
+
+type contractResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is the JSON response, or a JSON string for other bodies.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// TestContract replays the fixtures in testdata/contract against the gRPC
+// server and HTTP gateway, with a controller returning fixed data, and fails
+// when the wire format of a response changes. Run with -update to accept an
+// intended change.
+func TestContract(t *testing.T) {
+	baseURL := startContractServer(t)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   "contract-user",
+		"roles": []string{auth.AdminRole},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(contractSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join("testdata", "contract", "*.json"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no contract fixtures found: %v", err)
+	}
+	for _, path := range paths {
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".json"), func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read fixture: %v", err)
+			}
+			var fixture contractFixture
+			if err := json.Unmarshal(data, &fixture); err != nil {
+				t.Fatalf("invalid fixture: %v", err)
+			}
+
+			req, err := http.NewRequest(fixture.Request.Method, baseURL+fixture.Request.Path, bytes.NewReader(fixture.Request.Body))
+			if err != nil {
+				t.Fatalf("invalid request: %v", err)
+			}
+			if len(fixture.Request.Body) > 0 {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if !fixture.Request.Anonymous {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			for key, value := range fixture.Request.Headers {
+				req.Header.Set(key, value)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			got, err := readContractResponse(resp)
+			if err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+
+			if *updateContracts {
+				fixture.Response = got
+				writeContractFixture(t, path, fixture)
+				return
+			}
+			want := fixture.Response
+			if got.Status != want.Status {
+				t.Errorf("expected status %d, got %d", want.Status, got.Status)
+			}
+			if !reflect.DeepEqual(got.Headers, want.Headers) {
+				t.Errorf("expected headers %v, got %v", want.Headers, got.Headers)
+			}
+			if !jsonEqual(got.Body, want.Body) {
+				t.Errorf("expected body\n%s\ngot\n%s", want.Body, got.Body)
+			}
+		})
+	}
+}
+
+// startContractServer serves the v1 and v2 handlers over a contract
+// controller on free ports, with the interceptors and gateway options the
+// service runs with, and returns the gateway's base URL.
+func startContractServer(t *testing.T) string {
+	t.Helper()
+	logger := zap.NewNop()
+	grpcPort, httpPort := freePort(t), freePort(t)
+
+	interceptor := auth.NewAuthInterceptor(contractSecret)
+	s := NewServer(grpcPort, httpPort, logger, grpc.ChainUnaryInterceptor(
+		NewLocalizer().Unary(),
+		NewLoggingInterceptor(logger).Unary(),
+		interceptor.Unary(),
+	))
+	handler := NewCompanyHandler(contractController{}, logger)
+	handler.SetAlertWebhooks(contractAlertWebhooks{})
+	s.RegisterGRPCHandler(handler)
+	s.RegisterGRPCHandlerV2(NewCompanyHandlerV2(contractController{}, logger))
+	s.LimitRequestBody(1 << 20)
+	err := s.RegisterHTTPGateway(context.Background(), []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, contractSecret)
+	if err != nil {
+		t.Fatalf("RegisterHTTPGateway failed: %v", err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.Start() }()
+	t.Cleanup(func() {
+		s.Stop()
+		if err := <-errCh; err != nil {
+			t.Errorf("server returned error: %v", err)
+		}
+	})
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", httpPort)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get(baseURL + "/v1/companies/" + contractCompanyID.String())
+		if err == nil {
+			resp.Body.Close()
+			return baseURL
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server did not start: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// freePort returns a TCP port that was free when it was checked.
+func freePort(t *testing.T) int {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	defer lis.Close()
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+func readContractResponse(resp *http.Response) (contractResponse, error) {
+	got := contractResponse{Status: resp.StatusCode}
+	for _, key := range contractHeaders {
+		if value := resp.Header.Get(key); value != "" {
+			if got.Headers == nil {
+				got.Headers = make(map[string]string)
+			}
+			got.Headers[key] = value
+		}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return got, err
+	}
+	switch {
+	case len(body) == 0:
+	case json.Valid(body):
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, body); err != nil {
+			return got, err
+		}
+		got.Body = buf.Bytes()
+	default:
+		if got.Body, err = json.Marshal(string(body)); err != nil {
+			return got, err
+		}
+	}
+	return got, nil
+}
+
+func writeContractFixture(t *testing.T, path string, fixture contractFixture) {
+	t.Helper()
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to encode fixture: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+}
+
+// jsonEqual reports whether a and b hold the same JSON value, ignoring
+// formatting and key order.
+func jsonEqual(a, b json.RawMessage) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// contractController returns fixed data so responses are identical on every
+// run. It keeps no state: creates, updates and deletes are not stored.
+type contractController struct{}
+
+func (contractController) company() models.Company {
+	return models.Company{
+		ID:            contractCompanyID,
+		Name:          "Acme",
+		Description:   "Anvils and rockets",
+		Employees:     42,
+		EmployeeRange: models.EmployeeRangeFor(42),
+		Registered:    true,
+		Status:        models.StatusActive,
+		Type:          models.Corporations,
+		ExternalRef:   "ERP-1",
+		CreatedBy:     "founder",
+		UpdatedBy:     "founder",
+		CreatedAt:     contractTime,
+		UpdatedAt:     contractTime,
+	}
+}
+
+func (c contractController) CreateCompany(_ context.Context, company *models.Company, _ models.CreateOptions) (*models.Company, error) {
+	switch {
+	case company.Name == "":
+		return nil, e.Newf(e.CodeNameRequired, "name required")
+	case len(company.Name) > 15:
+		return nil, e.Newf(e.CodeNameTooLong, "name longer than 15 characters")
+	case company.Name == c.company().Name:
+		return nil, e.ErrDuplicateName
+	}
+	created := *company
+	created.ID = contractCreatedID
+	if created.Status == "" {
+		created.Status = models.StatusActive
+	}
+	created.EmployeeRange = models.EmployeeRangeFor(created.Employees)
+	created.CreatedBy, created.UpdatedBy = "contract-user", "contract-user"
+	created.CreatedAt, created.UpdatedAt = contractTime, contractTime
+	return &created, nil
+}
+
+func (c contractController) GetCompany(_ context.Context, id uuid.UUID) (*models.Company, error) {
+	if id != contractCompanyID {
+		return nil, e.ErrCompanyNotFound
+	}
+	company := c.company()
+	return &company, nil
+}
+
+func (c contractController) GetCompanyByName(ctx context.Context, name string) (*models.Company, error) {
+	if name != c.company().Name {
+		return nil, e.ErrCompanyNotFound
+	}
+	return c.GetCompany(ctx, contractCompanyID)
+}
+
+func (c contractController) GetCompanyByExternalRef(ctx context.Context, ref string) (*models.Company, error) {
+	if ref != c.company().ExternalRef {
+		return nil, e.ErrCompanyNotFound
+	}
+	return c.GetCompany(ctx, contractCompanyID)
+}
+
+func (c contractController) ListCompanies(_ context.Context, filter models.CompanyFilter, _ int, _ string) ([]models.Company, string, error) {
+	company := c.company()
+	if filter.NameContains != "" && !strings.Contains(strings.ToLower(company.Name), strings.ToLower(filter.NameContains)) {
+		return []models.Company{}, "", nil
+	}
+	return []models.Company{company}, "", nil
+}
+
+func (c contractController) CountCompanies(_ context.Context, filter models.CompanyFilter) (int64, error) {
+	if len(filter.Statuses) > 0 {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+func (c contractController) ListMyCompanies(ctx context.Context, _ models.CompanyFilter, _ int, _ string) ([]models.Company, string, error) {
+	identity, _ := auth.FromContext(ctx)
+	company := c.company()
+	company.CreatedBy = identity.UserID
+	return []models.Company{company}, "", nil
+}
+
+func (c contractController) UpdateCompany(ctx context.Context, update *models.CompanyUpdate, _ models.UpdateOptions) (*models.Company, error) {
+	company, err := c.GetCompany(ctx, update.ID)
+	if err != nil {
+		return nil, err
+	}
+	if update.Name != nil {
+		company.Name = *update.Name
+	}
+	if update.Description != nil {
+		company.Description = *update.Description
+	}
+	if update.Employees != nil {
+		company.Employees = *update.Employees
+		company.EmployeeRange = models.EmployeeRangeFor(company.Employees)
+	}
+	if update.Registered != nil {
+		company.Registered = *update.Registered
+	}
+	if update.Status != nil {
+		if !company.Status.CanTransitionTo(*update.Status) {
+			return nil, e.ErrInvalidStatusTransition
+		}
+		company.Status = *update.Status
+	}
+	if update.Type != nil {
+		company.Type = *update.Type
+	}
+	if update.ExternalRef != nil {
+		company.ExternalRef = *update.ExternalRef
+	}
+	company.UpdatedBy = "contract-user"
+	return company, nil
+}
+
+func (c contractController) DeleteCompany(ctx context.Context, id uuid.UUID) (bool, error) {
+	if id == contractDeletedID {
+		return false, nil
+	}
+	_, err := c.GetCompany(ctx, id)
+	return err == nil, err
+}
+
+func (c contractController) PurgeCompany(ctx context.Context, id uuid.UUID) error {
+	_, err := c.DeleteCompany(ctx, id)
+	return err
+}
+
+func (c contractController) EraseCompanyData(ctx context.Context, id uuid.UUID) (*models.Company, error) {
+	company, err := c.GetCompany(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	company.Description, company.ContactEmail = "", ""
+	return company, nil
+}
+
+func (c contractController) SuspendCompany(ctx context.Context, id uuid.UUID) (*models.Company, error) {
+	return c.UpdateCompany(ctx, &models.CompanyUpdate{ID: id, Status: utils.Ptr(models.StatusSuspended)}, models.UpdateOptions{})
+}
+
+func (c contractController) ActivateCompany(ctx context.Context, id uuid.UUID) (*models.Company, error) {
+	return c.UpdateCompany(ctx, &models.CompanyUpdate{ID: id, Status: utils.Ptr(models.StatusActive)}, models.UpdateOptions{})
+}
+
+func (contractController) ReplayCompanyEvents(context.Context, models.CompanyEventFilter, string) (int, error) {
+	return 0, nil
+}
+
+func (c contractController) GetCompanyHistory(_ context.Context, id uuid.UUID, _, _ time.Time, _ int, _ string) ([]models.CompanyEvent, string, error) {
+	if id != contractCompanyID {
+		return []models.CompanyEvent{}, "", nil
+	}
+	return []models.CompanyEvent{{
+		ID:        contractCreatedID,
+		Type:      "company_updated",
+		CompanyID: id,
+		Actor:     "founder",
+		Company:   c.company(),
+		Changes:   map[string]models.FieldChange{"employees": {Old: 40, New: 42}, "description": {Old: "Anvils", New: "Anvils and rockets"}},
+		CreatedAt: contractTime,
+	}}, "", nil
+}
+
+func (contractController) WatchCompanyEvents(context.Context, models.CompanyEventFilter, string, func(*models.CompanyEvent, string) error) error {
+	return nil
+}
+
+// ApplyCompanies plans creating every company but the fixed one, which is
+// updated when its employees differ.
+func (c contractController) ApplyCompanies(ctx context.Context, desired []models.Company, _ models.ApplyOptions) ([]models.CompanyChange, error) {
+	var changes []models.CompanyChange
+	for i := range desired {
+		switch desired[i].ExternalRef {
+		case "":
+			return nil, e.Newf(e.CodeExternalRefRequired, "company %q has no external reference", desired[i].Name)
+		case c.company().ExternalRef:
+			update := &models.CompanyUpdate{ID: contractCompanyID, Employees: &desired[i].Employees}
+			updated, err := c.UpdateCompany(ctx, update, models.UpdateOptions{})
+			if err != nil {
+				return nil, err
+			}
+			if updated.Employees != c.company().Employees {
+				changes = append(changes, models.CompanyChange{
+					Action:  models.ApplyUpdate,
+					Company: updated,
+					Fields:  []string{"employee_range", "employees"},
+				})
+			}
+		default:
+			created, err := c.CreateCompany(ctx, &desired[i], models.CreateOptions{})
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, models.CompanyChange{Action: models.ApplyCreate, Company: created})
+		}
+	}
+	return changes, nil
+}
+
+// contractAlertWebhooks returns a fixed alert webhook.
+type contractAlertWebhooks struct{}
+
+func (contractAlertWebhooks) CreateAlertWebhook(_ context.Context, webhook *models.AlertWebhook) error {
+	webhook.ID = contractCreatedID
+	webhook.CreatedBy = "contract-user"
+	webhook.CreatedAt = contractTime
+	return nil
+}
+
+func (contractAlertWebhooks) ListAlertWebhooks(context.Context) ([]models.AlertWebhook, error) {
+	return []models.AlertWebhook{{
+		ID:           contractCompanyID,
+		Name:         "#sales",
+		Kind:         models.WebhookSlack,
+		URL:          "https://hooks.slack.com/services/T0/B0/secret",
+		EventTypes:   []string{"company_created"},
+		MinEmployees: 1000,
+		CreatedBy:    "founder",
+		CreatedAt:    contractTime,
+	}}, nil
+}
+
+func (contractAlertWebhooks) DeleteAlertWebhook(context.Context, uuid.UUID) error {
+	return nil
+}
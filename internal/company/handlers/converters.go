@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -10,8 +11,10 @@ import (
 	"github.com/gartstein/xm/internal/pkg/utils"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
-	"google.golang.org/grpc/codes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 // protoToModel converts a protobuf Company object into an internal Company model.
@@ -21,11 +24,15 @@ func (h *CompanyHandler) protoToModel(pbCompany *pb.Company) (*models.Company, e
 	}
 
 	return &models.Company{
-		Name:        pbCompany.GetName(),
-		Description: pbCompany.GetDescription(),
-		Employees:   int(pbCompany.GetEmployees()),
-		Registered:  pbCompany.GetRegistered(),
-		Type:        normalizeCompanyType(pbCompany.Type),
+		Name:         pbCompany.GetName(),
+		Description:  pbCompany.GetDescription(),
+		Employees:    int(pbCompany.GetEmployees()),
+		Registered:   pbCompany.GetRegistered(),
+		Type:         companyType(pbCompany),
+		ExternalRef:  pbCompany.GetExternalRef(),
+		Status:       companyStatus(pbCompany.GetStatus()),
+		ContactEmail: pbCompany.GetContactEmail(),
+		Metadata:     pbCompany.GetMetadata(),
 	}, nil
 }
 
@@ -36,28 +43,73 @@ func (h *CompanyHandler) protoToUpdate(pbCompany *pb.Company, id uuid.UUID) (*mo
 		return nil, errors.New("nil update data")
 	}
 
+	// An empty reference leaves the stored one untouched, so clients unaware
+	// of the field cannot clear it by accident.
+	var externalRef *string
+	if pbCompany.GetExternalRef() != "" {
+		externalRef = &pbCompany.ExternalRef
+	}
+
+	// Likewise for the contact address, and an unspecified status leaves the lifecycle state unchanged.
+	var contactEmail *string
+	if pbCompany.GetContactEmail() != "" {
+		contactEmail = &pbCompany.ContactEmail
+	}
+	var newStatus *models.CompanyStatus
+	if pbCompany.GetStatus() != pb.CompanyStatus_COMPANY_STATUS_UNSPECIFIED {
+		newStatus = utils.Ptr(companyStatus(pbCompany.GetStatus()))
+	}
+	// Clients unaware of the taxonomy read UNSPECIFIED for the types added
+	// to it, so sending it back must not reset the type.
+	var newType *models.CompanyType
+	if pbCompany.GetTypeCode() != "" || pbCompany.GetType() != pb.CompanyType_UNSPECIFIED {
+		newType = utils.Ptr(companyType(pbCompany))
+	}
+
 	return &models.CompanyUpdate{
-		ID:          id,
-		Name:        &pbCompany.Name,
-		Description: &pbCompany.Description,
-		Employees:   utils.Ptr(int(pbCompany.Employees)),
-		Registered:  &pbCompany.Registered,
-		Type:        utils.Ptr(models.CompanyType(pbCompany.Type.String())),
+		ID:           id,
+		Name:         &pbCompany.Name,
+		Description:  &pbCompany.Description,
+		Employees:    utils.Ptr(int(pbCompany.Employees)),
+		Registered:   &pbCompany.Registered,
+		Type:         newType,
+		ExternalRef:  externalRef,
+		Status:       newStatus,
+		ContactEmail: contactEmail,
+		Metadata:     pbCompany.GetMetadata(),
 	}, nil
 }
 
-// modelToProto converts an internal Company model into a protobuf Company object.
-func (h *CompanyHandler) modelToProto(company *models.Company) *pb.Company {
+// modelToProto converts an internal Company model into a protobuf Company
+// object, without the fields the caller of ctx may not see.
+func (h *CompanyHandler) modelToProto(ctx context.Context, company *models.Company) *pb.Company {
+	company, redacted := h.visibility.redact(ctx, company)
 	return &pb.Company{
-		Id:          company.ID.String(),
-		Name:        company.Name,
-		Description: company.Description,
-		Employees:   int32(company.Employees),
-		Registered:  company.Registered,
-		Type:        pb.CompanyType(pb.CompanyType_value[string(company.Type)]),
+		Id:             company.ID.String(),
+		Name:           company.Name,
+		Description:    company.Description,
+		Employees:      int32(company.Employees),
+		Registered:     company.Registered,
+		Type:           pb.CompanyType(pb.CompanyType_value[string(company.Type)]),
+		TypeCode:       string(company.Type),
+		EmployeeRange:  pb.EmployeeRange(pb.EmployeeRange_value[string(company.EmployeeRange)]),
+		ExternalRef:    company.ExternalRef,
+		Status:         pb.CompanyStatus(pb.CompanyStatus_value[string(company.Status)]),
+		ContactEmail:   company.ContactEmail,
+		Metadata:       company.Metadata,
+		RedactedFields: redacted,
 	}
 }
 
+// companyType returns the type code of pbCompany when set, and otherwise
+// the built-in type its CompanyType enum maps to.
+func companyType(pbCompany *pb.Company) models.CompanyType {
+	if pbCompany.GetTypeCode() != "" {
+		return models.CompanyType(pbCompany.GetTypeCode())
+	}
+	return normalizeCompanyType(pbCompany.GetType())
+}
+
 // normalizeCompanyType converts string input to CompanyType enum
 func normalizeCompanyType(companyType pb.CompanyType) models.CompanyType {
 	switch companyType {
@@ -74,17 +126,107 @@ func normalizeCompanyType(companyType pb.CompanyType) models.CompanyType {
 	}
 }
 
-// mapServiceError maps domain or repository errors to appropriate gRPC status codes.
+// companyStatus converts a CompanyStatus enum, returning "" for
+// UNSPECIFIED so the service applies its default.
+func companyStatus(value pb.CompanyStatus) models.CompanyStatus {
+	if value == pb.CompanyStatus_COMPANY_STATUS_UNSPECIFIED {
+		return ""
+	}
+	return models.CompanyStatus(value.String())
+}
+
+// mapServiceError maps domain or repository errors to gRPC statuses through
+// the error code registry. The status carries an ErrorInfo with the broad
+// reason and, as "code" metadata, the stable error code.
 func (h *CompanyHandler) mapServiceError(err error) error {
+	return serviceErrorStatus(err, h.logger)
+}
+
+// serviceErrorStatus implements mapServiceError, logging internal errors
+// with logger.
+func serviceErrorStatus(err error, logger *zap.Logger) error {
+	code := e.CodeOf(err)
+	info, _ := e.Lookup(code)
+	var (
+		similarErr    *e.SimilarNameError
+		quotaErr      *e.QuotaExceededError
+		validationErr *e.ValidationError
+	)
 	switch {
-	case errors.Is(err, e.ErrNotFound):
-		return status.Error(codes.NotFound, err.Error())
-	case errors.Is(err, e.ErrDuplicateName):
-		return status.Error(codes.AlreadyExists, err.Error())
-	case errors.Is(err, e.ErrInvalidInput):
-		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &validationErr):
+		return validationStatus(validationErr)
+	case errors.As(err, &similarErr):
+		return similarNameStatus(similarErr)
+	case errors.As(err, &quotaErr):
+		return quotaStatus(quotaErr)
+	case code == e.CodeInternal:
+		logger.Error("Internal server error", zap.Error(err))
+		return codeStatus(info, fmt.Sprintf("internal server error: %v", err)).Err()
 	default:
-		h.logger.Error("Internal server error", zap.Error(err))
-		return status.Error(codes.Internal, fmt.Sprintf("internal server error: %v", err))
+		return codeStatus(info, err.Error()).Err()
+	}
+}
+
+// codeStatus returns a status for an error with the given code, carrying an
+// ErrorInfo with the code's reason, which clients and the Localizer can rely
+// on instead of the message.
+func codeStatus(info e.CodeInfo, msg string, details ...protoadapt.MessageV1) *status.Status {
+	st := status.New(info.GRPCCode, msg)
+	details = append(details, &errdetails.ErrorInfo{
+		Reason:   info.Reason,
+		Domain:   errorDomain,
+		Metadata: map[string]string{"code": string(info.Code)},
+	})
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st
+}
+
+// quotaStatus returns a ResourceExhausted status naming the quota reached as
+// a QuotaFailure detail, with a RetryInfo telling when to retry for rate
+// quotas.
+func quotaStatus(err *e.QuotaExceededError) error {
+	details := []protoadapt.MessageV1{&errdetails.QuotaFailure{
+		Violations: []*errdetails.QuotaFailure_Violation{{
+			Subject:     "tenant:" + err.TenantID,
+			Description: fmt.Sprintf("%s limited to %d", err.Quota, err.Limit),
+		}},
+	}}
+	if err.RetryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(err.RetryAfter)})
+	}
+	info, _ := e.Lookup(err.ErrorCode())
+	return codeStatus(info, err.Error(), details...).Err()
+}
+
+// validationStatus returns an InvalidArgument status listing every field
+// violation in a BadRequest detail, with the rule broken as the reason, so
+// clients can highlight each invalid field.
+func validationStatus(err *e.ValidationError) error {
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(err.Violations))
+	for i, v := range err.Violations {
+		violations[i] = &errdetails.BadRequest_FieldViolation{
+			Field:       v.Field,
+			Description: v.Message,
+			Reason:      string(v.Rule),
+		}
+	}
+	info, _ := e.Lookup(err.ErrorCode())
+	return codeStatus(info, err.Error(), &errdetails.BadRequest{FieldViolations: violations}).Err()
+}
+
+// similarNameStatus returns an AlreadyExists status listing the candidate
+// companies as ResourceInfo details, so clients can offer them to the user.
+func similarNameStatus(err *e.SimilarNameError) error {
+	details := make([]protoadapt.MessageV1, 0, len(err.Candidates))
+	for _, c := range err.Candidates {
+		details = append(details, &errdetails.ResourceInfo{
+			ResourceType: "definition.v1.Company",
+			ResourceName: c.ID.String(),
+			Description:  c.Name,
+		})
 	}
+	info, _ := e.Lookup(err.ErrorCode())
+	return codeStatus(info, err.Error(), details...).Err()
 }
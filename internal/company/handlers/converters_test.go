@@ -1,16 +1,21 @@
 package handlers
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	pb "github.com/gartstein/xm/api/gen/definition/v1"
 	e "github.com/gartstein/xm/internal/company/errors"
 	"github.com/gartstein/xm/internal/company/models"
 	"github.com/google/uuid"
 	"go.uber.org/zap/zaptest"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestProtoToModel(t *testing.T) {
@@ -99,10 +104,140 @@ func TestProtoToUpdate(t *testing.T) {
 	if update.Registered == nil || *update.Registered != pbCompany.Registered {
 		t.Errorf("expected Registered %v, got %v", pbCompany.Registered, update.Registered)
 	}
-	expectedType := models.CompanyType(pbCompany.Type.String())
-	if update.Type == nil || *update.Type != expectedType {
-		t.Errorf("expected Type %q, got %v", expectedType, update.Type)
+	if update.Type == nil || *update.Type != models.NonProfit {
+		t.Errorf("expected Type %q, got %v", models.NonProfit, update.Type)
 	}
+	if update.ExternalRef != nil {
+		t.Errorf("expected an empty external reference to leave it untouched, got %q", *update.ExternalRef)
+	}
+	if update.ContactEmail != nil {
+		t.Errorf("expected an empty contact email to leave it untouched, got %q", *update.ContactEmail)
+	}
+	if update.Metadata != nil {
+		t.Errorf("expected no metadata to leave it untouched, got %v", update.Metadata)
+	}
+
+	pbCompany.ExternalRef = "ERP-1"
+	pbCompany.ContactEmail = "ceo@acme.test"
+	pbCompany.Metadata = map[string]string{"crm": "salesforce", "tier": ""}
+	update, err = h.protoToUpdate(pbCompany, id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if update.ExternalRef == nil || *update.ExternalRef != "ERP-1" {
+		t.Errorf("expected ExternalRef %q, got %v", "ERP-1", update.ExternalRef)
+	}
+	if update.ContactEmail == nil || *update.ContactEmail != "ceo@acme.test" {
+		t.Errorf("expected ContactEmail %q, got %v", "ceo@acme.test", update.ContactEmail)
+	}
+	if len(update.Metadata) != 2 || update.Metadata["tier"] != "" {
+		t.Errorf("expected the metadata patch with its removals, got %v", update.Metadata)
+	}
+
+	// Clients unaware of the taxonomy send UNSPECIFIED back for custom types.
+	update, err = h.protoToUpdate(&pb.Company{Name: "Updated Name"}, id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if update.Type != nil {
+		t.Errorf("expected UNSPECIFIED to leave the type untouched, got %q", *update.Type)
+	}
+	update, err = h.protoToUpdate(&pb.Company{Type: pb.CompanyType_COOPERATIVE, TypeCode: "PARTNERSHIP"}, id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if update.Type == nil || *update.Type != "PARTNERSHIP" {
+		t.Errorf("expected the type code to take precedence, got %v", update.Type)
+	}
+}
+
+// FuzzProtoToModel decodes arbitrary wire bytes as a Company, covering
+// truncated payloads and enum values the service does not define.
+func FuzzProtoToModel(f *testing.F) {
+	for _, c := range []*pb.Company{
+		{Name: "Acme", Employees: 10, Type: pb.CompanyType_NON_PROFIT},
+		{Name: "Unknown type", Type: pb.CompanyType(42)},
+		{Employees: -1, Type: pb.CompanyType(-7)},
+	} {
+		b, err := proto.Marshal(c)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+		f.Add(b[:len(b)/2])
+	}
+
+	h := &CompanyHandler{}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var pbCompany pb.Company
+		if err := proto.Unmarshal(data, &pbCompany); err != nil {
+			return
+		}
+		company, err := h.protoToModel(&pbCompany)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if company.Name != pbCompany.GetName() || company.Employees != int(pbCompany.GetEmployees()) {
+			t.Errorf("fields not carried over: %+v from %v", company, &pbCompany)
+		}
+		if !knownCompanyType(company.Type) && string(company.Type) != pbCompany.GetTypeCode() {
+			t.Errorf("unknown company type %q from %v", company.Type, pbCompany.GetType())
+		}
+	})
+}
+
+// FuzzProtoToUpdate parses an ID the way UpdateCompany does and converts
+// arbitrary wire bytes as the update.
+func FuzzProtoToUpdate(f *testing.F) {
+	valid, err := proto.Marshal(&pb.Company{Name: "Acme", Type: pb.CompanyType_COOPERATIVE, ExternalRef: "ERP-1"})
+	if err != nil {
+		f.Fatal(err)
+	}
+	unknownType, err := proto.Marshal(&pb.Company{Type: pb.CompanyType(99)})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(uuid.NewString(), valid)
+	f.Add("{"+uuid.NewString()+"}", valid[:3])
+	f.Add("urn:uuid:"+uuid.NewString(), unknownType)
+	f.Add("not-a-uuid", []byte{0x08})
+	f.Add("", []byte{})
+
+	h := &CompanyHandler{}
+	f.Fuzz(func(t *testing.T, rawID string, data []byte) {
+		id, err := uuid.Parse(rawID)
+		if err != nil {
+			return
+		}
+		var pbCompany pb.Company
+		if err := proto.Unmarshal(data, &pbCompany); err != nil {
+			return
+		}
+		update, err := h.protoToUpdate(&pbCompany, id)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if update.ID != id {
+			t.Errorf("expected ID %v, got %v", id, update.ID)
+		}
+		switch {
+		case update.Type == nil:
+			if pbCompany.GetType() != pb.CompanyType_UNSPECIFIED || pbCompany.GetTypeCode() != "" {
+				t.Errorf("expected type %v to be set", pbCompany.GetType())
+			}
+		case !knownCompanyType(*update.Type) && string(*update.Type) != pbCompany.GetTypeCode():
+			t.Errorf("unknown company type %v from %v", *update.Type, pbCompany.GetType())
+		}
+	})
+}
+
+// knownCompanyType reports whether t is one of the built-in company types.
+func knownCompanyType(t models.CompanyType) bool {
+	switch t {
+	case models.Corporations, models.NonProfit, models.Cooperative, models.SoleProprietorship:
+		return true
+	}
+	return false
 }
 
 func TestModelToProto(t *testing.T) {
@@ -122,7 +257,7 @@ func TestModelToProto(t *testing.T) {
 		Type:        models.NonProfit,
 	}
 
-	pbCompany := h.modelToProto(company)
+	pbCompany := h.modelToProto(context.Background(), company)
 	if pbCompany.Id != id.String() {
 		t.Errorf("expected ID %q, got %q", id.String(), pbCompany.Id)
 	}
@@ -165,6 +300,51 @@ func TestMapServiceError(t *testing.T) {
 		t.Errorf("expected code %v, got %v", codes.AlreadyExists, status.Code(mappedErr))
 	}
 
+	// Test mapping for a mutation of another user's company.
+	mappedErr = h.mapServiceError(e.ErrNotOwner)
+	if status.Code(mappedErr) != codes.PermissionDenied {
+		t.Errorf("expected code %v, got %v", codes.PermissionDenied, status.Code(mappedErr))
+	}
+	if key := errorKey(status.Convert(mappedErr)); key != reasonNotOwner {
+		t.Errorf("expected reason %q, got %q", reasonNotOwner, key)
+	}
+
+	// Test mapping for a similar name error, which lists the candidates.
+	candidate := models.Company{ID: uuid.New(), Name: "Acme Corp"}
+	mappedErr = h.mapServiceError(fmt.Errorf("wrapped: %w", &e.SimilarNameError{Candidates: []models.Company{candidate}}))
+	st := status.Convert(mappedErr)
+	if st.Code() != codes.AlreadyExists {
+		t.Errorf("expected code %v, got %v", codes.AlreadyExists, st.Code())
+	}
+	if details := st.Details(); len(details) != 2 {
+		t.Errorf("expected 2 details, got %d", len(details))
+	} else if info, ok := details[0].(*errdetails.ResourceInfo); !ok || info.GetResourceName() != candidate.ID.String() || info.GetDescription() != candidate.Name {
+		t.Errorf("unexpected detail %v", details[0])
+	}
+	if key := errorKey(st); key != reasonSimilarName {
+		t.Errorf("expected reason %q, got %q", reasonSimilarName, key)
+	}
+
+	// Test mapping for a quota error, which names the quota and when to retry.
+	mappedErr = h.mapServiceError(fmt.Errorf("wrapped: %w", &e.QuotaExceededError{TenantID: "acme", Quota: e.QuotaMutationsPerMinute, Limit: 60, RetryAfter: 20 * time.Second}))
+	st = status.Convert(mappedErr)
+	if st.Code() != codes.ResourceExhausted {
+		t.Errorf("expected code %v, got %v", codes.ResourceExhausted, st.Code())
+	}
+	if details := st.Details(); len(details) != 3 {
+		t.Errorf("expected 3 details, got %d", len(details))
+	} else {
+		if failure, ok := details[0].(*errdetails.QuotaFailure); !ok || failure.GetViolations()[0].GetSubject() != "tenant:acme" {
+			t.Errorf("unexpected detail %v", details[0])
+		}
+		if retry, ok := details[1].(*errdetails.RetryInfo); !ok || retry.GetRetryDelay().AsDuration() != 20*time.Second {
+			t.Errorf("unexpected detail %v", details[1])
+		}
+	}
+	if key := errorKey(st); key != reasonQuotaExceeded {
+		t.Errorf("expected reason %q, got %q", reasonQuotaExceeded, key)
+	}
+
 	// Test mapping for invalid input error.
 	errInvalid := e.ErrInvalidInput
 	mappedErr = h.mapServiceError(errInvalid)
@@ -172,6 +352,40 @@ func TestMapServiceError(t *testing.T) {
 		t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(mappedErr))
 	}
 
+	// Test mapping for a coded error, whose code is attached as metadata.
+	mappedErr = h.mapServiceError(fmt.Errorf("wrapped: %w", e.Newf(e.CodeNameTooLong, "name too long")))
+	st = status.Convert(mappedErr)
+	if st.Code() != codes.InvalidArgument {
+		t.Errorf("expected code %v, got %v", codes.InvalidArgument, st.Code())
+	}
+	if info, ok := st.Details()[0].(*errdetails.ErrorInfo); !ok || info.GetReason() != reasonInvalidInput || info.GetMetadata()["code"] != string(e.CodeNameTooLong) {
+		t.Errorf("unexpected detail %v", st.Details()[0])
+	}
+
+	// Test mapping for a validation error, which lists every invalid field.
+	var invalid e.ValidationError
+	invalid.Add("name", e.CodeNameTooLong, "name longer than 15 characters")
+	invalid.Add("contact_email", e.CodeContactEmailInvalid, "invalid contact email")
+	st = status.Convert(h.mapServiceError(fmt.Errorf("wrapped: %w", invalid.Err())))
+	if st.Code() != codes.InvalidArgument {
+		t.Errorf("expected code %v, got %v", codes.InvalidArgument, st.Code())
+	}
+	if details := st.Details(); len(details) != 2 {
+		t.Errorf("expected 2 details, got %d", len(details))
+	} else {
+		badRequest, ok := details[0].(*errdetails.BadRequest)
+		if !ok || len(badRequest.GetFieldViolations()) != 2 {
+			t.Fatalf("unexpected detail %v", details[0])
+		}
+		violation := badRequest.GetFieldViolations()[1]
+		if violation.GetField() != "contact_email" || violation.GetReason() != string(e.CodeContactEmailInvalid) || violation.GetDescription() != "invalid contact email" {
+			t.Errorf("unexpected violation %v", violation)
+		}
+		if info, ok := details[1].(*errdetails.ErrorInfo); !ok || info.GetMetadata()["code"] != string(e.CodeNameTooLong) {
+			t.Errorf("unexpected detail %v", details[1])
+		}
+	}
+
 	// Test mapping for an unknown error.
 	genericErr := errors.New("some error")
 	mappedErr = h.mapServiceError(genericErr)
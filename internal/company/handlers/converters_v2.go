@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	pbv2 "github.com/gartstein/xm/api/gen/definition/v2"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/pkg/utils"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// outputOnlyFieldsV2 are v2 Company fields set by the service. Update masks
+// naming them are accepted and the fields ignored, so clients can send back
+// a company they read.
+var outputOnlyFieldsV2 = map[string]bool{
+	"id":              true,
+	"employee_range":  true,
+	"created_by":      true,
+	"updated_by":      true,
+	"create_time":     true,
+	"update_time":     true,
+	"redacted_fields": true,
+}
+
+// protoToModel converts a v2 protobuf Company into an internal Company model.
+func (h *CompanyHandlerV2) protoToModel(pbCompany *pbv2.Company) (*models.Company, error) {
+	if pbCompany == nil {
+		return nil, errors.New("nil company data")
+	}
+
+	return &models.Company{
+		Name:         pbCompany.GetName(),
+		Description:  pbCompany.GetDescription(),
+		Employees:    int(pbCompany.GetEmployees()),
+		Registered:   pbCompany.GetRegistered(),
+		Type:         companyTypeFromV2(pbCompany.GetType(), pbCompany.GetTypeCode()),
+		ExternalRef:  pbCompany.GetExternalRef(),
+		Status:       companyStatusFromV2(pbCompany.GetStatus()),
+		ContactEmail: pbCompany.GetContactEmail(),
+	}, nil
+}
+
+// protoToUpdate converts a v2 protobuf Company into an internal
+// CompanyUpdate setting the fields named in mask. An empty mask selects the
+// fields with a non-zero value.
+func (h *CompanyHandlerV2) protoToUpdate(pbCompany *pbv2.Company, mask *fieldmaskpb.FieldMask) (*models.CompanyUpdate, error) {
+	if pbCompany == nil {
+		return nil, errors.New("nil update data")
+	}
+	id, err := uuid.Parse(pbCompany.GetId())
+	if err != nil {
+		return nil, errors.New("invalid company ID")
+	}
+
+	paths := mask.GetPaths()
+	switch {
+	case len(paths) == 0:
+		paths = populatedFieldsV2(pbCompany)
+	case len(paths) == 1 && paths[0] == "*":
+		paths = []string{"name", "description", "employees", "registered", "type", "type_code", "external_ref", "status", "contact_email"}
+	}
+
+	update := &models.CompanyUpdate{ID: id}
+	for _, path := range paths {
+		switch path {
+		case "name":
+			update.Name = utils.Ptr(pbCompany.GetName())
+		case "description":
+			update.Description = utils.Ptr(pbCompany.GetDescription())
+		case "employees":
+			update.Employees = utils.Ptr(int(pbCompany.GetEmployees()))
+		case "registered":
+			update.Registered = utils.Ptr(pbCompany.GetRegistered())
+		case "type":
+			if update.Type == nil {
+				update.Type = utils.Ptr(companyTypeFromV2(pbCompany.GetType(), ""))
+			}
+		case "type_code":
+			if pbCompany.GetTypeCode() != "" {
+				update.Type = utils.Ptr(models.CompanyType(pbCompany.GetTypeCode()))
+			}
+		case "external_ref":
+			update.ExternalRef = utils.Ptr(pbCompany.GetExternalRef())
+		case "status":
+			update.Status = utils.Ptr(companyStatusFromV2(pbCompany.GetStatus()))
+		case "contact_email":
+			update.ContactEmail = utils.Ptr(pbCompany.GetContactEmail())
+		default:
+			if !outputOnlyFieldsV2[path] {
+				return nil, fmt.Errorf("invalid update mask path %q", path)
+			}
+		}
+	}
+	return update, nil
+}
+
+// populatedFieldsV2 returns the names of the fields of pbCompany with a
+// non-zero value.
+func populatedFieldsV2(pbCompany *pbv2.Company) []string {
+	var paths []string
+	m := pbCompany.ProtoReflect()
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if fd := fields.Get(i); m.Has(fd) {
+			paths = append(paths, string(fd.Name()))
+		}
+	}
+	return paths
+}
+
+// modelToProto converts an internal Company model into a v2 protobuf
+// Company, without the fields the caller of ctx may not see.
+func (h *CompanyHandlerV2) modelToProto(ctx context.Context, company *models.Company) *pbv2.Company {
+	company, redacted := h.v1.visibility.redact(ctx, company)
+	pbCompany := &pbv2.Company{
+		Id:             company.ID.String(),
+		Name:           company.Name,
+		Description:    company.Description,
+		Employees:      int32(company.Employees),
+		EmployeeRange:  pbv2.EmployeeRange(pbv2.EmployeeRange_value[string(company.EmployeeRange)]),
+		Registered:     company.Registered,
+		Type:           pbv2.CompanyType(pbv2.CompanyType_value[string(company.Type)]),
+		TypeCode:       string(company.Type),
+		ExternalRef:    company.ExternalRef,
+		CreatedBy:      company.CreatedBy,
+		UpdatedBy:      company.UpdatedBy,
+		Status:         pbv2.CompanyStatus(pbv2.CompanyStatus_value[string(company.Status)]),
+		ContactEmail:   company.ContactEmail,
+		RedactedFields: redacted,
+	}
+	if !company.CreatedAt.IsZero() {
+		pbCompany.CreateTime = timestamppb.New(company.CreatedAt)
+	}
+	if !company.UpdatedAt.IsZero() {
+		pbCompany.UpdateTime = timestamppb.New(company.UpdatedAt)
+	}
+	return pbCompany
+}
+
+// companyTypeFromV2 returns code when set, and otherwise converts a v2
+// CompanyType, defaulting to corporations like v1 does.
+func companyTypeFromV2(companyType pbv2.CompanyType, code string) models.CompanyType {
+	if code != "" {
+		return models.CompanyType(code)
+	}
+	switch companyType {
+	case pbv2.CompanyType_NON_PROFIT:
+		return models.NonProfit
+	case pbv2.CompanyType_COOPERATIVE:
+		return models.Cooperative
+	case pbv2.CompanyType_SOLE_PROPRIETORSHIP:
+		return models.SoleProprietorship
+	default:
+		return models.Corporations
+	}
+}
+
+// companyStatusFromV2 converts a v2 CompanyStatus, returning "" for
+// UNSPECIFIED.
+func companyStatusFromV2(value pbv2.CompanyStatus) models.CompanyStatus {
+	if value == pbv2.CompanyStatus_COMPANY_STATUS_UNSPECIFIED {
+		return ""
+	}
+	return models.CompanyStatus(value.String())
+}
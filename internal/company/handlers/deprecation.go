@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Deprecation response headers, forwarded unprefixed by the gateway.
+const (
+	deprecationHeader = "deprecation"
+	sunsetHeader      = "sunset"
+	linkHeader        = "link"
+)
+
+// Deprecation marks every method of a gRPC service as deprecated by setting
+// the Deprecation (RFC 9745), Sunset (RFC 8594) and successor Link response
+// headers.
+type Deprecation struct {
+	// Service is the full service name, e.g. "definition.v1.CompanyService".
+	Service string
+	// Since is when the service was deprecated.
+	Since time.Time
+	// Sunset, when set, is when the service is expected to be removed.
+	Sunset time.Time
+	// Successor, when set, is the path of the replacing API.
+	Successor string
+}
+
+// Unary returns the interceptor.
+func (d Deprecation) Unary() grpc.UnaryServerInterceptor {
+	prefix := "/" + d.Service + "/"
+	md := metadata.Pairs(deprecationHeader, fmt.Sprintf("@%d", d.Since.Unix()))
+	if !d.Sunset.IsZero() {
+		md.Append(sunsetHeader, d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Successor != "" {
+		md.Append(linkHeader, fmt.Sprintf("<%s>; rel=\"successor-version\"", d.Successor))
+	}
+
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if strings.HasPrefix(info.FullMethod, prefix) {
+			_ = grpc.SetHeader(ctx, md)
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	pbv2 "github.com/gartstein/xm/api/gen/definition/v2"
+	"github.com/google/uuid"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestDeprecation(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	deprecation := Deprecation{
+		Service:   "definition.v1.CompanyService",
+		Since:     time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		Sunset:    time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC),
+		Successor: "/v2/companies",
+	}
+	server := grpc.NewServer(grpc.UnaryInterceptor(deprecation.Unary()))
+	pb.RegisterCompanyServiceServer(server, NewCompanyHandler(&dummyCompanyController{}, logger))
+	pbv2.RegisterCompanyServiceServer(server, NewCompanyHandlerV2(&dummyCompanyController{}, logger))
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+	id := uuid.NewString()
+
+	var header metadata.MD
+	if _, err := pb.NewCompanyServiceClient(conn).GetCompany(ctx, &pb.GetCompanyRequest{Id: id}, grpc.Header(&header)); err != nil {
+		t.Fatalf("unexpected v1 error: %v", err)
+	}
+	for key, want := range map[string]string{
+		"deprecation": "@1740787200",
+		"sunset":      "Mon, 01 Sep 2025 00:00:00 GMT",
+		"link":        `</v2/companies>; rel="successor-version"`,
+	} {
+		if got := header.Get(key); len(got) != 1 || got[0] != want {
+			t.Errorf("expected %s header %q, got %v", key, want, got)
+		}
+	}
+
+	header = nil
+	if _, err := pbv2.NewCompanyServiceClient(conn).GetCompany(ctx, &pbv2.GetCompanyRequest{Id: id}, grpc.Header(&header)); err != nil {
+		t.Fatalf("unexpected v2 error: %v", err)
+	}
+	if got := header.Get("deprecation"); len(got) != 0 {
+		t.Errorf("expected no deprecation header on v2, got %v", got)
+	}
+}
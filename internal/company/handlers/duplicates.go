@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SetDuplicates serves the duplicate suggestion methods and MergeCompanies
+// with duplicates.
+func (h *CompanyHandler) SetDuplicates(duplicates DuplicateManager) {
+	h.duplicates = duplicates
+}
+
+// errDuplicatesDisabled answers the duplicate methods while no
+// DuplicateManager is set.
+var errDuplicatesDisabled = status.Error(codes.Unimplemented, "duplicate merging is not enabled")
+
+// MergeCompanies merges a duplicate company into the company kept.
+func (h *CompanyHandler) MergeCompanies(ctx context.Context, req *pb.MergeCompaniesRequest) (*pb.MergeCompaniesResponse, error) {
+	if h.duplicates == nil {
+		return nil, errDuplicatesDisabled
+	}
+	keep, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+	merged, err := uuid.Parse(req.GetDuplicateId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid duplicate company ID")
+	}
+
+	company, err := h.duplicates.MergeCompanies(ctx, keep, merged)
+	if err != nil {
+		h.logger.Error("Merge companies failed", zap.Error(err),
+			zap.String("company_id", keep.String()), zap.String("duplicate_id", merged.String()))
+		return nil, h.mapServiceError(err)
+	}
+	return &pb.MergeCompaniesResponse{Company: h.modelToProto(ctx, company)}, nil
+}
+
+// ListDuplicateSuggestions returns a page of the suggested duplicate pairs,
+// the likeliest first.
+func (h *CompanyHandler) ListDuplicateSuggestions(ctx context.Context, req *pb.ListDuplicateSuggestionsRequest) (*pb.ListDuplicateSuggestionsResponse, error) {
+	if h.duplicates == nil {
+		return nil, errDuplicatesDisabled
+	}
+	filter := models.DuplicateSuggestionFilter{
+		Status:   duplicateStatus(req.GetStatus()),
+		TenantID: req.GetTenantId(),
+	}
+	if req.GetCompanyId() != "" {
+		company, err := uuid.Parse(req.GetCompanyId())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+		}
+		filter.CompanyID = company
+	}
+
+	suggestions, next, err := h.duplicates.ListDuplicateSuggestions(ctx, filter, int(req.GetPageSize()), req.GetPageToken())
+	if err != nil {
+		return nil, h.mapServiceError(err)
+	}
+	resp := &pb.ListDuplicateSuggestionsResponse{NextPageToken: next}
+	for i := range suggestions {
+		resp.Suggestions = append(resp.Suggestions, duplicateSuggestionToProto(&suggestions[i]))
+	}
+	return resp, nil
+}
+
+// ResolveDuplicateSuggestion merges or dismisses a suggested pair.
+func (h *CompanyHandler) ResolveDuplicateSuggestion(ctx context.Context, req *pb.ResolveDuplicateSuggestionRequest) (*pb.ResolveDuplicateSuggestionResponse, error) {
+	if h.duplicates == nil {
+		return nil, errDuplicatesDisabled
+	}
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid suggestion ID")
+	}
+	var keep uuid.UUID
+	if req.GetKeepCompanyId() != "" {
+		if keep, err = uuid.Parse(req.GetKeepCompanyId()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid keep company ID")
+		}
+	}
+
+	suggestion, company, err := h.duplicates.ResolveDuplicateSuggestion(ctx, id, duplicateResolution(req.GetResolution()), keep)
+	if err != nil {
+		h.logger.Error("Resolve duplicate suggestion failed", zap.Error(err), zap.String("suggestion_id", id.String()))
+		return nil, h.mapServiceError(err)
+	}
+	resp := &pb.ResolveDuplicateSuggestionResponse{Suggestion: duplicateSuggestionToProto(suggestion)}
+	if company != nil {
+		resp.Company = h.modelToProto(ctx, company)
+	}
+	return resp, nil
+}
+
+// duplicateStatus converts a DuplicateStatus enum, returning "" for
+// UNSPECIFIED so no status filters.
+func duplicateStatus(value pb.DuplicateStatus) models.DuplicateStatus {
+	switch value {
+	case pb.DuplicateStatus_DUPLICATE_STATUS_UNSPECIFIED:
+		return ""
+	case pb.DuplicateStatus_DUPLICATE_STATUS_OPEN:
+		return models.DuplicateOpen
+	case pb.DuplicateStatus_DUPLICATE_STATUS_MERGED:
+		return models.DuplicateMerged
+	case pb.DuplicateStatus_DUPLICATE_STATUS_DISMISSED:
+		return models.DuplicateDismissed
+	default:
+		return models.DuplicateStatus(value.String())
+	}
+}
+
+// duplicateResolution converts a DuplicateResolution enum; UNSPECIFIED and
+// unknown values are left for the service to reject.
+func duplicateResolution(value pb.DuplicateResolution) models.DuplicateResolution {
+	switch value {
+	case pb.DuplicateResolution_DUPLICATE_RESOLUTION_MERGE:
+		return models.ResolveMerge
+	case pb.DuplicateResolution_DUPLICATE_RESOLUTION_DISMISS:
+		return models.ResolveDismiss
+	default:
+		return models.DuplicateResolution(value.String())
+	}
+}
+
+// duplicateSuggestionToProto converts a suggestion for a response.
+func duplicateSuggestionToProto(suggestion *models.DuplicateSuggestion) *pb.DuplicateSuggestion {
+	resp := &pb.DuplicateSuggestion{
+		Id:             suggestion.ID.String(),
+		CompanyId:      suggestion.CompanyID.String(),
+		DuplicateId:    suggestion.DuplicateID.String(),
+		TenantId:       suggestion.TenantID,
+		NameSimilarity: suggestion.NameSimilarity,
+		SameCountry:    suggestion.SameCountry,
+		SameWebsite:    suggestion.SameWebsite,
+		ResolvedBy:     suggestion.ResolvedBy,
+		CreatedAt:      timestamppb.New(suggestion.CreatedAt),
+	}
+	switch suggestion.Status {
+	case models.DuplicateOpen:
+		resp.Status = pb.DuplicateStatus_DUPLICATE_STATUS_OPEN
+	case models.DuplicateMerged:
+		resp.Status = pb.DuplicateStatus_DUPLICATE_STATUS_MERGED
+	case models.DuplicateDismissed:
+		resp.Status = pb.DuplicateStatus_DUPLICATE_STATUS_DISMISSED
+	}
+	if suggestion.ResolvedAt != nil {
+		resp.ResolvedAt = timestamppb.New(*suggestion.ResolvedAt)
+	}
+	return resp
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mockDuplicates is a DuplicateManager holding a single open suggestion.
+type mockDuplicates struct {
+	suggestion models.DuplicateSuggestion
+	filter     models.DuplicateSuggestionFilter
+}
+
+func (m *mockDuplicates) ListDuplicateSuggestions(_ context.Context, filter models.DuplicateSuggestionFilter, _ int, _ string) ([]models.DuplicateSuggestion, string, error) {
+	if filter.Status != "" && !filter.Status.Valid() {
+		return nil, "", e.Invalid("status", e.CodeDuplicateStatusUnknown, "unknown status")
+	}
+	m.filter = filter
+	return []models.DuplicateSuggestion{m.suggestion}, "", nil
+}
+
+func (m *mockDuplicates) ResolveDuplicateSuggestion(ctx context.Context, id uuid.UUID, resolution models.DuplicateResolution, keep uuid.UUID) (*models.DuplicateSuggestion, *models.Company, error) {
+	if id != m.suggestion.ID {
+		return nil, nil, e.ErrDuplicateSuggestionNotFound
+	}
+	if m.suggestion.Status != models.DuplicateOpen {
+		return nil, nil, e.Newf(e.CodeDuplicateSuggestionResolved, "suggestion is %s", m.suggestion.Status)
+	}
+	now := time.Now()
+	m.suggestion.ResolvedBy, m.suggestion.ResolvedAt = "alice", &now
+	switch resolution {
+	case models.ResolveDismiss:
+		m.suggestion.Status = models.DuplicateDismissed
+		return &m.suggestion, nil, nil
+	case models.ResolveMerge:
+		if keep == uuid.Nil {
+			keep = m.suggestion.CompanyID
+		}
+		m.suggestion.Status = models.DuplicateMerged
+		company, err := m.MergeCompanies(ctx, keep, m.suggestion.DuplicateID)
+		return &m.suggestion, company, err
+	}
+	return nil, nil, e.Invalid("resolution", e.CodeDuplicateResolutionUnknown, "unknown resolution")
+}
+
+func (m *mockDuplicates) MergeCompanies(_ context.Context, keep, merged uuid.UUID) (*models.Company, error) {
+	if keep == merged {
+		return nil, e.Invalid("duplicate_id", e.CodeMergeSameCompany, "cannot merge a company into itself")
+	}
+	return &models.Company{ID: keep, Name: "Acme Corp", Type: models.Corporations}, nil
+}
+
+func TestCompanyHandler_Duplicates(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+
+	t.Run("NotEnabled", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.ListDuplicateSuggestions(ctx, &pb.ListDuplicateSuggestionsRequest{})
+		if status.Code(err) != codes.Unimplemented {
+			t.Errorf("expected code %v, got %v", codes.Unimplemented, status.Code(err))
+		}
+	})
+
+	duplicates := &mockDuplicates{suggestion: models.DuplicateSuggestion{
+		ID:             uuid.New(),
+		CompanyID:      uuid.New(),
+		DuplicateID:    uuid.New(),
+		TenantID:       "acme",
+		NameSimilarity: 0.9,
+		SameWebsite:    true,
+		Status:         models.DuplicateOpen,
+	}}
+	suggestion := duplicates.suggestion
+	handler := NewCompanyHandler(&mockCompanyController{}, logger)
+	handler.SetDuplicates(duplicates)
+
+	listed, err := handler.ListDuplicateSuggestions(ctx, &pb.ListDuplicateSuggestionsRequest{
+		Status:    pb.DuplicateStatus_DUPLICATE_STATUS_OPEN,
+		CompanyId: suggestion.CompanyID.String(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if duplicates.filter.Status != models.DuplicateOpen || duplicates.filter.CompanyID != suggestion.CompanyID {
+		t.Errorf("unexpected filter %+v", duplicates.filter)
+	}
+	if len(listed.GetSuggestions()) != 1 {
+		t.Fatalf("expected 1 suggestion, got %d", len(listed.GetSuggestions()))
+	}
+	got := listed.GetSuggestions()[0]
+	if got.GetStatus() != pb.DuplicateStatus_DUPLICATE_STATUS_OPEN || got.GetDuplicateId() != suggestion.DuplicateID.String() ||
+		!got.GetSameWebsite() || got.GetNameSimilarity() != 0.9 || got.GetResolvedAt() != nil {
+		t.Errorf("unexpected suggestion %v", got)
+	}
+	if _, err := handler.ListDuplicateSuggestions(ctx, &pb.ListDuplicateSuggestionsRequest{CompanyId: "7"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected code %v for an invalid company ID, got %v", codes.InvalidArgument, status.Code(err))
+	}
+	if _, err := handler.ListDuplicateSuggestions(ctx, &pb.ListDuplicateSuggestionsRequest{Status: 9}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected code %v for an unknown status, got %v", codes.InvalidArgument, status.Code(err))
+	}
+
+	if _, err := handler.ResolveDuplicateSuggestion(ctx, &pb.ResolveDuplicateSuggestionRequest{Id: suggestion.ID.String()}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected code %v without a resolution, got %v", codes.InvalidArgument, status.Code(err))
+	}
+	if _, err := handler.ResolveDuplicateSuggestion(ctx, &pb.ResolveDuplicateSuggestionRequest{Id: uuid.NewString(), Resolution: pb.DuplicateResolution_DUPLICATE_RESOLUTION_DISMISS}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected code %v for an unknown suggestion, got %v", codes.NotFound, status.Code(err))
+	}
+	resolved, err := handler.ResolveDuplicateSuggestion(ctx, &pb.ResolveDuplicateSuggestionRequest{
+		Id:         suggestion.ID.String(),
+		Resolution: pb.DuplicateResolution_DUPLICATE_RESOLUTION_MERGE,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resolved.GetSuggestion().GetStatus() != pb.DuplicateStatus_DUPLICATE_STATUS_MERGED || resolved.GetSuggestion().GetResolvedAt() == nil {
+		t.Errorf("unexpected suggestion %v", resolved.GetSuggestion())
+	}
+	if resolved.GetCompany().GetId() != suggestion.CompanyID.String() {
+		t.Errorf("expected company %s to be kept, got %v", suggestion.CompanyID, resolved.GetCompany())
+	}
+	if _, err := handler.ResolveDuplicateSuggestion(ctx, &pb.ResolveDuplicateSuggestionRequest{Id: suggestion.ID.String(), Resolution: pb.DuplicateResolution_DUPLICATE_RESOLUTION_DISMISS}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected code %v for a resolved suggestion, got %v", codes.FailedPrecondition, status.Code(err))
+	}
+
+	if _, err := handler.MergeCompanies(ctx, &pb.MergeCompaniesRequest{Id: suggestion.CompanyID.String(), DuplicateId: "7"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected code %v for an invalid duplicate ID, got %v", codes.InvalidArgument, status.Code(err))
+	}
+	if _, err := handler.MergeCompanies(ctx, &pb.MergeCompaniesRequest{Id: suggestion.CompanyID.String(), DuplicateId: suggestion.CompanyID.String()}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected code %v merging a company into itself, got %v", codes.InvalidArgument, status.Code(err))
+	}
+	merged, err := handler.MergeCompanies(ctx, &pb.MergeCompaniesRequest{Id: suggestion.CompanyID.String(), DuplicateId: suggestion.DuplicateID.String()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if merged.GetCompany().GetName() != "Acme Corp" {
+		t.Errorf("unexpected company %v", merged.GetCompany())
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SetEmployees serves the employee methods with employees.
+func (h *CompanyHandler) SetEmployees(employees EmployeeManager) {
+	h.employees = employees
+}
+
+// errEmployeesDisabled answers the employee methods while no
+// EmployeeManager is set.
+var errEmployeesDisabled = status.Error(codes.Unimplemented, "employees are not enabled")
+
+// CreateEmployee adds an employee to a company.
+func (h *CompanyHandler) CreateEmployee(ctx context.Context, req *pb.CreateEmployeeRequest) (*pb.CreateEmployeeResponse, error) {
+	if h.employees == nil {
+		return nil, errEmployeesDisabled
+	}
+	company, err := uuid.Parse(req.GetCompanyId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+	in := req.GetEmployee()
+	if in == nil {
+		return nil, status.Error(codes.InvalidArgument, "employee data required")
+	}
+
+	employee, err := h.employees.CreateEmployee(ctx, &models.Employee{
+		CompanyID: company,
+		Name:      in.GetName(),
+		Title:     in.GetTitle(),
+		Email:     in.GetEmail(),
+	})
+	if err != nil {
+		h.logger.Error("Create employee failed", zap.Error(err), zap.String("company_id", company.String()))
+		return nil, h.mapServiceError(err)
+	}
+	setCreated(ctx, "/v1/companies/"+company.String()+"/employees/"+employee.ID.String())
+	return &pb.CreateEmployeeResponse{Employee: employeeToProto(employee)}, nil
+}
+
+// GetEmployee returns an employee of a company.
+func (h *CompanyHandler) GetEmployee(ctx context.Context, req *pb.GetEmployeeRequest) (*pb.GetEmployeeResponse, error) {
+	if h.employees == nil {
+		return nil, errEmployeesDisabled
+	}
+	company, id, err := parseEmployeeIDs(req.GetCompanyId(), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	employee, err := h.employees.GetEmployee(ctx, company, id)
+	if err != nil {
+		return nil, h.mapServiceError(err)
+	}
+	return &pb.GetEmployeeResponse{Employee: employeeToProto(employee)}, nil
+}
+
+// ListEmployees returns a page of the employees of a company.
+func (h *CompanyHandler) ListEmployees(ctx context.Context, req *pb.ListEmployeesRequest) (*pb.ListEmployeesResponse, error) {
+	if h.employees == nil {
+		return nil, errEmployeesDisabled
+	}
+	company, err := uuid.Parse(req.GetCompanyId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+
+	employees, next, err := h.employees.ListEmployees(ctx, company, int(req.GetPageSize()), req.GetPageToken())
+	if err != nil {
+		return nil, h.mapServiceError(err)
+	}
+	resp := &pb.ListEmployeesResponse{NextPageToken: next}
+	for i := range employees {
+		resp.Employees = append(resp.Employees, employeeToProto(&employees[i]))
+	}
+	return resp, nil
+}
+
+// UpdateEmployee modifies an employee of a company. Empty fields are left
+// unchanged.
+func (h *CompanyHandler) UpdateEmployee(ctx context.Context, req *pb.UpdateEmployeeRequest) (*pb.UpdateEmployeeResponse, error) {
+	if h.employees == nil {
+		return nil, errEmployeesDisabled
+	}
+	company, id, err := parseEmployeeIDs(req.GetCompanyId(), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	in := req.GetEmployee()
+	if in == nil {
+		return nil, status.Error(codes.InvalidArgument, "employee data required")
+	}
+
+	update := &models.EmployeeUpdate{ID: id, CompanyID: company}
+	if in.GetName() != "" {
+		update.Name = &in.Name
+	}
+	if in.GetTitle() != "" {
+		update.Title = &in.Title
+	}
+	if in.GetEmail() != "" {
+		update.Email = &in.Email
+	}
+	employee, err := h.employees.UpdateEmployee(ctx, update)
+	if err != nil {
+		h.logger.Error("Update employee failed", zap.Error(err), zap.String("employee_id", id.String()))
+		return nil, h.mapServiceError(err)
+	}
+	return &pb.UpdateEmployeeResponse{Employee: employeeToProto(employee)}, nil
+}
+
+// DeleteEmployee removes an employee from a company.
+func (h *CompanyHandler) DeleteEmployee(ctx context.Context, req *pb.DeleteEmployeeRequest) (*pb.DeleteEmployeeResponse, error) {
+	if h.employees == nil {
+		return nil, errEmployeesDisabled
+	}
+	company, id, err := parseEmployeeIDs(req.GetCompanyId(), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.employees.DeleteEmployee(ctx, company, id); err != nil {
+		return nil, h.mapServiceError(err)
+	}
+	setNoContent(ctx)
+	return &pb.DeleteEmployeeResponse{}, nil
+}
+
+// parseEmployeeIDs parses the company and employee IDs of a request.
+func parseEmployeeIDs(company, id string) (uuid.UUID, uuid.UUID, error) {
+	companyID, err := uuid.Parse(company)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+	employeeID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, status.Error(codes.InvalidArgument, "invalid employee ID")
+	}
+	return companyID, employeeID, nil
+}
+
+// employeeToProto converts an employee for a response.
+func employeeToProto(employee *models.Employee) *pb.Employee {
+	return &pb.Employee{
+		Id:        employee.ID.String(),
+		CompanyId: employee.CompanyID.String(),
+		Name:      employee.Name,
+		Title:     employee.Title,
+		Email:     employee.Email,
+		CreatedAt: timestamppb.New(employee.CreatedAt),
+		UpdatedAt: timestamppb.New(employee.UpdatedAt),
+	}
+}
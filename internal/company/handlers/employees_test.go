@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mockEmployees is an EmployeeManager keeping employees in memory.
+type mockEmployees struct {
+	employees map[uuid.UUID]models.Employee
+	update    *models.EmployeeUpdate
+}
+
+func (m *mockEmployees) CreateEmployee(_ context.Context, employee *models.Employee) (*models.Employee, error) {
+	if employee.Name == "" {
+		return nil, e.Invalid("name", e.CodeEmployeeNameRequired, "name required")
+	}
+	employee.ID = uuid.New()
+	m.employees[employee.ID] = *employee
+	return employee, nil
+}
+
+func (m *mockEmployees) GetEmployee(_ context.Context, company, id uuid.UUID) (*models.Employee, error) {
+	employee, ok := m.employees[id]
+	if !ok || employee.CompanyID != company {
+		return nil, e.ErrEmployeeNotFound
+	}
+	return &employee, nil
+}
+
+func (m *mockEmployees) ListEmployees(_ context.Context, company uuid.UUID, _ int, _ string) ([]models.Employee, string, error) {
+	var employees []models.Employee
+	for _, employee := range m.employees {
+		if employee.CompanyID == company {
+			employees = append(employees, employee)
+		}
+	}
+	return employees, "next", nil
+}
+
+func (m *mockEmployees) UpdateEmployee(ctx context.Context, update *models.EmployeeUpdate) (*models.Employee, error) {
+	m.update = update
+	return m.GetEmployee(ctx, update.CompanyID, update.ID)
+}
+
+func (m *mockEmployees) DeleteEmployee(_ context.Context, company, id uuid.UUID) error {
+	if employee, ok := m.employees[id]; !ok || employee.CompanyID != company {
+		return e.ErrEmployeeNotFound
+	}
+	delete(m.employees, id)
+	return nil
+}
+
+func TestCompanyHandler_Employees(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+	company := uuid.NewString()
+
+	t.Run("NotEnabled", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.ListEmployees(ctx, &pb.ListEmployeesRequest{CompanyId: company})
+		if status.Code(err) != codes.Unimplemented {
+			t.Errorf("expected code %v, got %v", codes.Unimplemented, status.Code(err))
+		}
+	})
+
+	employees := &mockEmployees{employees: map[uuid.UUID]models.Employee{}}
+	handler := NewCompanyHandler(&mockCompanyController{}, logger)
+	handler.SetEmployees(employees)
+
+	if _, err := handler.CreateEmployee(ctx, &pb.CreateEmployeeRequest{CompanyId: "42", Employee: &pb.Employee{Name: "Jane"}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected code %v for an invalid company ID, got %v", codes.InvalidArgument, status.Code(err))
+	}
+	if _, err := handler.CreateEmployee(ctx, &pb.CreateEmployeeRequest{CompanyId: company, Employee: &pb.Employee{}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected code %v without a name, got %v", codes.InvalidArgument, status.Code(err))
+	}
+	created, err := handler.CreateEmployee(ctx, &pb.CreateEmployeeRequest{
+		CompanyId: company,
+		Employee:  &pb.Employee{CompanyId: "ignored", Name: "Jane", Title: "CEO", Email: "jane@acme.test"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if created.GetEmployee().GetCompanyId() != company || created.GetEmployee().GetEmail() != "jane@acme.test" {
+		t.Errorf("unexpected employee %v", created.GetEmployee())
+	}
+	id := created.GetEmployee().GetId()
+
+	got, err := handler.GetEmployee(ctx, &pb.GetEmployeeRequest{CompanyId: company, Id: id})
+	if err != nil || got.GetEmployee().GetName() != "Jane" {
+		t.Errorf("expected the employee, got %v, %v", got, err)
+	}
+	if _, err := handler.GetEmployee(ctx, &pb.GetEmployeeRequest{CompanyId: uuid.NewString(), Id: id}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected code %v for another company, got %v", codes.NotFound, status.Code(err))
+	}
+	if _, err := handler.GetEmployee(ctx, &pb.GetEmployeeRequest{CompanyId: company, Id: "7"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected code %v for an invalid employee ID, got %v", codes.InvalidArgument, status.Code(err))
+	}
+
+	listed, err := handler.ListEmployees(ctx, &pb.ListEmployeesRequest{CompanyId: company})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(listed.GetEmployees()) != 1 || listed.GetNextPageToken() != "next" {
+		t.Errorf("unexpected page %v", listed)
+	}
+
+	if _, err := handler.UpdateEmployee(ctx, &pb.UpdateEmployeeRequest{CompanyId: company, Id: id, Employee: &pb.Employee{Title: "Chair"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if employees.update.Name != nil || employees.update.Email != nil || employees.update.Title == nil || *employees.update.Title != "Chair" {
+		t.Errorf("expected only the title to be updated, got %+v", employees.update)
+	}
+
+	if _, err := handler.DeleteEmployee(ctx, &pb.DeleteEmployeeRequest{CompanyId: company, Id: id}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := handler.DeleteEmployee(ctx, &pb.DeleteEmployeeRequest{CompanyId: company, Id: id}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected code %v, got %v", codes.NotFound, status.Code(err))
+	}
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"context"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+// ListErrorCodes returns the error code registry.
+func (h *CompanyHandler) ListErrorCodes(context.Context, *pb.ListErrorCodesRequest) (*pb.ListErrorCodesResponse, error) {
+	infos := e.Codes()
+	resp := &pb.ListErrorCodesResponse{Codes: make([]*pb.ErrorCode, 0, len(infos))}
+	for _, info := range infos {
+		resp.Codes = append(resp.Codes, &pb.ErrorCode{
+			Code:        string(info.Code),
+			Reason:      info.Reason,
+			GrpcCode:    code.Code(info.GRPCCode).String(),
+			HttpStatus:  int32(runtime.HTTPStatusFromCode(info.GRPCCode)),
+			Description: info.Description,
+		})
+	}
+	return resp, nil
+}
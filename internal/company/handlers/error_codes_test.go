@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCompanyHandler_ListErrorCodes(t *testing.T) {
+	handler := NewCompanyHandler(&mockCompanyController{}, zaptest.NewLogger(t))
+	resp, err := handler.ListErrorCodes(context.Background(), &pb.ListErrorCodesRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.GetCodes()) != len(e.Codes()) {
+		t.Errorf("expected %d codes, got %d", len(e.Codes()), len(resp.GetCodes()))
+	}
+	for _, c := range resp.GetCodes() {
+		if c.GetCode() == string(e.CodeNameTooLong) && (c.GetGrpcCode() != "INVALID_ARGUMENT" || c.GetHttpStatus() != http.StatusBadRequest) {
+			t.Errorf("unexpected code %v", c)
+		}
+	}
+}
+
+func TestErrorCodes_Translated(t *testing.T) {
+	for _, info := range e.Codes() {
+		for lang, messages := range errorMessages {
+			if _, ok := messages[info.Reason]; !ok {
+				t.Errorf("reason %s of code %s has no %s message", info.Reason, info.Code, lang)
+			}
+		}
+	}
+}
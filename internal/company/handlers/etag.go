@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// etagHeader is the metadata key carrying the entity tag of a response,
+	// forwarded as the HTTP ETag header.
+	etagHeader = "etag"
+	// cacheControl makes caches revalidate with If-None-Match on every use.
+	cacheControl = "private, no-cache"
+)
+
+// setCompanyETag sets the entity tag of company as served by API version on
+// the response.
+func setCompanyETag(ctx context.Context, version string, company *models.Company) {
+	b, err := json.Marshal(company)
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(append([]byte(version+":"), b...))
+	_ = grpc.SetHeader(ctx, metadata.Pairs(etagHeader, `"`+hex.EncodeToString(sum[:16])+`"`))
+}
+
+// conditionalGet adds Cache-Control to GET responses carrying an ETag and
+// answers 304 Not Modified when it matches the request's If-None-Match.
+func conditionalGet(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&conditionalWriter{ResponseWriter: w, ifNoneMatch: r.Header.Get("If-None-Match")}, r)
+	})
+}
+
+// conditionalWriter drops the body of responses turned into 304s.
+type conditionalWriter struct {
+	http.ResponseWriter
+	ifNoneMatch string
+	wroteHeader bool
+	notModified bool
+}
+
+func (w *conditionalWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if etag := w.Header().Get("ETag"); etag != "" && code == http.StatusOK {
+		w.Header().Set("Cache-Control", cacheControl)
+		if etagMatches(w.ifNoneMatch, etag) {
+			w.notModified = true
+			w.Header().Del("Content-Type")
+			w.Header().Del("Content-Length")
+			code = http.StatusNotModified
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *conditionalWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.notModified {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController flush streamed responses.
+func (w *conditionalWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// etagMatches reports whether an If-None-Match value matches etag, using the
+// weak comparison RFC 9110 prescribes for it.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// headerStream records the headers set by a handler.
+type headerStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestSetCompanyETag(t *testing.T) {
+	etag := func(version string, company models.Company) string {
+		stream := &headerStream{}
+		setCompanyETag(grpc.NewContextWithServerTransportStream(context.Background(), stream), version, &company)
+		if values := stream.header.Get(etagHeader); len(values) == 1 {
+			return values[0]
+		}
+		return ""
+	}
+
+	company := models.Company{ID: uuid.New(), Name: "Acme", UpdatedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)}
+	first := etag("v1", company)
+	if first == "" || first != etag("v1", company) {
+		t.Fatalf("expected a stable ETag, got %q", first)
+	}
+	if etag("v2", company) == first {
+		t.Error("expected the ETag to depend on the API version")
+	}
+	company.UpdatedAt = company.UpdatedAt.Add(time.Second)
+	if etag("v1", company) == first {
+		t.Error("expected the ETag to change with the row")
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	handler := conditionalGet(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Etag", `"abc"`)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"name":"Acme"}`))
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		ifNoneMatch string
+		wantStatus  int
+		wantBody    string
+	}{
+		{"NoCondition", http.MethodGet, "", http.StatusOK, `{"name":"Acme"}`},
+		{"Match", http.MethodGet, `"xyz", W/"abc"`, http.StatusNotModified, ""},
+		{"Wildcard", http.MethodGet, "*", http.StatusNotModified, ""},
+		{"Mismatch", http.MethodGet, `"xyz"`, http.StatusOK, `{"name":"Acme"}`},
+		{"NotGet", http.MethodPatch, `"abc"`, http.StatusOK, `{"name":"Acme"}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/v1/companies/42", nil)
+			if tc.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, rec.Code)
+			}
+			if rec.Body.String() != tc.wantBody {
+				t.Errorf("expected body %q, got %q", tc.wantBody, rec.Body)
+			}
+			if tc.method == http.MethodGet && rec.Header().Get("Cache-Control") != cacheControl {
+				t.Errorf("expected Cache-Control %q, got %q", cacheControl, rec.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
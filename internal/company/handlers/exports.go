@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/company/export"
+	"github.com/gartstein/xm/internal/company/models"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// exportKinds maps the proto export kinds to the model ones.
+var exportKinds = map[pb.ExportKind]models.ExportKind{
+	pb.ExportKind_EXPORT_KIND_UNSPECIFIED: models.ExportFull,
+	pb.ExportKind_FULL:                    models.ExportFull,
+	pb.ExportKind_INCREMENTAL:             models.ExportIncremental,
+}
+
+// SetExporter serves ExportCompanies with exporter.
+func (h *CompanyHandler) SetExporter(exporter CompanyExporter) {
+	h.exporter = exporter
+}
+
+// ExportCompanies writes an export of the companies and returns once it is
+// complete.
+func (h *CompanyHandler) ExportCompanies(ctx context.Context, req *pb.ExportCompaniesRequest) (*pb.ExportCompaniesResponse, error) {
+	if h.exporter == nil {
+		return nil, status.Error(codes.Unimplemented, "exports are not enabled")
+	}
+	kind, ok := exportKinds[req.GetKind()]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "invalid export kind")
+	}
+	run, err := h.exporter.Export(ctx, kind)
+	if errors.Is(err, export.ErrInProgress) {
+		return nil, status.Error(codes.Aborted, "another export is in progress; retry later")
+	}
+	if err != nil {
+		h.logger.Error("Export companies failed", zap.Error(err))
+		return nil, h.mapServiceError(err)
+	}
+	return &pb.ExportCompaniesResponse{Export: exportRunToProto(run)}, nil
+}
+
+// exportRunToProto converts an export for a response.
+func exportRunToProto(run *models.ExportRun) *pb.ExportRun {
+	out := &pb.ExportRun{
+		Id:        run.ID.String(),
+		Kind:      pb.ExportKind(pb.ExportKind_value[string(run.Kind)]),
+		Until:     timestamppb.New(run.Until),
+		Companies: run.Companies,
+		Files:     int32(run.Files),
+		Manifest:  run.Manifest,
+		CreatedBy: run.CreatedBy,
+		CreatedAt: timestamppb.New(run.CreatedAt),
+	}
+	if run.Since != nil {
+		out.Since = timestamppb.New(*run.Since)
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/company/export"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mockExporter is a CompanyExporter returning a canned run.
+type mockExporter struct {
+	kind models.ExportKind
+	err  error
+}
+
+func (m *mockExporter) Export(_ context.Context, kind models.ExportKind) (*models.ExportRun, error) {
+	m.kind = kind
+	if m.err != nil {
+		return nil, m.err
+	}
+	since := time.Date(2025, 3, 1, 11, 0, 0, 0, time.UTC)
+	return &models.ExportRun{
+		ID:        uuid.New(),
+		Kind:      kind,
+		Since:     &since,
+		Until:     since.Add(time.Hour),
+		Companies: 3,
+		Files:     1,
+		Manifest:  "s3://analytics/companies/incremental/20250301T120000Z/manifest.json",
+	}, nil
+}
+
+func TestCompanyHandler_ExportCompanies(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("NotEnabled", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.ExportCompanies(context.Background(), &pb.ExportCompaniesRequest{})
+		if status.Code(err) != codes.Unimplemented {
+			t.Errorf("expected code %v, got %v", codes.Unimplemented, status.Code(err))
+		}
+	})
+
+	exporter := &mockExporter{}
+	handler := NewCompanyHandler(&mockCompanyController{}, logger)
+	handler.SetExporter(exporter)
+
+	t.Run("DefaultsToFull", func(t *testing.T) {
+		if _, err := handler.ExportCompanies(context.Background(), &pb.ExportCompaniesRequest{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if exporter.kind != models.ExportFull {
+			t.Errorf("expected a full export, got %q", exporter.kind)
+		}
+	})
+
+	t.Run("Incremental", func(t *testing.T) {
+		resp, err := handler.ExportCompanies(context.Background(), &pb.ExportCompaniesRequest{Kind: pb.ExportKind_INCREMENTAL})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		run := resp.GetExport()
+		if run.GetKind() != pb.ExportKind_INCREMENTAL || run.GetSince() == nil || run.GetCompanies() != 3 || run.GetManifest() == "" {
+			t.Errorf("unexpected export %v", run)
+		}
+	})
+
+	t.Run("InvalidKind", func(t *testing.T) {
+		_, err := handler.ExportCompanies(context.Background(), &pb.ExportCompaniesRequest{Kind: pb.ExportKind(42)})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("InProgress", func(t *testing.T) {
+		exporter.err = export.ErrInProgress
+		defer func() { exporter.err = nil }()
+		_, err := handler.ExportCompanies(context.Background(), &pb.ExportCompaniesRequest{})
+		if status.Code(err) != codes.Aborted {
+			t.Errorf("expected code %v, got %v", codes.Aborted, status.Code(err))
+		}
+	})
+}
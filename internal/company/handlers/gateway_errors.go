@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorEnvelope is the JSON body of every gateway error response.
+type errorEnvelope struct {
+	// Code is the canonical name of the gRPC status code, e.g. "NOT_FOUND".
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Details are the status details in their protobuf JSON form, each
+	// tagged with its "@type".
+	Details   []json.RawMessage `json:"details"`
+	RequestID string            `json:"request_id"`
+}
+
+// gatewayErrorHandler writes gRPC errors returned through the gateway as an
+// errorEnvelope with the matching HTTP status.
+func gatewayErrorHandler(logger *zap.Logger) runtime.ErrorHandlerFunc {
+	return func(ctx context.Context, _ *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+		httpStatus := 0
+		var customStatus *runtime.HTTPStatusError
+		if errors.As(err, &customStatus) {
+			err, httpStatus = customStatus.Err, customStatus.HTTPStatus
+		}
+		st := status.Convert(err)
+		if httpStatus == 0 {
+			httpStatus = runtime.HTTPStatusFromCode(st.Code())
+		}
+
+		requestID := r.Header.Get(RequestIDHeader)
+		if md, ok := runtime.ServerMetadataFromContext(ctx); ok {
+			if ids := md.HeaderMD.Get(RequestIDHeader); len(ids) > 0 {
+				requestID = ids[0]
+			}
+			for k, vs := range md.HeaderMD {
+				h, _ := outgoingHeaderMatcher(k)
+				for _, v := range vs {
+					w.Header().Add(h, v)
+				}
+			}
+		}
+
+		envelope := errorEnvelope{
+			Code:      code.Code(st.Code()).String(),
+			Message:   st.Message(),
+			Details:   make([]json.RawMessage, 0, len(st.Proto().GetDetails())),
+			RequestID: requestID,
+		}
+		for _, detail := range st.Proto().GetDetails() {
+			b, err := marshaler.Marshal(detail)
+			if err != nil {
+				logger.Warn("Failed to marshal error detail", zap.String("type", detail.GetTypeUrl()), zap.Error(err))
+				continue
+			}
+			envelope.Details = append(envelope.Details, b)
+		}
+
+		if st.Code() == codes.Unauthenticated {
+			w.Header().Set("WWW-Authenticate", st.Message())
+		}
+		for _, detail := range st.Details() {
+			if retry, ok := detail.(*errdetails.RetryInfo); ok {
+				seconds := int(math.Ceil(retry.GetRetryDelay().AsDuration().Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			}
+		}
+		writeErrorEnvelope(w, httpStatus, envelope, logger)
+	}
+}
+
+// writeErrorEnvelope writes envelope as the JSON response body.
+func writeErrorEnvelope(w http.ResponseWriter, httpStatus int, envelope errorEnvelope, logger *zap.Logger) {
+	w.Header().Del("Trailer")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	if err := json.NewEncoder(w).Encode(envelope); err != nil {
+		logger.Warn("Failed to write error response", zap.Error(err))
+	}
+}
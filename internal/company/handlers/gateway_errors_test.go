@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGatewayErrorHandler(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	h := &CompanyHandler{logger: logger}
+	handleError := gatewayErrorHandler(logger)
+
+	t.Run("ServiceError", func(t *testing.T) {
+		err := h.mapServiceError(&e.SimilarNameError{Candidates: []models.Company{{ID: uuid.New(), Name: "Acme Corp"}}})
+		ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{
+			HeaderMD: metadata.Pairs(RequestIDHeader, "req-1"),
+		})
+		rec := httptest.NewRecorder()
+		handleError(ctx, nil, newGatewayMarshaler(), rec, httptest.NewRequest(http.MethodPost, "/v1/companies", nil), err)
+
+		if rec.Code != http.StatusConflict {
+			t.Errorf("expected status %d, got %d", http.StatusConflict, rec.Code)
+		}
+		if got := rec.Header().Get("Grpc-Metadata-X-Request-Id"); got != "req-1" {
+			t.Errorf("expected the request ID header to be forwarded, got %q", got)
+		}
+		var body struct {
+			Code      string                   `json:"code"`
+			Message   string                   `json:"message"`
+			Details   []map[string]interface{} `json:"details"`
+			RequestID string                   `json:"request_id"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid JSON %s: %v", rec.Body, err)
+		}
+		if body.Code != "ALREADY_EXISTS" || body.Message == "" || body.RequestID != "req-1" {
+			t.Errorf("unexpected envelope %s", rec.Body)
+		}
+		if len(body.Details) != 2 || body.Details[0]["@type"] != "type.googleapis.com/google.rpc.ResourceInfo" || body.Details[0]["description"] != "Acme Corp" {
+			t.Errorf("unexpected details %v", body.Details)
+		}
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/companies", nil)
+		req.Header.Set(RequestIDHeader, "req-2")
+		rec := httptest.NewRecorder()
+		handleError(context.Background(), nil, newGatewayMarshaler(), rec, req, status.Error(codes.Unauthenticated, "missing token"))
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+		}
+		if got := rec.Header().Get("WWW-Authenticate"); got != "missing token" {
+			t.Errorf("expected WWW-Authenticate to carry the message, got %q", got)
+		}
+		want := `{"code":"UNAUTHENTICATED","message":"missing token","details":[],"request_id":"req-2"}` + "\n"
+		if rec.Body.String() != want {
+			t.Errorf("expected body %s, got %s", want, rec.Body)
+		}
+	})
+	t.Run("QuotaExceeded", func(t *testing.T) {
+		err := h.mapServiceError(&e.QuotaExceededError{TenantID: "acme", Quota: e.QuotaMutationsPerMinute, Limit: 60, RetryAfter: 1500 * time.Millisecond})
+		rec := httptest.NewRecorder()
+		handleError(context.Background(), nil, newGatewayMarshaler(), rec, httptest.NewRequest(http.MethodPost, "/v1/companies", nil), err)
+
+		if rec.Code != http.StatusTooManyRequests {
+			t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+		}
+		if got := rec.Header().Get("Retry-After"); got != "2" {
+			t.Errorf("expected Retry-After rounded up to 2 seconds, got %q", got)
+		}
+	})
+}
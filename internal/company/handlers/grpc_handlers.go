@@ -2,13 +2,17 @@ package handlers
 
 import (
 	"context"
-	"fmt"
+	"slices"
+	"time"
 
 	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/company/models"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // CompanyHandler provides gRPC methods for Company operations,
@@ -17,6 +21,28 @@ type CompanyHandler struct {
 	pb.UnimplementedCompanyServiceServer
 	service CompanyController
 	logger  *zap.Logger
+	// alerts serves the alert webhook methods; nil leaves them unimplemented.
+	alerts AlertWebhookManager
+	// quotas serves the tenant quota methods; nil leaves them unimplemented.
+	quotas TenantQuotaManager
+	// employees serves the employee methods; nil leaves them unimplemented.
+	employees EmployeeManager
+	// notes serves the company note methods; nil leaves them unimplemented.
+	notes NoteManager
+	// exporter serves ExportCompanies; nil leaves it unimplemented.
+	exporter CompanyExporter
+	// jwtKeys serves RotateJWTKeys; nil leaves it unimplemented.
+	jwtKeys JWTKeyReloader
+	// reports serves the report methods; nil leaves them unimplemented.
+	reports ReportRunner
+	// duplicates serves the duplicate suggestion methods and
+	// MergeCompanies; nil leaves them unimplemented.
+	duplicates DuplicateManager
+	// companyTypes serves the company type methods; nil leaves them
+	// unimplemented.
+	companyTypes CompanyTypeManager
+	// visibility hides company fields from callers; nil shows them all.
+	visibility *FieldVisibility
 }
 
 // NewCompanyHandler constructs a new CompanyHandler with the given service and logger.
@@ -38,14 +64,17 @@ func (h *CompanyHandler) CreateCompany(ctx context.Context, req *pb.CreateCompan
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
-	created, err := h.service.CreateCompany(ctx, company)
+	opts := models.CreateOptions{Force: req.GetForce(), ValidateOnly: req.GetValidateOnly(), SuppressEvents: req.GetSuppressEvents()}
+	created, err := h.service.CreateCompany(ctx, company, opts)
 	if err != nil {
 		h.logger.Error("Create company failed", zap.Error(err))
 		return nil, h.mapServiceError(err)
 	}
-	fmt.Println("CREATEd COMPANY", h.modelToProto(created))
+	if !opts.ValidateOnly {
+		setCreated(ctx, "/v1/companies/"+created.ID.String())
+	}
 	return &pb.CreateCompanyResponse{
-		Company: h.modelToProto(created),
+		Company: h.modelToProto(ctx, created),
 	}, nil
 }
 
@@ -60,29 +89,37 @@ func (h *CompanyHandler) UpdateCompany(ctx context.Context, req *pb.UpdateCompan
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
+	h.visibility.restrictUpdate(ctx, update)
 
-	updated, err := h.service.UpdateCompany(ctx, update)
+	updated, err := h.service.UpdateCompany(ctx, update, models.UpdateOptions{ValidateOnly: req.GetValidateOnly(), SuppressEvents: req.GetSuppressEvents()})
 	if err != nil {
 		return nil, h.mapServiceError(err)
 	}
 
 	return &pb.UpdateCompanyResponse{
-		Company: h.modelToProto(updated),
+		Company: h.modelToProto(ctx, updated),
 	}, nil
 }
 
-// DeleteCompany removes a Company given its ID.
+// DeleteCompany removes a Company given its ID and reports whether it
+// existed.
 func (h *CompanyHandler) DeleteCompany(ctx context.Context, req *pb.DeleteCompanyRequest) (*pb.DeleteCompanyResponse, error) {
 	id, err := uuid.Parse(req.GetId())
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
 	}
 
-	if err := h.service.DeleteCompany(ctx, id); err != nil {
+	deleted, err := h.service.DeleteCompany(ctx, id)
+	if err != nil {
 		return nil, h.mapServiceError(err)
 	}
+	// A company already absent, with idempotent deletes, answers 200 with
+	// the flag rather than an empty 204.
+	if deleted {
+		setNoContent(ctx)
+	}
 
-	return &pb.DeleteCompanyResponse{}, nil
+	return &pb.DeleteCompanyResponse{Deleted: deleted}, nil
 }
 
 // GetCompany fetches a Company by ID, returning an error if not found.
@@ -96,8 +133,303 @@ func (h *CompanyHandler) GetCompany(ctx context.Context, req *pb.GetCompanyReque
 	if err != nil {
 		return nil, h.mapServiceError(err)
 	}
+	setCompanyETag(ctx, "v1", company)
 
 	return &pb.GetCompanyResponse{
-		Company: h.modelToProto(company),
+		Company: h.modelToProto(ctx, company),
+	}, nil
+}
+
+// GetCompanyByName fetches a Company by name, ignoring case, returning an
+// error if not found.
+func (h *CompanyHandler) GetCompanyByName(ctx context.Context, req *pb.GetCompanyByNameRequest) (*pb.GetCompanyByNameResponse, error) {
+	company, err := h.service.GetCompanyByName(ctx, req.GetName())
+	if err != nil {
+		return nil, h.mapServiceError(err)
+	}
+
+	return &pb.GetCompanyByNameResponse{
+		Company: h.modelToProto(ctx, company),
+	}, nil
+}
+
+// GetCompanyByExternalRef fetches a Company by its external reference,
+// returning an error if not found.
+func (h *CompanyHandler) GetCompanyByExternalRef(ctx context.Context, req *pb.GetCompanyByExternalRefRequest) (*pb.GetCompanyByExternalRefResponse, error) {
+	company, err := h.service.GetCompanyByExternalRef(ctx, req.GetExternalRef())
+	if err != nil {
+		return nil, h.mapServiceError(err)
+	}
+
+	return &pb.GetCompanyByExternalRefResponse{
+		Company: h.modelToProto(ctx, company),
+	}, nil
+}
+
+// ListCompanies returns a page of companies, optionally filtered by employee
+// range, status and metadata.
+func (h *CompanyHandler) ListCompanies(ctx context.Context, req *pb.ListCompaniesRequest) (*pb.ListCompaniesResponse, error) {
+	filter, err := companyFilter(req.GetEmployeeRanges(), req.GetStatuses(), req.GetMetadata())
+	if err != nil {
+		return nil, err
+	}
+
+	companies, next, err := h.service.ListCompanies(ctx, filter, int(req.GetPageSize()), req.GetPageToken())
+	if err != nil {
+		return nil, h.mapServiceError(err)
+	}
+
+	resp := &pb.ListCompaniesResponse{NextPageToken: next}
+	for i := range companies {
+		resp.Companies = append(resp.Companies, h.modelToProto(ctx, &companies[i]))
+	}
+	return resp, nil
+}
+
+// CountCompanies returns the number of companies matching the request's
+// filter.
+func (h *CompanyHandler) CountCompanies(ctx context.Context, req *pb.CountCompaniesRequest) (*pb.CountCompaniesResponse, error) {
+	filter, err := companyFilter(req.GetEmployeeRanges(), req.GetStatuses(), req.GetMetadata())
+	if err != nil {
+		return nil, err
+	}
+	count, err := h.service.CountCompanies(ctx, filter)
+	if err != nil {
+		return nil, h.mapServiceError(err)
+	}
+	return &pb.CountCompaniesResponse{Count: count}, nil
+}
+
+// ListMyCompanies returns a page of the companies created by the caller.
+func (h *CompanyHandler) ListMyCompanies(ctx context.Context, req *pb.ListMyCompaniesRequest) (*pb.ListCompaniesResponse, error) {
+	filter := models.CompanyFilter{Metadata: req.GetMetadata()}
+	for _, st := range req.GetStatuses() {
+		if st == pb.CompanyStatus_COMPANY_STATUS_UNSPECIFIED {
+			return nil, status.Error(codes.InvalidArgument, "invalid status")
+		}
+		filter.Statuses = append(filter.Statuses, models.CompanyStatus(st.String()))
+	}
+
+	companies, next, err := h.service.ListMyCompanies(ctx, filter, int(req.GetPageSize()), req.GetPageToken())
+	if err != nil {
+		return nil, h.mapServiceError(err)
+	}
+
+	resp := &pb.ListCompaniesResponse{NextPageToken: next}
+	for i := range companies {
+		resp.Companies = append(resp.Companies, h.modelToProto(ctx, &companies[i]))
+	}
+	return resp, nil
+}
+
+// PurgeCompany permanently removes a soft-deleted Company given its ID.
+func (h *CompanyHandler) PurgeCompany(ctx context.Context, req *pb.PurgeCompanyRequest) (*pb.PurgeCompanyResponse, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+
+	if err := h.service.PurgeCompany(ctx, id); err != nil {
+		return nil, h.mapServiceError(err)
+	}
+
+	return &pb.PurgeCompanyResponse{}, nil
+}
+
+// SuspendCompany moves an ACTIVE Company to SUSPENDED.
+func (h *CompanyHandler) SuspendCompany(ctx context.Context, req *pb.SuspendCompanyRequest) (*pb.SuspendCompanyResponse, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+
+	company, err := h.service.SuspendCompany(ctx, id)
+	if err != nil {
+		return nil, h.mapServiceError(err)
+	}
+
+	return &pb.SuspendCompanyResponse{
+		Company: h.modelToProto(ctx, company),
 	}, nil
 }
+
+// EraseCompanyData scrubs the personal data of a Company, returning what is
+// kept of it.
+func (h *CompanyHandler) EraseCompanyData(ctx context.Context, req *pb.EraseCompanyDataRequest) (*pb.EraseCompanyDataResponse, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+
+	company, err := h.service.EraseCompanyData(ctx, id)
+	if err != nil {
+		return nil, h.mapServiceError(err)
+	}
+
+	return &pb.EraseCompanyDataResponse{
+		Company: h.modelToProto(ctx, company),
+	}, nil
+}
+
+// ActivateCompany moves a DRAFT or SUSPENDED Company to ACTIVE.
+func (h *CompanyHandler) ActivateCompany(ctx context.Context, req *pb.ActivateCompanyRequest) (*pb.ActivateCompanyResponse, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+
+	company, err := h.service.ActivateCompany(ctx, id)
+	if err != nil {
+		return nil, h.mapServiceError(err)
+	}
+
+	return &pb.ActivateCompanyResponse{
+		Company: h.modelToProto(ctx, company),
+	}, nil
+}
+
+// ReplayCompanyEvents re-emits stored company events matching the request
+// filter to the requested topic.
+func (h *CompanyHandler) ReplayCompanyEvents(ctx context.Context, req *pb.ReplayCompanyEventsRequest) (*pb.ReplayCompanyEventsResponse, error) {
+	filter := models.CompanyEventFilter{Types: req.GetEventTypes()}
+	for _, raw := range req.GetCompanyIds() {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+		}
+		filter.CompanyIDs = append(filter.CompanyIDs, id)
+	}
+	if req.GetSince() != nil {
+		filter.Since = req.GetSince().AsTime()
+	}
+	if req.GetUntil() != nil {
+		filter.Until = req.GetUntil().AsTime()
+	}
+
+	replayed, err := h.service.ReplayCompanyEvents(ctx, filter, req.GetTargetTopic())
+	if err != nil {
+		h.logger.Error("Replay company events failed", zap.Error(err), zap.Int("replayed", replayed))
+		return nil, h.mapServiceError(err)
+	}
+	return &pb.ReplayCompanyEventsResponse{Replayed: int64(replayed)}, nil
+}
+
+// GetCompanyHistory returns a page of the stored events of a company.
+func (h *CompanyHandler) GetCompanyHistory(ctx context.Context, req *pb.GetCompanyHistoryRequest) (*pb.GetCompanyHistoryResponse, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+	var since, until time.Time
+	if req.GetSince() != nil {
+		since = req.GetSince().AsTime()
+	}
+	if req.GetUntil() != nil {
+		until = req.GetUntil().AsTime()
+	}
+
+	history, next, err := h.service.GetCompanyHistory(ctx, id, since, until, int(req.GetPageSize()), req.GetPageToken())
+	if err != nil {
+		return nil, h.mapServiceError(err)
+	}
+	resp := &pb.GetCompanyHistoryResponse{NextPageToken: next}
+	for i := range history {
+		event := &history[i]
+		entry := &pb.CompanyHistoryEntry{
+			EventId:     event.ID.String(),
+			EventType:   event.Type,
+			Company:     h.modelToProto(ctx, &event.Company),
+			Actor:       event.Actor,
+			PublishedAt: timestamppb.New(event.CreatedAt),
+		}
+		for field := range event.Changes {
+			entry.ChangedFields = append(entry.ChangedFields, field)
+		}
+		slices.Sort(entry.ChangedFields)
+		resp.Events = append(resp.Events, entry)
+	}
+	return resp, nil
+}
+
+// WatchCompanies streams the company events matching the request filter
+// published after its resume token, each with the token resuming after it,
+// until the client disconnects.
+func (h *CompanyHandler) WatchCompanies(req *pb.WatchCompaniesRequest, stream grpc.ServerStreamingServer[pb.WatchCompaniesResponse]) error {
+	filter := models.CompanyEventFilter{Types: req.GetEventTypes()}
+	for _, raw := range req.GetCompanyIds() {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, "invalid company ID")
+		}
+		filter.CompanyIDs = append(filter.CompanyIDs, id)
+	}
+
+	ctx := stream.Context()
+	err := h.service.WatchCompanyEvents(ctx, filter, req.GetResumeToken(), func(event *models.CompanyEvent, resumeToken string) error {
+		company := event.Company
+		return stream.Send(&pb.WatchCompaniesResponse{
+			EventId:     event.ID.String(),
+			EventType:   event.Type,
+			Company:     h.modelToProto(ctx, &company),
+			PublishedAt: timestamppb.New(event.CreatedAt),
+			ResumeToken: resumeToken,
+		})
+	})
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	if err != nil {
+		h.logger.Error("Watch companies failed", zap.Error(err))
+		return h.mapServiceError(err)
+	}
+	return nil
+}
+
+// ApplyCompanies converges the companies to the desired state in the request,
+// or with plan_only reports the changes that would be made.
+func (h *CompanyHandler) ApplyCompanies(ctx context.Context, req *pb.ApplyCompaniesRequest) (*pb.ApplyCompaniesResponse, error) {
+	desired := make([]models.Company, 0, len(req.GetCompanies()))
+	for _, pbCompany := range req.GetCompanies() {
+		company, err := h.protoToModel(pbCompany)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		desired = append(desired, *company)
+	}
+
+	opts := models.ApplyOptions{PlanOnly: req.GetPlanOnly(), Prune: req.GetPrune(), SuppressEvents: req.GetSuppressEvents()}
+	changes, err := h.service.ApplyCompanies(ctx, desired, opts)
+	if err != nil {
+		h.logger.Error("Apply companies failed", zap.Error(err))
+		return nil, h.mapServiceError(err)
+	}
+	resp := &pb.ApplyCompaniesResponse{}
+	for _, change := range changes {
+		resp.Changes = append(resp.Changes, &pb.CompanyChange{
+			Action:        pb.ApplyAction(pb.ApplyAction_value[string(change.Action)]),
+			ExternalRef:   change.Company.ExternalRef,
+			Company:       h.modelToProto(ctx, change.Company),
+			ChangedFields: change.Fields,
+		})
+	}
+	return resp, nil
+}
+
+// companyFilter converts the filter fields shared by the list and count
+// requests.
+func companyFilter(ranges []pb.EmployeeRange, statuses []pb.CompanyStatus, metadata map[string]string) (models.CompanyFilter, error) {
+	filter := models.CompanyFilter{Metadata: metadata}
+	for _, r := range ranges {
+		if r == pb.EmployeeRange_EMPLOYEE_RANGE_UNSPECIFIED {
+			return filter, status.Error(codes.InvalidArgument, "invalid employee range")
+		}
+		filter.EmployeeRanges = append(filter.EmployeeRanges, models.EmployeeRange(r.String()))
+	}
+	for _, st := range statuses {
+		if st == pb.CompanyStatus_COMPANY_STATUS_UNSPECIFIED {
+			return filter, status.Error(codes.InvalidArgument, "invalid status")
+		}
+		filter.Statuses = append(filter.Statuses, models.CompanyStatus(st.String()))
+	}
+	return filter, nil
+}
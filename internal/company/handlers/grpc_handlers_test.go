@@ -3,33 +3,51 @@ package handlers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	e "github.com/gartstein/xm/internal/company/errors"
 	"github.com/gartstein/xm/internal/company/models"
 	"github.com/google/uuid"
 	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // mockCompanyController is a simple mock implementation of CompanyController.
 type mockCompanyController struct {
-	createCompanyFunc func(ctx context.Context, company *models.Company) (*models.Company, error)
-	updateCompanyFunc func(ctx context.Context, update *models.CompanyUpdate) (*models.Company, error)
-	deleteCompanyFunc func(ctx context.Context, id uuid.UUID) error
+	createCompanyFunc func(ctx context.Context, company *models.Company, opts models.CreateOptions) (*models.Company, error)
+	updateCompanyFunc func(ctx context.Context, update *models.CompanyUpdate, opts models.UpdateOptions) (*models.Company, error)
+	deleteCompanyFunc func(ctx context.Context, id uuid.UUID) (bool, error)
 	getCompanyFunc    func(ctx context.Context, id uuid.UUID) (*models.Company, error)
+	getByName         func(ctx context.Context, name string) (*models.Company, error)
+	getByExternalRef  func(ctx context.Context, ref string) (*models.Company, error)
+	listCompaniesFunc func(ctx context.Context, filter models.CompanyFilter, pageSize int, pageToken string) ([]models.Company, string, error)
+	listMineFunc      func(ctx context.Context, filter models.CompanyFilter, pageSize int, pageToken string) ([]models.Company, string, error)
+	countFunc         func(ctx context.Context, filter models.CompanyFilter) (int64, error)
+	purgeCompanyFunc  func(ctx context.Context, id uuid.UUID) error
+	suspendFunc       func(ctx context.Context, id uuid.UUID) (*models.Company, error)
+	activateFunc      func(ctx context.Context, id uuid.UUID) (*models.Company, error)
+	eraseFunc         func(ctx context.Context, id uuid.UUID) (*models.Company, error)
+	replayEventsFunc  func(ctx context.Context, filter models.CompanyEventFilter, topic string) (int, error)
+	historyFunc       func(ctx context.Context, company uuid.UUID, since, until time.Time, pageSize int, pageToken string) ([]models.CompanyEvent, string, error)
+	watchEventsFunc   func(ctx context.Context, filter models.CompanyEventFilter, resumeToken string, fn func(*models.CompanyEvent, string) error) error
+	applyFunc         func(ctx context.Context, desired []models.Company, opts models.ApplyOptions) ([]models.CompanyChange, error)
 }
 
-func (m *mockCompanyController) CreateCompany(ctx context.Context, company *models.Company) (*models.Company, error) {
-	return m.createCompanyFunc(ctx, company)
+func (m *mockCompanyController) CreateCompany(ctx context.Context, company *models.Company, opts models.CreateOptions) (*models.Company, error) {
+	return m.createCompanyFunc(ctx, company, opts)
 }
 
-func (m *mockCompanyController) UpdateCompany(ctx context.Context, update *models.CompanyUpdate) (*models.Company, error) {
-	return m.updateCompanyFunc(ctx, update)
+func (m *mockCompanyController) UpdateCompany(ctx context.Context, update *models.CompanyUpdate, opts models.UpdateOptions) (*models.Company, error) {
+	return m.updateCompanyFunc(ctx, update, opts)
 }
 
-func (m *mockCompanyController) DeleteCompany(ctx context.Context, id uuid.UUID) error {
+func (m *mockCompanyController) DeleteCompany(ctx context.Context, id uuid.UUID) (bool, error) {
 	return m.deleteCompanyFunc(ctx, id)
 }
 
@@ -37,6 +55,58 @@ func (m *mockCompanyController) GetCompany(ctx context.Context, id uuid.UUID) (*
 	return m.getCompanyFunc(ctx, id)
 }
 
+func (m *mockCompanyController) GetCompanyByName(ctx context.Context, name string) (*models.Company, error) {
+	return m.getByName(ctx, name)
+}
+
+func (m *mockCompanyController) GetCompanyByExternalRef(ctx context.Context, ref string) (*models.Company, error) {
+	return m.getByExternalRef(ctx, ref)
+}
+
+func (m *mockCompanyController) ListCompanies(ctx context.Context, filter models.CompanyFilter, pageSize int, pageToken string) ([]models.Company, string, error) {
+	return m.listCompaniesFunc(ctx, filter, pageSize, pageToken)
+}
+
+func (m *mockCompanyController) CountCompanies(ctx context.Context, filter models.CompanyFilter) (int64, error) {
+	return m.countFunc(ctx, filter)
+}
+
+func (m *mockCompanyController) ListMyCompanies(ctx context.Context, filter models.CompanyFilter, pageSize int, pageToken string) ([]models.Company, string, error) {
+	return m.listMineFunc(ctx, filter, pageSize, pageToken)
+}
+
+func (m *mockCompanyController) PurgeCompany(ctx context.Context, id uuid.UUID) error {
+	return m.purgeCompanyFunc(ctx, id)
+}
+
+func (m *mockCompanyController) EraseCompanyData(ctx context.Context, id uuid.UUID) (*models.Company, error) {
+	return m.eraseFunc(ctx, id)
+}
+
+func (m *mockCompanyController) SuspendCompany(ctx context.Context, id uuid.UUID) (*models.Company, error) {
+	return m.suspendFunc(ctx, id)
+}
+
+func (m *mockCompanyController) ActivateCompany(ctx context.Context, id uuid.UUID) (*models.Company, error) {
+	return m.activateFunc(ctx, id)
+}
+
+func (m *mockCompanyController) ReplayCompanyEvents(ctx context.Context, filter models.CompanyEventFilter, topic string) (int, error) {
+	return m.replayEventsFunc(ctx, filter, topic)
+}
+
+func (m *mockCompanyController) GetCompanyHistory(ctx context.Context, company uuid.UUID, since, until time.Time, pageSize int, pageToken string) ([]models.CompanyEvent, string, error) {
+	return m.historyFunc(ctx, company, since, until, pageSize, pageToken)
+}
+
+func (m *mockCompanyController) WatchCompanyEvents(ctx context.Context, filter models.CompanyEventFilter, resumeToken string, fn func(*models.CompanyEvent, string) error) error {
+	return m.watchEventsFunc(ctx, filter, resumeToken, fn)
+}
+
+func (m *mockCompanyController) ApplyCompanies(ctx context.Context, desired []models.Company, opts models.ApplyOptions) ([]models.CompanyChange, error) {
+	return m.applyFunc(ctx, desired, opts)
+}
+
 // Test for CreateCompany.
 func TestCompanyHandler_CreateCompany(t *testing.T) {
 	logger := zaptest.NewLogger(t)
@@ -58,7 +128,7 @@ func TestCompanyHandler_CreateCompany(t *testing.T) {
 	t.Run("ServiceError", func(t *testing.T) {
 		expectedErr := errors.New("service error")
 		mockCtrl := &mockCompanyController{
-			createCompanyFunc: func(_ context.Context, _ *models.Company) (*models.Company, error) {
+			createCompanyFunc: func(_ context.Context, _ *models.Company, _ models.CreateOptions) (*models.Company, error) {
 				return nil, expectedErr
 			},
 		}
@@ -85,7 +155,10 @@ func TestCompanyHandler_CreateCompany(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		testID := uuid.New()
 		mockCtrl := &mockCompanyController{
-			createCompanyFunc: func(_ context.Context, company *models.Company) (*models.Company, error) {
+			createCompanyFunc: func(_ context.Context, company *models.Company, opts models.CreateOptions) (*models.Company, error) {
+				if !opts.Force || !opts.ValidateOnly {
+					t.Errorf("expected the request flags to be passed through, got %+v", opts)
+				}
 				company.ID = testID
 				return company, nil
 			},
@@ -98,7 +171,7 @@ func TestCompanyHandler_CreateCompany(t *testing.T) {
 			Registered:  true,
 			Type:        pb.CompanyType_CORPORATIONS,
 		}
-		req := &pb.CreateCompanyRequest{Company: pbCompany}
+		req := &pb.CreateCompanyRequest{Company: pbCompany, Force: true, ValidateOnly: true}
 		resp, err := handler.CreateCompany(context.Background(), req)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
@@ -133,7 +206,7 @@ func TestCompanyHandler_PatchCompany(t *testing.T) {
 	t.Run("ServiceError", func(t *testing.T) {
 		expectedErr := errors.New("update error")
 		mockCtrl := &mockCompanyController{
-			updateCompanyFunc: func(_ context.Context, _ *models.CompanyUpdate) (*models.Company, error) {
+			updateCompanyFunc: func(_ context.Context, _ *models.CompanyUpdate, _ models.UpdateOptions) (*models.Company, error) {
 				return nil, expectedErr
 			},
 		}
@@ -163,7 +236,7 @@ func TestCompanyHandler_PatchCompany(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		testID := uuid.New()
 		mockCtrl := &mockCompanyController{
-			updateCompanyFunc: func(_ context.Context, _ *models.CompanyUpdate) (*models.Company, error) {
+			updateCompanyFunc: func(_ context.Context, _ *models.CompanyUpdate, _ models.UpdateOptions) (*models.Company, error) {
 				return &models.Company{
 					ID:          testID,
 					Name:        "Updated Name",
@@ -217,8 +290,8 @@ func TestCompanyHandler_DeleteCompany(t *testing.T) {
 	t.Run("ServiceError", func(t *testing.T) {
 		expectedErr := errors.New("delete error")
 		mockCtrl := &mockCompanyController{
-			deleteCompanyFunc: func(_ context.Context, _ uuid.UUID) error {
-				return expectedErr
+			deleteCompanyFunc: func(_ context.Context, _ uuid.UUID) (bool, error) {
+				return false, expectedErr
 			},
 		}
 		handler := NewCompanyHandler(mockCtrl, logger)
@@ -236,8 +309,8 @@ func TestCompanyHandler_DeleteCompany(t *testing.T) {
 
 	t.Run("Success", func(t *testing.T) {
 		mockCtrl := &mockCompanyController{
-			deleteCompanyFunc: func(_ context.Context, _ uuid.UUID) error {
-				return nil
+			deleteCompanyFunc: func(_ context.Context, _ uuid.UUID) (bool, error) {
+				return true, nil
 			},
 		}
 		handler := NewCompanyHandler(mockCtrl, logger)
@@ -247,8 +320,29 @@ func TestCompanyHandler_DeleteCompany(t *testing.T) {
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
-		if resp == nil {
-			t.Error("expected non-nil response")
+		if !resp.GetDeleted() {
+			t.Error("expected the company to be reported deleted")
+		}
+	})
+
+	t.Run("AlreadyDeleted", func(t *testing.T) {
+		mockCtrl := &mockCompanyController{
+			deleteCompanyFunc: func(_ context.Context, _ uuid.UUID) (bool, error) {
+				return false, nil
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		stream := &headerStream{}
+		ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+		resp, err := handler.DeleteCompany(ctx, &pb.DeleteCompanyRequest{Id: uuid.New().String()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.GetDeleted() {
+			t.Error("expected the company to be reported not deleted")
+		}
+		if got := stream.header.Get(httpStatusHeader); len(got) != 0 {
+			t.Errorf("expected no status override, got %v", got)
 		}
 	})
 }
@@ -319,3 +413,626 @@ func TestCompanyHandler_GetCompany(t *testing.T) {
 		}
 	})
 }
+
+// Test for GetCompanyByName.
+func TestCompanyHandler_GetCompanyByName(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("InvalidName", func(t *testing.T) {
+		mockCtrl := &mockCompanyController{
+			getByName: func(_ context.Context, _ string) (*models.Company, error) {
+				return nil, e.ErrInvalidInput
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		_, err := handler.GetCompanyByName(context.Background(), &pb.GetCompanyByNameRequest{})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		testID := uuid.New()
+		mockCtrl := &mockCompanyController{
+			getByName: func(_ context.Context, name string) (*models.Company, error) {
+				if name != "acme" {
+					t.Errorf("expected name acme, got %q", name)
+				}
+				return &models.Company{ID: testID, Name: "Acme"}, nil
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		resp, err := handler.GetCompanyByName(context.Background(), &pb.GetCompanyByNameRequest{Name: "acme"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.GetCompany().GetId() != testID.String() {
+			t.Errorf("expected ID %v, got %v", testID, resp.GetCompany().GetId())
+		}
+	})
+}
+
+// Test for GetCompanyByExternalRef.
+func TestCompanyHandler_GetCompanyByExternalRef(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("NotFound", func(t *testing.T) {
+		mockCtrl := &mockCompanyController{
+			getByExternalRef: func(_ context.Context, _ string) (*models.Company, error) {
+				return nil, e.ErrNotFound
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		_, err := handler.GetCompanyByExternalRef(context.Background(), &pb.GetCompanyByExternalRefRequest{ExternalRef: "ERP-1"})
+		if status.Code(err) != codes.NotFound {
+			t.Errorf("expected code %v, got %v", codes.NotFound, status.Code(err))
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		testID := uuid.New()
+		mockCtrl := &mockCompanyController{
+			getByExternalRef: func(_ context.Context, ref string) (*models.Company, error) {
+				return &models.Company{ID: testID, ExternalRef: ref}, nil
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		resp, err := handler.GetCompanyByExternalRef(context.Background(), &pb.GetCompanyByExternalRefRequest{ExternalRef: "ERP-1"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.GetCompany().GetId() != testID.String() || resp.GetCompany().GetExternalRef() != "ERP-1" {
+			t.Errorf("unexpected company %v", resp.GetCompany())
+		}
+	})
+}
+
+// Test for ListCompanies.
+func TestCompanyHandler_ListCompanies(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("UnspecifiedRange", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.ListCompanies(context.Background(), &pb.ListCompaniesRequest{
+			EmployeeRanges: []pb.EmployeeRange{pb.EmployeeRange_EMPLOYEE_RANGE_UNSPECIFIED},
+		})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("UnspecifiedStatus", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.ListCompanies(context.Background(), &pb.ListCompaniesRequest{
+			Statuses: []pb.CompanyStatus{pb.CompanyStatus_COMPANY_STATUS_UNSPECIFIED},
+		})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		testID := uuid.New()
+		mockCtrl := &mockCompanyController{
+			listCompaniesFunc: func(_ context.Context, filter models.CompanyFilter, pageSize int, pageToken string) ([]models.Company, string, error) {
+				if len(filter.EmployeeRanges) != 1 || filter.EmployeeRanges[0] != models.Employees11To50 {
+					t.Errorf("unexpected filter %+v", filter)
+				}
+				if len(filter.Statuses) != 1 || filter.Statuses[0] != models.StatusSuspended {
+					t.Errorf("unexpected status filter %+v", filter.Statuses)
+				}
+				if pageSize != 10 || pageToken != "abc" {
+					t.Errorf("unexpected page size %d or token %q", pageSize, pageToken)
+				}
+				return []models.Company{{ID: testID, Employees: 20, EmployeeRange: models.Employees11To50}}, "next", nil
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		resp, err := handler.ListCompanies(context.Background(), &pb.ListCompaniesRequest{
+			PageSize:       10,
+			PageToken:      "abc",
+			EmployeeRanges: []pb.EmployeeRange{pb.EmployeeRange_EMPLOYEES_11_50},
+			Statuses:       []pb.CompanyStatus{pb.CompanyStatus_SUSPENDED},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(resp.GetCompanies()) != 1 || resp.GetCompanies()[0].GetId() != testID.String() {
+			t.Fatalf("unexpected companies %v", resp.GetCompanies())
+		}
+		if resp.GetCompanies()[0].GetEmployeeRange() != pb.EmployeeRange_EMPLOYEES_11_50 {
+			t.Errorf("expected employee range %v, got %v", pb.EmployeeRange_EMPLOYEES_11_50, resp.GetCompanies()[0].GetEmployeeRange())
+		}
+		if resp.GetNextPageToken() != "next" {
+			t.Errorf("expected next page token, got %q", resp.GetNextPageToken())
+		}
+	})
+
+	t.Run("ServiceError", func(t *testing.T) {
+		mockCtrl := &mockCompanyController{
+			listCompaniesFunc: func(_ context.Context, _ models.CompanyFilter, _ int, _ string) ([]models.Company, string, error) {
+				return nil, "", e.ErrInvalidInput
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		_, err := handler.ListCompanies(context.Background(), &pb.ListCompaniesRequest{PageToken: "bad"})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+}
+
+// Test for CountCompanies.
+func TestCompanyHandler_CountCompanies(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("UnspecifiedStatus", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.CountCompanies(context.Background(), &pb.CountCompaniesRequest{
+			Statuses: []pb.CompanyStatus{pb.CompanyStatus_COMPANY_STATUS_UNSPECIFIED},
+		})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockCtrl := &mockCompanyController{
+			countFunc: func(_ context.Context, filter models.CompanyFilter) (int64, error) {
+				if len(filter.EmployeeRanges) != 1 || filter.EmployeeRanges[0] != models.Employees11To50 {
+					t.Errorf("unexpected filter %+v", filter)
+				}
+				return 42, nil
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		resp, err := handler.CountCompanies(context.Background(), &pb.CountCompaniesRequest{
+			EmployeeRanges: []pb.EmployeeRange{pb.EmployeeRange_EMPLOYEES_11_50},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.GetCount() != 42 {
+			t.Errorf("expected 42, got %d", resp.GetCount())
+		}
+	})
+}
+
+// Test for ListMyCompanies.
+func TestCompanyHandler_ListMyCompanies(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("UnspecifiedStatus", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.ListMyCompanies(context.Background(), &pb.ListMyCompaniesRequest{
+			Statuses: []pb.CompanyStatus{pb.CompanyStatus_COMPANY_STATUS_UNSPECIFIED},
+		})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		testID := uuid.New()
+		mockCtrl := &mockCompanyController{
+			listMineFunc: func(_ context.Context, filter models.CompanyFilter, pageSize int, pageToken string) ([]models.Company, string, error) {
+				if len(filter.Statuses) != 1 || filter.Statuses[0] != models.StatusDraft {
+					t.Errorf("unexpected status filter %+v", filter.Statuses)
+				}
+				if pageSize != 5 || pageToken != "abc" {
+					t.Errorf("unexpected page size %d or token %q", pageSize, pageToken)
+				}
+				return []models.Company{{ID: testID, CreatedBy: "alice"}}, "next", nil
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		resp, err := handler.ListMyCompanies(context.Background(), &pb.ListMyCompaniesRequest{
+			PageSize:  5,
+			PageToken: "abc",
+			Statuses:  []pb.CompanyStatus{pb.CompanyStatus_DRAFT},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(resp.GetCompanies()) != 1 || resp.GetCompanies()[0].GetId() != testID.String() {
+			t.Fatalf("unexpected companies %v", resp.GetCompanies())
+		}
+		if resp.GetNextPageToken() != "next" {
+			t.Errorf("expected next page token, got %q", resp.GetNextPageToken())
+		}
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		mockCtrl := &mockCompanyController{
+			listMineFunc: func(_ context.Context, _ models.CompanyFilter, _ int, _ string) ([]models.Company, string, error) {
+				return nil, "", e.ErrInvalidInput
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		_, err := handler.ListMyCompanies(context.Background(), &pb.ListMyCompaniesRequest{})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+}
+
+// Test for PurgeCompany.
+func TestCompanyHandler_PurgeCompany(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("InvalidID", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.PurgeCompany(context.Background(), &pb.PurgeCompanyRequest{Id: "invalid-uuid"})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("NotDeleted", func(t *testing.T) {
+		mockCtrl := &mockCompanyController{
+			purgeCompanyFunc: func(_ context.Context, _ uuid.UUID) error {
+				return e.ErrNotFound
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		_, err := handler.PurgeCompany(context.Background(), &pb.PurgeCompanyRequest{Id: uuid.New().String()})
+		if status.Code(err) != codes.NotFound {
+			t.Errorf("expected code %v, got %v", codes.NotFound, status.Code(err))
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		testID := uuid.New()
+		mockCtrl := &mockCompanyController{
+			purgeCompanyFunc: func(_ context.Context, id uuid.UUID) error {
+				if id != testID {
+					t.Errorf("expected ID %v, got %v", testID, id)
+				}
+				return nil
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		resp, err := handler.PurgeCompany(context.Background(), &pb.PurgeCompanyRequest{Id: testID.String()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp == nil {
+			t.Error("expected non-nil response")
+		}
+	})
+}
+
+// Test for SuspendCompany and ActivateCompany.
+func TestCompanyHandler_StatusChanges(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("InvalidID", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.SuspendCompany(context.Background(), &pb.SuspendCompanyRequest{Id: "invalid-uuid"})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("InvalidTransition", func(t *testing.T) {
+		mockCtrl := &mockCompanyController{
+			activateFunc: func(_ context.Context, _ uuid.UUID) (*models.Company, error) {
+				return nil, fmt.Errorf("%w: ARCHIVED to ACTIVE", e.ErrInvalidStatusTransition)
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		_, err := handler.ActivateCompany(context.Background(), &pb.ActivateCompanyRequest{Id: uuid.New().String()})
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("expected code %v, got %v", codes.FailedPrecondition, status.Code(err))
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		testID := uuid.New()
+		mockCtrl := &mockCompanyController{
+			suspendFunc: func(_ context.Context, id uuid.UUID) (*models.Company, error) {
+				return &models.Company{ID: id, Name: "Acme", Status: models.StatusSuspended}, nil
+			},
+			activateFunc: func(_ context.Context, id uuid.UUID) (*models.Company, error) {
+				return &models.Company{ID: id, Name: "Acme", Status: models.StatusActive}, nil
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		suspended, err := handler.SuspendCompany(context.Background(), &pb.SuspendCompanyRequest{Id: testID.String()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if suspended.GetCompany().GetStatus() != pb.CompanyStatus_SUSPENDED {
+			t.Errorf("expected status %v, got %v", pb.CompanyStatus_SUSPENDED, suspended.GetCompany().GetStatus())
+		}
+		activated, err := handler.ActivateCompany(context.Background(), &pb.ActivateCompanyRequest{Id: testID.String()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if activated.GetCompany().GetStatus() != pb.CompanyStatus_ACTIVE {
+			t.Errorf("expected status %v, got %v", pb.CompanyStatus_ACTIVE, activated.GetCompany().GetStatus())
+		}
+	})
+}
+
+// Test for EraseCompanyData.
+func TestCompanyHandler_EraseCompanyData(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("InvalidID", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.EraseCompanyData(context.Background(), &pb.EraseCompanyDataRequest{Id: "invalid-uuid"})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		mockCtrl := &mockCompanyController{
+			eraseFunc: func(_ context.Context, _ uuid.UUID) (*models.Company, error) {
+				return nil, e.ErrCompanyNotFound
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		_, err := handler.EraseCompanyData(context.Background(), &pb.EraseCompanyDataRequest{Id: uuid.New().String()})
+		if status.Code(err) != codes.NotFound {
+			t.Errorf("expected code %v, got %v", codes.NotFound, status.Code(err))
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		mockCtrl := &mockCompanyController{
+			eraseFunc: func(_ context.Context, id uuid.UUID) (*models.Company, error) {
+				return &models.Company{ID: id, Name: "Acme"}, nil
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		resp, err := handler.EraseCompanyData(context.Background(), &pb.EraseCompanyDataRequest{Id: uuid.New().String()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.GetCompany().GetName() != "Acme" || resp.GetCompany().GetDescription() != "" {
+			t.Errorf("expected the kept company, got %v", resp.GetCompany())
+		}
+	})
+}
+
+// Test for ReplayCompanyEvents.
+func TestCompanyHandler_ReplayCompanyEvents(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("InvalidCompanyID", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.ReplayCompanyEvents(context.Background(), &pb.ReplayCompanyEventsRequest{
+			CompanyIds:  []string{"invalid-uuid"},
+			TargetTopic: "rebuild",
+		})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("ServiceError", func(t *testing.T) {
+		mockCtrl := &mockCompanyController{
+			replayEventsFunc: func(_ context.Context, _ models.CompanyEventFilter, _ string) (int, error) {
+				return 0, e.ErrInvalidInput
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		_, err := handler.ReplayCompanyEvents(context.Background(), &pb.ReplayCompanyEventsRequest{})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		testID := uuid.New()
+		since := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+		mockCtrl := &mockCompanyController{
+			replayEventsFunc: func(_ context.Context, filter models.CompanyEventFilter, topic string) (int, error) {
+				if len(filter.CompanyIDs) != 1 || filter.CompanyIDs[0] != testID {
+					t.Errorf("unexpected company IDs %v", filter.CompanyIDs)
+				}
+				if !filter.Since.Equal(since) || !filter.Until.IsZero() {
+					t.Errorf("unexpected window %v - %v", filter.Since, filter.Until)
+				}
+				if topic != "rebuild" {
+					t.Errorf("expected topic rebuild, got %q", topic)
+				}
+				return 3, nil
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		resp, err := handler.ReplayCompanyEvents(context.Background(), &pb.ReplayCompanyEventsRequest{
+			CompanyIds:  []string{testID.String()},
+			EventTypes:  []string{"company_updated"},
+			Since:       timestamppb.New(since),
+			TargetTopic: "rebuild",
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.GetReplayed() != 3 {
+			t.Errorf("expected 3 replayed events, got %d", resp.GetReplayed())
+		}
+	})
+}
+
+// Test for GetCompanyHistory.
+func TestCompanyHandler_GetCompanyHistory(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("InvalidCompanyID", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.GetCompanyHistory(context.Background(), &pb.GetCompanyHistoryRequest{Id: "invalid-uuid"})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		testID := uuid.New()
+		since := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+		mockCtrl := &mockCompanyController{
+			historyFunc: func(_ context.Context, company uuid.UUID, gotSince, until time.Time, pageSize int, pageToken string) ([]models.CompanyEvent, string, error) {
+				if company != testID || !gotSince.Equal(since) || !until.IsZero() || pageSize != 10 || pageToken != "page-1" {
+					t.Errorf("unexpected arguments %v %v %v %d %q", company, gotSince, until, pageSize, pageToken)
+				}
+				return []models.CompanyEvent{{
+					ID:        uuid.New(),
+					Type:      "company_updated",
+					Actor:     "alice",
+					Company:   models.Company{ID: testID, Name: "Acme"},
+					Changes:   map[string]models.FieldChange{"name": {Old: "Acm", New: "Acme"}, "employees": {Old: 1, New: 2}},
+					CreatedAt: since,
+				}}, "page-2", nil
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		resp, err := handler.GetCompanyHistory(context.Background(), &pb.GetCompanyHistoryRequest{
+			Id:        testID.String(),
+			Since:     timestamppb.New(since),
+			PageSize:  10,
+			PageToken: "page-1",
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.GetNextPageToken() != "page-2" || len(resp.GetEvents()) != 1 {
+			t.Fatalf("unexpected response %v", resp)
+		}
+		entry := resp.GetEvents()[0]
+		if entry.GetActor() != "alice" || entry.GetCompany().GetName() != "Acme" ||
+			fmt.Sprint(entry.GetChangedFields()) != "[employees name]" {
+			t.Errorf("unexpected entry %v", entry)
+		}
+	})
+}
+
+// watchStream records the responses sent on a WatchCompanies stream.
+type watchStream struct {
+	fakeStream
+	sent []*pb.WatchCompaniesResponse
+}
+
+func (s *watchStream) Send(resp *pb.WatchCompaniesResponse) error {
+	s.sent = append(s.sent, resp)
+	return nil
+}
+
+// Test for WatchCompanies.
+func TestCompanyHandler_WatchCompanies(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("InvalidCompanyID", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		err := handler.WatchCompanies(&pb.WatchCompaniesRequest{CompanyIds: []string{"invalid-uuid"}},
+			&watchStream{fakeStream: fakeStream{ctx: context.Background()}})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("ServiceError", func(t *testing.T) {
+		mockCtrl := &mockCompanyController{
+			watchEventsFunc: func(context.Context, models.CompanyEventFilter, string, func(*models.CompanyEvent, string) error) error {
+				return e.Invalid("resume_token", e.CodeResumeTokenInvalid, "invalid resume token")
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		err := handler.WatchCompanies(&pb.WatchCompaniesRequest{ResumeToken: "bogus"},
+			&watchStream{fakeStream: fakeStream{ctx: context.Background()}})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		testID := uuid.New()
+		published := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+		ctx, cancel := context.WithCancel(context.Background())
+		mockCtrl := &mockCompanyController{
+			watchEventsFunc: func(ctx context.Context, filter models.CompanyEventFilter, resumeToken string, fn func(*models.CompanyEvent, string) error) error {
+				if len(filter.CompanyIDs) != 1 || filter.CompanyIDs[0] != testID || resumeToken != "token-1" {
+					t.Errorf("unexpected filter %+v or resume token %q", filter, resumeToken)
+				}
+				event := &models.CompanyEvent{ID: uuid.New(), Type: "company_updated", Company: models.Company{ID: testID, Name: "Acme"}, CreatedAt: published}
+				if err := fn(event, "token-2"); err != nil {
+					return err
+				}
+				cancel()
+				return ctx.Err()
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		stream := &watchStream{fakeStream: fakeStream{ctx: ctx}}
+		err := handler.WatchCompanies(&pb.WatchCompaniesRequest{CompanyIds: []string{testID.String()}, ResumeToken: "token-1"}, stream)
+		if status.Code(err) != codes.Canceled {
+			t.Errorf("expected code %v once the client is gone, got %v", codes.Canceled, status.Code(err))
+		}
+		if len(stream.sent) != 1 {
+			t.Fatalf("expected 1 event, got %d", len(stream.sent))
+		}
+		sent := stream.sent[0]
+		if sent.GetCompany().GetName() != "Acme" || sent.GetEventType() != "company_updated" ||
+			sent.GetResumeToken() != "token-2" || !sent.GetPublishedAt().AsTime().Equal(published) {
+			t.Errorf("unexpected event %v", sent)
+		}
+	})
+}
+
+// Test for ApplyCompanies.
+func TestCompanyHandler_ApplyCompanies(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("ServiceError", func(t *testing.T) {
+		mockCtrl := &mockCompanyController{
+			applyFunc: func(_ context.Context, _ []models.Company, _ models.ApplyOptions) ([]models.CompanyChange, error) {
+				return nil, e.Newf(e.CodeExternalRefRepeated, "external reference \"ERP-1\" listed more than once")
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		_, err := handler.ApplyCompanies(context.Background(), &pb.ApplyCompaniesRequest{})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		created := &models.Company{ID: uuid.New(), Name: "Globex", ExternalRef: "ERP-2", Type: models.NonProfit}
+		mockCtrl := &mockCompanyController{
+			applyFunc: func(_ context.Context, desired []models.Company, opts models.ApplyOptions) ([]models.CompanyChange, error) {
+				if !opts.PlanOnly || !opts.Prune {
+					t.Errorf("unexpected options %+v", opts)
+				}
+				if len(desired) != 1 || desired[0].ExternalRef != "ERP-2" || desired[0].Type != models.NonProfit {
+					t.Errorf("unexpected desired state %+v", desired)
+				}
+				return []models.CompanyChange{
+					{Action: models.ApplyCreate, Company: created},
+					{Action: models.ApplyUpdate, Company: &models.Company{ExternalRef: "ERP-1"}, Fields: []string{"employees"}},
+				}, nil
+			},
+		}
+		handler := NewCompanyHandler(mockCtrl, logger)
+		resp, err := handler.ApplyCompanies(context.Background(), &pb.ApplyCompaniesRequest{
+			Companies: []*pb.Company{{Name: "Globex", ExternalRef: "ERP-2", Type: pb.CompanyType_NON_PROFIT}},
+			PlanOnly:  true,
+			Prune:     true,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		changes := resp.GetChanges()
+		if len(changes) != 2 {
+			t.Fatalf("expected 2 changes, got %d", len(changes))
+		}
+		if changes[0].GetAction() != pb.ApplyAction_CREATE || changes[0].GetCompany().GetId() != created.ID.String() {
+			t.Errorf("unexpected create %v", changes[0])
+		}
+		if changes[1].GetAction() != pb.ApplyAction_UPDATE || changes[1].GetExternalRef() != "ERP-1" ||
+			len(changes[1].GetChangedFields()) != 1 {
+			t.Errorf("unexpected update %v", changes[1])
+		}
+	})
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+
+	pbv2 "github.com/gartstein/xm/api/gen/definition/v2"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// CompanyHandlerV2 provides the gRPC methods of the v2 CompanyService. It
+// shares the CompanyController and error mapping of CompanyHandler, so both
+// versions behave the same apart from their messages.
+type CompanyHandlerV2 struct {
+	pbv2.UnimplementedCompanyServiceServer
+	v1 *CompanyHandler
+}
+
+// NewCompanyHandlerV2 constructs a new CompanyHandlerV2 with the given service and logger.
+func NewCompanyHandlerV2(service CompanyController, logger *zap.Logger) *CompanyHandlerV2 {
+	return &CompanyHandlerV2{v1: NewCompanyHandler(service, logger)}
+}
+
+// CreateCompany processes a CreateCompanyRequest, creating a new Company in the system.
+func (h *CompanyHandlerV2) CreateCompany(ctx context.Context, req *pbv2.CreateCompanyRequest) (*pbv2.Company, error) {
+	if req.GetCompany() == nil {
+		return nil, status.Error(codes.InvalidArgument, "company data required")
+	}
+
+	company, err := h.protoToModel(req.GetCompany())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	opts := models.CreateOptions{Force: req.GetForce(), ValidateOnly: req.GetValidateOnly(), SuppressEvents: req.GetSuppressEvents()}
+	created, err := h.v1.service.CreateCompany(ctx, company, opts)
+	if err != nil {
+		h.v1.logger.Error("Create company failed", zap.Error(err))
+		return nil, h.v1.mapServiceError(err)
+	}
+	if !opts.ValidateOnly {
+		setCreated(ctx, "/v2/companies/"+created.ID.String())
+	}
+	return h.modelToProto(ctx, created), nil
+}
+
+// GetCompany fetches a Company by ID, returning an error if not found.
+func (h *CompanyHandlerV2) GetCompany(ctx context.Context, req *pbv2.GetCompanyRequest) (*pbv2.Company, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+
+	company, err := h.v1.service.GetCompany(ctx, id)
+	if err != nil {
+		return nil, h.v1.mapServiceError(err)
+	}
+	setCompanyETag(ctx, "v2", company)
+	return h.modelToProto(ctx, company), nil
+}
+
+// UpdateCompany changes the fields of a Company named in the update mask.
+func (h *CompanyHandlerV2) UpdateCompany(ctx context.Context, req *pbv2.UpdateCompanyRequest) (*pbv2.Company, error) {
+	update, err := h.protoToUpdate(req.GetCompany(), req.GetUpdateMask())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	h.v1.visibility.restrictUpdate(ctx, update)
+
+	updated, err := h.v1.service.UpdateCompany(ctx, update, models.UpdateOptions{ValidateOnly: req.GetValidateOnly(), SuppressEvents: req.GetSuppressEvents()})
+	if err != nil {
+		return nil, h.v1.mapServiceError(err)
+	}
+	return h.modelToProto(ctx, updated), nil
+}
+
+// DeleteCompany removes a Company given its ID.
+func (h *CompanyHandlerV2) DeleteCompany(ctx context.Context, req *pbv2.DeleteCompanyRequest) (*emptypb.Empty, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+
+	if _, err := h.v1.service.DeleteCompany(ctx, id); err != nil {
+		return nil, h.v1.mapServiceError(err)
+	}
+	setNoContent(ctx)
+	return &emptypb.Empty{}, nil
+}
+
+// ListCompanies returns a page of companies, optionally filtered by employee
+// range and status.
+func (h *CompanyHandlerV2) ListCompanies(ctx context.Context, req *pbv2.ListCompaniesRequest) (*pbv2.ListCompaniesResponse, error) {
+	var filter models.CompanyFilter
+	for _, r := range req.GetEmployeeRanges() {
+		if r == pbv2.EmployeeRange_EMPLOYEE_RANGE_UNSPECIFIED {
+			return nil, status.Error(codes.InvalidArgument, "invalid employee range")
+		}
+		filter.EmployeeRanges = append(filter.EmployeeRanges, models.EmployeeRange(r.String()))
+	}
+	for _, st := range req.GetStatuses() {
+		if st == pbv2.CompanyStatus_COMPANY_STATUS_UNSPECIFIED {
+			return nil, status.Error(codes.InvalidArgument, "invalid status")
+		}
+		filter.Statuses = append(filter.Statuses, models.CompanyStatus(st.String()))
+	}
+
+	companies, next, err := h.list(ctx, filter, req.GetPageSize(), req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+	return &pbv2.ListCompaniesResponse{Companies: companies, NextPageToken: next}, nil
+}
+
+// ListMyCompanies returns a page of the companies created by the caller.
+func (h *CompanyHandlerV2) ListMyCompanies(ctx context.Context, req *pbv2.ListMyCompaniesRequest) (*pbv2.ListCompaniesResponse, error) {
+	var filter models.CompanyFilter
+	for _, st := range req.GetStatuses() {
+		if st == pbv2.CompanyStatus_COMPANY_STATUS_UNSPECIFIED {
+			return nil, status.Error(codes.InvalidArgument, "invalid status")
+		}
+		filter.Statuses = append(filter.Statuses, models.CompanyStatus(st.String()))
+	}
+
+	companies, next, err := h.v1.service.ListMyCompanies(ctx, filter, int(req.GetPageSize()), req.GetPageToken())
+	if err != nil {
+		return nil, h.v1.mapServiceError(err)
+	}
+
+	resp := &pbv2.ListCompaniesResponse{NextPageToken: next}
+	for i := range companies {
+		resp.Companies = append(resp.Companies, h.modelToProto(ctx, &companies[i]))
+	}
+	return resp, nil
+}
+
+// SearchCompanies returns a page of companies whose name contains the query.
+func (h *CompanyHandlerV2) SearchCompanies(ctx context.Context, req *pbv2.SearchCompaniesRequest) (*pbv2.SearchCompaniesResponse, error) {
+	if req.GetQuery() == "" {
+		return nil, status.Error(codes.InvalidArgument, "query required")
+	}
+
+	filter := models.CompanyFilter{NameContains: req.GetQuery()}
+	companies, next, err := h.list(ctx, filter, req.GetPageSize(), req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+	return &pbv2.SearchCompaniesResponse{Companies: companies, NextPageToken: next}, nil
+}
+
+// SuspendCompany moves an ACTIVE Company to SUSPENDED.
+func (h *CompanyHandlerV2) SuspendCompany(ctx context.Context, req *pbv2.SuspendCompanyRequest) (*pbv2.Company, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+
+	company, err := h.v1.service.SuspendCompany(ctx, id)
+	if err != nil {
+		return nil, h.v1.mapServiceError(err)
+	}
+	return h.modelToProto(ctx, company), nil
+}
+
+// ActivateCompany moves a DRAFT or SUSPENDED Company to ACTIVE.
+func (h *CompanyHandlerV2) ActivateCompany(ctx context.Context, req *pbv2.ActivateCompanyRequest) (*pbv2.Company, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+
+	company, err := h.v1.service.ActivateCompany(ctx, id)
+	if err != nil {
+		return nil, h.v1.mapServiceError(err)
+	}
+	return h.modelToProto(ctx, company), nil
+}
+
+// list fetches a page of companies matching filter as v2 protos.
+func (h *CompanyHandlerV2) list(ctx context.Context, filter models.CompanyFilter, pageSize int32, pageToken string) ([]*pbv2.Company, string, error) {
+	companies, next, err := h.v1.service.ListCompanies(ctx, filter, int(pageSize), pageToken)
+	if err != nil {
+		return nil, "", h.v1.mapServiceError(err)
+	}
+
+	result := make([]*pbv2.Company, 0, len(companies))
+	for i := range companies {
+		result = append(result, h.modelToProto(ctx, &companies[i]))
+	}
+	return result, next, nil
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pbv2 "github.com/gartstein/xm/api/gen/definition/v2"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestCompanyHandlerV2_CreateCompany(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	created := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	mockCtrl := &mockCompanyController{
+		createCompanyFunc: func(_ context.Context, company *models.Company, opts models.CreateOptions) (*models.Company, error) {
+			if company.Type != models.NonProfit || !opts.Force {
+				t.Errorf("unexpected company %+v or options %+v", company, opts)
+			}
+			company.ID = uuid.New()
+			company.CreatedBy = "user-1"
+			company.CreatedAt = created
+			return company, nil
+		},
+	}
+	handler := NewCompanyHandlerV2(mockCtrl, logger)
+
+	resp, err := handler.CreateCompany(context.Background(), &pbv2.CreateCompanyRequest{
+		Company: &pbv2.Company{Name: "Acme", Type: pbv2.CompanyType_NON_PROFIT},
+		Force:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.GetCreatedBy() != "user-1" || !resp.GetCreateTime().AsTime().Equal(created) {
+		t.Errorf("expected the richer model to be returned, got %v", resp)
+	}
+
+	if _, err := handler.CreateCompany(context.Background(), &pbv2.CreateCompanyRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+	}
+}
+
+func TestCompanyHandlerV2_UpdateCompany(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	testID := uuid.New()
+
+	var got *models.CompanyUpdate
+	mockCtrl := &mockCompanyController{
+		updateCompanyFunc: func(_ context.Context, update *models.CompanyUpdate, _ models.UpdateOptions) (*models.Company, error) {
+			got = update
+			return &models.Company{ID: update.ID}, nil
+		},
+	}
+	handler := NewCompanyHandlerV2(mockCtrl, logger)
+	company := &pbv2.Company{Id: testID.String(), Name: "Acme", Description: "ignored"}
+
+	t.Run("Mask", func(t *testing.T) {
+		_, err := handler.UpdateCompany(context.Background(), &pbv2.UpdateCompanyRequest{
+			Company:    company,
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name", "employees", "update_time"}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.ID != testID || got.Name == nil || *got.Name != "Acme" {
+			t.Errorf("expected the name to be updated, got %+v", got)
+		}
+		if got.Employees == nil || *got.Employees != 0 {
+			t.Errorf("expected employees to be cleared, got %v", got.Employees)
+		}
+		if got.Description != nil || got.Registered != nil || got.Type != nil || got.ExternalRef != nil {
+			t.Errorf("expected fields outside the mask to be left alone, got %+v", got)
+		}
+	})
+
+	t.Run("NoMask", func(t *testing.T) {
+		if _, err := handler.UpdateCompany(context.Background(), &pbv2.UpdateCompanyRequest{Company: company}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Name == nil || got.Description == nil || got.Employees != nil || got.Registered != nil {
+			t.Errorf("expected the populated fields to be updated, got %+v", got)
+		}
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		_, err := handler.UpdateCompany(context.Background(), &pbv2.UpdateCompanyRequest{
+			Company:    &pbv2.Company{Id: testID.String(), Status: pbv2.CompanyStatus_SUSPENDED},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"status"}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Status == nil || *got.Status != models.StatusSuspended {
+			t.Errorf("expected the status to be updated, got %v", got.Status)
+		}
+	})
+
+	t.Run("ClearContactEmail", func(t *testing.T) {
+		_, err := handler.UpdateCompany(context.Background(), &pbv2.UpdateCompanyRequest{
+			Company:    &pbv2.Company{Id: testID.String()},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"contact_email"}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.ContactEmail == nil || *got.ContactEmail != "" {
+			t.Errorf("expected the contact email to be cleared, got %v", got.ContactEmail)
+		}
+	})
+
+	t.Run("InvalidPath", func(t *testing.T) {
+		_, err := handler.UpdateCompany(context.Background(), &pbv2.UpdateCompanyRequest{
+			Company:    company,
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"owner"}},
+		})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+
+	t.Run("InvalidID", func(t *testing.T) {
+		_, err := handler.UpdateCompany(context.Background(), &pbv2.UpdateCompanyRequest{Company: &pbv2.Company{Id: "bad"}})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+		}
+	})
+}
+
+// FuzzCompanyHandlerV2_ProtoToUpdate decodes arbitrary wire bytes as an
+// UpdateCompanyRequest, covering malformed IDs, unknown mask paths and enum
+// values the service does not define.
+func FuzzCompanyHandlerV2_ProtoToUpdate(f *testing.F) {
+	for _, req := range []*pbv2.UpdateCompanyRequest{
+		{Company: &pbv2.Company{Id: uuid.NewString(), Name: "Acme"}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name", "create_time"}}},
+		{Company: &pbv2.Company{Id: uuid.NewString(), Type: pbv2.CompanyType(42)}, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"*"}}},
+		{Company: &pbv2.Company{Id: "bad", Employees: 5}},
+		{UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"owner"}}},
+	} {
+		b, err := proto.Marshal(req)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+		f.Add(b[:len(b)-1])
+	}
+
+	h := NewCompanyHandlerV2(&dummyCompanyController{}, zap.NewNop())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var req pbv2.UpdateCompanyRequest
+		if err := proto.Unmarshal(data, &req); err != nil {
+			return
+		}
+		update, err := h.protoToUpdate(req.GetCompany(), req.GetUpdateMask())
+		if err != nil {
+			return
+		}
+		if id, err := uuid.Parse(req.GetCompany().GetId()); err != nil || update.ID != id {
+			t.Errorf("expected ID %q, got %v", req.GetCompany().GetId(), update.ID)
+		}
+		if update.Type != nil && !knownCompanyType(*update.Type) {
+			t.Errorf("unknown company type %q from %v", *update.Type, req.GetCompany().GetType())
+		}
+	})
+}
+
+func TestCompanyHandlerV2_SearchCompanies(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	mockCtrl := &mockCompanyController{
+		listCompaniesFunc: func(_ context.Context, filter models.CompanyFilter, pageSize int, _ string) ([]models.Company, string, error) {
+			if filter.NameContains != "acme" || pageSize != 5 {
+				t.Errorf("unexpected filter %+v or page size %d", filter, pageSize)
+			}
+			return []models.Company{{ID: uuid.New(), Name: "Acme"}}, "next", nil
+		},
+	}
+	handler := NewCompanyHandlerV2(mockCtrl, logger)
+
+	resp, err := handler.SearchCompanies(context.Background(), &pbv2.SearchCompaniesRequest{Query: "acme", PageSize: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.GetCompanies()) != 1 || resp.GetNextPageToken() != "next" {
+		t.Errorf("unexpected response %v", resp)
+	}
+
+	if _, err := handler.SearchCompanies(context.Background(), &pbv2.SearchCompaniesRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+	}
+}
+
+func TestCompanyHandlerV2_GetCompanyNotFound(t *testing.T) {
+	mockCtrl := &mockCompanyController{
+		getCompanyFunc: func(_ context.Context, _ uuid.UUID) (*models.Company, error) {
+			return nil, e.ErrNotFound
+		},
+	}
+	handler := NewCompanyHandlerV2(mockCtrl, zaptest.NewLogger(t))
+
+	_, err := handler.GetCompany(context.Background(), &pbv2.GetCompanyRequest{Id: uuid.NewString()})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected code %v, got %v", codes.NotFound, status.Code(err))
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// httpStatusHeader is the metadata key carrying the HTTP status the
+	// gateway answers a successful call with instead of 200. It is consumed
+	// by the gateway rather than forwarded.
+	httpStatusHeader = "x-http-status"
+	// locationHeader is the metadata key carrying the path of a created
+	// resource, forwarded as the HTTP Location header.
+	locationHeader = "location"
+)
+
+// setCreated makes the gateway answer 201 Created with a Location header
+// pointing to location.
+func setCreated(ctx context.Context, location string) {
+	_ = grpc.SetHeader(ctx, metadata.Pairs(
+		httpStatusHeader, strconv.Itoa(http.StatusCreated),
+		locationHeader, location,
+	))
+}
+
+// setNoContent makes the gateway answer 204 No Content.
+func setNoContent(ctx context.Context) {
+	_ = grpc.SetHeader(ctx, metadata.Pairs(httpStatusHeader, strconv.Itoa(http.StatusNoContent)))
+}
+
+// forwardHTTPStatus is a gateway response mutator writing the HTTP status
+// set by the handler. 204 responses drop their Content-Type; the gateway's
+// body write is then refused by net/http.
+func forwardHTTPStatus(ctx context.Context, w http.ResponseWriter, _ proto.Message) error {
+	md, ok := runtime.ServerMetadataFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	values := md.HeaderMD.Get(httpStatusHeader)
+	if len(values) == 0 {
+		return nil
+	}
+	code, err := strconv.Atoi(values[0])
+	if err != nil {
+		return nil
+	}
+	if code == http.StatusNoContent {
+		w.Header().Del("Content-Type")
+	}
+	w.WriteHeader(code)
+	return nil
+}
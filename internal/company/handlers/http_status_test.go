@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+)
+
+func TestForwardHTTPStatus(t *testing.T) {
+	respond := func(set func(ctx context.Context)) *httptest.ResponseRecorder {
+		stream := &headerStream{}
+		if set != nil {
+			set(grpc.NewContextWithServerTransportStream(context.Background(), stream))
+		}
+		ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{HeaderMD: stream.header})
+		rec := httptest.NewRecorder()
+		rec.Header().Set("Content-Type", "application/json")
+		if err := forwardHTTPStatus(ctx, rec, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return rec
+	}
+
+	if rec := respond(nil); rec.Code != http.StatusOK {
+		t.Errorf("expected the default status, got %d", rec.Code)
+	}
+
+	rec := respond(func(ctx context.Context) { setCreated(ctx, "/v1/companies/42") })
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected %d, got %d", http.StatusCreated, rec.Code)
+	}
+	if rec.Header().Get("Content-Type") == "" {
+		t.Error("expected 201 responses to keep their Content-Type")
+	}
+
+	rec = respond(setNoContent)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if rec.Header().Get("Content-Type") != "" {
+		t.Error("expected 204 responses to drop their Content-Type")
+	}
+}
+
+func TestOutgoingHeaderMatcher(t *testing.T) {
+	if key, ok := outgoingHeaderMatcher(locationHeader); !ok || key != locationHeader {
+		t.Errorf("expected the Location header to be forwarded as is, got %q, %v", key, ok)
+	}
+	if _, ok := outgoingHeaderMatcher(httpStatusHeader); ok {
+		t.Error("expected the HTTP status metadata not to be forwarded")
+	}
+}
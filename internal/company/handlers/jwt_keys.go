@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"context"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SetJWTKeys serves RotateJWTKeys with keys.
+func (h *CompanyHandler) SetJWTKeys(keys JWTKeyReloader) {
+	h.jwtKeys = keys
+}
+
+// RotateJWTKeys reloads the JWT key set and returns the IDs of the keys now
+// in use. On failure the previous keys stay in use.
+func (h *CompanyHandler) RotateJWTKeys(ctx context.Context, _ *pb.RotateJWTKeysRequest) (*pb.RotateJWTKeysResponse, error) {
+	if h.jwtKeys == nil {
+		return nil, status.Error(codes.Unimplemented, "JWT key sets are not enabled")
+	}
+	if err := h.jwtKeys.Reload(ctx); err != nil {
+		h.logger.Error("Reload JWT keys failed", zap.Error(err))
+		return nil, status.Error(codes.FailedPrecondition, "failed to load the JWT key set; the previous keys stay in use")
+	}
+	primary, ids := h.jwtKeys.KeyIDs()
+	h.logger.Info("Reloaded JWT keys", zap.String("primary", primary), zap.Strings("keys", ids))
+	return &pb.RotateJWTKeysResponse{PrimaryKeyId: primary, KeyIds: ids}, nil
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mockJWTKeys is a JWTKeyReloader with fixed keys.
+type mockJWTKeys struct {
+	reloads int
+	err     error
+}
+
+func (m *mockJWTKeys) Reload(context.Context) error {
+	m.reloads++
+	return m.err
+}
+
+func (m *mockJWTKeys) KeyIDs() (string, []string) {
+	return "k2", []string{"k1", "k2"}
+}
+
+func TestCompanyHandler_RotateJWTKeys(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("NotEnabled", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.RotateJWTKeys(context.Background(), &pb.RotateJWTKeysRequest{})
+		if status.Code(err) != codes.Unimplemented {
+			t.Errorf("expected code %v, got %v", codes.Unimplemented, status.Code(err))
+		}
+	})
+
+	t.Run("Reloads", func(t *testing.T) {
+		keys := &mockJWTKeys{}
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		handler.SetJWTKeys(keys)
+		resp, err := handler.RotateJWTKeys(context.Background(), &pb.RotateJWTKeysRequest{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if keys.reloads != 1 {
+			t.Errorf("expected 1 reload, got %d", keys.reloads)
+		}
+		if resp.PrimaryKeyId != "k2" || !slices.Equal(resp.KeyIds, []string{"k1", "k2"}) {
+			t.Errorf("unexpected response %v", resp)
+		}
+	})
+
+	t.Run("ReloadFails", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		handler.SetJWTKeys(&mockJWTKeys{err: errors.New("vault unavailable")})
+		_, err := handler.RotateJWTKeys(context.Background(), &pb.RotateJWTKeysRequest{})
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("expected code %v, got %v", codes.FailedPrecondition, status.Code(err))
+		}
+	})
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"golang.org/x/text/language"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// AcceptLanguageHeader is the metadata key selecting the language of error
+// messages, forwarded from the HTTP header of the same name.
+const AcceptLanguageHeader = "accept-language"
+
+// errorDomain is the ErrorInfo domain of errors raised by this service.
+const errorDomain = "company.xm"
+
+// Error reasons attached to service errors as ErrorInfo, see the errors
+// package. They key the message catalog together with the canonical code
+// names (e.g. "UNAUTHENTICATED") used for errors without a reason.
+const (
+	reasonNotFound                = e.ReasonNotFound
+	reasonDuplicateName           = e.ReasonDuplicateName
+	reasonSimilarName             = e.ReasonSimilarName
+	reasonDuplicateExternalRef    = e.ReasonDuplicateExternalRef
+	reasonInvalidInput            = e.ReasonInvalidInput
+	reasonInvalidStatusTransition = e.ReasonInvalidStatusTransition
+	reasonNotOwner                = e.ReasonNotOwner
+	reasonReadOnly                = e.ReasonReadOnly
+	reasonQuotaExceeded           = e.ReasonQuotaExceeded
+	reasonOverloaded              = e.ReasonOverloaded
+	reasonTimeout                 = e.ReasonTimeout
+	reasonValidationFailed        = e.ReasonValidationFailed
+	reasonAdminRequired           = e.ReasonAdminRequired
+	reasonInternal                = e.ReasonInternal
+)
+
+// errorMessages holds the translated error messages per language and key.
+// English is canonical: such errors are returned unchanged.
+var errorMessages = map[language.Tag]map[string]string{
+	language.German: {
+		reasonNotFound:                "Die angeforderte Ressource wurde nicht gefunden.",
+		reasonDuplicateName:           "Ein Unternehmen mit diesem Namen existiert bereits.",
+		reasonSimilarName:             "Ein Unternehmen mit einem ähnlichen Namen existiert bereits.",
+		reasonDuplicateExternalRef:    "Ein Unternehmen mit dieser externen Referenz existiert bereits.",
+		reasonInvalidInput:            "Ungültige Eingabe.",
+		reasonInvalidStatusTransition: "Der Status des Unternehmens kann nicht so geändert werden.",
+		reasonNotOwner:                "Nur der Ersteller des Unternehmens darf es ändern.",
+		reasonReadOnly:                "Änderungen sind vorübergehend nicht möglich. Bitte versuchen Sie es später erneut.",
+		reasonQuotaExceeded:           "Das Kontingent Ihres Mandanten ist ausgeschöpft.",
+		reasonOverloaded:              "Der Dienst ist überlastet. Bitte versuchen Sie es später erneut.",
+		reasonTimeout:                 "Die Anfrage hat zu lange gedauert.",
+		reasonValidationFailed:        "Die Änderung hat eine externe Prüfung nicht bestanden.",
+		reasonAdminRequired:           "Dafür ist die Administratorrolle erforderlich.",
+		reasonInternal:                "Interner Serverfehler.",
+		"INVALID_ARGUMENT":            "Ungültige Eingabe.",
+		"ALREADY_EXISTS":              "Die Ressource existiert bereits.",
+		"UNAUTHENTICATED":             "Authentifizierung erforderlich.",
+		"PERMISSION_DENIED":           "Zugriff verweigert.",
+		"RESOURCE_EXHAUSTED":          "Zu viele Anfragen. Bitte versuchen Sie es später erneut.",
+		"FAILED_PRECONDITION":         "Die Anfrage kann im aktuellen Zustand nicht ausgeführt werden.",
+		"UNAVAILABLE":                 "Der Dienst ist vorübergehend nicht verfügbar.",
+		"DEADLINE_EXCEEDED":           "Zeitüberschreitung der Anfrage.",
+	},
+	language.French: {
+		reasonNotFound:                "La ressource demandée est introuvable.",
+		reasonDuplicateName:           "Une entreprise portant ce nom existe déjà.",
+		reasonSimilarName:             "Une entreprise portant un nom similaire existe déjà.",
+		reasonDuplicateExternalRef:    "Une entreprise avec cette référence externe existe déjà.",
+		reasonInvalidInput:            "Saisie invalide.",
+		reasonInvalidStatusTransition: "Le statut de l'entreprise ne peut pas être modifié ainsi.",
+		reasonNotOwner:                "Seul le créateur de l'entreprise peut la modifier.",
+		reasonReadOnly:                "Les modifications sont temporairement impossibles. Veuillez réessayer plus tard.",
+		reasonQuotaExceeded:           "Le quota de votre locataire est épuisé.",
+		reasonOverloaded:              "Le service est surchargé. Veuillez réessayer plus tard.",
+		reasonTimeout:                 "La requête a pris trop de temps.",
+		reasonValidationFailed:        "La modification n'a pas passé une vérification externe.",
+		reasonAdminRequired:           "Le rôle d'administrateur est requis.",
+		reasonInternal:                "Erreur interne du serveur.",
+		"INVALID_ARGUMENT":            "Saisie invalide.",
+		"ALREADY_EXISTS":              "La ressource existe déjà.",
+		"UNAUTHENTICATED":             "Authentification requise.",
+		"PERMISSION_DENIED":           "Accès refusé.",
+		"RESOURCE_EXHAUSTED":          "Trop de requêtes. Veuillez réessayer plus tard.",
+		"FAILED_PRECONDITION":         "La requête ne peut pas être exécutée dans l'état actuel.",
+		"UNAVAILABLE":                 "Le service est temporairement indisponible.",
+		"DEADLINE_EXCEEDED":           "Le délai de la requête a expiré.",
+	},
+	language.Spanish: {
+		reasonNotFound:                "No se encontró el recurso solicitado.",
+		reasonDuplicateName:           "Ya existe una empresa con este nombre.",
+		reasonSimilarName:             "Ya existe una empresa con un nombre similar.",
+		reasonDuplicateExternalRef:    "Ya existe una empresa con esta referencia externa.",
+		reasonInvalidInput:            "Entrada no válida.",
+		reasonInvalidStatusTransition: "El estado de la empresa no se puede cambiar de esta forma.",
+		reasonNotOwner:                "Solo el creador de la empresa puede modificarla.",
+		reasonReadOnly:                "Los cambios no están disponibles temporalmente. Inténtelo de nuevo más tarde.",
+		reasonQuotaExceeded:           "Se agotó la cuota de su inquilino.",
+		reasonOverloaded:              "El servicio está sobrecargado. Inténtelo de nuevo más tarde.",
+		reasonTimeout:                 "La solicitud tardó demasiado.",
+		reasonValidationFailed:        "El cambio no superó una validación externa.",
+		reasonAdminRequired:           "Se requiere el rol de administrador.",
+		reasonInternal:                "Error interno del servidor.",
+		"INVALID_ARGUMENT":            "Entrada no válida.",
+		"ALREADY_EXISTS":              "El recurso ya existe.",
+		"UNAUTHENTICATED":             "Se requiere autenticación.",
+		"PERMISSION_DENIED":           "Acceso denegado.",
+		"RESOURCE_EXHAUSTED":          "Demasiadas solicitudes. Inténtelo de nuevo más tarde.",
+		"FAILED_PRECONDITION":         "La solicitud no se puede ejecutar en el estado actual.",
+		"UNAVAILABLE":                 "El servicio no está disponible temporalmente.",
+		"DEADLINE_EXCEEDED":           "Se agotó el tiempo de espera de la solicitud.",
+	},
+}
+
+// Localizer translates the messages of errors returned to clients into the
+// language they ask for.
+type Localizer struct {
+	languages []language.Tag
+	matcher   language.Matcher
+}
+
+// NewLocalizer returns a Localizer for the built-in message catalog.
+func NewLocalizer() *Localizer {
+	languages := []language.Tag{language.English, language.German, language.French, language.Spanish}
+	return &Localizer{
+		languages: languages,
+		matcher:   language.NewMatcher(languages),
+	}
+}
+
+// Unary returns the interceptor. It should be first in the chain, ahead of
+// the LoggingInterceptor, so logs keep the canonical English messages.
+func (l *Localizer) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		_ *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			err = localizeError(l.language(ctx), err)
+		}
+		return resp, err
+	}
+}
+
+// language picks the best supported language for the Accept-Language
+// metadata of ctx, defaulting to English.
+func (l *Localizer) language(ctx context.Context) language.Tag {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return language.English
+	}
+	var preferred []language.Tag
+	for _, header := range md.Get(AcceptLanguageHeader) {
+		tags, _, err := language.ParseAcceptLanguage(header)
+		if err != nil {
+			continue
+		}
+		preferred = append(preferred, tags...)
+	}
+	_, index, confidence := l.matcher.Match(preferred...)
+	if confidence == language.No {
+		return language.English
+	}
+	return l.languages[index]
+}
+
+// localizeError replaces the message of a status error with its translation,
+// which is also attached as a LocalizedMessage detail. Errors without a
+// translation are returned unchanged.
+func localizeError(lang language.Tag, err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	msg, ok := errorMessages[lang][errorKey(st)]
+	if !ok {
+		return err
+	}
+	detail, anyErr := anypb.New(&errdetails.LocalizedMessage{Locale: lang.String(), Message: msg})
+	if anyErr != nil {
+		return err
+	}
+	localized := st.Proto()
+	localized.Message = msg
+	localized.Details = append(localized.Details, detail)
+	return status.ErrorProto(localized)
+}
+
+// errorKey returns the ErrorInfo reason of st, or the canonical name of its
+// code when it has none.
+func errorKey(st *status.Status) string {
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.GetDomain() == errorDomain {
+			return info.GetReason()
+		}
+	}
+	return code.Code(st.Code()).String()
+}
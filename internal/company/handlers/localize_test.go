@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/text/language"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestLocalizer(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	h := &CompanyHandler{logger: zap.NewNop()}
+	localizer := NewLocalizer().Unary()
+	logging := NewLoggingInterceptor(zap.New(core)).Unary()
+	info := &grpc.UnaryServerInfo{FullMethod: "/definition.v1.CompanyService/GetCompany"}
+
+	call := func(acceptLanguage string, err error) error {
+		ctx := context.Background()
+		if acceptLanguage != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(AcceptLanguageHeader, acceptLanguage))
+		}
+		// The localizer wraps the logging interceptor, as in the server chain.
+		_, got := localizer(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return logging(ctx, req, info, func(context.Context, interface{}) (interface{}, error) {
+				return nil, err
+			})
+		})
+		return got
+	}
+
+	t.Run("Translated", func(t *testing.T) {
+		err := call("fr-CH, de;q=0.8", h.mapServiceError(e.ErrNotFound))
+		st := status.Convert(err)
+		if st.Code() != codes.NotFound {
+			t.Errorf("expected code %v, got %v", codes.NotFound, st.Code())
+		}
+		if want := errorMessages[language.French][reasonNotFound]; st.Message() != want {
+			t.Errorf("expected message %q, got %q", want, st.Message())
+		}
+		var localized *errdetails.LocalizedMessage
+		for _, d := range st.Details() {
+			if m, ok := d.(*errdetails.LocalizedMessage); ok {
+				localized = m
+			}
+		}
+		if localized == nil || localized.GetLocale() != "fr" {
+			t.Errorf("expected a LocalizedMessage detail for fr, got %v", st.Details())
+		}
+
+		entries := logs.TakeAll()
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 log entry, got %d", len(entries))
+		}
+		if got := entries[0].ContextMap()["error"]; got != "rpc error: code = NotFound desc = not found" {
+			t.Errorf("expected the English message to be logged, got %v", got)
+		}
+	})
+
+	t.Run("FallsBackToCode", func(t *testing.T) {
+		err := call("de", status.Error(codes.Unauthenticated, "missing token"))
+		if want := errorMessages[language.German]["UNAUTHENTICATED"]; status.Convert(err).Message() != want {
+			t.Errorf("expected message %q, got %q", want, status.Convert(err).Message())
+		}
+		logs.TakeAll()
+	})
+
+	t.Run("English", func(t *testing.T) {
+		for _, acceptLanguage := range []string{"", "en-GB", "ja", "not a language tag!"} {
+			err := call(acceptLanguage, h.mapServiceError(e.ErrDuplicateName))
+			if msg := status.Convert(err).Message(); msg != e.ErrDuplicateName.Error() {
+				t.Errorf("Accept-Language %q: expected the canonical message, got %q", acceptLanguage, msg)
+			}
+		}
+		logs.TakeAll()
+	})
+}
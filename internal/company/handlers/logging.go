@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// RequestIDHeader is the metadata key carrying the request ID. Incoming
+// values are kept; otherwise one is generated. It is echoed in the response
+// headers.
+const RequestIDHeader = "x-request-id"
+
+const redacted = "[REDACTED]"
+
+// defaultRedactedFields are payload fields never written to logs.
+var defaultRedactedFields = []string{"password", "token", "secret", "apiKey", "authorization", "contactEmail"}
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID assigned by the logging
+// interceptor.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// LoggingInterceptor writes one structured log entry per call.
+type LoggingInterceptor struct {
+	logger     *zap.Logger
+	sampleRate float64
+	redact     redactor
+	sample     func() float64
+}
+
+// LoggingOption configures a LoggingInterceptor.
+type LoggingOption func(*LoggingInterceptor)
+
+// WithPayloadSampling logs request and response payloads for the given
+// fraction of calls, between 0 (never, the default) and 1 (always).
+func WithPayloadSampling(rate float64) LoggingOption {
+	return func(l *LoggingInterceptor) {
+		l.sampleRate = rate
+	}
+}
+
+// WithRedactedFields adds payload field names whose values are replaced
+// before logging. Names match the JSON (lowerCamelCase) field names.
+func WithRedactedFields(fields ...string) LoggingOption {
+	return func(l *LoggingInterceptor) {
+		l.redact.add(fields...)
+	}
+}
+
+// NewLoggingInterceptor returns a LoggingInterceptor writing to logger.
+func NewLoggingInterceptor(logger *zap.Logger, opts ...LoggingOption) *LoggingInterceptor {
+	l := &LoggingInterceptor{
+		logger: logger.Named("grpc"),
+		redact: make(redactor),
+		sample: rand.Float64,
+	}
+	WithRedactedFields(defaultRedactedFields...)(l)
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Unary returns the interceptor. It should precede the other interceptors,
+// except the Localizer, so calls rejected by them, such as by auth, are
+// logged too.
+func (l *LoggingInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		start := time.Now()
+		requestID := requestIDFromMetadata(ctx)
+		ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, requestID))
+		ctx, identity := auth.RecordIdentity(ctx)
+
+		resp, err := handler(ctx, req)
+
+		fields := callFields(info.FullMethod, start, requestID, identity, err)
+		if l.sampleRate > 0 && l.sample() < l.sampleRate {
+			fields = append(fields, l.payload("request", req), l.payload("response", resp))
+		}
+
+		l.logger.Log(levelFor(status.Code(err)), "gRPC call", fields...)
+		return resp, err
+	}
+}
+
+// Stream returns the interceptor for streaming calls, logged once they end.
+// Payloads are never logged for streams.
+func (l *LoggingInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		start := time.Now()
+		ctx := ss.Context()
+		requestID := requestIDFromMetadata(ctx)
+		ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+		_ = ss.SetHeader(metadata.Pairs(RequestIDHeader, requestID))
+		ctx, identity := auth.RecordIdentity(ctx)
+
+		err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+
+		fields := callFields(info.FullMethod, start, requestID, identity, err)
+		l.logger.Log(levelFor(status.Code(err)), "gRPC stream", fields...)
+		return err
+	}
+}
+
+// callFields returns the log fields common to unary and streaming calls.
+func callFields(method string, start time.Time, requestID string, identity func() (auth.Identity, bool), err error) []zap.Field {
+	fields := []zap.Field{
+		zap.String("method", method),
+		zap.Duration("duration", time.Since(start)),
+		zap.String("code", status.Code(err).String()),
+		zap.String("request_id", requestID),
+	}
+	if id, ok := identity(); ok {
+		fields = append(fields, zap.String("user_id", id.UserID))
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	return fields
+}
+
+// contextStream is a ServerStream whose handlers see ctx.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// payload renders msg as JSON with sensitive fields redacted.
+func (l *LoggingInterceptor) payload(key string, msg interface{}) zap.Field {
+	m, ok := msg.(proto.Message)
+	if !ok || m == nil {
+		return zap.Skip()
+	}
+	v, err := l.redact.value(m)
+	if err != nil {
+		return zap.String(key, "unmarshalable: "+err.Error())
+	}
+	return zap.Any(key, v)
+}
+
+// redactor holds the lowercased names of payload fields whose values are
+// replaced before payloads leave the service.
+type redactor map[string]bool
+
+func (r redactor) add(fields ...string) {
+	for _, f := range fields {
+		r[strings.ToLower(f)] = true
+	}
+}
+
+// value decodes msg as JSON with the fields of r redacted at any depth.
+func (r redactor) value(msg proto.Message) (interface{}, error) {
+	b, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return r.redactValue(v), nil
+}
+
+func (r redactor) redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if r[strings.ToLower(k)] {
+				t[k] = redacted
+				continue
+			}
+			t[k] = r.redactValue(val)
+		}
+	case []interface{}:
+		for i, val := range t {
+			t[i] = r.redactValue(val)
+		}
+	}
+	return v
+}
+
+// levelFor logs server-side failures as errors and everything else as info.
+func levelFor(code codes.Code) zapcore.Level {
+	switch code {
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unavailable, codes.DeadlineExceeded, codes.Unimplemented:
+		return zapcore.ErrorLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
+
+func requestIDFromMetadata(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(RequestIDHeader); len(ids) > 0 && ids[0] != "" {
+			return ids[0]
+		}
+	}
+	return uuid.NewString()
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// gatewayMarshaler renders gateway JSON with lowerCamelCase field names,
+// zero values included, RFC3339 timestamps and enum values without the
+// prefix repeating their type name ("UNSPECIFIED", not
+// "EMPLOYEE_RANGE_UNSPECIFIED"). Requests may use either enum spelling.
+type gatewayMarshaler struct {
+	*runtime.JSONPb
+}
+
+func newGatewayMarshaler() *gatewayMarshaler {
+	return &gatewayMarshaler{JSONPb: &runtime.JSONPb{
+		MarshalOptions: protojson.MarshalOptions{
+			EmitUnpopulated: true,
+		},
+		UnmarshalOptions: protojson.UnmarshalOptions{
+			DiscardUnknown: true,
+		},
+	}}
+}
+
+// Marshal implements runtime.Marshaler.
+func (m *gatewayMarshaler) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return m.JSONPb.Marshal(v)
+	}
+	b, err := m.JSONPb.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return renameEnums(b, msg.ProtoReflect().Descriptor(), shortEnumName)
+}
+
+// ContentType implements runtime.Marshaler, taking the content type of
+// HttpBody responses, such as streamed reports, from the message.
+func (m *gatewayMarshaler) ContentType(v interface{}) string {
+	if body, ok := v.(*httpbody.HttpBody); ok {
+		return body.GetContentType()
+	}
+	return m.JSONPb.ContentType(v)
+}
+
+// Unmarshal implements runtime.Marshaler.
+func (m *gatewayMarshaler) Unmarshal(data []byte, v interface{}) error {
+	if msg, ok := v.(proto.Message); ok {
+		var err error
+		if data, err = renameEnums(data, msg.ProtoReflect().Descriptor(), fullEnumName); err != nil {
+			return err
+		}
+	}
+	return m.JSONPb.Unmarshal(data, v)
+}
+
+// NewDecoder implements runtime.Marshaler.
+func (m *gatewayMarshaler) NewDecoder(r io.Reader) runtime.Decoder {
+	dec := json.NewDecoder(r)
+	return runtime.DecoderFunc(func(v interface{}) error {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		return m.Unmarshal(raw, v)
+	})
+}
+
+// NewEncoder implements runtime.Marshaler.
+func (m *gatewayMarshaler) NewEncoder(w io.Writer) runtime.Encoder {
+	return runtime.EncoderFunc(func(v interface{}) error {
+		b, err := m.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	})
+}
+
+// renameEnums rewrites the enum values of the protojson document data, a
+// message of type md, with rename.
+func renameEnums(data []byte, md protoreflect.MessageDescriptor, rename func(protoreflect.EnumDescriptor, string) string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(renameMessage(doc, md, rename)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func renameMessage(v interface{}, md protoreflect.MessageDescriptor, rename func(protoreflect.EnumDescriptor, string) string) interface{} {
+	obj, ok := v.(map[string]interface{})
+	// Well-known types have their own JSON mapping.
+	if !ok || md.ParentFile().Package() == "google.protobuf" {
+		return v
+	}
+	for key, val := range obj {
+		fd := md.Fields().ByJSONName(key)
+		if fd == nil {
+			fd = md.Fields().ByName(protoreflect.Name(key))
+		}
+		if fd == nil {
+			continue
+		}
+		switch {
+		case fd.IsMap():
+			if entries, ok := val.(map[string]interface{}); ok {
+				for k, entry := range entries {
+					entries[k] = renameValue(entry, fd.MapValue(), rename)
+				}
+			}
+		case fd.IsList():
+			if items, ok := val.([]interface{}); ok {
+				for i, item := range items {
+					items[i] = renameValue(item, fd, rename)
+				}
+			}
+		default:
+			obj[key] = renameValue(val, fd, rename)
+		}
+	}
+	return obj
+}
+
+func renameValue(v interface{}, fd protoreflect.FieldDescriptor, rename func(protoreflect.EnumDescriptor, string) string) interface{} {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		if s, ok := v.(string); ok {
+			return rename(fd.Enum(), s)
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return renameMessage(v, fd.Message(), rename)
+	}
+	return v
+}
+
+// shortEnumName strips the prefix repeating the enum type name from name,
+// unless that would clash with another value.
+func shortEnumName(ed protoreflect.EnumDescriptor, name string) string {
+	short, ok := strings.CutPrefix(name, enumPrefix(ed))
+	if !ok || short == "" || ed.Values().ByName(protoreflect.Name(short)) != nil {
+		return name
+	}
+	return short
+}
+
+// fullEnumName restores a name shortened by shortEnumName.
+func fullEnumName(ed protoreflect.EnumDescriptor, name string) string {
+	if ed.Values().ByName(protoreflect.Name(name)) != nil {
+		return name
+	}
+	if full := enumPrefix(ed) + name; ed.Values().ByName(protoreflect.Name(full)) != nil {
+		return full
+	}
+	return name
+}
+
+// enumPrefix returns the conventional value prefix of an enum type, e.g.
+// "EMPLOYEE_RANGE_" for EmployeeRange.
+func enumPrefix(ed protoreflect.EnumDescriptor) string {
+	var b strings.Builder
+	prev := rune(0)
+	for _, r := range string(ed.Name()) {
+		if unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+		prev = r
+	}
+	b.WriteByte('_')
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestGatewayMarshaler_Marshal(t *testing.T) {
+	m := newGatewayMarshaler()
+	created := time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC)
+	b, err := m.Marshal(&pb.Company{
+		Name:      "Acme",
+		Type:      pb.CompanyType_CORPORATIONS,
+		CreatedAt: timestamppb.New(created),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", b, err)
+	}
+	for key, want := range map[string]interface{}{
+		"name":          "Acme",
+		"type":          "CORPORATIONS",
+		"employeeRange": "UNSPECIFIED",
+		"employees":     float64(0),
+		"registered":    false,
+		"externalRef":   "",
+		"createdAt":     "2025-03-01T12:30:00Z",
+	} {
+		if got[key] != want {
+			t.Errorf("expected %s=%v, got %v in %s", key, want, got[key], b)
+		}
+	}
+}
+
+func TestGatewayMarshaler_Unmarshal(t *testing.T) {
+	m := newGatewayMarshaler()
+	for _, body := range []string{
+		`{"employeeRanges": ["EMPLOYEES_1_10", "UNSPECIFIED"]}`,
+		`{"employee_ranges": ["EMPLOYEES_1_10", "EMPLOYEE_RANGE_UNSPECIFIED"]}`,
+	} {
+		var req pb.ListCompaniesRequest
+		if err := m.NewDecoder(strings.NewReader(body)).Decode(&req); err != nil {
+			t.Fatalf("%s: unexpected error: %v", body, err)
+		}
+		want := []pb.EmployeeRange{pb.EmployeeRange_EMPLOYEES_1_10, pb.EmployeeRange_EMPLOYEE_RANGE_UNSPECIFIED}
+		if got := req.GetEmployeeRanges(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("%s: expected %v, got %v", body, want, got)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SetNotes serves the company note methods with notes.
+func (h *CompanyHandler) SetNotes(notes NoteManager) {
+	h.notes = notes
+}
+
+// errNotesDisabled answers the note methods while no NoteManager is set.
+var errNotesDisabled = status.Error(codes.Unimplemented, "company notes are not enabled")
+
+// AddCompanyNote records a note by the caller on a company.
+func (h *CompanyHandler) AddCompanyNote(ctx context.Context, req *pb.AddCompanyNoteRequest) (*pb.AddCompanyNoteResponse, error) {
+	if h.notes == nil {
+		return nil, errNotesDisabled
+	}
+	company, err := uuid.Parse(req.GetCompanyId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+	if req.GetNote() == nil {
+		return nil, status.Error(codes.InvalidArgument, "note required")
+	}
+
+	note, err := h.notes.AddCompanyNote(ctx, &models.CompanyNote{CompanyID: company, Body: req.GetNote().GetBody()})
+	if err != nil {
+		h.logger.Error("Add company note failed", zap.Error(err), zap.String("company_id", company.String()))
+		return nil, h.mapServiceError(err)
+	}
+	setCreated(ctx, "/v1/companies/"+company.String()+"/notes/"+note.ID.String())
+	return &pb.AddCompanyNoteResponse{Note: noteToProto(note)}, nil
+}
+
+// ListCompanyNotes returns a page of the notes on a company, newest first.
+func (h *CompanyHandler) ListCompanyNotes(ctx context.Context, req *pb.ListCompanyNotesRequest) (*pb.ListCompanyNotesResponse, error) {
+	if h.notes == nil {
+		return nil, errNotesDisabled
+	}
+	company, err := uuid.Parse(req.GetCompanyId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+
+	notes, next, err := h.notes.ListCompanyNotes(ctx, company, int(req.GetPageSize()), req.GetPageToken())
+	if err != nil {
+		return nil, h.mapServiceError(err)
+	}
+	resp := &pb.ListCompanyNotesResponse{NextPageToken: next}
+	for i := range notes {
+		resp.Notes = append(resp.Notes, noteToProto(&notes[i]))
+	}
+	return resp, nil
+}
+
+// DeleteCompanyNote removes a note from a company.
+func (h *CompanyHandler) DeleteCompanyNote(ctx context.Context, req *pb.DeleteCompanyNoteRequest) (*pb.DeleteCompanyNoteResponse, error) {
+	if h.notes == nil {
+		return nil, errNotesDisabled
+	}
+	company, err := uuid.Parse(req.GetCompanyId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid company ID")
+	}
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid note ID")
+	}
+
+	if err := h.notes.DeleteCompanyNote(ctx, company, id); err != nil {
+		return nil, h.mapServiceError(err)
+	}
+	setNoContent(ctx)
+	return &pb.DeleteCompanyNoteResponse{}, nil
+}
+
+// noteToProto converts a note for a response.
+func noteToProto(note *models.CompanyNote) *pb.CompanyNote {
+	return &pb.CompanyNote{
+		Id:        note.ID.String(),
+		CompanyId: note.CompanyID.String(),
+		Author:    note.Author,
+		Body:      note.Body,
+		CreatedAt: timestamppb.New(note.CreatedAt),
+		UpdatedAt: timestamppb.New(note.UpdatedAt),
+	}
+}
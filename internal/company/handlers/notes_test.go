@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mockNotes is a NoteManager keeping notes in memory, all written by bob.
+type mockNotes struct {
+	notes []models.CompanyNote
+}
+
+func (m *mockNotes) AddCompanyNote(_ context.Context, note *models.CompanyNote) (*models.CompanyNote, error) {
+	if note.Body == "" {
+		return nil, e.Invalid("body", e.CodeNoteBodyRequired, "body required")
+	}
+	note.ID = uuid.New()
+	note.Author = "bob"
+	m.notes = append(m.notes, *note)
+	return note, nil
+}
+
+func (m *mockNotes) ListCompanyNotes(_ context.Context, company uuid.UUID, _ int, _ string) ([]models.CompanyNote, string, error) {
+	var notes []models.CompanyNote
+	for _, note := range m.notes {
+		if note.CompanyID == company {
+			notes = append(notes, note)
+		}
+	}
+	return notes, "", nil
+}
+
+func (m *mockNotes) DeleteCompanyNote(_ context.Context, company, id uuid.UUID) error {
+	for i, note := range m.notes {
+		if note.CompanyID == company && note.ID == id {
+			m.notes = append(m.notes[:i], m.notes[i+1:]...)
+			return nil
+		}
+	}
+	return e.ErrNoteNotFound
+}
+
+func TestCompanyHandler_CompanyNotes(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	ctx := context.Background()
+	company := uuid.NewString()
+
+	t.Run("NotEnabled", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.ListCompanyNotes(ctx, &pb.ListCompanyNotesRequest{CompanyId: company})
+		if status.Code(err) != codes.Unimplemented {
+			t.Errorf("expected code %v, got %v", codes.Unimplemented, status.Code(err))
+		}
+	})
+
+	handler := NewCompanyHandler(&mockCompanyController{}, logger)
+	handler.SetNotes(&mockNotes{})
+
+	if _, err := handler.AddCompanyNote(ctx, &pb.AddCompanyNoteRequest{CompanyId: company}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected code %v without a note, got %v", codes.InvalidArgument, status.Code(err))
+	}
+	if _, err := handler.AddCompanyNote(ctx, &pb.AddCompanyNoteRequest{CompanyId: company, Note: &pb.CompanyNote{}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected code %v without a body, got %v", codes.InvalidArgument, status.Code(err))
+	}
+	added, err := handler.AddCompanyNote(ctx, &pb.AddCompanyNoteRequest{
+		CompanyId: company,
+		Note:      &pb.CompanyNote{Author: "mallory", Body: "Renewal due in **May**"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if added.GetNote().GetAuthor() != "bob" || added.GetNote().GetCompanyId() != company {
+		t.Errorf("unexpected note %v", added.GetNote())
+	}
+
+	listed, err := handler.ListCompanyNotes(ctx, &pb.ListCompanyNotesRequest{CompanyId: company})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(listed.GetNotes()) != 1 || listed.GetNotes()[0].GetBody() != "Renewal due in **May**" {
+		t.Errorf("unexpected notes %v", listed.GetNotes())
+	}
+
+	if _, err := handler.DeleteCompanyNote(ctx, &pb.DeleteCompanyNoteRequest{CompanyId: company, Id: "7"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected code %v for an invalid note ID, got %v", codes.InvalidArgument, status.Code(err))
+	}
+	if _, err := handler.DeleteCompanyNote(ctx, &pb.DeleteCompanyNoteRequest{CompanyId: company, Id: added.GetNote().GetId()}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := handler.DeleteCompanyNote(ctx, &pb.DeleteCompanyNoteRequest{CompanyId: company, Id: added.GetNote().GetId()}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected code %v, got %v", codes.NotFound, status.Code(err))
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gartstein/xm/internal/company/controller"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// quotaWarningHeader is the response header naming a tenant quota the call
+// brought close to its limit, forwarded unprefixed by the gateway.
+const quotaWarningHeader = "quota-warning"
+
+// QuotaWarnings returns middleware warning callers whose tenant is past 80%
+// of one of its quotas with a quota-warning response header per quota, such
+// as "companies; used=85; limit=100", so that they can act before calls
+// start failing.
+func QuotaWarnings() Middleware {
+	return Middleware{
+		Name:  "quota-warnings",
+		Order: OrderQuotaWarnings,
+		Unary: func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			ctx, warnings := controller.RecordQuotaWarnings(ctx)
+			resp, err := handler(ctx, req)
+			if recorded := warnings(); len(recorded) > 0 {
+				md := metadata.MD{}
+				for _, w := range recorded {
+					md.Append(quotaWarningHeader, fmt.Sprintf("%s; used=%d; limit=%d", w.Quota, w.Used, w.Limit))
+				}
+				_ = grpc.SetHeader(ctx, md)
+			}
+			return resp, err
+		},
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/gartstein/xm/internal/company/controller"
+	"github.com/gartstein/xm/internal/company/db"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"gorm.io/driver/sqlite"
+)
+
+// TestQuotaWarnings verifies calls bringing a tenant past 80% of a quota
+// get a quota-warning header, and calls well within it none.
+func TestQuotaWarnings(t *testing.T) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	require.NoError(t, err)
+	logger := zaptest.NewLogger(t)
+	svc := controller.NewCompanyService(repo, events.NewBus(logger), logger,
+		controller.WithQuotas(repo, models.TenantQuota{MaxCompanies: 5, MaxMutationsPerMinute: 100}))
+	ctx := auth.NewContext(context.Background(), auth.Identity{UserID: "alice", TenantID: "acme"})
+	create := func(name string) []string {
+		stream := &headerStream{}
+		ctx := grpc.NewContextWithServerTransportStream(ctx, stream)
+		_, err := QuotaWarnings().Unary(ctx, name, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return svc.CreateCompany(ctx, &models.Company{Name: req.(string), Type: models.Corporations}, models.CreateOptions{})
+		})
+		require.NoError(t, err)
+		return stream.header.Get(quotaWarningHeader)
+	}
+
+	for _, name := range []string{"Acme 1", "Acme 2", "Acme 3", "Acme 4"} {
+		assert.Empty(t, create(name), name)
+	}
+	assert.Equal(t, []string{"companies; used=5; limit=5"}, create("Acme 5"))
+
+	key, ok := outgoingHeaderMatcher(quotaWarningHeader)
+	assert.True(t, ok)
+	assert.Equal(t, quotaWarningHeader, key, "the gateway should forward the warning as is")
+}
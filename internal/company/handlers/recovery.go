@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Recovery returns middleware turning panics of handlers, unary or
+// streaming, into Internal errors, or 500 responses for the HTTP gateway,
+// logged with their stack, instead of crashing the service.
+func Recovery(logger *zap.Logger) Middleware {
+	logger = logger.Named("recovery")
+	return Middleware{
+		Name:  "recovery",
+		Order: OrderRecovery,
+		Unary: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					logger.Error("Handler panicked",
+						zap.String("method", info.FullMethod),
+						zap.Any("panic", p),
+						zap.ByteString("stack", debug.Stack()))
+					resp, err = nil, status.Error(codes.Internal, "internal error")
+				}
+			}()
+			return handler(ctx, req)
+		},
+		Stream: func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					logger.Error("Handler panicked",
+						zap.String("method", info.FullMethod),
+						zap.Any("panic", p),
+						zap.ByteString("stack", debug.Stack()))
+					err = status.Error(codes.Internal, "internal error")
+				}
+			}()
+			return handler(srv, ss)
+		},
+		HTTP: func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer func() {
+					if p := recover(); p != nil {
+						if p == http.ErrAbortHandler {
+							panic(p)
+						}
+						logger.Error("HTTP handler panicked",
+							zap.String("path", r.URL.Path),
+							zap.Any("panic", p),
+							zap.ByteString("stack", debug.Stack()))
+						http.Error(w, "internal error", http.StatusInternalServerError)
+					}
+				}()
+				next.ServeHTTP(w, r)
+			})
+		},
+	}
+}
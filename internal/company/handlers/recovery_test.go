@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecovery(t *testing.T) {
+	recovery := Recovery(zaptest.NewLogger(t))
+
+	_, err := recovery.Unary(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/definition.v1.CompanyService/GetCompany"},
+		func(context.Context, interface{}) (interface{}, error) { panic("boom") })
+	if status.Code(err) != codes.Internal {
+		t.Errorf("expected code %v, got %v", codes.Internal, status.Code(err))
+	}
+
+	err = recovery.Stream(nil, &fakeStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/definition.v1.CompanyService/WatchCompanies"},
+		func(interface{}, grpc.ServerStream) error { panic("boom") })
+	if status.Code(err) != codes.Internal {
+		t.Errorf("expected stream code %v, got %v", codes.Internal, status.Code(err))
+	}
+
+	rec := httptest.NewRecorder()
+	recovery.HTTP(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("boom") })).
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/companies", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/company/report"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// acceptHeader is the metadata key RunReport negotiates the report format
+// with, forwarded from the HTTP header of the same name.
+const acceptHeader = "accept"
+
+// ReportHandler serves the ReportService.
+type ReportHandler struct {
+	pb.UnimplementedReportServiceServer
+	reports ReportGenerator
+	logger  *zap.Logger
+}
+
+// NewReportHandler returns a ReportHandler running reports.
+func NewReportHandler(reports ReportGenerator, logger *zap.Logger) *ReportHandler {
+	return &ReportHandler{reports: reports, logger: logger.Named("report_handler")}
+}
+
+// ListReports returns the predefined reports.
+func (h *ReportHandler) ListReports(context.Context, *pb.ListReportsRequest) (*pb.ListReportsResponse, error) {
+	resp := &pb.ListReportsResponse{}
+	for _, r := range h.reports.Reports() {
+		resp.Reports = append(resp.Reports, &pb.Report{Name: r.Name, Description: r.Description, Columns: r.Columns})
+	}
+	return resp, nil
+}
+
+// RunReport streams the lines of a report in the requested or negotiated
+// format.
+func (h *ReportHandler) RunReport(req *pb.RunReportRequest, stream grpc.ServerStreamingServer[httpbody.HttpBody]) error {
+	ctx := stream.Context()
+	format, err := reportFormat(ctx, req.GetFormat())
+	if err != nil {
+		return serviceErrorStatus(err, h.logger)
+	}
+	params := report.Params{TenantID: req.GetTenantId(), Limit: int(req.GetLimit())}
+	if req.GetSince() != nil {
+		params.Since = req.GetSince().AsTime()
+	}
+	if req.GetUntil() != nil {
+		params.Until = req.GetUntil().AsTime()
+	}
+	err = h.reports.Run(ctx, req.GetName(), params, format, func(line []byte) error {
+		return stream.Send(&httpbody.HttpBody{ContentType: format.ContentType(), Data: line})
+	})
+	if err != nil {
+		h.logger.Error("Run report failed", zap.Error(err), zap.String("report", req.GetName()))
+		return serviceErrorStatus(err, h.logger)
+	}
+	return nil
+}
+
+// reportFormat returns the format of requested, or the one negotiated from
+// the Accept metadata when unspecified.
+func reportFormat(ctx context.Context, requested pb.ReportFormat) (report.Format, error) {
+	switch requested {
+	case pb.ReportFormat_CSV:
+		return report.CSV, nil
+	case pb.ReportFormat_JSON:
+		return report.JSON, nil
+	}
+	var accept string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(acceptHeader); len(values) > 0 {
+			accept = values[0]
+		}
+	}
+	return report.Negotiate(accept)
+}
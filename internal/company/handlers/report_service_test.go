@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/company/auth"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/report"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// stubReports serves a single report of two rows and records the params of
+// its last run.
+type stubReports struct {
+	params report.Params
+}
+
+func (s *stubReports) Reports() []report.Report {
+	return []report.Report{{Name: "growth", Description: "Growth.", Columns: []string{"month", "created"}}}
+}
+
+func (s *stubReports) Run(_ context.Context, name string, p report.Params, format report.Format, emit func(line []byte) error) error {
+	if name != "growth" {
+		return e.Newf(e.CodeReportNotFound, "no report %q", name)
+	}
+	s.params = p
+	lines := []string{`{"month":"2026-01","created":2}`, `{"month":"2026-02","created":1}`}
+	if format == report.CSV {
+		lines = []string{"month,created", "2026-01,2", "2026-02,1"}
+	}
+	for _, line := range lines {
+		if err := emit([]byte(line)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestReportHandler_ListReports(t *testing.T) {
+	h := NewReportHandler(&stubReports{}, zaptest.NewLogger(t))
+	resp, err := h.ListReports(context.Background(), &pb.ListReportsRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.GetReports(), 1)
+	assert.Equal(t, "growth", resp.GetReports()[0].GetName())
+	assert.Equal(t, []string{"month", "created"}, resp.GetReports()[0].GetColumns())
+}
+
+func TestReportFormat(t *testing.T) {
+	csvAccepted := metadata.NewIncomingContext(context.Background(), metadata.Pairs(acceptHeader, "text/csv"))
+	for _, tc := range []struct {
+		ctx       context.Context
+		requested pb.ReportFormat
+		want      report.Format
+	}{
+		{context.Background(), pb.ReportFormat_REPORT_FORMAT_UNSPECIFIED, report.JSON},
+		{csvAccepted, pb.ReportFormat_REPORT_FORMAT_UNSPECIFIED, report.CSV},
+		{csvAccepted, pb.ReportFormat_JSON, report.JSON},
+		{context.Background(), pb.ReportFormat_CSV, report.CSV},
+	} {
+		got, err := reportFormat(tc.ctx, tc.requested)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, got)
+	}
+	_, err := reportFormat(metadata.NewIncomingContext(context.Background(), metadata.Pairs(acceptHeader, "image/png")), pb.ReportFormat_REPORT_FORMAT_UNSPECIFIED)
+	assert.Equal(t, e.CodeReportFormatUnsupported, e.CodeOf(err))
+}
+
+// TestReportHandler_RunReportGateway streams reports through the HTTP
+// gateway, which writes a line per message in the negotiated format.
+func TestReportHandler_RunReportGateway(t *testing.T) {
+	const secret = "report-secret"
+	logger := zaptest.NewLogger(t)
+	grpcPort, httpPort := freePort(t), freePort(t)
+	s := NewServer(grpcPort, httpPort, logger)
+	reports := &stubReports{}
+	s.RegisterReportService(NewReportHandler(reports, logger))
+	err := s.RegisterHTTPGateway(context.Background(), []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, secret)
+	require.NoError(t, err)
+	errCh := make(chan error, 1)
+	go func() { errCh <- s.Start() }()
+	t.Cleanup(func() {
+		s.Stop()
+		if err := <-errCh; err != nil {
+			t.Errorf("server returned error: %v", err)
+		}
+	})
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   "reporter",
+		"roles": []string{auth.AdminRole},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(secret))
+	require.NoError(t, err)
+	get := func(path, accept string) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d%s", httpPort, path), nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		var resp *http.Response
+		require.Eventually(t, func() bool {
+			resp, err = http.DefaultClient.Do(req)
+			return err == nil
+		}, 5*time.Second, 20*time.Millisecond)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(body)
+	}
+
+	resp, body := get("/v1/reports/growth:run?tenantId=acme&limit=5", "text/csv")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, "text/csv", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "month,created\n2026-01,2\n2026-02,1\n", body)
+	assert.Equal(t, report.Params{TenantID: "acme", Limit: 5}, reports.params)
+
+	resp, body = get("/v1/reports/growth:run?format=JSON", "text/csv")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "{\"month\":\"2026-01\",\"created\":2}\n{\"month\":\"2026-02\",\"created\":1}\n", body, "the format parameter should win over the Accept header")
+
+	resp, body = get("/v1/reports/churn:run", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, body)
+	assert.Contains(t, body, "REPORT_NOT_FOUND")
+}
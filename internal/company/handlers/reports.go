@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/company/models"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SetReports serves the report methods with reports.
+func (h *CompanyHandler) SetReports(reports ReportRunner) {
+	h.reports = reports
+}
+
+// CountCompaniesPerMonth counts the companies created per month.
+func (h *CompanyHandler) CountCompaniesPerMonth(ctx context.Context, req *pb.CountCompaniesPerMonthRequest) (*pb.CountCompaniesPerMonthResponse, error) {
+	if h.reports == nil {
+		return nil, status.Error(codes.Unimplemented, "reports are not enabled")
+	}
+	filter := models.MonthlyCountFilter{TenantID: req.GetTenantId(), MetadataKey: req.GetMetadataKey()}
+	if req.GetSince() != nil {
+		filter.Since = req.GetSince().AsTime()
+	}
+	if req.GetUntil() != nil {
+		filter.Until = req.GetUntil().AsTime()
+	}
+	counts, err := h.reports.CountCompaniesPerMonth(ctx, filter)
+	if err != nil {
+		h.logger.Error("Count companies per month failed", zap.Error(err))
+		return nil, h.mapServiceError(err)
+	}
+	resp := &pb.CountCompaniesPerMonthResponse{Counts: make([]*pb.MonthlyCount, 0, len(counts))}
+	for _, count := range counts {
+		resp.Counts = append(resp.Counts, &pb.MonthlyCount{Month: count.Month, Key: count.Key, Count: count.Count})
+	}
+	return resp, nil
+}
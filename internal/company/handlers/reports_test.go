@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// mockReports is a ReportRunner recording the filter it was called with.
+type mockReports struct {
+	filter models.MonthlyCountFilter
+}
+
+func (m *mockReports) CountCompaniesPerMonth(_ context.Context, filter models.MonthlyCountFilter) ([]models.MonthlyCount, error) {
+	if filter.MetadataKey == "$" {
+		return nil, e.Invalid("metadata_key", e.CodeMetadataKeyInvalid, "invalid metadata key")
+	}
+	m.filter = filter
+	return []models.MonthlyCount{{Month: "2025-01", Key: "DE", Count: 4}, {Month: "2025-02", Key: "", Count: 1}}, nil
+}
+
+func TestCompanyHandler_CountCompaniesPerMonth(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	_, err := NewCompanyHandler(&mockCompanyController{}, logger).CountCompaniesPerMonth(context.Background(), &pb.CountCompaniesPerMonthRequest{})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("expected code %v without reports, got %v", codes.Unimplemented, status.Code(err))
+	}
+
+	reports := &mockReports{}
+	handler := NewCompanyHandler(&mockCompanyController{}, logger)
+	handler.SetReports(reports)
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	resp, err := handler.CountCompaniesPerMonth(context.Background(), &pb.CountCompaniesPerMonthRequest{
+		Since:       timestamppb.New(since),
+		TenantId:    "acme",
+		MetadataKey: "country",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := models.MonthlyCountFilter{Since: since, TenantID: "acme", MetadataKey: "country"}
+	if reports.filter != want {
+		t.Errorf("expected filter %+v, got %+v", want, reports.filter)
+	}
+	if len(resp.GetCounts()) != 2 || resp.GetCounts()[0].GetKey() != "DE" || resp.GetCounts()[0].GetCount() != 4 {
+		t.Errorf("unexpected counts %v", resp.GetCounts())
+	}
+
+	_, err = handler.CountCompaniesPerMonth(context.Background(), &pb.CountCompaniesPerMonthRequest{MetadataKey: "$"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected code %v, got %v", codes.InvalidArgument, status.Code(err))
+	}
+}
@@ -5,38 +5,134 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	pbv2 "github.com/gartstein/xm/api/gen/definition/v2"
 	"github.com/gartstein/xm/internal/company/auth"
 	"github.com/gartstein/xm/internal/company/models"
+	"github.com/gartstein/xm/internal/company/report"
 	"github.com/google/uuid"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/code"
 	"google.golang.org/grpc"
+	// Registers the gzip compressor, so clients may compress requests and
+	// receive compressed responses.
+	_ "google.golang.org/grpc/encoding/gzip"
 	"google.golang.org/grpc/reflection"
 )
 
 // CompanyController defines the business logic interface
 // that the gRPC/HTTP handlers will invoke.
 type CompanyController interface {
-	CreateCompany(ctx context.Context, company *models.Company) (*models.Company, error)
+	CreateCompany(ctx context.Context, company *models.Company, opts models.CreateOptions) (*models.Company, error)
 	GetCompany(ctx context.Context, id uuid.UUID) (*models.Company, error)
-	UpdateCompany(ctx context.Context, update *models.CompanyUpdate) (*models.Company, error)
-	DeleteCompany(ctx context.Context, id uuid.UUID) error
+	GetCompanyByName(ctx context.Context, name string) (*models.Company, error)
+	GetCompanyByExternalRef(ctx context.Context, ref string) (*models.Company, error)
+	ListCompanies(ctx context.Context, filter models.CompanyFilter, pageSize int, pageToken string) ([]models.Company, string, error)
+	CountCompanies(ctx context.Context, filter models.CompanyFilter) (int64, error)
+	ListMyCompanies(ctx context.Context, filter models.CompanyFilter, pageSize int, pageToken string) ([]models.Company, string, error)
+	UpdateCompany(ctx context.Context, update *models.CompanyUpdate, opts models.UpdateOptions) (*models.Company, error)
+	DeleteCompany(ctx context.Context, id uuid.UUID) (bool, error)
+	PurgeCompany(ctx context.Context, id uuid.UUID) error
+	SuspendCompany(ctx context.Context, id uuid.UUID) (*models.Company, error)
+	ActivateCompany(ctx context.Context, id uuid.UUID) (*models.Company, error)
+	EraseCompanyData(ctx context.Context, id uuid.UUID) (*models.Company, error)
+	ReplayCompanyEvents(ctx context.Context, filter models.CompanyEventFilter, topic string) (int, error)
+	GetCompanyHistory(ctx context.Context, company uuid.UUID, since, until time.Time, pageSize int, pageToken string) ([]models.CompanyEvent, string, error)
+	WatchCompanyEvents(ctx context.Context, filter models.CompanyEventFilter, resumeToken string, fn func(event *models.CompanyEvent, resumeToken string) error) error
+	ApplyCompanies(ctx context.Context, desired []models.Company, opts models.ApplyOptions) ([]models.CompanyChange, error)
 }
 
-// Server holds references to both a gRPC server and an HTTP server.
+// AlertWebhookManager manages the chat webhooks alerted about company
+// events.
+type AlertWebhookManager interface {
+	CreateAlertWebhook(ctx context.Context, webhook *models.AlertWebhook) error
+	ListAlertWebhooks(ctx context.Context) ([]models.AlertWebhook, error)
+	DeleteAlertWebhook(ctx context.Context, id uuid.UUID) error
+}
+
+// TenantQuotaManager views and adjusts the quotas of tenants.
+type TenantQuotaManager interface {
+	GetTenantQuota(ctx context.Context, tenant string) (*models.TenantQuota, *models.TenantUsage, error)
+	UpdateTenantQuota(ctx context.Context, quota *models.TenantQuota) (*models.TenantQuota, error)
+}
+
+// EmployeeManager manages the employees of companies.
+type EmployeeManager interface {
+	CreateEmployee(ctx context.Context, employee *models.Employee) (*models.Employee, error)
+	GetEmployee(ctx context.Context, company, id uuid.UUID) (*models.Employee, error)
+	ListEmployees(ctx context.Context, company uuid.UUID, pageSize int, pageToken string) ([]models.Employee, string, error)
+	UpdateEmployee(ctx context.Context, update *models.EmployeeUpdate) (*models.Employee, error)
+	DeleteEmployee(ctx context.Context, company, id uuid.UUID) error
+}
+
+// NoteManager records notes on companies.
+type NoteManager interface {
+	AddCompanyNote(ctx context.Context, note *models.CompanyNote) (*models.CompanyNote, error)
+	ListCompanyNotes(ctx context.Context, company uuid.UUID, pageSize int, pageToken string) ([]models.CompanyNote, string, error)
+	DeleteCompanyNote(ctx context.Context, company, id uuid.UUID) error
+}
+
+// CompanyExporter writes exports of the companies to object storage.
+type CompanyExporter interface {
+	Export(ctx context.Context, kind models.ExportKind) (*models.ExportRun, error)
+}
+
+// DuplicateManager lists and resolves the suggestions of duplicate
+// companies and merges companies.
+type DuplicateManager interface {
+	ListDuplicateSuggestions(ctx context.Context, filter models.DuplicateSuggestionFilter, pageSize int, pageToken string) ([]models.DuplicateSuggestion, string, error)
+	ResolveDuplicateSuggestion(ctx context.Context, id uuid.UUID, resolution models.DuplicateResolution, keep uuid.UUID) (*models.DuplicateSuggestion, *models.Company, error)
+	MergeCompanies(ctx context.Context, keep, merged uuid.UUID) (*models.Company, error)
+}
+
+// CompanyTypeManager manages the company type taxonomy.
+type CompanyTypeManager interface {
+	ListCompanyTypes(ctx context.Context) ([]models.CompanyTypeDefinition, error)
+	CreateCompanyType(ctx context.Context, companyType *models.CompanyTypeDefinition) (*models.CompanyTypeDefinition, error)
+	UpdateCompanyType(ctx context.Context, companyType *models.CompanyTypeDefinition) (*models.CompanyTypeDefinition, error)
+	DeleteCompanyType(ctx context.Context, code models.CompanyType) error
+}
+
+// ReportRunner computes reports over the companies.
+type ReportRunner interface {
+	CountCompaniesPerMonth(ctx context.Context, filter models.MonthlyCountFilter) ([]models.MonthlyCount, error)
+}
+
+// ReportGenerator runs the predefined reports; *report.Runner implements it.
+type ReportGenerator interface {
+	Reports() []report.Report
+	Run(ctx context.Context, name string, p report.Params, format report.Format, emit func(line []byte) error) error
+}
+
+// JWTKeyReloader reloads the keys JWTs are validated with; *auth.Keyring
+// implements it.
+type JWTKeyReloader interface {
+	Reload(ctx context.Context) error
+	KeyIDs() (primary string, ids []string)
+}
+
+// Server holds references to both a gRPC server and an HTTP server, plus an
+// optional admin server for operational endpoints.
 type Server struct {
 	grpcServer   *grpc.Server
 	httpServer   *http.Server
+	admin        *adminServer
 	logger       *zap.Logger
 	grpcEndpoint string
 	httpEndpoint string
+	// maxBodyBytes caps HTTP request bodies; 0 means no limit.
+	maxBodyBytes int64
+	// chain supplies the HTTP middleware wrapping the gateway, if set.
+	chain *Chain
 }
 
 // NewServer constructs a Server with separate endpoints for gRPC and HTTP.
@@ -61,9 +157,43 @@ func (s *Server) RegisterGRPCHandler(h *CompanyHandler) {
 	reflection.Register(s.grpcServer)
 }
 
+// RegisterGRPCHandlerV2 registers the gRPC handler for the v2 CompanyService
+// next to v1.
+func (s *Server) RegisterGRPCHandlerV2(h *CompanyHandlerV2) {
+	pbv2.RegisterCompanyServiceServer(s.grpcServer, h)
+}
+
+// LimitRequestBody rejects HTTP requests with bodies larger than maxBytes. It
+// must be called before RegisterHTTPGateway.
+func (s *Server) LimitRequestBody(maxBytes int64) {
+	s.maxBodyBytes = maxBytes
+}
+
+// UseHTTPMiddleware wraps the HTTP gateway in the HTTP middleware of chain,
+// whose gRPC interceptors are installed through chain.ServerOption. It must
+// be called before RegisterHTTPGateway.
+func (s *Server) UseHTTPMiddleware(chain *Chain) {
+	s.chain = chain
+}
+
+// RegisterReportService registers the gRPC handler for the ReportService.
+func (s *Server) RegisterReportService(h *ReportHandler) {
+	pb.RegisterReportServiceServer(s.grpcServer, h)
+}
+
 // RegisterHTTPGateway sets up the HTTP reverse-proxy (gRPC-Gateway) with the specified dial options.
-func (s *Server) RegisterHTTPGateway(ctx context.Context, dialOpts []grpc.DialOption, jwtSecret string) error {
-	mux := runtime.NewServeMux()
+func (s *Server) RegisterHTTPGateway(ctx context.Context, dialOpts []grpc.DialOption, jwtSecret string, authOpts ...auth.Option) error {
+	mux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(incomingHeaderMatcher),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeaderMatcher),
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, newGatewayMarshaler()),
+		runtime.WithErrorHandler(gatewayErrorHandler(s.logger)),
+		runtime.WithForwardResponseOption(forwardHTTPStatus),
+		// The auth middleware checks the route the mux matched for the
+		// request's own HTTP method, so POST to GET fallback stays off.
+		runtime.WithDisablePathLengthFallback(),
+		runtime.WithMiddlewares(auth.GatewayMiddleware(jwtSecret, authOpts...)),
+	)
 	err := pb.RegisterCompanyServiceHandlerFromEndpoint(
 		ctx,
 		mux,
@@ -73,20 +203,91 @@ func (s *Server) RegisterHTTPGateway(ctx context.Context, dialOpts []grpc.DialOp
 	if err != nil {
 		return err
 	}
+	err = pbv2.RegisterCompanyServiceHandlerFromEndpoint(
+		ctx,
+		mux,
+		s.grpcEndpoint,
+		dialOpts,
+	)
+	if err != nil {
+		return err
+	}
+	err = pb.RegisterReportServiceHandlerFromEndpoint(
+		ctx,
+		mux,
+		s.grpcEndpoint,
+		dialOpts,
+	)
+	if err != nil {
+		return err
+	}
 
-	// Wrap the mux with auth middleware
-	authMiddleware := auth.HTTPMiddleware(mux, jwtSecret)
-
-	s.httpServer.Handler = authMiddleware
+	s.httpServer.Handler = headAsGet(conditionalGet(mux))
+	if s.maxBodyBytes > 0 {
+		s.httpServer.Handler = limitBody(s.httpServer.Handler, s.maxBodyBytes, s.logger)
+	}
+	if s.chain != nil {
+		s.httpServer.Handler = s.chain.Handler(s.httpServer.Handler)
+	}
 	s.httpServer.Addr = s.httpEndpoint
 	return nil
 }
 
-// Start runs the gRPC and HTTP servers concurrently, returning on the first error.
+// incomingHeaderMatcher forwards the API key, request ID, Accept and
+// Accept-Language headers to gRPC metadata in addition to the gateway's
+// default headers.
+func incomingHeaderMatcher(key string) (string, bool) {
+	if strings.EqualFold(key, auth.APIKeyHeader) {
+		return auth.APIKeyHeader, true
+	}
+	if strings.EqualFold(key, RequestIDHeader) {
+		return RequestIDHeader, true
+	}
+	if strings.EqualFold(key, AcceptLanguageHeader) {
+		return AcceptLanguageHeader, true
+	}
+	if strings.EqualFold(key, acceptHeader) {
+		return acceptHeader, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// outgoingHeaderMatcher passes the deprecation, quota warning, ETag and
+// Location headers to HTTP clients as they are, drops the HTTP status, and
+// prefixes other response metadata like the gateway's default.
+func outgoingHeaderMatcher(key string) (string, bool) {
+	switch strings.ToLower(key) {
+	case deprecationHeader, sunsetHeader, linkHeader, quotaWarningHeader, etagHeader, locationHeader:
+		return key, true
+	case httpStatusHeader:
+		return "", false
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
+// limitBody answers 413 to requests declaring a body larger than maxBytes and
+// caps the others, so chunked bodies fail once they pass the limit.
+func limitBody(next http.Handler, maxBytes int64, logger *zap.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxBytes {
+			writeErrorEnvelope(w, http.StatusRequestEntityTooLarge, errorEnvelope{
+				Code:      code.Code_RESOURCE_EXHAUSTED.String(),
+				Message:   fmt.Sprintf("request body exceeds %d bytes", maxBytes),
+				Details:   []json.RawMessage{},
+				RequestID: r.Header.Get(RequestIDHeader),
+			}, logger)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Start runs the gRPC, HTTP and admin servers concurrently, returning on the first error.
 func (s *Server) Start() error {
 	var wg sync.WaitGroup
-	wg.Add(2)
-	errChan := make(chan error, 2)
+	wg.Add(3)
+	errChan := make(chan error, 3)
 
 	// Start gRPC Server
 	go func() {
@@ -111,6 +312,18 @@ func (s *Server) Start() error {
 		}
 	}()
 
+	// Start admin Server
+	go func() {
+		defer wg.Done()
+		if s.admin == nil {
+			return
+		}
+		s.logger.Info("Starting admin server", zap.String("endpoint", s.admin.server.Addr))
+		if err := s.admin.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errChan <- fmt.Errorf("admin serve error: %w", err)
+		}
+	}()
+
 	go func() {
 		wg.Wait()
 		close(errChan)
@@ -135,6 +348,11 @@ func (s *Server) Stop() {
 	if err := s.httpServer.Shutdown(ctx); err != nil {
 		s.logger.Error("HTTP server shutdown error", zap.Error(err))
 	}
+	if s.admin != nil {
+		if err := s.admin.server.Shutdown(ctx); err != nil {
+			s.logger.Error("Admin server shutdown error", zap.Error(err))
+		}
+	}
 
 	s.logger.Info("Servers stopped")
 }
@@ -2,7 +2,11 @@ package handlers
 
 import (
 	"context"
+	"io"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,7 +20,7 @@ import (
 // dummyCompanyController is a simple dummy implementation of CompanyController.
 type dummyCompanyController struct{}
 
-func (d *dummyCompanyController) CreateCompany(_ context.Context, company *models.Company) (*models.Company, error) {
+func (d *dummyCompanyController) CreateCompany(_ context.Context, company *models.Company, _ models.CreateOptions) (*models.Company, error) {
 	// Simply return the company as created.
 	return company, nil
 }
@@ -26,16 +30,69 @@ func (d *dummyCompanyController) GetCompany(_ context.Context, id uuid.UUID) (*m
 	return &models.Company{ID: id, Name: "Dummy"}, nil
 }
 
-func (d *dummyCompanyController) UpdateCompany(_ context.Context, update *models.CompanyUpdate) (*models.Company, error) {
+func (d *dummyCompanyController) GetCompanyByName(_ context.Context, name string) (*models.Company, error) {
+	return &models.Company{ID: uuid.New(), Name: name}, nil
+}
+
+func (d *dummyCompanyController) GetCompanyByExternalRef(_ context.Context, ref string) (*models.Company, error) {
+	return &models.Company{ID: uuid.New(), ExternalRef: ref}, nil
+}
+
+func (d *dummyCompanyController) ListCompanies(_ context.Context, _ models.CompanyFilter, _ int, _ string) ([]models.Company, string, error) {
+	return nil, "", nil
+}
+
+func (d *dummyCompanyController) CountCompanies(_ context.Context, _ models.CompanyFilter) (int64, error) {
+	return 0, nil
+}
+
+func (d *dummyCompanyController) ListMyCompanies(_ context.Context, _ models.CompanyFilter, _ int, _ string) ([]models.Company, string, error) {
+	return nil, "", nil
+}
+
+func (d *dummyCompanyController) UpdateCompany(_ context.Context, update *models.CompanyUpdate, _ models.UpdateOptions) (*models.Company, error) {
 	// Return a dummy updated company.
 	return &models.Company{ID: update.ID, Name: "Updated"}, nil
 }
 
-func (d *dummyCompanyController) DeleteCompany(_ context.Context, _ uuid.UUID) error {
+func (d *dummyCompanyController) DeleteCompany(_ context.Context, _ uuid.UUID) (bool, error) {
 	// Assume deletion always succeeds.
+	return true, nil
+}
+
+func (d *dummyCompanyController) PurgeCompany(_ context.Context, _ uuid.UUID) error {
+	// Assume purge always succeeds.
 	return nil
 }
 
+func (d *dummyCompanyController) SuspendCompany(_ context.Context, id uuid.UUID) (*models.Company, error) {
+	return &models.Company{ID: id, Name: "Dummy", Status: models.StatusSuspended}, nil
+}
+
+func (d *dummyCompanyController) ActivateCompany(_ context.Context, id uuid.UUID) (*models.Company, error) {
+	return &models.Company{ID: id, Name: "Dummy", Status: models.StatusActive}, nil
+}
+
+func (d *dummyCompanyController) EraseCompanyData(_ context.Context, id uuid.UUID) (*models.Company, error) {
+	return &models.Company{ID: id, Name: "Dummy"}, nil
+}
+
+func (d *dummyCompanyController) ReplayCompanyEvents(_ context.Context, _ models.CompanyEventFilter, _ string) (int, error) {
+	return 0, nil
+}
+
+func (d *dummyCompanyController) GetCompanyHistory(_ context.Context, _ uuid.UUID, _, _ time.Time, _ int, _ string) ([]models.CompanyEvent, string, error) {
+	return nil, "", nil
+}
+
+func (d *dummyCompanyController) WatchCompanyEvents(_ context.Context, _ models.CompanyEventFilter, _ string, _ func(*models.CompanyEvent, string) error) error {
+	return nil
+}
+
+func (d *dummyCompanyController) ApplyCompanies(_ context.Context, _ []models.Company, _ models.ApplyOptions) ([]models.CompanyChange, error) {
+	return nil, nil
+}
+
 func TestServer_RegisterHTTPGateway(t *testing.T) {
 	logger := zaptest.NewLogger(t)
 	// Create a new Server with fixed ports.
@@ -114,3 +171,31 @@ func TestServer_StartStop(t *testing.T) {
 		lis.Close()
 	}
 }
+
+func TestLimitBody(t *testing.T) {
+	handler := limitBody(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}), 8, zaptest.NewLogger(t))
+
+	tests := []struct {
+		name string
+		body io.Reader
+		want int
+	}{
+		{"WithinLimit", strings.NewReader("12345678"), http.StatusOK},
+		{"DeclaredTooLarge", strings.NewReader("123456789"), http.StatusRequestEntityTooLarge},
+		// Readers of unknown length get no Content-Length, like chunked bodies.
+		{"ChunkedTooLarge", io.MultiReader(strings.NewReader("123456789")), http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/companies", tc.body))
+			if rec.Code != tc.want {
+				t.Errorf("expected status %d, got %d", tc.want, rec.Code)
+			}
+		})
+	}
+}
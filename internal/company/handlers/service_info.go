@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"context"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/pkg/version"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GetServiceInfo returns the build of the running binary.
+func (h *CompanyHandler) GetServiceInfo(context.Context, *pb.GetServiceInfoRequest) (*pb.GetServiceInfoResponse, error) {
+	info := version.Get()
+	return &pb.GetServiceInfoResponse{
+		Version:   info.Version,
+		Commit:    info.Commit,
+		BuildTime: info.BuildTime,
+		GoVersion: info.GoVersion,
+		StartedAt: timestamppb.New(info.StartedAt),
+	}, nil
+}
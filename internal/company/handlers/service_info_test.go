@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/pkg/version"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestCompanyHandler_GetServiceInfo(t *testing.T) {
+	defer func(v string) { version.Version = v }(version.Version)
+	version.Version = "v1.4.0"
+
+	handler := NewCompanyHandler(&mockCompanyController{}, zaptest.NewLogger(t))
+	resp, err := handler.GetServiceInfo(context.Background(), &pb.GetServiceInfoRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.GetVersion() != "v1.4.0" || resp.GetGoVersion() != runtime.Version() {
+		t.Errorf("unexpected service info %v", resp)
+	}
+	if resp.GetStartedAt().AsTime().IsZero() {
+		t.Error("expected the start time to be set")
+	}
+}
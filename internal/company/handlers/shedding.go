@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// sheddingWindow is how often the in-flight limit is adjusted.
+	sheddingWindow = time.Second
+	// sheddingMinSamples is the number of calls a window needs for its p99
+	// to lower the limit, so a few slow calls do not.
+	sheddingMinSamples = 20
+	// sheddingMaxSamples bounds the latencies kept per window; beyond it the
+	// oldest are overwritten.
+	sheddingMaxSamples = 4096
+)
+
+// LoadShedder rejects calls with UNAVAILABLE and a RetryInfo while more calls
+// than its limit are in flight, before the database pool saturates and every
+// call slows down. The limit starts at the configured maximum and adapts
+// once per second: it drops by a tenth while the p99 latency of the calls
+// finished in the last window exceeds the target, down to a tenth of the
+// maximum, and recovers by a tenth otherwise. Without a target latency the
+// limit stays at the maximum.
+//
+// A LoadShedder implements expvar.Var, reporting its state and the number of
+// calls admitted and shed.
+type LoadShedder struct {
+	maxInFlight int64
+	minInFlight int64
+	targetP99   time.Duration
+	retryAfter  time.Duration
+	now         func() time.Time
+
+	inFlight atomic.Int64
+	limit    atomic.Int64
+	admitted atomic.Int64
+	shed     atomic.Int64
+
+	mu          sync.Mutex
+	samples     []time.Duration
+	observed    int
+	windowStart time.Time
+	p99         time.Duration
+}
+
+// LoadShedderOption configures a LoadShedder.
+type LoadShedderOption func(*LoadShedder)
+
+// WithTargetLatency lowers the in-flight limit while the p99 latency exceeds
+// target.
+func WithTargetLatency(target time.Duration) LoadShedderOption {
+	return func(s *LoadShedder) {
+		s.targetP99 = target
+	}
+}
+
+// WithRetryAfter sets the delay shed calls are told to retry after; it
+// defaults to one second.
+func WithRetryAfter(d time.Duration) LoadShedderOption {
+	return func(s *LoadShedder) {
+		s.retryAfter = d
+	}
+}
+
+// NewLoadShedder returns a LoadShedder admitting at most maxInFlight
+// concurrent calls.
+func NewLoadShedder(maxInFlight int, opts ...LoadShedderOption) *LoadShedder {
+	s := &LoadShedder{
+		maxInFlight: int64(maxInFlight),
+		minInFlight: max(1, int64(maxInFlight)/10),
+		retryAfter:  time.Second,
+		now:         time.Now,
+		samples:     make([]time.Duration, 0, sheddingMaxSamples),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.limit.Store(s.maxInFlight)
+	s.windowStart = s.now()
+	return s
+}
+
+// Unary returns the interceptor. It should run before authentication, so
+// shed calls cost as little as possible.
+func (s *LoadShedder) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		_ *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if s.inFlight.Add(1) > s.limit.Load() {
+			s.inFlight.Add(-1)
+			s.shed.Add(1)
+			info, _ := e.Lookup(e.CodeOverloaded)
+			return nil, codeStatus(info, "service overloaded, retry later",
+				&errdetails.RetryInfo{RetryDelay: durationpb.New(s.retryAfter)}).Err()
+		}
+		s.admitted.Add(1)
+		start := s.now()
+		defer func() {
+			s.inFlight.Add(-1)
+			s.observe(s.now().Sub(start))
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// observe records the latency of a finished call and adjusts the limit once
+// the window is over.
+func (s *LoadShedder) observe(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.samples) < sheddingMaxSamples {
+		s.samples = append(s.samples, latency)
+	} else {
+		s.samples[s.observed%sheddingMaxSamples] = latency
+	}
+	s.observed++
+	if now := s.now(); now.Sub(s.windowStart) >= sheddingWindow {
+		s.adjust()
+		s.samples = s.samples[:0]
+		s.observed = 0
+		s.windowStart = now
+	}
+}
+
+// adjust moves the limit according to the latencies of the window. s.mu
+// must be held.
+func (s *LoadShedder) adjust() {
+	if len(s.samples) > 0 {
+		sort.Slice(s.samples, func(i, j int) bool { return s.samples[i] < s.samples[j] })
+		s.p99 = s.samples[(len(s.samples)*99-1)/100]
+	}
+	limit := s.limit.Load()
+	if s.targetP99 > 0 && len(s.samples) >= sheddingMinSamples && s.p99 > s.targetP99 {
+		limit = max(s.minInFlight, min(limit*9/10, limit-1))
+	} else {
+		limit = min(s.maxInFlight, limit+max(1, limit/10))
+	}
+	s.limit.Store(limit)
+}
+
+// String implements expvar.Var.
+func (s *LoadShedder) String() string {
+	s.mu.Lock()
+	p99 := s.p99
+	s.mu.Unlock()
+	b, err := json.Marshal(struct {
+		InFlight    int64   `json:"in_flight"`
+		Limit       int64   `json:"limit"`
+		MaxInFlight int64   `json:"max_in_flight"`
+		P99Millis   float64 `json:"p99_ms"`
+		Admitted    int64   `json:"admitted"`
+		Shed        int64   `json:"shed"`
+	}{
+		InFlight:    s.inFlight.Load(),
+		Limit:       s.limit.Load(),
+		MaxInFlight: s.maxInFlight,
+		P99Millis:   float64(p99) / float64(time.Millisecond),
+		Admitted:    s.admitted.Load(),
+		Shed:        s.shed.Load(),
+	})
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLoadShedder_InFlightLimit(t *testing.T) {
+	shedder := NewLoadShedder(2, WithRetryAfter(3*time.Second))
+	interceptor := shedder.Unary()
+	info := &grpc.UnaryServerInfo{FullMethod: "/definition.v1.CompanyService/GetCompany"}
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan struct{})
+	blocking := func(context.Context, interface{}) (interface{}, error) {
+		started <- struct{}{}
+		<-release
+		return "ok", nil
+	}
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, _ = interceptor(context.Background(), nil, info, blocking)
+			done <- struct{}{}
+		}()
+		<-started
+	}
+
+	_, err := interceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		t.Error("expected the call to be shed")
+		return nil, nil
+	})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("expected Unavailable, got %v", err)
+	}
+	var retry *errdetails.RetryInfo
+	var code string
+	for _, detail := range status.Convert(err).Details() {
+		switch d := detail.(type) {
+		case *errdetails.RetryInfo:
+			retry = d
+		case *errdetails.ErrorInfo:
+			code = d.GetMetadata()["code"]
+		}
+	}
+	if retry == nil || retry.GetRetryDelay().AsDuration() != 3*time.Second {
+		t.Errorf("expected a 3s RetryInfo, got %v", retry)
+	}
+	if code != string(e.CodeOverloaded) {
+		t.Errorf("expected code %s, got %q", e.CodeOverloaded, code)
+	}
+
+	close(release)
+	<-done
+	<-done
+	if resp, err := interceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		return "ok", nil
+	}); err != nil || resp != "ok" {
+		t.Errorf("expected calls to be admitted again, got %v, %v", resp, err)
+	}
+
+	var stats map[string]float64
+	if err := json.Unmarshal([]byte(shedder.String()), &stats); err != nil {
+		t.Fatalf("invalid metrics %q: %v", shedder.String(), err)
+	}
+	if stats["admitted"] != 3 || stats["shed"] != 1 || stats["in_flight"] != 0 {
+		t.Errorf("unexpected metrics %v", stats)
+	}
+}
+
+func TestLoadShedder_AdaptsToLatency(t *testing.T) {
+	now := time.Unix(0, 0)
+	shedder := NewLoadShedder(100, WithTargetLatency(100*time.Millisecond))
+	shedder.now = func() time.Time { return now }
+	shedder.windowStart = now
+
+	window := func(latency time.Duration) int64 {
+		for i := 0; i < sheddingMinSamples; i++ {
+			shedder.observe(latency)
+		}
+		now = now.Add(sheddingWindow)
+		shedder.observe(latency)
+		return shedder.limit.Load()
+	}
+
+	if limit := window(300 * time.Millisecond); limit != 90 {
+		t.Errorf("expected a slow window to lower the limit to 90, got %d", limit)
+	}
+	for i := 0; i < 50; i++ {
+		window(300 * time.Millisecond)
+	}
+	if limit := shedder.limit.Load(); limit != 10 {
+		t.Errorf("expected the limit to stop at 10, got %d", limit)
+	}
+	if limit := window(10 * time.Millisecond); limit != 11 {
+		t.Errorf("expected a fast window to raise the limit to 11, got %d", limit)
+	}
+	for i := 0; i < 50; i++ {
+		window(10 * time.Millisecond)
+	}
+	if limit := shedder.limit.Load(); limit != 100 {
+		t.Errorf("expected the limit to recover to 100, got %d", limit)
+	}
+
+	// Too few calls do not lower the limit.
+	shedder.observe(time.Second)
+	now = now.Add(sheddingWindow)
+	shedder.observe(time.Second)
+	if limit := shedder.limit.Load(); limit != 100 {
+		t.Errorf("expected a sparse window to keep the limit, got %d", limit)
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/gartstein/xm/internal/company/models"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SetTenantQuotas serves the tenant quota methods with quotas.
+func (h *CompanyHandler) SetTenantQuotas(quotas TenantQuotaManager) {
+	h.quotas = quotas
+}
+
+// GetTenantQuota returns the quota of a tenant and its current usage.
+func (h *CompanyHandler) GetTenantQuota(ctx context.Context, req *pb.GetTenantQuotaRequest) (*pb.GetTenantQuotaResponse, error) {
+	if h.quotas == nil {
+		return nil, status.Error(codes.Unimplemented, "tenant quotas are not enabled")
+	}
+	quota, usage, err := h.quotas.GetTenantQuota(ctx, req.GetTenantId())
+	if err != nil {
+		return nil, h.mapServiceError(err)
+	}
+	return &pb.GetTenantQuotaResponse{
+		Quota: tenantQuotaToProto(quota),
+		Usage: &pb.TenantUsage{
+			Companies:           usage.Companies,
+			MutationsThisMinute: int32(usage.MutationsThisMinute),
+		},
+	}, nil
+}
+
+// UpdateTenantQuota sets the quota of a tenant.
+func (h *CompanyHandler) UpdateTenantQuota(ctx context.Context, req *pb.UpdateTenantQuotaRequest) (*pb.UpdateTenantQuotaResponse, error) {
+	if h.quotas == nil {
+		return nil, status.Error(codes.Unimplemented, "tenant quotas are not enabled")
+	}
+	in := req.GetQuota()
+	if in == nil {
+		return nil, status.Error(codes.InvalidArgument, "quota required")
+	}
+	quota, err := h.quotas.UpdateTenantQuota(ctx, &models.TenantQuota{
+		TenantID:              req.GetTenantId(),
+		MaxCompanies:          int(in.GetMaxCompanies()),
+		MaxMutationsPerMinute: int(in.GetMaxMutationsPerMinute()),
+	})
+	if err != nil {
+		h.logger.Error("Update tenant quota failed", zap.Error(err), zap.String("tenant_id", req.GetTenantId()))
+		return nil, h.mapServiceError(err)
+	}
+	return &pb.UpdateTenantQuotaResponse{Quota: tenantQuotaToProto(quota)}, nil
+}
+
+// tenantQuotaToProto converts a quota for a response. Defaults, which were
+// never set by an admin, have no update time.
+func tenantQuotaToProto(quota *models.TenantQuota) *pb.TenantQuota {
+	out := &pb.TenantQuota{
+		TenantId:              quota.TenantID,
+		MaxCompanies:          int32(quota.MaxCompanies),
+		MaxMutationsPerMinute: int32(quota.MaxMutationsPerMinute),
+		UpdatedBy:             quota.UpdatedBy,
+	}
+	if !quota.UpdatedAt.IsZero() {
+		out.UpdatedAt = timestamppb.New(quota.UpdatedAt)
+	}
+	return out
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mockTenantQuotas is a TenantQuotaManager keeping quotas in memory.
+type mockTenantQuotas struct {
+	quotas map[string]models.TenantQuota
+}
+
+func (m *mockTenantQuotas) GetTenantQuota(_ context.Context, tenant string) (*models.TenantQuota, *models.TenantUsage, error) {
+	if tenant == "" {
+		return nil, nil, e.Newf(e.CodeTenantIDRequired, "tenant ID required")
+	}
+	quota := m.quotas[tenant]
+	quota.TenantID = tenant
+	return &quota, &models.TenantUsage{Companies: 3, MutationsThisMinute: 1}, nil
+}
+
+func (m *mockTenantQuotas) UpdateTenantQuota(_ context.Context, quota *models.TenantQuota) (*models.TenantQuota, error) {
+	if quota.MaxCompanies < 0 {
+		return nil, e.Newf(e.CodeQuotaLimitNegative, "negative")
+	}
+	quota.UpdatedBy = "root"
+	quota.UpdatedAt = time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	m.quotas[quota.TenantID] = *quota
+	return quota, nil
+}
+
+func TestCompanyHandler_TenantQuotas(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	t.Run("NotEnabled", func(t *testing.T) {
+		handler := NewCompanyHandler(&mockCompanyController{}, logger)
+		_, err := handler.GetTenantQuota(context.Background(), &pb.GetTenantQuotaRequest{TenantId: "acme"})
+		if status.Code(err) != codes.Unimplemented {
+			t.Errorf("expected code %v, got %v", codes.Unimplemented, status.Code(err))
+		}
+	})
+
+	handler := NewCompanyHandler(&mockCompanyController{}, logger)
+	handler.SetTenantQuotas(&mockTenantQuotas{quotas: map[string]models.TenantQuota{}})
+
+	t.Run("Defaults", func(t *testing.T) {
+		resp, err := handler.GetTenantQuota(context.Background(), &pb.GetTenantQuotaRequest{TenantId: "acme"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.GetQuota().GetTenantId() != "acme" || resp.GetQuota().GetUpdatedAt() != nil {
+			t.Errorf("unexpected quota %v", resp.GetQuota())
+		}
+		if resp.GetUsage().GetCompanies() != 3 || resp.GetUsage().GetMutationsThisMinute() != 1 {
+			t.Errorf("unexpected usage %v", resp.GetUsage())
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		if _, err := handler.UpdateTenantQuota(context.Background(), &pb.UpdateTenantQuotaRequest{TenantId: "acme"}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v without a quota, got %v", codes.InvalidArgument, status.Code(err))
+		}
+		_, err := handler.UpdateTenantQuota(context.Background(), &pb.UpdateTenantQuotaRequest{
+			TenantId: "acme",
+			Quota:    &pb.TenantQuota{MaxCompanies: -1},
+		})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code %v for a negative limit, got %v", codes.InvalidArgument, status.Code(err))
+		}
+
+		resp, err := handler.UpdateTenantQuota(context.Background(), &pb.UpdateTenantQuotaRequest{
+			TenantId: "acme",
+			Quota:    &pb.TenantQuota{TenantId: "ignored", MaxCompanies: 100, MaxMutationsPerMinute: 60},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		quota := resp.GetQuota()
+		if quota.GetTenantId() != "acme" || quota.GetMaxCompanies() != 100 || quota.GetMaxMutationsPerMinute() != 60 || quota.GetUpdatedBy() != "root" {
+			t.Errorf("unexpected quota %v", quota)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"google.golang.org/grpc"
+)
+
+// Timeouts bounds how long unary calls may run. Each method gets the budget
+// configured for it, or the default one; a budget of 0 leaves its calls
+// unbounded. A shorter deadline set by the client is kept. Calls running out
+// of their budget fail with DEADLINE_EXCEEDED and code
+// LATENCY_BUDGET_EXCEEDED, however the handler reported the expiry.
+// Streaming calls are not bounded.
+//
+// A Timeouts implements expvar.Var, reporting the number of calls that ran
+// out of their budget per method.
+type Timeouts struct {
+	defaultBudget time.Duration
+	budgets       map[string]time.Duration
+
+	mu       sync.Mutex
+	exceeded map[string]int64
+}
+
+// NewTimeouts returns Timeouts giving the methods in budgets, full gRPC
+// method names, their budget and the others defaultBudget.
+func NewTimeouts(defaultBudget time.Duration, budgets map[string]time.Duration) (*Timeouts, error) {
+	if defaultBudget < 0 {
+		return nil, fmt.Errorf("default method timeout %v is negative", defaultBudget)
+	}
+	for method, budget := range budgets {
+		if budget < 0 {
+			return nil, fmt.Errorf("timeout %v of %s is negative", budget, method)
+		}
+	}
+	return &Timeouts{
+		defaultBudget: defaultBudget,
+		budgets:       budgets,
+		exceeded:      map[string]int64{},
+	}, nil
+}
+
+// budget returns the budget of method.
+func (t *Timeouts) budget(method string) time.Duration {
+	if budget, ok := t.budgets[method]; ok {
+		return budget
+	}
+	return t.defaultBudget
+}
+
+// Unary returns the interceptor. It should run before the transactions, so
+// that a call out of its budget is rolled back.
+func (t *Timeouts) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		budget := t.budget(info.FullMethod)
+		if budget <= 0 {
+			return handler(ctx, req)
+		}
+		deadline := time.Now().Add(budget)
+		if clientDeadline, ok := ctx.Deadline(); ok && clientDeadline.Before(deadline) {
+			return handler(ctx, req)
+		}
+		ctx, cancel := context.WithDeadline(ctx, deadline)
+		defer cancel()
+		resp, err := handler(ctx, req)
+		if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return resp, err
+		}
+		t.mu.Lock()
+		t.exceeded[info.FullMethod]++
+		t.mu.Unlock()
+		codeInfo, _ := e.Lookup(e.CodeLatencyBudgetExceeded)
+		return nil, codeStatus(codeInfo, fmt.Sprintf("call did not finish within %v", budget)).Err()
+	}
+}
+
+// String implements expvar.Var.
+func (t *Timeouts) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var total int64
+	for _, n := range t.exceeded {
+		total += n
+	}
+	b, err := json.Marshal(struct {
+		Exceeded         int64            `json:"exceeded"`
+		ExceededByMethod map[string]int64 `json:"exceeded_by_method"`
+	}{total, t.exceeded})
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
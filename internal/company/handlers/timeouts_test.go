@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewTimeouts(t *testing.T) {
+	if _, err := NewTimeouts(-time.Second, nil); err == nil {
+		t.Error("expected a negative default timeout to be rejected")
+	}
+	if _, err := NewTimeouts(time.Second, map[string]time.Duration{"/definition.v1.CompanyService/GetCompany": -1}); err == nil {
+		t.Error("expected a negative method timeout to be rejected")
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	const (
+		get    = "/definition.v1.CompanyService/GetCompany"
+		export = "/definition.v1.CompanyService/ExportCompanies"
+		create = "/definition.v1.CompanyService/CreateCompany"
+	)
+	timeouts, err := NewTimeouts(time.Hour, map[string]time.Duration{get: 20 * time.Millisecond, export: 0})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	interceptor := timeouts.Unary()
+	// slow waits for the deadline and, like mapServiceError, reports its
+	// expiry as an internal error.
+	slow := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, status.Error(codes.Internal, fmt.Sprintf("query failed: %v", ctx.Err()))
+	}
+	deadline := func(method string) (time.Duration, bool) {
+		var remaining time.Duration
+		var ok bool
+		_, _ = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			var d time.Time
+			if d, ok = ctx.Deadline(); ok {
+				remaining = time.Until(d)
+			}
+			return nil, nil
+		})
+		return remaining, ok
+	}
+
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: get}, slow)
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+	var code string
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			code = info.GetMetadata()["code"]
+		}
+	}
+	if code != string(e.CodeLatencyBudgetExceeded) {
+		t.Errorf("expected code %s, got %q", e.CodeLatencyBudgetExceeded, code)
+	}
+
+	if remaining, ok := deadline(create); !ok || remaining < 59*time.Minute {
+		t.Errorf("expected methods without a budget of their own to get the default, got %v, %v", remaining, ok)
+	}
+	if _, ok := deadline(export); ok {
+		t.Error("expected a 0 budget to leave the call unbounded")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: create}, slow)
+	if status.Code(err) != codes.Internal {
+		t.Errorf("expected the error of a call out of the client's deadline to be left alone, got %v", err)
+	}
+
+	var stats struct {
+		Exceeded         int64            `json:"exceeded"`
+		ExceededByMethod map[string]int64 `json:"exceeded_by_method"`
+	}
+	if err := json.Unmarshal([]byte(timeouts.String()), &stats); err != nil {
+		t.Fatalf("invalid metrics %q: %v", timeouts.String(), err)
+	}
+	if stats.Exceeded != 1 || stats.ExceededByMethod[get] != 1 {
+		t.Errorf("unexpected metrics %+v", stats)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// MutatingMethods are the full gRPC method names of the calls changing data.
+var MutatingMethods = []string{
+	"/definition.v1.CompanyService/CreateCompany",
+	"/definition.v1.CompanyService/UpdateCompany",
+	"/definition.v1.CompanyService/DeleteCompany",
+	"/definition.v1.CompanyService/PurgeCompany",
+	"/definition.v1.CompanyService/SuspendCompany",
+	"/definition.v1.CompanyService/ActivateCompany",
+	"/definition.v1.CompanyService/EraseCompanyData",
+	"/definition.v1.CompanyService/ApplyCompanies",
+	"/definition.v1.CompanyService/CreateEmployee",
+	"/definition.v1.CompanyService/UpdateEmployee",
+	"/definition.v1.CompanyService/DeleteEmployee",
+	"/definition.v1.CompanyService/AddCompanyNote",
+	"/definition.v1.CompanyService/DeleteCompanyNote",
+	"/definition.v1.CompanyService/CreateAlertWebhook",
+	"/definition.v1.CompanyService/DeleteAlertWebhook",
+	"/definition.v1.CompanyService/UpdateTenantQuota",
+	"/definition.v1.CompanyService/MergeCompanies",
+	"/definition.v1.CompanyService/ResolveDuplicateSuggestion",
+	"/definition.v1.CompanyService/CreateCompanyType",
+	"/definition.v1.CompanyService/UpdateCompanyType",
+	"/definition.v1.CompanyService/DeleteCompanyType",
+	"/definition.v2.CompanyService/CreateCompany",
+	"/definition.v2.CompanyService/UpdateCompany",
+	"/definition.v2.CompanyService/DeleteCompany",
+	"/definition.v2.CompanyService/SuspendCompany",
+	"/definition.v2.CompanyService/ActivateCompany",
+}
+
+// Transactor runs fn with a context carrying a transaction that commits when
+// fn returns nil and rolls back otherwise, like
+// db.Repository.RunInTransaction.
+type Transactor interface {
+	RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// TransactionInterceptor runs every call of the given methods in a
+// transaction of its own, so the statements a handler makes through the
+// repository, such as a uniqueness check, the insert and the event history
+// entry, commit or roll back together with the outcome of the call.
+type TransactionInterceptor struct {
+	transactor Transactor
+	methods    map[string]bool
+	logger     *zap.Logger
+}
+
+// NewTransactionInterceptor returns a TransactionInterceptor wrapping calls of
+// methods in transactions of transactor.
+func NewTransactionInterceptor(transactor Transactor, methods []string, logger *zap.Logger) *TransactionInterceptor {
+	t := &TransactionInterceptor{
+		transactor: transactor,
+		methods:    make(map[string]bool, len(methods)),
+		logger:     logger.Named("transactions"),
+	}
+	for _, method := range methods {
+		t.methods[method] = true
+	}
+	return t
+}
+
+// Unary returns the interceptor. It must run after authentication, so
+// rejected calls do not open transactions.
+func (t *TransactionInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if !t.methods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		var (
+			resp       interface{}
+			handlerErr error
+		)
+		err := t.transactor.RunInTransaction(ctx, func(ctx context.Context) error {
+			resp, handlerErr = handler(ctx, req)
+			return handlerErr
+		})
+		switch {
+		case handlerErr != nil:
+			return nil, handlerErr
+		case err != nil:
+			t.logger.Error("Failed to commit transaction", zap.String("method", info.FullMethod), zap.Error(err))
+			internal, _ := e.Lookup(e.CodeInternal)
+			return nil, codeStatus(internal, "internal server error: failed to commit transaction").Err()
+		}
+		return resp, nil
+	}
+}
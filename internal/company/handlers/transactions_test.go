@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	pbv2 "github.com/gartstein/xm/api/gen/definition/v2"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type txKey struct{}
+
+// fakeTransactor records the outcome of each transaction and fails commits
+// with commitErr.
+type fakeTransactor struct {
+	commitErr error
+	outcomes  []string
+}
+
+func (f *fakeTransactor) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(context.WithValue(ctx, txKey{}, true)); err != nil {
+		f.outcomes = append(f.outcomes, "rollback")
+		return err
+	}
+	if f.commitErr != nil {
+		f.outcomes = append(f.outcomes, "rollback")
+		return f.commitErr
+	}
+	f.outcomes = append(f.outcomes, "commit")
+	return nil
+}
+
+func TestTransactionInterceptor(t *testing.T) {
+	transactor := &fakeTransactor{}
+	interceptor := NewTransactionInterceptor(transactor, []string{"/definition.v1.CompanyService/CreateCompany"}, zaptest.NewLogger(t)).Unary()
+	create := &grpc.UnaryServerInfo{FullMethod: "/definition.v1.CompanyService/CreateCompany"}
+	get := &grpc.UnaryServerInfo{FullMethod: "/definition.v1.CompanyService/GetCompany"}
+
+	inTx := false
+	handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		inTx, _ = ctx.Value(txKey{}).(bool)
+		return "ok", nil
+	}
+	resp, err := interceptor(context.Background(), nil, create, handler)
+	if err != nil || resp != "ok" {
+		t.Fatalf("unexpected result %v, %v", resp, err)
+	}
+	if !inTx {
+		t.Error("expected the handler to run in the transaction")
+	}
+
+	if _, err := interceptor(context.Background(), nil, get, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if inTx {
+		t.Error("expected other methods to run without a transaction")
+	}
+
+	_, err = interceptor(context.Background(), nil, create, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.AlreadyExists, "name taken")
+	})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("expected the handler error, got %v", err)
+	}
+
+	transactor.commitErr = errors.New("connection reset")
+	resp, err = interceptor(context.Background(), nil, create, handler)
+	if resp != nil || status.Code(err) != codes.Internal {
+		t.Errorf("expected a failed commit to be internal, got %v, %v", resp, err)
+	}
+	details := status.Convert(err).Details()
+	if len(details) != 1 {
+		t.Fatalf("expected an ErrorInfo detail, got %v", details)
+	}
+	if info, ok := details[0].(*errdetails.ErrorInfo); !ok || info.GetMetadata()["code"] != string(e.CodeInternal) {
+		t.Errorf("expected code %s, got %v", e.CodeInternal, details[0])
+	}
+
+	want := []string{"commit", "rollback", "rollback"}
+	if len(transactor.outcomes) != len(want) {
+		t.Fatalf("expected outcomes %v, got %v", want, transactor.outcomes)
+	}
+	for i := range want {
+		if transactor.outcomes[i] != want[i] {
+			t.Errorf("expected outcomes %v, got %v", want, transactor.outcomes)
+			break
+		}
+	}
+}
+
+func TestMutatingMethodsExist(t *testing.T) {
+	known := make(map[string]bool)
+	for _, desc := range []grpc.ServiceDesc{pb.CompanyService_ServiceDesc, pbv2.CompanyService_ServiceDesc} {
+		for _, method := range desc.Methods {
+			known["/"+desc.ServiceName+"/"+method.MethodName] = true
+		}
+	}
+	for _, method := range MutatingMethods {
+		if !known[method] {
+			t.Errorf("unknown method %s", method)
+		}
+	}
+}
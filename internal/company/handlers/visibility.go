@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/gartstein/xm/internal/company/models"
+)
+
+// redactableFields are the company fields visibility rules may restrict.
+// Hiding employees hides the employee range derived from it too.
+var redactableFields = []string{"description", "employees", "external_ref", "contact_email", "metadata", "created_by", "updated_by"}
+
+// FieldVisibility restricts which callers see company fields, for data
+// minimization. Each rule names a field and the roles allowed to see it;
+// for other callers the field is left empty in responses and listed in
+// redacted_fields, and ignored in updates so that writing back a company
+// they read does not clear it. A nil *FieldVisibility shows every field.
+type FieldVisibility struct {
+	rules map[string][]string
+}
+
+// NewFieldVisibility returns the visibility of rules, mapping field names
+// to the roles allowed to see them, e.g. {"employees": ["admin"]}.
+func NewFieldVisibility(rules map[string][]string) (*FieldVisibility, error) {
+	v := &FieldVisibility{rules: make(map[string][]string, len(rules))}
+	for field, roles := range rules {
+		if !slices.Contains(redactableFields, field) {
+			return nil, fmt.Errorf("field %q cannot be hidden, use one of %v", field, redactableFields)
+		}
+		if len(roles) == 0 {
+			return nil, fmt.Errorf("field %q visible to no role", field)
+		}
+		v.rules[field] = roles
+	}
+	return v, nil
+}
+
+// hidden returns the fields the caller of ctx may not see, in the order of
+// redactableFields.
+func (v *FieldVisibility) hidden(ctx context.Context) []string {
+	if v == nil || len(v.rules) == 0 {
+		return nil
+	}
+	identity, _ := auth.FromContext(ctx)
+	var fields []string
+	for _, field := range redactableFields {
+		roles, ok := v.rules[field]
+		if ok && !slices.ContainsFunc(roles, identity.HasRole) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// redact returns a copy of company without the fields the caller of ctx may
+// not see, and their names.
+func (v *FieldVisibility) redact(ctx context.Context, company *models.Company) (*models.Company, []string) {
+	fields := v.hidden(ctx)
+	if len(fields) == 0 {
+		return company, nil
+	}
+	redacted := *company
+	for _, field := range fields {
+		switch field {
+		case "description":
+			redacted.Description = ""
+		case "employees":
+			redacted.Employees, redacted.EmployeeRange = 0, ""
+		case "external_ref":
+			redacted.ExternalRef = ""
+		case "contact_email":
+			redacted.ContactEmail = ""
+		case "metadata":
+			redacted.Metadata = nil
+		case "created_by":
+			redacted.CreatedBy = ""
+		case "updated_by":
+			redacted.UpdatedBy = ""
+		}
+	}
+	return &redacted, fields
+}
+
+// restrictUpdate drops the fields the caller of ctx may not see from update.
+func (v *FieldVisibility) restrictUpdate(ctx context.Context, update *models.CompanyUpdate) {
+	for _, field := range v.hidden(ctx) {
+		switch field {
+		case "description":
+			update.Description = nil
+		case "employees":
+			update.Employees = nil
+		case "external_ref":
+			update.ExternalRef = nil
+		case "contact_email":
+			update.ContactEmail = nil
+		case "metadata":
+			update.Metadata = nil
+		}
+	}
+}
+
+// SetFieldVisibility hides the company fields restricted by visibility from
+// the callers without the roles to see them.
+func (h *CompanyHandler) SetFieldVisibility(visibility *FieldVisibility) {
+	h.visibility = visibility
+}
+
+// SetFieldVisibility hides the company fields restricted by visibility from
+// the callers without the roles to see them.
+func (h *CompanyHandlerV2) SetFieldVisibility(visibility *FieldVisibility) {
+	h.v1.SetFieldVisibility(visibility)
+}
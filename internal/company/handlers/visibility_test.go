@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	pbv2 "github.com/gartstein/xm/api/gen/definition/v2"
+	"github.com/gartstein/xm/internal/company/auth"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestNewFieldVisibility(t *testing.T) {
+	_, err := NewFieldVisibility(map[string][]string{"name": {"admin"}})
+	assert.ErrorContains(t, err, `field "name" cannot be hidden`)
+	_, err = NewFieldVisibility(map[string][]string{"employees": {}})
+	assert.ErrorContains(t, err, "visible to no role")
+}
+
+// TestFieldVisibility verifies restricted fields are emptied and listed for
+// callers without the roles to see them, in both API versions, and
+// ignored in their updates.
+func TestFieldVisibility(t *testing.T) {
+	visibility, err := NewFieldVisibility(map[string][]string{
+		"employees":     {auth.AdminRole},
+		"contact_email": {auth.AdminRole, "compliance"},
+	})
+	require.NoError(t, err)
+	id := uuid.New()
+	company := &models.Company{
+		ID:            id,
+		Name:          "Acme",
+		Employees:     42,
+		EmployeeRange: models.EmployeeRangeFor(42),
+		ContactEmail:  "ceo@acme.test",
+		Type:          models.Corporations,
+	}
+	var update *models.CompanyUpdate
+	svc := &mockCompanyController{
+		getCompanyFunc: func(context.Context, uuid.UUID) (*models.Company, error) {
+			return company, nil
+		},
+		updateCompanyFunc: func(_ context.Context, u *models.CompanyUpdate, _ models.UpdateOptions) (*models.Company, error) {
+			update = u
+			return company, nil
+		},
+	}
+	h := NewCompanyHandler(svc, zaptest.NewLogger(t))
+	h.SetFieldVisibility(visibility)
+	h2 := NewCompanyHandlerV2(svc, zaptest.NewLogger(t))
+	h2.SetFieldVisibility(visibility)
+	as := func(roles ...string) context.Context {
+		return auth.NewContext(context.Background(), auth.Identity{UserID: "u1", Roles: roles})
+	}
+
+	resp, err := h.GetCompany(as(auth.AdminRole), &pb.GetCompanyRequest{Id: id.String()})
+	require.NoError(t, err)
+	assert.EqualValues(t, 42, resp.GetCompany().GetEmployees())
+	assert.Equal(t, "ceo@acme.test", resp.GetCompany().GetContactEmail())
+	assert.Empty(t, resp.GetCompany().GetRedactedFields())
+
+	resp, err = h.GetCompany(as("compliance"), &pb.GetCompanyRequest{Id: id.String()})
+	require.NoError(t, err)
+	assert.Zero(t, resp.GetCompany().GetEmployees())
+	assert.Equal(t, pb.EmployeeRange_EMPLOYEE_RANGE_UNSPECIFIED, resp.GetCompany().GetEmployeeRange())
+	assert.Equal(t, "ceo@acme.test", resp.GetCompany().GetContactEmail())
+	assert.Equal(t, []string{"employees"}, resp.GetCompany().GetRedactedFields())
+	assert.EqualValues(t, 42, company.Employees, "the stored company should be left alone")
+
+	v2Company, err := h2.GetCompany(context.Background(), &pbv2.GetCompanyRequest{Id: id.String()})
+	require.NoError(t, err)
+	assert.Zero(t, v2Company.GetEmployees())
+	assert.Empty(t, v2Company.GetContactEmail())
+	assert.Equal(t, []string{"employees", "contact_email"}, v2Company.GetRedactedFields())
+
+	_, err = h.UpdateCompany(as("compliance"), &pb.UpdateCompanyRequest{Id: id.String(), Company: &pb.Company{Name: "Acme", ContactEmail: "cfo@acme.test"}})
+	require.NoError(t, err)
+	assert.Nil(t, update.Employees, "hidden fields should be left unchanged")
+	assert.Equal(t, "cfo@acme.test", *update.ContactEmail)
+
+	_, err = h2.UpdateCompany(as(), &pbv2.UpdateCompanyRequest{
+		Company:    &pbv2.Company{Id: id.String(), Name: "Acme", Employees: 7},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"name", "employees", "redacted_fields"}},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, update.Employees)
+	assert.Equal(t, "Acme", *update.Name)
+}
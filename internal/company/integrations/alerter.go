@@ -0,0 +1,269 @@
+// Package integrations alerts chat channels, through Slack and Microsoft
+// Teams incoming webhooks, about company events. Webhooks and their filters
+// are managed at runtime by admins and stored in the database.
+package integrations
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// alertQueueSize bounds the alerts waiting for delivery; further alerts
+	// are dropped.
+	alertQueueSize = 100
+	// deliveryTimeout bounds each webhook call.
+	deliveryTimeout = 10 * time.Second
+	// maxNameLength bounds webhook names.
+	maxNameLength = 100
+)
+
+// Store persists alert webhooks.
+type Store interface {
+	CreateAlertWebhook(ctx context.Context, webhook *models.AlertWebhook) error
+	ListAlertWebhooks(ctx context.Context) ([]models.AlertWebhook, error)
+	DeleteAlertWebhook(ctx context.Context, id uuid.UUID) error
+}
+
+// Producer is the event producer the Alerter forwards events to.
+type Producer interface {
+	Produce(event events.Event)
+	Replay(ctx context.Context, topic string, event events.Event) error
+}
+
+// Publisher publishes events synchronously, as the outbox relay does.
+type Publisher interface {
+	Publish(ctx context.Context, event events.Event) error
+}
+
+// alert is one event to post to one webhook.
+type alert struct {
+	webhook models.AlertWebhook
+	event   events.Event
+}
+
+// Alerter is an event producer that forwards every event to the next
+// producer and posts the events matching an alert webhook to it. Replayed
+// events are forwarded only. Webhooks are kept in memory: changes made
+// through this Alerter apply at once, those made through other replicas
+// once the webhooks are refreshed.
+type Alerter struct {
+	next   Producer
+	store  Store
+	client *http.Client
+	logger *zap.Logger
+	queue  chan alert
+
+	mu       sync.RWMutex
+	webhooks []models.AlertWebhook
+}
+
+// NewAlerter returns an Alerter forwarding events to next. Call Start to
+// load the webhooks and deliver alerts.
+func NewAlerter(next Producer, store Store, logger *zap.Logger) *Alerter {
+	return &Alerter{
+		next:   next,
+		store:  store,
+		client: &http.Client{Timeout: deliveryTimeout},
+		logger: logger.Named("alerter"),
+		queue:  make(chan alert, alertQueueSize),
+	}
+}
+
+// Start loads the webhooks, then delivers alerts and reloads the webhooks
+// every refreshInterval until ctx is done.
+func (a *Alerter) Start(ctx context.Context, refreshInterval time.Duration) error {
+	if err := a.Refresh(ctx); err != nil {
+		return err
+	}
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case al := <-a.queue:
+				a.deliver(ctx, al)
+			}
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := a.Refresh(ctx); err != nil {
+					a.logger.Warn("Failed to refresh alert webhooks", zap.Error(err))
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Refresh reloads the webhooks from the store.
+func (a *Alerter) Refresh(ctx context.Context) error {
+	webhooks, err := a.store.ListAlertWebhooks(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load alert webhooks: %w", err)
+	}
+	a.mu.Lock()
+	a.webhooks = webhooks
+	a.mu.Unlock()
+	return nil
+}
+
+// Produce implements controller.EventProducer. Suppressed events are not
+// alerted about.
+func (a *Alerter) Produce(event events.Event) {
+	a.next.Produce(event)
+	a.alert(event)
+}
+
+// Replay implements controller.EventProducer.
+func (a *Alerter) Replay(ctx context.Context, topic string, event events.Event) error {
+	return a.next.Replay(ctx, topic, event)
+}
+
+// Publishing returns a Publisher publishing through next and alerting, like
+// Produce, about the events it published, for the outbox relay.
+func (a *Alerter) Publishing(next Publisher) Publisher {
+	return &alertingPublisher{alerter: a, next: next}
+}
+
+type alertingPublisher struct {
+	alerter *Alerter
+	next    Publisher
+}
+
+func (p *alertingPublisher) Publish(ctx context.Context, event events.Event) error {
+	if err := p.next.Publish(ctx, event); err != nil {
+		return err
+	}
+	p.alerter.alert(event)
+	return nil
+}
+
+// alert queues event for the webhooks it matches, unless suppressed.
+func (a *Alerter) alert(event events.Event) {
+	if event.Suppressed {
+		return
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, webhook := range a.webhooks {
+		if !Matches(webhook, event) {
+			continue
+		}
+		select {
+		case a.queue <- alert{webhook: webhook, event: event}:
+		default:
+			a.logger.Warn("Alert queue full, dropping alert",
+				zap.String("webhook_id", webhook.ID.String()),
+				zap.String("event_type", string(event.Type)),
+			)
+		}
+	}
+}
+
+// Matches reports whether event passes the filters of webhook, so that the
+// webhook is alerted about it.
+func Matches(webhook models.AlertWebhook, event events.Event) bool {
+	if len(webhook.EventTypes) > 0 && !slices.Contains(webhook.EventTypes, string(event.Type)) {
+		return false
+	}
+	if event.Company == nil {
+		return false
+	}
+	return event.Company.Employees >= webhook.MinEmployees
+}
+
+// CreateAlertWebhook validates and stores webhook, which starts receiving
+// alerts at once. Its ID and creator are set by the Alerter.
+func (a *Alerter) CreateAlertWebhook(ctx context.Context, webhook *models.AlertWebhook) error {
+	if err := validate(webhook); err != nil {
+		return err
+	}
+	identity, _ := auth.FromContext(ctx)
+	webhook.ID = uuid.New()
+	webhook.CreatedBy = identity.UserID
+	if err := a.store.CreateAlertWebhook(ctx, webhook); err != nil {
+		return fmt.Errorf("failed to create alert webhook: %w", err)
+	}
+
+	a.mu.Lock()
+	a.webhooks = append(a.webhooks, *webhook)
+	a.mu.Unlock()
+	a.logger.Info("Alert webhook created",
+		zap.String("webhook_id", webhook.ID.String()),
+		zap.String("name", webhook.Name),
+		zap.String("created_by", webhook.CreatedBy),
+	)
+	return nil
+}
+
+// ListAlertWebhooks returns every stored webhook.
+func (a *Alerter) ListAlertWebhooks(ctx context.Context) ([]models.AlertWebhook, error) {
+	return a.store.ListAlertWebhooks(ctx)
+}
+
+// DeleteAlertWebhook removes the webhook, which stops receiving alerts at
+// once. It returns errors.ErrNotFound for unknown webhooks.
+func (a *Alerter) DeleteAlertWebhook(ctx context.Context, id uuid.UUID) error {
+	if err := a.store.DeleteAlertWebhook(ctx, id); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.webhooks = slices.DeleteFunc(a.webhooks, func(w models.AlertWebhook) bool { return w.ID == id })
+	a.mu.Unlock()
+	a.logger.Info("Alert webhook deleted", zap.String("webhook_id", id.String()))
+	return nil
+}
+
+func validate(webhook *models.AlertWebhook) error {
+	if webhook.Name == "" || len(webhook.Name) > maxNameLength {
+		return e.Newf(e.CodeWebhookNameInvalid, "webhook name must be 1 to %d characters", maxNameLength)
+	}
+	if webhook.Kind != models.WebhookSlack && webhook.Kind != models.WebhookTeams {
+		return e.Newf(e.CodeWebhookKindUnknown, "unknown webhook kind %q", webhook.Kind)
+	}
+	u, err := url.Parse(webhook.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return e.Newf(e.CodeWebhookURLInvalid, "webhook URL must be an https URL")
+	}
+	for _, t := range webhook.EventTypes {
+		if !events.EventType(t).Valid() {
+			return e.Newf(e.CodeEventTypeUnknown, "unknown event type %q", t)
+		}
+	}
+	if webhook.MinEmployees < 0 {
+		return e.Newf(e.CodeMinEmployeesNegative, "min employees must not be negative")
+	}
+	return nil
+}
+
+// RedactURL returns the scheme and host of a webhook URL, leaving out the
+// path that carries its secret token.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,192 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/auth"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// memoryStore is an in-memory Store.
+type memoryStore struct {
+	webhooks []models.AlertWebhook
+}
+
+func (s *memoryStore) CreateAlertWebhook(_ context.Context, webhook *models.AlertWebhook) error {
+	s.webhooks = append(s.webhooks, *webhook)
+	return nil
+}
+
+func (s *memoryStore) ListAlertWebhooks(context.Context) ([]models.AlertWebhook, error) {
+	return slices.Clone(s.webhooks), nil
+}
+
+func (s *memoryStore) DeleteAlertWebhook(_ context.Context, id uuid.UUID) error {
+	n := len(s.webhooks)
+	s.webhooks = slices.DeleteFunc(s.webhooks, func(w models.AlertWebhook) bool { return w.ID == id })
+	if len(s.webhooks) == n {
+		return e.ErrNotFound
+	}
+	return nil
+}
+
+// countingProducer counts the events it receives, failing those published
+// with err.
+type countingProducer struct {
+	produced, replayed, published int
+	err                           error
+}
+
+func (p *countingProducer) Produce(events.Event) { p.produced++ }
+
+func (p *countingProducer) Replay(context.Context, string, events.Event) error {
+	p.replayed++
+	return nil
+}
+
+func (p *countingProducer) Publish(context.Context, events.Event) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.published++
+	return nil
+}
+
+// chatServer records the JSON payloads posted to it, keyed by path.
+type chatServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	payloads map[string][]map[string]string
+}
+
+func newChatServer(t *testing.T) *chatServer {
+	s := &chatServer{payloads: map[string][]map[string]string{}}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		s.mu.Lock()
+		s.payloads[r.URL.Path] = append(s.payloads[r.URL.Path], payload)
+		s.mu.Unlock()
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *chatServer) received(path string) []map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.payloads[path]
+}
+
+func TestAlerter(t *testing.T) {
+	chat := newChatServer(t)
+	next := &countingProducer{}
+	a := NewAlerter(next, &memoryStore{}, zaptest.NewLogger(t))
+	a.client = chat.Client()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, a.Start(ctx, time.Hour))
+
+	adminCtx := auth.NewContext(ctx, auth.Identity{UserID: "admin", Roles: []string{auth.AdminRole}})
+	large := &models.AlertWebhook{
+		Name:         "#sales",
+		Kind:         models.WebhookSlack,
+		URL:          chat.URL + "/slack/secret",
+		EventTypes:   []string{string(events.CompanyCreated)},
+		MinEmployees: 1000,
+	}
+	require.NoError(t, a.CreateAlertWebhook(adminCtx, large))
+	assert.NotEqual(t, uuid.Nil, large.ID)
+	assert.Equal(t, "admin", large.CreatedBy)
+	all := &models.AlertWebhook{Name: "audit", Kind: models.WebhookTeams, URL: chat.URL + "/teams/secret"}
+	require.NoError(t, a.CreateAlertWebhook(adminCtx, all))
+
+	a.Produce(events.Event{Type: events.CompanyCreated, Company: &models.Company{Name: "Small", Employees: 10}, Actor: "alice"})
+	a.Produce(events.Event{Type: events.CompanyCreated, Company: &models.Company{Name: "Big <Corp>", Employees: 1500}, Actor: "bob"})
+	a.Produce(events.Event{Type: events.CompanyDeleted, Company: &models.Company{Name: "Big <Corp>", Employees: 1500}})
+	require.NoError(t, a.Replay(ctx, "rebuild", events.Event{Type: events.CompanyCreated, Company: &models.Company{Name: "Replayed", Employees: 5000}}))
+	assert.Equal(t, 3, next.produced, "every event should reach the next producer")
+	assert.Equal(t, 1, next.replayed)
+
+	require.Eventually(t, func() bool {
+		return len(chat.received("/teams/secret")) == 3 && len(chat.received("/slack/secret")) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "*Company created*: Big &lt;Corp&gt; (1500 employees) by bob", chat.received("/slack/secret")[0]["text"])
+	teams := chat.received("/teams/secret")[0]
+	assert.Equal(t, "MessageCard", teams["@type"])
+	assert.Equal(t, "Company created", teams["title"])
+	assert.Equal(t, "Small (10 employees) by alice", teams["text"])
+
+	require.NoError(t, a.DeleteAlertWebhook(ctx, all.ID))
+	a.Produce(events.Event{Type: events.CompanyCreated, Company: &models.Company{Name: "Another", Employees: 2000}})
+	require.Eventually(t, func() bool { return len(chat.received("/slack/secret")) == 2 }, 5*time.Second, 10*time.Millisecond)
+	assert.Len(t, chat.received("/teams/secret"), 3, "deleted webhooks should not be alerted")
+	assert.ErrorIs(t, a.DeleteAlertWebhook(ctx, all.ID), e.ErrNotFound)
+
+	publisher := a.Publishing(next)
+	require.NoError(t, publisher.Publish(ctx, events.Event{Type: events.CompanyCreated, Company: &models.Company{Name: "Relayed", Employees: 3000}}))
+	assert.Equal(t, 1, next.published)
+	require.Eventually(t, func() bool { return len(chat.received("/slack/secret")) == 3 }, 5*time.Second, 10*time.Millisecond)
+	next.err = errors.New("kafka down")
+	assert.ErrorIs(t, publisher.Publish(ctx, events.Event{Type: events.CompanyCreated, Company: &models.Company{Name: "Lost", Employees: 3000}}), next.err)
+	next.err = nil
+	require.NoError(t, publisher.Publish(ctx, events.Event{Type: events.CompanyCreated, Company: &models.Company{Name: "Retried", Employees: 3000}}))
+	require.Eventually(t, func() bool { return len(chat.received("/slack/secret")) == 4 }, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, chat.received("/slack/secret")[3]["text"], "Retried", "events failing to publish should not be alerted")
+}
+
+func TestAlerter_Refresh(t *testing.T) {
+	store := &memoryStore{}
+	a := NewAlerter(&countingProducer{}, store, zaptest.NewLogger(t))
+	require.NoError(t, a.Refresh(context.Background()))
+
+	// A webhook created through another replica.
+	store.webhooks = append(store.webhooks, models.AlertWebhook{ID: uuid.New(), Kind: models.WebhookSlack, URL: "https://hooks.example.com/x"})
+	a.Produce(events.Event{Type: events.CompanyCreated, Company: &models.Company{Name: "Acme"}})
+	assert.Empty(t, a.queue, "unknown webhooks should not be alerted before a refresh")
+
+	require.NoError(t, a.Refresh(context.Background()))
+	a.Produce(events.Event{Type: events.CompanyCreated, Company: &models.Company{Name: "Acme"}})
+	assert.Len(t, a.queue, 1)
+}
+
+func TestAlerter_CreateInvalid(t *testing.T) {
+	a := NewAlerter(&countingProducer{}, &memoryStore{}, zaptest.NewLogger(t))
+	valid := models.AlertWebhook{Name: "#sales", Kind: models.WebhookSlack, URL: "https://hooks.slack.com/services/x"}
+	tests := map[string]func(w *models.AlertWebhook){
+		"no name":            func(w *models.AlertWebhook) { w.Name = "" },
+		"unknown kind":       func(w *models.AlertWebhook) { w.Kind = "discord" },
+		"plain http":         func(w *models.AlertWebhook) { w.URL = "http://hooks.slack.com/services/x" },
+		"no host":            func(w *models.AlertWebhook) { w.URL = "https:///services/x" },
+		"unknown event type": func(w *models.AlertWebhook) { w.EventTypes = []string{"company_renamed"} },
+		"negative employees": func(w *models.AlertWebhook) { w.MinEmployees = -1 },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			webhook := valid
+			mutate(&webhook)
+			assert.ErrorIs(t, a.CreateAlertWebhook(context.Background(), &webhook), e.ErrInvalidInput)
+		})
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	assert.Equal(t, "https://hooks.slack.com", RedactURL("https://hooks.slack.com/services/T0/B0/secret"))
+	assert.Equal(t, "", RedactURL("://bad"))
+}
@@ -0,0 +1,103 @@
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"go.uber.org/zap"
+)
+
+// eventTitles are the headlines of the alerts about each event type.
+var eventTitles = map[events.EventType]string{
+	events.CompanyCreated:       "Company created",
+	events.CompanyUpdated:       "Company updated",
+	events.CompanyDeleted:       "Company deleted",
+	events.CompanyStatusChanged: "Company status changed",
+	events.CompanyArchived:      "Company archived",
+	events.CompanyEnriched:      "Company enriched",
+	events.CompanyErased:        "Company data erased",
+	events.CompanyNoteAdded:     "Company note added",
+	events.CompanyNoteDeleted:   "Company note deleted",
+}
+
+// slackEscaper escapes the characters Slack treats as markup in text.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// deliver posts al to its webhook. Failures are logged, never retried, and
+// never include the webhook URL.
+func (a *Alerter) deliver(ctx context.Context, al alert) {
+	logger := a.logger.With(
+		zap.String("webhook_id", al.webhook.ID.String()),
+		zap.String("event_type", string(al.event.Type)),
+	)
+	body, err := Payload(al.webhook.Kind, al.event)
+	if err != nil {
+		logger.Error("Failed to encode alert", zap.Error(err))
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, al.webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		logger.Error("Invalid alert webhook URL", zap.String("host", RedactURL(al.webhook.URL)))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		// Drop the URL, which carries the webhook's secret, from the error.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		logger.Warn("Failed to post alert", zap.String("host", RedactURL(al.webhook.URL)), zap.Error(err))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		logger.Warn("Alert webhook rejected alert", zap.Int("status", resp.StatusCode))
+	}
+}
+
+// summary describes event in one line, e.g. "Acme (1200 employees) by alice".
+func summary(event events.Event) string {
+	company := event.Company
+	text := fmt.Sprintf("%s (%d employees)", company.Name, company.Employees)
+	if event.Type == events.CompanyStatusChanged {
+		text += fmt.Sprintf(", now %s", company.Status)
+	}
+	if event.Actor != "" {
+		text += " by " + event.Actor
+	}
+	return text
+}
+
+// Payload returns the JSON body posted to a webhook of the given kind about
+// event.
+func Payload(kind models.WebhookKind, event events.Event) ([]byte, error) {
+	return json.Marshal(payload(kind, event))
+}
+
+// payload returns the message posted to a webhook of the given kind.
+func payload(kind models.WebhookKind, event events.Event) interface{} {
+	title := eventTitles[event.Type]
+	if kind == models.WebhookTeams {
+		// Teams incoming webhooks accept legacy MessageCards.
+		return map[string]string{
+			"@type":    "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary":  title,
+			"title":    title,
+			"text":     summary(event),
+		}
+	}
+	return map[string]string{
+		"text": fmt.Sprintf("*%s*: %s", title, slackEscaper.Replace(summary(event))),
+	}
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookKind selects the chat service an alert webhook posts to, and so
+// the payload format.
+type WebhookKind string
+
+const (
+	WebhookSlack WebhookKind = "slack"
+	WebhookTeams WebhookKind = "teams"
+)
+
+// AlertWebhook is an incoming webhook of a chat service alerted about the
+// company events matching its filters.
+type AlertWebhook struct {
+	// ID is the unique identifier for the webhook.
+	ID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	// Name describes the channel alerted, e.g. "#sales".
+	Name string
+	// Kind is the chat service the URL belongs to.
+	Kind WebhookKind
+	// URL is the incoming webhook URL. It embeds a secret token.
+	URL string
+	// EventTypes lists the event types alerted about; empty means all.
+	EventTypes []string `gorm:"serializer:json"`
+	// MinEmployees only alerts about companies with at least this many
+	// employees; 0 matches every company.
+	MinEmployees int
+	// CreatedBy is the user ID of the admin who registered the webhook.
+	CreatedBy string
+	// CreatedAt records when the webhook was registered.
+	CreatedAt time.Time
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKey is a credential for machine clients. Only the SHA-256 hash of the
+// key is stored; the plaintext is shown once at creation.
+type APIKey struct {
+	// ID is the unique identifier for the key.
+	ID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	// Name describes the integration owning the key.
+	Name string
+	// KeyHash is the hex-encoded SHA-256 hash of the plaintext key.
+	KeyHash string `gorm:"uniqueIndex"`
+	// Scopes lists the permissions granted to the key.
+	Scopes []string `gorm:"serializer:json"`
+	// RateLimit is the number of requests allowed per minute; 0 means unlimited.
+	RateLimit int
+	// CreatedAt records when the key was issued.
+	CreatedAt time.Time
+	// RevokedAt is set once the key may no longer be used.
+	RevokedAt *time.Time
+}
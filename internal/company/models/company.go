@@ -1,14 +1,17 @@
 // Package models defines the core domain models for the Company entity.
-// It includes definitions for Company, CompanyUpdate, and the CompanyType enumeration.
+// It includes definitions for Company, CompanyUpdate, and the CompanyType and
+// CompanyStatus enumerations.
 package models
 
 import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-// CompanyType represents the type of a company.
+// CompanyType represents the type of a company: the code of an entry of
+// the company type taxonomy. The constants are the built-in types.
 type CompanyType string
 
 const (
@@ -19,24 +22,146 @@ const (
 	SoleProprietorship CompanyType = "SOLE_PROPRIETORSHIP"
 )
 
+// Valid reports whether t is one of the built-in company types. Other
+// types are checked against the taxonomy.
+func (t CompanyType) Valid() bool {
+	switch t {
+	case Corporations, NonProfit, Cooperative, SoleProprietorship:
+		return true
+	}
+	return false
+}
+
+// CompanyStatus is the lifecycle state of a company.
+type CompanyStatus string
+
+const (
+	// StatusDraft is a company being prepared that is not yet in use.
+	StatusDraft     CompanyStatus = "DRAFT"
+	StatusActive    CompanyStatus = "ACTIVE"
+	StatusSuspended CompanyStatus = "SUSPENDED"
+	// StatusArchived is final: archived companies cannot change status.
+	StatusArchived CompanyStatus = "ARCHIVED"
+)
+
+// statusTransitions lists the statuses each status may change to.
+var statusTransitions = map[CompanyStatus][]CompanyStatus{
+	StatusDraft:     {StatusActive, StatusArchived},
+	StatusActive:    {StatusSuspended, StatusArchived},
+	StatusSuspended: {StatusActive, StatusArchived},
+	StatusArchived:  nil,
+}
+
+// Valid reports whether s is a known status.
+func (s CompanyStatus) Valid() bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether a company in status s may change to next.
+// Keeping the current status is always allowed.
+func (s CompanyStatus) CanTransitionTo(next CompanyStatus) bool {
+	if s == next {
+		return true
+	}
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// EmployeeRange is a headcount band derived from a company's employee count,
+// for clients that only care about size.
+type EmployeeRange string
+
+const (
+	// EmployeeRangeUnknown is used when the employee count is zero.
+	EmployeeRangeUnknown EmployeeRange = ""
+	Employees1To10       EmployeeRange = "EMPLOYEES_1_10"
+	Employees11To50      EmployeeRange = "EMPLOYEES_11_50"
+	Employees51To200     EmployeeRange = "EMPLOYEES_51_200"
+	Employees201To500    EmployeeRange = "EMPLOYEES_201_500"
+	Employees501To1000   EmployeeRange = "EMPLOYEES_501_1000"
+	Employees1001To5000  EmployeeRange = "EMPLOYEES_1001_5000"
+	Employees5001Plus    EmployeeRange = "EMPLOYEES_5001_PLUS"
+)
+
+// employeeRangeBounds lists the upper bound of every range but the last.
+var employeeRangeBounds = []struct {
+	max   int
+	value EmployeeRange
+}{
+	{10, Employees1To10},
+	{50, Employees11To50},
+	{200, Employees51To200},
+	{500, Employees201To500},
+	{1000, Employees501To1000},
+	{5000, Employees1001To5000},
+}
+
+// EmployeeRangeFor returns the range containing employees.
+func EmployeeRangeFor(employees int) EmployeeRange {
+	if employees <= 0 {
+		return EmployeeRangeUnknown
+	}
+	for _, b := range employeeRangeBounds {
+		if employees <= b.max {
+			return b.value
+		}
+	}
+	return Employees5001Plus
+}
+
 // Company defines the domain model for a company entity.
 type Company struct {
 	// ID is the unique identifier for the company.
 	ID uuid.UUID
-	// Name is the company’s name.
-	Name string
+	// Name is the company’s name, unique among live companies. The
+	// functional index serves case-insensitive lookups by name.
+	Name string `gorm:"index:idx_companies_lower_name,expression:lower(name);uniqueIndex:idx_companies_name,where:deleted_at IS NULL"`
 	// Description provides details about the company.
 	Description string
 	// Employees is the number of employees in the company.
 	Employees int
+	// EmployeeRange is the headcount band of Employees, kept in sync on write.
+	EmployeeRange EmployeeRange `gorm:"size:32;index"`
 	// Registered indicates whether the company is officially registered.
 	Registered bool
+	// Status is the lifecycle state. Rows created before it was introduced
+	// default to ACTIVE.
+	Status CompanyStatus `gorm:"size:16;not null;default:ACTIVE;index"`
 	// Type specifies the category/type of the company.
 	Type CompanyType
+	// ExternalRef is an optional key assigned by an external system (e.g. an
+	// ERP), unique among companies that set it.
+	ExternalRef string `gorm:"size:255;uniqueIndex:idx_companies_live_external_ref,where:external_ref <> '' AND deleted_at IS NULL"`
+	// ContactEmail is the address of the company's contact person. It is
+	// encrypted at rest and left out of events.
+	ContactEmail string `gorm:"serializer:encrypted" json:"-"`
+	// Metadata holds attributes integrators attach to the company, keyed by
+	// names of their choosing. On PostgreSQL a GIN index serves metadata
+	// filters.
+	Metadata Metadata `gorm:"type:jsonb;serializer:json" json:",omitempty"`
+	// CreatedBy is the user ID of the caller that created the company, who
+	// owns it. The index serves listing a caller's own companies.
+	CreatedBy string `gorm:"index"`
+	// UpdatedBy is the user ID of the caller that last modified the company.
+	UpdatedBy string
+	// TenantID is the tenant of the caller that created the company, whose
+	// quota it counts against; empty for callers without a tenant.
+	TenantID string `gorm:"size:64;index" json:"-"`
 	// CreatedAt records the timestamp when the company was created.
 	CreatedAt time.Time
 	// UpdatedAt records the timestamp when the company was last updated.
 	UpdatedAt time.Time
+	// ErasedAt records when the personal data of the company was erased on
+	// request; nil when it never was.
+	ErasedAt *time.Time `json:",omitempty"`
+	// DeletedAt marks the company as soft-deleted; it is hidden from regular
+	// queries until purged.
+	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
 
 // CompanyUpdate represents the fields that can be updated for a Company.
@@ -50,8 +175,128 @@ type CompanyUpdate struct {
 	Description *string
 	// Employees is the new employee count.
 	Employees *int
+	// EmployeeRange is derived from Employees by the service.
+	EmployeeRange *EmployeeRange
 	// Registered is the updated registration status.
 	Registered *bool
+	// Status is the new lifecycle state; the service checks the transition.
+	Status *CompanyStatus
 	// Type is the updated company type.
 	Type *CompanyType
+	// ExternalRef is the new external reference.
+	ExternalRef *string
+	// ContactEmail is the new contact address.
+	ContactEmail *string `gorm:"serializer:encrypted"`
+	// Metadata is merged into the stored metadata: entries with an empty
+	// value remove the key, others set it, and keys not listed are kept.
+	Metadata Metadata `gorm:"-"`
+	// UpdatedBy is the user ID of the caller making the change.
+	UpdatedBy string
+}
+
+// Metadata maps the names of integrator-defined attributes to their values.
+type Metadata map[string]string
+
+// Merge returns m with patch applied like CompanyUpdate.Metadata, or nil
+// when no entry is left. m is not modified.
+func (m Metadata) Merge(patch Metadata) Metadata {
+	merged := make(Metadata, len(m)+len(patch))
+	for key, value := range m {
+		merged[key] = value
+	}
+	for key, value := range patch {
+		if value == "" {
+			delete(merged, key)
+		} else {
+			merged[key] = value
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// FieldChange records the value of a single Company field before and after
+// an update.
+type FieldChange struct {
+	Old interface{}
+	New interface{}
+}
+
+// CompanyFilter selects companies when listing. Empty fields match every
+// company.
+type CompanyFilter struct {
+	// EmployeeRanges restricts the result to companies in these ranges.
+	EmployeeRanges []EmployeeRange
+	// Statuses restricts the result to companies in these statuses.
+	Statuses []CompanyStatus
+	// UpdatedBefore restricts the result to companies last updated before
+	// it.
+	UpdatedBefore time.Time
+	// NameContains restricts the result to companies whose name contains
+	// it, ignoring case.
+	NameContains string
+	// CreatedBy restricts the result to companies created by this user ID.
+	CreatedBy string
+	// HasExternalRef restricts the result to companies carrying an external
+	// reference.
+	HasExternalRef bool
+	// Metadata restricts the result to companies carrying every one of
+	// these metadata entries.
+	Metadata Metadata
+}
+
+// CreateOptions adjusts how a company is created.
+type CreateOptions struct {
+	// Force creates the company even when its name is similar to an
+	// existing one.
+	Force bool
+	// ValidateOnly runs every check and reports the result without
+	// persisting the company or emitting events.
+	ValidateOnly bool
+	// SuppressEvents records the change without publishing its events, for
+	// bulk back-fills. Only admins may set it.
+	SuppressEvents bool
+}
+
+// UpdateOptions adjusts how a company is updated.
+type UpdateOptions struct {
+	// ValidateOnly runs every check and reports the result without
+	// persisting the change or emitting events.
+	ValidateOnly bool
+	// SuppressEvents records the change without publishing its events, for
+	// bulk back-fills. Only admins may set it.
+	SuppressEvents bool
+}
+
+// ApplyOptions adjusts how a desired state is applied.
+type ApplyOptions struct {
+	// PlanOnly computes the changes without applying them.
+	PlanOnly bool
+	// Prune deletes the companies carrying an external reference that is
+	// not in the desired state.
+	Prune bool
+	// SuppressEvents applies the changes without publishing their events,
+	// for bulk back-fills. Only admins may set it.
+	SuppressEvents bool
+}
+
+// ApplyAction is what applying a desired state does to one company.
+type ApplyAction string
+
+const (
+	ApplyCreate ApplyAction = "CREATE"
+	ApplyUpdate ApplyAction = "UPDATE"
+	ApplyDelete ApplyAction = "DELETE"
+)
+
+// CompanyChange is one change made, or planned, to converge the companies to
+// a desired state.
+type CompanyChange struct {
+	Action ApplyAction
+	// Company is the company after the change, or before it was deleted.
+	Company *Company
+	// Fields names the fields an update changes, sorted.
+	Fields []string
 }
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CompanyEvent is the stored copy of an event published for a company. The
+// history lets events be replayed to rebuild downstream read models.
+type CompanyEvent struct {
+	// ID is the EventID the event was published with.
+	ID uuid.UUID `gorm:"type:uuid;primaryKey;index:idx_company_events_history,priority:3"`
+	// Type is the event type, e.g. "company_updated".
+	Type string `gorm:"size:64;index"`
+	// CompanyID identifies the company the event refers to. Its index with
+	// CreatedAt and ID serves the history of a company in order.
+	CompanyID uuid.UUID `gorm:"type:uuid;index:idx_company_events_history,priority:1"`
+	// Actor is the user ID of the caller that triggered the event.
+	Actor string
+	// Company is the company state carried by the event.
+	Company Company `gorm:"serializer:json"`
+	// Changes holds the per-field diff of update events.
+	Changes map[string]FieldChange `gorm:"serializer:json"`
+	// Validations holds the verdicts of the external validators that
+	// approved the change, for create and update events.
+	Validations []ValidationResult `gorm:"serializer:json"`
+	// Suppressed reports that the event was recorded but not published, as
+	// the change was made with events suppressed.
+	Suppressed bool
+	// CreatedAt records when the event was published.
+	CreatedAt time.Time `gorm:"index;index:idx_company_events_history,priority:2"`
+}
+
+// CompanyEventFilter selects stored company events. Empty fields match
+// every event.
+type CompanyEventFilter struct {
+	// CompanyIDs restricts the events to these companies.
+	CompanyIDs []uuid.UUID
+	// Types restricts the events to these event types.
+	Types []string
+	// Since excludes events published before this time.
+	Since time.Time
+	// Until excludes events published at or after this time.
+	Until time.Time
+	// After, when set, excludes the events up to and including the one at
+	// this position, so iteration can resume where it stopped.
+	After *CompanyEventPosition
+}
+
+// CompanyEventPosition is the place of an event in the history, which is
+// ordered by publication time, then ID.
+type CompanyEventPosition struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Position returns the place of e in the history.
+func (e *CompanyEvent) Position() CompanyEventPosition {
+	return CompanyEventPosition{CreatedAt: e.CreatedAt, ID: e.ID}
+}
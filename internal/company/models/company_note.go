@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CompanyNote is a note recorded on a company, e.g. by an account manager,
+// shown in the company's timeline.
+type CompanyNote struct {
+	// ID is the unique identifier for the note.
+	ID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	// CompanyID identifies the company the note is about. The index serves
+	// listing a company's notes.
+	CompanyID uuid.UUID `gorm:"type:uuid;index:idx_company_notes_timeline,priority:1"`
+	// Author is the user ID of the caller that added the note.
+	Author string
+	// Body is the text of the note, in Markdown.
+	Body string `gorm:"type:text"`
+	// CreatedAt records when the note was added.
+	CreatedAt time.Time `gorm:"index:idx_company_notes_timeline,priority:2"`
+	// UpdatedAt records when the note was last modified.
+	UpdatedAt time.Time
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// CompanyTypeDefinition is an entry of the company type taxonomy admins
+// manage. Companies may only be given the types it holds.
+type CompanyTypeDefinition struct {
+	// Code is the value stored as Company.Type, e.g. "PARTNERSHIP": 1 to 32
+	// upper-case letters, digits or '_', starting with a letter.
+	Code CompanyType `gorm:"primaryKey;size:32"`
+	// Name is the label shown to users, e.g. "Partnership".
+	Name string `gorm:"size:100;not null"`
+	// Description explains when the type applies.
+	Description string `gorm:"size:3000"`
+	// Builtin marks the types the CompanyType enum of the API maps to,
+	// which cannot be deleted.
+	Builtin bool
+	// CreatedBy and UpdatedBy are the user IDs of the admins who added the
+	// type and last changed it; empty for the built-in types.
+	CreatedBy string
+	UpdatedBy string
+	// CreatedAt records when the type was added.
+	CreatedAt time.Time
+	// UpdatedAt records when the type was last changed.
+	UpdatedAt time.Time
+}
+
+// BuiltinCompanyTypes are the types the taxonomy starts with, one per value
+// of the CompanyType enum of the API.
+var BuiltinCompanyTypes = []CompanyTypeDefinition{
+	{Code: Corporations, Name: "Corporation", Builtin: true},
+	{Code: NonProfit, Name: "Non-profit", Builtin: true},
+	{Code: Cooperative, Name: "Cooperative", Builtin: true},
+	{Code: SoleProprietorship, Name: "Sole proprietorship", Builtin: true},
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// ComplianceRecord is the stored copy of a mutating call made while
+// compliance mode is enabled. Records are only ever appended, and removed
+// once older than the retention period.
+type ComplianceRecord struct {
+	// ID orders the records by the time they were appended.
+	ID uint64 `gorm:"primaryKey;autoIncrement"`
+	// Method is the full gRPC method name called.
+	Method string `gorm:"size:128;index"`
+	// RequestID is the x-request-id of the call.
+	RequestID string `gorm:"size:64;index"`
+	// UserID identifies the caller; empty for anonymous calls.
+	UserID string `gorm:"size:128;index"`
+	// Code is the gRPC status code the call ended with, e.g. "OK".
+	Code string `gorm:"size:32"`
+	// Error is the status message of failed calls.
+	Error string
+	// Request and Response are the JSON payloads of the call, with the
+	// configured fields redacted. Response is empty for failed calls.
+	Request  string `gorm:"type:text"`
+	Response string `gorm:"type:text"`
+	// CreatedAt records when the call completed.
+	CreatedAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DuplicateStatus is the state of a DuplicateSuggestion.
+type DuplicateStatus string
+
+const (
+	// DuplicateOpen suggestions await an admin's decision.
+	DuplicateOpen DuplicateStatus = "OPEN"
+	// DuplicateMerged suggestions were resolved by merging the pair.
+	DuplicateMerged DuplicateStatus = "MERGED"
+	// DuplicateDismissed suggestions were resolved as not duplicates; the
+	// pair is not suggested again.
+	DuplicateDismissed DuplicateStatus = "DISMISSED"
+)
+
+// Valid reports whether s is a known status.
+func (s DuplicateStatus) Valid() bool {
+	switch s {
+	case DuplicateOpen, DuplicateMerged, DuplicateDismissed:
+		return true
+	}
+	return false
+}
+
+// DuplicateResolution is an admin's decision on a DuplicateSuggestion.
+type DuplicateResolution string
+
+const (
+	// ResolveMerge merges the pair into one of its companies.
+	ResolveMerge DuplicateResolution = "MERGE"
+	// ResolveDismiss records that the pair are distinct companies.
+	ResolveDismiss DuplicateResolution = "DISMISS"
+)
+
+// DuplicateSuggestion is a pair of live companies of the same tenant that
+// are likely the same company, found by the duplicate detection job.
+type DuplicateSuggestion struct {
+	// ID is the unique identifier for the suggestion.
+	ID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	// CompanyID is the company of the pair created first, kept by default
+	// when merging, and DuplicateID the other. The pair is unique.
+	CompanyID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_duplicate_suggestions_pair,priority:1"`
+	DuplicateID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_duplicate_suggestions_pair,priority:2;index"`
+	// TenantID is the tenant owning both companies.
+	TenantID string `gorm:"size:64;index"`
+	// NameSimilarity is the trigram similarity (0-1) of the names.
+	NameSimilarity float64
+	// SameCountry and SameWebsite tell whether both companies carry the
+	// same "country" or "website" metadata entry.
+	SameCountry bool
+	SameWebsite bool
+	// Status tells whether the suggestion awaits a decision.
+	Status DuplicateStatus `gorm:"size:16;not null;index"`
+	// ResolvedBy is the user ID of the admin who resolved the suggestion.
+	ResolvedBy string
+	// ResolvedAt records when the suggestion was resolved; nil while open.
+	ResolvedAt *time.Time
+	// CreatedAt records when the pair was first suggested.
+	CreatedAt time.Time
+	// UpdatedAt records when detection last found the pair, or when it was
+	// resolved.
+	UpdatedAt time.Time
+}
+
+// DuplicateSuggestionFilter narrows the suggestions listed; zero fields do
+// not filter.
+type DuplicateSuggestionFilter struct {
+	Status   DuplicateStatus
+	TenantID string
+	// CompanyID matches suggestions with the company on either side.
+	CompanyID uuid.UUID
+}
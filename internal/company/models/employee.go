@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Employee is a person working for a company. The Employees count of a
+// company with employee records is derived from them.
+type Employee struct {
+	// ID is the unique identifier for the employee.
+	ID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	// CompanyID identifies the company the employee works for. The index
+	// serves listing and counting a company's employees.
+	CompanyID uuid.UUID `gorm:"type:uuid;index"`
+	// Name is the employee's full name.
+	Name string `gorm:"size:100"`
+	// Title is the employee's job title.
+	Title string `gorm:"size:100"`
+	// Email is the employee's work address; optional. It is encrypted at
+	// rest like company contact emails.
+	Email string `gorm:"serializer:encrypted" json:"-"`
+	// CreatedBy is the user ID of the caller that added the employee.
+	CreatedBy string
+	// UpdatedBy is the user ID of the caller that last modified the employee.
+	UpdatedBy string
+	// CreatedAt records when the employee was added.
+	CreatedAt time.Time `gorm:"index"`
+	// UpdatedAt records when the employee was last modified.
+	UpdatedAt time.Time
+}
+
+// EmployeeUpdate represents the fields that can be updated for an
+// Employee. Nil fields are left unchanged.
+type EmployeeUpdate struct {
+	// ID is the unique identifier for the employee to update.
+	ID uuid.UUID
+	// CompanyID is the company the employee must belong to.
+	CompanyID uuid.UUID `gorm:"-"`
+	Name      *string
+	Title     *string
+	Email     *string `gorm:"serializer:encrypted"`
+	// UpdatedBy is the user ID of the caller making the change.
+	UpdatedBy string
+}
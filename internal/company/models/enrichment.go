@@ -0,0 +1,12 @@
+package models
+
+// Enrichment holds company attributes found by enrichment providers, such
+// as business registries. Nil fields were not found.
+type Enrichment struct {
+	Description *string
+	Employees   *int
+	Type        *CompanyType
+	Registered  *bool
+	// Sources names the providers that found the attributes.
+	Sources []string
+}
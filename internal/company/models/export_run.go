@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExportKind tells whether an export holds every company or only the
+// changes since the previous export.
+type ExportKind string
+
+const (
+	// ExportFull exports every live company.
+	ExportFull ExportKind = "FULL"
+	// ExportIncremental exports the companies created, updated or deleted
+	// since the previous export.
+	ExportIncremental ExportKind = "INCREMENTAL"
+)
+
+// ExportRun records a completed export of companies to object storage. The
+// Until of the latest run is where the next incremental export starts.
+type ExportRun struct {
+	// ID is the unique identifier for the export.
+	ID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	// Kind tells whether the export is full or incremental.
+	Kind ExportKind `gorm:"size:16;not null"`
+	// Since is the start of the changes an incremental export holds; nil
+	// for full exports.
+	Since *time.Time
+	// Until is the end of the changes the export holds. The index serves
+	// finding the latest run.
+	Until time.Time `gorm:"not null;index"`
+	// Companies is the number of companies exported.
+	Companies int64
+	// Files is the number of data files written.
+	Files int
+	// Manifest is the URL of the manifest listing the data files, e.g.
+	// s3://bucket/companies/full/20250301T120000Z/manifest.json.
+	Manifest string
+	// CreatedBy is the user ID of the caller that requested the export;
+	// empty for scheduled exports.
+	CreatedBy string
+	// CreatedAt records when the export completed.
+	CreatedAt time.Time
+}
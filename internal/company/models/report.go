@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// MonthlyCountFilter selects the companies counted per month.
+type MonthlyCountFilter struct {
+	// Since and Until bound the creation times counted, Until excluded.
+	Since time.Time
+	Until time.Time
+	// TenantID restricts the count to the companies of a tenant; empty
+	// counts every company.
+	TenantID string
+	// MetadataKey groups the companies by the value of their metadata entry
+	// of that key, e.g. "country", rather than by type.
+	MetadataKey string
+}
+
+// MonthlyCount is the number of companies created in a month with the same
+// type, or value of the metadata entry grouped by.
+type MonthlyCount struct {
+	// Month is the calendar month in UTC, formatted like "2024-01".
+	Month string
+	Key   string
+	Count int64
+}
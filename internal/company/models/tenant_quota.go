@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// TenantQuota limits what the callers of a tenant may do. A limit of 0
+// means unlimited.
+type TenantQuota struct {
+	// TenantID is the tenant claim of the callers' tokens.
+	TenantID string `gorm:"primaryKey;size:64"`
+	// MaxCompanies is the number of live companies the tenant may own.
+	MaxCompanies int
+	// MaxMutationsPerMinute is the number of creates, updates and deletes
+	// the tenant may make per minute.
+	MaxMutationsPerMinute int
+	// UpdatedBy is the user ID of the admin who last set the quota.
+	UpdatedBy string
+	// UpdatedAt records when the quota was last set.
+	UpdatedAt time.Time
+}
+
+// TenantUsage is what a tenant currently uses of its quota.
+type TenantUsage struct {
+	// Companies is the number of live companies the tenant owns.
+	Companies int64
+	// MutationsThisMinute is the number of mutations made in the current
+	// minute.
+	MutationsThisMinute int
+}
@@ -0,0 +1,16 @@
+package models
+
+// ValidationResult is the verdict of one external validator, such as a
+// sanctions screening service, on a company change. It is recorded with
+// the event of the change.
+type ValidationResult struct {
+	// Hook names the validator.
+	Hook string
+	// Allowed reports whether the validator let the change through.
+	Allowed bool
+	// Reason is the explanation the validator gave, if any.
+	Reason string
+	// Error describes why the validator gave no verdict, when its failure
+	// policy let the change through anyway.
+	Error string
+}
@@ -0,0 +1,152 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/gartstein/xm/internal/notifier"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// keyTimeFormat names delivered reports after the time they were run.
+const keyTimeFormat = "20060102T150405Z"
+
+// scheduleNamePattern matches valid schedule names, which end up in job
+// names and object keys.
+var scheduleNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Schedule delivers a report on a cron schedule, to object storage, by
+// email or both.
+type Schedule struct {
+	// Name names the deliveries, e.g. "monthly-growth".
+	Name   string `yaml:"NAME"`
+	Report string `yaml:"REPORT"`
+	// Schedule is a standard five-field cron expression or a descriptor
+	// such as "@daily".
+	Schedule string `yaml:"SCHEDULE"`
+	// Format is "csv", the default, or "json".
+	Format string `yaml:"FORMAT"`
+	// Period is the length of the time range reported on, ending when the
+	// report runs; 0 keeps the default of the report.
+	Period   time.Duration `yaml:"PERIOD"`
+	TenantID string        `yaml:"TENANT_ID"`
+	Limit    int           `yaml:"LIMIT"`
+	// Store uploads the report to object storage.
+	Store bool `yaml:"STORE"`
+	// EmailTo emails the report to these addresses.
+	EmailTo []string `yaml:"EMAIL_TO"`
+}
+
+// Validate checks the settings of s that do not depend on the delivery
+// channels configured.
+func (s Schedule) Validate() error {
+	if !scheduleNamePattern.MatchString(s.Name) {
+		return fmt.Errorf("invalid report schedule name %q", s.Name)
+	}
+	if _, ok := Lookup(s.Report); !ok {
+		return fmt.Errorf("report schedule %s: unknown report %q", s.Name, s.Report)
+	}
+	if _, err := cron.ParseStandard(s.Schedule); err != nil {
+		return fmt.Errorf("report schedule %s: invalid schedule %q: %w", s.Name, s.Schedule, err)
+	}
+	if _, err := s.format(); err != nil {
+		return fmt.Errorf("report schedule %s: unknown format %q", s.Name, s.Format)
+	}
+	if s.Period < 0 || s.Limit < 0 {
+		return fmt.Errorf("report schedule %s: negative period or limit", s.Name)
+	}
+	if !s.Store && len(s.EmailTo) == 0 {
+		return fmt.Errorf("report schedule %s: neither stored nor emailed", s.Name)
+	}
+	return nil
+}
+
+func (s Schedule) format() (Format, error) {
+	if s.Format == "" {
+		return CSV, nil
+	}
+	return ParseFormat(s.Format)
+}
+
+// ObjectStore stores delivered reports; *export.S3Store implements it.
+type ObjectStore interface {
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+	URL(key string) string
+}
+
+// Deliverer delivers the reports of schedules.
+type Deliverer struct {
+	runner *Runner
+	store  ObjectStore
+	prefix string
+	mailer notifier.Mailer
+	logger *zap.Logger
+}
+
+// NewDeliverer returns a Deliverer running reports with runner, storing
+// them under prefix in store and emailing them with mailer. Either may be
+// nil when no schedule uses it.
+func NewDeliverer(runner *Runner, store ObjectStore, prefix string, mailer notifier.Mailer, logger *zap.Logger) *Deliverer {
+	return &Deliverer{runner: runner, store: store, prefix: prefix, mailer: mailer, logger: logger.Named("report")}
+}
+
+// Deliver runs the report of s and delivers it. Stored reports are named
+// <prefix>/<name>/<run time>.<format>; emails carry the report in their
+// body, with the location of the stored copy.
+func (d *Deliverer) Deliver(ctx context.Context, s Schedule) error {
+	format, err := s.format()
+	if err != nil {
+		return err
+	}
+	now := d.runner.now().UTC()
+	p := Params{Until: now, TenantID: s.TenantID, Limit: s.Limit}
+	if s.Period > 0 {
+		p.Since = now.Add(-s.Period)
+	}
+	var body bytes.Buffer
+	err = d.runner.Run(ctx, s.Report, p, format, func(line []byte) error {
+		body.Write(line)
+		body.WriteByte('\n')
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var location string
+	if s.Store {
+		if d.store == nil {
+			return errors.New("no object storage to store reports in")
+		}
+		key := path.Join(d.prefix, s.Name, now.Format(keyTimeFormat)+"."+string(format))
+		if err := d.store.Put(ctx, key, body.Bytes(), format.ContentType()); err != nil {
+			return fmt.Errorf("failed to store report %s: %w", s.Name, err)
+		}
+		location = d.store.URL(key)
+	}
+	if len(s.EmailTo) > 0 {
+		if d.mailer == nil {
+			return errors.New("no mailer to email reports with")
+		}
+		intro := fmt.Sprintf("Report %s (%s) as of %s.\n", s.Name, s.Report, now.Format(time.RFC3339))
+		if location != "" {
+			intro += fmt.Sprintf("Stored at %s.\n", location)
+		}
+		err := d.mailer.Send(ctx, notifier.Message{
+			To:      s.EmailTo,
+			Subject: fmt.Sprintf("Report %s", s.Name),
+			Body:    intro + "\n" + body.String(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to email report %s: %w", s.Name, err)
+		}
+	}
+	d.logger.Info("Delivered report", zap.String("schedule", s.Name), zap.String("report", s.Report), zap.String("location", location), zap.Int("recipients", len(s.EmailTo)))
+	return nil
+}
@@ -0,0 +1,83 @@
+package report
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gartstein/xm/internal/notifier"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+type memoryStore struct {
+	objects      map[string]string
+	contentTypes map[string]string
+}
+
+func (m *memoryStore) Put(_ context.Context, key string, body []byte, contentType string) error {
+	m.objects[key] = string(body)
+	m.contentTypes[key] = contentType
+	return nil
+}
+
+func (m *memoryStore) URL(key string) string {
+	return "s3://reports/" + key
+}
+
+type recordingMailer struct {
+	sent []notifier.Message
+}
+
+func (m *recordingMailer) Send(_ context.Context, msg notifier.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestScheduleValidate(t *testing.T) {
+	valid := Schedule{Name: "monthly-growth", Report: "growth", Schedule: "@monthly", Store: true}
+	require.NoError(t, valid.Validate())
+	for _, tc := range []struct {
+		change func(*Schedule)
+		err    string
+	}{
+		{func(s *Schedule) { s.Name = "Monthly Growth" }, "invalid report schedule name"},
+		{func(s *Schedule) { s.Report = "churn" }, `unknown report "churn"`},
+		{func(s *Schedule) { s.Schedule = "monthly" }, `invalid schedule "monthly"`},
+		{func(s *Schedule) { s.Format = "xlsx" }, `unknown format "xlsx"`},
+		{func(s *Schedule) { s.Limit = -1 }, "negative period or limit"},
+		{func(s *Schedule) { s.Store = false }, "neither stored nor emailed"},
+	} {
+		s := valid
+		tc.change(&s)
+		assert.ErrorContains(t, s.Validate(), tc.err)
+	}
+}
+
+func TestDeliverer_Deliver(t *testing.T) {
+	runner, _ := newTestRunner(t)
+	store := &memoryStore{objects: map[string]string{}, contentTypes: map[string]string{}}
+	mailer := &recordingMailer{}
+	deliverer := NewDeliverer(runner, store, "reports", mailer, zaptest.NewLogger(t))
+
+	err := deliverer.Deliver(context.Background(), Schedule{
+		Name:    "types",
+		Report:  "type_breakdown",
+		Store:   true,
+		EmailTo: []string{"ops@example.com"},
+	})
+	require.NoError(t, err)
+	const key = "reports/types/20260401T000000Z.csv"
+	assert.Equal(t, "type,status,companies\nCOOPERATIVE,ACTIVE,1\nCORPORATIONS,ACTIVE,1\nNON_PROFIT,ACTIVE,1\n", store.objects[key])
+	assert.Equal(t, "text/csv", store.contentTypes[key])
+	require.Len(t, mailer.sent, 1)
+	msg := mailer.sent[0]
+	assert.Equal(t, []string{"ops@example.com"}, msg.To)
+	assert.Equal(t, "Report types", msg.Subject)
+	assert.Contains(t, msg.Body, "Stored at s3://reports/"+key)
+	assert.True(t, strings.HasSuffix(msg.Body, store.objects[key]), "the email should carry the report")
+
+	err = NewDeliverer(runner, nil, "reports", nil, zaptest.NewLogger(t)).Deliver(context.Background(), Schedule{Name: "types", Report: "type_breakdown", Store: true})
+	assert.ErrorContains(t, err, "no object storage")
+}
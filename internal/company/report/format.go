@@ -0,0 +1,172 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+)
+
+// Format is the encoding of report lines.
+type Format string
+
+const (
+	// CSV renders a header line, then a line per row.
+	CSV Format = "csv"
+	// JSON renders a JSON object per row, keyed by column.
+	JSON Format = "json"
+)
+
+// ContentType returns the media type of reports in f.
+func (f Format) ContentType() string {
+	if f == CSV {
+		return "text/csv"
+	}
+	return "application/x-ndjson"
+}
+
+// ParseFormat returns the format called s, "csv" or "json" in any case.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case CSV, JSON:
+		return f, nil
+	}
+	return "", e.Invalid("format", e.CodeReportFormatUnsupported, fmt.Sprintf("unsupported report format %.32q", s))
+}
+
+// Negotiate returns the format a client sending the Accept header accept
+// prefers: CSV for text/csv, JSON for application/json,
+// application/x-ndjson and wildcards, taking the highest quality and then
+// the first listed. An empty header gets JSON.
+func Negotiate(accept string) (Format, error) {
+	if strings.TrimSpace(accept) == "" {
+		return JSON, nil
+	}
+	var best Format
+	bestQ := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		var f Format
+		switch mediaType {
+		case "text/csv":
+			f = CSV
+		case "application/json", "application/x-ndjson", "application/*", "*/*":
+			f = JSON
+		default:
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+	if best == "" {
+		return "", e.Invalid("format", e.CodeReportFormatUnsupported, fmt.Sprintf("none of %.64q is CSV or JSON", accept))
+	}
+	return best, nil
+}
+
+// encoder renders the lines of a report.
+type encoder interface {
+	// header returns the header line, or nil when the format has none.
+	header() []byte
+	row(values []interface{}) ([]byte, error)
+}
+
+func newEncoder(format Format, columns []string) (encoder, error) {
+	switch format {
+	case CSV:
+		return &csvEncoder{columns: columns}, nil
+	case JSON:
+		return &jsonEncoder{columns: columns}, nil
+	}
+	return nil, e.Invalid("format", e.CodeReportFormatUnsupported, fmt.Sprintf("unsupported report format %.32q", format))
+}
+
+// csvEncoder renders RFC 4180 lines. Times are RFC 3339 in UTC.
+type csvEncoder struct {
+	columns []string
+}
+
+func (c *csvEncoder) header() []byte {
+	line, _ := csvLine(c.columns)
+	return line
+}
+
+func (c *csvEncoder) row(values []interface{}) ([]byte, error) {
+	fields := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case string:
+			fields[i] = v
+		case int64:
+			fields[i] = strconv.FormatInt(v, 10)
+		case time.Time:
+			fields[i] = v.UTC().Format(time.RFC3339)
+		default:
+			return nil, fmt.Errorf("unsupported value %T", v)
+		}
+	}
+	return csvLine(fields)
+}
+
+// csvLine encodes fields as a CSV line without its line break.
+func csvLine(fields []string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(fields); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// jsonEncoder renders a JSON object per row with the columns in order.
+// Numbers are JSON numbers and times RFC 3339 strings in UTC.
+type jsonEncoder struct {
+	columns []string
+}
+
+func (j *jsonEncoder) header() []byte {
+	return nil
+}
+
+func (j *jsonEncoder) row(values []interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, v := range values {
+		if t, ok := v.(time.Time); ok {
+			v = t.UTC().Format(time.RFC3339)
+		}
+		key, _ := json.Marshal(j.columns[i])
+		value, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,221 @@
+// Package report runs the predefined reports over the companies, backed by
+// the named queries of the db package, and renders them as CSV or JSON
+// lines. Reports are streamed to callers through the ReportService and
+// delivered on schedules to object storage or by email.
+package report
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultLimit and maxLimit bound the rows of the reports listing
+	// companies.
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Source runs the named queries of the db package registry;
+// *db.Repository implements it.
+type Source interface {
+	RunNamedQuery(ctx context.Context, name string, params map[string]interface{}, dest interface{}) error
+}
+
+// Params parametrize a run of a report; reports ignore the ones they do not
+// take.
+type Params struct {
+	// Since and Until bound the time range reported on, Until excluded.
+	// Until defaults to now and Since to a period before Until that depends
+	// on the report.
+	Since time.Time
+	Until time.Time
+	// TenantID restricts the report to the companies of a tenant; empty
+	// reports on every company.
+	TenantID string
+	// Limit caps the rows of the reports listing companies; 0 means 100,
+	// and more than 1000 means 1000.
+	Limit int
+}
+
+// Report is a predefined report.
+type Report struct {
+	Name        string
+	Description string
+	Columns     []string
+	// defaultSince returns the start of the time range ending at until when
+	// none is given; nil when the report takes no time range.
+	defaultSince func(until time.Time) time.Time
+	// rows runs the report, returning the values of its rows in column
+	// order.
+	rows func(ctx context.Context, src Source, p Params) ([][]interface{}, error)
+}
+
+// reports are the predefined reports, in the order they are listed.
+var reports = []*Report{
+	{
+		Name:         "growth",
+		Description:  "Companies created and deleted per calendar month (UTC), over the last 12 months by default.",
+		Columns:      []string{"month", "created", "deleted"},
+		defaultSince: func(until time.Time) time.Time { return until.AddDate(0, -12, 0) },
+		rows: func(ctx context.Context, src Source, p Params) ([][]interface{}, error) {
+			var months []struct {
+				Month   string
+				Created int64
+				Deleted int64
+			}
+			err := src.RunNamedQuery(ctx, "company_growth_per_month", map[string]interface{}{
+				"since": p.Since, "until": p.Until, "tenant": p.TenantID,
+			}, &months)
+			rows := make([][]interface{}, len(months))
+			for i, m := range months {
+				rows[i] = []interface{}{m.Month, m.Created, m.Deleted}
+			}
+			return rows, err
+		},
+	},
+	{
+		Name:        "type_breakdown",
+		Description: "Live companies per type and status.",
+		Columns:     []string{"type", "status", "companies"},
+		rows: func(ctx context.Context, src Source, p Params) ([][]interface{}, error) {
+			var groups []struct {
+				Type      string
+				Status    string
+				Companies int64
+			}
+			err := src.RunNamedQuery(ctx, "companies_by_type_and_status", map[string]interface{}{
+				"tenant": p.TenantID,
+			}, &groups)
+			rows := make([][]interface{}, len(groups))
+			for i, g := range groups {
+				rows[i] = []interface{}{g.Type, g.Status, g.Companies}
+			}
+			return rows, err
+		},
+	},
+	{
+		Name:         "recently_modified",
+		Description:  "Live companies last updated in the time range, most recent first; the last 7 days and at most 100 companies by default.",
+		Columns:      []string{"id", "name", "type", "status", "updated_by", "updated_at"},
+		defaultSince: func(until time.Time) time.Time { return until.AddDate(0, 0, -7) },
+		rows: func(ctx context.Context, src Source, p Params) ([][]interface{}, error) {
+			var companies []struct {
+				ID        uuid.UUID
+				Name      string
+				Type      string
+				Status    string
+				UpdatedBy string
+				UpdatedAt time.Time
+			}
+			err := src.RunNamedQuery(ctx, "recently_updated_companies", map[string]interface{}{
+				"since": p.Since, "until": p.Until, "tenant": p.TenantID, "limit": p.Limit,
+			}, &companies)
+			rows := make([][]interface{}, len(companies))
+			for i, c := range companies {
+				rows[i] = []interface{}{c.ID.String(), c.Name, c.Type, c.Status, c.UpdatedBy, c.UpdatedAt}
+			}
+			return rows, err
+		},
+	},
+}
+
+// Reports returns the predefined reports.
+func Reports() []Report {
+	out := make([]Report, len(reports))
+	for i, r := range reports {
+		out[i] = *r
+	}
+	return out
+}
+
+// Lookup returns the report called name.
+func Lookup(name string) (*Report, bool) {
+	for _, r := range reports {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// Runner runs reports on the queries of a Source.
+type Runner struct {
+	source Source
+	now    func() time.Time
+}
+
+// NewRunner returns a Runner querying source.
+func NewRunner(source Source) *Runner {
+	return &Runner{source: source, now: time.Now}
+}
+
+// Reports returns the predefined reports.
+func (r *Runner) Reports() []Report {
+	return Reports()
+}
+
+// Run runs the report called name and passes its lines in format to emit,
+// the header first for CSV, without line breaks. It fails with
+// REPORT_NOT_FOUND for unknown reports and validation errors for invalid
+// params before emitting anything.
+func (r *Runner) Run(ctx context.Context, name string, p Params, format Format, emit func(line []byte) error) error {
+	report, ok := Lookup(name)
+	if !ok {
+		return e.Newf(e.CodeReportNotFound, "no report %q", name)
+	}
+	enc, err := newEncoder(format, report.Columns)
+	if err != nil {
+		return err
+	}
+	if p, err = r.normalize(report, p); err != nil {
+		return err
+	}
+	rows, err := report.rows(ctx, r.source, p)
+	if err != nil {
+		return fmt.Errorf("failed to run report %s: %w", name, err)
+	}
+	if header := enc.header(); header != nil {
+		if err := emit(header); err != nil {
+			return err
+		}
+	}
+	for _, row := range rows {
+		line, err := enc.row(row)
+		if err != nil {
+			return fmt.Errorf("failed to encode report %s: %w", name, err)
+		}
+		if err := emit(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalize validates p and fills in its defaults for report. Times are
+// made UTC, so that they compare with the stored ones on every database.
+func (r *Runner) normalize(report *Report, p Params) (Params, error) {
+	if p.Until.IsZero() {
+		p.Until = r.now()
+	}
+	if p.Since.IsZero() && report.defaultSince != nil {
+		p.Since = report.defaultSince(p.Until)
+	}
+	if report.defaultSince != nil && !p.Until.After(p.Since) {
+		return p, e.Invalid("until", e.CodeTimeRangeInvalid, "until must be after since")
+	}
+	p.Since, p.Until = p.Since.UTC(), p.Until.UTC()
+	switch {
+	case p.Limit < 0:
+		return p, e.Invalid("limit", e.CodeReportLimitInvalid, "negative limit")
+	case p.Limit == 0:
+		p.Limit = defaultLimit
+	case p.Limit > maxLimit:
+		p.Limit = maxLimit
+	}
+	return p, nil
+}
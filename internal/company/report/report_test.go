@@ -0,0 +1,125 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/db"
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+)
+
+// newTestRunner returns a Runner on a SQLite database holding companies of
+// tenant acme created in January and February 2026, one of them deleted in
+// March, and one of tenant globex.
+func newTestRunner(t *testing.T) (*Runner, uuid.UUID) {
+	repo, err := db.Open(sqlite.Open(":memory:"))
+	require.NoError(t, err)
+	ctx := context.Background()
+	jan := time.Date(2026, time.January, 10, 12, 0, 0, 0, time.UTC)
+	feb := time.Date(2026, time.February, 3, 8, 0, 0, 0, time.UTC)
+	var latest uuid.UUID
+	for _, c := range []models.Company{
+		{Name: "Acme", Type: models.Corporations, TenantID: "acme", CreatedAt: jan, UpdatedAt: jan},
+		{Name: "Acme Foundation", Type: models.NonProfit, TenantID: "acme", CreatedAt: jan, UpdatedAt: feb},
+		{Name: "Acme Co-op", Type: models.Cooperative, TenantID: "acme", CreatedAt: feb, UpdatedAt: feb.Add(time.Hour)},
+		{Name: "Globex", Type: models.Corporations, TenantID: "globex", CreatedAt: feb, UpdatedAt: feb},
+	} {
+		c.ID = uuid.New()
+		c.Status = models.StatusActive
+		c.UpdatedBy = "alice"
+		require.NoError(t, repo.CreateCompany(ctx, &c))
+		if c.Name == "Acme Co-op" {
+			latest = c.ID
+		}
+		if c.Name == "Acme" {
+			require.NoError(t, repo.Exec(ctx, "UPDATE companies SET deleted_at = ? WHERE id = ?", time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC), c.ID))
+		}
+	}
+	runner := NewRunner(repo)
+	runner.now = func() time.Time { return time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC) }
+	return runner, latest
+}
+
+// run returns the lines of a report.
+func run(t *testing.T, runner *Runner, name string, p Params, format Format) []string {
+	var lines []string
+	err := runner.Run(context.Background(), name, p, format, func(line []byte) error {
+		lines = append(lines, string(line))
+		return nil
+	})
+	require.NoError(t, err)
+	return lines
+}
+
+func TestRunner_Run(t *testing.T) {
+	runner, latest := newTestRunner(t)
+
+	assert.Equal(t, []string{
+		"month,created,deleted",
+		"2026-01,2,0",
+		"2026-02,2,0",
+		"2026-03,0,1",
+	}, run(t, runner, "growth", Params{}, CSV))
+	assert.Equal(t, []string{
+		`{"month":"2026-02","created":1,"deleted":0}`,
+	}, run(t, runner, "growth", Params{Since: time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC), TenantID: "acme", Until: time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)}, JSON))
+
+	assert.Equal(t, []string{
+		"type,status,companies",
+		"COOPERATIVE,ACTIVE,1",
+		"CORPORATIONS,ACTIVE,1",
+		"NON_PROFIT,ACTIVE,1",
+	}, run(t, runner, "type_breakdown", Params{}, CSV), "deleted companies should be left out")
+
+	runner.now = func() time.Time { return time.Date(2026, time.February, 5, 0, 0, 0, 0, time.UTC) }
+	lines := run(t, runner, "recently_modified", Params{TenantID: "acme", Limit: 1}, JSON)
+	assert.Equal(t, []string{
+		`{"id":"` + latest.String() + `","name":"Acme Co-op","type":"COOPERATIVE","status":"ACTIVE","updated_by":"alice","updated_at":"2026-02-03T09:00:00Z"}`,
+	}, lines)
+	lines = run(t, runner, "recently_modified", Params{}, CSV)
+	assert.Len(t, lines, 4, "the header and the three live companies updated in the last 7 days")
+	assert.True(t, strings.HasPrefix(lines[1], latest.String()+",Acme Co-op,"), lines[1])
+}
+
+func TestRunner_RunInvalid(t *testing.T) {
+	runner := NewRunner(nil)
+	emit := func([]byte) error { return nil }
+	ctx := context.Background()
+
+	err := runner.Run(ctx, "churn", Params{}, CSV, emit)
+	assert.Equal(t, e.CodeReportNotFound, e.CodeOf(err))
+	assert.True(t, errors.Is(err, e.ErrNotFound))
+
+	err = runner.Run(ctx, "growth", Params{Since: time.Now(), Until: time.Now().Add(-time.Hour)}, CSV, emit)
+	assert.Equal(t, e.CodeTimeRangeInvalid, e.CodeOf(err))
+	err = runner.Run(ctx, "recently_modified", Params{Limit: -1}, CSV, emit)
+	assert.Equal(t, e.CodeReportLimitInvalid, e.CodeOf(err))
+	err = runner.Run(ctx, "growth", Params{}, Format("xml"), emit)
+	assert.Equal(t, e.CodeReportFormatUnsupported, e.CodeOf(err))
+}
+
+func TestNegotiate(t *testing.T) {
+	for accept, want := range map[string]Format{
+		"":                                     JSON,
+		"*/*":                                  JSON,
+		"text/csv":                             CSV,
+		"application/json":                     JSON,
+		"text/html, text/csv;q=0.9, */*;q=0.1": CSV,
+		"application/json;q=0.5, text/csv":     CSV,
+		"text/csv, application/x-ndjson":       CSV,
+	} {
+		got, err := Negotiate(accept)
+		require.NoError(t, err, accept)
+		assert.Equal(t, want, got, accept)
+	}
+	_, err := Negotiate("application/xml")
+	assert.Equal(t, e.CodeReportFormatUnsupported, e.CodeOf(err))
+}
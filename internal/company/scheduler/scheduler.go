@@ -0,0 +1,202 @@
+// Package scheduler runs background jobs on cron schedules. Every run first
+// elects a leader through a database advisory lock derived from the job
+// name, so that when several instances of the service are deployed only one
+// of them runs a job at a time.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gartstein/xm/internal/pkg/leader"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// Locker grants advisory locks shared by every instance of the service;
+// *db.Repository implements it.
+type Locker = leader.Locker
+
+// JobFunc performs a single run of a job.
+type JobFunc func(ctx context.Context) error
+
+// JobStatus reports the schedule and the outcome of recent runs of a job.
+type JobStatus struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	Running  bool      `json:"running"`
+	NextRun  time.Time `json:"next_run"`
+	// LastRun is when the last run on this instance started.
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	Runs         int        `json:"runs"`
+	Failures     int        `json:"failures"`
+	// Skipped counts runs left to another instance holding the lock.
+	Skipped int `json:"skipped"`
+}
+
+type job struct {
+	schedule cron.Schedule
+	fn       JobFunc
+	lockKey  int64
+	// status is guarded by Scheduler.mu.
+	status JobStatus
+}
+
+// Scheduler runs the jobs added to it until its context is canceled.
+type Scheduler struct {
+	locker Locker
+	logger *zap.Logger
+
+	mu   sync.Mutex
+	jobs []*job
+}
+
+// New returns a Scheduler taking its locks from locker.
+func New(locker Locker, logger *zap.Logger) *Scheduler {
+	return &Scheduler{locker: locker, logger: logger.Named("scheduler")}
+}
+
+// Add registers fn to run on spec, a standard five-field cron expression
+// such as "0 3 * * *" or a descriptor such as "@daily" or "@every 1h". Jobs
+// must be added before Run.
+func (s *Scheduler) Add(name, spec string, fn JobFunc) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q for job %s: %w", spec, name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.status.Name == name {
+			return fmt.Errorf("job %s already added", name)
+		}
+	}
+	s.jobs = append(s.jobs, &job{
+		schedule: schedule,
+		fn:       fn,
+		lockKey:  lockKey(name),
+		status:   JobStatus{Name: name, Schedule: spec, NextRun: schedule.Next(time.Now())},
+	})
+	return nil
+}
+
+// lockKey derives the advisory lock key of a job from its name.
+func lockKey(name string) int64 {
+	return leader.Key("xm.scheduler/" + name)
+}
+
+// Run runs every job on its schedule and returns once ctx is canceled and
+// running jobs have returned.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	jobs := append([]*job(nil), s.jobs...)
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, j)
+		}()
+	}
+	wg.Wait()
+}
+
+// loop waits for each scheduled time of j and runs it.
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		next := j.schedule.Next(time.Now())
+		s.mu.Lock()
+		j.status.NextRun = next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.run(ctx, j)
+	}
+}
+
+// run runs j once unless another instance holds its lock.
+func (s *Scheduler) run(ctx context.Context, j *job) {
+	logger := s.logger.With(zap.String("job", j.status.Name))
+	ran, err := leader.Do(ctx, s.locker, j.lockKey, func(ctx context.Context) error {
+		s.execute(ctx, logger, j)
+		return nil
+	})
+	if err != nil {
+		logger.Error("Failed to take job lock", zap.Error(err))
+		s.finish(j, time.Now(), 0, fmt.Errorf("failed to take job lock: %w", err))
+		return
+	}
+	if !ran {
+		logger.Debug("Job running on another instance")
+		s.mu.Lock()
+		j.status.Skipped++
+		s.mu.Unlock()
+	}
+}
+
+// execute runs j and records the outcome.
+func (s *Scheduler) execute(ctx context.Context, logger *zap.Logger, j *job) {
+	s.mu.Lock()
+	j.status.Running = true
+	s.mu.Unlock()
+	start := time.Now()
+	err := j.fn(ctx)
+	elapsed := time.Since(start)
+	if err != nil && ctx.Err() == nil {
+		logger.Error("Job failed", zap.Error(err), zap.Duration("duration", elapsed))
+	} else {
+		logger.Info("Job finished", zap.Duration("duration", elapsed))
+	}
+	s.finish(j, start, elapsed, err)
+}
+
+// finish records the outcome of a run of j.
+func (s *Scheduler) finish(j *job, start time.Time, elapsed time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.status.Running = false
+	j.status.LastRun = &start
+	j.status.LastDuration = elapsed.String()
+	j.status.Runs++
+	j.status.LastError = ""
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+	}
+}
+
+// Status returns the status of every job in the order they were added.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		statuses = append(statuses, j.status)
+	}
+	return statuses
+}
+
+// ServeHTTP reports the status of every job on GET.
+func (s *Scheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"jobs": s.Status()})
+}
@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeLocker grants its lock unless held is set.
+type fakeLocker struct {
+	held     bool
+	err      error
+	keys     []int64
+	released int
+}
+
+func (l *fakeLocker) TryAdvisoryLock(_ context.Context, key int64) (func(), bool, error) {
+	l.keys = append(l.keys, key)
+	if l.err != nil || l.held {
+		return nil, false, l.err
+	}
+	return func() { l.released++ }, true, nil
+}
+
+func TestAdd(t *testing.T) {
+	s := New(&fakeLocker{}, zaptest.NewLogger(t))
+	noop := func(context.Context) error { return nil }
+
+	require.NoError(t, s.Add("archive", "0 3 * * *", noop))
+	assert.Error(t, s.Add("archive", "@daily", noop), "duplicate names should be rejected")
+	assert.Error(t, s.Add("broken", "every day", noop))
+
+	statuses := s.Status()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, "archive", statuses[0].Name)
+	assert.Equal(t, 3, statuses[0].NextRun.Hour())
+	assert.NotEqual(t, lockKey("archive"), lockKey("purge"))
+}
+
+func TestRun(t *testing.T) {
+	locker := &fakeLocker{}
+	s := New(locker, zaptest.NewLogger(t))
+	calls := 0
+	errFailed := errors.New("database unavailable")
+	var result error
+	require.NoError(t, s.Add("archive", "@daily", func(context.Context) error {
+		calls++
+		return result
+	}))
+	j := s.jobs[0]
+
+	result = errFailed
+	s.run(context.Background(), j)
+	status := s.Status()[0]
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 1, status.Failures)
+	assert.Equal(t, errFailed.Error(), status.LastError)
+	assert.NotNil(t, status.LastRun)
+	assert.False(t, status.Running)
+
+	result = nil
+	s.run(context.Background(), j)
+	status = s.Status()[0]
+	assert.Equal(t, 2, status.Runs)
+	assert.Empty(t, status.LastError, "a successful run should clear the last error")
+	assert.Equal(t, 2, locker.released)
+	assert.Equal(t, []int64{j.lockKey, j.lockKey}, locker.keys)
+
+	locker.held = true
+	s.run(context.Background(), j)
+	status = s.Status()[0]
+	assert.Equal(t, 2, calls, "the job should not run while another instance holds the lock")
+	assert.Equal(t, 1, status.Skipped)
+
+	locker.held, locker.err = false, errors.New("connection refused")
+	s.run(context.Background(), j)
+	assert.Equal(t, 2, calls)
+	assert.Contains(t, s.Status()[0].LastError, "connection refused")
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	s := New(&fakeLocker{}, zaptest.NewLogger(t))
+	require.NoError(t, s.Add("archive", "@daily", func(context.Context) error { return nil }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler did not stop after cancel")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	s := New(&fakeLocker{}, zaptest.NewLogger(t))
+	require.NoError(t, s.Add("archive", "0 3 * * *", func(context.Context) error { return nil }))
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/jobs", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Jobs []JobStatus `json:"jobs"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Jobs, 1)
+	assert.Equal(t, "0 3 * * *", body.Jobs[0].Schedule)
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/jobs", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
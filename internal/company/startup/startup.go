@@ -0,0 +1,93 @@
+// Package startup brings up the dependencies of a service, retrying each
+// with bounded exponential backoff so the service does not exit while, for
+// example, its database is still starting next to it.
+package startup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Backoff controls how a dependency is retried.
+type Backoff struct {
+	// Initial is the wait after the first failed attempt. It doubles after
+	// every further failure, up to Max.
+	Initial time.Duration
+	Max     time.Duration
+	// Attempts bounds the number of attempts; 0 retries until the context
+	// is done.
+	Attempts int
+}
+
+// DefaultBackoff makes 10 attempts over about two and a half minutes.
+func DefaultBackoff() Backoff {
+	return Backoff{Initial: time.Second, Max: 30 * time.Second, Attempts: 10}
+}
+
+// delay returns the wait after the given failed attempt, counting from 1.
+func (b Backoff) delay(attempt int) time.Duration {
+	d := b.Initial
+	for i := 1; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+	return min(d, b.Max)
+}
+
+// permanentError marks an error that retrying does not fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so Connect returns it at once instead of retrying,
+// for failures such as a missing configuration that only an operator can
+// fix.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Connect calls connect until it succeeds and returns its result, waiting
+// between attempts as backoff says. It gives up with the last error once
+// backoff.Attempts attempts failed or ctx is done. name identifies the
+// dependency in logs and errors. An error wrapped with Permanent ends the
+// retries immediately.
+func Connect[T any](ctx context.Context, logger *zap.Logger, name string, backoff Backoff, connect func(context.Context) (T, error)) (T, error) {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		result, err := connect(ctx)
+		if err == nil {
+			logger.Info("Dependency available",
+				zap.String("dependency", name),
+				zap.Int("attempts", attempt),
+				zap.Duration("elapsed", time.Since(start)))
+			return result, nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return result, fmt.Errorf("%s not available: %w", name, permanent.err)
+		}
+		if backoff.Attempts > 0 && attempt >= backoff.Attempts {
+			return result, fmt.Errorf("%s not available after %d attempts: %w", name, attempt, err)
+		}
+
+		wait := backoff.delay(attempt)
+		logger.Warn("Dependency not available, retrying",
+			zap.String("dependency", name),
+			zap.Int("attempt", attempt),
+			zap.Duration("retry_in", wait),
+			zap.Error(err))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return result, fmt.Errorf("%s not available: %w", name, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package startup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestBackoff_Delay(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 5 * time.Second}
+	var delays []time.Duration
+	for attempt := 1; attempt <= 5; attempt++ {
+		delays = append(delays, b.delay(attempt))
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, delays)
+}
+
+func TestConnect(t *testing.T) {
+	backoff := Backoff{Initial: time.Millisecond, Max: 2 * time.Millisecond, Attempts: 5}
+	calls := 0
+	got, err := Connect(context.Background(), zaptest.NewLogger(t), "database", backoff, func(context.Context) (string, error) {
+		calls++
+		if calls < 3 {
+			return "", errors.New("connection refused")
+		}
+		return "repo", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "repo", got)
+	assert.Equal(t, 3, calls)
+}
+
+func TestConnect_GivesUp(t *testing.T) {
+	backoff := Backoff{Initial: time.Millisecond, Max: time.Millisecond, Attempts: 3}
+	calls := 0
+	_, err := Connect(context.Background(), zaptest.NewLogger(t), "kafka", backoff, func(context.Context) (int, error) {
+		calls++
+		return 0, errors.New("connection refused")
+	})
+	assert.EqualError(t, err, "kafka not available after 3 attempts: connection refused")
+	assert.Equal(t, 3, calls)
+
+	// Without a bound on attempts, only the context stops the retries.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = Connect(ctx, zaptest.NewLogger(t), "kafka", Backoff{Initial: time.Millisecond, Max: time.Millisecond}, func(context.Context) (int, error) {
+		return 0, errors.New("connection refused")
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestConnect_Permanent(t *testing.T) {
+	backoff := Backoff{Initial: time.Millisecond, Max: time.Millisecond, Attempts: 5}
+	missing := errors.New("topic missing")
+	calls := 0
+	_, err := Connect(context.Background(), zaptest.NewLogger(t), "kafka", backoff, func(context.Context) (int, error) {
+		calls++
+		return 0, Permanent(missing)
+	})
+	assert.EqualError(t, err, "kafka not available: topic missing")
+	assert.ErrorIs(t, err, missing)
+	assert.Equal(t, 1, calls)
+}
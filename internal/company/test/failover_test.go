@@ -0,0 +1,90 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// failoverCompose is the cluster TestProducerFailover stops a broker of.
+const failoverCompose = "docker-compose.failover.yaml"
+
+// TestProducerFailover stops the broker leading the partition a producer
+// writes to and expects the producer to keep writing through the new
+// leader. It needs the cluster of failoverCompose, with its brokers listed
+// in KAFKA_FAILOVER_BROKERS; see `make failover-test`.
+func TestProducerFailover(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests")
+	}
+	brokersEnv := os.Getenv("KAFKA_FAILOVER_BROKERS")
+	if brokersEnv == "" {
+		t.Skip("KAFKA_FAILOVER_BROKERS not set")
+	}
+	brokers := strings.Split(brokersEnv, ",")
+
+	topic := "failover_" + uuid.NewString()
+	producer, err := events.NewProducer(brokers, zaptest.NewLogger(t), topic,
+		events.WithTopicSettings(events.TopicSettings{AutoCreate: true, Partitions: 1, ReplicationFactor: 3}),
+		events.WithTransport(events.TransportSettings{MetadataTTL: 2 * time.Second}))
+	require.NoError(t, err)
+	defer producer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	write := func() uuid.UUID {
+		company := &models.Company{ID: uuid.New(), Name: "Failover"}
+		err := producer.Replay(ctx, topic, events.Event{EventID: uuid.New(), Type: events.CompanyCreated, Company: company})
+		require.NoError(t, err)
+		return company.ID
+	}
+
+	before := write()
+	leader := partitionLeader(t, brokers, topic)
+	t.Logf("Stopping kafka-%d, the leader of %s", leader, topic)
+	compose(t, "stop", fmt.Sprintf("kafka-%d", leader))
+	t.Cleanup(func() { compose(t, "start", fmt.Sprintf("kafka-%d", leader)) })
+	after := write()
+
+	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: brokers, Topic: topic, StartOffset: kafka.FirstOffset})
+	defer reader.Close()
+	var keys []string
+	for len(keys) < 2 {
+		msg, err := reader.ReadMessage(ctx)
+		require.NoError(t, err)
+		keys = append(keys, string(msg.Key))
+	}
+	assert.Equal(t, []string{before.String(), after.String()}, keys)
+}
+
+// partitionLeader returns the ID of the broker leading the first partition
+// of topic.
+func partitionLeader(t *testing.T, brokers []string, topic string) int {
+	conn, err := kafka.Dial("tcp", brokers[0])
+	require.NoError(t, err)
+	defer conn.Close()
+	partitions, err := conn.ReadPartitions(topic)
+	require.NoError(t, err)
+	require.NotEmpty(t, partitions)
+	return partitions[0].Leader.ID
+}
+
+// compose runs a docker compose command against failoverCompose.
+func compose(t *testing.T, args ...string) {
+	cmd := exec.Command("docker", append([]string{"compose", "-f", failoverCompose}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("docker compose %v: %v\n%s", args, err, out)
+	}
+}
@@ -84,7 +84,7 @@ func initializeKafkaWithRetry(topic string) (*events.Producer, *kafka.Reader, er
 	var err error
 	// Retry producer initialization
 	err = backoff.Retry(func() error {
-		producer, err = events.NewProducer(kafkaBrokers, zap.NewNop(), topic)
+		producer, err = events.NewProducer(kafkaBrokers, zap.NewNop(), topic, events.WithTopicStrategy(events.TopicPerEvent))
 		if err != nil || producer == nil {
 			return fmt.Errorf("failed to create Kafka produce: %v", err)
 		}
@@ -149,7 +149,7 @@ func (s *IntegrationTestSuite) SetupTest() {
 }
 
 func (s *IntegrationTestSuite) createCompany(ctx context.Context, service *controller.CompanyService, newCompany *models.Company) uuid.UUID {
-	created, err := service.CreateCompany(ctx, newCompany)
+	created, err := service.CreateCompany(ctx, newCompany, models.CreateOptions{})
 	if err != nil {
 		s.T().Fatal("CreateCompany failed:", err)
 	}
@@ -181,7 +181,7 @@ func (s *IntegrationTestSuite) TestCompanyCreate() {
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	created, err := ctrl.CreateCompany(ctx, newCompany)
+	created, err := ctrl.CreateCompany(ctx, newCompany, models.CreateOptions{})
 	if err != nil {
 		s.T().Fatal("CreateCompany failed:", err)
 	}
@@ -193,7 +193,7 @@ func (s *IntegrationTestSuite) TestCompanyCreate() {
 func (s *IntegrationTestSuite) TestCompanyUpdate() {
 	// Initialize Kafka components with retries
 	var kafkaErr error
-	s.producer, s.kafkaReader, kafkaErr = initializeKafkaWithRetry(string(events.CompanyCreated))
+	s.producer, s.kafkaReader, kafkaErr = initializeKafkaWithRetry(string(events.CompanyUpdated))
 	if kafkaErr != nil {
 		s.T().Fatal("Kafka initialization failed:", kafkaErr)
 	}
@@ -228,7 +228,7 @@ func (s *IntegrationTestSuite) TestCompanyUpdate() {
 		Employees:   &newCompany.Employees,
 	}
 
-	updatedCompany, err := ctrl.UpdateCompany(ctx, update)
+	updatedCompany, err := ctrl.UpdateCompany(ctx, update, models.UpdateOptions{})
 	if err != nil {
 		s.T().Fatal("UpdateCompany failed:", err)
 	}
@@ -268,7 +268,7 @@ func (s *IntegrationTestSuite) TestCompanyDelete() {
 	if err != nil {
 		s.T().Fatal("CreateCompany failed:", err)
 	}
-	err = ctrl.DeleteCompany(ctx, company.ID)
+	_, err = ctrl.DeleteCompany(ctx, company.ID)
 	if err != nil {
 		s.T().Fatal("DeleteCompany failed:", err)
 	}
@@ -280,6 +280,31 @@ func (s *IntegrationTestSuite) TestCompanyDelete() {
 	s.verifyKafkaEvent(ctx, events.CompanyDeleted, company.ID)
 }
 
+// TestComplianceErasure verifies erasing a company clears its compliance
+// records past the append-only trigger, which keeps rejecting other updates.
+func (s *IntegrationTestSuite) TestComplianceErasure() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.testTimeout)
+	defer cancel()
+
+	company := &models.Company{ID: uuid.New(), Name: "Erased Company", ContactEmail: "jane@acme.test"}
+	if err := s.dbRepo.CreateCompany(ctx, company); err != nil {
+		s.T().Fatal("CreateCompany failed:", err)
+	}
+	record := &models.ComplianceRecord{
+		Method:  "/definition.v1.CompanyService/UpdateCompany",
+		Code:    "OK",
+		Request: fmt.Sprintf(`{"id":%q,"company":{"contactEmail":"jane@acme.test"}}`, company.ID),
+	}
+	if err := s.dbRepo.RecordCompliance(ctx, record); err != nil {
+		s.T().Fatal("RecordCompliance failed:", err)
+	}
+
+	_, err := s.dbRepo.EraseCompanyData(ctx, company.ID, "dpo", time.Now())
+	assert.NoError(s.T(), err, "erasure should pass the append-only trigger")
+	err = s.dbRepo.Exec(ctx, "UPDATE compliance_records SET request = '' WHERE id = ?", record.ID)
+	assert.ErrorContains(s.T(), err, "append-only", "other updates should still be rejected")
+}
+
 func (s *IntegrationTestSuite) verifyKafkaEvent(ctx context.Context, eventType events.EventType, companyID uuid.UUID) {
 	event := s.consumeKafkaEvent(ctx, eventType, companyID)
 
@@ -0,0 +1,183 @@
+package validation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// defaultTimeout bounds the calls of hooks configured without a timeout.
+	defaultTimeout = 5 * time.Second
+	// maxResponseSize bounds the hook responses read.
+	maxResponseSize = 1 << 20
+)
+
+// HTTPConfig configures an HTTPHook.
+type HTTPConfig struct {
+	// Name identifies the hook.
+	Name string `yaml:"NAME"`
+	// URL is the endpoint changes are POSTed to.
+	URL string `yaml:"URL"`
+	// APIKey, when set, is sent as a bearer token.
+	APIKey string `yaml:"API_KEY"`
+	// Timeout bounds each call; 0 selects 5s.
+	Timeout time.Duration `yaml:"TIMEOUT"`
+	// FailurePolicy is "fail_closed", the default, or "fail_open".
+	FailurePolicy string `yaml:"FAILURE_POLICY"`
+}
+
+// HTTPHook asks a JSON HTTP API about company changes. It POSTs
+// {"operation", "company", "changes"} and expects a 2xx answer of
+// {"allowed", "reason"}; any other answer, or none within the timeout, is
+// a failure handled by the failure policy.
+type HTTPHook struct {
+	name    string
+	url     string
+	apiKey  string
+	timeout time.Duration
+	policy  FailurePolicy
+	client  *http.Client
+}
+
+// httpRequest is the JSON posted to the hook API.
+type httpRequest struct {
+	Operation Operation             `json:"operation"`
+	Company   httpCompany           `json:"company"`
+	Changes   map[string]httpChange `json:"changes,omitempty"`
+}
+
+// httpCompany is the company as sent to the hook API.
+type httpCompany struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Description  string            `json:"description,omitempty"`
+	Employees    int               `json:"employees"`
+	Registered   bool              `json:"registered"`
+	Type         string            `json:"type"`
+	Status       string            `json:"status"`
+	ContactEmail string            `json:"contact_email,omitempty"`
+	ExternalRef  string            `json:"external_ref,omitempty"`
+	TenantID     string            `json:"tenant_id,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+// httpChange is the old and new value of a field as sent to the hook API.
+type httpChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// httpVerdict is the JSON answered by the hook API.
+type httpVerdict struct {
+	Allowed *bool  `json:"allowed"`
+	Reason  string `json:"reason"`
+}
+
+// NewHTTPHook returns an HTTPHook for cfg.
+func NewHTTPHook(cfg HTTPConfig) (*HTTPHook, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("validation hook name required")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("validation hook %s: invalid URL %q", cfg.Name, cfg.URL)
+	}
+	policy, err := ParseFailurePolicy(cfg.FailurePolicy)
+	if err != nil {
+		return nil, fmt.Errorf("validation hook %s: %w", cfg.Name, err)
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &HTTPHook{
+		name:    cfg.Name,
+		url:     u.String(),
+		apiKey:  cfg.APIKey,
+		timeout: timeout,
+		policy:  policy,
+		client:  &http.Client{},
+	}, nil
+}
+
+// Name implements Hook.
+func (h *HTTPHook) Name() string {
+	return h.name
+}
+
+// FailurePolicy implements Hook.
+func (h *HTTPHook) FailurePolicy() FailurePolicy {
+	return h.policy
+}
+
+// Validate implements Hook.
+func (h *HTTPHook) Validate(ctx context.Context, req Request) (Verdict, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	body, err := json.Marshal(newHTTPRequest(req))
+	if err != nil {
+		return Verdict{}, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return Verdict{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+	if h.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+h.apiKey)
+	}
+	resp, err := h.client.Do(httpReq)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return Verdict{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var verdict httpVerdict
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&verdict); err != nil {
+		return Verdict{}, fmt.Errorf("invalid response: %w", err)
+	}
+	if verdict.Allowed == nil {
+		return Verdict{}, errors.New("invalid response: allowed missing")
+	}
+	return Verdict{Allowed: *verdict.Allowed, Reason: verdict.Reason}, nil
+}
+
+// newHTTPRequest converts req to the JSON posted to the hook API.
+func newHTTPRequest(req Request) httpRequest {
+	c := req.Company
+	out := httpRequest{
+		Operation: req.Operation,
+		Company: httpCompany{
+			ID:           c.ID.String(),
+			Name:         c.Name,
+			Description:  c.Description,
+			Employees:    c.Employees,
+			Registered:   c.Registered,
+			Type:         string(c.Type),
+			Status:       string(c.Status),
+			ContactEmail: c.ContactEmail,
+			ExternalRef:  c.ExternalRef,
+			TenantID:     c.TenantID,
+			Metadata:     c.Metadata,
+		},
+	}
+	if len(req.Changes) > 0 {
+		out.Changes = make(map[string]httpChange, len(req.Changes))
+		for field, change := range req.Changes {
+			out.Changes[field] = httpChange{Old: change.Old, New: change.New}
+		}
+	}
+	return out
+}
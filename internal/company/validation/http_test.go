@@ -0,0 +1,74 @@
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPHook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		var body httpRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		switch body.Company.Name {
+		case "Acme":
+			assert.Equal(t, OperationUpdate, body.Operation)
+			assert.Equal(t, "Acme", body.Changes["name"].New)
+			_, _ = w.Write([]byte(`{"allowed": true}`))
+		case "Sanctioned":
+			_, _ = w.Write([]byte(`{"allowed": false, "reason": "listed entity"}`))
+		case "Slow":
+			time.Sleep(100 * time.Millisecond)
+			_, _ = w.Write([]byte(`{"allowed": true}`))
+		case "Broken":
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	hook, err := NewHTTPHook(HTTPConfig{Name: "sanctions", URL: server.URL + "/check", APIKey: "secret", Timeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	assert.Equal(t, "sanctions", hook.Name())
+	assert.Equal(t, FailClosed, hook.FailurePolicy())
+
+	ctx := context.Background()
+	verdict, err := hook.Validate(ctx, Request{
+		Operation: OperationUpdate,
+		Company:   models.Company{ID: uuid.New(), Name: "Acme"},
+		Changes:   map[string]models.FieldChange{"name": {Old: "Globex", New: "Acme"}},
+	})
+	require.NoError(t, err)
+	assert.True(t, verdict.Allowed)
+
+	verdict, err = hook.Validate(ctx, Request{Operation: OperationCreate, Company: models.Company{Name: "Sanctioned"}})
+	require.NoError(t, err)
+	assert.Equal(t, Verdict{Reason: "listed entity"}, verdict)
+
+	_, err = hook.Validate(ctx, Request{Operation: OperationCreate, Company: models.Company{Name: "Slow"}})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = hook.Validate(ctx, Request{Operation: OperationCreate, Company: models.Company{Name: "Broken"}})
+	assert.ErrorContains(t, err, "allowed missing")
+	_, err = hook.Validate(ctx, Request{Operation: OperationCreate, Company: models.Company{Name: "Initech"}})
+	assert.ErrorContains(t, err, "unexpected status 502")
+}
+
+func TestNewHTTPHook_Invalid(t *testing.T) {
+	_, err := NewHTTPHook(HTTPConfig{URL: "https://screening.example.com"})
+	assert.Error(t, err)
+	_, err = NewHTTPHook(HTTPConfig{Name: "sanctions", URL: "ftp://screening.example.com"})
+	assert.Error(t, err)
+	_, err = NewHTTPHook(HTTPConfig{Name: "sanctions", URL: "https://screening.example.com", FailurePolicy: "ignore"})
+	assert.Error(t, err)
+}
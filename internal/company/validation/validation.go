@@ -0,0 +1,119 @@
+// Package validation asks external services, such as sanctions screening,
+// to approve company changes before they are committed. Each hook answers
+// with a verdict; a hook that cannot give one either blocks the change or
+// lets it through, as its failure policy says.
+package validation
+
+import (
+	"context"
+	"fmt"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"go.uber.org/zap"
+)
+
+// Operation is the kind of change submitted to the hooks.
+type Operation string
+
+const (
+	OperationCreate Operation = "create"
+	OperationUpdate Operation = "update"
+)
+
+// FailurePolicy decides what happens to a change when a hook cannot give a
+// verdict, e.g. because it timed out.
+type FailurePolicy string
+
+const (
+	// FailClosed blocks the change. It is the default.
+	FailClosed FailurePolicy = "fail_closed"
+	// FailOpen lets the change through, recording the failure with it.
+	FailOpen FailurePolicy = "fail_open"
+)
+
+// ParseFailurePolicy returns the policy named by name; empty selects
+// FailClosed.
+func ParseFailurePolicy(name string) (FailurePolicy, error) {
+	switch policy := FailurePolicy(name); policy {
+	case "":
+		return FailClosed, nil
+	case FailClosed, FailOpen:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown failure policy %q", name)
+	}
+}
+
+// Request is a company change submitted to the hooks.
+type Request struct {
+	Operation Operation
+	// Company is the company as it would be stored.
+	Company models.Company
+	// Changes holds the old and new value of every field an update
+	// modifies, keyed by field name; it is empty for creates.
+	Changes map[string]models.FieldChange
+}
+
+// Hook is one external validator.
+type Hook interface {
+	// Name identifies the hook in logs, errors and the recorded results.
+	Name() string
+	// Validate returns the verdict of the hook on req, or an error when it
+	// could not give one.
+	Validate(ctx context.Context, req Request) (Verdict, error)
+	// FailurePolicy tells what an error returned by Validate means for the
+	// change.
+	FailurePolicy() FailurePolicy
+}
+
+// Verdict is the answer of a hook.
+type Verdict struct {
+	Allowed bool
+	// Reason explains the verdict, usually a rejection.
+	Reason string
+}
+
+// Validator runs a change past every configured hook, in order.
+type Validator struct {
+	hooks  []Hook
+	logger *zap.Logger
+}
+
+// NewValidator returns a Validator asking hooks.
+func NewValidator(logger *zap.Logger, hooks ...Hook) *Validator {
+	return &Validator{hooks: hooks, logger: logger.Named("validation")}
+}
+
+// Validate asks every hook about req and returns their results. It stops at
+// the first hook rejecting the change, failing with VALIDATION_REJECTED, or
+// failing to answer under FailClosed, failing with VALIDATION_UNAVAILABLE.
+// The errors of hooks are logged but not returned, as they may name
+// internal endpoints.
+func (v *Validator) Validate(ctx context.Context, req Request) ([]models.ValidationResult, error) {
+	results := make([]models.ValidationResult, 0, len(v.hooks))
+	for _, hook := range v.hooks {
+		verdict, err := hook.Validate(ctx, req)
+		if err != nil {
+			v.logger.Warn("Validation hook failed",
+				zap.Error(err),
+				zap.String("hook", hook.Name()),
+				zap.String("operation", string(req.Operation)),
+				zap.String("company_id", req.Company.ID.String()),
+			)
+			if hook.FailurePolicy() != FailOpen {
+				return nil, e.Newf(e.CodeValidationUnavailable, "%s did not answer", hook.Name())
+			}
+			results = append(results, models.ValidationResult{Hook: hook.Name(), Allowed: true, Error: err.Error()})
+			continue
+		}
+		if !verdict.Allowed {
+			if verdict.Reason == "" {
+				return nil, e.Newf(e.CodeValidationRejected, "rejected by %s", hook.Name())
+			}
+			return nil, e.Newf(e.CodeValidationRejected, "rejected by %s: %s", hook.Name(), verdict.Reason)
+		}
+		results = append(results, models.ValidationResult{Hook: hook.Name(), Allowed: true, Reason: verdict.Reason})
+	}
+	return results, nil
+}
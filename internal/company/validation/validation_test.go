@@ -0,0 +1,71 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	e "github.com/gartstein/xm/internal/company/errors"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeHook is a Hook answering verdict or err.
+type fakeHook struct {
+	name    string
+	verdict Verdict
+	err     error
+	policy  FailurePolicy
+	calls   int
+}
+
+func (h *fakeHook) Name() string                 { return h.name }
+func (h *fakeHook) FailurePolicy() FailurePolicy { return h.policy }
+
+func (h *fakeHook) Validate(context.Context, Request) (Verdict, error) {
+	h.calls++
+	return h.verdict, h.err
+}
+
+func TestValidator(t *testing.T) {
+	ctx := context.Background()
+	req := Request{Operation: OperationCreate, Company: models.Company{Name: "Acme"}}
+	sanctions := &fakeHook{name: "sanctions", verdict: Verdict{Allowed: true, Reason: "no match"}}
+	registry := &fakeHook{name: "registry", err: errors.New("timeout"), policy: FailOpen}
+
+	results, err := NewValidator(zaptest.NewLogger(t), sanctions, registry).Validate(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, []models.ValidationResult{
+		{Hook: "sanctions", Allowed: true, Reason: "no match"},
+		{Hook: "registry", Allowed: true, Error: "timeout"},
+	}, results)
+
+	// A rejection stops the validation.
+	sanctions.verdict = Verdict{Reason: "listed entity"}
+	registry.calls = 0
+	_, err = NewValidator(zaptest.NewLogger(t), sanctions, registry).Validate(ctx, req)
+	assert.ErrorIs(t, err, e.ErrValidationFailed)
+	assert.Equal(t, e.CodeValidationRejected, e.CodeOf(err))
+	assert.EqualError(t, err, "external validation failed: rejected by sanctions: listed entity")
+	assert.Zero(t, registry.calls)
+
+	// Failing closed, a hook without a verdict blocks the change without
+	// exposing its error.
+	registry.policy = FailClosed
+	_, err = NewValidator(zaptest.NewLogger(t), registry).Validate(ctx, req)
+	assert.Equal(t, e.CodeValidationUnavailable, e.CodeOf(err))
+	assert.EqualError(t, err, "external validation failed: registry did not answer")
+}
+
+func TestParseFailurePolicy(t *testing.T) {
+	policy, err := ParseFailurePolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, FailClosed, policy)
+	policy, err = ParseFailurePolicy("fail_open")
+	require.NoError(t, err)
+	assert.Equal(t, FailOpen, policy)
+	_, err = ParseFailurePolicy("ignore")
+	assert.Error(t, err)
+}
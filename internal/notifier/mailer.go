@@ -0,0 +1,42 @@
+// Package notifier emails configured recipients about company events, routing
+// each event type to the rules that subscribe to it.
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails. Implementations send from the address they were
+// configured with.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// validate rejects messages a mailer could not deliver, or that could
+// inject headers: recipients must be valid addresses and the subject a
+// single line.
+func (m Message) validate() error {
+	if len(m.To) == 0 {
+		return errors.New("no recipients")
+	}
+	for _, to := range m.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", to, err)
+		}
+	}
+	if strings.ContainsAny(m.Subject, "\r\n") {
+		return errors.New("subject must be a single line")
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMTPMailer(t *testing.T) {
+	m, err := NewSMTPMailer("smtp.example.com:587", "user", "secret", "XM <notify@example.com>")
+	require.NoError(t, err)
+	var gotFrom string
+	var gotTo []string
+	var gotMsg string
+	m.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Equal(t, "smtp.example.com:587", addr)
+		assert.NotNil(t, a)
+		gotFrom, gotTo, gotMsg = from, to, string(msg)
+		return nil
+	}
+
+	err = m.Send(context.Background(), Message{
+		To:      []string{"Ops <ops@example.com>", "audit@example.com"},
+		Subject: "Company created: Café",
+		Body:    "Café was created.",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "notify@example.com", gotFrom)
+	assert.Equal(t, []string{"ops@example.com", "audit@example.com"}, gotTo)
+	assert.Contains(t, gotMsg, "From: \"XM\" <notify@example.com>\r\n")
+	assert.Contains(t, gotMsg, "To: \"Ops\" <ops@example.com>, <audit@example.com>\r\n")
+	assert.Contains(t, gotMsg, "Subject: =?utf-8?q?Company_created:_Caf=C3=A9?=\r\n")
+	assert.True(t, strings.HasSuffix(gotMsg, "\r\n\r\nCaf=C3=A9 was created."), gotMsg)
+
+	err = m.Send(context.Background(), Message{To: []string{"ops@example.com"}, Subject: "Hi\r\nBcc: victim@example.com"})
+	assert.Error(t, err, "multi-line subjects could inject headers")
+
+	_, err = NewSMTPMailer("smtp.example.com", "", "", "notify@example.com")
+	assert.Error(t, err, "the address needs a port")
+}
+
+func TestSendGridMailer(t *testing.T) {
+	var got sendGridRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer api-key", r.Header.Get("Authorization"))
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		if got.Subject == "reject" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":[{"message":"invalid from"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	m, err := NewSendGridMailer("api-key", "XM <notify@example.com>")
+	require.NoError(t, err)
+	m.endpoint = server.URL
+
+	err = m.Send(context.Background(), Message{To: []string{"Ops <ops@example.com>"}, Subject: "Company created", Body: "Acme"})
+	require.NoError(t, err)
+	assert.Equal(t, sendGridAddress{Email: "notify@example.com", Name: "XM"}, got.From)
+	require.Len(t, got.Personalizations, 1)
+	assert.Equal(t, []sendGridAddress{{Email: "ops@example.com", Name: "Ops"}}, got.Personalizations[0].To)
+	assert.Equal(t, []sendGridContent{{Type: "text/plain", Value: "Acme"}}, got.Content)
+
+	err = m.Send(context.Background(), Message{To: []string{"ops@example.com"}, Subject: "reject"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid from")
+}
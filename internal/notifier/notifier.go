@@ -0,0 +1,159 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/gartstein/xm/internal/company/events"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultSubject is used by rules without a subject template.
+	defaultSubject = `{{.Type}}: {{with .Company}}{{.Name}}{{end}}`
+	// defaultBody is used by rules without a body template.
+	defaultBody = `{{with .Company}}Company {{.Name}} ({{.ID}}){{end}}
+Event: {{.Type}}
+Actor: {{.Actor}}
+`
+)
+
+// Rule emails the To recipients about events of the listed types. Subject
+// and Body are text/template templates executed with the events.Event;
+// empty ones fall back to a summary of the event.
+type Rule struct {
+	EventTypes []events.EventType `yaml:"EVENT_TYPES"`
+	To         []string           `yaml:"TO"`
+	Subject    string             `yaml:"SUBJECT"`
+	Body       string             `yaml:"BODY"`
+}
+
+type compiledRule struct {
+	Rule
+	subject *template.Template
+	body    *template.Template
+}
+
+// Notifier sends an email for every rule an event matches.
+type Notifier struct {
+	mailer Mailer
+	rules  []compiledRule
+	logger *zap.Logger
+}
+
+// NewNotifier validates rules and parses their templates.
+func NewNotifier(mailer Mailer, rules []Rule, logger *zap.Logger) (*Notifier, error) {
+	n := &Notifier{mailer: mailer, logger: logger.Named("notifier")}
+	for i, rule := range rules {
+		if len(rule.EventTypes) == 0 {
+			return nil, fmt.Errorf("rule %d: no event types", i)
+		}
+		for _, eventType := range rule.EventTypes {
+			if !eventType.Valid() {
+				return nil, fmt.Errorf("rule %d: unknown event type %q", i, eventType)
+			}
+		}
+		if err := (Message{To: rule.To}).validate(); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+
+		compiled := compiledRule{Rule: rule}
+		var err error
+		if compiled.subject, err = parseTemplate("subject", rule.Subject, defaultSubject); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		if compiled.body, err = parseTemplate("body", rule.Body, defaultBody); err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		n.rules = append(n.rules, compiled)
+	}
+	return n, nil
+}
+
+func parseTemplate(name, text, fallback string) (*template.Template, error) {
+	if text == "" {
+		text = fallback
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// EventTypes returns every event type a rule subscribes to, sorted.
+func (n *Notifier) EventTypes() []events.EventType {
+	var types []events.EventType
+	for _, rule := range n.rules {
+		types = append(types, rule.EventTypes...)
+	}
+	slices.Sort(types)
+	return slices.Compact(types)
+}
+
+// Handle emails the recipients of every rule matching the event type. It
+// is meant as an events.Consumer handler: on error the event is not
+// committed, and when it is redelivered the rules that had already been
+// delivered send their email again.
+func (n *Notifier) Handle(ctx context.Context, event events.Event) error {
+	var errs []error
+	for i, rule := range n.rules {
+		if !slices.Contains(rule.EventTypes, event.Type) {
+			continue
+		}
+		msg, err := rule.render(event)
+		if err == nil {
+			err = n.mailer.Send(ctx, msg)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %d: %w", i, err))
+			continue
+		}
+		n.logger.Info("Notification sent",
+			zap.String("event_type", string(event.Type)),
+			zap.String("event_id", event.EventID.String()),
+			zap.Int("rule", i),
+			zap.Int("recipients", len(msg.To)),
+		)
+	}
+	return errors.Join(errs...)
+}
+
+// Preview renders the email of every rule matching the event type without
+// sending it, to check the templates against an event.
+func (n *Notifier) Preview(event events.Event) ([]Message, error) {
+	var msgs []Message
+	for i, rule := range n.rules {
+		if !slices.Contains(rule.EventTypes, event.Type) {
+			continue
+		}
+		msg, err := rule.render(event)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// render executes the rule's templates for event. Runs of whitespace in
+// the subject, including line breaks, are collapsed to single spaces.
+func (r compiledRule) render(event events.Event) (Message, error) {
+	var subject, body bytes.Buffer
+	if err := r.subject.Execute(&subject, event); err != nil {
+		return Message{}, err
+	}
+	if err := r.body.Execute(&body, event); err != nil {
+		return Message{}, err
+	}
+	return Message{
+		To:      r.To,
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Body:    body.String(),
+	}, nil
+}
@@ -0,0 +1,115 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// recordingMailer records the messages it is asked to send.
+type recordingMailer struct {
+	sent []Message
+	err  error
+}
+
+func (m *recordingMailer) Send(_ context.Context, msg Message) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestNotifier_Routes(t *testing.T) {
+	mailer := &recordingMailer{}
+	n, err := NewNotifier(mailer, []Rule{
+		{
+			EventTypes: []events.EventType{events.CompanyCreated},
+			To:         []string{"ops@example.com"},
+			Subject:    "Created:\n{{.Company.Name}}",
+			Body:       "{{.Company.Name}} created by {{.Actor}}",
+		},
+		{
+			EventTypes: []events.EventType{events.CompanyCreated, events.CompanyDeleted},
+			To:         []string{"audit@example.com"},
+		},
+	}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	assert.Equal(t, []events.EventType{events.CompanyCreated, events.CompanyDeleted}, n.EventTypes())
+
+	company := &models.Company{ID: uuid.New(), Name: "Acme"}
+	require.NoError(t, n.Handle(context.Background(), events.Event{Type: events.CompanyCreated, Company: company, Actor: "alice"}))
+	require.Len(t, mailer.sent, 2)
+	assert.Equal(t, Message{To: []string{"ops@example.com"}, Subject: "Created: Acme", Body: "Acme created by alice"}, mailer.sent[0])
+	assert.Equal(t, "company_created: Acme", mailer.sent[1].Subject, "rules without templates should use the default subject")
+	assert.Contains(t, mailer.sent[1].Body, company.ID.String())
+
+	mailer.sent = nil
+	require.NoError(t, n.Handle(context.Background(), events.Event{Type: events.CompanyDeleted, Company: company}))
+	require.Len(t, mailer.sent, 1)
+	assert.Equal(t, []string{"audit@example.com"}, mailer.sent[0].To)
+
+	mailer.sent = nil
+	require.NoError(t, n.Handle(context.Background(), events.Event{Type: events.CompanyUpdated, Company: company}))
+	assert.Empty(t, mailer.sent, "events without rules should be ignored")
+}
+
+func TestNotifier_HandleErrors(t *testing.T) {
+	mailer := &recordingMailer{err: errors.New("connection refused")}
+	n, err := NewNotifier(mailer, []Rule{
+		{EventTypes: []events.EventType{events.CompanyDeleted}, To: []string{"ops@example.com"}},
+	}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	err = n.Handle(context.Background(), events.Event{Type: events.CompanyDeleted, Company: &models.Company{Name: "Acme"}})
+	assert.ErrorIs(t, err, mailer.err, "delivery failures should keep the event uncommitted")
+
+	mailer.err = nil
+	n, err = NewNotifier(mailer, []Rule{
+		{EventTypes: []events.EventType{events.CompanyDeleted}, To: []string{"ops@example.com"}, Subject: "{{.Company.Name}}"},
+	}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	assert.Error(t, n.Handle(context.Background(), events.Event{Type: events.CompanyDeleted}), "templates failing on the event should be reported")
+	assert.Empty(t, mailer.sent)
+}
+
+func TestNotifier_Preview(t *testing.T) {
+	mailer := &recordingMailer{}
+	n, err := NewNotifier(mailer, []Rule{
+		{EventTypes: []events.EventType{events.CompanyUpdated}, To: []string{"ops@example.com"}, Subject: "{{.Company.Name}} changed"},
+		{EventTypes: []events.EventType{events.CompanyDeleted}, To: []string{"audit@example.com"}},
+	}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	msgs, err := n.Preview(events.Event{Type: events.CompanyUpdated, Company: &models.Company{Name: "Acme"}})
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "Acme changed", msgs[0].Subject)
+	assert.Empty(t, mailer.sent, "previews should not be sent")
+
+	_, err = n.Preview(events.Event{Type: events.CompanyUpdated})
+	assert.ErrorContains(t, err, "rule 0")
+}
+
+func TestNewNotifier_InvalidRules(t *testing.T) {
+	tests := map[string]Rule{
+		"no event types":     {To: []string{"ops@example.com"}},
+		"unknown event type": {EventTypes: []events.EventType{"company_renamed"}, To: []string{"ops@example.com"}},
+		"no recipients":      {EventTypes: []events.EventType{events.CompanyCreated}},
+		"invalid recipient":  {EventTypes: []events.EventType{events.CompanyCreated}, To: []string{"ops"}},
+		"invalid template":   {EventTypes: []events.EventType{events.CompanyCreated}, To: []string{"ops@example.com"}, Body: "{{.Company.Name"},
+	}
+	for name, rule := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewNotifier(&recordingMailer{}, []Rule{rule}, zaptest.NewLogger(t))
+			assert.Error(t, err)
+		})
+	}
+}
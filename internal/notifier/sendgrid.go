@@ -0,0 +1,103 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"time"
+)
+
+const (
+	// sendGridEndpoint is the SendGrid v3 mail send API.
+	sendGridEndpoint = "https://api.sendgrid.com/v3/mail/send"
+	// sendGridTimeout bounds each API call.
+	sendGridTimeout = 10 * time.Second
+)
+
+// SendGridMailer sends emails through the SendGrid v3 API.
+type SendGridMailer struct {
+	apiKey   string
+	from     sendGridAddress
+	endpoint string
+	client   *http.Client
+}
+
+// NewSendGridMailer returns a mailer sending from the from address, which
+// must be a verified SendGrid sender, with apiKey.
+func NewSendGridMailer(apiKey, from string) (*SendGridMailer, error) {
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", from, err)
+	}
+	return &SendGridMailer{
+		apiKey:   apiKey,
+		from:     sendGridAddress{Email: sender.Address, Name: sender.Name},
+		endpoint: sendGridEndpoint,
+		client:   &http.Client{Timeout: sendGridTimeout},
+	}, nil
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridPersonalization struct {
+	To []sendGridAddress `json:"to"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridRequest struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+}
+
+// Send implements Mailer. Every recipient receives the same message.
+func (m *SendGridMailer) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+
+	to := make([]sendGridAddress, len(msg.To))
+	for i, recipient := range msg.To {
+		addr, _ := mail.ParseAddress(recipient)
+		to[i] = sendGridAddress{Email: addr.Address, Name: addr.Name}
+	}
+	payload := sendGridRequest{
+		Personalizations: []sendGridPersonalization{{To: to}},
+		From:             m.from,
+		Subject:          msg.Subject,
+		Content:          []sendGridContent{{Type: "text/plain", Value: msg.Body}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call SendGrid: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("SendGrid returned %s: %s", resp.Status, detail)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPMailer sends emails through an SMTP server, upgrading to TLS when the
+// server offers STARTTLS.
+type SMTPMailer struct {
+	addr string
+	from *mail.Address
+	auth smtp.Auth
+	// sendMail is smtp.SendMail, replaced in tests.
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPMailer returns a mailer sending from the from address through the
+// server at addr (host:port). An empty username disables authentication.
+func NewSMTPMailer(addr, username, password, from string) (*SMTPMailer, error) {
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", from, err)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP address %q: %w", addr, err)
+	}
+	m := &SMTPMailer{addr: addr, from: sender, sendMail: smtp.SendMail}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m, nil
+}
+
+// Send implements Mailer. net/smtp does not take a context, so ctx is only
+// checked before connecting.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	recipients := make([]string, len(msg.To))
+	headerTo := make([]string, len(msg.To))
+	for i, to := range msg.To {
+		addr, _ := mail.ParseAddress(to)
+		recipients[i] = addr.Address
+		headerTo[i] = addr.String()
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", m.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(headerTo, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	body := quotedprintable.NewWriter(&buf)
+	if _, err := body.Write([]byte(msg.Body)); err != nil {
+		return err
+	}
+	if err := body.Close(); err != nil {
+		return err
+	}
+
+	if err := m.sendMail(m.addr, m.auth, m.from.Address, recipients, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to send email via %s: %w", m.addr, err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+// Package leader elects, among the instances of a service sharing a
+// database, the one running a singleton job such as a scheduled purge. The
+// election is an advisory lock: the instance taking the lock of the job
+// leads until the run returns and releases it, while the others skip the
+// run. Nothing is held between runs, so a crashed leader is replaced at
+// the next run of another instance.
+package leader
+
+import (
+	"context"
+	"hash/fnv"
+)
+
+// Locker grants advisory locks shared by every instance of the service;
+// *db.Repository implements it with PostgreSQL advisory locks, and grants
+// every lock on other databases, which serve a single instance.
+type Locker interface {
+	TryAdvisoryLock(ctx context.Context, key int64) (release func(), ok bool, err error)
+}
+
+// Key derives the advisory lock key of the job named name. Names are
+// namespaced, e.g. "xm.janitor", as every service using the database
+// shares the keys.
+func Key(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// Do runs fn if this instance takes the lock key, releasing it once fn
+// returns, and reports whether fn ran. When another instance holds the lock
+// it returns false without running fn. An error is that of fn when fn ran,
+// and otherwise that of taking the lock.
+func Do(ctx context.Context, locker Locker, key int64, fn func(ctx context.Context) error) (bool, error) {
+	release, ok, err := locker.TryAdvisoryLock(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, nil
+	}
+	defer release()
+	return true, fn(ctx)
+}
@@ -0,0 +1,56 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLocker grants its lock unless held is set.
+type fakeLocker struct {
+	held     bool
+	err      error
+	released int
+}
+
+func (l *fakeLocker) TryAdvisoryLock(context.Context, int64) (func(), bool, error) {
+	if l.err != nil || l.held {
+		return nil, false, l.err
+	}
+	return func() { l.released++ }, true, nil
+}
+
+func TestKey(t *testing.T) {
+	assert.Equal(t, Key("xm.janitor"), Key("xm.janitor"))
+	assert.NotEqual(t, Key("xm.janitor"), Key("xm.export"))
+}
+
+func TestDo(t *testing.T) {
+	ctx := context.Background()
+	locker := &fakeLocker{}
+	calls := 0
+	errFailed := errors.New("purge failed")
+	fn := func(context.Context) error {
+		calls++
+		assert.Zero(t, locker.released, "the lock should be held while the job runs")
+		return errFailed
+	}
+
+	ran, err := Do(ctx, locker, Key("job"), fn)
+	assert.True(t, ran)
+	assert.ErrorIs(t, err, errFailed)
+	assert.Equal(t, 1, locker.released)
+
+	locker.held = true
+	ran, err = Do(ctx, locker, Key("job"), fn)
+	assert.False(t, ran)
+	assert.NoError(t, err)
+
+	locker.held, locker.err = false, errors.New("connection refused")
+	ran, err = Do(ctx, locker, Key("job"), fn)
+	assert.False(t, ran)
+	assert.EqualError(t, err, "connection refused")
+	assert.Equal(t, 1, calls)
+}
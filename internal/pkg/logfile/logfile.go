@@ -0,0 +1,111 @@
+// Package logfile writes logs to a file rotated by size, keeping a bounded
+// number of old files next to it.
+package logfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// File is an append-only log file. Once a write would grow it past its size
+// limit it is renamed to <path>.1, older files move up one number, the
+// oldest beyond the kept backups is removed, and writing continues in a new
+// file. Each write lands whole in one file. It is safe for concurrent use.
+type File struct {
+	path     string
+	maxBytes int64
+	backups  int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Open opens, or creates, the log file at path for appending. maxBytes
+// limits its size, 0 meaning no limit, and backups is the number of rotated
+// files kept.
+func Open(path string, maxBytes int64, backups int) (*File, error) {
+	if maxBytes < 0 || backups < 0 {
+		return nil, errors.New("log file size limit and backups must not be negative")
+	}
+	f := &File{path: path, maxBytes: maxBytes, backups: backups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("open log file: %w", err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write appends p, rotating the file first when p would take it past the
+// size limit. An entry larger than the limit gets a file of its own.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxBytes > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxBytes {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate moves the current file out of the way and opens a new one.
+func (f *File) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("rotate log file: %w", err)
+	}
+	f.file = nil
+	if f.backups == 0 {
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("rotate log file: %w", err)
+		}
+		return f.open()
+	}
+	for i := f.backups - 1; i > 0; i-- {
+		err := os.Rename(f.backup(i), f.backup(i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("rotate log file: %w", err)
+		}
+	}
+	if err := os.Rename(f.path, f.backup(1)); err != nil {
+		return fmt.Errorf("rotate log file: %w", err)
+	}
+	return f.open()
+}
+
+// backup returns the path of the i-th most recent rotated file.
+func (f *File) backup(i int) string {
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
+
+// Close closes the file; later writes fail.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,59 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFile_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := Open(path, 10, 2)
+	require.NoError(t, err)
+	defer f.Close()
+
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	read := func(path string) string {
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return string(b)
+	}
+	assert.Equal(t, "four\nfive\n", read(path))
+	assert.Equal(t, "three\n", read(path+".1"))
+	assert.Equal(t, "one\ntwo\n", read(path+".2"))
+
+	// The oldest file beyond the backups is removed.
+	_, err = f.Write([]byte("six six\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "six six\n", read(path))
+	assert.Equal(t, "four\nfive\n", read(path+".1"))
+	assert.Equal(t, "three\n", read(path+".2"))
+	assert.NoFileExists(t, path+".3")
+}
+
+func TestFile_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	require.NoError(t, os.WriteFile(path, []byte("12345678\n"), 0o644))
+
+	// The size of the existing file counts towards the limit.
+	f, err := Open(path, 10, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("next\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "next\n", string(b))
+	assert.NoFileExists(t, path+".1")
+
+	_, err = f.Write([]byte("closed\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
@@ -0,0 +1,79 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gartstein/xm/internal/pkg/awssig"
+)
+
+// AWSCredentials are the keys used to sign Secrets Manager requests.
+type AWSCredentials = awssig.Credentials
+
+// AWSSecretsManagerProvider reads secrets from AWS Secrets Manager:
+// awssm://secret-id#key. Requests are signed with Signature Version 4.
+type AWSSecretsManagerProvider struct {
+	region   string
+	endpoint string
+	creds    AWSCredentials
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewAWSSecretsManagerProvider returns a provider for region. An empty
+// endpoint uses the regional public endpoint.
+func NewAWSSecretsManagerProvider(region, endpoint string, creds AWSCredentials) *AWSSecretsManagerProvider {
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", region)
+	}
+	return &AWSSecretsManagerProvider{
+		region:   region,
+		endpoint: endpoint,
+		creds:    creds,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		now:      time.Now,
+	}
+}
+
+// Get implements Provider.
+func (p *AWSSecretsManagerProvider) Get(ctx context.Context, ref Ref) (string, error) {
+	payload, err := json.Marshal(map[string]string{"SecretId": ref.Path})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	p.sign(req, payload)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("secrets manager returned %s: %s", resp.Status, msg)
+	}
+
+	var body struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode secrets manager response: %w", err)
+	}
+	return selectKey(body.SecretString, ref.Key)
+}
+
+// sign adds SigV4 authentication headers for the secretsmanager service.
+func (p *AWSSecretsManagerProvider) sign(req *http.Request, payload []byte) {
+	awssig.Sign(req, payload, p.creds, p.region, "secretsmanager", p.now())
+}
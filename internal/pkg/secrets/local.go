@@ -0,0 +1,33 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvProvider reads secrets from environment variables: env://NAME.
+type EnvProvider struct{}
+
+// Get implements Provider.
+func (EnvProvider) Get(_ context.Context, ref Ref) (string, error) {
+	v, ok := os.LookupEnv(ref.Path)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s not set", ref.Path)
+	}
+	return selectKey(v, ref.Key)
+}
+
+// FileProvider reads secrets from files, such as mounted Kubernetes or Docker
+// secrets: file:///run/secrets/jwt. Surrounding whitespace is trimmed.
+type FileProvider struct{}
+
+// Get implements Provider.
+func (FileProvider) Get(_ context.Context, ref Ref) (string, error) {
+	b, err := os.ReadFile(ref.Path)
+	if err != nil {
+		return "", err
+	}
+	return selectKey(strings.TrimSpace(string(b)), ref.Key)
+}
@@ -0,0 +1,147 @@
+// Package secrets resolves configuration values that reference external
+// secret stores, so credentials need not live in the config file.
+//
+// A value of the form scheme://path#key is looked up with the provider
+// registered for scheme; any other value is returned unchanged. Supported
+// schemes are env://NAME, file:///path, vault://mount/path#key and
+// awssm://secret-id#key.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Ref identifies a secret within a provider.
+type Ref struct {
+	// Path locates the secret, e.g. a file path or Vault secret path.
+	Path string
+	// Key selects a field when the secret holds a JSON object; empty means
+	// the whole value.
+	Key string
+}
+
+// Provider fetches secrets from one backend.
+type Provider interface {
+	Get(ctx context.Context, ref Ref) (string, error)
+}
+
+// Resolver dispatches secret references to providers by scheme.
+type Resolver struct {
+	providers map[string]Provider
+}
+
+// NewResolver returns a Resolver with env and file providers registered.
+func NewResolver() *Resolver {
+	return &Resolver{providers: map[string]Provider{
+		"env":  EnvProvider{},
+		"file": FileProvider{},
+	}}
+}
+
+// FromEnv returns a resolver with the stores configured through the
+// environment: Vault via VAULT_ADDR and VAULT_TOKEN, AWS Secrets Manager via
+// AWS_REGION and the standard AWS credential variables.
+func FromEnv() *Resolver {
+	r := NewResolver()
+	if addr := os.Getenv("VAULT_ADDR"); addr != "" {
+		r.Register("vault", NewVaultProvider(addr, os.Getenv("VAULT_TOKEN")))
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		r.Register("awssm", NewAWSSecretsManagerProvider(region, "", AWSCredentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}))
+	}
+	return r
+}
+
+// Register adds or replaces the provider for scheme.
+func (r *Resolver) Register(scheme string, p Provider) {
+	r.providers[scheme] = p
+}
+
+// IsRef reports whether value is a secret reference rather than a literal.
+func (r *Resolver) IsRef(value string) bool {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return false
+	}
+	_, known := r.providers[scheme]
+	return known
+}
+
+// Resolve returns the secret value referenced by value, or value itself when
+// it is not a reference.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	scheme, rest, ok := strings.Cut(value, "://")
+	if !ok {
+		return value, nil
+	}
+	p, known := r.providers[scheme]
+	if !known {
+		return value, nil
+	}
+
+	path, key, _ := strings.Cut(rest, "#")
+	secret, err := p.Get(ctx, Ref{Path: path, Key: key})
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s secret %q: %w", scheme, path, err)
+	}
+	return secret, nil
+}
+
+// Watch re-resolves value every interval until ctx is done, calling onChange
+// whenever the secret differs from the last value seen. Resolution errors are
+// passed to onError and the previous value is kept.
+func (r *Resolver) Watch(ctx context.Context, value string, current string, interval time.Duration, onChange func(string), onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			secret, err := r.Resolve(ctx, value)
+			if err != nil {
+				onError(err)
+				continue
+			}
+			if secret != current {
+				current = secret
+				onChange(secret)
+			}
+		}
+	}
+}
+
+// selectKey returns the field key of a JSON object secret, or the raw value
+// when key is empty.
+func selectKey(raw string, key string) (string, error) {
+	if key == "" {
+		return raw, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+	return field(fields, key)
+}
+
+func field(fields map[string]interface{}, key string) (string, error) {
+	v, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found", key)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("key %q is not a string", key)
+	}
+	return s, nil
+}
@@ -0,0 +1,141 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	ctx := context.Background()
+	r := NewResolver()
+
+	t.Setenv("XM_TEST_SECRET", "from-env")
+	t.Setenv("XM_TEST_JSON", `{"password":"pw"}`)
+	path := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0o600))
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"literal", "literal", false},
+		{"postgres://not-a-secret", "postgres://not-a-secret", false},
+		{"env://XM_TEST_SECRET", "from-env", false},
+		{"env://XM_TEST_JSON#password", "pw", false},
+		{"env://XM_TEST_JSON#missing", "", true},
+		{"env://XM_TEST_UNSET", "", true},
+		{"file://" + path, "from-file", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := r.Resolve(ctx, tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	assert.True(t, r.IsRef("env://XM_TEST_SECRET"))
+	assert.False(t, r.IsRef("literal"))
+	assert.False(t, r.IsRef("postgres://host"))
+}
+
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "/v1/secret/data/xm/company", r.URL.Path)
+		_, _ = w.Write([]byte(`{"data":{"data":{"jwt_secret":"s3cret"}}}`))
+	}))
+	defer server.Close()
+
+	r := NewResolver()
+	r.Register("vault", NewVaultProvider(server.URL, "root"))
+
+	got, err := r.Resolve(context.Background(), "vault://secret/xm/company#jwt_secret")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", got)
+
+	r.Register("vault", NewVaultProvider(server.URL, "wrong"))
+	_, err = r.Resolve(context.Background(), "vault://secret/xm/company#jwt_secret")
+	assert.Error(t, err)
+}
+
+func TestAWSSecretsManagerProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+		assert.Equal(t, "token", r.Header.Get("X-Amz-Security-Token"))
+
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "xm/company", body["SecretId"])
+		_, _ = w.Write([]byte(`{"SecretString":"{\"jwt_secret\":\"s3cret\"}"}`))
+	}))
+	defer server.Close()
+
+	p := NewAWSSecretsManagerProvider("eu-west-1", server.URL, AWSCredentials{
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+	})
+	got, err := p.Get(context.Background(), Ref{Path: "xm/company", Key: "jwt_secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", got)
+}
+
+// sequenceProvider returns successive values on each call.
+type sequenceProvider struct {
+	values []string
+	calls  atomic.Int32
+}
+
+func (p *sequenceProvider) Get(context.Context, Ref) (string, error) {
+	i := int(p.calls.Add(1)) - 1
+	if i >= len(p.values) {
+		i = len(p.values) - 1
+	}
+	if p.values[i] == "" {
+		return "", errors.New("unavailable")
+	}
+	return p.values[i], nil
+}
+
+func TestResolver_Watch(t *testing.T) {
+	r := NewResolver()
+	r.Register("seq", &sequenceProvider{values: []string{"v1", "", "v2"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan string, 1)
+	var errs atomic.Int32
+	go r.Watch(ctx, "seq://x", "v1", time.Millisecond, func(s string) {
+		changes <- s
+		cancel()
+	}, func(error) { errs.Add(1) })
+
+	select {
+	case got := <-changes:
+		assert.Equal(t, "v2", got)
+		assert.Equal(t, int32(1), errs.Load(), "a failed refresh should be reported and skipped")
+	case <-time.After(time.Second):
+		t.Fatal("rotation was not observed")
+	}
+}
@@ -0,0 +1,62 @@
+// Package version describes the build of the running binary.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Build details, set at link time:
+//
+//	go build -ldflags "-X github.com/gartstein/xm/internal/pkg/version.Version=v1.4.0 \
+//	  -X github.com/gartstein/xm/internal/pkg/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/gartstein/xm/internal/pkg/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Commit and BuildTime fall back to the VCS details Go embeds when building
+// from a checkout.
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// started approximates the time the process started.
+var started = time.Now()
+
+// Info describes the running build.
+type Info struct {
+	Version   string
+	Commit    string
+	BuildTime string
+	GoVersion string
+	StartedAt time.Time
+}
+
+// Get returns the details of the running build.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+		StartedAt: started,
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+	return info
+}
+
+// UserAgent returns component tagged with the version, e.g.
+// "company-service/v1.4.0", for headers identifying the sender.
+func UserAgent(component string) string {
+	return component + "/" + Version
+}
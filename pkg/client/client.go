@@ -0,0 +1,145 @@
+// Package client is the Go client of the company service gRPC API. Every
+// call sends the configured token or API key and is retried according to a
+// RetryPolicy; Companies pages through list results so integrators don't
+// hand-roll pagination loops:
+//
+//	c, err := client.New("companies.example.com:443", client.WithToken(getJWT))
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//	for company, err := range c.Companies.List(ctx, client.ListFilter{Statuses: []apiv1.CompanyStatus{apiv1.CompanyStatus_ACTIVE}}).All() {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(company.GetName())
+//	}
+//
+// The generated clients are available as V1 and V2 for all RPCs.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	pbv2 "github.com/gartstein/xm/api/gen/definition/v2"
+	"github.com/gartstein/xm/internal/company/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// Client is a connection to the company service.
+type Client struct {
+	conn *grpc.ClientConn
+	// V1 and V2 are the generated clients of both API versions.
+	V1 pb.CompanyServiceClient
+	V2 pbv2.CompanyServiceClient
+	// Companies pages through the company listings.
+	Companies *Companies
+}
+
+// options configures a Client created by New.
+type options struct {
+	token       func(ctx context.Context) (string, error)
+	apiKey      string
+	insecure    bool
+	retry       RetryPolicy
+	dialOptions []grpc.DialOption
+}
+
+// Option configures a Client created by New.
+type Option func(*options)
+
+// WithToken sends the JWT returned by token as a bearer token with every
+// call. token is asked on every call, so refreshed tokens are picked up.
+func WithToken(token func(ctx context.Context) (string, error)) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithAPIKey sends key with every call, for service callers.
+func WithAPIKey(key string) Option {
+	return func(o *options) {
+		o.apiKey = key
+	}
+}
+
+// WithInsecure connects without TLS, e.g. to a local service.
+func WithInsecure() Option {
+	return func(o *options) {
+		o.insecure = true
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = policy
+	}
+}
+
+// WithDialOptions adds options to the connection, e.g. a custom dialer in
+// tests. The credential and retry interceptors still apply.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dialOptions = append(o.dialOptions, opts...)
+	}
+}
+
+// New returns a Client of the service at target, connecting over TLS
+// unless WithInsecure is given. The connection is made lazily, on the
+// first call.
+func New(target string, opts ...Option) (*Client, error) {
+	o := options{retry: DefaultRetryPolicy}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	transport := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if o.insecure {
+		transport = insecure.NewCredentials()
+	}
+	dialOptions := append([]grpc.DialOption{
+		grpc.WithTransportCredentials(transport),
+		grpc.WithChainUnaryInterceptor(authInterceptor(o.token, o.apiKey), retryInterceptor(o.retry)),
+	}, o.dialOptions...)
+	conn, err := grpc.NewClient(target, dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		conn: conn,
+		V1:   pb.NewCompanyServiceClient(conn),
+		V2:   pbv2.NewCompanyServiceClient(conn),
+	}
+	c.Companies = &Companies{v1: c.V1, v2: c.V2}
+	return c, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// authInterceptor adds the bearer token returned by token and apiKey, when
+// set, to the metadata of every call.
+func authInterceptor(token func(ctx context.Context) (string, error), apiKey string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if token != nil {
+			t, err := token(ctx)
+			if err != nil {
+				return err
+			}
+			if t != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+t)
+			}
+		}
+		if apiKey != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, auth.APIKeyHeader, apiKey)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
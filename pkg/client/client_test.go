@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// fakeServer serves five companies, two per page, after failing the first
+// call with UNAVAILABLE.
+type fakeServer struct {
+	pb.UnimplementedCompanyServiceServer
+	calls atomic.Int32
+}
+
+func (s *fakeServer) ListCompanies(ctx context.Context, req *pb.ListCompaniesRequest) (*pb.ListCompaniesResponse, error) {
+	if s.calls.Add(1) == 1 {
+		return nil, status.Error(codes.Unavailable, "overloaded")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if got := md.Get("authorization"); len(got) != 1 || got[0] != "Bearer jwt" {
+		return nil, status.Error(codes.Unauthenticated, "missing token")
+	}
+	if req.GetStatuses()[0] != pb.CompanyStatus_ACTIVE {
+		return nil, status.Error(codes.InvalidArgument, "filter not sent")
+	}
+	offset, _ := strconv.Atoi(req.GetPageToken())
+	resp := &pb.ListCompaniesResponse{}
+	for i := offset; i < min(offset+int(req.GetPageSize()), 5); i++ {
+		resp.Companies = append(resp.Companies, &pb.Company{Id: strconv.Itoa(i)})
+	}
+	if offset+int(req.GetPageSize()) < 5 {
+		resp.NextPageToken = strconv.Itoa(offset + int(req.GetPageSize()))
+	}
+	return resp, nil
+}
+
+func newTestClient(t *testing.T, server pb.CompanyServiceServer) *Client {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	pb.RegisterCompanyServiceServer(srv, server)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	c, err := New(lis.Addr().String(), WithInsecure(),
+		WithToken(func(context.Context) (string, error) { return "jwt", nil }),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestCompanies_List(t *testing.T) {
+	server := &fakeServer{}
+	c := newTestClient(t, server)
+	it := c.Companies.List(context.Background(), ListFilter{Statuses: []pb.CompanyStatus{pb.CompanyStatus_ACTIVE}, PageSize: 2})
+
+	var sizes []int
+	for page, err := range it.Pages() {
+		require.NoError(t, err)
+		sizes = append(sizes, len(page))
+	}
+	assert.Equal(t, []int{2, 2, 1}, sizes)
+	assert.Equal(t, int32(4), server.calls.Load(), "the unavailable call should have been retried")
+
+	var ids []string
+	for company, err := range it.All() {
+		require.NoError(t, err)
+		ids = append(ids, company.GetId())
+		if len(ids) == 3 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"0", "1", "2"}, ids)
+	assert.Equal(t, int32(6), server.calls.Load(), "stopping early should save the last page")
+
+	companies, err := it.Collect()
+	require.NoError(t, err)
+	assert.Len(t, companies, 5)
+}
+
+func TestCompanies_ListError(t *testing.T) {
+	c := newTestClient(t, &pb.UnimplementedCompanyServiceServer{})
+	var errs []error
+	for company, err := range c.Companies.List(context.Background(), ListFilter{}).All() {
+		assert.Nil(t, company)
+		errs = append(errs, err)
+	}
+	require.Len(t, errs, 1)
+	assert.Equal(t, codes.Unimplemented, status.Code(errs[0]))
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	unavailable := status.Error(codes.Unavailable, "overloaded")
+
+	d, ok := policy.delay(2, unavailable)
+	assert.True(t, ok)
+	assert.True(t, d >= 100*time.Millisecond && d <= 200*time.Millisecond, "expected jittered backoff, got %v", d)
+	_, ok = policy.delay(3, unavailable)
+	assert.False(t, ok, "attempts exhausted")
+	_, ok = policy.delay(1, status.Error(codes.InvalidArgument, "bad"))
+	assert.False(t, ok, "invalid requests are not retried")
+	_, ok = policy.delay(1, errors.New("dial failed"))
+	assert.False(t, ok)
+
+	// The server's RetryInfo wins, capped at MaxBackoff.
+	st, err := status.New(codes.ResourceExhausted, "quota").WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(5 * time.Second)})
+	require.NoError(t, err)
+	d, ok = policy.delay(1, st.Err())
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, d)
+}
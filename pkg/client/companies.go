@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"iter"
+
+	pb "github.com/gartstein/xm/api/gen/definition/v1"
+	pbv2 "github.com/gartstein/xm/api/gen/definition/v2"
+)
+
+// DefaultPageSize is the page size the iterators ask for unless told
+// otherwise; the server caps it at 100.
+const DefaultPageSize = 100
+
+// ListFilter selects companies when listing. Empty fields match every
+// company.
+type ListFilter struct {
+	EmployeeRanges []pb.EmployeeRange
+	Statuses       []pb.CompanyStatus
+	// Metadata matches companies carrying all these metadata entries.
+	Metadata map[string]string
+	// PageSize is the number of companies fetched per call; 0 selects
+	// DefaultPageSize.
+	PageSize int32
+}
+
+// pageSize returns the page size of f.
+func (f ListFilter) pageSize() int32 {
+	if f.PageSize <= 0 {
+		return DefaultPageSize
+	}
+	return f.PageSize
+}
+
+// Companies pages through the company listings of the API.
+type Companies struct {
+	v1 pb.CompanyServiceClient
+	v2 pbv2.CompanyServiceClient
+}
+
+// List returns the companies matching filter, oldest first.
+func (c *Companies) List(ctx context.Context, filter ListFilter) *Iterator[*pb.Company] {
+	return newIterator(ctx, func(ctx context.Context, token string) ([]*pb.Company, string, error) {
+		resp, err := c.v1.ListCompanies(ctx, &pb.ListCompaniesRequest{
+			PageSize:       filter.pageSize(),
+			PageToken:      token,
+			EmployeeRanges: filter.EmployeeRanges,
+			Statuses:       filter.Statuses,
+			Metadata:       filter.Metadata,
+		})
+		return resp.GetCompanies(), resp.GetNextPageToken(), err
+	})
+}
+
+// ListMine returns the companies created by the caller matching filter,
+// oldest first. Employee ranges are not filtered on.
+func (c *Companies) ListMine(ctx context.Context, filter ListFilter) *Iterator[*pb.Company] {
+	return newIterator(ctx, func(ctx context.Context, token string) ([]*pb.Company, string, error) {
+		resp, err := c.v1.ListMyCompanies(ctx, &pb.ListMyCompaniesRequest{
+			PageSize:  filter.pageSize(),
+			PageToken: token,
+			Statuses:  filter.Statuses,
+			Metadata:  filter.Metadata,
+		})
+		return resp.GetCompanies(), resp.GetNextPageToken(), err
+	})
+}
+
+// Search returns the companies whose name contains query, ignoring case,
+// fetching pageSize at a time; 0 selects DefaultPageSize.
+func (c *Companies) Search(ctx context.Context, query string, pageSize int32) *Iterator[*pbv2.Company] {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	return newIterator(ctx, func(ctx context.Context, token string) ([]*pbv2.Company, string, error) {
+		resp, err := c.v2.SearchCompanies(ctx, &pbv2.SearchCompaniesRequest{Query: query, PageSize: pageSize, PageToken: token})
+		return resp.GetCompanies(), resp.GetNextPageToken(), err
+	})
+}
+
+// Iterator walks a paginated listing, following the page tokens of the
+// API. Pages are fetched as the iteration proceeds, so stopping early saves
+// the remaining calls. An Iterator can be ranged over more than once; each
+// range starts over from the first page.
+type Iterator[T any] struct {
+	ctx   context.Context
+	fetch func(ctx context.Context, token string) ([]T, string, error)
+}
+
+func newIterator[T any](ctx context.Context, fetch func(ctx context.Context, token string) ([]T, string, error)) *Iterator[T] {
+	return &Iterator[T]{ctx: ctx, fetch: fetch}
+}
+
+// Pages yields the listing one page at a time. A failed call yields its
+// error and ends the iteration.
+func (it *Iterator[T]) Pages() iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		token := ""
+		for {
+			page, next, err := it.fetch(it.ctx, token)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(page, nil) || next == "" {
+				return
+			}
+			token = next
+		}
+	}
+}
+
+// All yields the items of every page in turn. A failed call yields its
+// error, with the zero item, and ends the iteration.
+func (it *Iterator[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for page, err := range it.Pages() {
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range page {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Collect returns every item of the listing, or the first error.
+func (it *Iterator[T]) Collect() ([]T, error) {
+	var items []T
+	for page, err := range it.Pages() {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page...)
+	}
+	return items, nil
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy is how calls failing with a transient error are retried.
+// UNAVAILABLE calls, such as shed or read-only ones, are retried with
+// exponential backoff and jitter. Calls failing with another code are
+// retried only when the server says when, e.g. for a per-minute mutation
+// quota; the delay of the server's RetryInfo always takes precedence.
+type RetryPolicy struct {
+	// MaxAttempts bounds the attempts of a call, the first included; 1
+	// disables retries.
+	MaxAttempts int
+	// InitialBackoff is the longest wait before the first retry; it doubles
+	// with every further retry, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy makes up to 4 attempts, waiting up to 0.5s, 1s and 2s
+// in between.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 4, InitialBackoff: 500 * time.Millisecond, MaxBackoff: 10 * time.Second}
+
+// delay returns how long to wait before retrying a call whose attempt
+// failed with err, and false to give up.
+func (p RetryPolicy) delay(attempt int, err error) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts {
+		return 0, false
+	}
+	st := status.Convert(err)
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			return min(info.GetRetryDelay().AsDuration(), p.MaxBackoff), true
+		}
+	}
+	if st.Code() != codes.Unavailable {
+		return 0, false
+	}
+	backoff := min(p.InitialBackoff<<(attempt-1), p.MaxBackoff)
+	if backoff <= 0 {
+		return 0, true
+	}
+	return backoff/2 + rand.N(backoff/2+1), true
+}
+
+// sleep waits for d or until ctx is done; tests replace it.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryInterceptor retries calls according to policy. The context of the
+// call bounds the attempts and the waits between them.
+func retryInterceptor(policy RetryPolicy) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		for attempt := 1; ; attempt++ {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil {
+				return nil
+			}
+			d, ok := policy.delay(attempt, err)
+			if !ok {
+				return err
+			}
+			if sleep(ctx, d) != nil {
+				return err
+			}
+		}
+	}
+}
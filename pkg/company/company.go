@@ -0,0 +1,59 @@
+// Package company exports the interfaces of the company service, and the
+// types in their signatures, for code outside this module: the storage the
+// controller runs on, the producer it publishes events through and the
+// controller the gRPC and HTTP handlers call. Package mocks holds mocks of
+// them, generated with mockery, to test against instead of hand-rolled
+// doubles.
+package company
+
+import (
+	"github.com/gartstein/xm/internal/company/controller"
+	"github.com/gartstein/xm/internal/company/db"
+	"github.com/gartstein/xm/internal/company/events"
+	"github.com/gartstein/xm/internal/company/handlers"
+	"github.com/gartstein/xm/internal/company/models"
+)
+
+//go:generate sh -c "cd ../.. && go run github.com/vektra/mockery/v2@v2.53.3"
+
+// The interfaces, mocked in package mocks.
+type (
+	// Repository is the storage CompanyService runs on.
+	Repository = controller.Repository
+	// UnitOfWork groups the writes of Repository into one transaction.
+	UnitOfWork = controller.UnitOfWork
+	// EventProducer publishes the events of CompanyService.
+	EventProducer = controller.EventProducer
+	// Controller is the business logic the gRPC and HTTP handlers call;
+	// CompanyService implements it.
+	Controller = handlers.CompanyController
+)
+
+// CompanyService is the Controller of the service, built on a Repository
+// and an EventProducer by NewCompanyService.
+type CompanyService = controller.CompanyService
+
+// NewCompanyService returns a CompanyService storing companies in repo and
+// publishing events through producer.
+var NewCompanyService = controller.NewCompanyService
+
+// The types in the signatures of the interfaces.
+type (
+	Company            = models.Company
+	CompanyUpdate      = models.CompanyUpdate
+	CompanyFilter      = models.CompanyFilter
+	CompanyChange      = models.CompanyChange
+	CompanyEvent       = models.CompanyEvent
+	CompanyEventFilter = models.CompanyEventFilter
+	CompanyNote        = models.CompanyNote
+	Employee           = models.Employee
+	CreateOptions      = models.CreateOptions
+	UpdateOptions      = models.UpdateOptions
+	ApplyOptions       = models.ApplyOptions
+	ValidationResult   = models.ValidationResult
+	Event              = events.Event
+	EventType          = events.EventType
+	// DB is the database handed to the functions run by
+	// Repository.WithTransaction.
+	DB = db.Repository
+)
@@ -0,0 +1,49 @@
+package company_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gartstein/xm/pkg/company"
+	"github.com/gartstein/xm/pkg/company/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+// The mocks implement the interfaces they are generated from.
+var (
+	_ company.Repository    = (*mocks.Repository)(nil)
+	_ company.EventProducer = (*mocks.EventProducer)(nil)
+	_ company.Controller    = (*mocks.Controller)(nil)
+	_ company.Controller    = (*company.CompanyService)(nil)
+)
+
+func TestMocks_CompanyService(t *testing.T) {
+	repo := mocks.NewRepository(t)
+	producer := mocks.NewEventProducer(t)
+	ctx := context.Background()
+
+	repo.On("RunInTransaction", ctx, mock.Anything).Return(func(ctx context.Context, fn func(context.Context) error) error {
+		return fn(ctx)
+	})
+	repo.On("CompanyExistsByName", ctx, "Acme").Return(false, nil)
+	repo.On("CreateCompany", ctx, mock.AnythingOfType("*models.Company")).Return(nil)
+	repo.On("RecordCompanyEvent", ctx, mock.AnythingOfType("*models.CompanyEvent")).Return(nil)
+	producer.On("Produce", mock.MatchedBy(func(event company.Event) bool {
+		return event.Company.Name == "Acme"
+	})).Once()
+
+	service := company.NewCompanyService(repo, producer, zaptest.NewLogger(t))
+	created, err := service.CreateCompany(ctx, &company.Company{Name: "Acme"}, company.CreateOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Acme", created.Name)
+
+	// A controller mock stands in for the service.
+	controller := mocks.NewController(t)
+	controller.On("CreateCompany", ctx, mock.Anything, company.CreateOptions{}).Return(nil, errors.New("unavailable"))
+	_, err = controller.CreateCompany(ctx, &company.Company{Name: "Acme"}, company.CreateOptions{})
+	assert.EqualError(t, err, "unavailable")
+}
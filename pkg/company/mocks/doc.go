@@ -0,0 +1,4 @@
+// Package mocks holds testify mocks of the interfaces exported by package
+// company. The mocks are generated by mockery from .mockery.yaml; run
+// `make mocks` after changing an interface instead of editing them.
+package mocks
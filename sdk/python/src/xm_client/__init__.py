@@ -0,0 +1,13 @@
+"""Python client of the company service, generated from api/definition.
+
+The generated messages are importable as ``xm_client.v1`` and
+``xm_client.v2``.
+"""
+
+from definition.v1 import api_pb2 as v1
+from definition.v2 import api_pb2 as v2
+
+from ._interceptors import RetryPolicy, TokenSource
+from .client import CompanyClient
+
+__all__ = ["CompanyClient", "RetryPolicy", "TokenSource", "v1", "v2"]
@@ -0,0 +1,109 @@
+"""Client interceptors adding credentials to and retrying company service calls."""
+
+import random
+import time
+from dataclasses import dataclass, field
+from typing import Callable, FrozenSet, Optional, Union
+
+import grpc
+from google.rpc import error_details_pb2
+from grpc_status import rpc_status
+
+TokenSource = Union[str, Callable[[], Optional[str]]]
+
+
+class _ClientCallDetails(grpc.ClientCallDetails):
+    def __init__(self, details, metadata):
+        self.method = details.method
+        self.timeout = details.timeout
+        self.metadata = metadata
+        self.credentials = details.credentials
+        self.wait_for_ready = details.wait_for_ready
+        self.compression = details.compression
+
+
+class AuthInterceptor(grpc.UnaryUnaryClientInterceptor):
+    """Sends a bearer token or an API key with every call.
+
+    token may be a callable, which is asked for the current token on every
+    call so refreshed tokens are picked up.
+    """
+
+    def __init__(self, token: Optional[TokenSource] = None, api_key: Optional[str] = None):
+        self._token = token
+        self._api_key = api_key
+
+    def intercept_unary_unary(self, continuation, client_call_details, request):
+        metadata = list(client_call_details.metadata or [])
+        token = self._token() if callable(self._token) else self._token
+        if token:
+            metadata.append(("authorization", f"Bearer {token}"))
+        if self._api_key:
+            metadata.append(("x-api-key", self._api_key))
+        return continuation(_ClientCallDetails(client_call_details, metadata), request)
+
+
+@dataclass(frozen=True)
+class RetryPolicy:
+    """How calls failing with a transient error are retried.
+
+    UNAVAILABLE calls, such as shed or read-only ones, are retried with
+    exponential backoff and jitter. RESOURCE_EXHAUSTED calls are retried only
+    when the server says when, e.g. for a per-minute mutation quota; the
+    delay of the server's RetryInfo always takes precedence.
+    """
+
+    max_attempts: int = 4
+    initial_backoff: float = 0.5
+    max_backoff: float = 10.0
+    retryable_codes: FrozenSet[grpc.StatusCode] = field(
+        default_factory=lambda: frozenset({grpc.StatusCode.UNAVAILABLE})
+    )
+
+    def delay(self, attempt: int, error: grpc.RpcError) -> Optional[float]:
+        """Returns the seconds to wait before retrying, or None to give up."""
+        if attempt >= self.max_attempts:
+            return None
+        server_delay = _retry_delay(error)
+        if server_delay is not None:
+            return min(server_delay, self.max_backoff)
+        if error.code() not in self.retryable_codes:
+            return None
+        backoff = min(self.initial_backoff * 2 ** (attempt - 1), self.max_backoff)
+        return random.uniform(backoff / 2, backoff)
+
+
+class RetryInterceptor(grpc.UnaryUnaryClientInterceptor):
+    """Retries calls according to a RetryPolicy."""
+
+    def __init__(self, policy: RetryPolicy, sleep: Callable[[float], None] = time.sleep):
+        self._policy = policy
+        self._sleep = sleep
+
+    def intercept_unary_unary(self, continuation, client_call_details, request):
+        attempt = 1
+        while True:
+            call = continuation(client_call_details, request)
+            if call.code() == grpc.StatusCode.OK:
+                return call
+            delay = self._policy.delay(attempt, call)
+            if delay is None:
+                return call
+            self._sleep(delay)
+            attempt += 1
+
+
+def _retry_delay(error: grpc.RpcError) -> Optional[float]:
+    """Returns the delay of the RetryInfo detail of error, if any."""
+    try:
+        status = rpc_status.from_call(error)
+    except ValueError:
+        return None
+    if status is None:
+        return None
+    for detail in status.details:
+        if detail.Is(error_details_pb2.RetryInfo.DESCRIPTOR):
+            info = error_details_pb2.RetryInfo()
+            detail.Unpack(info)
+            return info.retry_delay.ToTimedelta().total_seconds()
+    return None
@@ -0,0 +1,105 @@
+"""CompanyClient, the entry point of the package."""
+
+from typing import Callable, Iterable, Iterator, Optional, TypeVar
+
+import grpc
+
+from definition.v1 import api_pb2 as v1
+from definition.v1 import api_pb2_grpc as v1_grpc
+from definition.v2 import api_pb2 as v2
+from definition.v2 import api_pb2_grpc as v2_grpc
+
+from ._interceptors import AuthInterceptor, RetryInterceptor, RetryPolicy, TokenSource
+
+T = TypeVar("T")
+
+# Page size the iterators ask for; the server caps it at 100.
+DEFAULT_PAGE_SIZE = 100
+
+
+class CompanyClient:
+    """Client of the company service gRPC API.
+
+    Every call sends the token or API key and is retried according to retry.
+    The generated stubs are available as ``v1`` and ``v2`` for all RPCs; the
+    ``iter_`` methods page through list results::
+
+        with CompanyClient("companies.example.com:443", token=get_jwt) as client:
+            for company in client.iter_companies(statuses=[v1.ACTIVE]):
+                print(company.name)
+    """
+
+    def __init__(
+        self,
+        target: str,
+        *,
+        token: Optional[TokenSource] = None,
+        api_key: Optional[str] = None,
+        credentials: Optional[grpc.ChannelCredentials] = None,
+        insecure: bool = False,
+        retry: RetryPolicy = RetryPolicy(),
+        timeout: Optional[float] = 30.0,
+        channel: Optional[grpc.Channel] = None,
+    ):
+        """Connects to target, over TLS unless insecure is set.
+
+        token is a JWT, or a callable returning the current one. channel
+        replaces the connection, e.g. in tests; it is still intercepted.
+        """
+        if channel is None:
+            if insecure:
+                channel = grpc.insecure_channel(target)
+            else:
+                channel = grpc.secure_channel(target, credentials or grpc.ssl_channel_credentials())
+        self._channel = channel
+        self._timeout = timeout
+        intercepted = grpc.intercept_channel(
+            channel, AuthInterceptor(token, api_key), RetryInterceptor(retry)
+        )
+        self.v1 = v1_grpc.CompanyServiceStub(intercepted)
+        self.v2 = v2_grpc.CompanyServiceStub(intercepted)
+
+    def close(self) -> None:
+        self._channel.close()
+
+    def __enter__(self) -> "CompanyClient":
+        return self
+
+    def __exit__(self, *exc) -> None:
+        self.close()
+
+    def get_company(self, company_id: str) -> v1.Company:
+        return self.v1.GetCompany(v1.GetCompanyRequest(id=company_id), timeout=self._timeout).company
+
+    def iter_companies(
+        self,
+        employee_ranges: Iterable[int] = (),
+        statuses: Iterable[int] = (),
+        page_size: int = DEFAULT_PAGE_SIZE,
+    ) -> Iterator[v1.Company]:
+        """Yields every company matching the filters, oldest first."""
+        request = v1.ListCompaniesRequest(
+            page_size=page_size, employee_ranges=employee_ranges, statuses=statuses
+        )
+        return self._paginate(self.v1.ListCompanies, request, lambda r: r.companies)
+
+    def iter_my_companies(
+        self, statuses: Iterable[int] = (), page_size: int = DEFAULT_PAGE_SIZE
+    ) -> Iterator[v1.Company]:
+        """Yields every company created by the caller in one of statuses."""
+        request = v1.ListMyCompaniesRequest(page_size=page_size, statuses=statuses)
+        return self._paginate(self.v1.ListMyCompanies, request, lambda r: r.companies)
+
+    def search_companies(self, query: str, page_size: int = DEFAULT_PAGE_SIZE) -> Iterator[v2.Company]:
+        """Yields every company whose name contains query, ignoring case."""
+        request = v2.SearchCompaniesRequest(query=query, page_size=page_size)
+        return self._paginate(self.v2.SearchCompanies, request, lambda r: r.companies)
+
+    def _paginate(self, method: Callable, request, items: Callable[[object], Iterable[T]]) -> Iterator[T]:
+        """Calls method with request page after page, yielding the items of each."""
+        while True:
+            response = method(request, timeout=self._timeout)
+            yield from items(response)
+            if not response.next_page_token:
+                return
+            request.page_token = response.next_page_token
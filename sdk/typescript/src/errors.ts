@@ -0,0 +1,77 @@
+import type { JsonObject, JsonValue } from "@bufbuild/protobuf";
+
+const errorInfoType = "type.googleapis.com/google.rpc.ErrorInfo";
+const badRequestType = "type.googleapis.com/google.rpc.BadRequest";
+
+/** An invalid request field, from the google.rpc.BadRequest detail. */
+export interface FieldViolation {
+  /** Field name as in the API, e.g. "contact_email". */
+  field: string;
+  /** Error code of the rule the field breaks, e.g. "NAME_TOO_LONG". */
+  reason: string;
+  description: string;
+}
+
+/**
+ * ApiError is thrown for every non-2xx gateway response. It exposes the JSON
+ * error envelope of the gateway; match on `reason` or `errorCode`, not on the
+ * message, which may be translated.
+ */
+export class ApiError extends Error {
+  /** HTTP status of the response. */
+  readonly status: number;
+  /** Canonical gRPC status name, e.g. "NOT_FOUND". */
+  readonly code: string;
+  /** Status details, each tagged with its "@type". */
+  readonly details: JsonObject[];
+  readonly requestId: string;
+  /** Seconds to wait before retrying, from the Retry-After header. */
+  readonly retryAfter?: number;
+
+  constructor(status: number, body: JsonValue, headers: Headers) {
+    const envelope = isObject(body) ? body : {};
+    super(typeof envelope.message === "string" ? envelope.message : `HTTP ${status}`);
+    this.name = "ApiError";
+    this.status = status;
+    this.code = typeof envelope.code === "string" ? envelope.code : "UNKNOWN";
+    this.details = Array.isArray(envelope.details) ? envelope.details.filter(isObject) : [];
+    this.requestId = typeof envelope.request_id === "string" ? envelope.request_id : headers.get("x-request-id") ?? "";
+    const retryAfter = Number(headers.get("retry-after") ?? NaN);
+    if (Number.isFinite(retryAfter)) {
+      this.retryAfter = retryAfter;
+    }
+  }
+
+  /** Broad error reason, e.g. "DUPLICATE_NAME". */
+  get reason(): string | undefined {
+    const reason = this.detail(errorInfoType)?.reason;
+    return typeof reason === "string" ? reason : undefined;
+  }
+
+  /** Stable error code, e.g. "NAME_TAKEN"; GET /v1/errors lists them all. */
+  get errorCode(): string | undefined {
+    const metadata = this.detail(errorInfoType)?.metadata;
+    return isObject(metadata) && typeof metadata.code === "string" ? metadata.code : undefined;
+  }
+
+  /** Every invalid field of the request, for highlighting them in forms. */
+  get fieldViolations(): FieldViolation[] {
+    const violations = this.detail(badRequestType)?.fieldViolations;
+    if (!Array.isArray(violations)) {
+      return [];
+    }
+    return violations.filter(isObject).map((v) => ({
+      field: String(v.field ?? ""),
+      reason: String(v.reason ?? ""),
+      description: String(v.description ?? ""),
+    }));
+  }
+
+  private detail(type: string): JsonObject | undefined {
+    return this.details.find((d) => d["@type"] === type);
+  }
+}
+
+function isObject(value: JsonValue | undefined): value is JsonObject {
+  return typeof value === "object" && value !== null && !Array.isArray(value);
+}
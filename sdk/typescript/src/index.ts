@@ -0,0 +1,36 @@
+import { CompanyService } from "./gen/definition/v1/api_pb.js";
+import { CompanyService as CompanyServiceV2 } from "./gen/definition/v2/api_pb.js";
+import { createRestClient } from "./rest.js";
+import type { ClientOptions, RestClient } from "./rest.js";
+
+export { ApiError } from "./errors.js";
+export type { FieldViolation } from "./errors.js";
+export { createRestClient } from "./rest.js";
+export type { CallOptions, ClientOptions, RestClient, TokenSource } from "./rest.js";
+
+/** Messages and enums of definition.v1, e.g. v1.Company and v1.CompanyType. */
+export * as v1 from "./gen/definition/v1/api_pb.js";
+/** Messages of definition.v2. */
+export * as v2 from "./gen/definition/v2/api_pb.js";
+
+/** Client of the v1 company API. */
+export type CompanyClient = RestClient<typeof CompanyService>;
+
+/** Client of the v2 company API. */
+export type CompanyClientV2 = RestClient<typeof CompanyServiceV2>;
+
+/**
+ * createCompanyClient returns a client of the v1 company API served by the
+ * gateway at options.baseUrl.
+ *
+ *     const client = createCompanyClient({ baseUrl, token: () => session.jwt });
+ *     const { company } = await client.getCompany({ id });
+ */
+export function createCompanyClient(options: ClientOptions): CompanyClient {
+  return createRestClient(CompanyService, options);
+}
+
+/** createCompanyClientV2 returns a client of the v2 company API. */
+export function createCompanyClientV2(options: ClientOptions): CompanyClientV2 {
+  return createRestClient(CompanyServiceV2, options);
+}
@@ -0,0 +1,178 @@
+import { create, fromJson, getOption, toJson } from "@bufbuild/protobuf";
+import type {
+  DescMessage,
+  DescMethod,
+  DescMethodUnary,
+  DescService,
+  JsonObject,
+  JsonValue,
+  MessageInitShape,
+  MessageShape,
+} from "@bufbuild/protobuf";
+import { http } from "./gen/google/api/annotations_pb.js";
+import type { HttpRule } from "./gen/google/api/http_pb.js";
+import { ApiError } from "./errors.js";
+
+/** A JWT, or a function returning the current one, e.g. after a refresh. */
+export type TokenSource = string | (() => string | undefined | Promise<string | undefined>);
+
+export interface ClientOptions {
+  /** Origin of the HTTP gateway, e.g. "https://companies.example.com". */
+  baseUrl: string;
+  /** JWT sent as a bearer token with every call. */
+  token?: TokenSource;
+  /** API key sent as x-api-key, for service callers without a JWT. */
+  apiKey?: string;
+  /** Sent as Accept-Language to get translated error messages. */
+  language?: string;
+  /** Headers added to every call. */
+  headers?: HeadersInit;
+  /** Replaces the global fetch, e.g. in tests or older runtimes. */
+  fetch?: typeof globalThis.fetch;
+}
+
+export interface CallOptions {
+  signal?: AbortSignal;
+  /** Headers added to this call, e.g. x-request-id or If-Match. */
+  headers?: HeadersInit;
+}
+
+/** A client with one method per unary RPC of S that has an HTTP route. */
+export type RestClient<S extends DescService> = {
+  [K in keyof S["method"]]: S["method"][K] extends DescMethodUnary<infer I, infer O>
+    ? (request?: MessageInitShape<I>, options?: CallOptions) => Promise<MessageShape<O>>
+    : never;
+};
+
+/**
+ * createRestClient returns a client calling the methods of service through
+ * the HTTP gateway. Each call follows the google.api.http rule of its method:
+ * path parameters are taken from the request, the body is the field the rule
+ * names, and the remaining fields are sent as query parameters. Responses are
+ * decoded into the generated message types; errors throw an ApiError.
+ */
+export function createRestClient<S extends DescService>(service: S, options: ClientOptions): RestClient<S> {
+  const client: Record<string, unknown> = {};
+  for (const method of service.methods) {
+    const rule = getOption(method, http);
+    if (method.methodKind !== "unary" || rule.pattern.case === undefined) {
+      continue;
+    }
+    client[method.localName] = (request?: MessageInitShape<DescMessage>, call?: CallOptions) =>
+      invoke(method, rule, request, options, call);
+  }
+  return client as RestClient<S>;
+}
+
+async function invoke(
+  method: DescMethod,
+  rule: HttpRule,
+  request: MessageInitShape<DescMessage> | undefined,
+  options: ClientOptions,
+  call: CallOptions | undefined,
+): Promise<unknown> {
+  const fields = toJson(method.input, create(method.input, request), { useProtoFieldName: true }) as JsonObject;
+  const [verb, template] = route(rule);
+  const path = template.replace(/\{([^}=]+)(=[^}]*)?\}/g, (_, name: string) =>
+    encodeURIComponent(String(takeField(fields, name) ?? "")),
+  );
+
+  let body: JsonValue | undefined;
+  if (rule.body === "*") {
+    body = fields;
+  } else if (rule.body !== "") {
+    body = takeField(fields, rule.body) ?? {};
+  }
+  const query = new URLSearchParams();
+  if (rule.body !== "*") {
+    for (const [name, value] of Object.entries(fields)) {
+      appendQuery(query, name, value);
+    }
+  }
+
+  const headers = new Headers(options.headers);
+  new Headers(call?.headers).forEach((value, key) => headers.set(key, value));
+  const token = typeof options.token === "function" ? await options.token() : options.token;
+  if (token) {
+    headers.set("Authorization", `Bearer ${token}`);
+  }
+  if (options.apiKey) {
+    headers.set("x-api-key", options.apiKey);
+  }
+  if (options.language) {
+    headers.set("Accept-Language", options.language);
+  }
+  if (body !== undefined) {
+    headers.set("Content-Type", "application/json");
+  }
+
+  const search = query.toString();
+  const url = options.baseUrl.replace(/\/+$/, "") + path + (search === "" ? "" : `?${search}`);
+  const fetchFn = options.fetch ?? globalThis.fetch.bind(globalThis);
+  const response = await fetchFn(url, {
+    method: verb,
+    headers,
+    body: body === undefined ? undefined : JSON.stringify(body),
+    signal: call?.signal,
+  });
+  const text = await response.text();
+  let json: JsonValue = {};
+  if (text !== "") {
+    try {
+      json = JSON.parse(text) as JsonValue;
+    } catch {
+      // Errors written before the gateway, e.g. by a proxy, are not JSON.
+      json = { message: text };
+    }
+  }
+  if (!response.ok) {
+    throw new ApiError(response.status, json, response.headers);
+  }
+  return fromJson(method.output, json, { ignoreUnknownFields: true });
+}
+
+/** route returns the HTTP verb and path template of rule. */
+function route(rule: HttpRule): [string, string] {
+  switch (rule.pattern.case) {
+    case "get":
+    case "put":
+    case "post":
+    case "delete":
+    case "patch":
+      return [rule.pattern.case.toUpperCase(), rule.pattern.value];
+    case "custom":
+      return [rule.pattern.value.kind, rule.pattern.value.path];
+    default:
+      throw new Error("method has no HTTP route");
+  }
+}
+
+/** takeField removes the field at the dotted path from fields and returns it. */
+function takeField(fields: JsonObject, path: string): JsonValue | undefined {
+  const names = path.split(".");
+  let parent: JsonObject = fields;
+  for (const name of names.slice(0, -1)) {
+    const next = parent[name];
+    if (typeof next !== "object" || next === null || Array.isArray(next)) {
+      return undefined;
+    }
+    parent = next;
+  }
+  const last = names[names.length - 1];
+  const value = parent[last];
+  delete parent[last];
+  return value;
+}
+
+/** appendQuery adds value as query parameters, flattening messages to dotted names. */
+function appendQuery(query: URLSearchParams, name: string, value: JsonValue): void {
+  if (Array.isArray(value)) {
+    value.forEach((v) => appendQuery(query, name, v));
+  } else if (typeof value === "object" && value !== null) {
+    for (const [field, v] of Object.entries(value)) {
+      appendQuery(query, `${name}.${field}`, v);
+    }
+  } else if (value !== null) {
+    query.append(name, String(value));
+  }
+}